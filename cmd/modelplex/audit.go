@@ -0,0 +1,41 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/modelplex/modelplex/internal/audit"
+)
+
+// auditCommand groups the "modelplex audit" subcommands.
+type auditCommand struct {
+	Verify auditVerifyCommand `command:"verify" description:"Check that an audit journal is intact"`
+}
+
+// auditVerifyCommand implements "modelplex audit verify".
+type auditVerifyCommand struct {
+	Args struct {
+		File string `positional-arg-name:"FILE" required:"yes" description:"Audit journal, as in audit.path"`
+	} `positional-args:"yes"`
+
+	out io.Writer
+}
+
+// Execute checks the hash chain of the journal, failing at the first record that was altered,
+// removed or reordered. The hash at the end of the chain is printed so it can be kept elsewhere,
+// which makes truncating the journal evident too.
+func (c *auditVerifyCommand) Execute(_ []string) error {
+	file, err := os.Open(c.Args.File) // #nosec G304 -- journal path is provided by user via CLI argument
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	records, last, err := audit.Verify(file)
+	if err != nil {
+		return fmt.Errorf("%s: %w (%d records before it are intact)", c.Args.File, err, records)
+	}
+	_, err = fmt.Fprintf(c.out, "ok: %d records, last hash %s\n", records, last)
+	return err
+}
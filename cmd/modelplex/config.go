@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/jessevdk/go-flags"
+	"github.com/pelletier/go-toml/v2"
+
+	"github.com/modelplex/modelplex/internal/catalog"
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/secrets"
+)
+
+// configCommand groups the "modelplex config" subcommands.
+type configCommand struct {
+	Print   configPrintCommand   `command:"print" description:"Print the effective configuration with secrets redacted"`
+	Migrate configMigrateCommand `command:"migrate" description:"Upgrade the config file to the current format"`
+	Import  configImportCommand  `command:"import" description:"Convert another proxy's model list into providers"`
+}
+
+// configPrintCommand implements "modelplex config print".
+type configPrintCommand struct {
+	Resolved bool `long:"resolved" description:"Expand env vars and secret references and fill in defaults"`
+
+	// opts holds the global options, which go-flags parses before running the command
+	opts *Options
+	out  io.Writer
+}
+
+// Execute prints the configuration named by the global --config option.
+func (c *configPrintCommand) Execute(_ []string) error {
+	passphrase, err := loadPassphrase(c.opts.PassphraseFile)
+	if err != nil {
+		return err
+	}
+	return printConfig(context.Background(), c.out, c.opts.Config, passphrase, c.Resolved)
+}
+
+// configMigrateCommand implements "modelplex config migrate".
+type configMigrateCommand struct {
+	DryRun bool `long:"dry-run" description:"Print the migrated config instead of writing it"`
+
+	opts *Options
+	out  io.Writer
+}
+
+// Execute migrates the local config file named by the global --config option.
+func (c *configMigrateCommand) Execute(_ []string) error {
+	return migrateConfig(c.out, c.opts.Config, c.DryRun)
+}
+
+// configImportCommand implements "modelplex config import".
+type configImportCommand struct {
+	From string `long:"from" required:"yes" choice:"litellm" choice:"openrouter" description:"Format of the file"`
+	Args struct {
+		File string `positional-arg-name:"FILE" required:"yes" description:"LiteLLM config or OpenRouter model list"`
+	} `positional-args:"yes"`
+
+	out io.Writer
+}
+
+// Execute writes the providers converted from the file as TOML, and what needs checking to stderr.
+func (c *configImportCommand) Execute(_ []string) error {
+	data, err := os.ReadFile(c.Args.File) // #nosec G304 -- import file path is provided by user via CLI flag
+	if err != nil {
+		return err
+	}
+	return importConfig(c.out, os.Stderr, c.From, data)
+}
+
+// addCommands registers the subcommands; running without one starts the server.
+func addCommands(parser *flags.Parser, opts *Options, out io.Writer) error {
+	parser.SubcommandsOptional = true
+
+	cmd := &configCommand{
+		Print:   configPrintCommand{opts: opts, out: out},
+		Migrate: configMigrateCommand{opts: opts, out: out},
+		Import:  configImportCommand{out: out},
+	}
+	if _, err := parser.AddCommand("config", "Inspect configuration", "Inspect the configuration", cmd); err != nil {
+		return err
+	}
+
+	_, err := parser.AddCommand("eval", "Run an eval suite",
+		"Run a YAML suite of prompts and assertions against the models of a running server",
+		&evalCommand{opts: opts, out: out})
+	if err != nil {
+		return err
+	}
+
+	_, err = parser.AddCommand("doctor", "Diagnose the installation",
+		"Check the config, environment, socket, provider reachability, clock skew and MCP servers",
+		&doctorCommand{opts: opts, out: out})
+	if err != nil {
+		return err
+	}
+
+	_, err = parser.AddCommand("audit", "Inspect the audit journal", "Inspect the tamper-evident audit journal",
+		&auditCommand{Verify: auditVerifyCommand{out: out}})
+	if err != nil {
+		return err
+	}
+
+	_, err = parser.AddCommand("ping", "Check that the server is healthy",
+		"Check the health endpoint of the server on --socket or --http, for container health checks",
+		&pingCommand{opts: opts, out: out})
+	return err
+}
+
+// printConfig writes the configuration at location as TOML with credentials redacted.
+// With resolved set it shows what the server would actually run with.
+func printConfig(ctx context.Context, out io.Writer, location, passphrase string, resolved bool) error {
+	cfg, err := config.LoadFrom(ctx, location)
+	if err != nil {
+		return err
+	}
+
+	if resolved {
+		if err := secrets.NewResolver(passphrase).ResolveConfig(ctx, cfg); err != nil {
+			return err
+		}
+		config.ApplyDefaults(cfg)
+		catalog.Apply(cfg)
+	}
+
+	return toml.NewEncoder(out).Encode(config.Redact(cfg))
+}
+
+// importedProvider is the part of a provider an import sets, so the output stays as short as a
+// hand-written config.
+type importedProvider struct {
+	Name         string            `toml:"name"`
+	Type         string            `toml:"type"`
+	BaseURL      string            `toml:"base_url"`
+	APIKey       string            `toml:"api_key,omitempty"`
+	Models       []string          `toml:"models"`
+	Priority     int               `toml:"priority"`
+	ExtraHeaders map[string]string `toml:"extra_headers,omitempty"`
+	ExtraQuery   map[string]string `toml:"extra_query,omitempty"`
+	ModelMap     map[string]string `toml:"model_map,omitempty"`
+}
+
+// importConfig converts data in format into a config file written to out, with warnings written to
+// warnings. The result is checked like any config before it is written.
+func importConfig(out, warnings io.Writer, format string, data []byte) error {
+	var imported *config.Imported
+	var err error
+	switch format {
+	case "litellm":
+		imported, err = config.ImportLiteLLM(data)
+	case "openrouter":
+		imported, err = config.ImportOpenRouter(data)
+	default:
+		err = fmt.Errorf("unknown format %q, expected one of %s", format, strings.Join(config.ImportFormats, ", "))
+	}
+	if err != nil {
+		return err
+	}
+
+	file := struct {
+		Version   int                `toml:"version"`
+		Providers []importedProvider `toml:"providers"`
+	}{Version: config.CurrentVersion}
+	for _, p := range imported.Providers {
+		file.Providers = append(file.Providers, importedProvider{
+			Name: p.Name, Type: p.Type, BaseURL: p.BaseURL, APIKey: p.APIKey, Models: p.Models, Priority: p.Priority,
+			ExtraHeaders: p.ExtraHeaders, ExtraQuery: p.ExtraQuery, ModelMap: p.ModelMap,
+		})
+	}
+	encoded, err := toml.Marshal(file)
+	if err != nil {
+		return err
+	}
+
+	// Refuse to write anything the server would not load
+	cfg, err := config.Parse(encoded)
+	if err != nil {
+		return fmt.Errorf("imported config does not parse: %w", err)
+	}
+	config.ApplyDefaults(cfg)
+	if err := config.Validate(cfg); err != nil {
+		return fmt.Errorf("imported config is invalid: %w", err)
+	}
+
+	for _, warning := range imported.Warnings {
+		if _, err := fmt.Fprintln(warnings, "warning:", warning); err != nil {
+			return err
+		}
+	}
+	_, err = out.Write(encoded)
+	return err
+}
+
+// migrateConfig upgrades the config file at path in place, keeping the original next to it with a .bak suffix.
+func migrateConfig(out io.Writer, path string, dryRun bool) error {
+	if config.IsRemote(path) {
+		return errors.New("migrate only supports local config files; migrate the stored value and write it back")
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- config file path is provided by user via CLI flag
+	if err != nil {
+		return err
+	}
+
+	migrated, applied, err := config.Migrate(data)
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		_, err = fmt.Fprintf(out, "%s is already at version %d\n", path, config.CurrentVersion)
+		return err
+	}
+
+	// Refuse to write anything the server would not load
+	if _, err := config.Parse(migrated); err != nil {
+		return fmt.Errorf("migrated config does not parse: %w", err)
+	}
+
+	if dryRun {
+		_, err = out.Write(migrated)
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path+".bak", data, info.Mode().Perm()); err != nil {
+		return fmt.Errorf("failed to write backup: %w", err)
+	}
+	if err := os.WriteFile(path, migrated, info.Mode().Perm()); err != nil {
+		return err
+	}
+
+	for _, step := range applied {
+		if _, err := fmt.Fprintln(out, step); err != nil {
+			return err
+		}
+	}
+	_, err = fmt.Fprintf(out, "Migrated %s to version %d (backup at %s.bak)\n", path, config.CurrentVersion, path)
+	return err
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/modelplex/modelplex/internal/doctor"
+)
+
+// doctorCommand implements "modelplex doctor".
+type doctorCommand struct {
+	NoColor bool `long:"no-color" description:"Don't color the report"`
+
+	opts *Options
+	out  io.Writer
+}
+
+// Execute diagnoses the config named by the global --config option, served on the global --socket
+// when it is set. Any failed check makes the command fail so it can gate deployments.
+func (c *doctorCommand) Execute(_ []string) error {
+	passphrase, err := loadPassphrase(c.opts.PassphraseFile)
+	if err != nil {
+		return err
+	}
+
+	report := doctor.New(c.opts.Config, passphrase, c.opts.Socket).Run(context.Background())
+	if err := report.WriteText(c.out, !c.NoColor && isTerminal(c.out)); err != nil {
+		return err
+	}
+	if failed := report.Failed(); failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", failed, len(report.Checks))
+	}
+	return nil
+}
+
+// isTerminal reports whether out is a terminal, where colors can be shown.
+func isTerminal(out io.Writer) bool {
+	file, ok := out.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
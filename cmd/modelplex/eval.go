@@ -0,0 +1,59 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/eval"
+)
+
+// evalCommand implements "modelplex eval".
+type evalCommand struct {
+	Server  string        `long:"server" default:"http://localhost:41041" description:"Server URL to evaluate"`
+	Timeout time.Duration `long:"timeout" default:"2m" description:"Timeout for each completion request"`
+	JSON    bool          `long:"json" description:"Write the report as JSON"`
+	Args    struct {
+		Suite string `positional-arg-name:"SUITE" required:"yes" description:"YAML suite file"`
+	} `positional-args:"yes"`
+
+	opts *Options
+	out  io.Writer
+}
+
+// Execute runs the suite against the running server, over the global --socket when it is set.
+// Any failed check makes the command fail so it can gate CI.
+func (c *evalCommand) Execute(_ []string) error {
+	suite, err := eval.Load(c.Args.Suite)
+	if err != nil {
+		return err
+	}
+
+	client := &http.Client{Timeout: c.Timeout}
+	baseURL := c.Server
+	if c.opts.Socket != "" {
+		client.Transport = socketTransport(c.opts.Socket)
+		baseURL = "http://unix"
+	}
+
+	report := eval.NewRunner(client, baseURL).Run(context.Background(), suite)
+	if err := writeReport(c.out, report, c.JSON); err != nil {
+		return err
+	}
+	if report.Failed > 0 {
+		return fmt.Errorf("%d of %d checks failed", report.Failed, report.Passed+report.Failed)
+	}
+	return nil
+}
+
+func writeReport(out io.Writer, report *eval.Report, asJSON bool) error {
+	if !asJSON {
+		return report.WriteText(out)
+	}
+	encoder := json.NewEncoder(out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
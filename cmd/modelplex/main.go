@@ -3,17 +3,25 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"net"
 	"os"
 	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/jessevdk/go-flags"
 
 	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/secrets"
 	"github.com/modelplex/modelplex/internal/server"
+	"github.com/modelplex/modelplex/internal/version"
 )
 
 const (
@@ -23,17 +31,20 @@ const (
 
 // Options defines command line options
 type Options struct {
-	Config  string `short:"c" long:"config" default:"config.toml" description:"Path to configuration file"`
-	Socket  string `short:"s" long:"socket" description:"Path to Unix socket (optional, HTTP server used by default)"`
+	Config  string `short:"c" long:"config" default:"config.toml" description:"Config file path or consul/etcd key URL"`
+	Socket  string `short:"s" long:"socket" description:"Unix socket path, @name for abstract (optional, HTTP by default)"`
 	HTTP    string `long:"http" default:":41041" description:"HTTP server address in [HOST]:PORT format"`
 	Verbose bool   `short:"v" long:"verbose" description:"Enable verbose logging"`
 	Version bool   `long:"version" description:"Show version information"`
+
+	PassphraseFile string `long:"passphrase-file" description:"Passphrase file for encrypted secrets"`
+	EncryptSecret  bool   `long:"encrypt-secret" description:"Encrypt a secret read from stdin for use in config"`
+	ReadOnly       bool   `long:"read-only" description:"Reject admin mutations and MCP tool calls"`
 }
 
 var (
-	version = "dev"
-	commit  = "unknown"
-	date    = "unknown"
+	// logLevel is shared by every handler so it can follow the config after startup
+	logLevel = new(slog.LevelVar)
 )
 
 func main() {
@@ -41,6 +52,10 @@ func main() {
 	parser := flags.NewParser(&opts, flags.Default)
 	parser.Name = "modelplex"
 	parser.Usage = "[OPTIONS]"
+	if err := addCommands(parser, &opts, os.Stdout); err != nil {
+		slog.Error("Failed to register commands", "error", err)
+		os.Exit(1)
+	}
 
 	_, err := parser.Parse()
 	if err != nil {
@@ -49,33 +64,46 @@ func main() {
 		}
 		os.Exit(1)
 	}
+	if parser.Active != nil {
+		// A subcommand already ran as part of parsing
+		os.Exit(0)
+	}
 
 	if opts.Version {
-		fmt.Printf("modelplex %s\n", version)
-		fmt.Printf("commit: %s\n", commit)
-		fmt.Printf("built: %s\n", date)
+		fmt.Printf("modelplex %s\n", version.Version)
+		fmt.Printf("commit: %s\n", version.Commit)
+		fmt.Printf("built: %s\n", version.Date)
 		os.Exit(0)
 	}
 
-	if opts.Verbose {
-		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-			Level:     slog.LevelDebug,
-			AddSource: true,
-		})))
-		slog.Info("Verbose logging enabled")
-	} else {
-		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
-			Level: slog.LevelInfo,
-		})))
+	setupLogging(opts.Verbose)
+
+	passphrase, err := loadPassphrase(opts.PassphraseFile)
+	if err != nil {
+		slog.Error("Failed to read passphrase", "file", opts.PassphraseFile, "error", err)
+		os.Exit(1)
 	}
 
-	cfg, err := config.Load(opts.Config)
+	if opts.EncryptSecret {
+		if err := encryptSecret(os.Stdin, os.Stdout, passphrase); err != nil {
+			slog.Error("Failed to encrypt secret", "error", err)
+			os.Exit(1)
+		}
+		os.Exit(0)
+	}
+
+	cfg, err := loadConfig(&opts, passphrase)
 	if err != nil {
-		slog.Error("Failed to load config", "file", opts.Config, "error", err)
+		logProblems("Failed to load config", opts.Config, err)
 		os.Exit(1)
 	}
+	if !opts.Verbose {
+		// Validation guarantees the level parses
+		_ = logLevel.UnmarshalText([]byte(cfg.Server.LogLevel))
+	}
 
 	slog.Info("Loaded configuration", "file", opts.Config)
+	logBanner(cfg)
 
 	var srv *server.Server
 	if opts.Socket != "" {
@@ -86,6 +114,11 @@ func main() {
 		srv = server.NewWithHTTPAddress(cfg, opts.HTTP)
 	}
 
+	ready, err := inheritListener(srv)
+	if err != nil {
+		slog.Error("Failed to inherit listener", "error", err)
+		os.Exit(1)
+	}
 	done := srv.Start()
 	select {
 	case err := <-done:
@@ -101,13 +134,172 @@ func main() {
 	} else {
 		slog.Info("Server started successfully", "address", opts.HTTP)
 	}
+	ready()
+
+	watchCtx, stopWatch := context.WithCancel(context.Background())
+	defer stopWatch()
+	if config.IsRemote(opts.Config) {
+		go watchConfig(watchCtx, opts.Config, passphrase, srv)
+	}
 
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, append([]os.Signal{syscall.SIGINT, syscall.SIGTERM}, upgradeSignals...)...)
+	for sig := range sigChan {
+		if !slices.Contains(upgradeSignals, sig) {
+			break
+		}
+		slog.Info("Upgrading", "signal", sig)
+		if err := upgrade(srv); err != nil {
+			slog.Error("Upgrade failed, still serving", "error", err)
+			continue
+		}
+
+		slog.Info("Draining after upgrade...", "timeout", drainTimeout)
+		stopWatch()
+		ctx, cancel := context.WithTimeout(context.Background(), drainTimeout)
+		srv.Drain(ctx)
+		cancel()
+		return
+	}
 
 	slog.Info("Shutting down...")
+	stopWatch()
 	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
 	defer cancel()
 	srv.Stop(ctx)
 }
+
+// setupLogging installs the default logger, adding debug output and source locations when verbose.
+// Without --verbose the level follows server.log_level once the config is loaded.
+func setupLogging(verbose bool) {
+	if verbose {
+		logLevel.Set(slog.LevelDebug)
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level:     logLevel,
+			AddSource: true,
+		})))
+		slog.Info("Verbose logging enabled")
+	} else {
+		slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{
+			Level: logLevel,
+		})))
+	}
+}
+
+// loadConfig loads the configuration, unlocks its secrets and applies command line overrides.
+func loadConfig(opts *Options, passphrase string) (*config.Config, error) {
+	cfg, err := config.LoadFrom(context.Background(), opts.Config)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := secrets.NewResolver(passphrase).ResolveConfig(context.Background(), cfg); err != nil {
+		return nil, fmt.Errorf("failed to unlock secrets: %w", err)
+	}
+
+	if opts.ReadOnly {
+		cfg.Server.ReadOnly = true
+	}
+
+	config.ApplyDefaults(cfg)
+	if err := errors.Join(config.Validate(cfg), validateListenAddress(opts)); err != nil {
+		return nil, err
+	}
+	for _, deprecation := range config.Deprecations(cfg) {
+		slog.Warn("Deprecated config setting", "file", opts.Config, "setting", deprecation)
+	}
+	return cfg, nil
+}
+
+// validateListenAddress checks the --http address, which is unused in socket mode.
+func validateListenAddress(opts *Options) error {
+	if opts.Socket != "" {
+		return nil
+	}
+
+	_, port, err := net.SplitHostPort(opts.HTTP)
+	if err != nil {
+		return fmt.Errorf("--http: %w", err)
+	}
+	if _, err := strconv.ParseUint(port, 10, 16); err != nil {
+		return fmt.Errorf("--http: port %q must be a number between 0 and 65535", port)
+	}
+	return nil
+}
+
+// logProblems logs every error joined into err on its own line, so a validation report stays readable.
+func logProblems(msg, file string, err error) {
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		for _, e := range joined.Unwrap() {
+			logProblems(msg, file, e)
+		}
+		return
+	}
+	slog.Error(msg, "file", file, "error", err)
+}
+
+// logBanner logs the build and the shape of the configuration being served, which helps when reading bug reports.
+func logBanner(cfg *config.Config) {
+	slog.Info("Starting modelplex",
+		"version", version.Version,
+		"commit", version.Commit,
+		"built", version.Date,
+		"providers", len(cfg.Providers),
+		"mcp_servers", len(cfg.MCP.Servers),
+		"cache", cfg.Cache.Enabled,
+		"read_only", cfg.Server.ReadOnly,
+		"strict_privacy", cfg.Privacy.Strict,
+	)
+}
+
+// watchConfig applies every change to a remote configuration until ctx is cancelled.
+func watchConfig(ctx context.Context, location, passphrase string, srv *server.Server) {
+	err := config.Watch(ctx, location, func(cfg *config.Config) {
+		if err := secrets.NewResolver(passphrase).ResolveConfig(ctx, cfg); err != nil {
+			slog.Error("Ignoring remote config with unresolvable secrets", "error", err)
+			return
+		}
+		config.ApplyDefaults(cfg)
+		if err := config.Validate(cfg); err != nil {
+			logProblems("Ignoring invalid remote config", location, err)
+			return
+		}
+		srv.Reload(cfg)
+	})
+	if err != nil && ctx.Err() == nil {
+		slog.Error("Config watch stopped", "error", err)
+	}
+}
+
+// loadPassphrase reads the secrets passphrase from a file, falling back to the environment.
+func loadPassphrase(path string) (string, error) {
+	if path == "" {
+		return os.Getenv(secrets.PassphraseEnv), nil
+	}
+
+	data, err := os.ReadFile(path) // #nosec G304 -- passphrase file path is provided by user via CLI flag
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// encryptSecret reads a single secret from in and writes its encrypted form to out.
+func encryptSecret(in io.Reader, out io.Writer, passphrase string) error {
+	if passphrase == "" {
+		return secrets.ErrPassphraseRequired
+	}
+
+	data, err := io.ReadAll(in)
+	if err != nil {
+		return err
+	}
+
+	encrypted, err := secrets.Encrypt(strings.TrimRight(string(data), "\r\n"), passphrase)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintln(out, encrypted)
+	return err
+}
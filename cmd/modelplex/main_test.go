@@ -1,11 +1,24 @@
 package main
 
 import (
+	"bytes"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/jessevdk/go-flags"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/audit"
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/secrets"
+	"github.com/modelplex/modelplex/internal/version"
 )
 
 func TestOptions_DefaultValues(t *testing.T) {
@@ -102,9 +115,9 @@ func TestOptions_InvalidFlag(t *testing.T) {
 
 func TestVersionVariables(t *testing.T) {
 	// Test that version variables are defined
-	assert.NotEmpty(t, version)
-	assert.NotEmpty(t, commit)
-	assert.NotEmpty(t, date)
+	assert.NotEmpty(t, version.Version)
+	assert.NotEmpty(t, version.Commit)
+	assert.NotEmpty(t, version.Date)
 }
 
 func TestOptionsStructTags(t *testing.T) {
@@ -122,3 +135,231 @@ func TestOptionsStructTags(t *testing.T) {
 	require.True(t, ok)
 	assert.Equal(t, flags.ErrHelp, flagsErr.Type)
 }
+
+func TestEncryptSecret(t *testing.T) {
+	var out bytes.Buffer
+	require.NoError(t, encryptSecret(strings.NewReader("sk-test123\n"), &out, "pass"))
+
+	value := strings.TrimSpace(out.String())
+	assert.True(t, strings.HasPrefix(value, secrets.EncryptedPrefix))
+
+	plaintext, err := secrets.Decrypt(value, "pass")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-test123", plaintext)
+
+	err = encryptSecret(strings.NewReader("sk-test123"), &out, "")
+	assert.ErrorIs(t, err, secrets.ErrPassphraseRequired)
+}
+
+func TestLoadPassphrase(t *testing.T) {
+	t.Setenv(secrets.PassphraseEnv, "from-env")
+
+	passphrase, err := loadPassphrase("")
+	require.NoError(t, err)
+	assert.Equal(t, "from-env", passphrase)
+
+	path := filepath.Join(t.TempDir(), "passphrase")
+	require.NoError(t, os.WriteFile(path, []byte("from-file\n"), 0o600))
+
+	passphrase, err = loadPassphrase(path)
+	require.NoError(t, err)
+	assert.Equal(t, "from-file", passphrase)
+}
+
+func TestConfigPrintCommand(t *testing.T) {
+	var opts Options
+	parser := flags.NewParser(&opts, flags.Default)
+	var out bytes.Buffer
+	require.NoError(t, addCommands(parser, &opts, &out))
+
+	_, err := parser.ParseArgs([]string{})
+	require.NoError(t, err)
+	assert.Nil(t, parser.Active)
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte("[server]\nlog_level = \"warn\"\n"), 0o600))
+
+	_, err = parser.ParseArgs([]string{"--config", path, "config", "print", "--resolved"})
+	require.NoError(t, err)
+	require.NotNil(t, parser.Active)
+	assert.Equal(t, "config", parser.Active.Name)
+	assert.Contains(t, out.String(), "log_level = 'warn'")
+}
+
+func TestAuditVerifyCommand(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	journal, err := audit.Open(path, false)
+	require.NoError(t, err)
+	require.NoError(t, journal.Append(audit.Record{Tenant: "acme", Operation: audit.OperationChat, Model: "gpt-4"}))
+	require.NoError(t, journal.Close())
+
+	verify := func() (string, error) {
+		var opts Options
+		parser := flags.NewParser(&opts, flags.None)
+		var out bytes.Buffer
+		require.NoError(t, addCommands(parser, &opts, &out))
+		_, err := parser.ParseArgs([]string{"audit", "verify", path})
+		return out.String(), err
+	}
+
+	out, err := verify()
+	require.NoError(t, err)
+	assert.Contains(t, out, "ok: 1 records, last hash ")
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, bytes.Replace(data, []byte("gpt-4"), []byte("gpt-5"), 1), 0o600))
+	_, err = verify()
+	assert.ErrorContains(t, err, "record 1 was altered")
+}
+
+func TestPingCommand(t *testing.T) {
+	healthy := true
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/health", r.URL.Path)
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	})
+	server := httptest.NewServer(handler)
+	defer server.Close()
+	_, port, err := net.SplitHostPort(server.Listener.Addr().String())
+	require.NoError(t, err)
+
+	socketPath := filepath.Join(t.TempDir(), "ping.socket")
+	listener, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	socketServer := &http.Server{Handler: handler, ReadHeaderTimeout: time.Second}
+	go func() { _ = socketServer.Serve(listener) }()
+	defer socketServer.Close()
+
+	ping := func(args ...string) (string, error) {
+		var opts Options
+		parser := flags.NewParser(&opts, flags.None)
+		var out bytes.Buffer
+		require.NoError(t, addCommands(parser, &opts, &out))
+		_, err := parser.ParseArgs(append(args, "ping"))
+		return out.String(), err
+	}
+
+	out, err := ping("--http", ":"+port)
+	require.NoError(t, err)
+	assert.Equal(t, "ok\n", out)
+
+	_, err = ping("--socket", socketPath)
+	require.NoError(t, err)
+
+	healthy = false
+	_, err = ping("--socket", socketPath)
+	assert.EqualError(t, err, "unhealthy: status 503")
+
+	server.Close()
+	_, err = ping("--http", "127.0.0.1:"+port)
+	assert.ErrorContains(t, err, "connection refused")
+}
+
+func TestPrintConfig(t *testing.T) {
+	t.Setenv("TEST_PRINT_API_KEY", "sk-from-env")
+
+	path := filepath.Join(t.TempDir(), "config.toml")
+	data := `
+[[providers]]
+name = "openai"
+type = "openai"
+base_url = "https://api.openai.com/v1"
+api_key = "${TEST_PRINT_API_KEY}"
+`
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, printConfig(t.Context(), &out, path, "", false))
+	assert.Contains(t, out.String(), "api_key = 'REDACTED'")
+	assert.NotContains(t, out.String(), "modelplex:")
+
+	out.Reset()
+	require.NoError(t, printConfig(t.Context(), &out, path, "", true))
+	assert.Contains(t, out.String(), "api_key = 'REDACTED'")
+	assert.Contains(t, out.String(), "key_prefix = 'modelplex:'")
+	assert.NotContains(t, out.String(), "sk-from-env")
+
+	err := printConfig(t.Context(), &out, filepath.Join(t.TempDir(), "missing.toml"), "", true)
+	assert.Error(t, err)
+}
+
+func TestMigrateConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.toml")
+	original := []byte("# old config\n[server]\nlog_level = \"warn\"\n")
+	require.NoError(t, os.WriteFile(path, original, 0o600))
+
+	var out bytes.Buffer
+	require.NoError(t, migrateConfig(&out, path, true))
+	assert.Contains(t, out.String(), "version = 1")
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, original, data, "dry run must not write")
+
+	out.Reset()
+	require.NoError(t, migrateConfig(&out, path, false))
+	assert.Contains(t, out.String(), "Migrated")
+
+	backup, err := os.ReadFile(path + ".bak")
+	require.NoError(t, err)
+	assert.Equal(t, original, backup)
+
+	cfg, err := config.Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, config.CurrentVersion, cfg.Version)
+	assert.Equal(t, "warn", cfg.Server.LogLevel)
+
+	out.Reset()
+	require.NoError(t, migrateConfig(&out, path, false))
+	assert.Contains(t, out.String(), "already at version")
+
+	assert.Error(t, migrateConfig(&out, "consul://localhost:8500/modelplex", false))
+}
+
+func TestImportConfig(t *testing.T) {
+	litellm := []byte("model_list:\n" +
+		"  - model_name: fast\n    litellm_params:\n      model: groq/llama-3.1-8b-instant\n" +
+		"  - model_name: other\n    litellm_params:\n      model: vertex_ai/gemini-pro\n")
+
+	var out, warnings bytes.Buffer
+	require.NoError(t, importConfig(&out, &warnings, "litellm", litellm))
+	assert.Contains(t, warnings.String(), `warning: model_list[1] (other): skipped, "vertex_ai"`)
+
+	cfg, err := config.Parse(out.Bytes())
+	require.NoError(t, err)
+	require.Len(t, cfg.Providers, 1)
+	assert.Equal(t, "groq", cfg.Providers[0].Name)
+	assert.Equal(t, "https://api.groq.com/openai/v1", cfg.Providers[0].BaseURL)
+	assert.Equal(t, map[string]string{"fast": "llama-3.1-8b-instant"}, cfg.Providers[0].ModelMap)
+	assert.NotContains(t, out.String(), "timeout", "only imported settings are written")
+
+	assert.Error(t, importConfig(&out, &warnings, "openrouter", []byte("{}")))
+	assert.Error(t, importConfig(&out, &warnings, "portkey", litellm))
+}
+
+func TestValidateListenAddress(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"default", Options{HTTP: ":41041"}, false},
+		{"host and port", Options{HTTP: "0.0.0.0:8080"}, false},
+		{"port out of range", Options{HTTP: ":70000"}, true},
+		{"missing port", Options{HTTP: "localhost"}, true},
+		{"ignored in socket mode", Options{HTTP: "bogus", Socket: "/tmp/modelplex.socket"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateListenAddress(&tt.opts)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
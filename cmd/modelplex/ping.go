@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// pingCommand implements "modelplex ping".
+type pingCommand struct {
+	Timeout time.Duration `long:"timeout" default:"3s" description:"Timeout for the health check"`
+
+	opts *Options
+	out  io.Writer
+}
+
+// Execute checks the health endpoint of the server on the global --socket, or else on the global --http
+// address, so a container health check can take the arguments the server was started with.
+// Anything but a healthy answer makes the command fail.
+func (c *pingCommand) Execute(_ []string) error {
+	client, baseURL, err := serverClient(c.opts, c.Timeout)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), c.Timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, baseURL+"/health", http.NoBody)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unhealthy: status %d", resp.StatusCode)
+	}
+	_, err = fmt.Fprintln(c.out, "ok")
+	return err
+}
+
+// serverClient returns a client for the server on the global --socket or --http address, and its base URL.
+// Wildcard listen addresses are reached through localhost.
+func serverClient(opts *Options, timeout time.Duration) (*http.Client, string, error) {
+	client := &http.Client{Timeout: timeout}
+	if opts.Socket != "" {
+		client.Transport = socketTransport(opts.Socket)
+		return client, "http://unix", nil
+	}
+
+	host, port, err := net.SplitHostPort(opts.HTTP)
+	if err != nil {
+		return nil, "", fmt.Errorf("--http: %w", err)
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		host = "localhost"
+	}
+	return client, "http://" + net.JoinHostPort(host, port), nil
+}
+
+// socketTransport dials the Unix socket at path for every request.
+func socketTransport(path string) *http.Transport {
+	return &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", path)
+		},
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/server"
+)
+
+const (
+	// readyFDEnv names the environment variable telling an upgraded process where to report it is serving
+	readyFDEnv = "MODELPLEX_READY_FD"
+	// upgradeTimeout bounds how long the upgraded process may take to start serving
+	upgradeTimeout = 30 * time.Second
+	// drainTimeout bounds how long the replaced process waits for requests in flight, such as
+	// long agent sessions, after handing over its listener
+	drainTimeout = 5 * time.Minute
+)
+
+// File descriptors of the files handed to the upgraded process, after stdin, stdout and stderr
+const (
+	listenerFD = 3 + iota
+	readyFD
+)
+
+// upgrade starts the current executable again with the same arguments, handing it the listener of srv,
+// and returns once it is serving. srv keeps serving until it is drained, and also when the upgrade fails.
+func upgrade(srv *server.Server) error {
+	executable, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	listener, err := srv.ListenerFile()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = listener.Close() }()
+
+	readyRead, readyWrite, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = readyRead.Close() }()
+
+	cmd := exec.Command(executable, os.Args[1:]...) // #nosec G204 -- re-executes this binary with its own arguments
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(),
+		server.ListenerFDEnv+"="+strconv.Itoa(listenerFD), readyFDEnv+"="+strconv.Itoa(readyFD))
+	cmd.ExtraFiles = []*os.File{listener, readyWrite}
+	err = cmd.Start()
+	// Only the upgraded process holds the write end now, so the read ends when it exits
+	_ = readyWrite.Close()
+	if err != nil {
+		return err
+	}
+	// The upgraded process outlives this one; releasing it lets it be reaped by init
+	pid := cmd.Process.Pid
+	_ = cmd.Process.Release()
+
+	_ = readyRead.SetReadDeadline(time.Now().Add(upgradeTimeout))
+	if _, err := readyRead.Read(make([]byte, 1)); err != nil {
+		if errors.Is(err, io.EOF) {
+			return fmt.Errorf("upgraded process %d exited before serving", pid)
+		}
+		return fmt.Errorf("upgraded process %d is not serving: %w", pid, err)
+	}
+	slog.Info("Upgraded process is serving", "pid", pid)
+	return nil
+}
+
+// inheritListener hands srv the listener of the process being upgraded, if this is the upgraded process.
+// It returns a function reporting that srv is serving, which lets the replaced process drain.
+func inheritListener(srv *server.Server) (ready func(), err error) {
+	fd, ok := os.LookupEnv(server.ListenerFDEnv)
+	if !ok {
+		return func() {}, nil
+	}
+	readyFile, err := inheritedFile(readyFDEnv, "ready")
+	if err != nil {
+		return nil, err
+	}
+	listenerFile, err := inheritedFile(server.ListenerFDEnv, "listener")
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = listenerFile.Close() }()
+	// Not passed on to processes of a later upgrade, which get their own
+	_ = os.Unsetenv(server.ListenerFDEnv)
+	_ = os.Unsetenv(readyFDEnv)
+
+	listener, err := net.FileListener(listenerFile)
+	if err != nil {
+		return nil, fmt.Errorf("invalid inherited listener %s: %w", fd, err)
+	}
+	srv.Inherit(listener)
+	return func() {
+		if _, err := readyFile.Write([]byte{1}); err != nil {
+			slog.Warn("Failed to report serving to the replaced process", "error", err)
+		}
+		_ = readyFile.Close()
+	}, nil
+}
+
+// inheritedFile opens the file descriptor named by the environment variable env.
+func inheritedFile(env, name string) (*os.File, error) {
+	fd, err := strconv.ParseUint(os.Getenv(env), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", env, err)
+	}
+	return os.NewFile(uintptr(fd), name), nil
+}
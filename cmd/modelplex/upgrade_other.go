@@ -0,0 +1,8 @@
+//go:build !unix
+
+package main
+
+import "os"
+
+// upgradeSignals is empty since handing over listeners needs Unix file descriptor inheritance.
+var upgradeSignals []os.Signal
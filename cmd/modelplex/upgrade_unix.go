@@ -0,0 +1,11 @@
+//go:build unix
+
+package main
+
+import (
+	"os"
+	"syscall"
+)
+
+// upgradeSignals ask the server to upgrade to the binary now at its executable path.
+var upgradeSignals = []os.Signal{syscall.SIGUSR2}
@@ -0,0 +1,200 @@
+// Package approval holds sensitive MCP tool calls until an operator approves or denies them. Calls
+// matching a configured rule are parked in a queue the admin API lists; the agent's request waits
+// until a decision, or the timeout, and then goes on to the tool or gets the denial.
+package approval
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+const idBytes = 8
+
+// ErrUnknown is returned when deciding on a call that isn't waiting, or no longer is.
+var ErrUnknown = errors.New("unknown or already decided tool call")
+
+// Call is a tool call waiting for a decision.
+type Call struct {
+	ID        string                 `json:"id"`
+	Tool      string                 `json:"tool"`
+	Arguments map[string]interface{} `json:"arguments,omitempty"`
+	Requested time.Time              `json:"requested_at"`
+}
+
+// Decision is an operator's verdict on a call.
+type Decision struct {
+	Approved bool   `json:"approved"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// rule selects the calls needing approval.
+type rule struct {
+	tool      *regexp.Regexp
+	arguments map[string]*regexp.Regexp
+}
+
+// matches reports whether the call of tool with args falls under the rule. Every argument pattern
+// must match; a missing argument doesn't.
+func (r *rule) matches(tool string, args map[string]interface{}) bool {
+	if !r.tool.MatchString(tool) {
+		return false
+	}
+	for name, pattern := range r.arguments {
+		value, ok := args[name]
+		if !ok || !pattern.MatchString(argumentText(value)) {
+			return false
+		}
+	}
+	return true
+}
+
+// waiting is a parked call and where its decision goes.
+type waiting struct {
+	call     Call
+	decision chan Decision
+}
+
+// Queue parks the calls its rules select until they are decided on.
+type Queue struct {
+	rules   []rule
+	timeout time.Duration
+
+	mtx     sync.Mutex
+	waiting map[string]*waiting
+	// now is swappable so tests can fix request times
+	now func() time.Time
+}
+
+// NewQueue creates a queue with the rules and timeout of cfg.
+func NewQueue(cfg *config.MCPApprovals) (*Queue, error) {
+	q := &Queue{
+		timeout: time.Duration(cfg.TimeoutSeconds) * time.Second,
+		waiting: make(map[string]*waiting),
+		now:     time.Now,
+	}
+	for i, r := range cfg.Rules {
+		tool, err := compile(r.Tool)
+		if err != nil {
+			return nil, fmt.Errorf("rule %d tool: %w", i, err)
+		}
+		arguments := make(map[string]*regexp.Regexp, len(r.Arguments))
+		for name, pattern := range r.Arguments {
+			if arguments[name], err = compile(pattern); err != nil {
+				return nil, fmt.Errorf("rule %d argument %s: %w", i, name, err)
+			}
+		}
+		q.rules = append(q.rules, rule{tool: tool, arguments: arguments})
+	}
+	return q, nil
+}
+
+// Timeout returns how long a call waits for a decision before it is denied.
+func (q *Queue) Timeout() time.Duration {
+	return q.timeout
+}
+
+// Requires reports whether calling tool with args needs approval.
+func (q *Queue) Requires(tool string, args map[string]interface{}) bool {
+	return slices.ContainsFunc(q.rules, func(r rule) bool { return r.matches(tool, args) })
+}
+
+// Park queues a call of tool with args and returns it, with its id, and a function waiting for its
+// decision. Calls left undecided for the timeout are denied; when ctx ends first, as when the agent
+// gives up, the call leaves the queue and ctx's error is returned.
+func (q *Queue) Park(tool string, args map[string]interface{}) (Call, func(context.Context) (Decision, error)) {
+	w := &waiting{
+		call:     Call{ID: newID(), Tool: tool, Arguments: args, Requested: q.now().UTC()},
+		decision: make(chan Decision, 1),
+	}
+	q.mtx.Lock()
+	q.waiting[w.call.ID] = w
+	q.mtx.Unlock()
+
+	return w.call, func(ctx context.Context) (Decision, error) {
+		timer := time.NewTimer(q.timeout)
+		defer timer.Stop()
+		select {
+		case decision := <-w.decision:
+			return decision, nil
+		case <-timer.C:
+			if q.remove(w.call.ID) {
+				return Decision{Reason: fmt.Sprintf("no decision within %s", q.timeout)}, nil
+			}
+		case <-ctx.Done():
+			if q.remove(w.call.ID) {
+				return Decision{}, ctx.Err()
+			}
+		}
+		// Decided while giving up; the decision stands
+		return <-w.decision, nil
+	}
+}
+
+// Pending returns the calls waiting for a decision, oldest first.
+func (q *Queue) Pending() []Call {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	calls := make([]Call, 0, len(q.waiting))
+	for _, w := range q.waiting {
+		calls = append(calls, w.call)
+	}
+	slices.SortFunc(calls, func(a, b Call) int { return a.Requested.Compare(b.Requested) })
+	return calls
+}
+
+// Decide hands decision to the call with id and returns the call, or ErrUnknown.
+func (q *Queue) Decide(id string, decision Decision) (Call, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	w, ok := q.waiting[id]
+	if !ok {
+		return Call{}, ErrUnknown
+	}
+	delete(q.waiting, id)
+	w.decision <- decision
+	return w.call, nil
+}
+
+// remove takes the call with id out of the queue, reporting whether it was still waiting.
+func (q *Queue) remove(id string) bool {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+	if _, ok := q.waiting[id]; !ok {
+		return false
+	}
+	delete(q.waiting, id)
+	return true
+}
+
+// compile anchors pattern, so it must match a whole value.
+func compile(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// argumentText returns the text an argument's pattern is matched against.
+func argumentText(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+func newID() string {
+	b := make([]byte, idBytes)
+	_, _ = rand.Read(b) // crypto/rand.Read never returns an error
+	return hex.EncodeToString(b)
+}
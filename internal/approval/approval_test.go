@@ -0,0 +1,90 @@
+package approval
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestQueue_Requires(t *testing.T) {
+	q, err := NewQueue(&config.MCPApprovals{Rules: []config.ApprovalRule{
+		{Tool: "delete_.*"},
+		{Tool: "write_file", Arguments: map[string]string{"path": `/etc/.*`}},
+		{Tool: "transfer", Arguments: map[string]string{"amount": `\d{4,}`}},
+	}})
+	require.NoError(t, err)
+
+	assert.True(t, q.Requires("delete_file", nil))
+	assert.False(t, q.Requires("undelete_file", nil), "tool patterns match whole names")
+	assert.True(t, q.Requires("write_file", map[string]interface{}{"path": "/etc/passwd"}))
+	assert.False(t, q.Requires("write_file", map[string]interface{}{"path": "/tmp/notes"}))
+	assert.False(t, q.Requires("write_file", nil), "rules with argument patterns need the arguments")
+	assert.True(t, q.Requires("transfer", map[string]interface{}{"amount": 25000}))
+	assert.False(t, q.Requires("transfer", map[string]interface{}{"amount": 25}))
+	assert.False(t, q.Requires("read_file", nil))
+
+	_, err = NewQueue(&config.MCPApprovals{Rules: []config.ApprovalRule{{Tool: "("}}})
+	assert.ErrorContains(t, err, "rule 0 tool")
+}
+
+func TestQueue_Decide(t *testing.T) {
+	q, err := NewQueue(&config.MCPApprovals{TimeoutSeconds: 60})
+	require.NoError(t, err)
+	assert.Equal(t, time.Minute, q.Timeout())
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	q.now = func() time.Time {
+		now = now.Add(time.Second)
+		return now
+	}
+
+	first, waitFirst := q.Park("delete_file", map[string]interface{}{"path": "/data"})
+	second, waitSecond := q.Park("send_email", nil)
+	pending := q.Pending()
+	require.Len(t, pending, 2)
+	assert.Equal(t, []string{first.ID, second.ID}, []string{pending[0].ID, pending[1].ID})
+
+	decided, err := q.Decide(first.ID, Decision{Approved: true})
+	require.NoError(t, err)
+	assert.Equal(t, "delete_file", decided.Tool)
+	decision, err := waitFirst(t.Context())
+	require.NoError(t, err)
+	assert.True(t, decision.Approved)
+
+	_, err = q.Decide(first.ID, Decision{})
+	assert.ErrorIs(t, err, ErrUnknown, "calls are decided on once")
+
+	go func() {
+		_, _ = q.Decide(second.ID, Decision{Reason: "not during the freeze"})
+	}()
+	decision, err = waitSecond(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, Decision{Reason: "not during the freeze"}, decision)
+	assert.Empty(t, q.Pending())
+}
+
+func TestQueue_Undecided(t *testing.T) {
+	q, err := NewQueue(&config.MCPApprovals{})
+	require.NoError(t, err)
+	q.timeout = 10 * time.Millisecond
+
+	_, wait := q.Park("delete_file", nil)
+	decision, err := wait(t.Context())
+	require.NoError(t, err)
+	assert.False(t, decision.Approved)
+	assert.Equal(t, "no decision within 10ms", decision.Reason)
+	assert.Empty(t, q.Pending())
+
+	q.timeout = time.Minute
+	call, wait := q.Park("delete_file", nil)
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = wait(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	_, err = q.Decide(call.ID, Decision{Approved: true})
+	assert.ErrorIs(t, err, ErrUnknown, "calls the agent gave up on leave the queue")
+}
@@ -0,0 +1,322 @@
+// Package audit keeps a tamper-evident journal of API requests for compliance. Records are
+// appended to a file as JSON lines, each sealed with the SHA-256 hash of its bytes and carrying
+// the hash of the record before it, so changing, removing or reordering any record breaks the
+// chain from there on; Verify finds where.
+//
+// Records are appended, each synced to disk before the next, and an existing journal is verified
+// and continued rather than rewritten. Erase is the one exception: it drops the transcripts of a
+// data subject who asks to be forgotten by rewriting the journal beside itself and renaming it into
+// place. A record's transcript follows its seal and is sealed by its digest, so the records, their
+// hashes and the end of the chain are the same after a rewrite and Verify still passes; only the
+// transcripts are gone. Storage that enforces write-once retention would refuse the rewrite, so a
+// journal kept on it can't be erased, though deleting it is then evident as well.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/injection"
+)
+
+// maxRecordSize bounds the line of one record read back, which transcripts can make long.
+const maxRecordSize = 64 << 20
+
+// Record is one API request.
+type Record struct {
+	// Seq numbers records from 1 without gaps
+	Seq       int64     `json:"seq"`
+	Time      time.Time `json:"time"`
+	Tenant    string    `json:"tenant"`
+	Operation string    `json:"operation"`
+	Model     string    `json:"model"`
+	// Provider and Region are the provider that served the request and, when it has several, its
+	// region; empty when none did, as for a cached response
+	Provider string `json:"provider,omitempty"`
+	Region   string `json:"region,omitempty"`
+	Stream   bool   `json:"stream,omitempty"`
+	// Error is why the request failed; empty when it succeeded
+	Error    string                 `json:"error,omitempty"`
+	Usage    map[string]interface{} `json:"usage,omitempty"`
+	Metadata map[string]string      `json:"metadata,omitempty"`
+	// Injection is the prompt injection assessment of a tool-augmented chat request, when detection is on
+	Injection *injection.Assessment `json:"injection,omitempty"`
+	// ContentHash is the SHA-256 of the transcript, which stays sealed by it once erased
+	ContentHash string `json:"content_hash,omitempty"`
+	// Prev is the hash of the previous record, empty for the first
+	Prev string `json:"prev"`
+	// Hash is the SHA-256 of the record's line up to it, without the transcript
+	Hash string `json:"hash"`
+	// Transcript is the encoded Transcript, kept when transcripts are on and until erased; it must
+	// stay the last field
+	Transcript json.RawMessage `json:"transcript,omitempty"`
+}
+
+// Transcript is the content of a request.
+type Transcript struct {
+	// Request is the messages or prompt
+	Request interface{} `json:"request,omitempty"`
+	// Response is the response or, for a stream, its chunks
+	Response interface{} `json:"response,omitempty"`
+}
+
+// Log appends records to a journal file, safe for concurrent use.
+type Log struct {
+	path        string
+	transcripts bool
+	now         func() time.Time
+
+	mtx  sync.Mutex
+	file *os.File
+	seq  int64
+	last string
+	// failures counts records that couldn't be written
+	failures int64
+}
+
+// Status summarizes a journal for the internal metrics.
+type Status struct {
+	Records  int64  `json:"records"`
+	LastHash string `json:"last_hash,omitempty"`
+	Failures int64  `json:"failures"`
+}
+
+// Open opens the journal at path for appending, creating it if needed. An existing journal must
+// verify, so a tampered one isn't silently continued. With transcripts, records keep the content
+// of requests and responses.
+func Open(path string, transcripts bool) (*Log, error) {
+	// #nosec G304 -- the journal path comes from the config
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, err
+	}
+	seq, last, err := Verify(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, fmt.Errorf("%s fails verification: %w", path, err)
+	}
+	return &Log{path: path, transcripts: transcripts, now: time.Now, file: file, seq: seq, last: last}, nil
+}
+
+// Transcripts reports whether records keep the content of requests and responses.
+func (l *Log) Transcripts() bool {
+	return l.transcripts
+}
+
+// Append seals record onto the end of the chain and syncs it to disk. Seq, Time, ContentHash,
+// Prev and Hash are set here.
+func (l *Log) Append(record Record) error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	record.Seq = l.seq + 1
+	record.Time = l.now().UTC()
+	record.Prev = l.last
+	line, err := seal(&record)
+	if err == nil {
+		_, err = l.file.Write(append(line, '\n'))
+	}
+	if err == nil {
+		err = l.file.Sync()
+	}
+	if err != nil {
+		l.failures++
+		return err
+	}
+	l.seq, l.last = record.Seq, record.Hash
+	return nil
+}
+
+// Status returns the number of records, the hash at the end of the chain and the failed appends.
+func (l *Log) Status() Status {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return Status{Records: l.seq, LastHash: l.last, Failures: l.failures}
+}
+
+// Erase drops the transcripts of the records subject matches, returning how many were dropped.
+// The records stay, and the chain with them: a transcript is sealed by its digest, which remains.
+// The journal is rewritten beside itself and swapped in, so a failure leaves it as it was.
+func (l *Log) Erase(subject *erasure.Subject) (int, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return 0, err
+	}
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	erased := 0
+	for i, line := range lines {
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil || record.Transcript == nil {
+			continue
+		}
+		if !subject.Matches(record.Tenant, record.Metadata) {
+			continue
+		}
+		body := sealed(bytes.TrimSuffix(line, []byte("\n")), &record)
+		if body == nil {
+			return 0, fmt.Errorf("record %d was altered, its hash doesn't match", record.Seq)
+		}
+		record.Transcript = nil
+		lines[i] = append(append(body[:len(body)-1], tail(&record)...), '\n')
+		erased++
+	}
+	if erased == 0 {
+		return 0, nil
+	}
+
+	temp := l.path + ".erase"
+	if err := writeSynced(temp, bytes.Join(lines, nil)); err != nil {
+		return 0, err
+	}
+	if err := os.Rename(temp, l.path); err != nil {
+		_ = os.Remove(temp)
+		return 0, err
+	}
+	// #nosec G304 -- the journal path comes from the config
+	file, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return erased, err
+	}
+	_ = l.file.Close()
+	l.file = file
+	return erased, nil
+}
+
+// Records returns the records subject matches, oldest first, with their transcripts when kept.
+func (l *Log) Records(subject *erasure.Subject) ([]Record, error) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		return nil, err
+	}
+	var records []Record
+	for _, line := range bytes.Split(data, []byte("\n")) {
+		var record Record
+		if err := json.Unmarshal(line, &record); err != nil {
+			continue
+		}
+		if subject.Matches(record.Tenant, record.Metadata) {
+			records = append(records, record)
+		}
+	}
+	return records, nil
+}
+
+// writeSynced writes data to a new file at path and syncs it to disk.
+func writeSynced(path string, data []byte) error {
+	// #nosec G304 -- the path is beside the journal, whose path comes from the config
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	if err == nil {
+		err = file.Sync()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(path)
+	}
+	return err
+}
+
+// Close closes the journal file.
+func (l *Log) Close() error {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.file.Close()
+}
+
+// seal sets the hashes of record and returns its line.
+func seal(record *Record) ([]byte, error) {
+	transcript := record.Transcript
+	record.ContentHash, record.Hash, record.Transcript = "", "", nil
+	if transcript != nil {
+		record.ContentHash = digest(transcript)
+	}
+	line, err := json.Marshal(record)
+	if err != nil {
+		return nil, err
+	}
+
+	// The line ends in ,"hash":""}, which the sealed bytes leave out
+	body := append(bytes.TrimSuffix(line, []byte(`,"hash":""}`)), '}')
+	record.Hash, record.Transcript = digest(body), transcript
+	return append(body[:len(body)-1], tail(record)...), nil
+}
+
+// tail returns the end of a record's line after the sealed bytes: its hash and transcript.
+func tail(record *Record) []byte {
+	end := `,"hash":"` + record.Hash + `"`
+	if record.Transcript != nil {
+		end += `,"transcript":` + string(record.Transcript)
+	}
+	return []byte(end + "}")
+}
+
+// sealed returns the bytes of line the record's hash is taken over, or nil when the line doesn't
+// end in the record's hash and transcript.
+func sealed(line []byte, record *Record) []byte {
+	end := tail(record)
+	if !bytes.HasSuffix(line, end) {
+		return nil
+	}
+	body := line[: len(line)-len(end) : len(line)-len(end)]
+	return append(body, '}')
+}
+
+func digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Verify reads a journal and checks that every record is intact and chained to the one before it,
+// returning the number of records and the hash at the end of the chain. An erased transcript
+// doesn't break the chain. An error names the first record that fails, every record before it
+// being intact.
+func Verify(r io.Reader) (records int64, last string, err error) {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxRecordSize)
+	for line := 1; scanner.Scan(); line++ {
+		var record Record
+		if err := json.Unmarshal(scanner.Bytes(), &record); err != nil {
+			return records, last, fmt.Errorf("line %d: not a record: %w", line, err)
+		}
+		body := sealed(scanner.Bytes(), &record)
+		switch {
+		case body == nil || digest(body) != record.Hash:
+			return records, last, fmt.Errorf("line %d: record %d was altered, its hash doesn't match", line, record.Seq)
+		case record.Transcript != nil && digest(record.Transcript) != record.ContentHash:
+			return records, last, fmt.Errorf("line %d: the transcript of record %d was altered", line, record.Seq)
+		case record.Prev != last:
+			return records, last, fmt.Errorf("line %d: record %d doesn't follow the record before it", line, record.Seq)
+		case record.Seq != records+1:
+			return records, last, fmt.Errorf("line %d: record %d found where %d was expected", line, record.Seq, records+1)
+		}
+		records, last = record.Seq, record.Hash
+	}
+	if err := scanner.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return records, last, fmt.Errorf("line %d: record longer than %d bytes", records+1, maxRecordSize)
+		}
+		return records, last, err
+	}
+	return records, last, nil
+}
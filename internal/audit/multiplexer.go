@@ -0,0 +1,154 @@
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+
+	"github.com/modelplex/modelplex/internal/injection"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// Operations recorded.
+const (
+	OperationChat       = "chat.completion"
+	OperationCompletion = "text_completion"
+)
+
+// Multiplexer wraps a multiplexer and journals every request in a Log.
+type Multiplexer struct {
+	proxy.Multiplexer
+	log *Log
+}
+
+// NewMultiplexer wraps mux so requests are journaled in log.
+func NewMultiplexer(mux proxy.Multiplexer, log *Log) *Multiplexer {
+	return &Multiplexer{Multiplexer: mux, log: log}
+}
+
+// ChatCompletion forwards the request and journals it.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	ctx, assessment := injection.Track(providers.WithServingProvider(ctx))
+	result, err := m.Multiplexer.ChatCompletion(ctx, model, messages)
+	record, transcript := m.record(ctx, OperationChat, model, messages, result, err)
+	record.Injection = assessment()
+	m.append(ctx, record, transcript)
+	return result, err
+}
+
+// Completion forwards the request and journals it.
+func (m *Multiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	ctx = providers.WithServingProvider(ctx)
+	result, err := m.Multiplexer.Completion(ctx, model, prompt)
+	record, transcript := m.record(ctx, OperationCompletion, model, prompt, result, err)
+	m.append(ctx, record, transcript)
+	return result, err
+}
+
+// ChatCompletionStream forwards the request and journals it once the stream ends.
+func (m *Multiplexer) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	ctx, assessment := injection.Track(providers.WithServingProvider(ctx))
+	stream, err := m.Multiplexer.ChatCompletionStream(ctx, model, messages)
+	record, transcript := m.record(ctx, OperationChat, model, messages, nil, err)
+	record.Injection = assessment()
+	return m.tee(ctx, record, transcript, stream, err)
+}
+
+// CompletionStream forwards the request and journals it once the stream ends.
+func (m *Multiplexer) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	ctx = providers.WithServingProvider(ctx)
+	stream, err := m.Multiplexer.CompletionStream(ctx, model, prompt)
+	record, transcript := m.record(ctx, OperationCompletion, model, prompt, nil, err)
+	return m.tee(ctx, record, transcript, stream, err)
+}
+
+// record describes a request, and returns its transcript when transcripts are on.
+func (m *Multiplexer) record(
+	ctx context.Context, operation, model string, request, result interface{}, err error,
+) (Record, *Transcript) {
+	record := Record{
+		Tenant: usage.TenantFrom(ctx), Operation: operation, Model: model, Metadata: metadata.From(ctx),
+		Provider: providers.ServingProvider(ctx), Region: providers.ServingRegion(ctx),
+	}
+	if err != nil {
+		record.Error = err.Error()
+	}
+	if response, ok := result.(map[string]interface{}); ok && err == nil {
+		record.Usage, _ = response["usage"].(map[string]interface{})
+	}
+	if !m.log.transcripts {
+		return record, nil
+	}
+	// Encoded now, so later changes to the request or response aren't journaled
+	transcript := &Transcript{Request: snapshot(request)}
+	if err == nil && result != nil {
+		transcript.Response = snapshot(result)
+	}
+	return record, transcript
+}
+
+// tee passes a stream's chunks on and journals the request when it ends, with the chunks as the
+// response when transcripts are on. A stream that failed to start is journaled at once.
+func (m *Multiplexer) tee(
+	ctx context.Context, record Record, transcript *Transcript, stream <-chan interface{}, err error,
+) (<-chan interface{}, error) {
+	if err != nil {
+		m.append(ctx, record, transcript)
+		return stream, err
+	}
+
+	record.Stream = true
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		var chunks []json.RawMessage
+		defer func() {
+			if transcript != nil {
+				transcript.Response = chunks
+			}
+			m.append(ctx, record, transcript)
+		}()
+		for chunk := range stream {
+			// Copied before it is passed on, since the proxy may modify chunks in place
+			if transcript != nil {
+				chunks = append(chunks, snapshot(chunk))
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				record.Error = "client disconnected: " + ctx.Err().Error()
+				for range stream {
+				}
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// snapshot encodes v as it is now; nil when it can't be.
+func snapshot(v interface{}) json.RawMessage {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil
+	}
+	return data
+}
+
+// append journals record with its transcript, if any; a record that can't be written is logged
+// and counted, not failed.
+func (m *Multiplexer) append(ctx context.Context, record Record, transcript *Transcript) {
+	if transcript != nil {
+		record.Transcript = snapshot(transcript)
+	}
+	if err := m.log.Append(record); err != nil {
+		slog.ErrorContext(ctx, "Failed to write audit record", "model", record.Model, "error", err)
+	}
+}
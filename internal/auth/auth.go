@@ -0,0 +1,156 @@
+// Package auth provides role-based authentication for the admin endpoints.
+// Callers present either a static admin token or an OIDC-issued JWT as a bearer token;
+// viewers may read admin state while operators may also mutate it.
+package auth
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// Role is an admin permission level; higher roles include the lower ones.
+type Role int
+
+const (
+	// RoleNone grants nothing
+	RoleNone Role = iota
+	// RoleViewer may read admin endpoints
+	RoleViewer
+	// RoleOperator may also call mutating admin endpoints
+	RoleOperator
+)
+
+// String returns the config name of the role.
+func (r Role) String() string {
+	switch r {
+	case RoleViewer:
+		return "viewer"
+	case RoleOperator:
+		return "operator"
+	default:
+		return "none"
+	}
+}
+
+// ParseRole parses a role name from config.
+func ParseRole(name string) (Role, error) {
+	switch name {
+	case "viewer":
+		return RoleViewer, nil
+	case "operator":
+		return RoleOperator, nil
+	default:
+		return RoleNone, fmt.Errorf("unknown admin role: %q", name)
+	}
+}
+
+// ErrUnauthenticated is returned when a request carries no valid credentials.
+var ErrUnauthenticated = errors.New("missing or invalid credentials")
+
+// staticToken is a configured admin token and its role.
+type staticToken struct {
+	token []byte
+	role  Role
+}
+
+// Authenticator resolves the admin role of a request.
+type Authenticator struct {
+	tokens []staticToken
+	oidc   *oidcVerifier
+}
+
+// New creates an authenticator from cfg, or returns nil when admin auth is not configured.
+func New(cfg *config.AdminConfig) (*Authenticator, error) {
+	if len(cfg.Tokens) == 0 && cfg.OIDC.Issuer == "" {
+		return nil, nil
+	}
+
+	a := &Authenticator{}
+	for i, t := range cfg.Tokens {
+		role, err := ParseRole(t.Role)
+		if err != nil {
+			return nil, fmt.Errorf("admin token %d: %w", i, err)
+		}
+		if t.Token == "" {
+			return nil, fmt.Errorf("admin token %d: empty token", i)
+		}
+		a.tokens = append(a.tokens, staticToken{token: []byte(t.Token), role: role})
+	}
+
+	if cfg.OIDC.Issuer != "" {
+		a.oidc = newOIDCVerifier(&cfg.OIDC)
+	}
+
+	return a, nil
+}
+
+// Authenticate returns the role granted by the request's bearer token.
+func (a *Authenticator) Authenticate(r *http.Request) (Role, error) {
+	token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if !ok || token == "" {
+		return RoleNone, ErrUnauthenticated
+	}
+
+	// Compare against every token so timing doesn't reveal which one matched
+	role := RoleNone
+	for _, t := range a.tokens {
+		if subtle.ConstantTimeCompare(t.token, []byte(token)) == 1 && t.role > role {
+			role = t.role
+		}
+	}
+	if role != RoleNone {
+		return role, nil
+	}
+
+	if a.oidc != nil && strings.Count(token, ".") == 2 {
+		return a.oidc.verify(r.Context(), token)
+	}
+
+	return RoleNone, ErrUnauthenticated
+}
+
+// Require returns middleware rejecting requests whose role is below required.
+func (a *Authenticator) Require(required func(r *http.Request) Role) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			role, err := a.Authenticate(r)
+			if err != nil {
+				slog.Warn("Admin authentication failed", "path", r.URL.Path, "error", err)
+				writeError(w, http.StatusUnauthorized, "authentication required")
+				return
+			}
+			if role < required(r) {
+				writeError(w, http.StatusForbidden, "insufficient role: "+role.String())
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// MethodRole requires operators for mutating methods and viewers for reads.
+func MethodRole(r *http.Request) Role {
+	switch r.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return RoleViewer
+	default:
+		return RoleOperator
+	}
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	if status == http.StatusUnauthorized {
+		w.Header().Set("WWW-Authenticate", "Bearer")
+	}
+	w.WriteHeader(status)
+	if _, err := fmt.Fprintf(w, `{"error":%q}`, message); err != nil {
+		slog.Error("Error writing auth error response", "error", err)
+	}
+}
@@ -0,0 +1,209 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	a, err := New(&config.AdminConfig{})
+	require.NoError(t, err)
+	assert.Nil(t, a)
+
+	_, err = New(&config.AdminConfig{Tokens: []config.AdminToken{{Token: "t", Role: "root"}}})
+	assert.ErrorContains(t, err, "unknown admin role")
+
+	_, err = New(&config.AdminConfig{Tokens: []config.AdminToken{{Role: "viewer"}}})
+	assert.ErrorContains(t, err, "empty token")
+}
+
+func TestRequire_StaticTokens(t *testing.T) {
+	a, err := New(&config.AdminConfig{Tokens: []config.AdminToken{
+		{Token: "view-token", Role: "viewer"},
+		{Token: "op-token", Role: "operator"},
+	}})
+	require.NoError(t, err)
+
+	handler := a.Require(MethodRole)(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	tests := []struct {
+		name     string
+		method   string
+		token    string
+		expected int
+	}{
+		{"no token", "GET", "", http.StatusUnauthorized},
+		{"wrong token", "GET", "nope", http.StatusUnauthorized},
+		{"viewer reads", "GET", "view-token", http.StatusOK},
+		{"viewer cannot mutate", "POST", "view-token", http.StatusForbidden},
+		{"operator reads", "GET", "op-token", http.StatusOK},
+		{"operator mutates", "POST", "op-token", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, "/_internal/status", http.NoBody)
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, req)
+			assert.Equal(t, tt.expected, w.Code)
+		})
+	}
+}
+
+// testIssuer is a minimal OIDC issuer serving discovery and JWKS for one RSA key.
+type testIssuer struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	// fetches counts JWKS requests, which wait for block to close when it is set
+	fetches atomic.Int32
+	block   chan struct{}
+}
+
+func newTestIssuer(t *testing.T) *testIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+
+	issuer := &testIssuer{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]string{"jwks_uri": issuer.server.URL + "/jwks"})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		issuer.fetches.Add(1)
+		if issuer.block != nil {
+			<-issuer.block
+		}
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "key-1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	})
+	issuer.server = httptest.NewServer(mux)
+	t.Cleanup(issuer.server.Close)
+	return issuer
+}
+
+func (i *testIssuer) sign(t *testing.T, kid string, claims map[string]interface{}) string {
+	encode := func(v interface{}) string {
+		data, err := json.Marshal(v)
+		require.NoError(t, err)
+		return base64.RawURLEncoding.EncodeToString(data)
+	}
+
+	signingInput := encode(map[string]string{"alg": "RS256", "kid": kid}) + "." + encode(claims)
+	digest := sha256.Sum256([]byte(signingInput))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, i.key, crypto.SHA256, digest[:])
+	require.NoError(t, err)
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestAuthenticate_OIDC(t *testing.T) {
+	issuer := newTestIssuer(t)
+	a, err := New(&config.AdminConfig{OIDC: config.OIDCConfig{
+		Issuer:         issuer.server.URL,
+		Audience:       "modelplex",
+		RoleClaim:      "groups",
+		ViewerValues:   []string{"ml-team"},
+		OperatorValues: []string{"platform"},
+	}})
+	require.NoError(t, err)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{
+			"iss": issuer.server.URL, "aud": []string{"modelplex"}, "exp": exp, "groups": []string{"ml-team"},
+		}
+		for k, v := range overrides {
+			c[k] = v
+		}
+		return c
+	}
+
+	signed := func(key, value string, v interface{}) string {
+		if key == "" {
+			return issuer.sign(t, value, claims(nil))
+		}
+		return issuer.sign(t, "key-1", claims(map[string]interface{}{key: v}))
+	}
+	expired := time.Now().Add(-time.Hour).Unix()
+
+	tests := []struct {
+		name     string
+		token    string
+		expected Role
+		wantErr  bool
+	}{
+		{"viewer group", signed("", "key-1", nil), RoleViewer, false},
+		{"operator group", signed("groups", "", "platform"), RoleOperator, false},
+		{"no matching group", signed("groups", "", []string{"x"}), RoleNone, false},
+		{"wrong audience", signed("aud", "", "other"), RoleNone, true},
+		{"wrong issuer", signed("iss", "", "https://evil"), RoleNone, true},
+		{"expired", signed("exp", "", expired), RoleNone, true},
+		{"unknown key", signed("", "key-2", nil), RoleNone, true},
+		{"tampered", signed("", "key-1", nil) + "x", RoleNone, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/_internal/status", http.NoBody)
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+			role, err := a.Authenticate(req)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, role)
+		})
+	}
+}
+
+func TestOIDCVerifier_SharesKeyFetches(t *testing.T) {
+	issuer := newTestIssuer(t)
+	issuer.block = make(chan struct{})
+	v := newOIDCVerifier(&config.OIDCConfig{Issuer: issuer.server.URL})
+	assert.Equal(t, jwksFetchTimeout, v.client.Timeout)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := v.key(ctx, "key-1")
+	assert.ErrorIs(t, err, context.Canceled, "a lookup stops waiting when its request ends")
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			key, err := v.key(t.Context(), "key-1")
+			assert.NoError(t, err)
+			assert.NotNil(t, key)
+		}()
+	}
+	close(issuer.block)
+	wg.Wait()
+	assert.Equal(t, int32(1), issuer.fetches.Load(), "the cancelled lookup's fetch serves the others")
+}
@@ -0,0 +1,286 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+const (
+	// jwksCacheTTL controls how long signing keys are trusted before refetching
+	jwksCacheTTL = time.Hour
+	// jwksMinRefetch stops tokens with unknown key ids from hammering the issuer
+	jwksMinRefetch = time.Minute
+	// jwksFetchTimeout bounds fetching discovery and the JWKS from an issuer that doesn't answer
+	jwksFetchTimeout = 10 * time.Second
+	// clockSkew tolerates small clock differences with the issuer
+	clockSkew = time.Minute
+)
+
+// oidcVerifier validates RS256 JWTs issued by an OIDC provider and maps claims to roles.
+type oidcVerifier struct {
+	cfg    config.OIDCConfig
+	client *http.Client
+	now    func() time.Time
+
+	mtx       sync.Mutex
+	keys      map[string]*rsa.PublicKey
+	fetchedAt time.Time
+	// fetches shares a JWKS fetch between the lookups needing keys while it runs
+	fetches singleflight.Group
+}
+
+func newOIDCVerifier(cfg *config.OIDCConfig) *oidcVerifier {
+	return &oidcVerifier{
+		cfg:    *cfg,
+		client: &http.Client{Timeout: jwksFetchTimeout},
+		now:    time.Now,
+	}
+}
+
+// jwtClaims holds the registered claims we check plus the raw claim set for role lookup.
+type jwtClaims struct {
+	Issuer    string      `json:"iss"`
+	Audience  interface{} `json:"aud"`
+	ExpiresAt int64       `json:"exp"`
+	NotBefore int64       `json:"nbf"`
+	raw       map[string]interface{}
+}
+
+func (v *oidcVerifier) verify(ctx context.Context, token string) (Role, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return RoleNone, ErrUnauthenticated
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return RoleNone, fmt.Errorf("invalid token header: %w", err)
+	}
+	if header.Alg != "RS256" {
+		return RoleNone, fmt.Errorf("unsupported token algorithm: %s", header.Alg)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return RoleNone, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return RoleNone, fmt.Errorf("invalid token signature: %w", err)
+	}
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, digest[:], signature); err != nil {
+		return RoleNone, errors.New("invalid token signature")
+	}
+
+	var claims jwtClaims
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return RoleNone, fmt.Errorf("invalid token claims: %w", err)
+	}
+	if err := decodeSegment(parts[1], &claims.raw); err != nil {
+		return RoleNone, fmt.Errorf("invalid token claims: %w", err)
+	}
+	if err := v.validate(&claims); err != nil {
+		return RoleNone, err
+	}
+
+	return v.role(claims.raw), nil
+}
+
+func (v *oidcVerifier) validate(claims *jwtClaims) error {
+	now := v.now()
+	if claims.Issuer != v.cfg.Issuer {
+		return fmt.Errorf("unexpected token issuer: %s", claims.Issuer)
+	}
+	if v.cfg.Audience != "" && !slices.Contains(stringList(claims.Audience), v.cfg.Audience) {
+		return errors.New("token audience mismatch")
+	}
+	if claims.ExpiresAt == 0 || now.After(time.Unix(claims.ExpiresAt, 0).Add(clockSkew)) {
+		return errors.New("token expired")
+	}
+	if claims.NotBefore != 0 && now.Add(clockSkew).Before(time.Unix(claims.NotBefore, 0)) {
+		return errors.New("token not yet valid")
+	}
+	return nil
+}
+
+// role grants the highest role whose configured values appear in the role claim.
+func (v *oidcVerifier) role(claims map[string]interface{}) Role {
+	values := stringList(claims[v.cfg.RoleClaim])
+	for _, value := range values {
+		if slices.Contains(v.cfg.OperatorValues, value) {
+			return RoleOperator
+		}
+	}
+	for _, value := range values {
+		if slices.Contains(v.cfg.ViewerValues, value) {
+			return RoleViewer
+		}
+	}
+	return RoleNone
+}
+
+// key returns the signing key for kid, refetching the JWKS when it is stale or the kid is unknown.
+// The fetch runs outside the lock, so cached keys are served meanwhile, and concurrent lookups share
+// it; a lookup whose ctx ends stops waiting without cancelling the fetch for the others.
+func (v *oidcVerifier) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	v.mtx.Lock()
+	age := v.now().Sub(v.fetchedAt)
+	key, ok := v.keys[kid]
+	if ok && age < jwksCacheTTL {
+		v.mtx.Unlock()
+		return key, nil
+	}
+	if !ok && v.keys != nil && age < jwksMinRefetch {
+		v.mtx.Unlock()
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	v.mtx.Unlock()
+
+	fetched := v.fetches.DoChan("jwks", func() (interface{}, error) {
+		return v.refresh(context.WithoutCancel(ctx))
+	})
+	var result singleflight.Result
+	select {
+	case result = <-fetched:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if result.Err != nil {
+		return nil, fmt.Errorf("failed to fetch signing keys: %w", result.Err)
+	}
+	key, ok = result.Val.(map[string]*rsa.PublicKey)[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	return key, nil
+}
+
+// refresh fetches the signing keys and caches them, unless a fetch that just ended already did.
+func (v *oidcVerifier) refresh(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	v.mtx.Lock()
+	if v.keys != nil && v.now().Sub(v.fetchedAt) < jwksMinRefetch {
+		keys := v.keys
+		v.mtx.Unlock()
+		return keys, nil
+	}
+	v.mtx.Unlock()
+
+	keys, err := v.fetchKeys(ctx)
+	if err != nil {
+		return nil, err
+	}
+	v.mtx.Lock()
+	defer v.mtx.Unlock()
+	v.keys = keys
+	v.fetchedAt = v.now()
+	return keys, nil
+}
+
+func (v *oidcVerifier) fetchKeys(ctx context.Context) (map[string]*rsa.PublicKey, error) {
+	var discovery struct {
+		JWKSURI string `json:"jwks_uri"`
+	}
+	wellKnown := strings.TrimSuffix(v.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := v.getJSON(ctx, wellKnown, &discovery); err != nil {
+		return nil, err
+	}
+
+	var jwks struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := v.getJSON(ctx, discovery.JWKSURI, &jwks); err != nil {
+		return nil, err
+	}
+
+	keys := make(map[string]*rsa.PublicKey)
+	for _, k := range jwks.Keys {
+		if k.Kty != "RSA" {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}
+	}
+	return keys, nil
+}
+
+func (v *oidcVerifier) getJSON(ctx context.Context, url string, out interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, http.NoBody)
+	if err != nil {
+		return err
+	}
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("request to %s failed with status %d", url, resp.StatusCode)
+	}
+	return json.Unmarshal(body, out)
+}
+
+func decodeSegment(segment string, out interface{}) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// stringList normalizes a claim that may be a single string or an array of strings.
+func stringList(claim interface{}) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,192 @@
+// Package broadcast lets several consumers observe one streaming generation, such as the client,
+// an admin tailing live traffic and a resumed connection. Chunks are buffered as they arrive, so a
+// slow consumer never holds up the generation or the others, and a late one can start from the top.
+package broadcast
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Stream is the buffered output of one generation, readable by any number of consumers.
+type Stream struct {
+	mtx     sync.Mutex
+	chunks  []interface{}
+	done    bool
+	changed chan struct{}
+	// finished is closed once upstream has closed
+	finished chan struct{}
+}
+
+// New starts buffering upstream until it closes.
+func New(upstream <-chan interface{}) *Stream {
+	s := &Stream{changed: make(chan struct{}), finished: make(chan struct{})}
+	go s.buffer(upstream)
+	return s
+}
+
+// Next returns the chunks after the first n, whether the generation has finished,
+// and a channel that is closed once there is more to read.
+func (s *Stream) Next(n int) (chunks []interface{}, done bool, changed <-chan struct{}) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if n < len(s.chunks) {
+		chunks = s.chunks[n:len(s.chunks):len(s.chunks)]
+	}
+	return chunks, s.done, s.changed
+}
+
+// Len returns the number of chunks buffered so far.
+func (s *Stream) Len() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return len(s.chunks)
+}
+
+// Finished returns a channel that is closed once the generation has finished.
+func (s *Stream) Finished() <-chan struct{} {
+	return s.finished
+}
+
+// finishedNow reports whether the generation has finished, even if that hasn't been acted on yet.
+func (s *Stream) finishedNow() bool {
+	select {
+	case <-s.finished:
+		return true
+	default:
+		return false
+	}
+}
+
+// Subscribe returns a channel delivering every chunk from the first one. It is closed once the
+// generation finishes or ctx is done; a subscriber that stops reading must cancel ctx.
+func (s *Stream) Subscribe(ctx context.Context) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		n := 0
+		for {
+			chunks, done, changed := s.Next(n)
+			for _, chunk := range chunks {
+				select {
+				case out <- chunk:
+					n++
+				case <-ctx.Done():
+					return
+				}
+			}
+			if done {
+				return
+			}
+			select {
+			case <-changed:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// buffer appends upstream chunks and wakes readers until upstream closes.
+func (s *Stream) buffer(upstream <-chan interface{}) {
+	for chunk := range upstream {
+		s.mtx.Lock()
+		s.chunks = append(s.chunks, chunk)
+		s.notifyLocked()
+		s.mtx.Unlock()
+	}
+
+	s.mtx.Lock()
+	s.done = true
+	s.notifyLocked()
+	s.mtx.Unlock()
+	close(s.finished)
+}
+
+func (s *Stream) notifyLocked() {
+	close(s.changed)
+	s.changed = make(chan struct{})
+}
+
+// Info describes a live stream.
+type Info struct {
+	ID      string    `json:"id"`
+	Model   string    `json:"model"`
+	Tenant  string    `json:"tenant,omitempty"`
+	Started time.Time `json:"started"`
+	Chunks  int       `json:"chunks"`
+}
+
+// Registry tracks the streams currently generating so they can be tailed.
+type Registry struct {
+	mtx     sync.Mutex
+	nextID  int
+	streams map[string]*entry
+}
+
+type entry struct {
+	seq    int
+	info   Info
+	stream *Stream
+}
+
+// NewRegistry creates an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{streams: make(map[string]*entry)}
+}
+
+// Add tracks stream until it finishes. Finished streams are never listed, even before they are dropped.
+func (reg *Registry) Add(model, tenant string, stream *Stream) {
+	reg.mtx.Lock()
+	reg.nextID++
+	id := strconv.Itoa(reg.nextID)
+	reg.streams[id] = &entry{
+		seq:    reg.nextID,
+		info:   Info{ID: id, Model: model, Tenant: tenant, Started: time.Now()},
+		stream: stream,
+	}
+	reg.mtx.Unlock()
+
+	go func() {
+		<-stream.Finished()
+		reg.mtx.Lock()
+		delete(reg.streams, id)
+		reg.mtx.Unlock()
+	}()
+}
+
+// List returns the live streams, oldest first.
+func (reg *Registry) List() []Info {
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+
+	entries := slices.SortedFunc(maps.Values(reg.streams), func(a, b *entry) int { return a.seq - b.seq })
+	infos := make([]Info, 0, len(entries))
+	for _, e := range entries {
+		if e.stream.finishedNow() {
+			continue
+		}
+		info := e.info
+		info.Chunks = e.stream.Len()
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// Get returns the live stream with id.
+func (reg *Registry) Get(id string) (*Stream, bool) {
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+
+	e, ok := reg.streams[id]
+	if !ok || e.stream.finishedNow() {
+		return nil, false
+	}
+	return e.stream, true
+}
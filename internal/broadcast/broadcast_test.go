@@ -0,0 +1,82 @@
+package broadcast
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func collect(ch <-chan interface{}) []interface{} {
+	var chunks []interface{}
+	for chunk := range ch {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestStream_FansOutToEverySubscriber(t *testing.T) {
+	upstream := make(chan interface{})
+	stream := New(upstream)
+
+	early := stream.Subscribe(t.Context())
+	upstream <- "one"
+	upstream <- "two"
+
+	// A late subscriber starts from the first chunk
+	late := stream.Subscribe(t.Context())
+	upstream <- "three"
+	close(upstream)
+
+	expected := []interface{}{"one", "two", "three"}
+	assert.Equal(t, expected, collect(early))
+	assert.Equal(t, expected, collect(late))
+	<-stream.Finished()
+	assert.Equal(t, 3, stream.Len())
+}
+
+func TestStream_SubscriberLeavingDoesNotBlock(t *testing.T) {
+	upstream := make(chan interface{})
+	stream := New(upstream)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	abandoned := stream.Subscribe(ctx)
+	cancel()
+	assert.Empty(t, collect(abandoned))
+
+	// Upstream is still drained with nobody reading
+	upstream <- "one"
+	upstream <- "two"
+	close(upstream)
+	<-stream.Finished()
+	assert.Equal(t, []interface{}{"one", "two"}, collect(stream.Subscribe(t.Context())))
+}
+
+func TestRegistry(t *testing.T) {
+	reg := NewRegistry()
+	first := make(chan interface{})
+	second := make(chan interface{})
+	firstStream, secondStream := New(first), New(second)
+	reg.Add("gpt-4", "acme", firstStream)
+	reg.Add("llama2", "", secondStream)
+	first <- "hi"
+
+	infos := reg.List()
+	require.Len(t, infos, 2)
+	assert.Equal(t, "gpt-4", infos[0].Model)
+	assert.Equal(t, "acme", infos[0].Tenant)
+	assert.Equal(t, 1, infos[0].Chunks)
+	assert.Equal(t, "llama2", infos[1].Model)
+
+	got, ok := reg.Get(infos[1].ID)
+	require.True(t, ok)
+	assert.Same(t, secondStream, got)
+
+	close(first)
+	<-firstStream.Finished()
+	infos = reg.List()
+	require.Len(t, infos, 1)
+	assert.Equal(t, "llama2", infos[0].Model)
+	close(second)
+}
@@ -0,0 +1,140 @@
+// Package budget caps the turns and tokens of a conversation, the requests carrying the same
+// conversation_id metadata value, so an agent stuck in a loop that never terminates is cut off
+// instead of spending without bound. Counts live in the state backend, so with Redis every
+// instance counts against the same budget.
+package budget
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/state"
+)
+
+// Budgets a conversation can exceed, as reported by providers.BudgetExceededError.
+const (
+	BudgetTurns  = "turns"
+	BudgetTokens = "tokens"
+)
+
+// Budget counts the turns and tokens of conversations against their limits.
+type Budget struct {
+	store     state.Store
+	maxTurns  int64
+	maxTokens int64
+	ttl       time.Duration
+}
+
+// New creates a budget for cfg counting in store, or nil when conversations aren't limited.
+func New(store state.Store, cfg *config.ConversationLimits) *Budget {
+	if cfg.MaxTurns == 0 && cfg.MaxTokens == 0 {
+		return nil
+	}
+	return &Budget{
+		store:     store,
+		maxTurns:  cfg.MaxTurns,
+		maxTokens: cfg.MaxTokens,
+		ttl:       time.Duration(cfg.TTLSeconds) * time.Second,
+	}
+}
+
+// Conversation returns the conversation the request of ctx belongs to, or "" when it has none.
+func Conversation(ctx context.Context) string {
+	return metadata.From(ctx)[erasure.ConversationKey]
+}
+
+// Admit counts a turn of conversation, returning a *providers.BudgetExceededError instead when
+// the conversation has used up either budget. Refused requests don't count as turns.
+func (b *Budget) Admit(ctx context.Context, conversation string) error {
+	if b.maxTokens > 0 {
+		used, err := b.count(ctx, b.key(conversation, BudgetTokens))
+		if err != nil {
+			return err
+		}
+		if used >= b.maxTokens {
+			return b.exceeded(conversation, BudgetTokens, b.maxTokens, used)
+		}
+	}
+	if b.maxTurns > 0 {
+		key := b.key(conversation, BudgetTurns)
+		turns, err := b.store.IncrBy(ctx, key, 1, b.ttl)
+		if err != nil {
+			return err
+		}
+		if turns > b.maxTurns {
+			if _, err := b.store.IncrBy(ctx, key, -1, b.ttl); err != nil {
+				return err
+			}
+			return b.exceeded(conversation, BudgetTurns, b.maxTurns, turns-1)
+		}
+	}
+	return nil
+}
+
+// Spend adds tokens used by a response to the budget of conversation.
+func (b *Budget) Spend(ctx context.Context, conversation string, tokens int64) error {
+	if b.maxTokens == 0 || tokens <= 0 {
+		return nil
+	}
+	_, err := b.store.IncrBy(ctx, b.key(conversation, BudgetTokens), tokens, b.ttl)
+	return err
+}
+
+// Limits returns the turns and tokens a conversation may use, zero when unlimited.
+func (b *Budget) Limits() (maxTurns, maxTokens int64) {
+	return b.maxTurns, b.maxTokens
+}
+
+// Used returns the turns and tokens conversation has used.
+func (b *Budget) Used(ctx context.Context, conversation string) (turns, tokens int64, err error) {
+	if turns, err = b.count(ctx, b.key(conversation, BudgetTurns)); err != nil {
+		return 0, 0, err
+	}
+	if tokens, err = b.count(ctx, b.key(conversation, BudgetTokens)); err != nil {
+		return 0, 0, err
+	}
+	return turns, tokens, nil
+}
+
+// Restore raises the turns and tokens conversation has used to at least turns and tokens, as when
+// the conversation moves here from another instance. Counts only grow, so restoring the same
+// counts again, or into a backend the instances share, changes nothing.
+func (b *Budget) Restore(ctx context.Context, conversation string, turns, tokens int64) error {
+	if err := b.raise(ctx, b.key(conversation, BudgetTurns), turns); err != nil {
+		return err
+	}
+	return b.raise(ctx, b.key(conversation, BudgetTokens), tokens)
+}
+
+// raise adds to the counter at key what it lacks of target.
+func (b *Budget) raise(ctx context.Context, key string, target int64) error {
+	used, err := b.count(ctx, key)
+	if err != nil || used >= target {
+		return err
+	}
+	_, err = b.store.IncrBy(ctx, key, target-used, b.ttl)
+	return err
+}
+
+func (b *Budget) count(ctx context.Context, key string) (int64, error) {
+	value, ok, err := b.store.Get(ctx, key)
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseInt(string(value), 10, 64)
+}
+
+func (b *Budget) exceeded(conversation, budget string, limit, used int64) error {
+	return &providers.BudgetExceededError{ConversationID: conversation, Budget: budget, Limit: limit, Used: used}
+}
+
+// key returns the counter of budget for conversation.
+func (b *Budget) key(conversation, budget string) string {
+	return fmt.Sprintf("conversation:%s:%s", conversation, budget)
+}
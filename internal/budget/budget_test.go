@@ -0,0 +1,129 @@
+package budget
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/state"
+)
+
+// stubMultiplexer answers with a fixed response and stream, counting the requests it serves.
+type stubMultiplexer struct {
+	proxy.Multiplexer
+	served int
+}
+
+func (m *stubMultiplexer) ChatCompletion(
+	_ context.Context, _ string, _ []map[string]interface{},
+) (interface{}, error) {
+	m.served++
+	return map[string]interface{}{
+		"usage": map[string]interface{}{"prompt_tokens": float64(30), "completion_tokens": float64(10)},
+	}, nil
+}
+
+func (m *stubMultiplexer) ChatCompletionStream(
+	_ context.Context, _ string, _ []map[string]interface{},
+) (<-chan interface{}, error) {
+	m.served++
+	stream := make(chan interface{}, 3)
+	stream <- map[string]interface{}{"type": "message_start",
+		"message": map[string]interface{}{"usage": map[string]interface{}{"input_tokens": float64(20)}}}
+	stream <- map[string]interface{}{"type": "content_block_delta"}
+	stream <- map[string]interface{}{"type": "message_delta", "usage": map[string]interface{}{"output_tokens": float64(5)}}
+	close(stream)
+	return stream, nil
+}
+
+func conversation(t *testing.T, id string) context.Context {
+	return metadata.With(t.Context(), map[string]string{"conversation_id": id})
+}
+
+func TestMultiplexer_TurnBudget(t *testing.T) {
+	store := state.NewMemoryStore()
+	budget := New(store, &config.ConversationLimits{MaxTurns: 2, TTLSeconds: 60})
+	stub := &stubMultiplexer{}
+	m := NewMultiplexer(stub, budget)
+
+	for range 2 {
+		_, err := m.ChatCompletion(conversation(t, "c1"), "gpt-4", nil)
+		require.NoError(t, err)
+	}
+	_, err := m.ChatCompletion(conversation(t, "c1"), "gpt-4", nil)
+	var exceeded *providers.BudgetExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, providers.BudgetExceededError{ConversationID: "c1", Budget: BudgetTurns, Limit: 2, Used: 2},
+		*exceeded)
+	assert.Equal(t, 2, stub.served, "refused requests reach no provider")
+
+	turns, _, err := budget.Used(t.Context(), "c1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), turns, "refused requests don't count as turns")
+
+	_, err = m.ChatCompletion(conversation(t, "c2"), "gpt-4", nil)
+	assert.NoError(t, err, "conversations have budgets of their own")
+	_, err = m.ChatCompletion(t.Context(), "gpt-4", nil)
+	assert.NoError(t, err, "requests without a conversation aren't limited")
+}
+
+func TestMultiplexer_TokenBudget(t *testing.T) {
+	budget := New(state.NewMemoryStore(), &config.ConversationLimits{MaxTokens: 60, TTLSeconds: 60})
+	m := NewMultiplexer(&stubMultiplexer{}, budget)
+
+	_, err := m.ChatCompletion(conversation(t, "c1"), "gpt-4", nil)
+	require.NoError(t, err)
+	stream, err := m.ChatCompletionStream(conversation(t, "c1"), "claude-3", nil)
+	require.NoError(t, err)
+	for range stream {
+	}
+
+	_, tokens, err := budget.Used(t.Context(), "c1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(65), tokens)
+
+	_, err = m.ChatCompletion(conversation(t, "c1"), "gpt-4", nil)
+	var exceeded *providers.BudgetExceededError
+	require.ErrorAs(t, err, &exceeded)
+	assert.Equal(t, BudgetTokens, exceeded.Budget)
+	assert.Equal(t, int64(65), exceeded.Used)
+}
+
+func TestResponseTokens(t *testing.T) {
+	assert.Equal(t, int64(12), ResponseTokens(map[string]interface{}{
+		"usage": map[string]interface{}{"prompt_tokens": float64(5), "completion_tokens": float64(6),
+			"total_tokens": float64(12)},
+	}))
+	assert.Equal(t, int64(9), ResponseTokens(map[string]interface{}{
+		"usage": map[string]interface{}{"input_tokens": float64(7), "output_tokens": float64(2)},
+	}))
+	assert.Equal(t, int64(30), ResponseTokens(map[string]interface{}{
+		"done": true, "prompt_eval_count": float64(26), "eval_count": float64(4),
+	}))
+	assert.Zero(t, ResponseTokens(map[string]interface{}{}))
+}
+
+func TestNew_Unlimited(t *testing.T) {
+	assert.Nil(t, New(state.NewMemoryStore(), &config.ConversationLimits{TTLSeconds: 60}))
+}
+
+func TestBudget_Restore(t *testing.T) {
+	b := New(state.NewMemoryStore(), &config.ConversationLimits{MaxTurns: 10, TTLSeconds: 60})
+	require.NoError(t, b.Admit(t.Context(), "c1"))
+	require.NoError(t, b.Restore(t.Context(), "c1", 4, 120))
+	require.NoError(t, b.Restore(t.Context(), "c1", 4, 120))
+	turns, tokens, err := b.Used(t.Context(), "c1")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{4, 120}, []int64{turns, tokens}, "restoring again changes nothing")
+
+	require.NoError(t, b.Restore(t.Context(), "c1", 2, 0))
+	turns, _, err = b.Used(t.Context(), "c1")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), turns, "counts only grow")
+}
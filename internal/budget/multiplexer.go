@@ -0,0 +1,133 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+)
+
+// Multiplexer wraps a multiplexer and refuses the requests of conversations over budget, counting
+// the tokens of the responses it forwards. Store failures fail open, like the rate limit, so an
+// unreachable Redis doesn't take down completions.
+type Multiplexer struct {
+	proxy.Multiplexer
+	budget *Budget
+}
+
+// NewMultiplexer wraps mux so conversations are held to budget.
+func NewMultiplexer(mux proxy.Multiplexer, budget *Budget) *Multiplexer {
+	return &Multiplexer{Multiplexer: mux, budget: budget}
+}
+
+// ChatCompletion forwards the request unless its conversation is over budget, and counts its tokens.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	conversation := Conversation(ctx)
+	if err := m.admit(ctx, conversation); err != nil {
+		return nil, err
+	}
+	result, err := m.Multiplexer.ChatCompletion(ctx, model, messages)
+	m.spend(ctx, conversation, result, err)
+	return result, err
+}
+
+// Completion forwards the request unless its conversation is over budget, and counts its tokens.
+func (m *Multiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	conversation := Conversation(ctx)
+	if err := m.admit(ctx, conversation); err != nil {
+		return nil, err
+	}
+	result, err := m.Multiplexer.Completion(ctx, model, prompt)
+	m.spend(ctx, conversation, result, err)
+	return result, err
+}
+
+// ChatCompletionStream forwards the request unless its conversation is over budget, and counts its
+// tokens once the stream ends.
+func (m *Multiplexer) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	conversation := Conversation(ctx)
+	if err := m.admit(ctx, conversation); err != nil {
+		return nil, err
+	}
+	stream, err := m.Multiplexer.ChatCompletionStream(ctx, model, messages)
+	return m.tee(ctx, conversation, stream, err)
+}
+
+// CompletionStream forwards the request unless its conversation is over budget, and counts its
+// tokens once the stream ends.
+func (m *Multiplexer) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	conversation := Conversation(ctx)
+	if err := m.admit(ctx, conversation); err != nil {
+		return nil, err
+	}
+	stream, err := m.Multiplexer.CompletionStream(ctx, model, prompt)
+	return m.tee(ctx, conversation, stream, err)
+}
+
+// admit counts a turn of conversation, returning the error refusing it when it is over budget.
+func (m *Multiplexer) admit(ctx context.Context, conversation string) error {
+	if conversation == "" {
+		return nil
+	}
+	err := m.budget.Admit(ctx, conversation)
+	var exceeded *providers.BudgetExceededError
+	if err != nil && !errors.As(err, &exceeded) {
+		slog.ErrorContext(ctx, "Conversation budget check failed, allowing request",
+			"conversation_id", conversation, "error", err)
+		return nil
+	}
+	return err
+}
+
+func (m *Multiplexer) spend(ctx context.Context, conversation string, result interface{}, err error) {
+	if conversation == "" || err != nil {
+		return
+	}
+	if response, ok := result.(map[string]interface{}); ok {
+		m.record(ctx, conversation, ResponseTokens(response))
+	}
+}
+
+// tee passes a stream's chunks on and counts the tokens they report when it ends; the stream is
+// drained when the client disconnects, so what it used is counted still.
+func (m *Multiplexer) tee(
+	ctx context.Context, conversation string, stream <-chan interface{}, err error,
+) (<-chan interface{}, error) {
+	if conversation == "" || err != nil {
+		return stream, err
+	}
+
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		var tokens StreamTokens
+		disconnected := false
+		for chunk := range stream {
+			if c, ok := chunk.(map[string]interface{}); ok {
+				tokens.Add(c)
+			}
+			if disconnected {
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				disconnected = true
+			}
+		}
+		m.record(context.WithoutCancel(ctx), conversation, tokens.Total())
+	}()
+	return out, nil
+}
+
+func (m *Multiplexer) record(ctx context.Context, conversation string, tokens int64) {
+	if err := m.budget.Spend(ctx, conversation, tokens); err != nil {
+		slog.ErrorContext(ctx, "Failed to count conversation tokens", "conversation_id", conversation, "error", err)
+	}
+}
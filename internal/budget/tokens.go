@@ -0,0 +1,59 @@
+package budget
+
+// ResponseTokens returns the tokens a complete response reports using, prompt and completion:
+// an OpenAI or Anthropic usage object, or Ollama's eval counts.
+func ResponseTokens(response map[string]interface{}) int64 {
+	if usage, ok := response["usage"].(map[string]interface{}); ok {
+		return usageTokens(usage)
+	}
+	return intField(response, "prompt_eval_count") + intField(response, "eval_count")
+}
+
+// StreamTokens sums the tokens a stream reports using as its chunks arrive. OpenAI streams report
+// usage in their last chunk, Anthropic streams their input tokens at the start and the running
+// count of output tokens in message_delta events, and Ollama streams counts in the final line.
+type StreamTokens struct {
+	input  int64
+	output int64
+	total  int64
+}
+
+// Add counts the usage chunk reports.
+func (s *StreamTokens) Add(chunk map[string]interface{}) {
+	switch chunk["type"] {
+	case "message_start":
+		message, _ := chunk["message"].(map[string]interface{})
+		usage, _ := message["usage"].(map[string]interface{})
+		s.input = intField(usage, "input_tokens")
+		s.output = intField(usage, "output_tokens")
+		return
+	case "message_delta":
+		usage, _ := chunk["usage"].(map[string]interface{})
+		s.output = max(s.output, intField(usage, "output_tokens"))
+		return
+	}
+	if tokens := ResponseTokens(chunk); tokens > 0 {
+		s.total = tokens
+	}
+}
+
+// Total returns the tokens counted so far.
+func (s *StreamTokens) Total() int64 {
+	return s.input + s.output + s.total
+}
+
+// usageTokens returns the total of an OpenAI-style usage object; Anthropic's names are accepted too.
+func usageTokens(usage map[string]interface{}) int64 {
+	if total := intField(usage, "total_tokens"); total > 0 {
+		return total
+	}
+	return intField(usage, "prompt_tokens") + intField(usage, "completion_tokens") +
+		intField(usage, "input_tokens") + intField(usage, "output_tokens")
+}
+
+func intField(m map[string]interface{}, key string) int64 {
+	if val, ok := m[key].(float64); ok {
+		return int64(val)
+	}
+	return 0
+}
@@ -0,0 +1,265 @@
+// Package cache provides response caching for completions on top of a state backend.
+// Keys are versioned by a per-model generation counter so invalidation is a single
+// increment that every instance sharing the backend observes immediately.
+//
+// Cached responses are also indexed by the tenant and metadata of the request that stored them,
+// so Forget can delete those of a data subject. The index is kept in buckets a ttl long, so a
+// lookup only reads the two buckets whose entries may still be cached.
+package cache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"strconv"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/state"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+const (
+	// DefaultTTL is used when no ttl is configured
+	DefaultTTL = 10 * time.Minute
+
+	// allModelsGeneration is the generation key bumped to invalidate every model
+	allModelsGeneration = "*"
+)
+
+// Cache stores completion responses keyed by model and request parameters.
+type Cache struct {
+	store state.Store
+	ttl   time.Duration
+	now   func() time.Time
+}
+
+// indexEntry names a cached response and the request that stored it.
+type indexEntry struct {
+	Key      string            `json:"key"`
+	Tenant   string            `json:"tenant"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// New creates a cache on store; a non-positive ttl falls back to DefaultTTL.
+func New(store state.Store, ttl time.Duration) *Cache {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Cache{store: store, ttl: ttl, now: time.Now}
+}
+
+// Get returns a cached response for the request key, if any.
+func (c *Cache) Get(ctx context.Context, model string, request interface{}) (interface{}, bool, error) {
+	key, err := c.key(ctx, model, request)
+	if err != nil {
+		return nil, false, err
+	}
+
+	data, ok, err := c.store.Get(ctx, key)
+	if err != nil || !ok {
+		return nil, false, err
+	}
+
+	var result interface{}
+	if err := json.Unmarshal(data, &result); err != nil {
+		return nil, false, err
+	}
+	return result, true, nil
+}
+
+// Set stores a response for the request key.
+func (c *Cache) Set(ctx context.Context, model string, request, result interface{}) error {
+	key, err := c.key(ctx, model, request)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	if err := c.store.Set(ctx, key, data, c.ttl); err != nil {
+		return err
+	}
+	return c.index(ctx, key)
+}
+
+// index records key under every index key the request in ctx can be looked up by. A bucket's
+// counter outlives the entries added to it, which expire at most a ttl after the bucket ends.
+func (c *Cache) index(ctx context.Context, key string) error {
+	entry := indexEntry{Key: key, Tenant: usage.TenantFrom(ctx), Metadata: metadata.From(ctx)}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	bucket := c.bucket(c.now())
+	for _, indexKey := range erasure.Keys(entry.Tenant, entry.Metadata) {
+		slot, err := c.store.IncrBy(ctx, bucketKey(indexKey, bucket), 1, 2*c.ttl)
+		if err != nil {
+			return err
+		}
+		if err := c.store.Set(ctx, slotKey(indexKey, bucket, slot), data, 2*c.ttl); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Forget deletes the cached responses of the requests subject matches, returning how many.
+func (c *Cache) Forget(ctx context.Context, subject *erasure.Subject) (int, error) {
+	indexKey := subject.Key()
+	current := c.bucket(c.now())
+	deleted := 0
+	for _, bucket := range []int64{current - 1, current} {
+		data, ok, err := c.store.Get(ctx, bucketKey(indexKey, bucket))
+		if err != nil {
+			return deleted, err
+		}
+		if !ok {
+			continue
+		}
+		slots, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return deleted, err
+		}
+
+		for slot := int64(1); slot <= slots; slot++ {
+			data, ok, err := c.store.Get(ctx, slotKey(indexKey, bucket, slot))
+			if err != nil {
+				return deleted, err
+			}
+			var entry indexEntry
+			if !ok || json.Unmarshal(data, &entry) != nil || !subject.Matches(entry.Tenant, entry.Metadata) {
+				continue
+			}
+			if _, cached, err := c.store.Get(ctx, entry.Key); err != nil {
+				return deleted, err
+			} else if cached {
+				if err := c.store.Delete(ctx, entry.Key); err != nil {
+					return deleted, err
+				}
+				deleted++
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// bucket numbers the ttl-long period now falls in.
+func (c *Cache) bucket(now time.Time) int64 {
+	return now.UnixNano() / int64(c.ttl)
+}
+
+func bucketKey(indexKey string, bucket int64) string {
+	return fmt.Sprintf("cache:index:%d:%s", bucket, indexKey)
+}
+
+func slotKey(indexKey string, bucket, slot int64) string {
+	return fmt.Sprintf("cache:index:%d:%d:%s", bucket, slot, indexKey)
+}
+
+// Invalidate drops every cached response for model, or for all models if model is empty.
+// Old entries are orphaned rather than deleted and expire with their ttl.
+func (c *Cache) Invalidate(ctx context.Context, model string) error {
+	if model == "" {
+		model = allModelsGeneration
+	}
+	_, err := c.store.IncrBy(ctx, generationKey(model), 1, 0)
+	return err
+}
+
+// key builds a versioned key from the global and per-model generations plus a request hash.
+func (c *Cache) key(ctx context.Context, model string, request interface{}) (string, error) {
+	global, err := c.generation(ctx, allModelsGeneration)
+	if err != nil {
+		return "", err
+	}
+	perModel, err := c.generation(ctx, model)
+	if err != nil {
+		return "", err
+	}
+
+	// json.Marshal sorts map keys, so equal requests always hash the same
+	data, err := json.Marshal(request)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+
+	return fmt.Sprintf("cache:%d:%d:%s:%s", global, perModel, model, hex.EncodeToString(sum[:])), nil
+}
+
+func (c *Cache) generation(ctx context.Context, model string) (int64, error) {
+	data, ok, err := c.store.Get(ctx, generationKey(model))
+	if err != nil || !ok {
+		return 0, err
+	}
+	return strconv.ParseInt(string(data), 10, 64)
+}
+
+func generationKey(model string) string {
+	return "cache:generation:" + model
+}
+
+// Multiplexer wraps a multiplexer, serving completions from the cache. Streams are recorded as they
+// pass through and replayed as synthetic streams; listing passes straight through.
+type Multiplexer struct {
+	proxy.Multiplexer
+	cache *Cache
+}
+
+// NewMultiplexer wraps mux with cache.
+func NewMultiplexer(mux proxy.Multiplexer, cache *Cache) *Multiplexer {
+	return &Multiplexer{Multiplexer: mux, cache: cache}
+}
+
+// ChatCompletion returns a cached response or forwards the request and caches the result.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	request := map[string]interface{}{
+		"kind": "chat", "messages": messages, "params": providers.ParamsFrom(ctx).Values(),
+	}
+	return m.cached(ctx, model, request, func() (interface{}, error) {
+		return m.Multiplexer.ChatCompletion(ctx, model, messages)
+	})
+}
+
+// Completion returns a cached response or forwards the request and caches the result.
+func (m *Multiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	request := map[string]interface{}{
+		"kind": "completion", "prompt": prompt, "params": providers.ParamsFrom(ctx).Values(),
+	}
+	return m.cached(ctx, model, request, func() (interface{}, error) {
+		return m.Multiplexer.Completion(ctx, model, prompt)
+	})
+}
+
+// cached treats cache failures as misses so a degraded backend never fails a request.
+func (m *Multiplexer) cached(
+	ctx context.Context, model string, request interface{}, call func() (interface{}, error),
+) (interface{}, error) {
+	if result, ok, err := m.cache.Get(ctx, model, request); err != nil {
+		slog.Warn("Cache lookup failed", "model", model, "error", err)
+	} else if ok {
+		slog.Debug("Cache hit", "model", model)
+		return result, nil
+	}
+
+	result, err := call()
+	if err != nil {
+		return nil, err
+	}
+
+	if err := m.cache.Set(ctx, model, request, result); err != nil {
+		slog.Warn("Cache store failed", "model", model, "error", err)
+	}
+	return result, nil
+}
@@ -0,0 +1,264 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/state"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// countingMultiplexer counts upstream calls and answers with a fixed response.
+type countingMultiplexer struct {
+	proxy.Multiplexer
+	calls int
+	err   error
+	// chunks are streamed in answer to streaming calls
+	chunks []interface{}
+}
+
+func (m *countingMultiplexer) ChatCompletion(
+	_ context.Context, model string, _ []map[string]interface{},
+) (interface{}, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return map[string]interface{}{"model": model, "call": float64(m.calls)}, nil
+}
+
+func (m *countingMultiplexer) Completion(_ context.Context, model, _ string) (interface{}, error) {
+	m.calls++
+	return map[string]interface{}{"model": model, "call": float64(m.calls)}, nil
+}
+
+func (m *countingMultiplexer) ChatCompletionStream(
+	_ context.Context, _ string, _ []map[string]interface{},
+) (<-chan interface{}, error) {
+	return m.stream(), nil
+}
+
+func (m *countingMultiplexer) CompletionStream(_ context.Context, _, _ string) (<-chan interface{}, error) {
+	return m.stream(), nil
+}
+
+func (m *countingMultiplexer) stream() <-chan interface{} {
+	m.calls++
+	out := make(chan interface{}, len(m.chunks))
+	for _, chunk := range m.chunks {
+		out <- chunk
+	}
+	close(out)
+	return out
+}
+
+// collect reads stream to its end.
+func collect(stream <-chan interface{}, err error) ([]interface{}, error) {
+	var chunks []interface{}
+	if err != nil {
+		return nil, err
+	}
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	return chunks, nil
+}
+
+// choice returns the first choice of an OpenAI chunk.
+func choice(chunk interface{}) map[string]interface{} {
+	return chunk.(map[string]interface{})["choices"].([]interface{})[0].(map[string]interface{})
+}
+
+// chatChunk is an OpenAI chat completion chunk.
+func chatChunk(delta map[string]interface{}, finishReason interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id": "chatcmpl-1", "object": "chat.completion.chunk",
+		"choices": []interface{}{map[string]interface{}{"index": float64(0), "delta": delta, "finish_reason": finishReason}},
+	}
+}
+
+func TestMultiplexer_ServesFromCache(t *testing.T) {
+	upstream := &countingMultiplexer{}
+	mux := NewMultiplexer(upstream, New(state.NewMemoryStore(), 0))
+	messages := []map[string]interface{}{{"role": "user", "content": "Hello"}}
+
+	first, err := mux.ChatCompletion(t.Context(), "gpt-4", messages)
+	require.NoError(t, err)
+	second, err := mux.ChatCompletion(t.Context(), "gpt-4", messages)
+	require.NoError(t, err)
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, upstream.calls)
+
+	// Different parameters or models miss the cache
+	_, err = mux.ChatCompletion(t.Context(), "gpt-4", []map[string]interface{}{{"role": "user", "content": "Bye"}})
+	require.NoError(t, err)
+	_, err = mux.ChatCompletion(t.Context(), "gpt-3.5-turbo", messages)
+	require.NoError(t, err)
+	// Chat and text completions never share entries
+	_, err = mux.Completion(t.Context(), "gpt-4", "Hello")
+	require.NoError(t, err)
+	assert.Equal(t, 4, upstream.calls)
+}
+
+func TestMultiplexer_ErrorsNotCached(t *testing.T) {
+	upstream := &countingMultiplexer{err: errors.New("provider down")}
+	mux := NewMultiplexer(upstream, New(state.NewMemoryStore(), 0))
+
+	_, err := mux.ChatCompletion(t.Context(), "gpt-4", nil)
+	require.Error(t, err)
+
+	upstream.err = nil
+	_, err = mux.ChatCompletion(t.Context(), "gpt-4", nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, upstream.calls)
+}
+
+func TestCache_Invalidate(t *testing.T) {
+	upstream := &countingMultiplexer{}
+	c := New(state.NewMemoryStore(), 0)
+	mux := NewMultiplexer(upstream, c)
+	ctx := t.Context()
+
+	call := func(model string) {
+		_, err := mux.Completion(ctx, model, "prompt")
+		require.NoError(t, err)
+	}
+
+	call("gpt-4")
+	call("claude-3-sonnet")
+	assert.Equal(t, 2, upstream.calls)
+
+	require.NoError(t, c.Invalidate(ctx, "gpt-4"))
+	call("gpt-4")
+	call("claude-3-sonnet")
+	assert.Equal(t, 3, upstream.calls)
+
+	require.NoError(t, c.Invalidate(ctx, ""))
+	call("gpt-4")
+	call("claude-3-sonnet")
+	assert.Equal(t, 5, upstream.calls)
+}
+
+func TestCache_Forget(t *testing.T) {
+	upstream := &countingMultiplexer{}
+	c := New(state.NewMemoryStore(), time.Minute)
+	now := time.Date(2026, 5, 4, 12, 0, 30, 0, time.UTC)
+	c.now = func() time.Time { return now }
+	mux := NewMultiplexer(upstream, c)
+
+	call := func(tenant, conversation, prompt string) {
+		ctx := metadata.With(usage.WithTenant(t.Context(), tenant), map[string]string{"conversation_id": conversation})
+		_, err := mux.Completion(ctx, "gpt-4", prompt)
+		require.NoError(t, err)
+	}
+	call("acme", "c-1", "first")
+	call("acme", "c-2", "second")
+	now = now.Add(time.Minute) // the next index bucket
+	call("acme", "c-1", "third")
+	call("globex", "c-1", "fourth")
+	assert.Equal(t, 4, upstream.calls)
+
+	deleted, err := c.Forget(t.Context(), &erasure.Subject{ConversationID: "c-1", Tenant: "acme"})
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	deleted, err = c.Forget(t.Context(), &erasure.Subject{Metadata: map[string]string{"conversation_id": "c-1"}})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted, "globex's response is left")
+
+	call("acme", "c-2", "second")
+	assert.Equal(t, 4, upstream.calls, "other conversations stay cached")
+	call("acme", "c-1", "first")
+	assert.Equal(t, 5, upstream.calls)
+}
+
+func TestMultiplexer_ReplaysStreams(t *testing.T) {
+	upstream := &countingMultiplexer{chunks: []interface{}{
+		chatChunk(map[string]interface{}{"role": "assistant", "content": ""}, nil),
+		chatChunk(map[string]interface{}{"content": "Hel"}, nil),
+		chatChunk(map[string]interface{}{"content": "lo"}, nil),
+		chatChunk(map[string]interface{}{}, "stop"),
+	}}
+	mux := NewMultiplexer(upstream, New(state.NewMemoryStore(), 0))
+	messages := []map[string]interface{}{{"role": "user", "content": "Hello"}}
+
+	first, err := collect(mux.ChatCompletionStream(t.Context(), "gpt-4", messages))
+	require.NoError(t, err)
+	assert.Len(t, first, 4, "the live stream passes through unchanged")
+
+	// The replay carries the joined deltas and the chunks around them
+	second, err := collect(mux.ChatCompletionStream(t.Context(), "gpt-4", messages))
+	require.NoError(t, err)
+	assert.Equal(t, 1, upstream.calls)
+	require.Len(t, second, 3)
+	assert.Equal(t, map[string]interface{}{"content": "Hello"}, choice(second[1])["delta"])
+	assert.Equal(t, "stop", choice(second[2])["finish_reason"])
+
+	// Streams and complete responses never share entries
+	_, err = mux.ChatCompletion(t.Context(), "gpt-4", messages)
+	require.NoError(t, err)
+	assert.Equal(t, 2, upstream.calls)
+}
+
+func TestMultiplexer_IncompleteStreamsNotCached(t *testing.T) {
+	for name, chunks := range map[string][]interface{}{
+		"cut off": {chatChunk(map[string]interface{}{"content": "Hel"}, nil)},
+		"error": {
+			map[string]interface{}{"type": "message_start"},
+			map[string]interface{}{"type": "error", "error": map[string]interface{}{"type": "overloaded_error"}},
+			map[string]interface{}{"type": "message_stop"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			upstream := &countingMultiplexer{chunks: chunks}
+			mux := NewMultiplexer(upstream, New(state.NewMemoryStore(), 0))
+
+			for range 2 {
+				_, err := collect(mux.CompletionStream(t.Context(), "gpt-4", "Hello"))
+				require.NoError(t, err)
+			}
+			assert.Equal(t, 2, upstream.calls)
+		})
+	}
+}
+
+func TestCompact(t *testing.T) {
+	delta := func(index float64, kind, field, text string) map[string]interface{} {
+		return map[string]interface{}{
+			"type": "content_block_delta", "index": index, "delta": map[string]interface{}{"type": kind, field: text},
+		}
+	}
+	chunks := []interface{}{
+		map[string]interface{}{"type": "message_start"},
+		delta(0, "thinking_delta", "thinking", "Let me "),
+		delta(0, "thinking_delta", "thinking", "think."),
+		delta(1, "text_delta", "text", "Hi"),
+		delta(1, "text_delta", "text", " there"),
+		map[string]interface{}{"type": "content_block_stop", "index": float64(1)},
+		map[string]interface{}{"model": "llama3", "message": map[string]interface{}{"role": "assistant", "content": "a"},
+			"done": false},
+		map[string]interface{}{"model": "llama3", "message": map[string]interface{}{"role": "assistant", "content": "b"},
+			"done": false},
+		map[string]interface{}{"model": "llama3", "message": map[string]interface{}{"role": "assistant", "content": ""},
+			"done": true},
+	}
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"type": "message_start"},
+		delta(0, "thinking_delta", "thinking", "Let me think."),
+		delta(1, "text_delta", "text", "Hi there"),
+		map[string]interface{}{"type": "content_block_stop", "index": float64(1)},
+		map[string]interface{}{"model": "llama3", "message": map[string]interface{}{"role": "assistant", "content": "ab"},
+			"done": false},
+		map[string]interface{}{"model": "llama3", "message": map[string]interface{}{"role": "assistant", "content": ""},
+			"done": true},
+	}, compact(chunks))
+}
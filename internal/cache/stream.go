@@ -0,0 +1,295 @@
+package cache
+
+import (
+	"cmp"
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// errNotStream is returned for a cached entry that isn't a recorded stream.
+var errNotStream = errors.New("cached entry is not a stream")
+
+// ChatCompletionStream replays a recorded stream or forwards the request, recording the stream
+// for identical requests once it finishes.
+func (m *Multiplexer) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	request := map[string]interface{}{
+		"kind": "chat_stream", "messages": messages, "params": providers.ParamsFrom(ctx).Values(),
+	}
+	return m.cachedStream(ctx, model, request, func() (<-chan interface{}, error) {
+		return m.Multiplexer.ChatCompletionStream(ctx, model, messages)
+	})
+}
+
+// CompletionStream replays a recorded stream or forwards the request, recording the stream for
+// identical requests once it finishes.
+func (m *Multiplexer) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	request := map[string]interface{}{
+		"kind": "completion_stream", "prompt": prompt, "params": providers.ParamsFrom(ctx).Values(),
+	}
+	return m.cachedStream(ctx, model, request, func() (<-chan interface{}, error) {
+		return m.Multiplexer.CompletionStream(ctx, model, prompt)
+	})
+}
+
+// cachedStream serves a recorded stream as a synthetic one, or records the upstream stream as it
+// passes through. Like cached it treats cache failures as misses.
+func (m *Multiplexer) cachedStream(
+	ctx context.Context, model string, request interface{}, call func() (<-chan interface{}, error),
+) (<-chan interface{}, error) {
+	if result, ok, err := m.cache.Get(ctx, model, request); err != nil {
+		slog.Warn("Cache lookup failed", "model", model, "error", err)
+	} else if ok {
+		chunks, err := streamChunks(result)
+		if err == nil {
+			slog.Debug("Cache hit", "model", model, "stream", true)
+			return replay(ctx, chunks), nil
+		}
+		slog.Warn("Ignoring cached stream", "model", model, "error", err)
+	}
+
+	upstream, err := call()
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		// Chunks are copied before they are passed on, since the proxy may modify them in place
+		var recorded []json.RawMessage
+		var recordErr error
+		for chunk := range upstream {
+			data, err := json.Marshal(chunk)
+			recorded, recordErr = append(recorded, data), cmp.Or(recordErr, err)
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				// The client left; drain so the upstream can finish, but don't keep a partial stream
+				for range upstream {
+				}
+				return
+			}
+		}
+		if recordErr != nil {
+			slog.Warn("Cache store failed", "model", model, "error", recordErr)
+			return
+		}
+		m.record(ctx, model, request, recorded)
+	}()
+	return out, nil
+}
+
+// record stores the recorded chunks, with their text deltas joined, when they make up a complete stream.
+func (m *Multiplexer) record(ctx context.Context, model string, request interface{}, recorded []json.RawMessage) {
+	chunks := make([]interface{}, len(recorded))
+	for i, data := range recorded {
+		if err := json.Unmarshal(data, &chunks[i]); err != nil {
+			slog.Warn("Cache store failed", "model", model, "error", err)
+			return
+		}
+	}
+	if !complete(chunks) {
+		slog.Debug("Not caching incomplete stream", "model", model)
+		return
+	}
+
+	if err := m.cache.Set(ctx, model, request, map[string]interface{}{"chunks": compact(chunks)}); err != nil {
+		slog.Warn("Cache store failed", "model", model, "error", err)
+	}
+}
+
+// streamChunks returns the chunks of a recorded stream.
+func streamChunks(result interface{}) ([]interface{}, error) {
+	entry, _ := result.(map[string]interface{})
+	chunks, ok := entry["chunks"].([]interface{})
+	if !ok {
+		return nil, errNotStream
+	}
+	return chunks, nil
+}
+
+// replay streams chunks as they were recorded.
+func replay(ctx context.Context, chunks []interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for _, chunk := range chunks {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// complete reports whether chunks make up a whole stream: one that ended with a finish reason,
+// rather than being cut off, and carried no error.
+func complete(chunks []interface{}) bool {
+	finished := false
+	for _, chunk := range chunks {
+		c, ok := chunk.(map[string]interface{})
+		if !ok {
+			return false
+		}
+		if _, ok := c["error"]; ok || c["type"] == "error" {
+			return false
+		}
+		finished = finished || finishes(c)
+	}
+	return finished
+}
+
+// finishes reports whether chunk ends a stream: an OpenAI chunk with a finish reason, Anthropic's
+// message_stop or Ollama's final line.
+func finishes(chunk map[string]interface{}) bool {
+	if chunk["type"] == "message_stop" || chunk["done"] == true {
+		return true
+	}
+	choices, _ := chunk["choices"].([]interface{})
+	for _, choice := range choices {
+		if c, ok := choice.(map[string]interface{}); ok && c["finish_reason"] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// compact joins runs of chunks that only add text into one chunk, so a recorded stream is its
+// concatenated deltas plus the chunks that carry anything else, such as the final one.
+func compact(chunks []interface{}) []interface{} {
+	compacted := make([]interface{}, 0, len(chunks))
+	var last map[string]interface{}
+	for _, chunk := range chunks {
+		c, _ := chunk.(map[string]interface{})
+		if last != nil && c != nil && appendText(last, c) {
+			continue
+		}
+		compacted = append(compacted, chunk)
+		last = c
+	}
+	return compacted
+}
+
+// appendText appends the text of next to last when both only add text at the same place,
+// reporting whether it did.
+func appendText(last, next map[string]interface{}) bool {
+	lastText, ok := textOf(last)
+	if !ok {
+		return false
+	}
+	nextText, ok := textOf(next)
+	if !ok || !samePlace(last, next) {
+		return false
+	}
+	setText(last, lastText+nextText)
+	return true
+}
+
+// textOf returns the text a chunk adds, if adding text is all it does.
+func textOf(chunk map[string]interface{}) (string, bool) {
+	// Anthropic text and thinking deltas
+	if chunk["type"] == "content_block_delta" {
+		delta, _ := chunk["delta"].(map[string]interface{})
+		switch delta["type"] {
+		case "text_delta":
+			text, ok := delta["text"].(string)
+			return text, ok
+		case "thinking_delta":
+			text, ok := delta["thinking"].(string)
+			return text, ok
+		}
+		return "", false
+	}
+
+	// Ollama lines before the final one
+	if done, ok := chunk["done"].(bool); ok {
+		if done {
+			return "", false
+		}
+		if message, ok := chunk["message"].(map[string]interface{}); ok {
+			if len(message) != 2 || message["role"] != "assistant" {
+				return "", false
+			}
+			text, ok := message["content"].(string)
+			return text, ok
+		}
+		text, ok := chunk["response"].(string)
+		return text, ok
+	}
+
+	// OpenAI chat and text completion chunks with a single choice
+	choices, _ := chunk["choices"].([]interface{})
+	if len(choices) != 1 || chunk["usage"] != nil {
+		return "", false
+	}
+	choice, _ := choices[0].(map[string]interface{})
+	if choice == nil || choice["finish_reason"] != nil || choice["logprobs"] != nil {
+		return "", false
+	}
+	if delta, ok := choice["delta"].(map[string]interface{}); ok {
+		if len(delta) != 1 {
+			return "", false
+		}
+		text, ok := delta["content"].(string)
+		return text, ok
+	}
+	text, ok := choice["text"].(string)
+	return text, ok
+}
+
+// samePlace reports whether two text chunks add to the same content block or choice.
+func samePlace(a, b map[string]interface{}) bool {
+	if a["type"] != b["type"] || a["index"] != b["index"] {
+		return false
+	}
+	aChoices, _ := a["choices"].([]interface{})
+	bChoices, _ := b["choices"].([]interface{})
+	if len(aChoices) != len(bChoices) {
+		return false
+	}
+	if len(aChoices) == 1 {
+		aChoice, _ := aChoices[0].(map[string]interface{})
+		bChoice, _ := bChoices[0].(map[string]interface{})
+		if aChoice["index"] != bChoice["index"] || (aChoice["delta"] == nil) != (bChoice["delta"] == nil) {
+			return false
+		}
+	}
+	_, aMessage := a["message"]
+	_, bMessage := b["message"]
+	return aMessage == bMessage
+}
+
+// setText replaces the text of a chunk textOf accepted.
+func setText(chunk map[string]interface{}, text string) {
+	if chunk["type"] == "content_block_delta" {
+		delta := chunk["delta"].(map[string]interface{})
+		if delta["type"] == "thinking_delta" {
+			delta["thinking"] = text
+		} else {
+			delta["text"] = text
+		}
+		return
+	}
+	if _, ok := chunk["done"].(bool); ok {
+		if message, ok := chunk["message"].(map[string]interface{}); ok {
+			message["content"] = text
+		} else {
+			chunk["response"] = text
+		}
+		return
+	}
+	choice := chunk["choices"].([]interface{})[0].(map[string]interface{})
+	if delta, ok := choice["delta"].(map[string]interface{}); ok {
+		delta["content"] = text
+	} else {
+		choice["text"] = text
+	}
+}
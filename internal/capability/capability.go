@@ -0,0 +1,196 @@
+// Package capability mints and checks the tokens that scope which MCP tools an agent may call.
+// A token is minted per conversation for the tools the conversation needs, optionally with patterns
+// their arguments must match, and expires after minutes; a hijacked agent holding one can't call
+// unrelated tools, aim permitted ones elsewhere or use it in another conversation. Tokens are signed,
+// so checking one needs no state.
+package capability
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	// Header carries a capability token on tool calls
+	Header = "X-Modelplex-Capability"
+
+	// prefix marks capability tokens, so they can't be mistaken for other credentials
+	prefix = "mcap."
+	// secretSize is the size of the random secret used when none is configured
+	secretSize = 32
+)
+
+var (
+	// ErrInvalid is returned for a token that is malformed or wasn't signed with the secret
+	ErrInvalid = errors.New("invalid capability token")
+	// ErrExpired is returned for a token past its expiry
+	ErrExpired = errors.New("capability token expired")
+)
+
+// DeniedError is returned when a valid token doesn't permit a tool call.
+type DeniedError struct {
+	Tool   string
+	Reason string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("capability token does not permit calling %s: %s", e.Tool, e.Reason)
+}
+
+// Grant is what a token permits.
+type Grant struct {
+	// Conversation is the conversation the token was minted for
+	Conversation string `json:"conversation_id"`
+	// Tools maps each permitted tool to the patterns its arguments must match, by argument name.
+	// Patterns match whole values, non-string ones in their JSON encoding; arguments without a
+	// pattern may take any value.
+	Tools map[string]map[string]string `json:"tools"`
+	// Expires is when the token stops being accepted
+	Expires time.Time `json:"expires_at"`
+}
+
+// Validate checks that the grant names a conversation and tools and that its patterns compile.
+func (g *Grant) Validate() error {
+	if g.Conversation == "" {
+		return errors.New("conversation_id is required")
+	}
+	if len(g.Tools) == 0 {
+		return errors.New("tools is required")
+	}
+	for tool, patterns := range g.Tools {
+		for arg, pattern := range patterns {
+			if _, err := compile(pattern); err != nil {
+				return fmt.Errorf("tool %s argument %s: %w", tool, arg, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Permit returns a DeniedError unless the grant permits calling tool with args in conversation.
+func (g *Grant) Permit(conversation, tool string, args map[string]interface{}) error {
+	if conversation != g.Conversation {
+		return &DeniedError{Tool: tool, Reason: "token was minted for another conversation"}
+	}
+	patterns, ok := g.Tools[tool]
+	if !ok {
+		return &DeniedError{Tool: tool, Reason: "tool not granted"}
+	}
+	for _, arg := range slices.Sorted(maps.Keys(patterns)) {
+		value, ok := args[arg]
+		if !ok {
+			return &DeniedError{Tool: tool, Reason: fmt.Sprintf("argument %q is required", arg)}
+		}
+		pattern, err := compile(patterns[arg])
+		if err != nil {
+			return &DeniedError{Tool: tool, Reason: err.Error()}
+		}
+		if !pattern.MatchString(argumentText(value)) {
+			return &DeniedError{Tool: tool, Reason: fmt.Sprintf("argument %q is outside the granted pattern", arg)}
+		}
+	}
+	return nil
+}
+
+// compile anchors pattern, so it must match an argument's whole value.
+func compile(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// argumentText returns the text an argument's pattern is matched against.
+func argumentText(value interface{}) string {
+	if s, ok := value.(string); ok {
+		return s
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(data)
+}
+
+// Minter mints and verifies tokens signed with its secret.
+type Minter struct {
+	secret []byte
+	ttl    time.Duration
+	// now is swappable so tests can expire tokens
+	now func() time.Time
+}
+
+// NewMinter creates a minter signing with secret, or with a random secret when it is empty, in which
+// case tokens are only accepted by this process. Tokens live for ttl at most.
+func NewMinter(secret string, ttl time.Duration) (*Minter, error) {
+	key := []byte(secret)
+	if secret == "" {
+		key = make([]byte, secretSize)
+		if _, err := rand.Read(key); err != nil {
+			return nil, err
+		}
+	}
+	return &Minter{secret: key, ttl: ttl, now: time.Now}, nil
+}
+
+// Mint returns a token for grant, valid for ttl, or the minter's ttl when ttl is 0 or longer, and the
+// grant with its expiry.
+func (m *Minter) Mint(grant Grant, ttl time.Duration) (string, Grant, error) {
+	if err := grant.Validate(); err != nil {
+		return "", Grant{}, err
+	}
+	if ttl <= 0 || ttl > m.ttl {
+		ttl = m.ttl
+	}
+	grant.Expires = m.now().Add(ttl).UTC().Truncate(time.Second)
+	payload, err := json.Marshal(grant)
+	if err != nil {
+		return "", Grant{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return prefix + encoded + "." + m.sign(encoded), grant, nil
+}
+
+// Verify returns the grant of token, or ErrInvalid or ErrExpired.
+func (m *Minter) Verify(token string) (*Grant, error) {
+	encoded, signature, ok := strings.Cut(strings.TrimPrefix(token, prefix), ".")
+	if !ok || !strings.HasPrefix(token, prefix) || !hmac.Equal([]byte(signature), []byte(m.sign(encoded))) {
+		return nil, ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var grant Grant
+	if err := json.Unmarshal(payload, &grant); err != nil {
+		return nil, ErrInvalid
+	}
+	if !m.now().Before(grant.Expires) {
+		return nil, ErrExpired
+	}
+	return &grant, nil
+}
+
+// Authorize verifies token and checks that its grant permits calling tool with args in conversation,
+// returning the grant. It returns ErrInvalid or ErrExpired for a token it can't verify, and a
+// DeniedError for a call the token doesn't permit.
+func (m *Minter) Authorize(token, conversation, tool string, args map[string]interface{}) (*Grant, error) {
+	grant, err := m.Verify(token)
+	if err != nil {
+		return nil, err
+	}
+	return grant, grant.Permit(conversation, tool, args)
+}
+
+func (m *Minter) sign(encoded string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
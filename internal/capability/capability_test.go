@@ -0,0 +1,101 @@
+package capability
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinter_MintAndVerify(t *testing.T) {
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	minter, err := NewMinter("secret", 15*time.Minute)
+	require.NoError(t, err)
+	minter.now = func() time.Time { return now }
+	grant := Grant{Conversation: "conv-1", Tools: map[string]map[string]string{"read_file": {"path": `/workspace/.*`}}}
+
+	token, minted, err := minter.Mint(grant, time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, now.Add(15*time.Minute), minted.Expires, "tokens never outlive the configured ttl")
+	verified, err := minter.Verify(token)
+	require.NoError(t, err)
+	assert.Equal(t, &minted, verified)
+
+	other, err := NewMinter("other secret", 15*time.Minute)
+	require.NoError(t, err)
+	_, err = other.Verify(token)
+	assert.ErrorIs(t, err, ErrInvalid, "tokens are only accepted with the secret that signed them")
+	_, err = minter.Verify(token[:len(token)-2])
+	assert.ErrorIs(t, err, ErrInvalid)
+	_, err = minter.Verify("Bearer " + token)
+	assert.ErrorIs(t, err, ErrInvalid)
+
+	now = now.Add(15 * time.Minute)
+	_, err = minter.Verify(token)
+	assert.ErrorIs(t, err, ErrExpired)
+
+	_, _, err = minter.Mint(Grant{Conversation: "conv-1"}, 0)
+	assert.EqualError(t, err, "tools is required")
+	_, _, err = minter.Mint(Grant{Conversation: "conv-1", Tools: map[string]map[string]string{"ls": {"dir": "("}}}, 0)
+	assert.ErrorContains(t, err, "tool ls argument dir")
+}
+
+func TestGrant_Permit(t *testing.T) {
+	grant := &Grant{Conversation: "conv-1", Tools: map[string]map[string]string{
+		"read_file": {"path": `/workspace/[^.][^/]*`},
+		"search":    nil,
+		"fetch":     {"limit": `[1-9]`},
+	}}
+
+	tests := []struct {
+		name         string
+		conversation string
+		tool         string
+		args         map[string]interface{}
+		denied       string
+	}{
+		{"granted", "conv-1", "read_file", map[string]interface{}{"path": "/workspace/notes.md"}, ""},
+		{"any arguments", "conv-1", "search", map[string]interface{}{"query": "anything"}, ""},
+		{"non-string argument", "conv-1", "fetch", map[string]interface{}{"limit": 5}, ""},
+		{"tool not granted", "conv-1", "delete_file", nil, "tool not granted"},
+		{"whole value must match", "conv-1", "read_file", map[string]interface{}{"path": "/workspace/../etc/passwd"},
+			`argument "path" is outside the granted pattern`},
+		{"missing argument", "conv-1", "read_file", map[string]interface{}{}, `argument "path" is required`},
+		{"another conversation", "conv-2", "search", nil, "token was minted for another conversation"},
+		{"no conversation", "", "search", nil, "token was minted for another conversation"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := grant.Permit(tt.conversation, tt.tool, tt.args)
+			if tt.denied == "" {
+				assert.NoError(t, err)
+				return
+			}
+			var denied *DeniedError
+			require.ErrorAs(t, err, &denied)
+			assert.Equal(t, tt.denied, denied.Reason)
+		})
+	}
+}
+
+func TestMinter_Authorize(t *testing.T) {
+	minter, err := NewMinter("secret", time.Hour)
+	require.NoError(t, err)
+	token, _, err := minter.Mint(Grant{Conversation: "conv-1", Tools: map[string]map[string]string{"search": nil}}, 0)
+	require.NoError(t, err)
+
+	grant, err := minter.Authorize(token, "conv-1", "search", map[string]interface{}{"query": "go"})
+	require.NoError(t, err)
+	assert.Equal(t, "conv-1", grant.Conversation)
+
+	var denied *DeniedError
+	_, err = minter.Authorize(token, "conv-2", "search", nil)
+	require.ErrorAs(t, err, &denied, "tokens don't carry over to other conversations")
+	assert.Equal(t, "token was minted for another conversation", denied.Reason)
+	_, err = minter.Authorize(token, "conv-1", "delete_file", nil)
+	require.ErrorAs(t, err, &denied)
+	assert.Equal(t, &DeniedError{Tool: "delete_file", Reason: "tool not granted"}, denied)
+	_, err = minter.Authorize("mcap.forged.token", "conv-1", "search", nil)
+	assert.ErrorIs(t, err, ErrInvalid)
+}
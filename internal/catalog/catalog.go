@@ -0,0 +1,134 @@
+// Package catalog ships the context windows, modalities and prices of well-known models, so cost
+// tracking, token limits and model listings work without entering them by hand. Whatever the config
+// sets takes precedence over the catalog.
+package catalog
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+//go:embed catalog.json
+var builtinJSON []byte
+
+// Model describes a model; zero values are unknown.
+type Model struct {
+	ContextWindow   int64    `json:"context_window,omitempty"`
+	MaxOutputTokens int64    `json:"max_output_tokens,omitempty"`
+	Modalities      []string `json:"modalities,omitempty"`
+	Pricing         *Pricing `json:"pricing,omitempty"`
+}
+
+// Pricing is the price of a model in currency units per million tokens.
+type Pricing struct {
+	Input  float64 `json:"input"`
+	Output float64 `json:"output"`
+}
+
+// Catalog maps model names to their descriptions.
+type Catalog map[string]Model
+
+var builtin = sync.OnceValue(func() Catalog {
+	c, err := Parse(builtinJSON)
+	if err != nil {
+		panic(fmt.Sprintf("invalid built-in catalog: %v", err))
+	}
+	return c
+})
+
+// Builtin returns the catalog shipped with modelplex. It must not be modified.
+func Builtin() Catalog {
+	return builtin()
+}
+
+// Parse parses a catalog in the JSON format of the built-in one.
+func Parse(data []byte) (Catalog, error) {
+	var c Catalog
+	if err := json.Unmarshal(data, &c); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Lookup returns the description of model. Names qualified by their vendor, such as
+// "openai/gpt-4o", are looked up without it when they aren't listed as is.
+func (c Catalog) Lookup(model string) (Model, bool) {
+	if m, ok := c[model]; ok {
+		return m, true
+	}
+	if i := strings.LastIndex(model, "/"); i >= 0 {
+		m, ok := c[model[i+1:]]
+		return m, ok
+	}
+	return Model{}, false
+}
+
+// Apply fills in the prices and token limits cfg leaves unset for its providers' models from the
+// built-in catalog, unless the catalog is disabled. Like config.ApplyDefaults it is idempotent.
+func Apply(cfg *config.Config) {
+	if cfg.Catalog.Disabled {
+		return
+	}
+	Builtin().apply(cfg)
+}
+
+func (c Catalog) apply(cfg *config.Config) {
+	for i := range cfg.Providers {
+		p := &cfg.Providers[i]
+		for _, model := range p.Models {
+			// Limits are keyed by the backend's model name, prices by the public one
+			backend := model
+			if mapped, ok := p.ModelMap[model]; ok {
+				backend = mapped
+			}
+			entry, ok := c.Lookup(backend)
+			if !ok {
+				continue
+			}
+
+			if _, ok := p.ModelLimits[backend]; !ok && (entry.ContextWindow > 0 || entry.MaxOutputTokens > 0) {
+				if p.ModelLimits == nil {
+					p.ModelLimits = make(map[string]config.ModelLimits)
+				}
+				p.ModelLimits[backend] = config.ModelLimits{
+					ContextWindow: entry.ContextWindow, MaxOutputTokens: entry.MaxOutputTokens,
+				}
+			}
+			if _, ok := cfg.Usage.Prices[model]; !ok && entry.Pricing != nil {
+				if cfg.Usage.Prices == nil {
+					cfg.Usage.Prices = make(map[string]config.ModelPrice)
+				}
+				cfg.Usage.Prices[model] = config.ModelPrice{Input: entry.Pricing.Input, Output: entry.Pricing.Output}
+			}
+		}
+	}
+}
+
+// Describe returns the description of model under cfg: the catalog's, with the price and token
+// limits the config sets instead where it does. The limits are those of the first provider
+// serving model that sets any.
+func (c Catalog) Describe(cfg *config.Config, model string) (Model, bool) {
+	entry, found := c.Lookup(model)
+	if price, ok := cfg.Usage.Prices[model]; ok {
+		entry.Pricing = &Pricing{Input: price.Input, Output: price.Output}
+		found = true
+	}
+	for _, p := range cfg.Providers {
+		backend := model
+		if mapped, ok := p.ModelMap[model]; ok {
+			backend = mapped
+		}
+		if limits, ok := p.ModelLimits[backend]; ok && slices.Contains(p.Models, model) {
+			entry.ContextWindow, entry.MaxOutputTokens = limits.ContextWindow, limits.MaxOutputTokens
+			found = true
+			break
+		}
+	}
+	return entry, found
+}
@@ -0,0 +1,75 @@
+package catalog
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestBuiltin(t *testing.T) {
+	require.NotEmpty(t, Builtin())
+	for name, model := range Builtin() {
+		assert.Positive(t, model.ContextWindow, name)
+		assert.LessOrEqual(t, model.MaxOutputTokens, model.ContextWindow, name)
+		assert.NotEmpty(t, model.Modalities, name)
+		require.NotNil(t, model.Pricing, name)
+		assert.Positive(t, model.Pricing.Input, name)
+	}
+}
+
+func TestLookup(t *testing.T) {
+	c := Catalog{"gpt-4o": {ContextWindow: 128000}}
+
+	model, ok := c.Lookup("openai/gpt-4o")
+	require.True(t, ok)
+	assert.Equal(t, int64(128000), model.ContextWindow)
+
+	_, ok = c.Lookup("gpt-4o-2024-05-13")
+	assert.False(t, ok)
+}
+
+func TestApply(t *testing.T) {
+	c := Catalog{
+		"gpt-4o":      {ContextWindow: 128000, MaxOutputTokens: 16384, Pricing: &Pricing{Input: 2.5, Output: 10}},
+		"gpt-4o-mini": {ContextWindow: 128000, MaxOutputTokens: 16384, Pricing: &Pricing{Input: 0.15, Output: 0.6}},
+	}
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "openai", Models: []string{"gpt-4o", "gpt-4o-mini"},
+				ModelLimits: map[string]config.ModelLimits{"gpt-4o": {ContextWindow: 64000}}},
+			// Served under another name, which the limits but not the price are keyed by
+			{Name: "gateway", Models: []string{"fast"}, ModelMap: map[string]string{"fast": "gpt-4o-mini"}},
+			{Name: "local", Models: []string{"llama2"}},
+		},
+		Usage: config.UsageConfig{Prices: map[string]config.ModelPrice{"gpt-4o-mini": {Input: 1, Output: 2}}},
+	}
+	c.apply(cfg)
+	c.apply(cfg)
+
+	assert.Equal(t, map[string]config.ModelLimits{
+		"gpt-4o":      {ContextWindow: 64000},
+		"gpt-4o-mini": {ContextWindow: 128000, MaxOutputTokens: 16384},
+	}, cfg.Providers[0].ModelLimits)
+	assert.Equal(t, map[string]config.ModelLimits{
+		"gpt-4o-mini": {ContextWindow: 128000, MaxOutputTokens: 16384},
+	}, cfg.Providers[1].ModelLimits)
+	assert.Nil(t, cfg.Providers[2].ModelLimits)
+	assert.Equal(t, map[string]config.ModelPrice{
+		"gpt-4o":      {Input: 2.5, Output: 10},
+		"gpt-4o-mini": {Input: 1, Output: 2},
+		"fast":        {Input: 0.15, Output: 0.6},
+	}, cfg.Usage.Prices)
+}
+
+func TestApply_Disabled(t *testing.T) {
+	cfg := &config.Config{
+		Providers: []config.Provider{{Name: "openai", Models: []string{"gpt-4o"}}},
+		Catalog:   config.CatalogConfig{Disabled: true},
+	}
+	Apply(cfg)
+	assert.Nil(t, cfg.Providers[0].ModelLimits)
+	assert.Nil(t, cfg.Usage.Prices)
+}
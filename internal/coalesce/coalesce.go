@@ -0,0 +1,200 @@
+// Package coalesce merges identical concurrent non-streaming requests into a single upstream call.
+// Parallel agent branches often send the same completion at the same moment; only the first
+// reaches the provider and every caller receives its response.
+package coalesce
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	"sync"
+
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+const (
+	// RouteChatCompletions is the /v1/chat/completions route
+	RouteChatCompletions = "chat/completions"
+	// RouteCompletions is the /v1/completions route
+	RouteCompletions = "completions"
+)
+
+// RouteStats counts requests on one route.
+type RouteStats struct {
+	// UpstreamCalls is the number of requests forwarded to a provider
+	UpstreamCalls int64 `json:"upstream_calls"`
+	// Coalesced is the number of requests answered by another request's upstream call
+	Coalesced int64 `json:"coalesced"`
+}
+
+// Stats collects per-route counts. It outlives a Multiplexer so counts survive config reloads.
+type Stats struct {
+	mtx    sync.Mutex
+	routes map[string]*RouteStats
+}
+
+// NewStats creates empty stats.
+func NewStats() *Stats {
+	return &Stats{routes: make(map[string]*RouteStats)}
+}
+
+// Snapshot returns a copy of the current counts keyed by route.
+func (s *Stats) Snapshot() map[string]RouteStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	out := make(map[string]RouteStats, len(s.routes))
+	for route, stats := range s.routes {
+		out[route] = *stats
+	}
+	return out
+}
+
+func (s *Stats) record(route string, coalesced bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	stats, ok := s.routes[route]
+	if !ok {
+		stats = &RouteStats{}
+		s.routes[route] = stats
+	}
+	if coalesced {
+		stats.Coalesced++
+	} else {
+		stats.UpstreamCalls++
+	}
+}
+
+// call is an upstream request shared by every caller waiting on it.
+type call struct {
+	done    chan struct{}
+	result  interface{}
+	err     error
+	waiters int
+	cancel  context.CancelFunc
+}
+
+// Multiplexer wraps a multiplexer, coalescing non-streaming completions on the enabled routes.
+// Streaming calls and listing pass straight through to the embedded multiplexer.
+type Multiplexer struct {
+	proxy.Multiplexer
+	routes map[string]bool
+	stats  *Stats
+
+	mtx   sync.Mutex
+	calls map[string]*call
+}
+
+// NewMultiplexer wraps mux, coalescing requests on routes and counting them in stats.
+func NewMultiplexer(mux proxy.Multiplexer, routes []string, stats *Stats) *Multiplexer {
+	enabled := make(map[string]bool, len(routes))
+	for _, route := range routes {
+		enabled[route] = true
+	}
+	return &Multiplexer{Multiplexer: mux, routes: enabled, stats: stats, calls: make(map[string]*call)}
+}
+
+// ChatCompletion joins an identical in-flight chat completion or starts one.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	if !m.routes[RouteChatCompletions] {
+		return m.Multiplexer.ChatCompletion(ctx, model, messages)
+	}
+	return m.do(ctx, RouteChatCompletions, model, messages, func(ctx context.Context) (interface{}, error) {
+		return m.Multiplexer.ChatCompletion(ctx, model, messages)
+	})
+}
+
+// Completion joins an identical in-flight completion or starts one.
+func (m *Multiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	if !m.routes[RouteCompletions] {
+		return m.Multiplexer.Completion(ctx, model, prompt)
+	}
+	return m.do(ctx, RouteCompletions, model, prompt, func(ctx context.Context) (interface{}, error) {
+		return m.Multiplexer.Completion(ctx, model, prompt)
+	})
+}
+
+// do runs fn once per distinct in-flight request. The upstream call is detached from the caller
+// that started it, so one client disconnecting doesn't fail the others; it is cancelled once
+// every waiting caller has gone.
+func (m *Multiplexer) do(
+	ctx context.Context, route, model string, request interface{}, fn func(context.Context) (interface{}, error),
+) (interface{}, error) {
+	key, err := key(ctx, route, model, request)
+	if err != nil {
+		slog.Warn("Failed to build coalescing key", "model", model, "error", err)
+		return fn(ctx)
+	}
+
+	m.mtx.Lock()
+	c, joined := m.calls[key]
+	if joined {
+		c.waiters++
+	} else {
+		callCtx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		c = &call{done: make(chan struct{}), waiters: 1, cancel: cancel}
+		m.calls[key] = c
+		go m.run(callCtx, key, c, fn)
+	}
+	m.mtx.Unlock()
+
+	m.stats.record(route, joined)
+	if joined {
+		slog.Debug("Coalesced request", "route", route, "model", model)
+	}
+
+	select {
+	case <-c.done:
+		return c.result, c.err
+	case <-ctx.Done():
+		m.leave(key, c)
+		return nil, ctx.Err()
+	}
+}
+
+func (m *Multiplexer) run(ctx context.Context, key string, c *call, fn func(context.Context) (interface{}, error)) {
+	c.result, c.err = fn(ctx)
+
+	m.mtx.Lock()
+	if m.calls[key] == c {
+		delete(m.calls, key)
+	}
+	m.mtx.Unlock()
+
+	c.cancel()
+	close(c.done)
+}
+
+// leave drops a caller that gave up waiting, cancelling the upstream call if it was the last one.
+func (m *Multiplexer) leave(key string, c *call) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	c.waiters--
+	if c.waiters > 0 {
+		return
+	}
+	c.cancel()
+	// Later identical requests must not join a cancelled call
+	if m.calls[key] == c {
+		delete(m.calls, key)
+	}
+}
+
+// key identifies identical requests, parameters included.
+// The tenant is included so usage stays attributed correctly.
+func key(ctx context.Context, route, model string, request interface{}) (string, error) {
+	data, err := json.Marshal([]interface{}{request, providers.ParamsFrom(ctx).Values()})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return route + "\x00" + usage.TenantFrom(ctx) + "\x00" + model + "\x00" + hex.EncodeToString(sum[:]), nil
+}
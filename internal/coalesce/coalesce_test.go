@@ -0,0 +1,180 @@
+package coalesce
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// blockingMultiplexer holds every call until release is closed and counts the calls that reached it.
+type blockingMultiplexer struct {
+	proxy.Multiplexer
+	calls   atomic.Int32
+	release chan struct{}
+	err     error
+}
+
+func (m *blockingMultiplexer) ChatCompletion(
+	ctx context.Context, model string, _ []map[string]interface{},
+) (interface{}, error) {
+	return m.wait(ctx, model)
+}
+
+func (m *blockingMultiplexer) Completion(ctx context.Context, model, _ string) (interface{}, error) {
+	return m.wait(ctx, model)
+}
+
+func (m *blockingMultiplexer) wait(ctx context.Context, model string) (interface{}, error) {
+	n := m.calls.Add(1)
+	select {
+	case <-m.release:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+	if m.err != nil {
+		return nil, m.err
+	}
+	return map[string]interface{}{"model": model, "call": n}, nil
+}
+
+// waitFor polls until the multiplexer has seen want coalesced requests on route.
+func waitFor(t *testing.T, stats *Stats, route string, want int64) {
+	require.Eventually(t, func() bool {
+		return stats.Snapshot()[route].Coalesced == want
+	}, time.Second, time.Millisecond)
+}
+
+func TestMultiplexer_CoalescesConcurrentRequests(t *testing.T) {
+	upstream := &blockingMultiplexer{release: make(chan struct{})}
+	stats := NewStats()
+	mux := NewMultiplexer(upstream, []string{RouteChatCompletions}, stats)
+	messages := []map[string]interface{}{{"role": "user", "content": "Hello"}}
+
+	const callers = 5
+	results := make([]interface{}, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := mux.ChatCompletion(t.Context(), "gpt-4", messages)
+			assert.NoError(t, err)
+			results[i] = result
+		}()
+	}
+	waitFor(t, stats, RouteChatCompletions, callers-1)
+	close(upstream.release)
+	wg.Wait()
+
+	assert.Equal(t, int32(1), upstream.calls.Load())
+	for _, result := range results {
+		assert.Equal(t, results[0], result)
+	}
+	assert.Equal(t, RouteStats{UpstreamCalls: 1, Coalesced: callers - 1}, stats.Snapshot()[RouteChatCompletions])
+
+	// Once the call has finished, the next identical request goes upstream again
+	_, err := mux.ChatCompletion(t.Context(), "gpt-4", messages)
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), upstream.calls.Load())
+}
+
+func TestMultiplexer_DistinctRequests(t *testing.T) {
+	upstream := &blockingMultiplexer{release: make(chan struct{})}
+	close(upstream.release)
+	mux := NewMultiplexer(upstream, []string{RouteChatCompletions}, NewStats())
+
+	// Routes that aren't enabled pass straight through
+	_, err := mux.Completion(t.Context(), "gpt-4", "Hello")
+	require.NoError(t, err)
+
+	// Requests differing in model or tenant are never merged, even while in flight
+	upstream.release = make(chan struct{})
+	var wg sync.WaitGroup
+	for _, call := range []struct{ model, tenant string }{{"gpt-4", "a"}, {"gpt-4", "b"}, {"gpt-3.5", "a"}} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := mux.ChatCompletion(usage.WithTenant(t.Context(), call.tenant), call.model, nil)
+			assert.NoError(t, err)
+		}()
+	}
+	require.Eventually(t, func() bool { return upstream.calls.Load() == 4 }, time.Second, time.Millisecond)
+	close(upstream.release)
+	wg.Wait()
+}
+
+func TestMultiplexer_SharesErrors(t *testing.T) {
+	upstream := &blockingMultiplexer{release: make(chan struct{}), err: errors.New("provider down")}
+	stats := NewStats()
+	mux := NewMultiplexer(upstream, []string{RouteCompletions}, stats)
+
+	errs := make(chan error, 2)
+	for range 2 {
+		go func() {
+			_, err := mux.Completion(t.Context(), "gpt-4", "Hello")
+			errs <- err
+		}()
+	}
+	waitFor(t, stats, RouteCompletions, 1)
+	close(upstream.release)
+
+	require.EqualError(t, <-errs, "provider down")
+	require.EqualError(t, <-errs, "provider down")
+	assert.Equal(t, int32(1), upstream.calls.Load())
+}
+
+func TestMultiplexer_CallerCancellation(t *testing.T) {
+	upstream := &blockingMultiplexer{release: make(chan struct{})}
+	stats := NewStats()
+	mux := NewMultiplexer(upstream, []string{RouteCompletions}, stats)
+
+	firstCtx, cancelFirst := context.WithCancel(t.Context())
+	first := make(chan error, 1)
+	go func() {
+		_, err := mux.Completion(firstCtx, "gpt-4", "Hello")
+		first <- err
+	}()
+	second := make(chan error, 1)
+	go func() {
+		_, err := mux.Completion(t.Context(), "gpt-4", "Hello")
+		second <- err
+	}()
+	waitFor(t, stats, RouteCompletions, 1)
+
+	// The caller that started the upstream call leaving must not fail the other one
+	cancelFirst()
+	require.ErrorIs(t, <-first, context.Canceled)
+	close(upstream.release)
+	require.NoError(t, <-second)
+	assert.Equal(t, int32(1), upstream.calls.Load())
+}
+
+func TestMultiplexer_CancelsAbandonedCall(t *testing.T) {
+	upstream := &blockingMultiplexer{release: make(chan struct{})}
+	mux := NewMultiplexer(upstream, []string{RouteCompletions}, NewStats())
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		_, err := mux.Completion(ctx, "gpt-4", "Hello")
+		done <- err
+	}()
+	require.Eventually(t, func() bool { return upstream.calls.Load() == 1 }, time.Second, time.Millisecond)
+	cancel()
+	require.ErrorIs(t, <-done, context.Canceled)
+
+	// A new identical request starts a fresh call rather than joining the cancelled one
+	close(upstream.release)
+	_, err := mux.Completion(t.Context(), "gpt-4", "Hello")
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), upstream.calls.Load())
+}
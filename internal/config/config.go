@@ -2,31 +2,333 @@
 package config
 
 import (
+	"fmt"
 	"os"
-
-	"github.com/pelletier/go-toml/v2"
+	"strings"
+	"time"
 )
 
 // Config represents the main configuration structure for modelplex.
 type Config struct {
-	Providers []Provider `toml:"providers"`
-	MCP       MCPConfig  `toml:"mcp"`
-	Server    Server     `toml:"server"`
+	// Version is the configuration format; see CurrentVersion and "modelplex config migrate"
+	Version   int         `toml:"version"`
+	Providers []Provider  `toml:"providers"`
+	MCP       MCPConfig   `toml:"mcp"`
+	Server    Server      `toml:"server"`
+	State     StateConfig `toml:"state"`
+	Limits    Limits      `toml:"limits"`
+	Cache     CacheConfig `toml:"cache"`
+	Usage     UsageConfig `toml:"usage"`
+	Admin     AdminConfig `toml:"admin"`
+	Chaos     ChaosConfig `toml:"chaos"`
+	// Pause decides what happens to provider calls while an operator has paused outbound traffic
+	Pause PauseConfig `toml:"pause"`
+	// Maintenance closes the model routes with a message for clients during planned maintenance
+	Maintenance MaintenanceConfig `toml:"maintenance"`
+	// Startup lists what must be up, in order, before the server reports ready on /ready
+	Startup StartupConfig `toml:"startup"`
+	// Idempotency controls replay of responses to requests carrying an Idempotency-Key header
+	Idempotency IdempotencyConfig `toml:"idempotency"`
+	// Coalesce merges identical concurrent non-streaming requests into one upstream call
+	Coalesce CoalesceConfig `toml:"coalesce"`
+	// Streams controls resumption of streaming responses after a client disconnects
+	Streams StreamsConfig `toml:"streams"`
+	// Judge scores a sample of responses with a judge model for quality tracking
+	Judge JudgeConfig `toml:"judge"`
+	// Injection flags likely prompt injection in tool-augmented requests, and can block it
+	Injection InjectionConfig `toml:"injection"`
+	// Loops refuses requests a client keeps repeating, a sign of an agent stuck in a loop
+	Loops LoopsConfig `toml:"loops"`
+	// Residency restricts which provider jurisdictions may process each tenant's requests
+	Residency ResidencyConfig `toml:"residency"`
+	// Privacy controls whether request and response content may be retained
+	Privacy PrivacyConfig `toml:"privacy"`
+	// Audit keeps a tamper-evident journal of requests for compliance
+	Audit AuditConfig `toml:"audit"`
+	// Conversations signs the exports that move a conversation's stored state to another instance
+	Conversations ConversationsConfig `toml:"conversations"`
+	// Parameters decides what happens to request parameters a provider can't honor
+	Parameters ParametersConfig `toml:"parameters"`
+	// Routing holds ordered rules that rewrite a request's model, provider or parameters
+	Routing RoutingConfig `toml:"routing"`
+	// Events notifies an operator webhook of changes such as a standby provider being promoted
+	Events EventsConfig `toml:"events"`
+	// Reasoning decides how reasoning models' intermediate output is returned
+	Reasoning ReasoningConfig `toml:"reasoning"`
+	// Provenance stamps responses with the model, provider and request that produced them
+	Provenance ProvenanceConfig `toml:"provenance"`
+	// ToolCalls decides which tenants get streamed tool calls whole instead of in fragments
+	ToolCalls ToolCallsConfig `toml:"tool_calls"`
+	// Updates checks for newer releases of modelplex
+	Updates UpdatesConfig `toml:"updates"`
+	// Catalog fills in prices and token limits of well-known models left unset
+	Catalog CatalogConfig `toml:"catalog"`
+	// Tags turns allowed request metadata keys into metric labels and usage event fields
+	Tags TagsConfig `toml:"tags"`
+	// OpenAPI configures the browsable documentation of the API document served at /openapi.json
+	OpenAPI OpenAPIConfig `toml:"openapi"`
 }
 
 // Provider represents configuration for an AI provider.
 type Provider struct {
-	Name     string   `toml:"name"`
-	Type     string   `toml:"type"`
-	BaseURL  string   `toml:"base_url"`
-	APIKey   string   `toml:"api_key"`
-	Models   []string `toml:"models"`
-	Priority int      `toml:"priority"`
+	Name    string   `toml:"name"`
+	Type    string   `toml:"type"`
+	BaseURL string   `toml:"base_url"`
+	APIKey  string   `toml:"api_key"`
+	Models  []string `toml:"models"`
+	// Priority orders the providers of a model without a routing.models route: the first primary,
+	// lowest priority first, serves all its requests.
+	//
+	// Deprecated: a route in routing.models can also split traffic by weight and fail over.
+	Priority int `toml:"priority"`
+	// Jurisdiction tags where the provider processes data (e.g. "eu") for data residency routing
+	Jurisdiction string `toml:"jurisdiction"`
+	// Auth replaces the static API key with short-lived tokens when set
+	Auth ProviderAuth `toml:"auth"`
+	// Regions lists alternative endpoints; when set they replace BaseURL and enable regional failover
+	Regions []ProviderRegion `toml:"regions"`
+	// ExtraHeaders are sent on every request to the provider, overriding built-in headers of the same name
+	ExtraHeaders map[string]string `toml:"extra_headers"`
+	// ExtraQuery parameters are added to every request URL (e.g. api-version for Azure gateways)
+	ExtraQuery map[string]string `toml:"extra_query"`
+	// CaptureHeaders are the response headers whose last values are kept, e.g. request ids and rate
+	// limit state; unset captures DefaultCaptureHeaders, and an empty list captures none
+	CaptureHeaders []string `toml:"capture_headers"`
+	// Faults injects synthetic failures for resilience testing while chaos mode is on
+	Faults ProviderFaults `toml:"faults"`
+	// Scheduling shares the provider's capacity fairly between tenants, e.g. a local GPU backend
+	Scheduling ProviderScheduling `toml:"scheduling"`
+	// Throttle caps the rate tenants receive output tokens at, e.g. to hold batch agents back
+	Throttle ProviderThrottle `toml:"throttle"`
+	// Telemetry polls an ollama backend for the models it has loaded and the GPU memory they take
+	Telemetry ProviderTelemetry `toml:"telemetry"`
+	// KeepAlive unloads an ollama backend's models once requests have stopped using them
+	KeepAlive ProviderKeepAlive `toml:"keep_alive"`
+	// Speculative maps models to the draft model that speeds up their decoding on the backend
+	Speculative map[string]SpeculativeDecoding `toml:"speculative"`
+	// Standby keeps the provider out of rotation until every primary serving a model is unhealthy
+	Standby bool `toml:"standby"`
+	// Streaming marks a provider that supports only one transport, StreamingUnsupported or
+	// StreamingRequired; the other is adapted. Empty means both are supported.
+	Streaming string `toml:"streaming"`
+	// Anthropic sets the Messages API version and beta features of an anthropic provider
+	Anthropic ProviderAnthropic `toml:"anthropic"`
+	// Azure sets the API version of an azure-openai provider
+	Azure ProviderAzure `toml:"azure"`
+	// Bedrock sets the AWS region and credentials of a bedrock provider
+	Bedrock ProviderBedrock `toml:"bedrock"`
+	// Compatible sets the paths of an openai-compatible provider
+	Compatible ProviderCompatible `toml:"compatible"`
+	// OpenRouter sets the app attribution an openrouter provider sends
+	OpenRouter ProviderOpenRouter `toml:"openrouter"`
+	// TGI sets the chat template a tgi provider renders prompts with
+	TGI ProviderTGI `toml:"tgi"`
+	// ModelMap maps public model names from Models to the names the backend serves them under,
+	// e.g. "gpt-4o-mini" to "llama3.1:8b-instruct"; responses report the public name
+	ModelMap map[string]string `toml:"model_map"`
+	// ModelLimits maps model names, as sent to the backend, to their token limits, used to default
+	// max_tokens where a provider requires it
+	ModelLimits map[string]ModelLimits `toml:"model_limits"`
+}
+
+// ModelLimits represents the token limits of a model; zero means unknown.
+type ModelLimits struct {
+	// ContextWindow is the most tokens the prompt and completion may take together
+	ContextWindow int64 `toml:"context_window"`
+	// MaxOutputTokens is the most tokens the model generates in one completion
+	MaxOutputTokens int64 `toml:"max_output_tokens"`
+}
+
+// ProviderAnthropic represents the API version and beta features requested from Anthropic.
+type ProviderAnthropic struct {
+	// Version is sent as the anthropic-version header
+	Version string `toml:"version"`
+	// Betas are sent in the anthropic-beta header, each a name from AnthropicBetas or a raw
+	// header value such as "context-1m-2025-08-07" for betas released since
+	Betas []string `toml:"betas"`
+}
+
+// ProviderAzure represents the Azure OpenAI API version requested.
+type ProviderAzure struct {
+	// APIVersion is sent as the api-version query parameter, e.g. "2024-10-21" or "2025-04-01-preview"
+	APIVersion string `toml:"api_version"`
+}
+
+// ProviderBedrock represents the AWS region and credentials requests to Bedrock are signed for.
+// Credentials are the static keys when set, else those of the named profile, else the ones the
+// environment provides: AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY, a web identity such as an EKS
+// service account's (IRSA), or the default profile.
+type ProviderBedrock struct {
+	// Region is the AWS region, e.g. "us-east-1"; base_url defaults to its Bedrock runtime endpoint
+	Region          string `toml:"region"`
+	AccessKeyID     string `toml:"access_key_id"`
+	SecretAccessKey string `toml:"secret_access_key"`
+	SessionToken    string `toml:"session_token"`
+	// Profile names a profile of the shared credentials file, ~/.aws/credentials by default
+	Profile string `toml:"profile"`
+}
+
+// ProviderCompatible represents where an openai-compatible provider, such as vLLM, LiteLLM, Together
+// or Fireworks, serves OpenAI's API. Headers it requires go in extra_headers.
+type ProviderCompatible struct {
+	// PathPrefix goes between the base URL and the API paths, e.g. "/openai/v1" for /openai/v1/chat/completions
+	PathPrefix string `toml:"path_prefix"`
+	// ModelsEndpoint lists the served models when models is unset, a path below the base URL or a full
+	// URL; it defaults to /models below the path prefix
+	ModelsEndpoint string `toml:"models_endpoint"`
+}
+
+// ProviderOpenRouter represents the app OpenRouter attributes requests to, in its rankings and
+// analytics.
+type ProviderOpenRouter struct {
+	// Referer is the app's URL, sent as the HTTP-Referer header
+	Referer string `toml:"referer"`
+	// Title is the app's name, sent as the X-Title header
+	Title string `toml:"title"`
+}
+
+// ProviderTGI represents how chat requests are served by a Text Generation Inference server, whose
+// /generate route takes a prompt rather than messages.
+type ProviderTGI struct {
+	// Template is one of TGITemplates, the prompt format of the served model's family
+	Template string `toml:"template"`
+}
+
+// AnthropicBetas maps the beta feature names accepted in anthropic.betas to their header values.
+var AnthropicBetas = map[string]string{
+	"prompt_caching":        "prompt-caching-2024-07-31",
+	"context_1m":            "context-1m-2025-08-07",
+	"output_128k":           "output-128k-2025-02-19",
+	"token_efficient_tools": "token-efficient-tools-2025-02-19",
+	"interleaved_thinking":  "interleaved-thinking-2025-05-14",
+	"files_api":             "files-api-2025-04-14",
+}
+
+// ProviderScheduling represents fair scheduling of a provider's generations between tenants.
+// When every slot is busy, a freed slot goes to the waiting tenant that has decoded the fewest
+// tokens relative to its weight, instead of to whichever request arrived first.
+type ProviderScheduling struct {
+	// MaxConcurrent is the number of generations the backend runs at once; 0 disables scheduling
+	MaxConcurrent int64 `toml:"max_concurrent"`
+	// Weights give tenants a larger share of the provider; tenants not listed weigh 1
+	Weights map[string]float64 `toml:"weights"`
+}
+
+// ProviderThrottle represents output token rate ceilings on a provider. Each tenant's requests share
+// one ceiling, so a batch agent running many generations at once is held to it as a whole.
+type ProviderThrottle struct {
+	// TokensPerSecond is the ceiling of tenants not listed in Tenants; 0 leaves them unthrottled
+	TokensPerSecond float64 `toml:"tokens_per_second"`
+	// Tenants override TokensPerSecond for the listed tenants; 0 leaves a tenant unthrottled
+	Tenants map[string]float64 `toml:"tenants"`
+}
+
+// ProviderTelemetry represents polling a local backend for its loaded models and GPU memory, shown
+// in the internal status and metrics. Ollama doesn't report how much GPU memory it has, so spilling
+// over to other providers when it runs short needs it configured.
+type ProviderTelemetry struct {
+	// IntervalSeconds is the time between polls; 0 disables telemetry
+	IntervalSeconds int64 `toml:"interval_seconds"`
+	// VRAMMB is the GPU memory of the backend in MiB; 0 leaves it unknown
+	VRAMMB int64 `toml:"vram_mb"`
+	// SpillBelowMB sends requests for models the backend hasn't loaded to another provider of the
+	// model while less GPU memory than this is free, or a loaded model was partly offloaded to
+	// system memory; 0 never spills
+	SpillBelowMB int64 `toml:"spill_below_mb"`
+}
+
+// ProviderKeepAlive represents unloading a local backend's models once they have gone unused, so
+// modelplex rather than each client decides how long models hold memory. A request may still name
+// its own keep_alive, which is passed on to the backend.
+type ProviderKeepAlive struct {
+	// IdleSeconds is how long a model goes unused before it is unloaded; 0 leaves it to the backend
+	IdleSeconds int64 `toml:"idle_seconds"`
+}
+
+// SpeculativeDecoding represents an experimental pairing of a model with a small draft model on the
+// same backend: the draft proposes tokens and the model verifies them, several in one pass, which
+// speeds up decoding without changing the output. It uses the backend's own speculative API: an
+// openai provider sends the draft as draft_model, as LM Studio takes it, and a llamacpp provider
+// tunes drafting by the model llama-server was started with (--model-draft).
+type SpeculativeDecoding struct {
+	// Draft is the backend's name for the draft model; llamacpp providers ignore it
+	Draft string `toml:"draft"`
+	// MaxDraftTokens and MinDraftTokens bound the tokens drafted at a time on llamacpp; 0 leaves
+	// them to the backend
+	MaxDraftTokens int64 `toml:"max_draft_tokens"`
+	MinDraftTokens int64 `toml:"min_draft_tokens"`
+	// MinProbability is how likely the draft model must find a token to keep drafting, on llamacpp;
+	// 0 leaves it to the backend
+	MinProbability float64 `toml:"min_probability"`
+}
+
+// ProviderFaults represents synthetic failures injected into requests to a provider.
+// Rates are probabilities between 0 and 1, evaluated independently for every upstream request.
+type ProviderFaults struct {
+	LatencyRate float64 `toml:"latency_rate"`
+	LatencyMS   int64   `toml:"latency_ms"`
+	// RateLimitRate answers with a synthetic 429 instead of calling the provider
+	RateLimitRate float64 `toml:"rate_limit_rate"`
+	// ErrorRate answers with a synthetic 500 instead of calling the provider
+	ErrorRate float64 `toml:"error_rate"`
+	// DisconnectRate cuts the response body off after its first read, e.g. mid-stream
+	DisconnectRate float64 `toml:"disconnect_rate"`
+}
+
+// ProviderRegion represents one regional endpoint of a provider.
+type ProviderRegion struct {
+	Name    string `toml:"name"`
+	BaseURL string `toml:"base_url"`
+}
+
+// ProviderAuth represents token-based authentication for a provider.
+// Type is "oauth2" for a generic client-credentials grant or "azure_ad" for Azure AD;
+// an empty Type means the static api_key is used.
+type ProviderAuth struct {
+	Type         string   `toml:"type"`
+	TokenURL     string   `toml:"token_url"`
+	TenantID     string   `toml:"tenant_id"`
+	ClientID     string   `toml:"client_id"`
+	ClientSecret string   `toml:"client_secret"`
+	Scopes       []string `toml:"scopes"`
 }
 
 // MCPConfig represents MCP (Model Context Protocol) configuration.
 type MCPConfig struct {
 	Servers []MCPServer `toml:"servers"`
+	// Capabilities requires tool calls to present a token scoping the tools they may call
+	Capabilities MCPCapabilities `toml:"capabilities"`
+	// Approvals parks sensitive tool calls until an operator approves or denies them
+	Approvals MCPApprovals `toml:"approvals"`
+}
+
+// MCPCapabilities represents the capability tokens tool calls present. Tokens are minted per
+// conversation through /_internal/capabilities and list the permitted tools and argument patterns.
+type MCPCapabilities struct {
+	// Required refuses tool calls without a valid token
+	Required bool `toml:"required"`
+	// Secret signs the tokens; empty uses a random one, so tokens don't survive a restart or work
+	// across instances
+	Secret string `toml:"secret"`
+	// TTLSeconds is the longest a token is valid
+	TTLSeconds int64 `toml:"ttl_seconds"`
+}
+
+// MCPApprovals holds the tool calls that match one of its rules until an operator decides on them
+// through the admin API.
+type MCPApprovals struct {
+	Rules []ApprovalRule `toml:"rules"`
+	// TimeoutSeconds is how long a call waits for a decision before it is denied
+	TimeoutSeconds int64 `toml:"timeout_seconds"`
+}
+
+// ApprovalRule selects tool calls needing approval. Patterns are regular expressions matching whole
+// values, non-string arguments in their JSON encoding.
+type ApprovalRule struct {
+	// Tool matches the names of the tools the rule applies to
+	Tool string `toml:"tool"`
+	// Arguments narrows the rule to calls whose arguments match these patterns, by argument name
+	Arguments map[string]string `toml:"arguments"`
 }
 
 // MCPServer represents configuration for a single MCP server.
@@ -40,6 +342,447 @@ type MCPServer struct {
 type Server struct {
 	LogLevel       string `toml:"log_level"`
 	MaxRequestSize int64  `toml:"max_request_size"`
+	// ReadOnly rejects admin mutations and MCP tool calls while still serving completions
+	ReadOnly bool `toml:"read_only"`
+	// SocketMode is the octal file mode of the Unix socket, e.g. "0660"; empty keeps the umask's
+	SocketMode string `toml:"socket_mode"`
+	// SocketOwner and SocketGroup own the Unix socket, by name or numeric id; empty keeps the process's
+	SocketOwner string `toml:"socket_owner"`
+	SocketGroup string `toml:"socket_group"`
+	// StrictOpenAI rewrites every response into exactly the OpenAI schema, filling in required fields,
+	// dropping extensions and converting Anthropic and Ollama responses
+	StrictOpenAI bool `toml:"strict_openai"`
+}
+
+// StateConfig selects where shared counters are kept.
+// Backend is "memory" (default, per instance) or "redis" (shared across instances).
+type StateConfig struct {
+	Backend   string `toml:"backend"`
+	RedisURL  string `toml:"redis_url"`
+	KeyPrefix string `toml:"key_prefix"`
+}
+
+// Limits represents request limits enforced through the state backend.
+type Limits struct {
+	// RequestsPerMinute caps API requests across all clients; zero disables the limit
+	RequestsPerMinute int64 `toml:"requests_per_minute"`
+	// Conversation caps each conversation, the requests sharing a conversation_id metadata value
+	Conversation ConversationLimits `toml:"conversation"`
+}
+
+// ConversationLimits caps the turns and tokens of a conversation, so an agent loop that never
+// terminates is cut off. Requests without a conversation id aren't limited.
+type ConversationLimits struct {
+	// MaxTurns caps the requests of a conversation; zero disables the limit
+	MaxTurns int64 `toml:"max_turns"`
+	// MaxTokens caps the total tokens, prompt and completion, of a conversation's responses; zero disables the limit
+	MaxTokens int64 `toml:"max_tokens"`
+	// TTLSeconds is how long a conversation's counts are kept from its first request
+	TTLSeconds int64 `toml:"ttl_seconds"`
+}
+
+// CacheConfig represents response caching for completions; streamed responses are replayed as streams.
+// Cached responses live in the state backend, so a Redis backend shares them across instances.
+type CacheConfig struct {
+	Enabled    bool  `toml:"enabled"`
+	TTLSeconds int64 `toml:"ttl_seconds"`
+}
+
+// UsageConfig represents periodic export of usage events to a metering endpoint.
+type UsageConfig struct {
+	// Endpoint receives CloudEvents batches (e.g. OpenMeter); empty disables export
+	Endpoint        string `toml:"endpoint"`
+	APIKey          string `toml:"api_key"`
+	IntervalSeconds int64  `toml:"interval_seconds"`
+	// TenantHeader names the request header that identifies the tenant to bill
+	TenantHeader string `toml:"tenant_header"`
+	// Prices maps model names to their per-million-token prices for cost calculation
+	Prices map[string]ModelPrice `toml:"prices"`
+}
+
+// ModelPrice represents the price of a model in currency units per million tokens.
+type ModelPrice struct {
+	Input  float64 `toml:"input"`
+	Output float64 `toml:"output"`
+}
+
+// IdempotencyConfig represents how long responses are kept for Idempotency-Key replays.
+type IdempotencyConfig struct {
+	WindowSeconds int64 `toml:"window_seconds"`
+}
+
+// CoalesceConfig represents merging of identical concurrent non-streaming requests.
+type CoalesceConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Routes limits coalescing to these API routes, see CoalesceRoutes; empty means all of them
+	Routes []string `toml:"routes"`
+}
+
+// StreamsConfig represents resumable streaming responses and the recovery of failed ones.
+// While Resumable, streamed deltas are buffered and the generation keeps running when the client
+// disconnects, so the client can reconnect with its resume token and continue.
+type StreamsConfig struct {
+	Resumable bool `toml:"resumable"`
+	// RetentionSeconds is how long a stream waits for a reconnect once its client is gone or it has finished
+	RetentionSeconds int64 `toml:"retention_seconds"`
+	// RestartAttempts is how often a stream that fails before its first token is started again, on the
+	// same or the next provider; 0 leaves such failures to the client
+	RestartAttempts int64 `toml:"restart_attempts"`
+	// SalvageAttempts is how often a stream that fails mid-output is continued by a fallback provider
+	// from the partial output; 0 leaves such failures to the client. Salvageable streams are sent in
+	// OpenAI's chunk shape whatever the provider.
+	SalvageAttempts int64 `toml:"salvage_attempts"`
+}
+
+// JudgeConfig represents background scoring of non-streaming responses by a judge model.
+// Scores are logged and summarized in the internal metrics; client responses are never changed.
+type JudgeConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Model is the judge, served by the configured providers like any other model
+	Model    string `toml:"model"`
+	Criteria string `toml:"criteria"`
+	// SampleRate is the fraction of responses scored, between 0 and 1
+	SampleRate float64 `toml:"sample_rate"`
+}
+
+// InjectionConfig represents detection of prompt injection and jailbreak attempts in tool-augmented
+// chat requests, those offering tools or carrying tool results. User messages and tool results are
+// scored from 0 to 1 by heuristic rules and, optionally, a classifier model; the risk is logged,
+// counted in the internal metrics and kept in audit records.
+type InjectionConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Model classifies the scanned text in addition to the rules; empty uses the rules only
+	Model string `toml:"model"`
+	// FlagThreshold is the risk from which a request is logged as suspicious
+	FlagThreshold float64 `toml:"flag_threshold"`
+	// BlockThreshold is the risk from which a request is refused; 0 never refuses one
+	BlockThreshold float64 `toml:"block_threshold"`
+	// Rules add patterns to the built-in ones
+	Rules []InjectionRule `toml:"rules"`
+}
+
+// LoopsConfig represents the detection of agent loops: a client, its tenant and conversation, sending
+// nearly identical requests over and over. Requests are compared by their last exchange, the last
+// assistant message and what follows it, or by their prompt.
+type LoopsConfig struct {
+	Enabled bool `toml:"enabled"`
+	// Threshold is how many nearly identical requests a client may send within the window; the next are refused
+	Threshold int64 `toml:"threshold"`
+	// WindowSeconds is how far back requests are compared
+	WindowSeconds int64 `toml:"window_seconds"`
+	// Similarity is the least similarity, above 0 and at most 1, of nearly identical requests; 1 matches
+	// identical ones only
+	Similarity float64 `toml:"similarity"`
+}
+
+// InjectionRule represents a pattern that raises the injection risk of text it matches.
+type InjectionRule struct {
+	Name string `toml:"name"`
+	// Pattern is a regular expression, matched case-insensitively
+	Pattern string `toml:"pattern"`
+	// Score is the risk of a match, above 0 and at most 1
+	Score float64 `toml:"score"`
+}
+
+// ResidencyConfig represents data residency requirements, matched against provider jurisdictions.
+// Requests are refused rather than routed to a provider outside the allowed jurisdictions.
+type ResidencyConfig struct {
+	// Tenants maps tenants, as identified by usage.tenant_header, to their allowed jurisdictions
+	Tenants map[string][]string `toml:"tenants"`
+	// Default applies to tenants without an entry; empty leaves them unrestricted
+	Default []string `toml:"default"`
+}
+
+// Jurisdictions returns the jurisdictions tenant's requests may be processed in; empty means any.
+func (r *ResidencyConfig) Jurisdictions(tenant string) []string {
+	if jurisdictions, ok := r.Tenants[tenant]; ok {
+		return jurisdictions
+	}
+	return r.Default
+}
+
+// PrivacyConfig represents retention of request and response content.
+// In Strict mode nothing stores content: features that would are rejected by validation,
+// Idempotency-Key replays are off, and the state backend refuses to store values.
+type PrivacyConfig struct {
+	Strict bool `toml:"strict"`
+}
+
+// AuditConfig represents a tamper-evident journal of API requests, kept for compliance: records are
+// hash-chained and only ever appended, and "modelplex audit verify" checks that none was altered.
+type AuditConfig struct {
+	// Path is the file records are appended to; empty disables the journal
+	Path string `toml:"path"`
+	// Transcripts adds the messages and response of each request to its record
+	Transcripts bool `toml:"transcripts"`
+}
+
+// ConversationsConfig represents the export of a conversation's stored state, its history and budget
+// counts, and its import on another instance, e.g. when an agent's sandbox migrates.
+type ConversationsConfig struct {
+	// Secret signs exports with HMAC-SHA256 and verifies imports, so every instance exchanging
+	// exports shares it; empty disables export and import
+	Secret string `toml:"secret"`
+}
+
+// ParametersConfig represents the handling of request parameters a provider can't honor,
+// such as logit_bias sent to Anthropic. Each parameter follows one of ParameterPolicies.
+type ParametersConfig struct {
+	// Unsupported is the policy for parameters without an entry in Policies
+	Unsupported string `toml:"unsupported"`
+	// Policies maps parameter names to the policy applied to them
+	Policies map[string]string `toml:"policies"`
+}
+
+// Policy returns the policy for the parameter name.
+func (p *ParametersConfig) Policy(name string) string {
+	if policy, ok := p.Policies[name]; ok {
+		return policy
+	}
+	return p.Unsupported
+}
+
+// EventsConfig represents where operational events are delivered.
+type EventsConfig struct {
+	// Webhook receives each event as a CloudEvent; empty only logs them
+	Webhook string `toml:"webhook"`
+}
+
+// CatalogConfig represents the use of the built-in catalog of well-known models.
+type CatalogConfig struct {
+	// Disabled leaves prices and token limits to usage.prices and model_limits alone
+	Disabled bool `toml:"disabled"`
+}
+
+// TagsConfig represents the request metadata keys, such as team or experiment, reported as tags.
+// Tags appear as labels on the internal metrics, which keep a bounded number of values per tag.
+type TagsConfig struct {
+	// Allowed lists the metadata keys that are tags; empty disables tagging
+	Allowed []string `toml:"allowed"`
+	// MaxPerRequest rejects requests carrying more tags than this
+	MaxPerRequest int64 `toml:"max_per_request"`
+	// MaxValues is how many distinct values of a tag the metrics keep; later ones are counted as TagOverflow
+	MaxValues int64 `toml:"max_values"`
+}
+
+// OpenAPIConfig represents the documentation served alongside the OpenAPI document.
+type OpenAPIConfig struct {
+	// SwaggerUI serves a Swagger UI page at /docs, which loads Swagger UI from a CDN
+	SwaggerUI bool `toml:"swagger_ui"`
+}
+
+// UpdatesConfig represents the check for newer releases, which only ever logs.
+type UpdatesConfig struct {
+	// Check enables the check; it is off so nothing is sent anywhere unless asked for
+	Check         bool  `toml:"check"`
+	IntervalHours int64 `toml:"interval_hours"`
+	// URL returns the latest release as a GitHub release object
+	URL string `toml:"url"`
+}
+
+// ReasoningConfig represents the handling of reasoning models' intermediate output, which
+// providers return as OpenAI reasoning_content, Anthropic thinking blocks or Ollama thinking.
+type ReasoningConfig struct {
+	// Mode is one of ReasoningModes
+	Mode string `toml:"mode"`
+}
+
+// ProvenanceConfig represents the provenance stamped on responses, so downstream systems can
+// attribute generated content to the model, provider and request it came from through modelplex.
+type ProvenanceConfig struct {
+	// Mode is one of ProvenanceModes; empty stamps nothing
+	Mode string `toml:"mode"`
+	// Secret signs the provenance with HMAC-SHA256 when set, so holders of the secret can verify it
+	Secret string `toml:"secret"`
+}
+
+// ToolCallsConfig represents how streamed tool calls reach clients. Assembled tool calls are
+// buffered until complete and sent in one event, for clients that can't parse partial arguments.
+type ToolCallsConfig struct {
+	// Assemble applies to tenants without an entry in Tenants
+	Assemble bool `toml:"assemble"`
+	// Tenants maps tenants, as identified by usage.tenant_header, to whether their tool calls are assembled
+	Tenants map[string]bool `toml:"tenants"`
+}
+
+// AssembleFor reports whether streamed tool calls are assembled for tenant.
+func (t *ToolCallsConfig) AssembleFor(tenant string) bool {
+	if assemble, ok := t.Tenants[tenant]; ok {
+		return assemble
+	}
+	return t.Assemble
+}
+
+// RoutingConfig represents ordered routing rules. The first rule whose conditions all match
+// a request decides its route; requests no rule matches are routed by model as usual.
+type RoutingConfig struct {
+	// Timezone is the IANA zone that hours and days are read in, e.g. "Europe/Berlin"; empty means local time
+	Timezone string        `toml:"timezone"`
+	Rules    []RoutingRule `toml:"rules"`
+	// Models maps model names to the routes their requests take, in place of provider priorities
+	Models map[string]ModelRoute `toml:"models"`
+}
+
+// ModelRoute represents how a model's requests are spread over providers and where they go when one fails.
+type ModelRoute struct {
+	// Targets share the model's requests in proportion to their weights
+	Targets []RouteTarget `toml:"targets"`
+	// Failover lists the providers tried in order after the chosen target fails; empty means no failover
+	Failover []string `toml:"failover"`
+	// RequireHealthy skips providers that failed in the last 30 seconds while any other is healthy
+	RequireHealthy bool `toml:"require_healthy"`
+}
+
+// RouteTarget represents a provider's share of a model's requests.
+type RouteTarget struct {
+	Provider string `toml:"provider"`
+	Weight   int64  `toml:"weight"`
+}
+
+// RoutingRule represents one routing rule and the route it chooses.
+type RoutingRule struct {
+	Name  string       `toml:"name"`
+	Match RoutingMatch `toml:"match"`
+	// Model replaces the requested model
+	Model string `toml:"model"`
+	// Provider pins the request to the named provider instead of the one serving the model
+	Provider string `toml:"provider"`
+	// Params override request parameters such as temperature
+	Params map[string]interface{} `toml:"params"`
+}
+
+// RoutingMatch represents the conditions of a routing rule; unset conditions match every request.
+type RoutingMatch struct {
+	Models []string `toml:"models"`
+	// Tenants as identified by usage.tenant_header
+	Tenants []string `toml:"tenants"`
+	// Metadata entries must all be present with these values in the request's metadata
+	Metadata map[string]string `toml:"metadata"`
+	// MinPromptTokens and MaxPromptTokens bound the estimated prompt size; 0 leaves a bound open
+	MinPromptTokens int64 `toml:"min_prompt_tokens"`
+	MaxPromptTokens int64 `toml:"max_prompt_tokens"`
+	// Hours is a time window such as "09:00-17:00"; windows may wrap past midnight
+	Hours string `toml:"hours"`
+	// Days limits the rule to weekdays such as "mon" and "sat"
+	Days []string `toml:"days"`
+	// MinInFlight and MaxInFlight bound the requests already in flight to the requested model,
+	// e.g. to move traffic elsewhere once it queues up; 0 leaves a bound open
+	MinInFlight int64 `toml:"min_in_flight"`
+	MaxInFlight int64 `toml:"max_in_flight"`
+}
+
+// HoursWindow returns the bounds of Hours as minutes after midnight. A window ending before it
+// starts wraps past midnight; ok is false when Hours is unset.
+func (m *RoutingMatch) HoursWindow() (start, end int, ok bool, err error) {
+	if m.Hours == "" {
+		return 0, 0, false, nil
+	}
+	from, to, found := strings.Cut(m.Hours, "-")
+	if !found {
+		return 0, 0, false, fmt.Errorf("expected a window like 09:00-17:00, got %q", m.Hours)
+	}
+	if start, err = minuteOfDay(strings.TrimSpace(from)); err != nil {
+		return 0, 0, false, err
+	}
+	if end, err = minuteOfDay(strings.TrimSpace(to)); err != nil {
+		return 0, 0, false, err
+	}
+	return start, end, true, nil
+}
+
+func minuteOfDay(clock string) (int, error) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", clock)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ChaosConfig represents fault injection for resilience testing.
+// Faults are configured per provider and only injected while Enabled, which admins can also toggle at runtime.
+type ChaosConfig struct {
+	Enabled bool `toml:"enabled"`
+}
+
+// PauseConfig represents the handling of provider calls while outbound traffic is paused, which
+// admins toggle at runtime for incident response. Inbound requests are accepted either way.
+type PauseConfig struct {
+	// InFlight is one of PauseInFlightPolicies: what becomes of the calls in flight when traffic is paused
+	InFlight string `toml:"in_flight"`
+	// Inbound is one of PauseInboundPolicies: what becomes of the calls made while traffic is paused
+	Inbound string `toml:"inbound"`
+	// QueueTimeoutSeconds is how long queued calls wait for traffic to resume before they are refused
+	QueueTimeoutSeconds int64 `toml:"queue_timeout_seconds"`
+}
+
+// MaintenanceConfig represents maintenance mode, in which the model routes answer 503 with a message
+// for clients while health and admin endpoints stay up, e.g. during a planned provider migration.
+// Admins toggle it at runtime.
+type MaintenanceConfig struct {
+	// Enabled starts the server in maintenance mode
+	Enabled bool `toml:"enabled"`
+	// Message tells clients why requests are refused and when to expect service back
+	Message string `toml:"message"`
+	// RetryAfterSeconds is sent as Retry-After with the refusals when set
+	RetryAfterSeconds int64 `toml:"retry_after_seconds"`
+}
+
+// StartupConfig represents the dependencies the server waits for after it starts listening, such as
+// a local Ollama coming up or MCP servers initializing. They are waited for one after another, in
+// order; /ready answers 503 with the state of each until all are up, and names the one that failed
+// and why if one doesn't come up in time. /health answers meanwhile, so the process isn't restarted.
+type StartupConfig struct {
+	Dependencies []StartupDependency `toml:"dependencies"`
+}
+
+// StartupDependency is one dependency waited for at startup. Exactly one of Provider, MCPServer and
+// URL is set.
+type StartupDependency struct {
+	// Name identifies the dependency in readiness reports; empty names it after what it waits for
+	Name string `toml:"name"`
+	// Provider waits for the base URL of the provider of this name to answer without a server error,
+	// e.g. an Ollama server to be running
+	Provider string `toml:"provider"`
+	// MCPServer waits for the MCP server of this name to complete the initialize handshake
+	MCPServer string `toml:"mcp_server"`
+	// URL waits for a GET of it to answer with a 2xx status, such as a backend's health endpoint
+	URL string `toml:"url"`
+	// TimeoutSeconds is how long the dependency is waited for before readiness fails
+	TimeoutSeconds int64 `toml:"timeout_seconds"`
+}
+
+// Describe names what the dependency waits for, e.g. "provider ollama" or "mcp filesystem".
+func (d *StartupDependency) Describe() string {
+	switch {
+	case d.Provider != "":
+		return "provider " + d.Provider
+	case d.MCPServer != "":
+		return "mcp " + d.MCPServer
+	}
+	return d.URL
+}
+
+// AdminConfig protects the /_internal endpoints.
+// With neither tokens nor OIDC configured the endpoints stay open, as before.
+type AdminConfig struct {
+	Tokens []AdminToken `toml:"tokens"`
+	OIDC   OIDCConfig   `toml:"oidc"`
+}
+
+// AdminToken represents a static bearer token and the role it grants ("viewer" or "operator").
+type AdminToken struct {
+	Token string `toml:"token"`
+	Role  string `toml:"role"`
+}
+
+// OIDCConfig represents verification of OIDC-issued JWT bearer tokens.
+// Roles are granted when RoleClaim contains one of the listed values.
+type OIDCConfig struct {
+	Issuer         string   `toml:"issuer"`
+	Audience       string   `toml:"audience"`
+	RoleClaim      string   `toml:"role_claim"`
+	ViewerValues   []string `toml:"viewer_values"`
+	OperatorValues []string `toml:"operator_values"`
 }
 
 // Load reads and parses a TOML configuration file.
@@ -49,10 +792,5 @@ func Load(path string) (*Config, error) {
 		return nil, err
 	}
 
-	var cfg Config
-	if err := toml.Unmarshal(data, &cfg); err != nil {
-		return nil, err
-	}
-
-	return &cfg, nil
+	return Parse(data)
 }
@@ -67,6 +67,31 @@ log_level = "debug"
 				assert.Empty(t, cfg.MCP.Servers)
 			},
 		},
+		{
+			name: "provider with regions",
+			configData: `
+[[providers]]
+name = "azure"
+type = "openai"
+models = ["gpt-4"]
+
+[[providers.regions]]
+name = "eastus"
+base_url = "https://eastus.example.com/v1"
+
+[[providers.regions]]
+name = "westeurope"
+base_url = "https://westeurope.example.com/v1"
+`,
+			wantErr: false,
+			validate: func(t *testing.T, cfg *Config) {
+				require.Len(t, cfg.Providers, 1)
+				assert.Equal(t, []ProviderRegion{
+					{Name: "eastus", BaseURL: "https://eastus.example.com/v1"},
+					{Name: "westeurope", BaseURL: "https://westeurope.example.com/v1"},
+				}, cfg.Providers[0].Regions)
+			},
+		},
 		{
 			name:       "invalid toml",
 			configData: `invalid toml content [[[`,
@@ -0,0 +1,297 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+const (
+	// Redacted replaces secret values in printed configuration
+	Redacted = "REDACTED"
+
+	// DefaultLogLevel is used when server.log_level is unset
+	DefaultLogLevel = "info"
+	// DefaultMaxRequestSize bounds API request bodies when server.max_request_size is unset
+	DefaultMaxRequestSize = 10 << 20
+	// DefaultStateBackend keeps shared state in process memory
+	DefaultStateBackend = "memory"
+	// DefaultStateKeyPrefix namespaces state keys so a Redis instance can be shared
+	DefaultStateKeyPrefix = "modelplex:"
+	// DefaultCacheTTLSeconds is how long cached responses live when cache.ttl_seconds is unset
+	DefaultCacheTTLSeconds = 600
+	// DefaultUsageIntervalSeconds is how often usage events are exported when usage.interval_seconds is unset
+	DefaultUsageIntervalSeconds = 60
+	// DefaultIdempotencyWindowSeconds is how long Idempotency-Key responses are kept when unset
+	DefaultIdempotencyWindowSeconds = 3600
+	// DefaultConversationTTLSeconds is how long conversation turn and token counts are kept when unset
+	DefaultConversationTTLSeconds = 24 * 60 * 60
+	// DefaultStreamRetentionSeconds is how long resumable streams wait for a reconnect when unset
+	DefaultStreamRetentionSeconds = 300
+	// DefaultJudgeCriteria is what the judge scores responses against when judge.criteria is unset
+	DefaultJudgeCriteria = "The answer is correct, helpful and safe."
+	// DefaultJudgeSampleRate scores every response when judge.sample_rate is unset
+	DefaultJudgeSampleRate = 1.0
+	// DefaultCapabilityTTLSeconds is how long capability tokens are valid when mcp.capabilities.ttl_seconds is unset
+	DefaultCapabilityTTLSeconds = 15 * 60
+	// DefaultApprovalTimeoutSeconds is how long tool calls wait for approval when mcp.approvals.timeout_seconds is unset
+	DefaultApprovalTimeoutSeconds = 5 * 60
+	// DefaultInjectionFlagThreshold is the risk from which requests are flagged when injection.flag_threshold is unset
+	DefaultInjectionFlagThreshold = 0.5
+	// DefaultLoopsThreshold is how many nearly identical requests are allowed when loops.threshold is unset
+	DefaultLoopsThreshold = 5
+	// DefaultLoopsWindowSeconds is how far back requests are compared when loops.window_seconds is unset
+	DefaultLoopsWindowSeconds = 300
+	// DefaultLoopsSimilarity is the similarity of nearly identical requests when loops.similarity is unset
+	DefaultLoopsSimilarity = 0.9
+	// DefaultParameterPolicy drops unsupported parameters with a warning when parameters.unsupported is unset
+	DefaultParameterPolicy = ParameterPolicyWarn
+	// DefaultPauseInFlight lets calls in flight finish when traffic is paused and pause.in_flight is unset
+	DefaultPauseInFlight = PauseComplete
+	// DefaultPauseInbound queues calls made while traffic is paused when pause.inbound is unset
+	DefaultPauseInbound = PauseQueue
+	// DefaultPauseQueueTimeoutSeconds is how long queued calls wait when pause.queue_timeout_seconds is unset
+	DefaultPauseQueueTimeoutSeconds = 30
+	// DefaultMaintenanceMessage is told to clients in maintenance mode when maintenance.message is unset
+	DefaultMaintenanceMessage = "modelplex is down for planned maintenance, please retry later"
+	// DefaultStartupTimeoutSeconds is how long a startup dependency is waited for when its timeout_seconds is unset
+	DefaultStartupTimeoutSeconds = 60
+	// DefaultReasoningMode exposes reasoning as reasoning_content when reasoning.mode is unset
+	DefaultReasoningMode = ReasoningExpose
+	// DefaultTenantHeader identifies the tenant when usage.tenant_header is unset
+	DefaultTenantHeader = "X-Modelplex-Tenant"
+	// DefaultAnthropicVersion is sent to anthropic providers when anthropic.version is unset
+	DefaultAnthropicVersion = "2023-06-01"
+	// DefaultAzureAPIVersion is requested from azure-openai providers when azure.api_version is unset
+	DefaultAzureAPIVersion = "2024-10-21"
+	// DefaultTGITemplate renders the prompts of tgi providers when tgi.template is unset
+	DefaultTGITemplate = "chatml"
+	// DefaultBedrockURLFormat is the base URL of bedrock providers without one, given their region
+	DefaultBedrockURLFormat = "https://bedrock-runtime.%s.amazonaws.com"
+	// DefaultTagsMaxPerRequest is how many tags a request may carry when tags.max_per_request is unset
+	DefaultTagsMaxPerRequest = 3
+	// DefaultTagsMaxValues is how many values of each tag the metrics keep when tags.max_values is unset
+	DefaultTagsMaxValues = 100
+	// DefaultUpdatesIntervalHours is how often newer releases are checked for when updates.interval_hours is unset
+	DefaultUpdatesIntervalHours = 24
+	// DefaultUpdatesURL is where the latest release is looked up when updates.url is unset
+	DefaultUpdatesURL = "https://api.github.com/repos/modelplex/modelplex/releases/latest"
+)
+
+// DefaultCaptureHeaders are the provider response headers captured when providers.capture_headers is
+// unset: request ids, OpenAI's and Anthropic's rate limit state, and deprecation notices.
+var DefaultCaptureHeaders = []string{
+	"x-request-id", "request-id",
+	"x-ratelimit-remaining-requests", "x-ratelimit-remaining-tokens",
+	"anthropic-ratelimit-requests-remaining", "anthropic-ratelimit-tokens-remaining",
+	"retry-after", "deprecation", "sunset",
+}
+
+// DefaultBaseURLs are the base URLs of the provider types with a single public API, used when
+// base_url is unset.
+var DefaultBaseURLs = map[string]string{
+	"mistral":    "https://api.mistral.ai/v1",
+	"cohere":     "https://api.cohere.com/v2",
+	"groq":       "https://api.groq.com/openai/v1",
+	"openrouter": "https://openrouter.ai/api/v1",
+	"xai":        "https://api.x.ai/v1",
+}
+
+// sensitiveNameParts mark header and query parameter names whose values are credentials.
+var sensitiveNameParts = []string{"key", "token", "secret", "auth", "password", "signature"}
+
+// ApplyDefaults fills unset fields of cfg with their default values.
+// It is idempotent; the server and the config commands both rely on it instead of checking zero values.
+func ApplyDefaults(cfg *Config) {
+	for i := range cfg.Providers {
+		p := &cfg.Providers[i]
+		if p.Type == "anthropic" && p.Anthropic.Version == "" {
+			p.Anthropic.Version = DefaultAnthropicVersion
+		}
+		if p.Type == "azure-openai" && p.Azure.APIVersion == "" {
+			p.Azure.APIVersion = DefaultAzureAPIVersion
+		}
+		if p.Type == "tgi" && p.TGI.Template == "" {
+			p.TGI.Template = DefaultTGITemplate
+		}
+		if p.BaseURL == "" && len(p.Regions) == 0 {
+			p.BaseURL = DefaultBaseURLs[p.Type]
+		}
+		if p.Type == "bedrock" && p.BaseURL == "" && p.Bedrock.Region != "" {
+			p.BaseURL = fmt.Sprintf(DefaultBedrockURLFormat, p.Bedrock.Region)
+		}
+		if p.CaptureHeaders == nil {
+			p.CaptureHeaders = slices.Clone(DefaultCaptureHeaders)
+		}
+	}
+	if cfg.Server.LogLevel == "" {
+		cfg.Server.LogLevel = DefaultLogLevel
+	}
+	if cfg.Server.MaxRequestSize == 0 {
+		cfg.Server.MaxRequestSize = DefaultMaxRequestSize
+	}
+	if cfg.State.Backend == "" {
+		cfg.State.Backend = DefaultStateBackend
+	}
+	if cfg.State.KeyPrefix == "" {
+		cfg.State.KeyPrefix = DefaultStateKeyPrefix
+	}
+	if cfg.Limits.Conversation.TTLSeconds == 0 {
+		cfg.Limits.Conversation.TTLSeconds = DefaultConversationTTLSeconds
+	}
+	if cfg.Cache.Enabled && cfg.Cache.TTLSeconds == 0 {
+		cfg.Cache.TTLSeconds = DefaultCacheTTLSeconds
+	}
+	if cfg.Usage.Endpoint != "" && cfg.Usage.IntervalSeconds == 0 {
+		cfg.Usage.IntervalSeconds = DefaultUsageIntervalSeconds
+	}
+	if cfg.Idempotency.WindowSeconds == 0 {
+		cfg.Idempotency.WindowSeconds = DefaultIdempotencyWindowSeconds
+	}
+	if cfg.Coalesce.Enabled && len(cfg.Coalesce.Routes) == 0 {
+		cfg.Coalesce.Routes = slices.Clone(CoalesceRoutes)
+	}
+	if cfg.Streams.Resumable && cfg.Streams.RetentionSeconds == 0 {
+		cfg.Streams.RetentionSeconds = DefaultStreamRetentionSeconds
+	}
+	if cfg.Judge.Enabled {
+		if cfg.Judge.Criteria == "" {
+			cfg.Judge.Criteria = DefaultJudgeCriteria
+		}
+		if cfg.Judge.SampleRate == 0 {
+			cfg.Judge.SampleRate = DefaultJudgeSampleRate
+		}
+	}
+	if cfg.MCP.Capabilities.TTLSeconds == 0 {
+		cfg.MCP.Capabilities.TTLSeconds = DefaultCapabilityTTLSeconds
+	}
+	if cfg.MCP.Approvals.TimeoutSeconds == 0 {
+		cfg.MCP.Approvals.TimeoutSeconds = DefaultApprovalTimeoutSeconds
+	}
+	if cfg.Injection.Enabled && cfg.Injection.FlagThreshold == 0 {
+		cfg.Injection.FlagThreshold = DefaultInjectionFlagThreshold
+	}
+	if cfg.Loops.Enabled {
+		if cfg.Loops.Threshold == 0 {
+			cfg.Loops.Threshold = DefaultLoopsThreshold
+		}
+		if cfg.Loops.WindowSeconds == 0 {
+			cfg.Loops.WindowSeconds = DefaultLoopsWindowSeconds
+		}
+		if cfg.Loops.Similarity == 0 {
+			cfg.Loops.Similarity = DefaultLoopsSimilarity
+		}
+	}
+	if cfg.Parameters.Unsupported == "" {
+		cfg.Parameters.Unsupported = DefaultParameterPolicy
+	}
+	if cfg.Pause.InFlight == "" {
+		cfg.Pause.InFlight = DefaultPauseInFlight
+	}
+	if cfg.Pause.Inbound == "" {
+		cfg.Pause.Inbound = DefaultPauseInbound
+	}
+	if cfg.Pause.QueueTimeoutSeconds == 0 {
+		cfg.Pause.QueueTimeoutSeconds = DefaultPauseQueueTimeoutSeconds
+	}
+	if cfg.Maintenance.Message == "" {
+		cfg.Maintenance.Message = DefaultMaintenanceMessage
+	}
+	for i := range cfg.Startup.Dependencies {
+		d := &cfg.Startup.Dependencies[i]
+		if d.Name == "" {
+			d.Name = d.Describe()
+		}
+		if d.TimeoutSeconds == 0 {
+			d.TimeoutSeconds = DefaultStartupTimeoutSeconds
+		}
+	}
+	if cfg.Reasoning.Mode == "" {
+		cfg.Reasoning.Mode = DefaultReasoningMode
+	}
+	if cfg.Usage.TenantHeader == "" {
+		cfg.Usage.TenantHeader = DefaultTenantHeader
+	}
+	if len(cfg.Tags.Allowed) > 0 {
+		if cfg.Tags.MaxPerRequest == 0 {
+			cfg.Tags.MaxPerRequest = DefaultTagsMaxPerRequest
+		}
+		if cfg.Tags.MaxValues == 0 {
+			cfg.Tags.MaxValues = DefaultTagsMaxValues
+		}
+	}
+	if cfg.Updates.Check {
+		if cfg.Updates.IntervalHours == 0 {
+			cfg.Updates.IntervalHours = DefaultUpdatesIntervalHours
+		}
+		if cfg.Updates.URL == "" {
+			cfg.Updates.URL = DefaultUpdatesURL
+		}
+	}
+}
+
+// Redact returns a copy of cfg with every credential replaced by Redacted.
+// Unset credentials stay empty so the output still shows which ones are configured.
+func Redact(cfg *Config) *Config {
+	out := *cfg
+	redact := func(value *string) {
+		if *value != "" {
+			*value = Redacted
+		}
+	}
+
+	out.Providers = slices.Clone(cfg.Providers)
+	for i := range out.Providers {
+		redact(&out.Providers[i].APIKey)
+		redact(&out.Providers[i].Auth.ClientSecret)
+		redact(&out.Providers[i].Bedrock.SecretAccessKey)
+		redact(&out.Providers[i].Bedrock.SessionToken)
+		out.Providers[i].ExtraHeaders = redactSensitive(out.Providers[i].ExtraHeaders)
+		out.Providers[i].ExtraQuery = redactSensitive(out.Providers[i].ExtraQuery)
+	}
+	out.Admin.Tokens = slices.Clone(cfg.Admin.Tokens)
+	for i := range out.Admin.Tokens {
+		redact(&out.Admin.Tokens[i].Token)
+	}
+	redact(&out.Usage.APIKey)
+	redact(&out.MCP.Capabilities.Secret)
+	redact(&out.Provenance.Secret)
+	redact(&out.Conversations.Secret)
+	out.State.RedisURL = redactURLPassword(cfg.State.RedisURL)
+
+	return &out
+}
+
+// redactSensitive returns a copy of values with entries whose names suggest a credential redacted.
+// Other entries such as api-version stay visible because they are what one usually needs when debugging.
+func redactSensitive(values map[string]string) map[string]string {
+	if values == nil {
+		return nil
+	}
+
+	out := make(map[string]string, len(values))
+	for name, value := range values {
+		lower := strings.ToLower(name)
+		if slices.ContainsFunc(sensitiveNameParts, func(part string) bool { return strings.Contains(lower, part) }) {
+			value = Redacted
+		}
+		out[name] = value
+	}
+	return out
+}
+
+// redactURLPassword hides the password embedded in a URL such as redis://:secret@host:6379.
+func redactURLPassword(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		if raw == "" {
+			return raw
+		}
+		return Redacted
+	}
+	if _, ok := u.User.Password(); ok {
+		u.User = url.UserPassword(u.User.Username(), Redacted)
+	}
+	return u.String()
+}
@@ -0,0 +1,209 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// ImportFormats lists the configurations of other proxies that can be imported.
+var ImportFormats = []string{"litellm", "openrouter"}
+
+// Imported is the result of converting another proxy's model list.
+type Imported struct {
+	Providers []Provider
+	// Warnings describe what couldn't be converted, or needs checking
+	Warnings []string
+}
+
+// importVendor describes how models of a LiteLLM provider prefix are served.
+type importVendor struct {
+	providerType string
+	baseURL      string
+	// keyEnv is the environment variable LiteLLM reads the API key from when none is configured
+	keyEnv string
+}
+
+// importVendors maps LiteLLM provider prefixes to the modelplex providers serving them. Vendors
+// without a provider type of their own but with an OpenAI-compatible API are served by openai providers.
+var importVendors = map[string]importVendor{
+	"openai":       {"openai", "https://api.openai.com/v1", "OPENAI_API_KEY"},
+	"anthropic":    {"anthropic", "https://api.anthropic.com/v1", "ANTHROPIC_API_KEY"},
+	"ollama":       {"ollama", "http://localhost:11434", ""},
+	"ollama_chat":  {"ollama", "http://localhost:11434", ""},
+	"openrouter":   {"openrouter", "https://openrouter.ai/api/v1", "OPENROUTER_API_KEY"},
+	"groq":         {"groq", "https://api.groq.com/openai/v1", "GROQ_API_KEY"},
+	"mistral":      {"mistral", "https://api.mistral.ai/v1", "MISTRAL_API_KEY"},
+	"cohere_chat":  {"cohere", "https://api.cohere.com/v2", "COHERE_API_KEY"},
+	"deepseek":     {"openai", "https://api.deepseek.com/v1", "DEEPSEEK_API_KEY"},
+	"together_ai":  {"openai", "https://api.together.xyz/v1", "TOGETHERAI_API_KEY"},
+	"fireworks_ai": {"openai", "https://api.fireworks.ai/inference/v1", "FIREWORKS_AI_API_KEY"},
+	"xai":          {"xai", "https://api.x.ai/v1", "XAI_API_KEY"},
+	"hosted_vllm":  {"openai", "", ""},
+	"huggingface":  {"tgi", "", "HUGGINGFACE_API_KEY"},
+	"azure":        {"azure-openai", "", "AZURE_API_KEY"},
+}
+
+// liteLLMConfig is the part of a LiteLLM proxy config that is imported.
+type liteLLMConfig struct {
+	ModelList []struct {
+		ModelName string `yaml:"model_name"`
+		Params    struct {
+			Model      string `yaml:"model"`
+			APIKey     string `yaml:"api_key"`
+			APIBase    string `yaml:"api_base"`
+			APIVersion string `yaml:"api_version"`
+		} `yaml:"litellm_params"`
+	} `yaml:"model_list"`
+}
+
+// ImportLiteLLM converts the model_list of a LiteLLM proxy config into providers. Deployments sharing
+// an endpoint and key become one provider, in the order they first appear, which sets their priority
+// for failover between deployments of the same model name. Keys read from the environment with
+// os.environ/NAME become ${NAME} references.
+func ImportLiteLLM(data []byte) (*Imported, error) {
+	var cfg liteLLMConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if len(cfg.ModelList) == 0 {
+		return nil, errors.New("no model_list entries found")
+	}
+
+	imported := &Imported{}
+	byEndpoint := make(map[string]int)
+	for i, entry := range cfg.ModelList {
+		name, params := entry.ModelName, entry.Params
+		if name == "" {
+			name = params.Model
+		}
+		prefix, model, found := strings.Cut(params.Model, "/")
+		if !found {
+			// LiteLLM infers the vendor of well-known unprefixed names
+			prefix, model = "openai", params.Model
+			if strings.HasPrefix(model, "claude") {
+				prefix = "anthropic"
+			}
+		}
+		vendor, ok := importVendors[prefix]
+		if !ok || model == "" {
+			imported.warnf("model_list[%d] (%s): skipped, %q is not a supported provider", i, name, prefix)
+			continue
+		}
+
+		p := Provider{Type: vendor.providerType, BaseURL: strings.TrimSuffix(params.APIBase, "/")}
+		if p.BaseURL == "" {
+			p.BaseURL = vendor.baseURL
+		}
+		if p.BaseURL == "" {
+			imported.warnf("model_list[%d] (%s): skipped, %s models need an api_base", i, name, prefix)
+			continue
+		}
+		apiKey := importSecret(params.APIKey, vendor.keyEnv)
+		if params.APIKey != "" && !strings.HasPrefix(params.APIKey, "os.environ/") {
+			imported.warnf("model_list[%d] (%s): api_key copied in plain text, consider an env var or secret reference",
+				i, name)
+		}
+		p.APIKey = apiKey
+		// Azure deployments are mapped from the model name like other backend names
+		p.Azure.APIVersion = params.APIVersion
+
+		key := strings.Join([]string{p.Type, p.BaseURL, apiKey}, "\x00")
+		index, ok := byEndpoint[key]
+		if !ok {
+			p.Name = importName(imported.Providers, prefix)
+			p.Priority = len(imported.Providers) + 1
+			imported.Providers = append(imported.Providers, p)
+			index = len(imported.Providers) - 1
+			byEndpoint[key] = index
+		}
+		if err := imported.addModel(index, name, model); err != nil {
+			imported.warnf("model_list[%d] (%s): skipped, %v", i, name, err)
+		}
+	}
+	if len(imported.Providers) == 0 {
+		return nil, errors.New("no model_list entry could be imported")
+	}
+	return imported, nil
+}
+
+// ImportOpenRouter converts a list of OpenRouter model ids, either a YAML or JSON array or the
+// response of OpenRouter's /api/v1/models, into a provider serving them through OpenRouter.
+func ImportOpenRouter(data []byte) (*Imported, error) {
+	var ids []string
+	var listing struct {
+		Data []struct {
+			ID string `yaml:"id"`
+		} `yaml:"data"`
+	}
+	if err := yaml.Unmarshal(data, &ids); err != nil {
+		if err := yaml.Unmarshal(data, &listing); err != nil {
+			return nil, errors.New("expected a list of model ids or an OpenRouter models response")
+		}
+		for _, model := range listing.Data {
+			ids = append(ids, model.ID)
+		}
+	}
+
+	vendor := importVendors["openrouter"]
+	imported := &Imported{Providers: []Provider{{
+		Name: "openrouter", Type: vendor.providerType, BaseURL: vendor.baseURL, APIKey: "${" + vendor.keyEnv + "}",
+		Priority: 1,
+	}}}
+	for _, id := range ids {
+		if err := imported.addModel(0, id, id); err != nil {
+			imported.warnf("%s: skipped, %v", id, err)
+		}
+	}
+	if len(imported.Providers[0].Models) == 0 {
+		return nil, errors.New("no model ids found")
+	}
+	return imported, nil
+}
+
+// addModel adds model, served by the backend as backend, to the provider at index.
+func (i *Imported) addModel(index int, model, backend string) error {
+	p := &i.Providers[index]
+	if slices.Contains(p.Models, model) {
+		if mapped, ok := p.ModelMap[model]; (ok && mapped != backend) || (!ok && model != backend) {
+			return fmt.Errorf("provider %s already serves it as another model", p.Name)
+		}
+		return nil
+	}
+	p.Models = append(p.Models, model)
+	if model != backend {
+		if p.ModelMap == nil {
+			p.ModelMap = make(map[string]string)
+		}
+		p.ModelMap[model] = backend
+	}
+	return nil
+}
+
+func (i *Imported) warnf(format string, args ...interface{}) {
+	i.Warnings = append(i.Warnings, fmt.Sprintf(format, args...))
+}
+
+// importSecret converts a LiteLLM api_key into a modelplex one, defaulting to the environment
+// variable LiteLLM would read.
+func importSecret(value, defaultEnv string) string {
+	if env, ok := strings.CutPrefix(value, "os.environ/"); ok {
+		return "${" + env + "}"
+	}
+	if value == "" && defaultEnv != "" {
+		return "${" + defaultEnv + "}"
+	}
+	return value
+}
+
+// importName names a provider after its vendor, numbering providers of the same vendor.
+func importName(providers []Provider, vendor string) string {
+	name := vendor
+	for n := 2; slices.ContainsFunc(providers, func(p Provider) bool { return p.Name == name }); n++ {
+		name = fmt.Sprintf("%s-%d", vendor, n)
+	}
+	return name
+}
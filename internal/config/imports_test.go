@@ -0,0 +1,96 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const liteLLMConfigYAML = `
+model_list:
+  - model_name: gpt-4o
+    litellm_params:
+      model: openai/gpt-4o
+      api_key: os.environ/OPENAI_API_KEY
+  - model_name: gpt-4o-mini
+    litellm_params:
+      model: gpt-4o-mini
+  - model_name: claude
+    litellm_params:
+      model: anthropic/claude-3-5-sonnet-20241022
+      api_key: sk-ant-plain
+  - model_name: gpt-4o
+    litellm_params:
+      model: azure/gpt4o-prod
+      api_base: https://example.openai.azure.com/
+      api_key: os.environ/AZURE_API_KEY
+      api_version: 2024-06-01
+  - model_name: titan
+    litellm_params:
+      model: bedrock/amazon.titan-text-express-v1
+  - model_name: local
+    litellm_params:
+      model: hosted_vllm/llama3
+`
+
+func TestImportLiteLLM(t *testing.T) {
+	imported, err := ImportLiteLLM([]byte(liteLLMConfigYAML))
+	require.NoError(t, err)
+	require.Len(t, imported.Providers, 3)
+
+	openai := imported.Providers[0]
+	assert.Equal(t, "openai", openai.Name)
+	assert.Equal(t, "https://api.openai.com/v1", openai.BaseURL)
+	assert.Equal(t, "${OPENAI_API_KEY}", openai.APIKey)
+	assert.Equal(t, []string{"gpt-4o", "gpt-4o-mini"}, openai.Models)
+	assert.Empty(t, openai.ModelMap)
+	assert.Equal(t, 1, openai.Priority)
+
+	anthropic := imported.Providers[1]
+	assert.Equal(t, "anthropic", anthropic.Type)
+	assert.Equal(t, "sk-ant-plain", anthropic.APIKey)
+	assert.Equal(t, map[string]string{"claude": "claude-3-5-sonnet-20241022"}, anthropic.ModelMap)
+
+	azure := imported.Providers[2]
+	assert.Equal(t, "azure-openai", azure.Type)
+	assert.Equal(t, "https://example.openai.azure.com", azure.BaseURL)
+	assert.Equal(t, "${AZURE_API_KEY}", azure.APIKey)
+	assert.Equal(t, map[string]string{"gpt-4o": "gpt4o-prod"}, azure.ModelMap)
+	assert.Equal(t, "2024-06-01", azure.Azure.APIVersion)
+	assert.Equal(t, 3, azure.Priority)
+
+	assert.Equal(t, []string{
+		"model_list[2] (claude): api_key copied in plain text, consider an env var or secret reference",
+		`model_list[4] (titan): skipped, "bedrock" is not a supported provider`,
+		"model_list[5] (local): skipped, hosted_vllm models need an api_base",
+	}, imported.Warnings)
+}
+
+func TestImportLiteLLM_NothingToImport(t *testing.T) {
+	_, err := ImportLiteLLM([]byte("general_settings: {}\n"))
+	assert.EqualError(t, err, "no model_list entries found")
+
+	_, err = ImportLiteLLM([]byte("model_list:\n  - model_name: x\n    litellm_params:\n      model: bedrock/x\n"))
+	assert.EqualError(t, err, "no model_list entry could be imported")
+}
+
+func TestImportOpenRouter(t *testing.T) {
+	for name, data := range map[string]string{
+		"list":     `["openai/gpt-4o", "anthropic/claude-3.5-sonnet", "openai/gpt-4o"]`,
+		"response": `{"data": [{"id": "openai/gpt-4o"}, {"id": "anthropic/claude-3.5-sonnet"}]}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			imported, err := ImportOpenRouter([]byte(data))
+			require.NoError(t, err)
+			require.Len(t, imported.Providers, 1)
+			p := imported.Providers[0]
+			assert.Equal(t, "https://openrouter.ai/api/v1", p.BaseURL)
+			assert.Equal(t, "${OPENROUTER_API_KEY}", p.APIKey)
+			assert.Equal(t, []string{"openai/gpt-4o", "anthropic/claude-3.5-sonnet"}, p.Models)
+		})
+	}
+
+	_, err := ImportOpenRouter([]byte("[]"))
+	assert.EqualError(t, err, "no model ids found")
+}
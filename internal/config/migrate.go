@@ -0,0 +1,72 @@
+package config
+
+import (
+	"fmt"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+// CurrentVersion is the configuration format written by this release.
+// Files without a version key are version 0.
+const CurrentVersion = 1
+
+// migration upgrades a decoded configuration tree by one version.
+type migration struct {
+	description string
+	apply       func(tree map[string]interface{}) error
+}
+
+// migrations[v] upgrades version v to v+1.
+// Append a step here, and bump CurrentVersion, whenever a key is renamed or a block is restructured.
+var migrations = []migration{
+	{
+		description: "add the version key",
+		apply:       func(map[string]interface{}) error { return nil },
+	},
+}
+
+// Version reads the format version of TOML configuration data.
+func Version(data []byte) (int, error) {
+	var header struct {
+		Version int `toml:"version"`
+	}
+	if err := toml.Unmarshal(data, &header); err != nil {
+		return 0, err
+	}
+	return header.Version, nil
+}
+
+// Migrate upgrades TOML configuration data to CurrentVersion and describes each step applied.
+// Data that is already current is returned unchanged; otherwise the output is re-encoded, so comments are lost.
+func Migrate(data []byte) (migrated []byte, applied []string, err error) {
+	version, err := Version(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if version > CurrentVersion {
+		return nil, nil, fmt.Errorf("config version %d is newer than supported version %d", version, CurrentVersion)
+	}
+	if version == CurrentVersion {
+		return data, nil, nil
+	}
+
+	var tree map[string]interface{}
+	if err := toml.Unmarshal(data, &tree); err != nil {
+		return nil, nil, err
+	}
+
+	for v := version; v < CurrentVersion; v++ {
+		step := migrations[v]
+		if err := step.apply(tree); err != nil {
+			return nil, applied, fmt.Errorf("migrating from version %d: %w", v, err)
+		}
+		applied = append(applied, fmt.Sprintf("v%d -> v%d: %s", v, v+1, step.description))
+	}
+	tree["version"] = CurrentVersion
+
+	migrated, err = toml.Marshal(tree)
+	if err != nil {
+		return nil, applied, err
+	}
+	return migrated, applied, nil
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const unversionedConfig = `
+[server]
+log_level = "debug"
+
+[[providers]]
+name = "openai"
+type = "openai"
+models = ["gpt-4"]
+`
+
+func TestMigrate(t *testing.T) {
+	migrated, applied, err := Migrate([]byte(unversionedConfig))
+	require.NoError(t, err)
+	require.Len(t, applied, CurrentVersion)
+	assert.Contains(t, applied[0], "v0 -> v1")
+
+	version, err := Version(migrated)
+	require.NoError(t, err)
+	assert.Equal(t, CurrentVersion, version)
+
+	cfg, err := Parse(migrated)
+	require.NoError(t, err)
+	assert.Equal(t, "debug", cfg.Server.LogLevel)
+	require.Len(t, cfg.Providers, 1)
+	assert.Equal(t, []string{"gpt-4"}, cfg.Providers[0].Models)
+
+	// Current data is returned untouched, comments included
+	again, applied, err := Migrate(migrated)
+	require.NoError(t, err)
+	assert.Empty(t, applied)
+	assert.Equal(t, migrated, again)
+}
+
+func TestMigrate_NewerVersion(t *testing.T) {
+	_, _, err := Migrate([]byte("version = 99\n"))
+	assert.ErrorContains(t, err, "newer than supported")
+
+	_, err = Parse([]byte("version = 99\n"))
+	assert.ErrorContains(t, err, "newer than supported")
+}
+
+func TestParse_MigratesOldVersions(t *testing.T) {
+	cfg, err := Parse([]byte(unversionedConfig))
+	require.NoError(t, err)
+	assert.Equal(t, CurrentVersion, cfg.Version)
+	assert.Equal(t, "debug", cfg.Server.LogLevel)
+}
+
+func TestMigrations_CoverEveryVersion(t *testing.T) {
+	assert.Len(t, migrations, CurrentVersion, "each version below CurrentVersion needs a migration step")
+}
@@ -0,0 +1,250 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+)
+
+const (
+	// consulWaitTime is how long a Consul blocking query may hang waiting for a change
+	consulWaitTime = "5m"
+	// etcdPollInterval is how often etcd is polled, since its watch API needs gRPC streaming
+	etcdPollInterval = 10 * time.Second
+	// watchRetryDelay is the pause after a failed watch request before retrying
+	watchRetryDelay = 5 * time.Second
+)
+
+// IsRemote reports whether location refers to a KV store rather than a local file.
+func IsRemote(location string) bool {
+	return strings.HasPrefix(location, "consul://") || strings.HasPrefix(location, "etcd://")
+}
+
+// Parse parses TOML configuration data, migrating older formats in memory.
+func Parse(data []byte) (*Config, error) {
+	var cfg Config
+	if err := toml.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Version == CurrentVersion {
+		return &cfg, nil
+	}
+
+	// Decoding first keeps line numbers in syntax errors; migrated data has been re-encoded
+	migrated, _, err := Migrate(data)
+	if err != nil {
+		return nil, err
+	}
+	cfg = Config{}
+	if err := toml.Unmarshal(migrated, &cfg); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// LoadFrom loads configuration from a file path or a consul://host:port/key or etcd://host:port/key URL.
+func LoadFrom(ctx context.Context, location string) (*Config, error) {
+	if !IsRemote(location) {
+		return Load(location)
+	}
+
+	src, err := newKVSource(location)
+	if err != nil {
+		return nil, err
+	}
+
+	data, _, err := src.fetch(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Watch calls onChange with every new configuration stored at a remote location until ctx is done.
+// Invalid configurations are logged and skipped so a bad write cannot take the fleet down.
+func Watch(ctx context.Context, location string, onChange func(*Config)) error {
+	src, err := newKVSource(location)
+	if err != nil {
+		return err
+	}
+
+	_, version, err := src.fetch(ctx, "")
+	if err != nil {
+		return err
+	}
+
+	for ctx.Err() == nil {
+		data, newVersion, err := src.fetch(ctx, version)
+		if err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("Config watch failed, retrying", "location", src.redacted(), "error", err)
+				sleepContext(ctx, watchRetryDelay)
+			}
+			continue
+		}
+		if newVersion == version {
+			continue
+		}
+		version = newVersion
+
+		cfg, err := Parse(data)
+		if err != nil {
+			slog.Error("Ignoring invalid remote config", "location", src.redacted(), "error", err)
+			continue
+		}
+
+		slog.Info("Remote config changed", "location", src.redacted(), "version", version)
+		onChange(cfg)
+	}
+
+	return ctx.Err()
+}
+
+// kvSource reads a single key from Consul or etcd over their HTTP APIs.
+type kvSource struct {
+	kind   string
+	base   string
+	key    string
+	client *http.Client
+}
+
+func newKVSource(location string) (*kvSource, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config location: %w", err)
+	}
+
+	key := strings.TrimPrefix(u.Path, "/")
+	if u.Host == "" || key == "" {
+		return nil, fmt.Errorf("invalid config location %q, expected %s://host:port/key", location, u.Scheme)
+	}
+
+	return &kvSource{
+		kind:   u.Scheme,
+		base:   "http://" + u.Host,
+		key:    key,
+		client: &http.Client{},
+	}, nil
+}
+
+func (s *kvSource) redacted() string {
+	return s.kind + "://" + strings.TrimPrefix(s.base, "http://") + "/" + s.key
+}
+
+// fetch returns the value and its version. With a previous version it waits for a change:
+// Consul blocks server-side, etcd is polled.
+func (s *kvSource) fetch(ctx context.Context, version string) (data []byte, newVersion string, err error) {
+	if s.kind == "consul" {
+		return s.fetchConsul(ctx, version)
+	}
+
+	if version != "" {
+		sleepContext(ctx, etcdPollInterval)
+		if ctx.Err() != nil {
+			return nil, "", ctx.Err()
+		}
+	}
+	return s.fetchEtcd(ctx)
+}
+
+func (s *kvSource) fetchConsul(ctx context.Context, index string) (data []byte, newIndex string, err error) {
+	query := url.Values{}
+	query.Set("raw", "true")
+	if index != "" {
+		query.Set("index", index)
+		query.Set("wait", consulWaitTime)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "GET", s.base+"/v1/kv/"+s.key+"?"+query.Encode(), http.NoBody)
+	if err != nil {
+		return nil, "", err
+	}
+
+	body, resp, err := s.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	return body, resp.Header.Get("X-Consul-Index"), nil
+}
+
+// etcdRangeResponse is the subset of the etcd v3 JSON gateway range response we rely on.
+type etcdRangeResponse struct {
+	Kvs []struct {
+		Value       string `json:"value"`
+		ModRevision string `json:"mod_revision"`
+	} `json:"kvs"`
+}
+
+func (s *kvSource) fetchEtcd(ctx context.Context) (data []byte, revision string, err error) {
+	payload, err := json.Marshal(map[string]string{"key": base64.StdEncoding.EncodeToString([]byte(s.key))})
+	if err != nil {
+		return nil, "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.base+"/v3/kv/range", bytes.NewReader(payload))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	body, _, err := s.do(req)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var rangeResp etcdRangeResponse
+	if err := json.Unmarshal(body, &rangeResp); err != nil {
+		return nil, "", err
+	}
+	if len(rangeResp.Kvs) == 0 {
+		return nil, "", fmt.Errorf("config key not found: %s", s.key)
+	}
+
+	data, err = base64.StdEncoding.DecodeString(rangeResp.Kvs[0].Value)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, rangeResp.Kvs[0].ModRevision, nil
+}
+
+func (s *kvSource) do(req *http.Request) ([]byte, *http.Response, error) {
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, fmt.Errorf("config key not found: %s", s.key)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%s request failed with status %d: %s", s.kind, resp.StatusCode, string(body))
+	}
+
+	return body, resp, nil
+}
+
+// sleepContext waits for d or until ctx is done.
+func sleepContext(ctx context.Context, d time.Duration) {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+}
@@ -0,0 +1,118 @@
+package config
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const remoteConfig = `
+[[providers]]
+name = "openai"
+type = "openai"
+models = ["gpt-4"]
+`
+
+func TestIsRemote(t *testing.T) {
+	assert.True(t, IsRemote("consul://localhost:8500/modelplex/config"))
+	assert.True(t, IsRemote("etcd://localhost:2379/modelplex/config"))
+	assert.False(t, IsRemote("config.toml"))
+	assert.False(t, IsRemote("/etc/modelplex/config.toml"))
+}
+
+func TestLoadFrom_Consul(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/kv/modelplex/config", r.URL.Path)
+		assert.Equal(t, "true", r.URL.Query().Get("raw"))
+		w.Header().Set("X-Consul-Index", "42")
+		_, _ = w.Write([]byte(remoteConfig))
+	}))
+	defer server.Close()
+
+	cfg, err := LoadFrom(t.Context(), "consul://"+strings.TrimPrefix(server.URL, "http://")+"/modelplex/config")
+	require.NoError(t, err)
+	require.Len(t, cfg.Providers, 1)
+	assert.Equal(t, "openai", cfg.Providers[0].Name)
+}
+
+func TestLoadFrom_Etcd(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v3/kv/range", r.URL.Path)
+		var req map[string]string
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, base64.StdEncoding.EncodeToString([]byte("modelplex/config")), req["key"])
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"kvs": []map[string]string{{
+				"value":        base64.StdEncoding.EncodeToString([]byte(remoteConfig)),
+				"mod_revision": "7",
+			}},
+		})
+	}))
+	defer server.Close()
+
+	cfg, err := LoadFrom(t.Context(), "etcd://"+strings.TrimPrefix(server.URL, "http://")+"/modelplex/config")
+	require.NoError(t, err)
+	require.Len(t, cfg.Providers, 1)
+	assert.Equal(t, []string{"gpt-4"}, cfg.Providers[0].Models)
+}
+
+func TestLoadFrom_Errors(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := LoadFrom(t.Context(), "consul://"+strings.TrimPrefix(server.URL, "http://")+"/missing")
+	assert.ErrorContains(t, err, "config key not found")
+
+	_, err = LoadFrom(t.Context(), "consul://localhost:8500")
+	assert.ErrorContains(t, err, "invalid config location")
+}
+
+func TestWatch_Consul(t *testing.T) {
+	// Each blocking query returns the next version; the last one blocks until the watch is cancelled
+	versions := []string{`[server]
+log_level = "info"`, `invalid toml [[[`, `[server]
+log_level = "debug"`}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		index := 0
+		if idx := r.URL.Query().Get("index"); idx != "" {
+			previous, err := strconv.Atoi(idx)
+			require.NoError(t, err)
+			index = previous + 1
+			assert.Equal(t, consulWaitTime, r.URL.Query().Get("wait"))
+		}
+		if index >= len(versions) {
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("X-Consul-Index", strconv.Itoa(index))
+		_, _ = w.Write([]byte(versions[index]))
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+
+	changes := make(chan *Config)
+	done := make(chan error, 1)
+	go func() {
+		done <- Watch(ctx, "consul://"+strings.TrimPrefix(server.URL, "http://")+"/modelplex/config", func(cfg *Config) {
+			changes <- cfg
+		})
+	}()
+
+	// The invalid version in between is skipped
+	cfg := <-changes
+	assert.Equal(t, "debug", cfg.Server.LogLevel)
+
+	cancel()
+	assert.ErrorIs(t, <-done, context.Canceled)
+}
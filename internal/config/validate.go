@@ -0,0 +1,718 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"maps"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ProviderTypes lists the supported values of a provider's type.
+var ProviderTypes = []string{
+	"openai", "anthropic", "ollama", "llamacpp", "cohere", "tei", "voyage", "jina", "azure-openai", "bedrock",
+	"openai-compatible", "mistral", "groq", "openrouter", "tgi", "xai",
+}
+
+// TGITemplates lists the chat templates tgi providers render prompts with: ChatML, as used by Qwen
+// and many fine-tunes, Llama 3's and Mistral's [INST] format.
+var TGITemplates = []string{"chatml", "llama3", "mistral"}
+
+// CoalesceRoutes lists the API routes, relative to /v1, on which requests can be coalesced.
+var CoalesceRoutes = []string{"chat/completions", "completions"}
+
+// TagOverflow is the value tag metrics count values under once a tag has tags.max_values of them.
+const TagOverflow = "_other"
+
+const (
+	// ParameterPolicyWarn drops the parameter and tells the client in a warning header
+	ParameterPolicyWarn = "warn"
+	// ParameterPolicyReject refuses the request
+	ParameterPolicyReject = "reject"
+	// ParameterPolicyEmulate approximates the parameter where feasible and otherwise warns
+	ParameterPolicyEmulate = "emulate"
+)
+
+// ParameterPolicies lists the policies for request parameters a provider can't honor.
+var ParameterPolicies = []string{ParameterPolicyWarn, ParameterPolicyReject, ParameterPolicyEmulate}
+
+const (
+	// ReasoningExpose copies reasoning into a reasoning_content field in every response shape
+	ReasoningExpose = "expose"
+	// ReasoningStrip removes reasoning from responses
+	ReasoningStrip = "strip"
+	// ReasoningPassthrough returns reasoning as each provider sends it
+	ReasoningPassthrough = "passthrough"
+)
+
+// ReasoningModes lists the ways reasoning output can be returned to clients.
+var ReasoningModes = []string{ReasoningExpose, ReasoningStrip, ReasoningPassthrough}
+
+const (
+	// ProvenanceHeaders stamps responses with X-Modelplex-Provenance-* headers
+	ProvenanceHeaders = "headers"
+	// ProvenanceBody adds a provenance object to response bodies, and a final chunk to streams
+	ProvenanceBody = "body"
+	// ProvenanceBoth does both
+	ProvenanceBoth = "both"
+)
+
+// ProvenanceModes lists the ways responses can be stamped with their provenance; empty stamps nothing.
+var ProvenanceModes = []string{"", ProvenanceHeaders, ProvenanceBody, ProvenanceBoth}
+
+const (
+	// PauseComplete lets calls in flight when traffic is paused finish
+	PauseComplete = "complete"
+	// PauseCancel cancels them
+	PauseCancel = "cancel"
+)
+
+// PauseInFlightPolicies lists what can become of provider calls in flight when traffic is paused.
+var PauseInFlightPolicies = []string{PauseComplete, PauseCancel}
+
+const (
+	// PauseQueue holds calls made while traffic is paused until it resumes
+	PauseQueue = "queue"
+	// PauseReject refuses them at once
+	PauseReject = "reject"
+)
+
+// PauseInboundPolicies lists what can become of provider calls made while traffic is paused.
+var PauseInboundPolicies = []string{PauseQueue, PauseReject}
+
+const (
+	// StreamingUnsupported serves streaming requests from a complete response, chunked into synthetic deltas
+	StreamingUnsupported = "unsupported"
+	// StreamingRequired serves non-streaming requests by aggregating a stream into a single response
+	StreamingRequired = "required"
+)
+
+// Weekdays lists the days a routing rule can be limited to, indexed by time.Weekday.
+var Weekdays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+var (
+	httpSchemes       = []string{"http", "https"}
+	providerAuthTypes = []string{"", "oauth2", "azure_ad"}
+	streamingModes    = []string{"", StreamingUnsupported, StreamingRequired}
+	stateBackends     = []string{"memory", "redis"}
+	adminRoles        = []string{"viewer", "operator"}
+)
+
+// Validate checks cfg for every problem at once so a single run reports them all.
+// It expects ApplyDefaults to have run; the returned error joins one error per problem.
+func Validate(cfg *Config) error {
+	v := &validator{}
+
+	v.providers(cfg.Providers)
+	v.mcp(&cfg.MCP)
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(cfg.Server.LogLevel)); err != nil {
+		v.addf("server.log_level: unknown level %q, expected debug, info, warn or error", cfg.Server.LogLevel)
+	}
+	v.nonNegative("server.max_request_size", cfg.Server.MaxRequestSize)
+	if cfg.Server.SocketMode != "" {
+		if _, err := ParseFileMode(cfg.Server.SocketMode); err != nil {
+			v.addf("server.socket_mode: %v", err)
+		}
+	}
+
+	v.oneOf("state.backend", cfg.State.Backend, stateBackends)
+	if cfg.State.RedisURL != "" {
+		v.url("state.redis_url", cfg.State.RedisURL, "redis", "rediss")
+	}
+
+	v.nonNegative("limits.requests_per_minute", cfg.Limits.RequestsPerMinute)
+	v.nonNegative("limits.conversation.max_turns", cfg.Limits.Conversation.MaxTurns)
+	v.nonNegative("limits.conversation.max_tokens", cfg.Limits.Conversation.MaxTokens)
+	v.nonNegative("limits.conversation.ttl_seconds", cfg.Limits.Conversation.TTLSeconds)
+	v.nonNegative("cache.ttl_seconds", cfg.Cache.TTLSeconds)
+	v.nonNegative("idempotency.window_seconds", cfg.Idempotency.WindowSeconds)
+	for i, route := range cfg.Coalesce.Routes {
+		v.oneOf(fmt.Sprintf("coalesce.routes[%d]", i), route, CoalesceRoutes)
+	}
+	v.nonNegative("streams.retention_seconds", cfg.Streams.RetentionSeconds)
+	v.nonNegative("streams.restart_attempts", cfg.Streams.RestartAttempts)
+	v.nonNegative("streams.salvage_attempts", cfg.Streams.SalvageAttempts)
+	if cfg.Judge.Enabled {
+		v.required("judge.model", cfg.Judge.Model)
+	}
+	if cfg.Judge.SampleRate < 0 || cfg.Judge.SampleRate > 1 {
+		v.addf("judge.sample_rate: must be between 0 and 1, got %g", cfg.Judge.SampleRate)
+	}
+	v.injection(&cfg.Injection)
+	v.nonNegative("loops.threshold", cfg.Loops.Threshold)
+	v.nonNegative("loops.window_seconds", cfg.Loops.WindowSeconds)
+	v.fraction("loops.similarity", cfg.Loops.Similarity)
+
+	v.oneOf("parameters.unsupported", cfg.Parameters.Unsupported, ParameterPolicies)
+	for _, name := range slices.Sorted(maps.Keys(cfg.Parameters.Policies)) {
+		v.oneOf("parameters.policies."+name, cfg.Parameters.Policies[name], ParameterPolicies)
+	}
+
+	v.oneOf("pause.in_flight", cfg.Pause.InFlight, PauseInFlightPolicies)
+	v.oneOf("pause.inbound", cfg.Pause.Inbound, PauseInboundPolicies)
+	v.nonNegative("pause.queue_timeout_seconds", cfg.Pause.QueueTimeoutSeconds)
+	v.nonNegative("maintenance.retry_after_seconds", cfg.Maintenance.RetryAfterSeconds)
+	v.startup(cfg)
+
+	v.oneOf("reasoning.mode", cfg.Reasoning.Mode, ReasoningModes)
+	v.oneOf("provenance.mode", cfg.Provenance.Mode, ProvenanceModes)
+
+	if _, err := time.LoadLocation(cfg.Routing.Timezone); err != nil {
+		v.addf("routing.timezone: %v", err)
+	}
+	v.routing(&cfg.Routing, cfg.Providers)
+
+	if cfg.Events.Webhook != "" {
+		v.url("events.webhook", cfg.Events.Webhook, httpSchemes...)
+	}
+
+	v.tags(&cfg.Tags)
+
+	v.nonNegative("updates.interval_hours", cfg.Updates.IntervalHours)
+	if cfg.Updates.URL != "" {
+		v.url("updates.url", cfg.Updates.URL, httpSchemes...)
+	}
+
+	v.usage(&cfg.Usage)
+	v.admin(&cfg.Admin)
+	v.residency(&cfg.Residency, cfg.Providers)
+	if cfg.Privacy.Strict {
+		v.privacy(cfg)
+	}
+
+	return errors.Join(v.errs...)
+}
+
+// ParseFileMode parses an octal permission mode such as "0660".
+func ParseFileMode(value string) (os.FileMode, error) {
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("%q is not an octal permission mode like 0660", value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// validator collects problems instead of stopping at the first one.
+type validator struct {
+	errs []error
+}
+
+func (v *validator) addf(format string, args ...interface{}) {
+	v.errs = append(v.errs, fmt.Errorf(format, args...))
+}
+
+func (v *validator) required(field, value string) {
+	if value == "" {
+		v.addf("%s: required", field)
+	}
+}
+
+func (v *validator) nonNegative(field string, value int64) {
+	if value < 0 {
+		v.addf("%s: must not be negative, got %d", field, value)
+	}
+}
+
+func (v *validator) fraction(field string, value float64) {
+	if value < 0 || value > 1 {
+		v.addf("%s: must be between 0 and 1, got %g", field, value)
+	}
+}
+
+func (v *validator) oneOf(field, value string, allowed []string) {
+	if !slices.Contains(allowed, value) {
+		v.addf("%s: unknown value %q, expected one of %s", field, value, strings.Join(allowed, ", "))
+	}
+}
+
+func (v *validator) injection(inj *InjectionConfig) {
+	v.fraction("injection.flag_threshold", inj.FlagThreshold)
+	v.fraction("injection.block_threshold", inj.BlockThreshold)
+	for i, rule := range inj.Rules {
+		field := fmt.Sprintf("injection.rules[%d]", i)
+		v.required(field+".name", rule.Name)
+		v.required(field+".pattern", rule.Pattern)
+		if _, err := regexp.Compile(rule.Pattern); err != nil {
+			v.addf("%s.pattern: %v", field, err)
+		}
+		if rule.Score <= 0 || rule.Score > 1 {
+			v.addf("%s.score: must be above 0 and at most 1, got %g", field, rule.Score)
+		}
+	}
+}
+
+func (v *validator) tags(t *TagsConfig) {
+	for i, tag := range t.Allowed {
+		field := fmt.Sprintf("tags.allowed[%d]", i)
+		v.required(field, tag)
+		if tag != "" && slices.Index(t.Allowed, tag) < i {
+			v.addf("%s: duplicate tag %q", field, tag)
+		}
+	}
+	v.nonNegative("tags.max_per_request", t.MaxPerRequest)
+	v.nonNegative("tags.max_values", t.MaxValues)
+}
+
+// url checks that value is an absolute URL with one of schemes.
+// Values that are still "${ENV_VAR}" references are resolved later and skipped.
+func (v *validator) url(field, value string, schemes ...string) {
+	if strings.Contains(value, "${") {
+		return
+	}
+	u, err := url.Parse(value)
+	if err != nil {
+		v.addf("%s: invalid URL: %v", field, err)
+		return
+	}
+	if !slices.Contains(schemes, u.Scheme) || u.Host == "" {
+		v.addf("%s: %q must be an absolute %s URL", field, value, strings.Join(schemes, " or "))
+	}
+}
+
+func (v *validator) providers(providers []Provider) {
+	seen := make(map[string]bool, len(providers))
+	for i := range providers {
+		p := &providers[i]
+		field := fmt.Sprintf("providers[%d]", i)
+		if p.Name != "" {
+			field = fmt.Sprintf("providers[%d] (%s)", i, p.Name)
+			if seen[p.Name] {
+				v.addf("%s: duplicate provider name", field)
+			}
+			seen[p.Name] = true
+		}
+		v.provider(field, p)
+	}
+}
+
+func (v *validator) provider(field string, p *Provider) {
+	v.required(field+".name", p.Name)
+	v.oneOf(field+".type", p.Type, ProviderTypes)
+
+	// Regions replace base_url, so it is only required without them
+	v.baseURL(field, p.BaseURL, len(p.Regions) == 0)
+	for j, r := range p.Regions {
+		regionField := fmt.Sprintf("%s.regions[%d]", field, j)
+		v.required(regionField+".name", r.Name)
+		v.baseURL(regionField, r.BaseURL, true)
+	}
+
+	v.faults(field+".faults", &p.Faults)
+	v.oneOf(field+".streaming", p.Streaming, streamingModes)
+	for j, name := range p.CaptureHeaders {
+		v.required(fmt.Sprintf("%s.capture_headers[%d]", field, j), name)
+	}
+
+	v.anthropic(field+".anthropic", p)
+	v.azure(field+".azure", p)
+	v.bedrock(field+".bedrock", p)
+	v.compatible(field+".compatible", p)
+	v.openRouter(field+".openrouter", p)
+	v.tgi(field+".tgi", p)
+	// Bedrock requests are signed before the transport adds the extra query parameters
+	if p.Type == "bedrock" && len(p.ExtraQuery) > 0 {
+		v.addf("%s.extra_query: not supported by bedrock providers, whose requests are signed", field)
+	}
+
+	for _, model := range slices.Sorted(maps.Keys(p.ModelMap)) {
+		if !slices.Contains(p.Models, model) {
+			v.addf("%s.model_map.%s: not one of the provider's models", field, model)
+		}
+		v.required(field+".model_map."+model, p.ModelMap[model])
+	}
+	for _, model := range slices.Sorted(maps.Keys(p.ModelLimits)) {
+		limits, limitsField := p.ModelLimits[model], field+".model_limits."+model
+		v.nonNegative(limitsField+".context_window", limits.ContextWindow)
+		v.nonNegative(limitsField+".max_output_tokens", limits.MaxOutputTokens)
+		if limits.ContextWindow > 0 && limits.MaxOutputTokens > limits.ContextWindow {
+			v.addf("%s: max_output_tokens is above context_window", limitsField)
+		}
+	}
+
+	v.nonNegative(field+".scheduling.max_concurrent", p.Scheduling.MaxConcurrent)
+	for _, tenant := range slices.Sorted(maps.Keys(p.Scheduling.Weights)) {
+		if weight := p.Scheduling.Weights[tenant]; weight <= 0 {
+			v.addf("%s.scheduling.weights.%s: must be positive, got %g", field, tenant, weight)
+		}
+	}
+
+	if p.Throttle.TokensPerSecond < 0 {
+		v.addf("%s.throttle.tokens_per_second: must not be negative, got %g", field, p.Throttle.TokensPerSecond)
+	}
+	for _, tenant := range slices.Sorted(maps.Keys(p.Throttle.Tenants)) {
+		if rate := p.Throttle.Tenants[tenant]; rate < 0 {
+			v.addf("%s.throttle.tenants.%s: must not be negative, got %g", field, tenant, rate)
+		}
+	}
+
+	v.nonNegative(field+".telemetry.interval_seconds", p.Telemetry.IntervalSeconds)
+	v.nonNegative(field+".telemetry.vram_mb", p.Telemetry.VRAMMB)
+	v.nonNegative(field+".telemetry.spill_below_mb", p.Telemetry.SpillBelowMB)
+	if p.Telemetry.IntervalSeconds > 0 && p.Type != "ollama" {
+		v.addf("%s.telemetry: only ollama providers report their resources", field)
+	}
+	if p.Telemetry.SpillBelowMB > 0 && (p.Telemetry.IntervalSeconds <= 0 || p.Telemetry.VRAMMB <= 0) {
+		v.addf("%s.telemetry.spill_below_mb: requires interval_seconds and vram_mb", field)
+	}
+	v.nonNegative(field+".keep_alive.idle_seconds", p.KeepAlive.IdleSeconds)
+	if p.KeepAlive.IdleSeconds > 0 && p.Type != "ollama" {
+		v.addf("%s.keep_alive: only ollama providers unload models", field)
+	}
+	v.speculative(field, p)
+
+	v.oneOf(field+".auth.type", p.Auth.Type, providerAuthTypes)
+	switch p.Auth.Type {
+	case "oauth2":
+		v.required(field+".auth.token_url", p.Auth.TokenURL)
+		v.required(field+".auth.client_id", p.Auth.ClientID)
+	case "azure_ad":
+		v.required(field+".auth.tenant_id", p.Auth.TenantID)
+		v.required(field+".auth.client_id", p.Auth.ClientID)
+	}
+}
+
+func (v *validator) speculative(field string, p *Provider) {
+	if len(p.Speculative) > 0 && p.Type != "openai" && p.Type != "llamacpp" {
+		v.addf("%s.speculative: only openai and llamacpp providers decode speculatively", field)
+	}
+	for _, model := range slices.Sorted(maps.Keys(p.Speculative)) {
+		pair, pairField := p.Speculative[model], field+".speculative."+model
+		if !slices.Contains(p.Models, model) {
+			v.addf("%s: not one of the provider's models", pairField)
+		}
+		if p.Type == "openai" {
+			v.required(pairField+".draft", pair.Draft)
+		}
+		v.nonNegative(pairField+".max_draft_tokens", pair.MaxDraftTokens)
+		v.nonNegative(pairField+".min_draft_tokens", pair.MinDraftTokens)
+		if pair.MaxDraftTokens > 0 && pair.MinDraftTokens > pair.MaxDraftTokens {
+			v.addf("%s: min_draft_tokens is above max_draft_tokens", pairField)
+		}
+		if pair.MinProbability < 0 || pair.MinProbability > 1 {
+			v.addf("%s.min_probability: must be between 0 and 1, got %g", pairField, pair.MinProbability)
+		}
+	}
+}
+
+// azureAPIVersion matches Azure OpenAI API versions, dated and optionally previews
+var azureAPIVersion = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}(-preview)?$`)
+
+func (v *validator) azure(field string, p *Provider) {
+	if p.Type != "azure-openai" {
+		if p.Azure.APIVersion != "" {
+			v.addf("%s: only applies to azure-openai providers", field)
+		}
+		return
+	}
+	if p.Azure.APIVersion != "" && !azureAPIVersion.MatchString(p.Azure.APIVersion) {
+		v.addf("%s.api_version: %q is not a version like %s", field, p.Azure.APIVersion, DefaultAzureAPIVersion)
+	}
+}
+
+func (v *validator) bedrock(field string, p *Provider) {
+	if p.Type != "bedrock" {
+		if p.Bedrock != (ProviderBedrock{}) {
+			v.addf("%s: only applies to bedrock providers", field)
+		}
+		return
+	}
+	v.required(field+".region", p.Bedrock.Region)
+	if (p.Bedrock.AccessKeyID == "") != (p.Bedrock.SecretAccessKey == "") {
+		v.addf("%s: access_key_id and secret_access_key go together", field)
+	}
+}
+
+func (v *validator) compatible(field string, p *Provider) {
+	if p.Type != "openai-compatible" {
+		if p.Compatible != (ProviderCompatible{}) {
+			v.addf("%s: only applies to openai-compatible providers", field)
+		}
+		return
+	}
+	if p.Compatible.PathPrefix != "" && !strings.HasPrefix(p.Compatible.PathPrefix, "/") {
+		v.addf("%s.path_prefix: %q must start with /", field, p.Compatible.PathPrefix)
+	}
+	if endpoint := p.Compatible.ModelsEndpoint; endpoint != "" && !strings.HasPrefix(endpoint, "/") {
+		v.url(field+".models_endpoint", endpoint, httpSchemes...)
+	}
+}
+
+func (v *validator) openRouter(field string, p *Provider) {
+	if p.Type != "openrouter" {
+		if p.OpenRouter != (ProviderOpenRouter{}) {
+			v.addf("%s: only applies to openrouter providers", field)
+		}
+		return
+	}
+	if p.OpenRouter.Referer != "" {
+		v.url(field+".referer", p.OpenRouter.Referer, httpSchemes...)
+	}
+}
+
+func (v *validator) tgi(field string, p *Provider) {
+	if p.Type != "tgi" {
+		if p.TGI != (ProviderTGI{}) {
+			v.addf("%s: only applies to tgi providers", field)
+		}
+		return
+	}
+	v.oneOf(field+".template", p.TGI.Template, TGITemplates)
+}
+
+// anthropicBetaValue matches raw anthropic-beta values, which end in their release date
+var anthropicBetaValue = regexp.MustCompile(`^[a-z0-9]+(-[a-z0-9]+)*-\d{4}-\d{2}-\d{2}$`)
+
+func (v *validator) anthropic(field string, p *Provider) {
+	if p.Type != "anthropic" {
+		if p.Anthropic.Version != "" || len(p.Anthropic.Betas) > 0 {
+			v.addf("%s: only applies to anthropic providers", field)
+		}
+		return
+	}
+	if _, err := time.Parse(time.DateOnly, p.Anthropic.Version); p.Anthropic.Version != "" && err != nil {
+		v.addf("%s.version: %q is not a version date like %s", field, p.Anthropic.Version, DefaultAnthropicVersion)
+	}
+	for i, beta := range p.Anthropic.Betas {
+		if _, known := AnthropicBetas[beta]; !known && !anthropicBetaValue.MatchString(beta) {
+			v.addf("%s.betas[%d]: unknown beta %q, expected one of %s or a dated header value",
+				field, i, beta, strings.Join(slices.Sorted(maps.Keys(AnthropicBetas)), ", "))
+		}
+	}
+}
+
+func (v *validator) faults(field string, f *ProviderFaults) {
+	rates := []struct {
+		name  string
+		value float64
+	}{
+		{"latency_rate", f.LatencyRate},
+		{"rate_limit_rate", f.RateLimitRate},
+		{"error_rate", f.ErrorRate},
+		{"disconnect_rate", f.DisconnectRate},
+	}
+	for _, rate := range rates {
+		if rate.value < 0 || rate.value > 1 {
+			v.addf("%s.%s: must be between 0 and 1, got %g", field, rate.name, rate.value)
+		}
+	}
+	v.nonNegative(field+".latency_ms", f.LatencyMS)
+}
+
+func (v *validator) baseURL(field, value string, required bool) {
+	field += ".base_url"
+	if value == "" {
+		if required {
+			v.required(field, value)
+		}
+		return
+	}
+	v.url(field, value, httpSchemes...)
+}
+
+// startup checks that each startup dependency waits for exactly one configured provider, MCP server
+// or URL.
+func (v *validator) startup(cfg *Config) {
+	for i, d := range cfg.Startup.Dependencies {
+		field := fmt.Sprintf("startup.dependencies[%d]", i)
+		set := 0
+		for _, target := range []string{d.Provider, d.MCPServer, d.URL} {
+			if target != "" {
+				set++
+			}
+		}
+		if set != 1 {
+			v.addf("%s: exactly one of provider, mcp_server and url is required", field)
+		}
+		provider := func(p Provider) bool { return p.Name == d.Provider }
+		if d.Provider != "" && !slices.ContainsFunc(cfg.Providers, provider) {
+			v.addf("%s.provider: no provider is named %q", field, d.Provider)
+		}
+		mcpServer := func(s MCPServer) bool { return s.Name == d.MCPServer }
+		if d.MCPServer != "" && !slices.ContainsFunc(cfg.MCP.Servers, mcpServer) {
+			v.addf("%s.mcp_server: no MCP server is named %q", field, d.MCPServer)
+		}
+		if d.URL != "" {
+			v.url(field+".url", d.URL, httpSchemes...)
+		}
+		v.nonNegative(field+".timeout_seconds", d.TimeoutSeconds)
+	}
+}
+
+func (v *validator) mcp(cfg *MCPConfig) {
+	for i, s := range cfg.Servers {
+		field := fmt.Sprintf("mcp.servers[%d]", i)
+		v.required(field+".name", s.Name)
+		v.required(field+".command", s.Command)
+	}
+	v.nonNegative("mcp.capabilities.ttl_seconds", cfg.Capabilities.TTLSeconds)
+	for i, rule := range cfg.Approvals.Rules {
+		field := fmt.Sprintf("mcp.approvals.rules[%d]", i)
+		v.required(field+".tool", rule.Tool)
+		if _, err := regexp.Compile(rule.Tool); err != nil {
+			v.addf("%s.tool: %v", field, err)
+		}
+		for _, arg := range slices.Sorted(maps.Keys(rule.Arguments)) {
+			if _, err := regexp.Compile(rule.Arguments[arg]); err != nil {
+				v.addf("%s.arguments.%s: %v", field, arg, err)
+			}
+		}
+	}
+	v.nonNegative("mcp.approvals.timeout_seconds", cfg.Approvals.TimeoutSeconds)
+}
+
+func (v *validator) usage(cfg *UsageConfig) {
+	if cfg.Endpoint != "" {
+		v.url("usage.endpoint", cfg.Endpoint, httpSchemes...)
+	}
+	v.nonNegative("usage.interval_seconds", cfg.IntervalSeconds)
+	for model, price := range cfg.Prices {
+		if price.Input < 0 || price.Output < 0 {
+			v.addf("usage.prices.%s: prices must not be negative", model)
+		}
+	}
+}
+
+func (v *validator) admin(cfg *AdminConfig) {
+	for i, t := range cfg.Tokens {
+		field := fmt.Sprintf("admin.tokens[%d]", i)
+		v.required(field+".token", t.Token)
+		v.oneOf(field+".role", t.Role, adminRoles)
+	}
+
+	if cfg.OIDC.Issuer != "" {
+		v.url("admin.oidc.issuer", cfg.OIDC.Issuer, httpSchemes...)
+		v.required("admin.oidc.audience", cfg.OIDC.Audience)
+		v.required("admin.oidc.role_claim", cfg.OIDC.RoleClaim)
+		if len(cfg.OIDC.ViewerValues) == 0 && len(cfg.OIDC.OperatorValues) == 0 {
+			v.addf("admin.oidc: viewer_values or operator_values is required to grant any role")
+		}
+	}
+}
+
+// residency checks that every required jurisdiction is served by some provider,
+// since a requirement nothing satisfies would refuse all of a tenant's requests.
+func (v *validator) residency(cfg *ResidencyConfig, providers []Provider) {
+	served := make(map[string]bool, len(providers))
+	for _, p := range providers {
+		if p.Jurisdiction != "" {
+			served[p.Jurisdiction] = true
+		}
+	}
+	check := func(field string, jurisdictions []string) {
+		for _, j := range jurisdictions {
+			if !served[j] {
+				v.addf("%s: no provider is in jurisdiction %q", field, j)
+			}
+		}
+	}
+
+	check("residency.default", cfg.Default)
+	tenants := slices.Sorted(maps.Keys(cfg.Tenants))
+	for _, tenant := range tenants {
+		check("residency.tenants."+tenant, cfg.Tenants[tenant])
+	}
+}
+
+// routing checks that every rule chooses something and names only configured providers.
+func (v *validator) routing(cfg *RoutingConfig, providers []Provider) {
+	for i := range cfg.Rules {
+		rule := &cfg.Rules[i]
+		field := fmt.Sprintf("routing.rules[%d]", i)
+		if rule.Name != "" {
+			field = fmt.Sprintf("routing.rules[%d] (%s)", i, rule.Name)
+		}
+
+		if rule.Model == "" && rule.Provider == "" && len(rule.Params) == 0 {
+			v.addf("%s: one of model, provider and params is required", field)
+		}
+		if rule.Provider != "" && !slices.ContainsFunc(providers, func(p Provider) bool { return p.Name == rule.Provider }) {
+			v.addf("%s.provider: no provider is named %q", field, rule.Provider)
+		}
+		v.nonNegative(field+".match.min_prompt_tokens", rule.Match.MinPromptTokens)
+		v.nonNegative(field+".match.max_prompt_tokens", rule.Match.MaxPromptTokens)
+		if rule.Match.MaxPromptTokens > 0 && rule.Match.MinPromptTokens > rule.Match.MaxPromptTokens {
+			v.addf("%s.match: min_prompt_tokens is above max_prompt_tokens", field)
+		}
+		if _, _, _, err := rule.Match.HoursWindow(); err != nil {
+			v.addf("%s.match.hours: %v", field, err)
+		}
+		for j, day := range rule.Match.Days {
+			v.oneOf(fmt.Sprintf("%s.match.days[%d]", field, j), day, Weekdays)
+		}
+		v.nonNegative(field+".match.min_in_flight", rule.Match.MinInFlight)
+		v.nonNegative(field+".match.max_in_flight", rule.Match.MaxInFlight)
+		if rule.Match.MaxInFlight > 0 && rule.Match.MinInFlight > rule.Match.MaxInFlight {
+			v.addf("%s.match: min_in_flight is above max_in_flight", field)
+		}
+	}
+
+	for _, model := range slices.Sorted(maps.Keys(cfg.Models)) {
+		v.modelRoute("routing.models."+model, cfg.Models[model], providers)
+	}
+}
+
+// modelRoute checks that a route has targets to send requests to and names only configured providers.
+func (v *validator) modelRoute(field string, route ModelRoute, providers []Provider) {
+	configured := func(name string) bool {
+		return slices.ContainsFunc(providers, func(p Provider) bool { return p.Name == name })
+	}
+
+	if len(route.Targets) == 0 {
+		v.addf("%s.targets: at least one target is required", field)
+	}
+	seen := make(map[string]bool, len(route.Targets))
+	for i, target := range route.Targets {
+		targetField := fmt.Sprintf("%s.targets[%d]", field, i)
+		switch {
+		case target.Provider == "":
+			v.addf("%s.provider: required", targetField)
+		case !configured(target.Provider):
+			v.addf("%s.provider: no provider is named %q", targetField, target.Provider)
+		case seen[target.Provider]:
+			v.addf("%s.provider: duplicate target %q", targetField, target.Provider)
+		}
+		seen[target.Provider] = true
+		if target.Weight <= 0 {
+			v.addf("%s.weight: must be positive, got %d", targetField, target.Weight)
+		}
+	}
+	for i, name := range route.Failover {
+		if !configured(name) {
+			v.addf("%s.failover[%d]: no provider is named %q", field, i, name)
+		}
+	}
+}
+
+// Deprecations describes the settings of cfg that still work but have a replacement, one line each.
+func Deprecations(cfg *Config) []string {
+	var deprecations []string
+	for i, p := range cfg.Providers {
+		if p.Priority != 0 {
+			deprecations = append(deprecations, fmt.Sprintf(
+				"providers[%d] (%s).priority: deprecated, route models with routing.models targets and failover instead",
+				i, p.Name))
+		}
+	}
+	return deprecations
+}
+
+// privacy rejects features that retain request or response content, which strict privacy mode forbids.
+func (v *validator) privacy(cfg *Config) {
+	if cfg.Cache.Enabled {
+		v.addf("cache.enabled: not allowed in strict privacy mode, cached responses retain content")
+	}
+	if cfg.Streams.Resumable {
+		v.addf("streams.resumable: not allowed in strict privacy mode, resumable streams buffer content")
+	}
+	if cfg.Audit.Transcripts {
+		v.addf("audit.transcripts: not allowed in strict privacy mode, transcripts retain content")
+	}
+}
@@ -0,0 +1,259 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate_ExampleConfig(t *testing.T) {
+	cfg, err := Load("../../config.toml")
+	require.NoError(t, err)
+
+	ApplyDefaults(cfg)
+	assert.NoError(t, Validate(cfg))
+}
+
+func TestValidate_ReportsEveryProblem(t *testing.T) {
+	cfg := &Config{
+		Providers: []Provider{
+			{
+				Name: "openai", Type: "openai", BaseURL: "https://api.openai.com/v1",
+				Scheduling: ProviderScheduling{MaxConcurrent: -1, Weights: map[string]float64{"acme": 0}},
+				Throttle:   ProviderThrottle{TokensPerSecond: -5, Tenants: map[string]float64{"batch": -1, "ci": 0}},
+				Telemetry:  ProviderTelemetry{IntervalSeconds: 10, VRAMMB: -1, SpillBelowMB: 1024},
+				KeepAlive:  ProviderKeepAlive{IdleSeconds: 300},
+				Speculative: map[string]SpeculativeDecoding{
+					"gpt-4":   {MaxDraftTokens: 4, MinDraftTokens: 8},
+					"gpt-4.5": {Draft: "gpt-4o-mini", MinProbability: 1.5},
+				},
+				Models:   []string{"gpt-4"},
+				ModelMap: map[string]string{"gpt-4": "", "gpt-4o": "llama3.1"},
+				ModelLimits: map[string]ModelLimits{
+					"gpt-4":  {ContextWindow: 8192, MaxOutputTokens: 16384},
+					"gpt-4o": {ContextWindow: -1},
+				},
+			},
+			{
+				Name: "openai", Type: "gpt", BaseURL: "api.example.com",
+				Anthropic: ProviderAnthropic{Betas: []string{"context_1m"}}, Azure: ProviderAzure{APIVersion: "2024-10-21"},
+				Bedrock: ProviderBedrock{Region: "us-east-1"}, Compatible: ProviderCompatible{PathPrefix: "/v1"},
+				OpenRouter: ProviderOpenRouter{Title: "agent"}, TGI: ProviderTGI{Template: "chatml"},
+			},
+			{
+				Type:           "anthropic",
+				Anthropic:      ProviderAnthropic{Version: "v1", Betas: []string{"prompt_caching", "caching"}},
+				Streaming:      "sometimes",
+				CaptureHeaders: []string{"x-request-id", ""},
+			},
+			{
+				Name:    "azure",
+				Type:    "openai",
+				Regions: []ProviderRegion{{Name: "eastus", BaseURL: "https://eastus.example.com"}, {}},
+				Auth:    ProviderAuth{Type: "azure_ad"},
+			},
+			{
+				Name: "foundry", Type: "azure-openai", BaseURL: "https://example.openai.azure.com", Models: []string{"gpt-4o"},
+				Azure: ProviderAzure{APIVersion: "latest"},
+			},
+			{
+				Name: "claude", Type: "bedrock", Models: []string{"anthropic.claude-3-5-sonnet-20240620-v1:0"},
+				Bedrock: ProviderBedrock{AccessKeyID: "AKIDEXAMPLE"}, ExtraQuery: map[string]string{"trace": "1"},
+			},
+			{
+				Name: "vllm", Type: "openai-compatible", BaseURL: "http://gpu:8000",
+				Compatible: ProviderCompatible{PathPrefix: "v1", ModelsEndpoint: "gpu:8000/v1/models"},
+			},
+			{Name: "openrouter", Type: "openrouter", OpenRouter: ProviderOpenRouter{Referer: "example.com"}},
+			{Name: "tgi", Type: "tgi", BaseURL: "http://gpu:8080", TGI: ProviderTGI{Template: "alpaca"}},
+		},
+		MCP: MCPConfig{
+			Servers: []MCPServer{{Name: "fs"}}, Capabilities: MCPCapabilities{Required: true, TTLSeconds: -60},
+			Approvals: MCPApprovals{Rules: []ApprovalRule{
+				{Tool: "delete_.*", Arguments: map[string]string{"path": "/etc/.*"}},
+				{Arguments: map[string]string{"cmd": "rm ("}},
+			}},
+		},
+		Server:    Server{LogLevel: "loud", MaxRequestSize: -1, SocketMode: "rw-rw----"},
+		State:     StateConfig{Backend: "etcd", RedisURL: "localhost:6379"},
+		Limits:    Limits{RequestsPerMinute: -5, Conversation: ConversationLimits{MaxTurns: -1}},
+		Coalesce:  CoalesceConfig{Enabled: true, Routes: []string{"chat/completions", "embeddings"}},
+		Streams:   StreamsConfig{Resumable: true, RetentionSeconds: -1, RestartAttempts: -1, SalvageAttempts: -1},
+		Judge:     JudgeConfig{Enabled: true, SampleRate: 1.5},
+		Injection: InjectionConfig{BlockThreshold: 2, Rules: []InjectionRule{{Name: "exfil", Pattern: "curl (", Score: 0}}},
+		Loops:     LoopsConfig{Threshold: -1, Similarity: 1.2},
+		Residency: ResidencyConfig{Tenants: map[string][]string{"acme": {"eu"}}},
+		Privacy:   PrivacyConfig{Strict: true},
+		Audit:     AuditConfig{Path: "audit.jsonl", Transcripts: true},
+		Parameters: ParametersConfig{
+			Policies: map[string]string{"logit_bias": "reject", "seed": "ignore"},
+		},
+		Pause:       PauseConfig{Inbound: "drop", QueueTimeoutSeconds: -1},
+		Maintenance: MaintenanceConfig{RetryAfterSeconds: -60},
+		Startup: StartupConfig{Dependencies: []StartupDependency{
+			{Provider: "ollama", URL: "http://localhost:11434"},
+			{MCPServer: "git", TimeoutSeconds: -1},
+			{MCPServer: "fs"},
+		}},
+		Reasoning:  ReasoningConfig{Mode: "hide"},
+		Provenance: ProvenanceConfig{Mode: "trailer"},
+		Routing: RoutingConfig{Timezone: "Mars/Olympus", Rules: []RoutingRule{
+			{Name: "noop"},
+			{Match: RoutingMatch{MinPromptTokens: 100, MaxPromptTokens: 10, Hours: "9-17"}, Provider: "gemini"},
+			{Match: RoutingMatch{Days: []string{"mon", "monday"}, MinInFlight: 5, MaxInFlight: 2}, Model: "gpt-4"},
+		}, Models: map[string]ModelRoute{
+			"gpt-4": {
+				Targets: []RouteTarget{
+					{Provider: "openai", Weight: 70}, {Provider: "gemini", Weight: 0}, {Provider: "openai", Weight: 30},
+				},
+				Failover: []string{"openai", "backup"},
+			},
+			"gpt-4o": {},
+		}},
+		Events:  EventsConfig{Webhook: "hooks.example.com"},
+		Tags:    TagsConfig{Allowed: []string{"team", "", "team"}, MaxValues: -1},
+		Updates: UpdatesConfig{IntervalHours: -1, URL: "ftp://example.com/latest"},
+		Usage:   UsageConfig{Endpoint: "${METER_URL}", Prices: map[string]ModelPrice{"gpt-4": {Input: -1}}},
+		Admin: AdminConfig{
+			Tokens: []AdminToken{{Token: "t", Role: "root"}},
+			OIDC:   OIDCConfig{Issuer: "https://issuer.example.com"},
+		},
+	}
+	ApplyDefaults(cfg)
+
+	err := Validate(cfg)
+	require.Error(t, err)
+
+	var problems []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		problems = append(problems, e.Error())
+	}
+
+	expected := []string{
+		"providers[0] (openai).model_map.gpt-4: required",
+		"providers[0] (openai).model_map.gpt-4o: not one of the provider's models",
+		"providers[0] (openai).model_limits.gpt-4: max_output_tokens is above context_window",
+		"providers[0] (openai).model_limits.gpt-4o.context_window: must not be negative, got -1",
+		"providers[0] (openai).scheduling.max_concurrent: must not be negative, got -1",
+		"providers[0] (openai).scheduling.weights.acme: must be positive, got 0",
+		"providers[0] (openai).throttle.tokens_per_second: must not be negative, got -5",
+		"providers[0] (openai).throttle.tenants.batch: must not be negative, got -1",
+		"providers[0] (openai).telemetry.vram_mb: must not be negative, got -1",
+		"providers[0] (openai).telemetry: only ollama providers report their resources",
+		"providers[0] (openai).telemetry.spill_below_mb: requires interval_seconds and vram_mb",
+		"providers[0] (openai).keep_alive: only ollama providers unload models",
+		"providers[0] (openai).speculative.gpt-4.draft: required",
+		"providers[0] (openai).speculative.gpt-4: min_draft_tokens is above max_draft_tokens",
+		"providers[0] (openai).speculative.gpt-4.5: not one of the provider's models",
+		"providers[0] (openai).speculative.gpt-4.5.min_probability: must be between 0 and 1, got 1.5",
+		"providers[1] (openai): duplicate provider name",
+		`providers[1] (openai).type: unknown value "gpt", expected one of openai, anthropic, ollama, llamacpp, cohere, tei, voyage, jina, azure-openai, bedrock, openai-compatible, mistral, groq, openrouter, tgi, xai`,
+		`providers[1] (openai).base_url: "api.example.com" must be an absolute http or https URL`,
+		"providers[1] (openai).anthropic: only applies to anthropic providers",
+		"providers[1] (openai).azure: only applies to azure-openai providers",
+		"providers[1] (openai).bedrock: only applies to bedrock providers",
+		"providers[1] (openai).compatible: only applies to openai-compatible providers",
+		"providers[1] (openai).openrouter: only applies to openrouter providers",
+		"providers[1] (openai).tgi: only applies to tgi providers",
+		"providers[2].name: required",
+		"providers[2].base_url: required",
+		`providers[2].streaming: unknown value "sometimes", expected one of , unsupported, required`,
+		"providers[2].capture_headers[1]: required",
+		`providers[2].anthropic.version: "v1" is not a version date like 2023-06-01`,
+		`providers[2].anthropic.betas[1]: unknown beta "caching", expected one of context_1m, files_api, ` +
+			`interleaved_thinking, output_128k, prompt_caching, token_efficient_tools or a dated header value`,
+		"providers[3] (azure).regions[1].name: required",
+		"providers[3] (azure).regions[1].base_url: required",
+		"providers[3] (azure).auth.tenant_id: required",
+		"providers[3] (azure).auth.client_id: required",
+		`providers[4] (foundry).azure.api_version: "latest" is not a version like 2024-10-21`,
+		"providers[5] (claude).base_url: required",
+		"providers[5] (claude).bedrock.region: required",
+		"providers[5] (claude).bedrock: access_key_id and secret_access_key go together",
+		"providers[5] (claude).extra_query: not supported by bedrock providers, whose requests are signed",
+		`providers[6] (vllm).compatible.path_prefix: "v1" must start with /`,
+		`providers[6] (vllm).compatible.models_endpoint: "gpu:8000/v1/models" must be an absolute http or https URL`,
+		`providers[7] (openrouter).openrouter.referer: "example.com" must be an absolute http or https URL`,
+		`providers[8] (tgi).tgi.template: unknown value "alpaca", expected one of chatml, llama3, mistral`,
+		"mcp.servers[0].command: required",
+		"mcp.capabilities.ttl_seconds: must not be negative, got -60",
+		"mcp.approvals.rules[1].tool: required",
+		"mcp.approvals.rules[1].arguments.cmd: error parsing regexp: missing closing ): `rm (`",
+		`server.log_level: unknown level "loud", expected debug, info, warn or error`,
+		"server.max_request_size: must not be negative, got -1",
+		`server.socket_mode: "rw-rw----" is not an octal permission mode like 0660`,
+		`state.backend: unknown value "etcd", expected one of memory, redis`,
+		`state.redis_url: "localhost:6379" must be an absolute redis or rediss URL`,
+		"limits.requests_per_minute: must not be negative, got -5",
+		"limits.conversation.max_turns: must not be negative, got -1",
+		`coalesce.routes[1]: unknown value "embeddings", expected one of chat/completions, completions`,
+		"streams.retention_seconds: must not be negative, got -1",
+		"streams.restart_attempts: must not be negative, got -1",
+		"streams.salvage_attempts: must not be negative, got -1",
+		"judge.model: required",
+		"judge.sample_rate: must be between 0 and 1, got 1.5",
+		"injection.block_threshold: must be between 0 and 1, got 2",
+		"injection.rules[0].pattern: error parsing regexp: missing closing ): `curl (`",
+		"injection.rules[0].score: must be above 0 and at most 1, got 0",
+		"loops.threshold: must not be negative, got -1",
+		"loops.similarity: must be between 0 and 1, got 1.2",
+		`parameters.policies.seed: unknown value "ignore", expected one of warn, reject, emulate`,
+		`pause.inbound: unknown value "drop", expected one of queue, reject`,
+		"pause.queue_timeout_seconds: must not be negative, got -1",
+		"maintenance.retry_after_seconds: must not be negative, got -60",
+		"startup.dependencies[0]: exactly one of provider, mcp_server and url is required",
+		`startup.dependencies[0].provider: no provider is named "ollama"`,
+		`startup.dependencies[1].mcp_server: no MCP server is named "git"`,
+		"startup.dependencies[1].timeout_seconds: must not be negative, got -1",
+		`reasoning.mode: unknown value "hide", expected one of expose, strip, passthrough`,
+		`provenance.mode: unknown value "trailer", expected one of , headers, body, both`,
+		"routing.timezone: unknown time zone Mars/Olympus",
+		"routing.rules[0] (noop): one of model, provider and params is required",
+		`routing.rules[1].provider: no provider is named "gemini"`,
+		"routing.rules[1].match: min_prompt_tokens is above max_prompt_tokens",
+		`routing.rules[1].match.hours: invalid time "9", expected HH:MM`,
+		`routing.rules[2].match.days[1]: unknown value "monday", expected one of sun, mon, tue, wed, thu, fri, sat`,
+		"routing.rules[2].match: min_in_flight is above max_in_flight",
+		`routing.models.gpt-4.targets[1].provider: no provider is named "gemini"`,
+		"routing.models.gpt-4.targets[1].weight: must be positive, got 0",
+		`routing.models.gpt-4.targets[2].provider: duplicate target "openai"`,
+		`routing.models.gpt-4.failover[1]: no provider is named "backup"`,
+		"routing.models.gpt-4o.targets: at least one target is required",
+		`events.webhook: "hooks.example.com" must be an absolute http or https URL`,
+		"tags.allowed[1]: required",
+		`tags.allowed[2]: duplicate tag "team"`,
+		"tags.max_values: must not be negative, got -1",
+		"updates.interval_hours: must not be negative, got -1",
+		`updates.url: "ftp://example.com/latest" must be an absolute http or https URL`,
+		"usage.prices.gpt-4: prices must not be negative",
+		`admin.tokens[0].role: unknown value "root", expected one of viewer, operator`,
+		"admin.oidc.audience: required",
+		"admin.oidc.role_claim: required",
+		"admin.oidc: viewer_values or operator_values is required to grant any role",
+		`residency.tenants.acme: no provider is in jurisdiction "eu"`,
+		"streams.resumable: not allowed in strict privacy mode, resumable streams buffer content",
+		"audit.transcripts: not allowed in strict privacy mode, transcripts retain content",
+	}
+	assert.Equal(t, expected, problems)
+}
+
+func TestValidate_Defaults(t *testing.T) {
+	cfg := &Config{
+		Providers: []Provider{{Name: "local", Type: "ollama", BaseURL: "http://localhost:11434"}},
+	}
+
+	assert.Error(t, Validate(cfg), "log level and state backend are unset before defaults")
+
+	ApplyDefaults(cfg)
+	require.NoError(t, Validate(cfg))
+	assert.Equal(t, int64(DefaultMaxRequestSize), cfg.Server.MaxRequestSize)
+}
+
+func TestDeprecations(t *testing.T) {
+	cfg := &Config{Providers: []Provider{{Name: "openai", Priority: 1}, {Name: "local"}}}
+
+	assert.Equal(t, []string{
+		"providers[0] (openai).priority: deprecated, route models with routing.models targets and failover instead",
+	}, Deprecations(cfg))
+}
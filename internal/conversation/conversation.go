@@ -0,0 +1,219 @@
+// Package conversation moves the state modelplex stores about a conversation, the requests carrying
+// the same conversation_id metadata value, between instances, so an agent whose sandbox migrates
+// keeps its context and budget. The state is the conversation's history, the audit journal's records
+// of its requests with their transcripts when kept, and what it used of its budget.
+//
+// An export is a blob signed with a secret the instances share: the base64 of the state's JSON and
+// its HMAC-SHA256. It is signed, not encrypted, so whoever holds it can read the transcripts. An
+// import raises the budget counts to the exported ones and keeps the history in the state backend,
+// where a later export picks it up, so a conversation can move on again without losing it.
+package conversation
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/audit"
+	"github.com/modelplex/modelplex/internal/budget"
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/state"
+)
+
+// prefix marks exports, so they can't be mistaken for other signed values such as capability tokens
+const prefix = "mconv."
+
+// ErrInvalid is returned for an export that is malformed or wasn't signed with the secret.
+var ErrInvalid = errors.New("invalid conversation export")
+
+// State is what modelplex stores about a conversation.
+type State struct {
+	ConversationID string    `json:"conversation_id"`
+	ExportedAt     time.Time `json:"exported_at"`
+	// Budget is what the conversation used of its budget; nil when conversations aren't limited
+	Budget *Usage `json:"budget,omitempty"`
+	// History is the audit records of the conversation's requests, oldest first
+	History []audit.Record `json:"history"`
+}
+
+// Usage is what a conversation used of its budget.
+type Usage struct {
+	Turns  int64 `json:"turns"`
+	Tokens int64 `json:"tokens"`
+}
+
+// Migrator exports and imports the state of conversations.
+type Migrator struct {
+	secret []byte
+	store  state.Store
+	// budget is nil unless conversations are limited, journal unless requests are journaled
+	budget  *budget.Budget
+	journal *audit.Log
+	// ttl is how long imported history is kept
+	ttl time.Duration
+	now func() time.Time
+}
+
+// NewMigrator creates a migrator signing exports with secret, keeping imported history in store
+// for ttl.
+func NewMigrator(secret string, store state.Store, b *budget.Budget, journal *audit.Log, ttl time.Duration) *Migrator {
+	return &Migrator{
+		secret:  []byte(secret),
+		store:   store,
+		budget:  b,
+		journal: journal,
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// Export returns the signed export of conversation and the state it holds.
+func (m *Migrator) Export(ctx context.Context, conversation string) (string, *State, error) {
+	history, err := m.imported(ctx, conversation)
+	if err != nil {
+		return "", nil, err
+	}
+	if m.journal != nil {
+		records, err := m.journal.Records(&erasure.Subject{ConversationID: conversation})
+		if err != nil {
+			return "", nil, err
+		}
+		// A conversation that moved back and forth holds records of this journal in its imported history
+		for _, record := range records {
+			if !slices.ContainsFunc(history, func(r audit.Record) bool { return r.Hash == record.Hash }) {
+				history = append(history, record)
+			}
+		}
+	}
+
+	exported := &State{ConversationID: conversation, ExportedAt: m.now().UTC(), History: history}
+	if m.budget != nil {
+		turns, tokens, err := m.budget.Used(ctx, conversation)
+		if err != nil {
+			return "", nil, err
+		}
+		exported.Budget = &Usage{Turns: turns, Tokens: tokens}
+	}
+	payload, err := json.Marshal(exported)
+	if err != nil {
+		return "", nil, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return prefix + encoded + "." + m.sign(encoded), exported, nil
+}
+
+// Import restores the state of an export: the budget counts, when conversations are limited here,
+// and the history. It returns the state, whose Budget is nil when the counts weren't restored and
+// whose History is empty when the history couldn't be kept, as in strict privacy mode.
+func (m *Migrator) Import(ctx context.Context, export string) (*State, error) {
+	imported, err := m.verify(export)
+	if err != nil {
+		return nil, err
+	}
+
+	if imported.Budget != nil && m.budget != nil {
+		err := m.budget.Restore(ctx, imported.ConversationID, imported.Budget.Turns, imported.Budget.Tokens)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		imported.Budget = nil
+	}
+
+	if len(imported.History) > 0 {
+		data, err := json.Marshal(imported.History)
+		if err != nil {
+			return nil, err
+		}
+		err = m.store.Set(ctx, historyKey(imported.ConversationID), data, m.ttl)
+		switch {
+		case errors.Is(err, state.ErrRetentionDisabled):
+			slog.Warn("Imported conversation history not kept in strict privacy mode",
+				"conversation_id", imported.ConversationID)
+			imported.History = nil
+		case err != nil:
+			return nil, err
+		}
+	}
+	return imported, nil
+}
+
+// Forget drops the imported history subject matches, returning how many records were dropped.
+// Imported history is looked up by conversation, so subjects without a conversation id match none.
+func (m *Migrator) Forget(ctx context.Context, subject *erasure.Subject) (int, error) {
+	if subject.ConversationID == "" {
+		return 0, nil
+	}
+	history, err := m.imported(ctx, subject.ConversationID)
+	if err != nil || len(history) == 0 {
+		return 0, err
+	}
+
+	kept := slices.DeleteFunc(slices.Clone(history), func(r audit.Record) bool {
+		return subject.Matches(r.Tenant, r.Metadata)
+	})
+	forgotten := len(history) - len(kept)
+	switch {
+	case forgotten == 0:
+		return 0, nil
+	case len(kept) == 0:
+		err = m.store.Delete(ctx, historyKey(subject.ConversationID))
+	default:
+		var data []byte
+		if data, err = json.Marshal(kept); err == nil {
+			err = m.store.Set(ctx, historyKey(subject.ConversationID), data, m.ttl)
+		}
+	}
+	if err != nil {
+		return 0, err
+	}
+	return forgotten, nil
+}
+
+// imported returns the history imported for conversation.
+func (m *Migrator) imported(ctx context.Context, conversation string) ([]audit.Record, error) {
+	data, ok, err := m.store.Get(ctx, historyKey(conversation))
+	if err != nil || !ok {
+		return nil, err
+	}
+	var history []audit.Record
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, err
+	}
+	return history, nil
+}
+
+// verify returns the state of export, or ErrInvalid.
+func (m *Migrator) verify(export string) (*State, error) {
+	encoded, signature, ok := strings.Cut(strings.TrimPrefix(export, prefix), ".")
+	if !ok || !strings.HasPrefix(export, prefix) || !hmac.Equal([]byte(signature), []byte(m.sign(encoded))) {
+		return nil, ErrInvalid
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalid
+	}
+	var imported State
+	if err := json.Unmarshal(payload, &imported); err != nil || imported.ConversationID == "" {
+		return nil, ErrInvalid
+	}
+	return &imported, nil
+}
+
+func (m *Migrator) sign(encoded string) string {
+	mac := hmac.New(sha256.New, m.secret)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// historyKey returns the key the imported history of conversation is kept at.
+func historyKey(conversation string) string {
+	return "conversation:" + conversation + ":history"
+}
@@ -0,0 +1,126 @@
+package conversation
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/audit"
+	"github.com/modelplex/modelplex/internal/budget"
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/state"
+)
+
+var limits = &config.ConversationLimits{MaxTurns: 10, MaxTokens: 1000, TTLSeconds: 3600}
+
+// newInstance returns the migrator of an instance with its own state and journal, and its budget.
+func newInstance(t *testing.T, store state.Store) (*Migrator, *budget.Budget) {
+	t.Helper()
+	journal, err := audit.Open(filepath.Join(t.TempDir(), "audit.jsonl"), true)
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = journal.Close() })
+	b := budget.New(store, limits)
+	return NewMigrator("shared-secret", store, b, journal, time.Hour), b
+}
+
+func TestMigrator_MovesConversations(t *testing.T) {
+	source, sourceBudget := newInstance(t, state.NewMemoryStore())
+	for _, conversation := range []string{"c-1", "c-2", "c-1"} {
+		require.NoError(t, source.journal.Append(audit.Record{
+			Tenant: "acme", Operation: audit.OperationChat, Model: "gpt-4",
+			Metadata: map[string]string{"conversation_id": conversation}, Transcript: []byte(`{"request":"Hi"}`),
+		}))
+	}
+	require.NoError(t, sourceBudget.Admit(t.Context(), "c-1"))
+	require.NoError(t, sourceBudget.Spend(t.Context(), "c-1", 42))
+
+	export, exported, err := source.Export(t.Context(), "c-1")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(export, "mconv."))
+	assert.Equal(t, &Usage{Turns: 1, Tokens: 42}, exported.Budget)
+	require.Len(t, exported.History, 2)
+
+	target, targetBudget := newInstance(t, state.NewMemoryStore())
+	imported, err := target.Import(t.Context(), export)
+	require.NoError(t, err)
+	assert.Equal(t, "c-1", imported.ConversationID)
+	assert.Len(t, imported.History, 2)
+	turns, tokens, err := targetBudget.Used(t.Context(), "c-1")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{1, 42}, []int64{turns, tokens})
+
+	// The conversation goes on here, then moves back
+	require.NoError(t, target.journal.Append(audit.Record{
+		Tenant: "acme", Operation: audit.OperationChat, Model: "gpt-4",
+		Metadata: map[string]string{"conversation_id": "c-1"},
+	}))
+	export, _, err = target.Export(t.Context(), "c-1")
+	require.NoError(t, err)
+	_, err = source.Import(t.Context(), export)
+	require.NoError(t, err)
+	_, exported, err = source.Export(t.Context(), "c-1")
+	require.NoError(t, err)
+	assert.Len(t, exported.History, 3, "records of the source's own journal aren't repeated")
+}
+
+func TestMigrator_RejectsForgedExports(t *testing.T) {
+	source, _ := newInstance(t, state.NewMemoryStore())
+	export, _, err := source.Export(t.Context(), "c-1")
+	require.NoError(t, err)
+
+	other := NewMigrator("other-secret", state.NewMemoryStore(), nil, nil, time.Hour)
+	_, err = other.Import(t.Context(), export)
+	assert.ErrorIs(t, err, ErrInvalid, "signed with another secret")
+	_, err = source.Import(t.Context(), strings.Replace(export, "mconv.e", "mconv.f", 1))
+	assert.ErrorIs(t, err, ErrInvalid, "altered")
+	_, err = source.Import(t.Context(), "mcap."+strings.TrimPrefix(export, "mconv."))
+	assert.ErrorIs(t, err, ErrInvalid, "not an export")
+}
+
+func TestMigrator_ImportWithoutRetention(t *testing.T) {
+	source, _ := newInstance(t, state.NewMemoryStore())
+	require.NoError(t, source.journal.Append(audit.Record{
+		Tenant: "acme", Operation: audit.OperationChat, Metadata: map[string]string{"conversation_id": "c-1"},
+	}))
+	export, _, err := source.Export(t.Context(), "c-1")
+	require.NoError(t, err)
+
+	target := NewMigrator("shared-secret", state.NoRetention(state.NewMemoryStore()), nil, nil, time.Hour)
+	imported, err := target.Import(t.Context(), export)
+	require.NoError(t, err)
+	assert.Nil(t, imported.Budget, "conversations aren't limited here")
+	assert.Empty(t, imported.History, "strict privacy mode keeps no history")
+}
+
+func TestMigrator_Forget(t *testing.T) {
+	source, _ := newInstance(t, state.NewMemoryStore())
+	for _, tenant := range []string{"acme", "globex"} {
+		require.NoError(t, source.journal.Append(audit.Record{
+			Tenant: tenant, Operation: audit.OperationChat, Metadata: map[string]string{"conversation_id": "c-1"},
+		}))
+	}
+	export, _, err := source.Export(t.Context(), "c-1")
+	require.NoError(t, err)
+	target := NewMigrator("shared-secret", state.NewMemoryStore(), nil, nil, time.Hour)
+	_, err = target.Import(t.Context(), export)
+	require.NoError(t, err)
+
+	forgotten, err := target.Forget(t.Context(), &erasure.Subject{Tenant: "acme"})
+	require.NoError(t, err)
+	assert.Zero(t, forgotten, "imported history is looked up by conversation")
+	forgotten, err = target.Forget(t.Context(), &erasure.Subject{ConversationID: "c-1", Tenant: "acme"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, forgotten)
+	forgotten, err = target.Forget(t.Context(), &erasure.Subject{ConversationID: "c-1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, forgotten)
+
+	_, exported, err := target.Export(t.Context(), "c-1")
+	require.NoError(t, err)
+	assert.Empty(t, exported.History)
+}
@@ -0,0 +1,323 @@
+// Package doctor diagnoses a modelplex installation before it is started: whether the config
+// loads and validates, the environment variables it refers to are set, the socket can be
+// created, providers are reachable with clocks that agree, and MCP servers can be executed.
+// Each problem comes with a suggestion for fixing it.
+package doctor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/secrets"
+)
+
+const (
+	// providerTimeout bounds how long a provider may take to answer the reachability probe
+	providerTimeout = 5 * time.Second
+	// maxClockSkew is how far a provider's clock may be from ours before it is reported;
+	// signed requests and token expiry start failing beyond a few minutes
+	maxClockSkew = time.Minute
+)
+
+// Status is the outcome of a check.
+type Status int
+
+const (
+	// OK means the check passed
+	OK Status = iota
+	// Warn means something may go wrong, but the server can run
+	Warn
+	// Fail means the server won't start or can't serve requests
+	Fail
+)
+
+// String returns the label the status is reported with.
+func (s Status) String() string {
+	switch s {
+	case OK:
+		return "ok"
+	case Warn:
+		return "warn"
+	default:
+		return "fail"
+	}
+}
+
+// Check is the outcome of one diagnostic.
+type Check struct {
+	Name   string
+	Status Status
+	Detail string
+	// Fix suggests how to resolve a warning or failure
+	Fix string
+}
+
+// Report is the outcome of every diagnostic, in the order they ran.
+type Report struct {
+	Checks []Check
+}
+
+// Failed returns the number of failed checks.
+func (r *Report) Failed() int {
+	var failed int
+	for _, check := range r.Checks {
+		if check.Status == Fail {
+			failed++
+		}
+	}
+	return failed
+}
+
+func (r *Report) add(name string, status Status, detail, fix string) {
+	r.Checks = append(r.Checks, Check{Name: name, Status: status, Detail: detail, Fix: fix})
+}
+
+// ANSI colors of the statuses
+var colors = map[Status]string{OK: "\033[32m", Warn: "\033[33m", Fail: "\033[31m"}
+
+const colorReset = "\033[0m"
+
+// WriteText writes the report one check per line, with its fix below, colored when color is set.
+func (r *Report) WriteText(w io.Writer, color bool) error {
+	for _, check := range r.Checks {
+		status := fmt.Sprintf("%-4s", strings.ToUpper(check.Status.String()))
+		if color {
+			status = colors[check.Status] + status + colorReset
+		}
+		if _, err := fmt.Fprintf(w, "%s %s: %s\n", status, check.Name, check.Detail); err != nil {
+			return err
+		}
+		if check.Fix != "" && check.Status != OK {
+			if _, err := fmt.Fprintf(w, "     fix: %s\n", check.Fix); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d checks, %d failed\n", len(r.Checks), r.Failed())
+	return err
+}
+
+// Doctor runs the diagnostics for one installation.
+type Doctor struct {
+	// Config is the config file path or consul/etcd key URL
+	Config string
+	// Passphrase unlocks encrypted secrets
+	Passphrase string
+	// Socket is the Unix socket the server would listen on, if any
+	Socket string
+
+	client   *http.Client
+	lookPath func(string) (string, error)
+	now      func() time.Time
+}
+
+// New creates a doctor for the config at location, unlocked with passphrase, served on socket
+// when it is set.
+func New(location, passphrase, socket string) *Doctor {
+	return &Doctor{
+		Config:     location,
+		Passphrase: passphrase,
+		Socket:     socket,
+		client:     &http.Client{Timeout: providerTimeout},
+		lookPath:   exec.LookPath,
+		now:        time.Now,
+	}
+}
+
+// Run runs every diagnostic. Checks that need the config are skipped when it doesn't load.
+func (d *Doctor) Run(ctx context.Context) *Report {
+	report := &Report{}
+	d.checkSocket(report)
+
+	cfg, err := config.LoadFrom(ctx, d.Config)
+	if err != nil {
+		report.add("config", Fail, err.Error(), "check that the config exists and is valid TOML")
+		return report
+	}
+	d.checkEnv(report, cfg)
+	if err := secrets.NewResolver(d.Passphrase).ResolveConfig(ctx, cfg); err != nil {
+		report.add("secrets", Fail, err.Error(),
+			"pass --passphrase-file or set "+secrets.PassphraseEnv+", and check keychain entries")
+	}
+	config.ApplyDefaults(cfg)
+	d.checkConfig(report, cfg)
+	d.checkProviders(ctx, report, cfg)
+	d.checkMCP(report, cfg)
+	return report
+}
+
+// checkConfig reports every validation problem of cfg.
+func (d *Doctor) checkConfig(report *Report, cfg *config.Config) {
+	err := config.Validate(cfg)
+	if err == nil {
+		report.add("config", OK, d.Config+" is valid", "")
+		return
+	}
+	problems := []error{err}
+	if joined, ok := err.(interface{ Unwrap() []error }); ok {
+		problems = joined.Unwrap()
+	}
+	for _, problem := range problems {
+		report.add("config", Fail, problem.Error(), "correct the setting in "+d.Config)
+	}
+}
+
+// checkEnv reports the ${VAR} references in credentials whose variable isn't set, which would
+// otherwise resolve to empty credentials without complaint.
+func (d *Doctor) checkEnv(report *Report, cfg *config.Config) {
+	refs := make(map[string][]string)
+	var names []string
+	ref := func(owner, value string) {
+		if !strings.HasPrefix(value, "${") || !strings.HasSuffix(value, "}") {
+			return
+		}
+		name := strings.TrimSuffix(strings.TrimPrefix(value, "${"), "}")
+		if _, ok := refs[name]; !ok {
+			names = append(names, name)
+		}
+		refs[name] = append(refs[name], owner)
+	}
+	for _, p := range cfg.Providers {
+		ref("provider "+p.Name, p.APIKey)
+		ref("provider "+p.Name, p.Auth.ClientSecret)
+		ref("provider "+p.Name, p.Bedrock.AccessKeyID)
+		ref("provider "+p.Name, p.Bedrock.SecretAccessKey)
+		ref("provider "+p.Name, p.Bedrock.SessionToken)
+	}
+	ref("usage", cfg.Usage.APIKey)
+	ref("mcp capabilities", cfg.MCP.Capabilities.Secret)
+	ref("provenance", cfg.Provenance.Secret)
+	ref("conversations", cfg.Conversations.Secret)
+
+	for _, name := range names {
+		if _, ok := os.LookupEnv(name); ok {
+			report.add("env", OK, name+" is set", "")
+			continue
+		}
+		report.add("env", Fail, fmt.Sprintf("%s is not set, used by %s", name, strings.Join(refs[name], ", ")),
+			"export "+name+" before starting modelplex")
+	}
+}
+
+// checkSocket reports whether the socket can be created at its path.
+func (d *Doctor) checkSocket(report *Report) {
+	if d.Socket == "" {
+		return
+	}
+	if strings.HasPrefix(d.Socket, "@") {
+		if runtime.GOOS != "linux" {
+			report.add("socket", Fail, "abstract sockets are not supported on "+runtime.GOOS,
+				"use a socket path instead of "+d.Socket)
+			return
+		}
+		report.add("socket", OK, d.Socket+" is an abstract socket, no file is needed", "")
+		return
+	}
+	if _, err := os.Stat(d.Socket); err == nil {
+		report.add("socket", Fail, d.Socket+" already exists",
+			"stop the server using it, or remove the stale socket file")
+		return
+	}
+	dir := filepath.Dir(d.Socket)
+	probe, err := os.CreateTemp(dir, ".modelplex-doctor-*")
+	if err != nil {
+		report.add("socket", Fail, fmt.Sprintf("cannot create files in %s: %v", dir, err),
+			"create the directory or make it writable by this user")
+		return
+	}
+	_ = probe.Close()
+	_ = os.Remove(probe.Name())
+	report.add("socket", OK, d.Socket+" can be created", "")
+}
+
+// checkProviders reports whether each provider endpoint answers, and whether the clocks of
+// those that say agree with ours.
+func (d *Doctor) checkProviders(ctx context.Context, report *Report, cfg *config.Config) {
+	var worst time.Duration
+	var worstEndpoint string
+	for _, p := range cfg.Providers {
+		endpoints := []string{p.BaseURL}
+		if len(p.Regions) > 0 {
+			endpoints = endpoints[:0]
+			for _, r := range p.Regions {
+				endpoints = append(endpoints, r.BaseURL)
+			}
+		}
+		for _, endpoint := range endpoints {
+			skew, ok := d.checkProvider(ctx, report, p.Name, endpoint)
+			if ok && (worstEndpoint == "" || skew > worst) {
+				worst, worstEndpoint = skew, endpoint
+			}
+		}
+	}
+
+	switch {
+	case worstEndpoint == "":
+		// No provider told the time
+	case worst > maxClockSkew:
+		report.add("clock", Warn, fmt.Sprintf("off by %s from %s", worst.Round(time.Second), worstEndpoint),
+			"synchronize the system clock, e.g. enable NTP")
+	default:
+		report.add("clock", OK, "agrees with the providers within "+maxClockSkew.String(), "")
+	}
+}
+
+// checkProvider reports whether endpoint answers, and returns how far its clock is from ours
+// when it says.
+func (d *Doctor) checkProvider(ctx context.Context, report *Report, name, endpoint string) (time.Duration, bool) {
+	check := "provider " + name
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, http.NoBody)
+	if err != nil {
+		report.add(check, Fail, err.Error(), "correct base_url in the provider's config")
+		return 0, false
+	}
+	sent := d.now()
+	resp, err := d.client.Do(req)
+	if err != nil {
+		var cause error = err
+		if unwrapped := errors.Unwrap(err); unwrapped != nil {
+			cause = unwrapped
+		}
+		report.add(check, Fail, fmt.Sprintf("%s is unreachable: %v", endpoint, cause),
+			"check base_url, DNS, proxies and firewalls, and that a local backend is running")
+		return 0, false
+	}
+	_ = resp.Body.Close()
+	// Any answer, even an error status for the bare base URL, shows the endpoint is reachable
+	report.add(check, OK, fmt.Sprintf("%s answered with status %d", endpoint, resp.StatusCode), "")
+
+	date, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		return 0, false
+	}
+	// Date has second precision, so the time sent is truncated to match
+	skew := date.Sub(sent.Truncate(time.Second))
+	if skew < 0 {
+		skew = -skew
+	}
+	return skew, true
+}
+
+// checkMCP reports whether each MCP server's command can be executed.
+func (d *Doctor) checkMCP(report *Report, cfg *config.Config) {
+	for _, server := range cfg.MCP.Servers {
+		check := "mcp " + server.Name
+		path, err := d.lookPath(server.Command)
+		if err != nil {
+			report.add(check, Fail, fmt.Sprintf("%s is not executable: %v", server.Command, err),
+				"install the command or use its absolute path, and check its permissions")
+			continue
+		}
+		report.add(check, OK, path+" is executable", "")
+	}
+}
@@ -0,0 +1,127 @@
+package doctor
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeConfig(t *testing.T, data string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	require.NoError(t, os.WriteFile(path, []byte(data), 0o600))
+	return path
+}
+
+// statuses returns the status of every check by name and detail, for comparing reports.
+func statuses(report *Report) map[string]Status {
+	result := make(map[string]Status)
+	for _, check := range report.Checks {
+		result[check.Name+": "+check.Detail] = check.Status
+	}
+	return result
+}
+
+func TestDoctor_Run(t *testing.T) {
+	t.Setenv("DOCTOR_SET_KEY", "sk-test")
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Date", now.Add(5*time.Minute).Format(http.TimeFormat))
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer upstream.Close()
+	unreachable := httptest.NewServer(http.NotFoundHandler())
+	unreachable.Close()
+
+	path := writeConfig(t, fmt.Sprintf(`
+[[providers]]
+name = "up"
+type = "openai"
+base_url = %q
+api_key = "${DOCTOR_SET_KEY}"
+
+[[providers]]
+name = "down"
+type = "openai"
+base_url = %q
+api_key = "${DOCTOR_UNSET_KEY}"
+
+[mcp]
+[[mcp.servers]]
+name = "files"
+command = "mcp-files"
+`, upstream.URL, unreachable.URL))
+
+	socket := filepath.Join(t.TempDir(), "modelplex.socket")
+	d := New(path, "", socket)
+	d.now = func() time.Time { return now }
+	d.lookPath = func(string) (string, error) { return "", errors.New("not found") }
+
+	report := d.Run(t.Context())
+	checks := statuses(report)
+	assert.Equal(t, OK, checks["socket: "+socket+" can be created"])
+	assert.Equal(t, OK, checks["env: DOCTOR_SET_KEY is set"])
+	assert.Equal(t, Fail, checks["env: DOCTOR_UNSET_KEY is not set, used by provider down"])
+	assert.Equal(t, OK, checks["config: "+path+" is valid"])
+	assert.Equal(t, OK, checks["provider up: "+upstream.URL+" answered with status 404"])
+	assert.Equal(t, Warn, checks["clock: off by 5m0s from "+upstream.URL])
+	assert.Equal(t, Fail, checks["mcp files: mcp-files is not executable: not found"])
+	assert.Len(t, report.Checks, 8)
+	assert.Equal(t, 3, report.Failed())
+
+	var out bytes.Buffer
+	require.NoError(t, report.WriteText(&out, false))
+	assert.Contains(t, out.String(), "FAIL env: DOCTOR_UNSET_KEY is not set, used by provider down\n"+
+		"     fix: export DOCTOR_UNSET_KEY before starting modelplex\n")
+	assert.Contains(t, out.String(), "8 checks, 3 failed\n")
+}
+
+func TestDoctor_InvalidConfig(t *testing.T) {
+	path := writeConfig(t, "[server]\nlog_level = \"loud\"\nmax_request_size = -1\n")
+
+	report := New(path, "", "").Run(t.Context())
+	assert.Equal(t, 2, report.Failed())
+	for _, check := range report.Checks {
+		assert.Equal(t, "config", check.Name)
+		assert.Equal(t, "correct the setting in "+path, check.Fix)
+	}
+}
+
+func TestDoctor_MissingConfig(t *testing.T) {
+	report := New(filepath.Join(t.TempDir(), "missing.toml"), "", "").Run(t.Context())
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, Fail, report.Checks[0].Status)
+}
+
+func TestDoctor_StaleSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "modelplex.socket")
+	require.NoError(t, os.WriteFile(socket, nil, 0o600))
+
+	report := &Report{}
+	New("", "", socket).checkSocket(report)
+	assert.Equal(t, []Check{{
+		Name: "socket", Status: Fail, Detail: socket + " already exists",
+		Fix: "stop the server using it, or remove the stale socket file",
+	}}, report.Checks)
+}
+
+func TestDoctor_AbstractSocket(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("Abstract sockets are Linux only")
+	}
+
+	report := &Report{}
+	New("", "", "@modelplex").checkSocket(report)
+	require.Len(t, report.Checks, 1)
+	assert.Equal(t, OK, report.Checks[0].Status)
+}
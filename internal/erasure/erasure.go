@@ -0,0 +1,94 @@
+// Package erasure selects the stored data of a data subject, such as a user or a conversation, so
+// it can be purged wherever modelplex keeps it when the subject asks to be forgotten, as the GDPR's
+// right to erasure requires. Data is selected by the requests it came from: their tenant and the
+// metadata clients attached to them.
+package erasure
+
+import (
+	"maps"
+	"slices"
+)
+
+// ConversationKey is the request metadata key that carries the conversation a request belongs to.
+const ConversationKey = "conversation_id"
+
+// Subject selects the data of the requests that match every criterion given.
+type Subject struct {
+	// ConversationID matches requests carrying it as their conversation_id metadata
+	ConversationID string `json:"conversation_id,omitempty"`
+	// Tenant matches the requests of one client, as identified by usage.tenant_header
+	Tenant string `json:"tenant,omitempty"`
+	// Metadata matches requests carrying all these metadata pairs, e.g. a user id
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Empty reports whether the subject has no criteria, which would select everything.
+func (s *Subject) Empty() bool {
+	return s.ConversationID == "" && s.Tenant == "" && len(s.Metadata) == 0
+}
+
+// Matches reports whether a request of tenant carrying md is the subject's.
+func (s *Subject) Matches(tenant string, md map[string]string) bool {
+	if s.Empty() {
+		return false
+	}
+	if s.Tenant != "" && s.Tenant != tenant {
+		return false
+	}
+	if s.ConversationID != "" && md[ConversationKey] != s.ConversationID {
+		return false
+	}
+	for key, value := range s.Metadata {
+		if stored, ok := md[key]; !ok || stored != value {
+			return false
+		}
+	}
+	return true
+}
+
+// Key returns the index key the subject's data is looked up by: its most selective criterion.
+func (s *Subject) Key() string {
+	switch {
+	case s.ConversationID != "":
+		return conversationKey(s.ConversationID)
+	case len(s.Metadata) > 0:
+		key := slices.Min(slices.Collect(maps.Keys(s.Metadata)))
+		return metadataKey(key, s.Metadata[key])
+	}
+	return tenantKey(s.Tenant)
+}
+
+// Keys returns the index keys a request of tenant carrying md can be looked up by, one for each
+// criterion a Subject may select it with.
+func Keys(tenant string, md map[string]string) []string {
+	keys := []string{tenantKey(tenant)}
+	for _, key := range slices.Sorted(maps.Keys(md)) {
+		if key == ConversationKey {
+			keys = append(keys, conversationKey(md[key]))
+		}
+		keys = append(keys, metadataKey(key, md[key]))
+	}
+	return keys
+}
+
+func tenantKey(tenant string) string {
+	return "tenant:" + tenant
+}
+
+func conversationKey(id string) string {
+	return "conversation:" + id
+}
+
+func metadataKey(key, value string) string {
+	return "metadata:" + key + "=" + value
+}
+
+// Result is what was purged from one storage subsystem.
+type Result struct {
+	// Enabled is false for a subsystem that isn't configured, and so holds nothing to purge
+	Enabled bool `json:"enabled"`
+	// Deleted counts the items purged, e.g. cached responses or transcripts
+	Deleted int `json:"deleted"`
+	// Error is why the purge failed; items counted in Deleted were still purged
+	Error string `json:"error,omitempty"`
+}
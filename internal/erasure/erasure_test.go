@@ -0,0 +1,31 @@
+package erasure
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubject_Matches(t *testing.T) {
+	md := map[string]string{"conversation_id": "c-1", "user": "u-1"}
+	tests := []struct {
+		name    string
+		subject Subject
+		matches bool
+	}{
+		{name: "conversation", subject: Subject{ConversationID: "c-1"}, matches: true},
+		{name: "tenant", subject: Subject{Tenant: "acme"}, matches: true},
+		{name: "metadata", subject: Subject{Metadata: map[string]string{"user": "u-1"}}, matches: true},
+		{name: "every criterion", subject: Subject{ConversationID: "c-1", Tenant: "globex"}},
+		{name: "missing key", subject: Subject{Metadata: map[string]string{"team": ""}}},
+		{name: "empty", subject: Subject{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.matches, tt.subject.Matches("acme", md))
+			if tt.matches {
+				assert.Contains(t, Keys("acme", md), tt.subject.Key(), "the subject is found through the index")
+			}
+		})
+	}
+}
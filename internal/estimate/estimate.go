@@ -0,0 +1,184 @@
+// Package estimate tells what a request would cost before it is made. A request body is routed
+// like a real one, by the routing rules and then the multiplexer, but never sent: the estimate
+// holds its prompt size, the route it would take and the most it could cost, assuming the
+// completion runs to its limit.
+package estimate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/multiplexer"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/routing"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// Estimate is what is known about a request before it is made.
+type Estimate struct {
+	Object string `json:"object"`
+	// Model is the model the request asked for
+	Model        string `json:"model"`
+	Route        Route  `json:"route"`
+	PromptTokens int64  `json:"prompt_tokens"`
+	// MaxCompletionTokens is nil when neither the request nor the model's limits bound the completion
+	MaxCompletionTokens *int64 `json:"max_completion_tokens,omitempty"`
+	// MaxCost is nil when the completion is unbounded or the routed model has no price
+	MaxCost *float64 `json:"max_cost,omitempty"`
+}
+
+// Route is where a request would be sent.
+type Route struct {
+	// Rule is the name of the routing rule that matched the request, if any
+	Rule     string `json:"rule,omitempty"`
+	Model    string `json:"model"`
+	Provider string `json:"provider"`
+}
+
+// maxTokenParams are the request parameters bounding the completion, in order of precedence.
+var maxTokenParams = []string{"max_completion_tokens", "max_tokens"}
+
+// Estimator estimates requests against a config and the multiplexer built from it.
+type Estimator struct {
+	router    *routing.Multiplexer
+	mux       *multiplexer.ModelMultiplexer
+	providers []config.Provider
+	prices    map[string]config.ModelPrice
+	policies  *config.ParametersConfig
+}
+
+// New creates an estimator routing by the rules of cfg, with load for their in-flight conditions,
+// to the providers of mux.
+func New(cfg *config.Config, mux *multiplexer.ModelMultiplexer, load *routing.Load) *Estimator {
+	return &Estimator{
+		router:    routing.NewMultiplexer(mux, &cfg.Routing, load),
+		mux:       mux,
+		providers: cfg.Providers,
+		prices:    cfg.Usage.Prices,
+		policies:  &cfg.Parameters,
+	}
+}
+
+// Estimate estimates a chat completion or completion request body, decoded with UseNumber.
+// Bodies with messages are chat completions; the others are completions of their prompt.
+func (e *Estimator) Estimate(ctx context.Context, body map[string]interface{}) (*Estimate, error) {
+	model, _ := body["model"].(string)
+	if model == "" {
+		return nil, errors.New("model is required")
+	}
+
+	var promptTokens int64
+	if raw, ok := body["messages"]; ok {
+		messages, err := decodeMessages(raw)
+		if err != nil {
+			return nil, err
+		}
+		promptTokens = providers.EstimateMessagesTokens(messages)
+	} else {
+		prompt, _ := body["prompt"].(string)
+		promptTokens = providers.EstimateTextTokens(prompt)
+	}
+
+	md, err := metadata.Parse(body["metadata"])
+	if err != nil {
+		return nil, err
+	}
+	if md != nil {
+		ctx = metadata.With(ctx, md)
+	}
+	// Parameters as the proxy passes them on, so rules overriding them take effect
+	params := maps.Clone(body)
+	for _, field := range []string{"model", "messages", "prompt", "stream", "metadata"} {
+		delete(params, field)
+	}
+	ctx = providers.WithParams(ctx, providers.NewParams(params, e.policies))
+
+	ctx, routed, rule := e.router.Route(ctx, model, promptTokens)
+	provider, err := e.mux.Resolve(ctx, routed)
+	if err != nil {
+		return nil, err
+	}
+
+	estimate := &Estimate{
+		Object:       "estimate",
+		Model:        model,
+		Route:        Route{Rule: rule, Model: routed, Provider: provider.Name()},
+		PromptTokens: promptTokens,
+	}
+	maxTokens, ok, err := requestMaxTokens(providers.ParamsFrom(ctx).Values())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		maxTokens, ok = e.providerMaxTokens(provider.Name(), routed, promptTokens)
+	}
+	if !ok {
+		return estimate, nil
+	}
+	estimate.MaxCompletionTokens = &maxTokens
+	if price, ok := e.prices[routed]; ok {
+		cost := usage.Cost(price, promptTokens, maxTokens)
+		estimate.MaxCost = &cost
+	}
+	return estimate, nil
+}
+
+// providerMaxTokens returns the completion limit the named provider applies to model by default.
+func (e *Estimator) providerMaxTokens(name, model string, promptTokens int64) (int64, bool) {
+	i := slices.IndexFunc(e.providers, func(p config.Provider) bool { return p.Name == name })
+	if i < 0 {
+		return 0, false
+	}
+	return providers.MaxCompletionTokens(&e.providers[i], model, promptTokens)
+}
+
+// decodeMessages converts the messages of a decoded body to the form providers take.
+func decodeMessages(raw interface{}) ([]map[string]interface{}, error) {
+	list, ok := raw.([]interface{})
+	if !ok {
+		return nil, errors.New("messages must be an array")
+	}
+	messages := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		message, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, errors.New("messages must be objects")
+		}
+		messages = append(messages, message)
+	}
+	return messages, nil
+}
+
+// requestMaxTokens returns the completion limit set by the request parameters, if any.
+// Values come from JSON as numbers, or from routing rules as TOML integers.
+func requestMaxTokens(values map[string]interface{}) (int64, bool, error) {
+	for _, name := range maxTokenParams {
+		value, ok := values[name]
+		if !ok {
+			continue
+		}
+		var tokens int64
+		var err error
+		switch v := value.(type) {
+		case json.Number:
+			tokens, err = v.Int64()
+		case int64:
+			tokens = v
+		case float64:
+			tokens = int64(v)
+		default:
+			err = fmt.Errorf("unexpected type %T", value)
+		}
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid %s: %w", name, err)
+		}
+		return tokens, true, nil
+	}
+	return 0, false, nil
+}
@@ -0,0 +1,108 @@
+package estimate
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/multiplexer"
+	"github.com/modelplex/modelplex/internal/routing"
+)
+
+func newEstimator(rules ...config.RoutingRule) *Estimator {
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "openai", Type: "openai", BaseURL: "http://openai.invalid", Models: []string{"gpt-4"}, Priority: 1,
+				ModelLimits: map[string]config.ModelLimits{"gpt-4": {ContextWindow: 8192, MaxOutputTokens: 4096}}},
+			{Name: "anthropic", Type: "anthropic", BaseURL: "http://anthropic.invalid",
+				Models: []string{"claude-3-haiku"}, Priority: 2},
+			{Name: "local", Type: "ollama", BaseURL: "http://ollama.invalid", Models: []string{"llama2"}, Priority: 3},
+		},
+		Routing: config.RoutingConfig{Rules: rules},
+		Usage: config.UsageConfig{Prices: map[string]config.ModelPrice{
+			"gpt-4":          {Input: 30, Output: 60},
+			"claude-3-haiku": {Input: 0.25, Output: 1.25},
+		}},
+	}
+	return New(cfg, multiplexer.New(cfg.Providers), routing.NewLoad())
+}
+
+func body(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var value map[string]interface{}
+	decoder := json.NewDecoder(strings.NewReader(data))
+	decoder.UseNumber()
+	require.NoError(t, decoder.Decode(&value))
+	return value
+}
+
+func TestEstimate(t *testing.T) {
+	tests := []struct {
+		name     string
+		rules    []config.RoutingRule
+		body     string
+		expected string
+	}{
+		{
+			name: "limited by the model's context window",
+			body: `{"model":"gpt-4","messages":[{"role":"user","content":"` + strings.Repeat("a", 40) + `"}]}`,
+			expected: `{"object":"estimate","model":"gpt-4","route":{"model":"gpt-4","provider":"openai"},` +
+				`"prompt_tokens":10,"max_completion_tokens":4096,"max_cost":0.2460600}`,
+		},
+		{
+			name: "limited by the request",
+			body: `{"model":"gpt-4","prompt":"Hello","max_tokens":100}`,
+			expected: `{"object":"estimate","model":"gpt-4","route":{"model":"gpt-4","provider":"openai"},` +
+				`"prompt_tokens":2,"max_completion_tokens":100,"max_cost":0.00606}`,
+		},
+		{
+			name:  "routed by a rule",
+			rules: []config.RoutingRule{{Name: "cheap", Match: config.RoutingMatch{Models: []string{"gpt-4"}}, Model: "claude-3-haiku"}},
+			body:  `{"model":"gpt-4","messages":[{"role":"user","content":"Hi"}]}`,
+			expected: `{"object":"estimate","model":"gpt-4","route":{"rule":"cheap","model":"claude-3-haiku",` +
+				`"provider":"anthropic"},"prompt_tokens":1,"max_completion_tokens":4096,"max_cost":0.00512025}`,
+		},
+		{
+			name: "rule parameters override the request",
+			rules: []config.RoutingRule{{
+				Name: "short", Match: config.RoutingMatch{Models: []string{"gpt-4"}}, Params: map[string]interface{}{"max_tokens": int64(10)},
+			}},
+			body: `{"model":"gpt-4","prompt":"Hello","max_tokens":100}`,
+			expected: `{"object":"estimate","model":"gpt-4","route":{"rule":"short","model":"gpt-4","provider":"openai"},` +
+				`"prompt_tokens":2,"max_completion_tokens":10,"max_cost":0.00066}`,
+		},
+		{
+			name:     "unbounded completion",
+			body:     `{"model":"llama2","prompt":"Hello"}`,
+			expected: `{"object":"estimate","model":"llama2","route":{"model":"llama2","provider":"local"},"prompt_tokens":2}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := newEstimator(tt.rules...).Estimate(t.Context(), body(t, tt.body))
+			require.NoError(t, err)
+
+			data, err := json.Marshal(result)
+			require.NoError(t, err)
+			assert.JSONEq(t, tt.expected, string(data))
+		})
+	}
+}
+
+func TestEstimate_InvalidRequest(t *testing.T) {
+	e := newEstimator()
+
+	_, err := e.Estimate(t.Context(), body(t, `{"messages":[]}`))
+	assert.EqualError(t, err, "model is required")
+
+	_, err = e.Estimate(t.Context(), body(t, `{"model":"gpt-4","messages":"Hello"}`))
+	assert.EqualError(t, err, "messages must be an array")
+
+	_, err = e.Estimate(t.Context(), body(t, `{"model":"gpt-4","prompt":"Hello","max_tokens":"many"}`))
+	assert.ErrorContains(t, err, "invalid max_tokens")
+}
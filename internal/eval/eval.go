@@ -0,0 +1,316 @@
+// Package eval runs golden-answer regression suites against models served by modelplex.
+// A suite is a YAML file of prompts with assertions on the answers; running it against a
+// local server turns modelplex into a lightweight eval harness for model and config changes.
+package eval
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+
+	"golang.org/x/sync/errgroup"
+	"gopkg.in/yaml.v3"
+
+	"github.com/modelplex/modelplex/internal/judge"
+)
+
+const (
+	// DefaultMinScore is the judge score an answer needs to pass when min_score is unset
+	DefaultMinScore = 7
+	// maxConcurrentChecks bounds the requests a run keeps in flight
+	maxConcurrentChecks = 4
+)
+
+// Suite is a set of cases run against every listed model.
+type Suite struct {
+	Models []string `yaml:"models"`
+	// Judge is the model that scores answers for judge assertions
+	Judge string `yaml:"judge"`
+	Cases []Case `yaml:"cases"`
+}
+
+// Case is a single prompt and the assertions its answer must satisfy.
+type Case struct {
+	Name   string `yaml:"name"`
+	System string `yaml:"system"`
+	Prompt string `yaml:"prompt"`
+	// Models replaces the suite models for this case
+	Models []string    `yaml:"models"`
+	Assert []Assertion `yaml:"assert"`
+}
+
+// Assertion checks an answer. Exactly one of Contains, Regex and Judge is set.
+type Assertion struct {
+	Contains string `yaml:"contains"`
+	Regex    string `yaml:"regex"`
+	// Judge holds the criteria the judge model scores the answer against
+	Judge    string  `yaml:"judge"`
+	MinScore float64 `yaml:"min_score"`
+
+	pattern *regexp.Regexp
+}
+
+// Load reads and validates the suite at path.
+func Load(path string) (*Suite, error) {
+	data, err := os.ReadFile(path) // #nosec G304 -- suite path is provided by user via CLI argument
+	if err != nil {
+		return nil, err
+	}
+	return Parse(data)
+}
+
+// Parse decodes a YAML suite and checks it for every problem at once.
+func Parse(data []byte) (*Suite, error) {
+	var suite Suite
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&suite); err != nil {
+		return nil, fmt.Errorf("invalid suite: %w", err)
+	}
+
+	var errs []error
+	addf := func(format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+	if len(suite.Cases) == 0 {
+		addf("cases: required")
+	}
+	for i := range suite.Cases {
+		c := &suite.Cases[i]
+		field := fmt.Sprintf("cases[%d]", i)
+		if c.Name != "" {
+			field += " (" + c.Name + ")"
+		} else {
+			addf("%s.name: required", field)
+		}
+		if c.Prompt == "" {
+			addf("%s.prompt: required", field)
+		}
+		if len(c.Models) == 0 && len(suite.Models) == 0 {
+			addf("%s.models: required when the suite lists no models", field)
+		}
+		if len(c.Assert) == 0 {
+			addf("%s.assert: required", field)
+		}
+
+		for j := range c.Assert {
+			a := &c.Assert[j]
+			afield := fmt.Sprintf("%s.assert[%d]", field, j)
+			set := 0
+			for _, value := range []string{a.Contains, a.Regex, a.Judge} {
+				if value != "" {
+					set++
+				}
+			}
+			if set != 1 {
+				addf("%s: exactly one of contains, regex and judge is required", afield)
+			}
+			if a.Regex != "" {
+				var err error
+				if a.pattern, err = regexp.Compile(a.Regex); err != nil {
+					addf("%s.regex: %v", afield, err)
+				}
+			}
+			if a.Judge != "" {
+				if suite.Judge == "" {
+					addf("%s.judge: the suite needs a judge model", afield)
+				}
+				if a.MinScore == 0 {
+					a.MinScore = DefaultMinScore
+				}
+				if a.MinScore < 0 || a.MinScore > judge.MaxScore {
+					addf("%s.min_score: must be between 0 and %d, got %g", afield, judge.MaxScore, a.MinScore)
+				}
+			}
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+	return &suite, nil
+}
+
+// Result is the outcome of one case against one model.
+type Result struct {
+	Case   string `json:"case"`
+	Model  string `json:"model"`
+	Passed bool   `json:"passed"`
+	// Error is set when no answer could be obtained or judged
+	Error    string   `json:"error,omitempty"`
+	Failures []string `json:"failures,omitempty"`
+	Answer   string   `json:"answer"`
+}
+
+// Report is the outcome of a suite run.
+type Report struct {
+	Results []Result `json:"results"`
+	Passed  int      `json:"passed"`
+	Failed  int      `json:"failed"`
+}
+
+// WriteText writes one line per result followed by a summary.
+func (r *Report) WriteText(w io.Writer) error {
+	for _, result := range r.Results {
+		status := "PASS"
+		if !result.Passed {
+			status = "FAIL"
+		}
+		if _, err := fmt.Fprintf(w, "%s %s [%s]\n", status, result.Case, result.Model); err != nil {
+			return err
+		}
+		if result.Error != "" {
+			if _, err := fmt.Fprintf(w, "    error: %s\n", result.Error); err != nil {
+				return err
+			}
+		}
+		for _, failure := range result.Failures {
+			if _, err := fmt.Fprintf(w, "    %s\n", failure); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d passed, %d failed\n", r.Passed, r.Failed)
+	return err
+}
+
+// Runner sends suite prompts to a modelplex server's chat completions endpoint.
+type Runner struct {
+	client  *http.Client
+	baseURL string
+}
+
+// NewRunner creates a runner for the server at baseURL, e.g. http://localhost:41041.
+func NewRunner(client *http.Client, baseURL string) *Runner {
+	return &Runner{client: client, baseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// Run runs every case against each of its models. Failed requests count as failed checks
+// rather than aborting the run, so one broken provider still yields a full report.
+func (r *Runner) Run(ctx context.Context, suite *Suite) *Report {
+	type check struct {
+		c     *Case
+		model string
+	}
+	var checks []check
+	for i := range suite.Cases {
+		models := suite.Cases[i].Models
+		if len(models) == 0 {
+			models = suite.Models
+		}
+		for _, model := range models {
+			checks = append(checks, check{c: &suite.Cases[i], model: model})
+		}
+	}
+
+	report := &Report{Results: make([]Result, len(checks))}
+	g, ctx := errgroup.WithContext(ctx)
+	g.SetLimit(maxConcurrentChecks)
+	for i, ch := range checks {
+		g.Go(func() error {
+			report.Results[i] = r.runCase(ctx, suite.Judge, ch.c, ch.model)
+			return nil
+		})
+	}
+	_ = g.Wait() // checks report their errors in their results
+
+	for _, result := range report.Results {
+		if result.Passed {
+			report.Passed++
+		} else {
+			report.Failed++
+		}
+	}
+	return report
+}
+
+func (r *Runner) runCase(ctx context.Context, judgeModel string, c *Case, model string) Result {
+	result := Result{Case: c.Name, Model: model}
+
+	var messages []map[string]interface{}
+	if c.System != "" {
+		messages = append(messages, map[string]interface{}{"role": "system", "content": c.System})
+	}
+	messages = append(messages, map[string]interface{}{"role": "user", "content": c.Prompt})
+
+	answer, err := r.complete(ctx, model, messages)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Answer = answer
+
+	for _, a := range c.Assert {
+		switch {
+		case a.Contains != "":
+			if !strings.Contains(answer, a.Contains) {
+				result.Failures = append(result.Failures, fmt.Sprintf("contains %q: not found", a.Contains))
+			}
+		case a.pattern != nil:
+			if !a.pattern.MatchString(answer) {
+				result.Failures = append(result.Failures, fmt.Sprintf("regex %q: no match", a.Regex))
+			}
+		case a.Judge != "":
+			score, err := r.score(ctx, judgeModel, a.Judge, c.Prompt, answer)
+			if err != nil {
+				result.Failures = append(result.Failures, fmt.Sprintf("judge %q: %v", a.Judge, err))
+			} else if score < a.MinScore {
+				result.Failures = append(result.Failures,
+					fmt.Sprintf("judge %q: scored %g, need %g", a.Judge, score, a.MinScore))
+			}
+		}
+	}
+
+	result.Passed = len(result.Failures) == 0
+	return result
+}
+
+// score asks the judge model to rate answer against criteria.
+func (r *Runner) score(ctx context.Context, judgeModel, criteria, prompt, answer string) (float64, error) {
+	reply, err := r.complete(ctx, judgeModel, judge.Messages(criteria, prompt, answer))
+	if err != nil {
+		return 0, err
+	}
+	return judge.ParseScore(reply)
+}
+
+// complete sends a chat completion and returns the answer text.
+func (r *Runner) complete(ctx context.Context, model string, messages []map[string]interface{}) (string, error) {
+	body, err := json.Marshal(map[string]interface{}{"model": model, "messages": messages})
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.baseURL+"/v1/chat/completions", bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request failed with status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	var decoded interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return "", fmt.Errorf("invalid response: %w", err)
+	}
+	return judge.AnswerText(decoded), nil
+}
@@ -0,0 +1,99 @@
+package eval
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad_ExampleSuite(t *testing.T) {
+	suite, err := Load("../../examples/eval/suite.yaml")
+	require.NoError(t, err)
+	assert.Len(t, suite.Cases, 3)
+	assert.Equal(t, 8.0, suite.Cases[2].Assert[0].MinScore)
+}
+
+func TestParse_ReportsEveryProblem(t *testing.T) {
+	_, err := Parse([]byte(`
+cases:
+  - prompt: hi
+    assert:
+      - contains: a
+        regex: b
+  - name: broken
+    models: [gpt-4]
+    prompt: hi
+    assert:
+      - regex: "("
+      - judge: is helpful
+`))
+	require.Error(t, err)
+
+	var problems []string
+	for _, e := range err.(interface{ Unwrap() []error }).Unwrap() {
+		problems = append(problems, e.Error())
+	}
+	assert.Equal(t, []string{
+		"cases[0].name: required",
+		"cases[0].models: required when the suite lists no models",
+		"cases[0].assert[0]: exactly one of contains, regex and judge is required",
+		"cases[1] (broken).assert[0].regex: error parsing regexp: missing closing ): `(`",
+		"cases[1] (broken).assert[1].judge: the suite needs a judge model",
+	}, problems)
+}
+
+func TestRunner_Run(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Model    string                   `json:"model"`
+			Messages []map[string]interface{} `json:"messages"`
+		}
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+
+		var resp map[string]interface{}
+		switch req.Model {
+		case "judge":
+			resp = map[string]interface{}{"choices": []interface{}{
+				map[string]interface{}{"message": map[string]interface{}{"content": "Score: 9"}},
+			}}
+		case "claude":
+			resp = map[string]interface{}{"content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "Lyon"},
+			}}
+		default:
+			resp = map[string]interface{}{"choices": []interface{}{
+				map[string]interface{}{"message": map[string]interface{}{"content": "Paris"}},
+			}}
+		}
+		require.NoError(t, json.NewEncoder(w).Encode(resp))
+	}))
+	defer server.Close()
+
+	suite, err := Parse([]byte(`
+models: [gpt-4, claude]
+judge: judge
+cases:
+  - name: capital
+    prompt: Capital of France?
+    assert:
+      - contains: Paris
+      - judge: names the capital
+`))
+	require.NoError(t, err)
+
+	report := NewRunner(server.Client(), server.URL).Run(t.Context(), suite)
+
+	assert.Equal(t, 1, report.Passed)
+	assert.Equal(t, 1, report.Failed)
+	assert.Equal(t, Result{Case: "capital", Model: "gpt-4", Passed: true, Answer: "Paris"}, report.Results[0])
+	assert.Equal(t, []string{`contains "Paris": not found`}, report.Results[1].Failures)
+
+	var out strings.Builder
+	require.NoError(t, report.WriteText(&out))
+	assert.Equal(t, "PASS capital [gpt-4]\nFAIL capital [claude]\n    contains \"Paris\": not found\n1 passed, 1 failed\n", out.String())
+}
@@ -0,0 +1,126 @@
+// Package events notifies an operator webhook of changes in how traffic is served, such as a
+// standby provider being promoted, a session being terminated, outbound traffic being paused or
+// maintenance mode, and of tool calls waiting for an operator. Events are CloudEvents posted one at a time in the
+// background; delivery is best effort, so an unreachable webhook never holds up requests.
+package events
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+const (
+	// postTimeout bounds a single webhook delivery
+	postTimeout  = 10 * time.Second
+	eventIDBytes = 16
+)
+
+// Event types emitted by the proxy.
+const (
+	// TypeProviderPromoted is emitted when a standby provider starts serving a model
+	TypeProviderPromoted = "modelplex.provider.promoted"
+	// TypeProviderDemoted is emitted when a model's primaries are back and its standby stops serving it
+	TypeProviderDemoted = "modelplex.provider.demoted"
+	// TypeToolCallPending is emitted when a tool call is held for an operator's approval
+	TypeToolCallPending = "modelplex.toolcall.pending"
+	// TypeSessionTerminated is emitted when an operator terminates a conversation or tenant
+	TypeSessionTerminated = "modelplex.session.terminated"
+	// TypeSessionResumed is emitted when a terminated session may make requests again
+	TypeSessionResumed = "modelplex.session.resumed"
+	// TypeTrafficPaused is emitted when an operator pauses all outbound provider traffic
+	TypeTrafficPaused = "modelplex.traffic.paused"
+	// TypeTrafficResumed is emitted when outbound provider traffic flows again
+	TypeTrafficResumed = "modelplex.traffic.resumed"
+	// TypeMaintenanceStarted is emitted when an operator closes the model routes for maintenance
+	TypeMaintenanceStarted = "modelplex.maintenance.started"
+	// TypeMaintenanceEnded is emitted when the model routes open again after maintenance
+	TypeMaintenanceEnded = "modelplex.maintenance.ended"
+)
+
+// Event is a CloudEvents envelope.
+type Event struct {
+	SpecVersion string      `json:"specversion"`
+	ID          string      `json:"id"`
+	Source      string      `json:"source"`
+	Type        string      `json:"type"`
+	Subject     string      `json:"subject"`
+	Time        time.Time   `json:"time"`
+	Data        interface{} `json:"data"`
+}
+
+// Notifier posts events to the configured webhook. A nil Notifier or one without a webhook
+// only logs them.
+type Notifier struct {
+	webhook string
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewNotifier creates a notifier from cfg.
+func NewNotifier(cfg *config.EventsConfig) *Notifier {
+	return &Notifier{webhook: cfg.Webhook, client: &http.Client{Timeout: postTimeout}, now: time.Now}
+}
+
+// Emit logs an event of eventType about subject and posts it to the webhook in the background.
+func (n *Notifier) Emit(eventType, subject string, data interface{}) {
+	slog.Info("Event", "type", eventType, "subject", subject, "data", data)
+	if n == nil || n.webhook == "" {
+		return
+	}
+
+	event := Event{
+		SpecVersion: "1.0",
+		ID:          newEventID(),
+		Source:      "modelplex",
+		Type:        eventType,
+		Subject:     subject,
+		Time:        n.now().UTC(),
+		Data:        data,
+	}
+	go func() {
+		if err := n.post(event); err != nil {
+			slog.Warn("Failed to deliver event", "type", eventType, "subject", subject, "error", err)
+		}
+	}()
+}
+
+func (n *Notifier) post(event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(context.Background(), "POST", n.webhook, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents+json")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("webhook failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func newEventID() string {
+	b := make([]byte, eventIDBytes)
+	_, _ = rand.Read(b) // crypto/rand.Read never returns an error
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,45 @@
+package events
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestNotifier_PostsCloudEvents(t *testing.T) {
+	received := make(chan Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/cloudevents+json", r.Header.Get("Content-Type"))
+		var event Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		received <- event
+	}))
+	defer server.Close()
+
+	n := NewNotifier(&config.EventsConfig{Webhook: server.URL})
+	now := time.Date(2026, time.October, 18, 12, 0, 0, 0, time.UTC)
+	n.now = func() time.Time { return now }
+	n.Emit(TypeProviderPromoted, "gpt-4", map[string]interface{}{"provider": "spare"})
+
+	event := <-received
+	assert.Equal(t, "1.0", event.SpecVersion)
+	assert.NotEmpty(t, event.ID)
+	assert.Equal(t, "modelplex", event.Source)
+	assert.Equal(t, TypeProviderPromoted, event.Type)
+	assert.Equal(t, "gpt-4", event.Subject)
+	assert.Equal(t, now, event.Time)
+	assert.Equal(t, map[string]interface{}{"provider": "spare"}, event.Data)
+}
+
+func TestNotifier_WithoutWebhook(t *testing.T) {
+	var n *Notifier
+	require.NotPanics(t, func() { n.Emit(TypeProviderDemoted, "gpt-4", nil) })
+	require.NotPanics(t, func() { NewNotifier(&config.EventsConfig{}).Emit(TypeProviderDemoted, "gpt-4", nil) })
+}
@@ -0,0 +1,220 @@
+// Package gemini serves the generateContent API of Google's Gemini, so tooling written for Google's
+// SDKs can be pointed at modelplex unchanged. Requests are translated into chat completions for
+// the multiplexer, and its responses, whatever the provider, are translated back into Gemini's
+// shape: candidates made of parts, with usage metadata. The translation itself is pkg/convert's.
+package gemini
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/strict"
+	"github.com/modelplex/modelplex/internal/toolcalls"
+	"github.com/modelplex/modelplex/pkg/convert"
+)
+
+// errorStatuses names the Google API status of HTTP status codes; any other is INTERNAL.
+var errorStatuses = map[int]string{
+	http.StatusBadRequest:         "INVALID_ARGUMENT",
+	http.StatusNotFound:           "NOT_FOUND",
+	http.StatusTooManyRequests:    "RESOURCE_EXHAUSTED",
+	http.StatusBadGateway:         "UNAVAILABLE",
+	http.StatusServiceUnavailable: "UNAVAILABLE",
+}
+
+// Handler serves generateContent requests with a multiplexer.
+type Handler struct {
+	mux proxy.Multiplexer
+	// policies decides what happens to parameters a provider can't honor; nil drops them with a warning
+	policies *config.ParametersConfig
+	// normalize reads every provider's responses as OpenAI's before they are translated
+	normalize *strict.Normalizer
+}
+
+// NewHandler creates a handler serving requests with mux.
+func NewHandler(mux proxy.Multiplexer, policies *config.ParametersConfig) *Handler {
+	return &Handler{mux: mux, policies: policies, normalize: strict.New(true)}
+}
+
+// GenerateContent handles POST /models/{model}:generateContent.
+func (h *Handler) GenerateContent(w http.ResponseWriter, r *http.Request) {
+	model, messages, r, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+	result, err := h.mux.ChatCompletion(r.Context(), model, messages)
+	if err != nil {
+		writeRequestError(w, err, "generate content")
+		return
+	}
+	normalized, err := h.normalize.Response(result, model, true)
+	if err != nil {
+		slog.Error("Unreadable provider response", "operation", "generate content", "model", model, "error", err)
+		writeError(w, http.StatusBadGateway, "Provider response could not be translated")
+		return
+	}
+	response, _ := normalized.(map[string]interface{})
+
+	writeWarnings(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(convert.OpenAIToGeminiResponse(response)); err != nil {
+		slog.Error("Failed to encode response", "type", "generate content", "error", err)
+	}
+}
+
+// StreamGenerateContent handles POST /models/{model}:streamGenerateContent. Responses are server-sent
+// events with alt=sse, which Google's SDKs ask for, and otherwise a JSON array written as it grows.
+func (h *Handler) StreamGenerateContent(w http.ResponseWriter, r *http.Request) {
+	model, messages, r, ok := h.decode(w, r)
+	if !ok {
+		return
+	}
+	upstream, err := h.mux.ChatCompletionStream(r.Context(), model, messages)
+	if err != nil {
+		writeRequestError(w, err, "stream generate content")
+		return
+	}
+	// Function calls are only ever sent whole, as arguments objects
+	chunks := toolcalls.Assemble(h.normalize.Stream(upstream, model, true))
+
+	sse := r.URL.Query().Get("alt") == "sse"
+	writeWarnings(w, r)
+	if sse {
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+	} else {
+		w.Header().Set("Content-Type", "application/json")
+	}
+	w.WriteHeader(http.StatusOK)
+	flusher, _ := w.(http.Flusher)
+
+	written := 0
+	for chunk := range chunks {
+		c, _ := chunk.(map[string]interface{})
+		response := convert.OpenAIToGeminiResponse(c)
+		if len(response["candidates"].([]interface{})) == 0 && response["usageMetadata"] == nil {
+			continue
+		}
+		data, err := json.Marshal(response)
+		if err != nil {
+			slog.Error("Failed to marshal streaming chunk", "operation", "stream generate content", "error", err)
+			continue
+		}
+		switch {
+		case sse:
+			_, err = fmt.Fprintf(w, "data: %s\n\n", data)
+		case written == 0:
+			_, err = fmt.Fprintf(w, "[%s", data)
+		default:
+			_, err = fmt.Fprintf(w, ",\n%s", data)
+		}
+		if err != nil {
+			slog.Error("Failed to write streaming chunk", "operation", "stream generate content", "error", err)
+			// Drains the stream, which must be read until it closes
+			for range chunks {
+			}
+			return
+		}
+		written++
+		if flusher != nil {
+			flusher.Flush()
+		}
+	}
+	if sse {
+		return
+	}
+	if written == 0 {
+		_, err = fmt.Fprint(w, "[]")
+	} else {
+		_, err = fmt.Fprint(w, "]")
+	}
+	if err != nil {
+		slog.Error("Failed to end streaming response", "operation", "stream generate content", "error", err)
+	}
+}
+
+// decode reads the request and returns its model and messages, with r carrying its parameters
+// to the provider. On failure the error has been answered and ok is false.
+func (h *Handler) decode(w http.ResponseWriter, r *http.Request) (
+	string, []map[string]interface{}, *http.Request, bool,
+) {
+	model := mux.Vars(r)["model"]
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read request body")
+		return "", nil, r, false
+	}
+	var req convert.GeminiRequest
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Keeps integers such as seed exact when they are re-encoded for the provider
+	decoder.UseNumber()
+	if err := decoder.Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return "", nil, r, false
+	}
+	messages, err := convert.GeminiToOpenAIMessages(&req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return "", nil, r, false
+	}
+
+	params, ignored := convert.GeminiToOpenAIParams(&req)
+	for _, field := range ignored {
+		w.Header().Add(providers.WarningHeader, fmt.Sprintf("generationConfig.%s is not supported and was ignored", field))
+	}
+	ctx := providers.WithParams(r.Context(), providers.NewParams(params, h.policies))
+	return model, messages, r.WithContext(ctx), true
+}
+
+// writeRequestError answers a failed request, as a client error when the request itself can't be served.
+func writeRequestError(w http.ResponseWriter, err error, operation string) {
+	var unsupported *providers.UnsupportedParamError
+	var invalid *providers.InvalidParamError
+	if errors.As(err, &unsupported) || errors.As(err, &invalid) {
+		slog.Debug("Rejected request parameter", "operation", operation, "error", err)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var failover *providers.FailoverError
+	if errors.As(err, &failover) {
+		slog.Error("Operation failed on every attempt", "operation", operation, "error", err)
+		writeError(w, http.StatusBadGateway, fmt.Sprintf("All %d attempts to serve the request failed",
+			len(failover.Attempts)))
+		return
+	}
+	slog.Error("Operation failed", "operation", operation, "error", err)
+	writeError(w, http.StatusInternalServerError, "Internal server error")
+}
+
+// writeWarnings adds the warnings raised while serving r, such as dropped parameters, as response headers.
+func writeWarnings(w http.ResponseWriter, r *http.Request) {
+	for _, warning := range providers.ParamsFrom(r.Context()).Warnings() {
+		w.Header().Add(providers.WarningHeader, warning)
+	}
+}
+
+// writeError answers with an error in the Google API format.
+func writeError(w http.ResponseWriter, statusCode int, message string) {
+	status, ok := errorStatuses[statusCode]
+	if !ok {
+		status = "INTERNAL"
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	errorResp := map[string]interface{}{
+		"error": map[string]interface{}{"code": statusCode, "message": message, "status": status},
+	}
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+}
@@ -0,0 +1,181 @@
+package gemini
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+)
+
+// stubMultiplexer answers with result, or streams chunks, and records what it was asked.
+type stubMultiplexer struct {
+	proxy.Multiplexer
+	result   interface{}
+	chunks   []interface{}
+	err      error
+	model    string
+	messages []map[string]interface{}
+	params   map[string]interface{}
+}
+
+func (m *stubMultiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	m.model, m.messages, m.params = model, messages, providers.ParamsFrom(ctx).Values()
+	return m.result, m.err
+}
+
+func (m *stubMultiplexer) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	m.model, m.messages, m.params = model, messages, providers.ParamsFrom(ctx).Values()
+	if m.err != nil {
+		return nil, m.err
+	}
+	stream := make(chan interface{}, len(m.chunks))
+	for _, chunk := range m.chunks {
+		stream <- chunk
+	}
+	close(stream)
+	return stream, nil
+}
+
+func serve(t *testing.T, m proxy.Multiplexer, path, body string) *httptest.ResponseRecorder {
+	t.Helper()
+	router := mux.NewRouter()
+	handler := NewHandler(m, nil)
+	router.HandleFunc("/models/{model:[^/:]+}:generateContent", handler.GenerateContent)
+	router.HandleFunc("/models/{model:[^/:]+}:streamGenerateContent", handler.StreamGenerateContent)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest(http.MethodPost, path, strings.NewReader(body)))
+	return w
+}
+
+func TestHandler_GenerateContent(t *testing.T) {
+	m := &stubMultiplexer{result: map[string]interface{}{
+		"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-3-sonnet",
+		"content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "Checking."},
+			map[string]interface{}{"type": "tool_use", "id": "toolu_1", "name": "weather",
+				"input": map[string]interface{}{"city": "Paris"}},
+		},
+		"stop_reason": "tool_use",
+		"usage":       map[string]interface{}{"input_tokens": float64(10), "output_tokens": float64(5)},
+	}}
+
+	w := serve(t, m, "/models/claude-3-sonnet:generateContent",
+		`{"contents":[{"role":"user","parts":[{"text":"Hi"}]}],"generationConfig":{"seed":12345678901234567}}`)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "claude-3-sonnet", m.model)
+	assert.Equal(t, json.Number("12345678901234567"), m.params["seed"])
+
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, map[string]interface{}{
+		"candidates": []interface{}{map[string]interface{}{
+			"content": map[string]interface{}{"role": "model", "parts": []interface{}{
+				map[string]interface{}{"text": "Checking."},
+				map[string]interface{}{"functionCall": map[string]interface{}{
+					"id": "toolu_1", "name": "weather", "args": map[string]interface{}{"city": "Paris"},
+				}},
+			}},
+			"finishReason": "STOP",
+			"index":        float64(0),
+		}},
+		"usageMetadata": map[string]interface{}{
+			"promptTokenCount": float64(10), "candidatesTokenCount": float64(5), "totalTokenCount": float64(15),
+		},
+		"modelVersion": "claude-3-sonnet",
+		"responseId":   "msg_1",
+	}, response)
+}
+
+func TestHandler_StreamGenerateContent(t *testing.T) {
+	chunks := []interface{}{
+		map[string]interface{}{"id": "chatcmpl-1", "choices": []interface{}{map[string]interface{}{
+			"index": float64(0), "delta": map[string]interface{}{"role": "assistant", "content": "Hel"},
+		}}},
+		map[string]interface{}{"id": "chatcmpl-1", "choices": []interface{}{map[string]interface{}{
+			"index": float64(0), "delta": map[string]interface{}{"content": "lo"},
+		}}},
+		map[string]interface{}{"id": "chatcmpl-1", "choices": []interface{}{map[string]interface{}{
+			"index": float64(0), "delta": map[string]interface{}{}, "finish_reason": "length",
+		}}},
+	}
+	body := `{"contents":[{"parts":[{"text":"Hi"}]}]}`
+
+	text := func(response map[string]interface{}) string {
+		candidate := response["candidates"].([]interface{})[0].(map[string]interface{})
+		var b strings.Builder
+		for _, part := range candidate["content"].(map[string]interface{})["parts"].([]interface{}) {
+			b.WriteString(part.(map[string]interface{})["text"].(string))
+		}
+		return b.String()
+	}
+
+	t.Run("sse", func(t *testing.T) {
+		w := serve(t, &stubMultiplexer{chunks: chunks}, "/models/gpt-4:streamGenerateContent?alt=sse", body)
+		require.Equal(t, http.StatusOK, w.Code)
+		assert.Equal(t, "text/event-stream", w.Header().Get("Content-Type"))
+
+		var responses []map[string]interface{}
+		for _, line := range strings.Split(w.Body.String(), "\n") {
+			if data, ok := strings.CutPrefix(line, "data: "); ok {
+				var response map[string]interface{}
+				require.NoError(t, json.Unmarshal([]byte(data), &response))
+				responses = append(responses, response)
+			}
+		}
+		require.Len(t, responses, 3)
+		assert.Equal(t, "Hel", text(responses[0]))
+		assert.Equal(t, "lo", text(responses[1]))
+		last := responses[2]["candidates"].([]interface{})[0].(map[string]interface{})
+		assert.Equal(t, "MAX_TOKENS", last["finishReason"])
+	})
+
+	t.Run("json array", func(t *testing.T) {
+		w := serve(t, &stubMultiplexer{chunks: chunks}, "/models/gpt-4:streamGenerateContent", body)
+		require.Equal(t, http.StatusOK, w.Code)
+
+		var responses []map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &responses))
+		require.Len(t, responses, 3)
+		assert.Equal(t, "Hello", text(responses[0])+text(responses[1]))
+	})
+}
+
+func TestHandler_Errors(t *testing.T) {
+	var response struct {
+		Error struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+			Status  string `json:"status"`
+		} `json:"error"`
+	}
+
+	w := serve(t, &stubMultiplexer{}, "/models/gpt-4:generateContent", `{"contents":`)
+	require.Equal(t, http.StatusBadRequest, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "INVALID_ARGUMENT", response.Error.Status)
+	assert.Equal(t, http.StatusBadRequest, response.Error.Code)
+
+	m := &stubMultiplexer{err: &providers.UnsupportedParamError{Provider: "ollama", Param: "seed"}}
+	w = serve(t, m, "/models/gpt-4:streamGenerateContent", `{"contents":[{"parts":[{"text":"Hi"}]}]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	m = &stubMultiplexer{err: errors.New("upstream down")}
+	w = serve(t, m, "/models/gpt-4:generateContent", `{"contents":[{"parts":[{"text":"Hi"}]}]}`)
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "INTERNAL", response.Error.Status)
+}
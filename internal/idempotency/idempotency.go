@@ -0,0 +1,332 @@
+// Package idempotency replays recorded responses for requests that repeat an Idempotency-Key,
+// so agent retry storms don't re-invoke providers and spend tokens twice.
+// Records live in the state backend, so a Redis backend deduplicates across instances.
+//
+// Records are also indexed by the tenant and metadata of the request that produced them, so Forget
+// can delete those of a data subject, in window-long buckets as the response cache indexes its own.
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/state"
+)
+
+const (
+	// Header carries the client-chosen key identifying retries of one logical request
+	Header = "Idempotency-Key"
+	// ReplayedHeader is set on responses served from a previous request
+	ReplayedHeader = "Idempotent-Replayed"
+
+	// maxKeyLength bounds keys so they can't be used to bloat the state backend
+	maxKeyLength = 255
+	// lockTTL releases the in-progress marker of a request whose instance died mid-flight
+	lockTTL = 2 * time.Minute
+)
+
+// record is a stored response together with the fingerprint of the request that produced it.
+type record struct {
+	Fingerprint string `json:"fingerprint"`
+	Status      int    `json:"status"`
+	ContentType string `json:"content_type"`
+	Body        []byte `json:"body"`
+}
+
+// indexEntry names a stored record and the request that produced it.
+type indexEntry struct {
+	Key      string            `json:"key"`
+	Tenant   string            `json:"tenant"`
+	Metadata map[string]string `json:"metadata,omitempty"`
+}
+
+// Guard deduplicates POST requests carrying an Idempotency-Key.
+type Guard struct {
+	store  state.Store
+	window time.Duration
+	scope  func(*http.Request) string
+	now    func() time.Time
+}
+
+// New creates a guard that remembers responses for window.
+// scope partitions keys by tenant, so clients can't replay each other's responses; Forget matches
+// data subjects on it.
+func New(store state.Store, window time.Duration, scope func(*http.Request) string) *Guard {
+	return &Guard{store: store, window: window, scope: scope, now: time.Now}
+}
+
+// Fingerprint returns a deterministic hash of a request body.
+// JSON bodies are canonicalized first, so key order and whitespace don't change the result.
+func Fingerprint(body []byte) string {
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err == nil {
+		// json.Marshal sorts map keys
+		if canonical, err := json.Marshal(decoded); err == nil {
+			body = canonical
+		}
+	}
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// Wrap returns middleware applying the guard to next; a nil guard passes requests straight through.
+// State backend failures fail open so a degraded backend never blocks requests.
+func (g *Guard) Wrap(next http.Handler) http.Handler {
+	if g == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(Header)
+		if key == "" || r.Method != http.MethodPost {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if len(key) > maxKeyLength {
+			writeError(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		fingerprint := Fingerprint(body)
+
+		keyHash := sha256.Sum256([]byte(g.scope(r) + "\x00" + r.URL.Path + "\x00" + key))
+		recordKey := "idempotency:" + hex.EncodeToString(keyHash[:])
+
+		if g.replay(w, r, recordKey, fingerprint) {
+			return
+		}
+
+		lockKey := recordKey + ":lock"
+		holders, err := g.store.IncrBy(r.Context(), lockKey, 1, lockTTL)
+		if err != nil {
+			slog.Warn("Idempotency lock failed, processing request", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+		if holders > 1 {
+			writeError(w, http.StatusConflict, "A request with this Idempotency-Key is already in progress")
+			return
+		}
+		defer func() {
+			// The client may be gone by now, but the lock must still be released
+			if err := g.store.Delete(context.WithoutCancel(r.Context()), lockKey); err != nil {
+				slog.Warn("Failed to release idempotency lock", "error", err)
+			}
+		}()
+
+		// The previous holder may have finished between the lookup and taking the lock
+		if g.replay(w, r, recordKey, fingerprint) {
+			return
+		}
+
+		rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(rec, r)
+		g.save(r, recordKey, fingerprint, body, rec)
+	})
+}
+
+// replay writes the recorded response for recordKey, if any, and reports whether it did.
+func (g *Guard) replay(w http.ResponseWriter, r *http.Request, recordKey, fingerprint string) bool {
+	data, ok, err := g.store.Get(r.Context(), recordKey)
+	if err != nil {
+		slog.Warn("Idempotency lookup failed, processing request", "error", err)
+		return false
+	}
+	if !ok {
+		return false
+	}
+
+	var stored record
+	if err := json.Unmarshal(data, &stored); err != nil {
+		slog.Warn("Discarding unreadable idempotency record", "error", err)
+		return false
+	}
+	if stored.Fingerprint != fingerprint {
+		writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request body")
+		return true
+	}
+
+	slog.Debug("Replaying idempotent response", "path", r.URL.Path)
+	w.Header().Set("Content-Type", stored.ContentType)
+	w.Header().Set(ReplayedHeader, "true")
+	w.WriteHeader(stored.Status)
+	if _, err := w.Write(stored.Body); err != nil {
+		slog.Error("Error writing replayed response", "error", err)
+	}
+	return true
+}
+
+// save records a completed response. Server errors and streams are not recorded,
+// so retrying after a failure or a dropped stream reaches the provider again.
+func (g *Guard) save(r *http.Request, recordKey, fingerprint string, body []byte, rec *recorder) {
+	contentType := rec.Header().Get("Content-Type")
+	if rec.status >= http.StatusInternalServerError || rec.streamed {
+		return
+	}
+	if strings.HasPrefix(contentType, "text/event-stream") {
+		return
+	}
+
+	data, err := json.Marshal(record{
+		Fingerprint: fingerprint,
+		Status:      rec.status,
+		ContentType: contentType,
+		Body:        rec.body.Bytes(),
+	})
+	if err != nil {
+		slog.Warn("Failed to encode idempotency record", "error", err)
+		return
+	}
+	ctx := context.WithoutCancel(r.Context())
+	if err := g.store.Set(ctx, recordKey, data, g.window); err != nil {
+		slog.Warn("Failed to store idempotency record", "error", err)
+		return
+	}
+	if err := g.index(ctx, recordKey, g.scope(r), requestMetadata(body)); err != nil {
+		slog.Warn("Failed to index idempotency record", "error", err)
+	}
+}
+
+// requestMetadata returns the metadata a request body carries, or nil.
+func requestMetadata(body []byte) map[string]string {
+	var request struct {
+		Metadata interface{} `json:"metadata"`
+	}
+	if json.Unmarshal(body, &request) != nil {
+		return nil
+	}
+	md, err := metadata.Parse(request.Metadata)
+	if err != nil {
+		return nil
+	}
+	return md
+}
+
+// index records recordKey under every index key the request of tenant carrying md can be looked up
+// by. A bucket's counter outlives the records added to it, which expire at most a window after it ends.
+func (g *Guard) index(ctx context.Context, recordKey, tenant string, md map[string]string) error {
+	data, err := json.Marshal(indexEntry{Key: recordKey, Tenant: tenant, Metadata: md})
+	if err != nil {
+		return err
+	}
+	bucket := g.bucket(g.now())
+	for _, indexKey := range erasure.Keys(tenant, md) {
+		slot, err := g.store.IncrBy(ctx, bucketKey(indexKey, bucket), 1, 2*g.window)
+		if err != nil {
+			return err
+		}
+		if err := g.store.Set(ctx, slotKey(indexKey, bucket, slot), data, 2*g.window); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Forget deletes the recorded responses of the requests subject matches, returning how many.
+func (g *Guard) Forget(ctx context.Context, subject *erasure.Subject) (int, error) {
+	indexKey := subject.Key()
+	current := g.bucket(g.now())
+	deleted := 0
+	for _, bucket := range []int64{current - 1, current} {
+		data, ok, err := g.store.Get(ctx, bucketKey(indexKey, bucket))
+		if err != nil {
+			return deleted, err
+		}
+		if !ok {
+			continue
+		}
+		slots, err := strconv.ParseInt(string(data), 10, 64)
+		if err != nil {
+			return deleted, err
+		}
+
+		for slot := int64(1); slot <= slots; slot++ {
+			data, ok, err := g.store.Get(ctx, slotKey(indexKey, bucket, slot))
+			if err != nil {
+				return deleted, err
+			}
+			var entry indexEntry
+			if !ok || json.Unmarshal(data, &entry) != nil || !subject.Matches(entry.Tenant, entry.Metadata) {
+				continue
+			}
+			if _, recorded, err := g.store.Get(ctx, entry.Key); err != nil {
+				return deleted, err
+			} else if recorded {
+				if err := g.store.Delete(ctx, entry.Key); err != nil {
+					return deleted, err
+				}
+				deleted++
+			}
+		}
+	}
+	return deleted, nil
+}
+
+// bucket numbers the window-long period now falls in.
+func (g *Guard) bucket(now time.Time) int64 {
+	return now.UnixNano() / int64(g.window)
+}
+
+func bucketKey(indexKey string, bucket int64) string {
+	return fmt.Sprintf("idempotency:index:%d:%s", bucket, indexKey)
+}
+
+func slotKey(indexKey string, bucket, slot int64) string {
+	return fmt.Sprintf("idempotency:index:%d:%d:%s", bucket, slot, indexKey)
+}
+
+// recorder passes a response through while keeping a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status   int
+	body     bytes.Buffer
+	streamed bool
+}
+
+func (r *recorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(p []byte) (int, error) {
+	if !r.streamed {
+		r.body.Write(p)
+	}
+	return r.ResponseWriter.Write(p)
+}
+
+// Flush keeps streaming responses streaming; a flushed response is never recorded.
+func (r *recorder) Flush() {
+	r.streamed = true
+	r.body.Reset()
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// writeError writes an OpenAI-style error response.
+func writeError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	body := map[string]interface{}{"error": map[string]string{"message": message, "type": "idempotency_error"}}
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		slog.Error("Error writing idempotency error response", "error", err)
+	}
+}
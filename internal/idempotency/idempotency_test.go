@@ -0,0 +1,148 @@
+package idempotency
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/state"
+)
+
+func newTestGuard(t *testing.T) *Guard {
+	store := state.NewMemoryStore()
+	t.Cleanup(func() { _ = store.Close() })
+	return New(store, time.Hour, func(r *http.Request) string { return r.Header.Get("X-Tenant") })
+}
+
+func send(handler http.Handler, key, tenant, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	if key != "" {
+		req.Header.Set(Header, key)
+	}
+	req.Header.Set("X-Tenant", tenant)
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	return w
+}
+
+func TestGuard_ReplaysDuplicates(t *testing.T) {
+	var calls atomic.Int32
+	handler := newTestGuard(t).Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		n := calls.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusOK)
+		_, _ = fmt.Fprintf(w, `{"call":%d}`, n)
+	}))
+
+	first := send(handler, "key-1", "acme", `{"model":"gpt-4","messages":[]}`)
+	assert.Equal(t, http.StatusOK, first.Code)
+	assert.Empty(t, first.Header().Get(ReplayedHeader))
+
+	// Same logical body with different key order and whitespace
+	second := send(handler, "key-1", "acme", `{ "messages": [], "model": "gpt-4" }`)
+	assert.Equal(t, http.StatusOK, second.Code)
+	assert.Equal(t, "true", second.Header().Get(ReplayedHeader))
+	assert.Equal(t, first.Body.String(), second.Body.String())
+	assert.Equal(t, int32(1), calls.Load())
+
+	assert.Equal(t, http.StatusUnprocessableEntity, send(handler, "key-1", "acme", `{"model":"gpt-3.5"}`).Code)
+
+	// Keys are scoped per tenant, and requests without a key are never deduplicated
+	assert.Equal(t, http.StatusOK, send(handler, "key-1", "other", `{"model":"gpt-4","messages":[]}`).Code)
+	assert.Equal(t, http.StatusOK, send(handler, "", "acme", `{"model":"gpt-4","messages":[]}`).Code)
+	assert.Equal(t, int32(3), calls.Load())
+}
+
+func TestGuard_InProgress(t *testing.T) {
+	started := make(chan struct{})
+	release := make(chan struct{})
+	handler := newTestGuard(t).Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		close(started)
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- send(handler, "key-1", "acme", `{}`) }()
+	<-started
+
+	assert.Equal(t, http.StatusConflict, send(handler, "key-1", "acme", `{}`).Code)
+
+	close(release)
+	assert.Equal(t, http.StatusOK, (<-done).Code)
+	assert.Equal(t, "true", send(handler, "key-1", "acme", `{}`).Header().Get(ReplayedHeader))
+}
+
+func TestGuard_DoesNotRecordServerErrors(t *testing.T) {
+	var calls atomic.Int32
+	handler := newTestGuard(t).Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+
+	send(handler, "key-1", "acme", `{}`)
+	send(handler, "key-1", "acme", `{}`)
+	assert.Equal(t, int32(2), calls.Load(), "a retry after a server error must reach the provider")
+}
+
+func TestGuard_DoesNotRecordStreams(t *testing.T) {
+	var calls atomic.Int32
+	handler := newTestGuard(t).Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {}\n\n"))
+		w.(http.Flusher).Flush()
+	}))
+
+	send(handler, "key-1", "acme", `{"stream":true}`)
+	send(handler, "key-1", "acme", `{"stream":true}`)
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestGuard_Forget(t *testing.T) {
+	var calls atomic.Int32
+	guard := newTestGuard(t)
+	handler := guard.Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		w.WriteHeader(http.StatusOK)
+	}))
+	forgotten := `{"metadata":{"conversation_id":"conv-1"}}`
+	kept := `{"metadata":{"conversation_id":"conv-2"}}`
+	send(handler, "key-1", "acme", forgotten)
+	send(handler, "key-2", "acme", kept)
+	send(handler, "key-3", "other", forgotten)
+
+	deleted, err := guard.Forget(t.Context(), &erasure.Subject{Tenant: "acme", ConversationID: "conv-1"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+
+	assert.Empty(t, send(handler, "key-1", "acme", forgotten).Header().Get(ReplayedHeader), "a forgotten response is gone")
+	assert.Equal(t, "true", send(handler, "key-2", "acme", kept).Header().Get(ReplayedHeader))
+	assert.Equal(t, "true", send(handler, "key-3", "other", forgotten).Header().Get(ReplayedHeader))
+	assert.Equal(t, int32(4), calls.Load())
+
+	deleted, err = guard.Forget(t.Context(), &erasure.Subject{Tenant: "other"})
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+}
+
+func TestGuard_RejectsLongKeys(t *testing.T) {
+	handler := newTestGuard(t).Wrap(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	assert.Equal(t, http.StatusBadRequest, send(handler, strings.Repeat("k", 256), "acme", `{}`).Code)
+}
+
+func TestFingerprint(t *testing.T) {
+	assert.Equal(t, Fingerprint([]byte(`{"a":1,"b":[1,2]}`)), Fingerprint([]byte(`{ "b": [1, 2], "a": 1 }`)))
+	assert.NotEqual(t, Fingerprint([]byte(`{"a":1}`)), Fingerprint([]byte(`{"a":2}`)))
+	require.NotEmpty(t, Fingerprint([]byte("not json")))
+}
@@ -0,0 +1,167 @@
+package injection
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/judge"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+const (
+	// classifyTimeout bounds a classifier request, which the client request waits for
+	classifyTimeout = 30 * time.Second
+	// maxClassifiedBytes bounds the text sent to the classifier; the rules see all of it
+	maxClassifiedBytes = 16 << 10
+)
+
+// ModelStats counts the assessed requests for one model.
+type ModelStats struct {
+	Assessed int64 `json:"assessed"`
+	// Flagged counts requests at or above the flag threshold, blocked ones included
+	Flagged int64 `json:"flagged"`
+	Blocked int64 `json:"blocked"`
+	// ClassifierFailures counts requests the classifier couldn't score, which were scored by the rules only
+	ClassifierFailures int64 `json:"classifier_failures"`
+}
+
+// Stats collects per-model counts. It outlives a Multiplexer so counts survive config reloads.
+type Stats struct {
+	mtx    sync.Mutex
+	models map[string]*ModelStats
+}
+
+// NewStats creates empty stats.
+func NewStats() *Stats {
+	return &Stats{models: make(map[string]*ModelStats)}
+}
+
+// Snapshot returns a copy of the current counts keyed by model.
+func (s *Stats) Snapshot() map[string]ModelStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	out := make(map[string]ModelStats, len(s.models))
+	for model, stats := range s.models {
+		out[model] = *stats
+	}
+	return out
+}
+
+func (s *Stats) record(model string, flagged, blocked, classifierFailed bool) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	stats, ok := s.models[model]
+	if !ok {
+		stats = &ModelStats{}
+		s.models[model] = stats
+	}
+	stats.Assessed++
+	if flagged {
+		stats.Flagged++
+	}
+	if blocked {
+		stats.Blocked++
+	}
+	if classifierFailed {
+		stats.ClassifierFailures++
+	}
+}
+
+// Multiplexer wraps a multiplexer and assesses tool-augmented chat requests before forwarding them,
+// refusing those the detector blocks. Text completions carry no tools and are forwarded unassessed.
+type Multiplexer struct {
+	proxy.Multiplexer
+	detector *Detector
+	stats    *Stats
+}
+
+// NewMultiplexer wraps mux, assessing requests with detector and counting them in stats.
+// Classifier requests go through mux directly, so they are never assessed themselves.
+func NewMultiplexer(mux proxy.Multiplexer, detector *Detector, stats *Stats) *Multiplexer {
+	return &Multiplexer{Multiplexer: mux, detector: detector, stats: stats}
+}
+
+// ChatCompletion assesses the request and forwards it unless it is blocked.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	if err := m.assess(ctx, model, messages); err != nil {
+		return nil, err
+	}
+	return m.Multiplexer.ChatCompletion(ctx, model, messages)
+}
+
+// ChatCompletionStream assesses the request and forwards it unless it is blocked.
+func (m *Multiplexer) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	if err := m.assess(ctx, model, messages); err != nil {
+		return nil, err
+	}
+	return m.Multiplexer.ChatCompletionStream(ctx, model, messages)
+}
+
+// assess scores a tool-augmented request, reports the assessment to the request's tracker and
+// returns the error refusing it when it is blocked. A failing classifier leaves the rules to decide.
+func (m *Multiplexer) assess(ctx context.Context, model string, messages []map[string]interface{}) error {
+	if !ToolAugmented(providers.ParamsFrom(ctx).Values(), messages) {
+		return nil
+	}
+
+	text := ScannedText(messages)
+	risk, signals := m.detector.Score(text)
+	classifierFailed := false
+	if m.detector.model != "" && text != "" {
+		score, err := m.classify(ctx, text)
+		if err != nil {
+			classifierFailed = true
+			slog.WarnContext(ctx, "Injection classifier failed, scoring with rules only",
+				"model", model, "classifier", m.detector.model, "error", err)
+		} else {
+			if m.detector.flagged(score) {
+				signals = append(signals, "classifier")
+			}
+			risk = max(risk, score)
+		}
+	}
+
+	assessment := &Assessment{Risk: risk, Signals: signals, Blocked: m.detector.blocked(risk)}
+	report(ctx, assessment)
+	flagged := m.detector.flagged(risk)
+	m.stats.record(model, flagged, assessment.Blocked, classifierFailed)
+
+	attrs := []any{"tenant", usage.TenantFrom(ctx), "model", model, "risk", risk, "signals", signals}
+	switch {
+	case assessment.Blocked:
+		slog.WarnContext(ctx, "Blocked likely prompt injection", attrs...)
+		return &providers.BlockedError{Reason: fmt.Sprintf("likely prompt injection (risk %.2f)", risk)}
+	case flagged:
+		slog.WarnContext(ctx, "Flagged likely prompt injection", attrs...)
+	}
+	return nil
+}
+
+// classify asks the classifier model to score text. The client's parameters, tools among them, are
+// not passed on.
+func (m *Multiplexer) classify(ctx context.Context, text string) (float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, classifyTimeout)
+	defer cancel()
+	ctx = providers.WithParams(ctx, providers.NewParams(map[string]interface{}{"temperature": 0}, nil))
+
+	if len(text) > maxClassifiedBytes {
+		text = strings.ToValidUTF8(text[:maxClassifiedBytes], "")
+	}
+	reply, err := m.Multiplexer.ChatCompletion(ctx, m.detector.model, ClassifierMessages(text))
+	if err != nil {
+		return 0, err
+	}
+	return ParseClassifierScore(judge.AnswerText(reply))
+}
@@ -0,0 +1,80 @@
+// Package journal keeps the most recent provider failures, so intermittent upstream problems can
+// be investigated after the fact. Failures are kept in a fixed-size ring, which outlives config
+// reloads; the oldest are overwritten once it is full.
+package journal
+
+import (
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// DefaultSize is the number of failures kept when no size is given.
+const DefaultSize = 1000
+
+// Entry is one failed provider request.
+type Entry struct {
+	Time  time.Time `json:"time"`
+	Model string    `json:"model"`
+	// LatencyMS is how long the provider took to fail, up to the start of a stream
+	LatencyMS int64 `json:"latency_ms"`
+	providers.Attempt
+	// Headers are the captured headers of the provider's responses, e.g. its request id
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Journal is a ring of provider failures, safe for concurrent use.
+type Journal struct {
+	mtx     sync.Mutex
+	entries []Entry
+	// next is the slot the next entry is written to; entries is full once it has wrapped
+	next int
+	full bool
+}
+
+// New creates a journal keeping the last size failures, or DefaultSize when size isn't positive.
+func New(size int) *Journal {
+	if size <= 0 {
+		size = DefaultSize
+	}
+	return &Journal{entries: make([]Entry, size)}
+}
+
+// Record adds a failure, overwriting the oldest once the journal is full. A nil Journal records nothing.
+func (j *Journal) Record(entry Entry) {
+	if j == nil {
+		return
+	}
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	j.entries[j.next] = entry
+	j.next = (j.next + 1) % len(j.entries)
+	if j.next == 0 {
+		j.full = true
+	}
+}
+
+// Query returns the failures of provider, or of every provider when it is empty, recorded at or
+// after since, oldest first.
+func (j *Journal) Query(provider string, since time.Time) []Entry {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	ordered := j.entries[:j.next]
+	if j.full {
+		ordered = append(append([]Entry(nil), j.entries[j.next:]...), j.entries[:j.next]...)
+	}
+	matched := make([]Entry, 0)
+	for _, entry := range ordered {
+		if provider != "" && entry.Provider != provider {
+			continue
+		}
+		if entry.Time.Before(since) {
+			continue
+		}
+		matched = append(matched, entry)
+	}
+	return matched
+}
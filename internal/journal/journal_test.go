@@ -0,0 +1,49 @@
+package journal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+func entry(provider string, at time.Time) Entry {
+	return Entry{Time: at, Model: "gpt-4", Attempt: providers.Attempt{Provider: provider, StatusCode: 500}}
+}
+
+func TestJournal_Query(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	j := New(10)
+	j.Record(entry("openai", start))
+	j.Record(entry("anthropic", start.Add(time.Minute)))
+	j.Record(entry("openai", start.Add(2*time.Minute)))
+
+	assert.Len(t, j.Query("", time.Time{}), 3)
+	assert.Equal(t, []Entry{entry("openai", start), entry("openai", start.Add(2*time.Minute))},
+		j.Query("openai", time.Time{}))
+	assert.Equal(t, []Entry{entry("anthropic", start.Add(time.Minute)), entry("openai", start.Add(2*time.Minute))},
+		j.Query("", start.Add(time.Minute)))
+	assert.Empty(t, j.Query("ollama", time.Time{}))
+}
+
+func TestJournal_OverwritesOldest(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	j := New(3)
+	for i := range 5 {
+		j.Record(entry("openai", start.Add(time.Duration(i)*time.Minute)))
+	}
+
+	entries := j.Query("", time.Time{})
+	assert.Equal(t, []Entry{
+		entry("openai", start.Add(2*time.Minute)),
+		entry("openai", start.Add(3*time.Minute)),
+		entry("openai", start.Add(4*time.Minute)),
+	}, entries)
+}
+
+func TestJournal_NilRecordsNothing(t *testing.T) {
+	var j *Journal
+	assert.NotPanics(t, func() { j.Record(entry("openai", time.Now())) })
+}
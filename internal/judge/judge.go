@@ -0,0 +1,241 @@
+// Package judge scores responses with a judge model. The scoring middleware grades sampled
+// production responses in the background, so quality and safety can be tracked without
+// touching what clients receive; the eval harness uses the same scoring for its assertions.
+package judge
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math/rand/v2"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/proxy"
+)
+
+const (
+	// MaxScore is the top of the scale the judge scores on
+	MaxScore = 10
+
+	// scoreTimeout bounds a background judge request
+	scoreTimeout = 2 * time.Minute
+	// maxPending bounds background judge requests; responses arriving while it is reached are not scored
+	maxPending = 16
+
+	instructions = "You grade answers produced by another model. Score how well the answer meets the criteria " +
+		"on a scale from 0 to 10, where 10 means it fully meets them. Reply with the score only."
+)
+
+// scorePattern finds the score in a judge reply that didn't follow the instructions exactly.
+var scorePattern = regexp.MustCompile(`\d+(\.\d+)?`)
+
+// Messages builds the chat messages asking the judge to score answer to prompt against criteria.
+func Messages(criteria, prompt, answer string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{"role": "system", "content": instructions},
+		{"role": "user", "content": fmt.Sprintf("Criteria: %s\n\nPrompt: %s\n\nAnswer: %s", criteria, prompt, answer)},
+	}
+}
+
+// ParseScore reads the score from a judge reply.
+func ParseScore(reply string) (float64, error) {
+	match := scorePattern.FindString(reply)
+	if match == "" {
+		return 0, fmt.Errorf("judge reply has no score: %q", reply)
+	}
+	score, err := strconv.ParseFloat(match, 64)
+	if err != nil {
+		return 0, err
+	}
+	if score > MaxScore {
+		return 0, fmt.Errorf("judge score %g is above %d", score, MaxScore)
+	}
+	return score, nil
+}
+
+// AnswerText extracts the generated text from the response shapes providers return:
+// OpenAI choices, Anthropic content blocks and Ollama messages.
+func AnswerText(result interface{}) string {
+	resp, ok := result.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	if choices, ok := resp["choices"].([]interface{}); ok && len(choices) > 0 {
+		choice, _ := choices[0].(map[string]interface{})
+		if message, ok := choice["message"].(map[string]interface{}); ok {
+			content, _ := message["content"].(string)
+			return content
+		}
+		text, _ := choice["text"].(string)
+		return text
+	}
+
+	if blocks, ok := resp["content"].([]interface{}); ok {
+		var text strings.Builder
+		for _, block := range blocks {
+			if b, ok := block.(map[string]interface{}); ok && b["type"] == "text" {
+				s, _ := b["text"].(string)
+				text.WriteString(s)
+			}
+		}
+		return text.String()
+	}
+
+	if message, ok := resp["message"].(map[string]interface{}); ok {
+		content, _ := message["content"].(string)
+		return content
+	}
+	return ""
+}
+
+// ModelStats summarizes the scores of one model's responses.
+type ModelStats struct {
+	Scored  int64   `json:"scored"`
+	Failed  int64   `json:"failed"`
+	Average float64 `json:"average"`
+	Min     float64 `json:"min"`
+}
+
+// Stats collects per-model scores. It outlives a Multiplexer so scores survive config reloads.
+type Stats struct {
+	mtx    sync.Mutex
+	models map[string]*ModelStats
+}
+
+// NewStats creates empty stats.
+func NewStats() *Stats {
+	return &Stats{models: make(map[string]*ModelStats)}
+}
+
+// Snapshot returns a copy of the current stats keyed by model.
+func (s *Stats) Snapshot() map[string]ModelStats {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	out := make(map[string]ModelStats, len(s.models))
+	for model, stats := range s.models {
+		out[model] = *stats
+	}
+	return out
+}
+
+func (s *Stats) record(model string, score float64, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	stats, ok := s.models[model]
+	if !ok {
+		stats = &ModelStats{Min: MaxScore}
+		s.models[model] = stats
+	}
+	if err != nil {
+		stats.Failed++
+		return
+	}
+	stats.Average = (stats.Average*float64(stats.Scored) + score) / float64(stats.Scored+1)
+	stats.Scored++
+	stats.Min = min(stats.Min, score)
+}
+
+// Multiplexer wraps a multiplexer and scores a sample of its non-streaming responses in the background.
+// Responses are returned unchanged and without waiting for the judge.
+type Multiplexer struct {
+	proxy.Multiplexer
+	model      string
+	criteria   string
+	sampleRate float64
+	stats      *Stats
+	pending    chan struct{}
+	// rand is swappable so tests can control sampling
+	rand func() float64
+}
+
+// NewMultiplexer wraps mux, scoring responses with the judge configured in cfg and counting them in stats.
+// Judge requests go through mux directly, so they are never scored themselves.
+func NewMultiplexer(mux proxy.Multiplexer, cfg *config.JudgeConfig, stats *Stats) *Multiplexer {
+	return &Multiplexer{
+		Multiplexer: mux,
+		model:       cfg.Model,
+		criteria:    cfg.Criteria,
+		sampleRate:  cfg.SampleRate,
+		stats:       stats,
+		pending:     make(chan struct{}, maxPending),
+		rand:        rand.Float64, // #nosec G404 -- sampling doesn't need cryptographic randomness
+	}
+}
+
+// ChatCompletion forwards the request and scores the response against the last user message.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	result, err := m.Multiplexer.ChatCompletion(ctx, model, messages)
+	if err == nil {
+		m.maybeScore(ctx, model, lastUserMessage(messages), result)
+	}
+	return result, err
+}
+
+// Completion forwards the request and scores the response against the prompt.
+func (m *Multiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	result, err := m.Multiplexer.Completion(ctx, model, prompt)
+	if err == nil {
+		m.maybeScore(ctx, model, prompt, result)
+	}
+	return result, err
+}
+
+func (m *Multiplexer) maybeScore(ctx context.Context, model, prompt string, result interface{}) {
+	if m.rand() >= m.sampleRate {
+		return
+	}
+	answer := AnswerText(result)
+	if answer == "" {
+		return
+	}
+
+	select {
+	case m.pending <- struct{}{}:
+	default:
+		slog.Debug("Judge is busy, skipping response", "model", model)
+		return
+	}
+	go func() {
+		defer func() { <-m.pending }()
+		m.score(ctx, model, prompt, answer)
+	}()
+}
+
+// score runs detached from the client request, which has usually finished by the time the judge answers.
+// It keeps the request's values so the judge call is billed to the same tenant and obeys its data residency.
+func (m *Multiplexer) score(ctx context.Context, model, prompt, answer string) {
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), scoreTimeout)
+	defer cancel()
+
+	var score float64
+	reply, err := m.Multiplexer.ChatCompletion(ctx, m.model, Messages(m.criteria, prompt, answer))
+	if err == nil {
+		score, err = ParseScore(AnswerText(reply))
+	}
+	m.stats.record(model, score, err)
+	if err != nil {
+		slog.Warn("Judge scoring failed", "model", model, "judge", m.model, "error", err)
+		return
+	}
+	slog.Info("Response scored", "model", model, "judge", m.model, "score", score)
+}
+
+func lastUserMessage(messages []map[string]interface{}) string {
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i]["role"] == "user" {
+			content, _ := messages[i]["content"].(string)
+			return content
+		}
+	}
+	return ""
+}
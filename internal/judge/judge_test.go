@@ -0,0 +1,90 @@
+package judge
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/proxy"
+)
+
+// fakeMux answers every chat completion with the reply for its model.
+type fakeMux struct {
+	proxy.Multiplexer
+	replies map[string]string
+	judged  chan []map[string]interface{}
+}
+
+func (f *fakeMux) Completion(ctx context.Context, model, _ string) (interface{}, error) {
+	return f.ChatCompletion(ctx, model, nil)
+}
+
+func (f *fakeMux) ChatCompletion(
+	_ context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	if model == "judge" {
+		f.judged <- messages
+	}
+	return map[string]interface{}{"choices": []interface{}{
+		map[string]interface{}{"message": map[string]interface{}{"content": f.replies[model]}},
+	}}, nil
+}
+
+func TestParseScore(t *testing.T) {
+	score, err := ParseScore("Score: 8.5/10")
+	require.NoError(t, err)
+	assert.Equal(t, 8.5, score)
+
+	_, err = ParseScore("great answer")
+	assert.Error(t, err)
+	_, err = ParseScore("42")
+	assert.Error(t, err)
+}
+
+func TestAnswerText(t *testing.T) {
+	assert.Equal(t, "hi", AnswerText(map[string]interface{}{"choices": []interface{}{
+		map[string]interface{}{"text": "hi"},
+	}}))
+	assert.Equal(t, "a b", AnswerText(map[string]interface{}{"content": []interface{}{
+		map[string]interface{}{"type": "text", "text": "a "},
+		map[string]interface{}{"type": "tool_use"},
+		map[string]interface{}{"type": "text", "text": "b"},
+	}}))
+	assert.Equal(t, "ollama", AnswerText(map[string]interface{}{"message": map[string]interface{}{"content": "ollama"}}))
+	assert.Empty(t, AnswerText("not a response"))
+}
+
+func TestMultiplexer_ScoresInBackground(t *testing.T) {
+	inner := &fakeMux{replies: map[string]string{"gpt-4": "Paris", "judge": "9"}, judged: make(chan []map[string]interface{}, 1)}
+	stats := NewStats()
+	m := NewMultiplexer(inner, &config.JudgeConfig{Model: "judge", Criteria: "is correct", SampleRate: 1}, stats)
+
+	result, err := m.ChatCompletion(t.Context(), "gpt-4", []map[string]interface{}{
+		{"role": "system", "content": "Be brief"},
+		{"role": "user", "content": "Capital of France?"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "Paris", AnswerText(result), "the client response must be unchanged")
+
+	judged := <-inner.judged
+	assert.Equal(t, "Criteria: is correct\n\nPrompt: Capital of France?\n\nAnswer: Paris", judged[1]["content"])
+
+	// Taking every slot waits for the background scoring to finish
+	for range maxPending {
+		m.pending <- struct{}{}
+	}
+	assert.Equal(t, map[string]ModelStats{"gpt-4": {Scored: 1, Average: 9, Min: 9}}, stats.Snapshot())
+}
+
+func TestMultiplexer_Sampling(t *testing.T) {
+	inner := &fakeMux{replies: map[string]string{"gpt-4": "Paris"}}
+	m := NewMultiplexer(inner, &config.JudgeConfig{Model: "judge", SampleRate: 0.5}, NewStats())
+	m.rand = func() float64 { return 0.7 }
+
+	_, err := m.Completion(t.Context(), "gpt-4", "Capital of France?")
+	require.NoError(t, err)
+	assert.Empty(t, m.pending, "unsampled responses must not be judged")
+}
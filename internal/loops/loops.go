@@ -0,0 +1,189 @@
+// Package loops refuses requests a client keeps repeating, nearly identical, within a window: the
+// mark of an agent stuck in a loop, such as one calling the same tool with the same arguments and
+// getting the same result forever. Refusing them surfaces the loop instead of paying for it.
+//
+// Chat requests are compared by their last exchange, the last assistant message and the messages
+// after it, since a conversation's earlier turns are resent with every request; text completions by
+// their prompt. Identical requests match by hash, nearly identical ones by the Jaccard similarity of
+// their word trigrams. Requests are remembered by the instance that served them.
+package loops
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+const (
+	// maxHistory bounds the requests remembered per client; the oldest are forgotten first
+	maxHistory = 64
+	// maxClients is how many clients are remembered before those without recent requests are forgotten
+	maxClients = 10000
+	// shingleWords is the number of words in a shingle
+	shingleWords = 3
+)
+
+// Detector remembers the recent requests of each client and refuses the ones repeating too often.
+type Detector struct {
+	threshold  int64
+	window     time.Duration
+	similarity float64
+	now        func() time.Time
+
+	mtx     sync.Mutex
+	clients map[string][]fingerprint
+}
+
+// fingerprint identifies a request for comparison with later ones.
+type fingerprint struct {
+	at   time.Time
+	hash [sha256.Size]byte
+	// shingles is nil when only identical requests match
+	shingles map[uint64]struct{}
+}
+
+// NewDetector creates a detector as cfg configures it.
+func NewDetector(cfg *config.LoopsConfig) *Detector {
+	return &Detector{
+		threshold:  cfg.Threshold,
+		window:     time.Duration(cfg.WindowSeconds) * time.Second,
+		similarity: cfg.Similarity,
+		now:        time.Now,
+		clients:    make(map[string][]fingerprint),
+	}
+}
+
+// Check remembers a request of client, whose text is the part compared, and returns a
+// *providers.LoopDetectedError instead when the client sent threshold nearly identical requests
+// within the window. Refused requests aren't remembered, so a loop stays refused until the
+// requests it repeats age out of the window.
+func (d *Detector) Check(client, text string) error {
+	now := d.now()
+	request := fingerprint{at: now, hash: sha256.Sum256([]byte(text))}
+	if d.similarity < 1 {
+		request.shingles = shingles(text)
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	history := d.recent(d.clients[client], now)
+	var repeats int64
+	for _, previous := range history {
+		if previous.hash == request.hash || jaccard(previous.shingles, request.shingles) >= d.similarity {
+			repeats++
+		}
+	}
+	if repeats >= d.threshold {
+		d.clients[client] = history
+		return &providers.LoopDetectedError{Repeats: repeats, Window: d.window}
+	}
+
+	if len(history) == maxHistory {
+		history = history[1:]
+	}
+	d.clients[client] = append(history, request)
+	if len(d.clients) > maxClients {
+		d.forgetIdle(now)
+	}
+	return nil
+}
+
+// recent returns the requests of history within the window.
+func (d *Detector) recent(history []fingerprint, now time.Time) []fingerprint {
+	for i, request := range history {
+		if now.Sub(request.at) < d.window {
+			return history[i:]
+		}
+	}
+	return nil
+}
+
+// forgetIdle forgets the clients without requests within the window.
+func (d *Detector) forgetIdle(now time.Time) {
+	for client, history := range d.clients {
+		if len(d.recent(history, now)) == 0 {
+			delete(d.clients, client)
+		}
+	}
+}
+
+// shingles returns the hashes of the word trigrams of text, ignoring case and spacing; text of
+// fewer words is a single shingle.
+func shingles(text string) map[uint64]struct{} {
+	words := strings.Fields(strings.ToLower(text))
+	set := make(map[uint64]struct{})
+	for i := 0; i == 0 || i+shingleWords <= len(words); i++ {
+		h := fnv.New64a()
+		h.Write([]byte(strings.Join(words[i:min(i+shingleWords, len(words))], " ")))
+		set[h.Sum64()] = struct{}{}
+	}
+	return set
+}
+
+// jaccard returns the similarity of two sets of shingles, the share of shingles they have in common.
+func jaccard(a, b map[uint64]struct{}) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for shingle := range a {
+		if _, ok := b[shingle]; ok {
+			common++
+		}
+	}
+	return float64(common) / float64(len(a)+len(b)-common)
+}
+
+// ChatText returns the part of a chat request compared for loops: the model and the last
+// exchange, the last assistant message and the messages after it, or every message before the
+// first reply. Tool call ids differ between otherwise identical calls, so they are left out.
+func ChatText(model string, messages []map[string]interface{}) string {
+	start := 0
+	for i := len(messages) - 1; i >= 0; i-- {
+		if messages[i]["role"] == "assistant" {
+			start = i
+			break
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(model)
+	for _, message := range messages[start:] {
+		b.WriteString("\n")
+		b.WriteString(messageText(message))
+	}
+	return b.String()
+}
+
+// messageText returns the role, content and tool calls of message as text.
+func messageText(message map[string]interface{}) string {
+	role, _ := message["role"].(string)
+	parts := []string{role + ":"}
+	switch content := message["content"].(type) {
+	case string:
+		parts = append(parts, content)
+	case []interface{}:
+		for _, part := range content {
+			if p, ok := part.(map[string]interface{}); ok {
+				if text, ok := p["text"].(string); ok {
+					parts = append(parts, text)
+				}
+			}
+		}
+	}
+	calls, _ := message["tool_calls"].([]interface{})
+	for _, call := range calls {
+		c, _ := call.(map[string]interface{})
+		function, _ := c["function"].(map[string]interface{})
+		encoded, _ := json.Marshal(function)
+		parts = append(parts, string(encoded))
+	}
+	return strings.Join(parts, " ")
+}
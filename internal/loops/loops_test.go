@@ -0,0 +1,97 @@
+package loops
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+func newTestDetector(threshold int64, similarity float64) (*Detector, *time.Time) {
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	d := NewDetector(&config.LoopsConfig{Threshold: threshold, WindowSeconds: 60, Similarity: similarity})
+	d.now = func() time.Time { return now }
+	return d, &now
+}
+
+func TestDetector_RefusesRepeats(t *testing.T) {
+	d, now := newTestDetector(2, 1)
+	for range 2 {
+		require.NoError(t, d.Check("acme", "gpt-4\nuser: list the files"))
+	}
+	err := d.Check("acme", "gpt-4\nuser: list the files")
+	var loop *providers.LoopDetectedError
+	require.ErrorAs(t, err, &loop)
+	assert.Equal(t, providers.LoopDetectedError{Repeats: 2, Window: time.Minute}, *loop)
+
+	assert.NoError(t, d.Check("acme", "gpt-4\nuser: list the files again"), "only identical requests match")
+	assert.NoError(t, d.Check("other", "gpt-4\nuser: list the files"), "clients are told apart")
+
+	*now = now.Add(time.Minute)
+	assert.NoError(t, d.Check("acme", "gpt-4\nuser: list the files"), "repeats age out of the window")
+}
+
+func TestDetector_NearlyIdentical(t *testing.T) {
+	d, _ := newTestDetector(1, 0.8)
+	base := "gpt-4\nassistant: {\"name\":\"read_file\",\"arguments\":\"{\\\"path\\\":\\\"main.go\\\"}\"}\n" +
+		"tool: open main.go: no such file or directory, the working directory is /workspace/project"
+	require.NoError(t, d.Check("acme", base))
+	assert.Error(t, d.Check("acme", base+" now"), "a word more is nearly identical")
+	assert.NoError(t, d.Check("acme", "gpt-4\nuser: summarize the README"))
+}
+
+func TestChatText(t *testing.T) {
+	messages := []map[string]interface{}{
+		{"role": "system", "content": "You are a coding agent."},
+		{"role": "user", "content": "Fix the build"},
+		{"role": "assistant", "content": nil, "tool_calls": []interface{}{map[string]interface{}{
+			"id": "call_1", "type": "function",
+			"function": map[string]interface{}{"name": "run", "arguments": `{"cmd":"go build"}`},
+		}}},
+		{"role": "tool", "tool_call_id": "call_1", "content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "exit status 1"},
+		}},
+	}
+	assert.Equal(t, "gpt-4\nassistant: {\"arguments\":\"{\\\"cmd\\\":\\\"go build\\\"}\",\"name\":\"run\"}\n"+
+		"tool: exit status 1", ChatText("gpt-4", messages))
+	assert.Equal(t, "gpt-4\nsystem: You are a coding agent.\nuser: Fix the build", ChatText("gpt-4", messages[:2]))
+}
+
+// stubMultiplexer answers every chat request, counting them.
+type stubMultiplexer struct {
+	proxy.Multiplexer
+	served int
+}
+
+func (m *stubMultiplexer) ChatCompletion(
+	_ context.Context, _ string, _ []map[string]interface{},
+) (interface{}, error) {
+	m.served++
+	return map[string]interface{}{}, nil
+}
+
+func TestMultiplexer(t *testing.T) {
+	d, _ := newTestDetector(1, 1)
+	stub := &stubMultiplexer{}
+	m := NewMultiplexer(stub, d)
+	messages := []map[string]interface{}{{"role": "user", "content": "Hello"}}
+
+	ctx := usage.WithTenant(t.Context(), "acme")
+	_, err := m.ChatCompletion(ctx, "gpt-4", messages)
+	require.NoError(t, err)
+	_, err = m.ChatCompletion(ctx, "gpt-4", messages)
+	var loop *providers.LoopDetectedError
+	assert.ErrorAs(t, err, &loop)
+	assert.Equal(t, 1, stub.served, "refused requests reach no provider")
+
+	_, err = m.ChatCompletion(metadata.With(ctx, map[string]string{"conversation_id": "c2"}), "gpt-4", messages)
+	assert.NoError(t, err, "conversations of a tenant are told apart")
+}
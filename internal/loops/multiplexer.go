@@ -0,0 +1,63 @@
+package loops
+
+import (
+	"context"
+
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// Multiplexer wraps a multiplexer and refuses the requests of clients stuck in a loop.
+type Multiplexer struct {
+	proxy.Multiplexer
+	detector *Detector
+}
+
+// NewMultiplexer wraps mux so requests are checked with detector before they are forwarded.
+func NewMultiplexer(mux proxy.Multiplexer, detector *Detector) *Multiplexer {
+	return &Multiplexer{Multiplexer: mux, detector: detector}
+}
+
+// ChatCompletion forwards the request unless it repeats the client's recent ones.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	if err := m.detector.Check(client(ctx), ChatText(model, messages)); err != nil {
+		return nil, err
+	}
+	return m.Multiplexer.ChatCompletion(ctx, model, messages)
+}
+
+// Completion forwards the request unless it repeats the client's recent ones.
+func (m *Multiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	if err := m.detector.Check(client(ctx), model+"\n"+prompt); err != nil {
+		return nil, err
+	}
+	return m.Multiplexer.Completion(ctx, model, prompt)
+}
+
+// ChatCompletionStream forwards the request unless it repeats the client's recent ones.
+func (m *Multiplexer) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	if err := m.detector.Check(client(ctx), ChatText(model, messages)); err != nil {
+		return nil, err
+	}
+	return m.Multiplexer.ChatCompletionStream(ctx, model, messages)
+}
+
+// CompletionStream forwards the request unless it repeats the client's recent ones.
+func (m *Multiplexer) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	if err := m.detector.Check(client(ctx), model+"\n"+prompt); err != nil {
+		return nil, err
+	}
+	return m.Multiplexer.CompletionStream(ctx, model, prompt)
+}
+
+// client identifies who sent the request of ctx: its tenant and, when it has one, its conversation,
+// so concurrent conversations of a tenant aren't taken for one loop.
+func client(ctx context.Context) string {
+	return usage.TenantFrom(ctx) + "\x00" + metadata.From(ctx)[erasure.ConversationKey]
+}
@@ -5,10 +5,12 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"os/exec"
+	"slices"
 	"sync"
 
 	"github.com/modelplex/modelplex/internal/config"
@@ -16,14 +18,36 @@ import (
 
 const (
 	// MCP protocol constants
-	mcpListToolsRequestID = 2
-	mcpCallToolRequestID  = 99
+	mcpInitializeRequestID = 1
+	mcpListToolsRequestID  = 2
+	// mcpCallToolRequestID is the ID of a server's first tool call; later ones count up from it
+	mcpCallToolRequestID = 99
 )
 
+var (
+	// ErrToolNotFound is returned for a call to a tool no MCP server offers
+	ErrToolNotFound = errors.New("tool not found")
+	// ErrServerExited is returned for a tool call the MCP server exited without answering
+	ErrServerExited = errors.New("MCP server exited")
+)
+
+// ToolError is an MCP server's error response to a tool call.
+type ToolError struct {
+	Tool    string
+	Code    int
+	Message string
+}
+
+func (e *ToolError) Error() string {
+	return fmt.Sprintf("tool %s failed: %s", e.Tool, e.Message)
+}
+
 // Client manages connections to multiple MCP servers.
 type Client struct {
 	servers map[string]*Server
-	mu      sync.RWMutex
+	// failed holds why the servers that couldn't be started didn't, by name
+	failed map[string]error
+	mu     sync.RWMutex
 }
 
 // Server represents a single MCP server connection.
@@ -35,6 +59,15 @@ type Server struct {
 	stderr io.ReadCloser
 	tools  []Tool
 	mu     sync.RWMutex
+	// writeMu keeps concurrent requests from interleaving on stdin
+	writeMu sync.Mutex
+	// pending holds the tool calls waiting for their response, by request ID; nil once the server exited
+	pending map[int]chan Response
+	nextID  int
+	// initialized is set once the server answered initialize, initErr when it refused
+	initialized bool
+	initErr     error
+	exited      bool
 }
 
 // Tool represents an MCP tool with its schema.
@@ -70,11 +103,15 @@ type Error struct {
 func NewMCPClient(configs []config.MCPServer) *Client {
 	client := &Client{
 		servers: make(map[string]*Server),
+		failed:  make(map[string]error),
 	}
 
 	for _, cfg := range configs {
 		if err := client.StartServer(cfg); err != nil {
 			slog.Error("Failed to start MCP server", "server", cfg.Name, "error", err)
+			client.mu.Lock()
+			client.failed[cfg.Name] = err
+			client.mu.Unlock()
 		}
 	}
 
@@ -109,12 +146,14 @@ func (c *Client) StartServer(cfg config.MCPServer) error {
 	}
 
 	server := &Server{
-		name:   cfg.Name,
-		cmd:    cmd,
-		stdin:  stdin,
-		stdout: stdout,
-		stderr: stderr,
-		tools:  make([]Tool, 0),
+		name:    cfg.Name,
+		cmd:     cmd,
+		stdin:   stdin,
+		stdout:  stdout,
+		stderr:  stderr,
+		tools:   make([]Tool, 0),
+		pending: make(map[int]chan Response),
+		nextID:  mcpCallToolRequestID,
 	}
 
 	c.servers[cfg.Name] = server
@@ -130,9 +169,24 @@ func (c *Client) StartServer(cfg config.MCPServer) error {
 }
 
 func (s *Server) initialize() error {
-	initReq := Request{
+	if err := s.sendRequest(initializeRequest()); err != nil {
+		return err
+	}
+
+	listToolsReq := Request{
+		JSONRPC: "2.0",
+		ID:      mcpListToolsRequestID,
+		Method:  "tools/list",
+	}
+
+	return s.sendRequest(listToolsReq)
+}
+
+// initializeRequest opens the MCP handshake.
+func initializeRequest() Request {
+	return Request{
 		JSONRPC: "2.0",
-		ID:      1,
+		ID:      mcpInitializeRequestID,
 		Method:  "initialize",
 		Params: map[string]interface{}{
 			"protocolVersion": "2024-11-05",
@@ -145,18 +199,6 @@ func (s *Server) initialize() error {
 			},
 		},
 	}
-
-	if err := s.sendRequest(initReq); err != nil {
-		return err
-	}
-
-	listToolsReq := Request{
-		JSONRPC: "2.0",
-		ID:      mcpListToolsRequestID,
-		Method:  "tools/list",
-	}
-
-	return s.sendRequest(listToolsReq)
 }
 
 func (s *Server) sendRequest(req Request) error {
@@ -165,6 +207,8 @@ func (s *Server) sendRequest(req Request) error {
 		return err
 	}
 
+	s.writeMu.Lock()
+	defer s.writeMu.Unlock()
 	_, err = s.stdin.Write(append(data, '\n'))
 	return err
 }
@@ -182,6 +226,15 @@ func (s *Server) handleOutput() {
 
 		s.handleResponse(resp)
 	}
+
+	// Calls still waiting won't be answered
+	s.mu.Lock()
+	for _, responses := range s.pending {
+		close(responses)
+	}
+	s.pending = nil
+	s.exited = true
+	s.mu.Unlock()
 }
 
 func (s *Server) handleErrors() {
@@ -192,6 +245,24 @@ func (s *Server) handleErrors() {
 }
 
 func (s *Server) handleResponse(resp Response) {
+	s.mu.Lock()
+	responses, ok := s.pending[resp.ID]
+	delete(s.pending, resp.ID)
+	s.mu.Unlock()
+	if ok {
+		responses <- resp
+		return
+	}
+
+	if resp.ID == mcpInitializeRequestID {
+		s.mu.Lock()
+		if resp.Error != nil {
+			s.initErr = errors.New(resp.Error.Message)
+		} else {
+			s.initialized = true
+		}
+		s.mu.Unlock()
+	}
 	if resp.Error != nil {
 		slog.Error("MCP server error", "server", s.name, "message", resp.Error.Message)
 		return
@@ -221,6 +292,33 @@ func (s *Server) handleResponse(resp Response) {
 	}
 }
 
+// Ready returns nil once the MCP server name completed the initialize handshake and still runs, or
+// why it isn't ready: it failed to start, refused the handshake, exited or hasn't answered yet.
+func (c *Client) Ready(name string) error {
+	c.mu.RLock()
+	server, ok := c.servers[name]
+	err := c.failed[name]
+	c.mu.RUnlock()
+	switch {
+	case err != nil:
+		return fmt.Errorf("failed to start: %w", err)
+	case !ok:
+		return fmt.Errorf("no MCP server is named %q", name)
+	}
+
+	server.mu.RLock()
+	defer server.mu.RUnlock()
+	switch {
+	case server.initErr != nil:
+		return fmt.Errorf("initialize refused: %w", server.initErr)
+	case server.exited:
+		return ErrServerExited
+	case !server.initialized:
+		return errors.New("hasn't answered initialize yet")
+	}
+	return nil
+}
+
 // ListTools returns all available tools from all connected MCP servers.
 func (c *Client) ListTools() []Tool {
 	c.mu.RLock()
@@ -236,67 +334,82 @@ func (c *Client) ListTools() []Tool {
 	return allTools
 }
 
-// CallTool executes a tool on the appropriate MCP server with context cancellation support.
+// CallTool executes a tool on the appropriate MCP server with context cancellation support, and
+// returns the server's result. It returns ErrToolNotFound when no server offers the tool, and a
+// ToolError when the server answers with an error.
 func (c *Client) CallTool(ctx context.Context, name string, args map[string]interface{}) (interface{}, error) {
+	server := c.serverOf(name)
+	if server == nil {
+		return nil, fmt.Errorf("%w: %s", ErrToolNotFound, name)
+	}
+	return server.callTool(ctx, name, args)
+}
+
+// serverOf returns the server offering the tool name, or nil.
+func (c *Client) serverOf(name string) *Server {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
 
 	for _, server := range c.servers {
 		server.mu.RLock()
-		found := false
-		for _, tool := range server.tools {
-			if tool.Name == name {
-				found = true
-				break
-			}
-		}
+		found := slices.ContainsFunc(server.tools, func(tool Tool) bool { return tool.Name == name })
 		server.mu.RUnlock()
-
 		if found {
-			return server.callTool(ctx, name, args)
+			return server
 		}
 	}
-
-	return nil, fmt.Errorf("tool not found: %s", name)
+	return nil
 }
 
 func (s *Server) callTool(ctx context.Context, name string, args map[string]interface{}) (interface{}, error) {
+	s.mu.Lock()
+	if s.pending == nil {
+		s.mu.Unlock()
+		return nil, ErrServerExited
+	}
+	id := s.nextID
+	s.nextID++
+	// Buffered, so the response can be handed over after the call was abandoned
+	responses := make(chan Response, 1)
+	s.pending[id] = responses
+	s.mu.Unlock()
+
 	req := Request{
 		JSONRPC: "2.0",
-		ID:      mcpCallToolRequestID,
+		ID:      id,
 		Method:  "tools/call",
 		Params: map[string]interface{}{
 			"name":      name,
 			"arguments": args,
 		},
 	}
+	if err := s.sendRequest(req); err != nil {
+		s.abandon(id)
+		return nil, err
+	}
 
-	// Create a channel to receive the response
-	responseChan := make(chan interface{}, 1)
-	errorChan := make(chan error, 1)
-
-	// Send request in a goroutine to allow cancellation
-	go func() {
-		if err := s.sendRequest(req); err != nil {
-			errorChan <- err
-			return
-		}
-		// For now, return a success response
-		// In a full implementation, this would read the actual MCP response
-		responseChan <- map[string]interface{}{"success": true}
-	}()
-
-	// Wait for response or context cancellation
 	select {
 	case <-ctx.Done():
+		s.abandon(id)
 		return nil, ctx.Err()
-	case err := <-errorChan:
-		return nil, err
-	case response := <-responseChan:
-		return response, nil
+	case resp, ok := <-responses:
+		if !ok {
+			return nil, ErrServerExited
+		}
+		if resp.Error != nil {
+			return nil, &ToolError{Tool: name, Code: resp.Error.Code, Message: resp.Error.Message}
+		}
+		return resp.Result, nil
 	}
 }
 
+// abandon stops waiting for the response to the request id.
+func (s *Server) abandon(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.pending, id)
+}
+
 // Stop gracefully shuts down all MCP server connections.
 func (c *Client) Stop() {
 	c.mu.Lock()
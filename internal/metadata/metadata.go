@@ -0,0 +1,98 @@
+// Package metadata carries the metadata object clients attach to requests, following the OpenAI spec,
+// so agents can tell modelplex what a request is for, e.g. {"task": "summarize"}. It is recorded with
+// usage events and available to routing without being sent to providers that don't understand it.
+package metadata
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// MaxPairs is the most key-value pairs a request's metadata may hold
+	MaxPairs = 16
+	// MaxKeyLength bounds metadata keys, in characters
+	MaxKeyLength = 64
+	// MaxValueLength bounds metadata values, in characters
+	MaxValueLength = 512
+)
+
+type metadataKey struct{}
+
+type tagsKey struct{}
+
+// With returns a context carrying the request's metadata.
+func With(ctx context.Context, md map[string]string) context.Context {
+	return context.WithValue(ctx, metadataKey{}, md)
+}
+
+// From returns the metadata carried by ctx, or nil when the request had none.
+func From(ctx context.Context) map[string]string {
+	md, _ := ctx.Value(metadataKey{}).(map[string]string)
+	return md
+}
+
+// WithTags returns a context carrying the request's tags.
+func WithTags(ctx context.Context, tags map[string]string) context.Context {
+	return context.WithValue(ctx, tagsKey{}, tags)
+}
+
+// Tags returns the tags carried by ctx, or nil when the request had none.
+func Tags(ctx context.Context) map[string]string {
+	tags, _ := ctx.Value(tagsKey{}).(map[string]string)
+	return tags
+}
+
+// SelectTags returns the pairs of md whose keys are allowed tags, or nil when there are none. Tags
+// label metrics, so a request may carry at most limit of them.
+func SelectTags(md map[string]string, allowed []string, limit int64) (map[string]string, error) {
+	var tags map[string]string
+	for _, key := range allowed {
+		value, ok := md[key]
+		if !ok {
+			continue
+		}
+		if tags == nil {
+			tags = make(map[string]string)
+		}
+		tags[key] = value
+	}
+	if int64(len(tags)) > limit {
+		return nil, fmt.Errorf("metadata may hold at most %d tags (%s), got %d",
+			limit, strings.Join(allowed, ", "), len(tags))
+	}
+	return tags, nil
+}
+
+// Parse checks a decoded metadata object against the OpenAI limits: at most MaxPairs string values,
+// with keys and values no longer than MaxKeyLength and MaxValueLength.
+func Parse(value interface{}) (map[string]string, error) {
+	if value == nil {
+		return nil, nil
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("metadata must be an object")
+	}
+	if len(object) > MaxPairs {
+		return nil, fmt.Errorf("metadata may hold at most %d pairs, got %d", MaxPairs, len(object))
+	}
+
+	md := make(map[string]string, len(object))
+	for key, v := range object {
+		s, ok := v.(string)
+		if !ok {
+			return nil, fmt.Errorf("metadata.%s must be a string", key)
+		}
+		if utf8.RuneCountInString(key) > MaxKeyLength {
+			return nil, fmt.Errorf("metadata key %q is longer than %d characters", key, MaxKeyLength)
+		}
+		if utf8.RuneCountInString(s) > MaxValueLength {
+			return nil, fmt.Errorf("metadata.%s is longer than %d characters", key, MaxValueLength)
+		}
+		md[key] = s
+	}
+	return md, nil
+}
@@ -0,0 +1,58 @@
+package metadata
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	md, err := Parse(map[string]interface{}{"task": "summarize", "agent": "planner"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"task": "summarize", "agent": "planner"}, md)
+
+	md, err = Parse(nil)
+	require.NoError(t, err)
+	assert.Nil(t, md)
+
+	tooMany := make(map[string]interface{})
+	for i := range MaxPairs + 1 {
+		tooMany[strings.Repeat("k", i+1)] = "v"
+	}
+
+	tests := []struct {
+		name  string
+		value interface{}
+		err   string
+	}{
+		{"not an object", "summarize", "metadata must be an object"},
+		{"non-string value", map[string]interface{}{"priority": 1.0}, "metadata.priority must be a string"},
+		{"too many pairs", tooMany, "metadata may hold at most 16 pairs, got 17"},
+		{"long key", map[string]interface{}{strings.Repeat("k", 65): "v"}, "longer than 64 characters"},
+		{"long value", map[string]interface{}{"task": strings.Repeat("é", 513)}, "metadata.task is longer than 512 characters"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := Parse(tt.value)
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tt.err)
+		})
+	}
+}
+
+func TestSelectTags(t *testing.T) {
+	md := map[string]string{"team": "search", "experiment": "b", "task": "summarize"}
+
+	tags, err := SelectTags(md, []string{"team", "experiment", "owner"}, 2)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"team": "search", "experiment": "b"}, tags)
+
+	tags, err = SelectTags(map[string]string{"task": "summarize"}, []string{"team"}, 2)
+	require.NoError(t, err)
+	assert.Nil(t, tags)
+
+	_, err = SelectTags(md, []string{"team", "experiment", "task"}, 2)
+	assert.EqualError(t, err, "metadata may hold at most 2 tags (team, experiment, task), got 3")
+}
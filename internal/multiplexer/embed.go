@@ -0,0 +1,50 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// Embed embeds texts with model, on the first of its embedding providers by priority within the
+// residency requirement of ctx, failing over to the next when one fails.
+func (m *ModelMultiplexer) Embed(
+	ctx context.Context, model string, req *providers.EmbeddingRequest,
+) (*providers.EmbeddingResponse, error) {
+	candidates := m.embedders[model]
+	if allowed := residencyFrom(ctx); len(allowed) > 0 {
+		candidates = slices.DeleteFunc(slices.Clone(candidates), func(e providers.Embedder) bool {
+			return !slices.Contains(allowed, m.jurisdictions[e.Name()])
+		})
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("%w for model %s in jurisdictions %s",
+				providers.ErrNoEmbedder, model, strings.Join(allowed, ", "))
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w for model %s", providers.ErrNoEmbedder, model)
+	}
+
+	var attempts []providers.Attempt
+	var errs []error
+	for i, embedder := range candidates {
+		response, err := embedder.Embed(ctx, model, req)
+		if err == nil || !upstreamFailure(ctx, err) {
+			return response, err
+		}
+		if len(candidates) == 1 {
+			return nil, err
+		}
+		attempts = append(attempts, providers.NewAttempt(embedder.Name(), "", err))
+		errs = append(errs, fmt.Errorf("provider %s: %w", embedder.Name(), err))
+		if i < len(candidates)-1 {
+			slog.Warn("Embedding provider failed, failing over", "model", model, "provider", embedder.Name(),
+				"next", candidates[i+1].Name(), "error", err)
+		}
+	}
+	return nil, providers.NewFailoverError(attempts, errs)
+}
@@ -0,0 +1,42 @@
+package multiplexer
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+func TestEmbed_RoutesApartFromChat(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"detail":"overloaded"}`, http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	jina := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"data":[{"embedding":[0.5],"index":0}],"usage":{"total_tokens":1}}`))
+	}))
+	defer jina.Close()
+
+	mux := New([]config.Provider{
+		{Name: "voyage", Type: "voyage", BaseURL: down.URL, Models: []string{"embed"}},
+		{Name: "jina", Type: "jina", BaseURL: jina.URL, Models: []string{"embed"}, Priority: 1},
+		{Name: "openai", Type: "openai", BaseURL: down.URL, Models: []string{"gpt-4"}},
+	})
+	req := &providers.EmbeddingRequest{Input: []string{"a"}}
+
+	response, err := mux.Embed(t.Context(), "embed", req)
+	require.NoError(t, err)
+	assert.Equal(t, []float64{0.5}, response.Data[0].Embedding, "failed over to jina")
+	assert.Equal(t, []string{"gpt-4"}, mux.ListModels(), "embedding providers serve no completions")
+
+	_, err = mux.Embed(t.Context(), "gpt-4", req)
+	assert.ErrorIs(t, err, providers.ErrNoEmbedder)
+	_, err = mux.Embed(WithResidency(t.Context(), []string{"eu"}), "embed", req)
+	assert.ErrorIs(t, err, providers.ErrNoEmbedder)
+}
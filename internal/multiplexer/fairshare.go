@@ -0,0 +1,285 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"sync"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// fairProvider runs at most a fixed number of generations on a provider at once and hands freed
+// slots to tenants by weighted fair queuing: each tenant's service is the tokens decoded for it
+// divided by its weight, and the waiting tenant with the least service goes next. Agents sharing
+// one local backend each get their share of decode throughput instead of first come, first served.
+type fairProvider struct {
+	providers.Provider
+	slots   int
+	weights map[string]float64
+
+	mtx     sync.Mutex
+	running int
+	waiting []*waiter
+	// service is the weighted tokens decoded per tenant with requests running or waiting;
+	// active counts those requests, and idle tenants are forgotten
+	service map[string]float64
+	active  map[string]int
+}
+
+// waiter is a request queued for a slot; ready is closed once it holds one.
+type waiter struct {
+	tenant string
+	ready  chan struct{}
+}
+
+// Headroom is the capacity left on a scheduled provider, as seen by one tenant.
+type Headroom struct {
+	Provider      string `json:"provider"`
+	MaxConcurrent int    `json:"max_concurrent"`
+	Running       int    `json:"running"`
+	Waiting       int    `json:"waiting"`
+	// TenantRequests counts the tenant's own requests running or waiting
+	TenantRequests int `json:"tenant_requests"`
+	// Available is the number of slots a new request could take without queuing
+	Available int `json:"available"`
+}
+
+// newFairProvider schedules the generations of provider as cfg says.
+func newFairProvider(provider providers.Provider, cfg *config.ProviderScheduling) *fairProvider {
+	return &fairProvider{
+		Provider: provider,
+		slots:    int(cfg.MaxConcurrent),
+		weights:  cfg.Weights,
+		service:  make(map[string]float64),
+		active:   make(map[string]int),
+	}
+}
+
+// ChatCompletion runs a chat completion once its tenant's turn comes.
+func (p *fairProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	tenant := usage.TenantFrom(ctx)
+	if err := p.acquire(ctx, tenant); err != nil {
+		return nil, err
+	}
+	result, err := p.Provider.ChatCompletion(ctx, model, messages)
+	p.release(tenant, completionTokens(result))
+	return result, err
+}
+
+// Completion runs a completion once its tenant's turn comes.
+func (p *fairProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	tenant := usage.TenantFrom(ctx)
+	if err := p.acquire(ctx, tenant); err != nil {
+		return nil, err
+	}
+	result, err := p.Provider.Completion(ctx, model, prompt)
+	p.release(tenant, completionTokens(result))
+	return result, err
+}
+
+// ChatCompletionStream starts a streaming chat completion once its tenant's turn comes.
+func (p *fairProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	tenant := usage.TenantFrom(ctx)
+	if err := p.acquire(ctx, tenant); err != nil {
+		return nil, err
+	}
+	stream, err := p.Provider.ChatCompletionStream(ctx, model, messages)
+	return p.meter(ctx, tenant, stream, err)
+}
+
+// CompletionStream starts a streaming completion once its tenant's turn comes.
+func (p *fairProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	tenant := usage.TenantFrom(ctx)
+	if err := p.acquire(ctx, tenant); err != nil {
+		return nil, err
+	}
+	stream, err := p.Provider.CompletionStream(ctx, model, prompt)
+	return p.meter(ctx, tenant, stream, err)
+}
+
+// meter charges a stream's tenant as chunks arrive, roughly one token each, and keeps its slot
+// until the last chunk has been passed on or ctx is done.
+func (p *fairProvider) meter(
+	ctx context.Context, tenant string, stream <-chan interface{}, err error,
+) (<-chan interface{}, error) {
+	if err != nil {
+		p.release(tenant, 0)
+		return nil, err
+	}
+	out := make(chan interface{})
+	go func() {
+		// The slot is released before out closes, so the next request can start right away
+		defer close(out)
+		defer p.release(tenant, 0)
+		for chunk := range stream {
+			p.charge(tenant, 1)
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// acquire waits for a slot for tenant, or until ctx is done.
+func (p *fairProvider) acquire(ctx context.Context, tenant string) error {
+	p.mtx.Lock()
+	if p.active[tenant] == 0 {
+		// A tenant returning from idle starts level with the others rather than with credit
+		// for the time it sent nothing, which would let it crowd them out
+		p.service[tenant] = p.floorLocked()
+	}
+	p.active[tenant]++
+	if p.running < p.slots && len(p.waiting) == 0 {
+		p.running++
+		p.mtx.Unlock()
+		return nil
+	}
+	w := &waiter{tenant: tenant, ready: make(chan struct{})}
+	p.waiting = append(p.waiting, w)
+	waiting := len(p.waiting)
+	p.mtx.Unlock()
+
+	slog.Debug("Queued request for a fair share of the provider",
+		"provider", p.Name(), "tenant", tenant, "waiting", waiting)
+	select {
+	case <-w.ready:
+		return nil
+	case <-ctx.Done():
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		select {
+		case <-w.ready:
+			// Granted while giving up, so the slot passes on
+			p.running--
+		default:
+			p.removeLocked(w)
+		}
+		p.leaveLocked(tenant)
+		p.grantLocked()
+		return ctx.Err()
+	}
+}
+
+// release frees tenant's slot, charging tokens decoded that weren't charged while streaming.
+func (p *fairProvider) release(tenant string, tokens float64) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	p.service[tenant] += tokens / p.weight(tenant)
+	p.running--
+	p.leaveLocked(tenant)
+	p.grantLocked()
+}
+
+func (p *fairProvider) charge(tenant string, tokens float64) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.service[tenant] += tokens / p.weight(tenant)
+}
+
+// grantLocked hands free slots to the waiting tenants with the least service, oldest request first.
+func (p *fairProvider) grantLocked() {
+	for p.running < p.slots && len(p.waiting) > 0 {
+		// Waiters are in arrival order, so ties go to the oldest
+		next := 0
+		for i, w := range p.waiting[1:] {
+			if p.service[w.tenant] < p.service[p.waiting[next].tenant] {
+				next = i + 1
+			}
+		}
+		w := p.waiting[next]
+		p.waiting = append(p.waiting[:next], p.waiting[next+1:]...)
+		p.running++
+		close(w.ready)
+	}
+}
+
+func (p *fairProvider) removeLocked(w *waiter) {
+	for i, queued := range p.waiting {
+		if queued == w {
+			p.waiting = append(p.waiting[:i], p.waiting[i+1:]...)
+			return
+		}
+	}
+}
+
+// headroom returns the capacity of the provider left for tenant.
+func (p *fairProvider) headroom(tenant string) Headroom {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	return Headroom{
+		Provider:       p.Name(),
+		MaxConcurrent:  p.slots,
+		Running:        p.running,
+		Waiting:        len(p.waiting),
+		TenantRequests: p.active[tenant],
+		Available:      p.slots - p.running,
+	}
+}
+
+// leaveLocked forgets tenant once it has nothing running or waiting.
+func (p *fairProvider) leaveLocked(tenant string) {
+	p.active[tenant]--
+	if p.active[tenant] == 0 {
+		delete(p.active, tenant)
+		delete(p.service, tenant)
+	}
+}
+
+// floorLocked returns the least service among active tenants, or 0 when none is active.
+func (p *fairProvider) floorLocked() float64 {
+	floor := math.Inf(1)
+	for tenant := range p.active {
+		floor = math.Min(floor, p.service[tenant])
+	}
+	if math.IsInf(floor, 1) {
+		return 0
+	}
+	return floor
+}
+
+func (p *fairProvider) weight(tenant string) float64 {
+	if weight, ok := p.weights[tenant]; ok {
+		return weight
+	}
+	return 1
+}
+
+// completionTokens returns the tokens a non-streaming response decoded, or 1 when it doesn't say.
+func completionTokens(result interface{}) float64 {
+	response, _ := result.(map[string]interface{})
+	counts, _ := response["usage"].(map[string]interface{})
+	if tokens, ok := counts["completion_tokens"].(float64); ok {
+		return tokens
+	}
+	return 1
+}
+
+// CountTokens counts tokens without a slot, since counting decodes nothing.
+func (p *fairProvider) CountTokens(
+	ctx context.Context, model string, request map[string]interface{},
+) (int64, error) {
+	return providers.CountTokens(ctx, p.Provider, model, request)
+}
+
+// Forward sends a raw request through the provider without a slot; raw requests aren't scheduled.
+func (p *fairProvider) Forward(req *http.Request, path string) (*http.Response, error) {
+	forwarder, ok := p.Provider.(providers.RawForwarder)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support raw requests", p.Name())
+	}
+	return forwarder.Forward(req, path)
+}
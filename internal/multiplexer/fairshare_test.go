@@ -0,0 +1,207 @@
+package multiplexer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// gatedProvider holds every completion until the test answers it with a number of decoded tokens.
+type gatedProvider struct {
+	providers.Provider
+	calls chan gatedCall
+}
+
+type gatedCall struct {
+	tenant string
+	answer chan float64
+}
+
+func (p *gatedProvider) Name() string { return "local" }
+
+func (p *gatedProvider) ChatCompletion(
+	ctx context.Context, _ string, _ []map[string]interface{},
+) (interface{}, error) {
+	call := gatedCall{tenant: usage.TenantFrom(ctx), answer: make(chan float64)}
+	p.calls <- call
+	tokens := <-call.answer
+	return map[string]interface{}{"usage": map[string]interface{}{"completion_tokens": tokens}}, nil
+}
+
+func (p *gatedProvider) ChatCompletionStream(
+	_ context.Context, _ string, _ []map[string]interface{},
+) (<-chan interface{}, error) {
+	stream := make(chan interface{})
+	p.calls <- gatedCall{answer: make(chan float64)}
+	close(stream)
+	return stream, nil
+}
+
+// queued returns the number of requests waiting for a slot.
+func queued(p *fairProvider) int {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	return len(p.waiting)
+}
+
+func TestFairProvider_LeastServedTenantGoesFirst(t *testing.T) {
+	upstream := &gatedProvider{calls: make(chan gatedCall)}
+	p := newFairProvider(upstream, &config.ProviderScheduling{MaxConcurrent: 1})
+
+	send := func(tenant string) {
+		go func() {
+			_, err := p.ChatCompletion(usage.WithTenant(t.Context(), tenant), "llama2", nil)
+			assert.NoError(t, err)
+		}()
+	}
+
+	send("busy")
+	first := <-upstream.calls
+	send("busy")
+	require.Eventually(t, func() bool { return queued(p) == 1 }, time.Second, time.Millisecond)
+	send("quiet")
+	require.Eventually(t, func() bool { return queued(p) == 2 }, time.Second, time.Millisecond)
+
+	// busy decoded 100 tokens, so quiet is served before busy's second request despite arriving later
+	first.answer <- 100
+	next := <-upstream.calls
+	assert.Equal(t, "quiet", next.tenant)
+	next.answer <- 10
+	last := <-upstream.calls
+	assert.Equal(t, "busy", last.tenant)
+	last.answer <- 10
+}
+
+func TestFairProvider_Weights(t *testing.T) {
+	p := newFairProvider(&gatedProvider{}, &config.ProviderScheduling{
+		MaxConcurrent: 1,
+		Weights:       map[string]float64{"premium": 4},
+	})
+	p.active = map[string]int{"premium": 1, "standard": 1}
+	p.charge("premium", 40)
+	p.charge("standard", 20)
+
+	standard := &waiter{tenant: "standard", ready: make(chan struct{})}
+	premium := &waiter{tenant: "premium", ready: make(chan struct{})}
+	p.waiting = []*waiter{standard, premium}
+	p.grantLocked()
+
+	// premium decoded twice as much, but at four times the weight its share is half used
+	assert.Len(t, p.waiting, 1)
+	assert.Same(t, standard, p.waiting[0])
+	select {
+	case <-premium.ready:
+	default:
+		t.Fatal("premium was not granted the slot")
+	}
+}
+
+func TestFairProvider_CancelWhileWaiting(t *testing.T) {
+	upstream := &gatedProvider{calls: make(chan gatedCall)}
+	p := newFairProvider(upstream, &config.ProviderScheduling{MaxConcurrent: 1})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, err := p.ChatCompletion(t.Context(), "llama2", nil)
+		assert.NoError(t, err)
+	}()
+	holder := <-upstream.calls
+
+	ctx, cancel := context.WithCancel(t.Context())
+	errs := make(chan error)
+	go func() {
+		_, err := p.ChatCompletion(ctx, "llama2", nil)
+		errs <- err
+	}()
+	require.Eventually(t, func() bool { return queued(p) == 1 }, time.Second, time.Millisecond)
+	cancel()
+	assert.ErrorIs(t, <-errs, context.Canceled)
+	assert.Zero(t, queued(p))
+
+	holder.answer <- 1
+	<-done
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	assert.Zero(t, p.running)
+	assert.Empty(t, p.active, "idle tenants are forgotten")
+}
+
+func TestFairProvider_StreamReleasesSlotWhenDrained(t *testing.T) {
+	upstream := &gatedProvider{calls: make(chan gatedCall, 1)}
+	p := newFairProvider(upstream, &config.ProviderScheduling{MaxConcurrent: 1})
+
+	stream, err := p.ChatCompletionStream(t.Context(), "llama2", nil)
+	require.NoError(t, err)
+	for range stream {
+	}
+
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	assert.Zero(t, p.running)
+}
+
+func TestNew_Scheduling(t *testing.T) {
+	mux := New([]config.Provider{
+		{Name: "local", Type: "ollama", BaseURL: "http://localhost:11434", Scheduling: config.ProviderScheduling{MaxConcurrent: 2}},
+		{Name: "cloud", Type: "openai", BaseURL: "https://api.openai.com/v1"},
+	})
+
+	local, ok := mux.Provider("local")
+	require.True(t, ok)
+	assert.IsType(t, &fairProvider{}, local)
+	cloud, ok := mux.Provider("cloud")
+	require.True(t, ok)
+	_, scheduled := cloud.(*fairProvider)
+	assert.False(t, scheduled)
+}
+
+func TestFairProvider_Headroom(t *testing.T) {
+	p := newFairProvider(&gatedProvider{}, &config.ProviderScheduling{MaxConcurrent: 2})
+	assert.Equal(t, Headroom{Provider: "local", MaxConcurrent: 2, Available: 2}, p.headroom("a"))
+
+	require.NoError(t, p.acquire(t.Context(), "a"))
+	assert.Equal(t, Headroom{Provider: "local", MaxConcurrent: 2, Running: 1, TenantRequests: 1, Available: 1},
+		p.headroom("a"))
+	assert.Equal(t, Headroom{Provider: "local", MaxConcurrent: 2, Running: 1, Available: 1}, p.headroom("b"))
+
+	require.NoError(t, p.acquire(t.Context(), "b"))
+	assert.Equal(t, Headroom{Provider: "local", MaxConcurrent: 2, Running: 2, TenantRequests: 1}, p.headroom("b"))
+}
+
+// assertForwards checks that the provider cfg configures, served by a test backend, passes raw
+// requests through.
+func assertForwards(t *testing.T, cfg config.Provider) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/files", r.URL.Path)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	t.Cleanup(server.Close)
+	cfg.BaseURL = server.URL + "/api"
+	mux := New([]config.Provider{cfg})
+
+	provider, ok := mux.Provider(cfg.Name)
+	require.True(t, ok)
+	forwarder, ok := provider.(providers.RawForwarder)
+	require.True(t, ok, "%T doesn't forward raw requests", provider)
+	resp, err := forwarder.Forward(httptest.NewRequest("GET", "/providers/"+cfg.Name+"/raw/files", http.NoBody), "/files")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusAccepted, resp.StatusCode)
+}
+
+func TestFairProvider_Forward(t *testing.T) {
+	assertForwards(t, config.Provider{
+		Name: "local", Type: "openai", Scheduling: config.ProviderScheduling{MaxConcurrent: 1},
+	})
+}
@@ -0,0 +1,106 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/journal"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// WithJournal records every provider failure in j.
+func WithJournal(j *journal.Journal) Option {
+	return func(m *ModelMultiplexer) {
+		m.journal = j
+	}
+}
+
+// journaledProvider records the failures of a provider, or of one region of it, in a journal,
+// along with the response headers the provider captured, such as the upstream request id.
+type journaledProvider struct {
+	providers.Provider
+	region  string
+	journal *journal.Journal
+	now     func() time.Time
+}
+
+// journaled wraps provider so its failures are recorded in j, or returns it as is when j is nil.
+func journaled(provider providers.Provider, region string, j *journal.Journal) providers.Provider {
+	if j == nil {
+		return provider
+	}
+	return &journaledProvider{Provider: provider, region: region, journal: j, now: time.Now}
+}
+
+// ChatCompletion performs a chat completion, recording its failure.
+func (p *journaledProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	start := p.now()
+	ctx, headers := providers.WithResponseHeaders(ctx)
+	result, err := p.Provider.ChatCompletion(ctx, model, messages)
+	p.record(ctx, model, start, headers, err)
+	return result, err
+}
+
+// Completion performs a completion, recording its failure.
+func (p *journaledProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	start := p.now()
+	ctx, headers := providers.WithResponseHeaders(ctx)
+	result, err := p.Provider.Completion(ctx, model, prompt)
+	p.record(ctx, model, start, headers, err)
+	return result, err
+}
+
+// ChatCompletionStream starts a streaming chat completion, recording a failure to start it.
+func (p *journaledProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	start := p.now()
+	ctx, headers := providers.WithResponseHeaders(ctx)
+	stream, err := p.Provider.ChatCompletionStream(ctx, model, messages)
+	p.record(ctx, model, start, headers, err)
+	return stream, err
+}
+
+// CompletionStream starts a streaming completion, recording a failure to start it.
+func (p *journaledProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	start := p.now()
+	ctx, headers := providers.WithResponseHeaders(ctx)
+	stream, err := p.Provider.CompletionStream(ctx, model, prompt)
+	p.record(ctx, model, start, headers, err)
+	return stream, err
+}
+
+// Forward sends a raw request through the provider; raw requests aren't journaled.
+func (p *journaledProvider) Forward(req *http.Request, path string) (*http.Response, error) {
+	forwarder, ok := p.Provider.(providers.RawForwarder)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support raw requests", p.Name())
+	}
+	return forwarder.Forward(req, path)
+}
+
+// CountTokens counts tokens through the provider; counting isn't journaled.
+func (p *journaledProvider) CountTokens(
+	ctx context.Context, model string, request map[string]interface{},
+) (int64, error) {
+	return providers.CountTokens(ctx, p.Provider, model, request)
+}
+
+func (p *journaledProvider) record(
+	ctx context.Context, model string, start time.Time, headers *providers.ResponseHeaders, err error,
+) {
+	if err == nil || !upstreamFailure(ctx, err) {
+		return
+	}
+	p.journal.Record(journal.Entry{
+		Time:      start,
+		Model:     model,
+		LatencyMS: p.now().Sub(start).Milliseconds(),
+		Attempt:   providers.NewAttempt(p.Name(), p.region, err),
+		Headers:   headers.Values(),
+	})
+}
@@ -0,0 +1,53 @@
+package multiplexer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/journal"
+)
+
+func TestJournal_RecordsProviderFailures(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("X-Request-Id", "req_123")
+		http.Error(w, `{"error": "overloaded"}`, http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[]}`))
+	}))
+	defer up.Close()
+
+	j := journal.New(10)
+	mux := New([]config.Provider{{
+		Name: "openai", Type: "openai", Models: []string{"gpt-4"},
+		Regions:        []config.ProviderRegion{{Name: "us", BaseURL: down.URL}, {Name: "eu", BaseURL: up.URL}},
+		CaptureHeaders: []string{"x-request-id"},
+	}}, WithJournal(j))
+
+	_, err := mux.ChatCompletion(t.Context(), "gpt-4", []map[string]interface{}{{"role": "user", "content": "Hi"}})
+	require.NoError(t, err)
+
+	entries := j.Query("openai", time.Time{})
+	require.Len(t, entries, 1)
+	assert.Equal(t, "gpt-4", entries[0].Model)
+	assert.Equal(t, "us", entries[0].Region)
+	assert.Equal(t, http.StatusServiceUnavailable, entries[0].StatusCode)
+	assert.Equal(t, `{"error": "overloaded"}`, entries[0].Error)
+	assert.Equal(t, map[string]string{"x-request-id": "req_123"}, entries[0].Headers)
+
+	// A request the caller gave up on says nothing about the provider
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = mux.ChatCompletion(ctx, "gpt-4", []map[string]interface{}{{"role": "user", "content": "Hi"}})
+	require.Error(t, err)
+	assert.Len(t, j.Query("", time.Time{}), 1)
+}
@@ -0,0 +1,187 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// idleProvider tracks when each model of a local backend was last used, so Maintain can unload
+// the models that have gone unused for too long. A model is in use until its response, or the
+// last chunk of its stream, has been passed on.
+type idleProvider struct {
+	providers.Provider
+	unloader providers.ModelUnloader
+	idle     time.Duration
+	// upstream maps public model names to the backend's
+	upstream map[string]string
+
+	mtx sync.Mutex
+	// active counts the requests in progress per model, and lastUsed is when the last one ended
+	active   map[string]int
+	lastUsed map[string]time.Time
+	now      func() time.Time
+}
+
+// newIdleProvider unloads the models of provider as cfg says, or returns nil when its backend
+// can't unload models.
+func newIdleProvider(provider providers.Provider, cfg *config.Provider) *idleProvider {
+	unloader := providers.NewModelUnloader(cfg)
+	if unloader == nil {
+		return nil
+	}
+	return &idleProvider{
+		Provider: provider,
+		unloader: unloader,
+		idle:     time.Duration(cfg.KeepAlive.IdleSeconds) * time.Second,
+		upstream: cfg.ModelMap,
+		active:   make(map[string]int),
+		lastUsed: make(map[string]time.Time),
+		now:      time.Now,
+	}
+}
+
+// ChatCompletion performs a chat completion, keeping model in use meanwhile.
+func (p *idleProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	p.use(model)
+	defer p.release(model)
+	return p.Provider.ChatCompletion(ctx, model, messages)
+}
+
+// Completion performs a completion, keeping model in use meanwhile.
+func (p *idleProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	p.use(model)
+	defer p.release(model)
+	return p.Provider.Completion(ctx, model, prompt)
+}
+
+// ChatCompletionStream performs a streaming chat completion, keeping model in use until it ends.
+func (p *idleProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	p.use(model)
+	stream, err := p.Provider.ChatCompletionStream(ctx, model, messages)
+	return p.watch(ctx, model, stream, err)
+}
+
+// CompletionStream performs a streaming completion, keeping model in use until it ends.
+func (p *idleProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	p.use(model)
+	stream, err := p.Provider.CompletionStream(ctx, model, prompt)
+	return p.watch(ctx, model, stream, err)
+}
+
+// CountTokens counts tokens without using the model, since counting loads nothing.
+func (p *idleProvider) CountTokens(
+	ctx context.Context, model string, request map[string]interface{},
+) (int64, error) {
+	return providers.CountTokens(ctx, p.Provider, model, request)
+}
+
+// Forward sends a raw request through the provider without using a model, since which one a raw
+// request loads isn't known.
+func (p *idleProvider) Forward(req *http.Request, path string) (*http.Response, error) {
+	forwarder, ok := p.Provider.(providers.RawForwarder)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support raw requests", p.Name())
+	}
+	return forwarder.Forward(req, path)
+}
+
+// watch passes stream on, releasing model once it ends or ctx is done.
+func (p *idleProvider) watch(
+	ctx context.Context, model string, stream <-chan interface{}, err error,
+) (<-chan interface{}, error) {
+	if err != nil {
+		p.release(model)
+		return nil, err
+	}
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		defer p.release(model)
+		for chunk := range stream {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				drain(stream)
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (p *idleProvider) use(model string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.active[model]++
+}
+
+func (p *idleProvider) release(model string) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+	p.active[model]--
+	if p.active[model] == 0 {
+		delete(p.active, model)
+	}
+	p.lastUsed[model] = p.now()
+}
+
+// run unloads idle models every half of the idle period until ctx is done, so a model is unloaded
+// between one and one and a half idle periods after its last use.
+func (p *idleProvider) run(ctx context.Context) {
+	ticker := time.NewTicker(max(p.idle/2, time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			p.unloadIdle(ctx)
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// unloadIdle unloads the models that have gone unused for the idle period. A model that fails to
+// unload is tried again next time.
+func (p *idleProvider) unloadIdle(ctx context.Context) {
+	var idle []string
+	p.mtx.Lock()
+	now := p.now()
+	for model, used := range p.lastUsed {
+		if p.active[model] == 0 && now.Sub(used) >= p.idle {
+			idle = append(idle, model)
+		}
+	}
+	p.mtx.Unlock()
+
+	for _, model := range idle {
+		upstream := model
+		if name, ok := p.upstream[model]; ok {
+			upstream = name
+		}
+		if err := p.unloader.Unload(ctx, upstream); err != nil {
+			if ctx.Err() == nil {
+				slog.Warn("Failed to unload idle model", "provider", p.Name(), "model", upstream, "error", err)
+			}
+			continue
+		}
+		slog.Info("Unloaded idle model", "provider", p.Name(), "model", upstream, "idle", p.idle)
+
+		p.mtx.Lock()
+		// Used again while unloading, it stays tracked and is loaded back by that request
+		if p.active[model] == 0 && !p.lastUsed[model].After(now) {
+			delete(p.lastUsed, model)
+		}
+		p.mtx.Unlock()
+	}
+}
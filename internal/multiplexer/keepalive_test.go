@@ -0,0 +1,83 @@
+package multiplexer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestIdleProvider_UnloadsUnusedModels(t *testing.T) {
+	var mtx sync.Mutex
+	var unloaded []interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if keepAlive, ok := body["keep_alive"]; ok && keepAlive == float64(0) {
+			mtx.Lock()
+			unloaded = append(unloaded, body["model"])
+			mtx.Unlock()
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if body["stream"] == true {
+			_, _ = w.Write([]byte(`{"response":"Hi","done":false}` + "\n" + `{"response":"","done":true}` + "\n"))
+			return
+		}
+		_, _ = w.Write([]byte(`{"response":"Hi","done":true}`))
+	}))
+	defer server.Close()
+
+	mux := New([]config.Provider{{
+		Name: "local", Type: "ollama", BaseURL: server.URL, Models: []string{"llama2", "gpt-4o-mini"},
+		ModelMap:  map[string]string{"gpt-4o-mini": "llama3.1:8b-instruct"},
+		KeepAlive: config.ProviderKeepAlive{IdleSeconds: 60},
+	}})
+	require.Len(t, mux.idle, 1)
+	p := mux.idle[0]
+	now := time.Unix(1700000000, 0)
+	p.now = func() time.Time { return now }
+	unloads := func() []interface{} {
+		p.unloadIdle(t.Context())
+		mtx.Lock()
+		defer mtx.Unlock()
+		got := unloaded
+		unloaded = nil
+		return got
+	}
+
+	_, err := mux.Completion(t.Context(), "llama2", "Hello")
+	require.NoError(t, err)
+	_, err = mux.Completion(t.Context(), "gpt-4o-mini", "Hello")
+	require.NoError(t, err)
+	stream, err := mux.CompletionStream(t.Context(), "llama2", "Hello")
+	require.NoError(t, err)
+	<-stream
+
+	now = now.Add(time.Minute)
+	assert.Equal(t, []interface{}{"llama3.1:8b-instruct"}, unloads(),
+		"the backend's name is unloaded, and a model streaming stays loaded")
+	assert.Empty(t, unloads(), "unloaded models are forgotten")
+
+	drain(stream)
+	require.Eventually(t, func() bool {
+		p.mtx.Lock()
+		defer p.mtx.Unlock()
+		return p.active["llama2"] == 0
+	}, time.Second, 10*time.Millisecond)
+	assert.Empty(t, unloads(), "the stream just ended")
+	now = now.Add(time.Minute)
+	assert.Equal(t, []interface{}{"llama2"}, unloads())
+}
+
+func TestIdleProvider_Forward(t *testing.T) {
+	assertForwards(t, config.Provider{
+		Name: "local", Type: "ollama", KeepAlive: config.ProviderKeepAlive{IdleSeconds: 60},
+	})
+}
@@ -4,41 +4,162 @@ package multiplexer
 import (
 	"context"
 	"fmt"
-	"sort"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/sync/errgroup"
 
 	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/events"
+	"github.com/modelplex/modelplex/internal/journal"
 	"github.com/modelplex/modelplex/internal/providers"
 )
 
+const (
+	// defaultListTimeout bounds how long a single provider may take to list its models
+	defaultListTimeout = 5 * time.Second
+)
+
 // ModelMultiplexer routes requests to appropriate AI providers based on model names.
 type ModelMultiplexer struct {
-	providers []providers.Provider
-	modelMap  map[string]providers.Provider
+	providers   []providers.Provider
+	modelMap    map[string]providers.Provider
+	listTimeout time.Duration
+	// modelProviders lists every provider of a model and jurisdictions tags providers by name,
+	// both for routing requests with a data residency requirement
+	modelProviders map[string][]providers.Provider
+	jurisdictions  map[string]string
+	// standby names the providers held back until a model's primaries are all unhealthy
+	standby  map[string]bool
+	health   *health
+	notifier *events.Notifier
+	// routes maps models to their configured route, built from routeConfigs once providers exist
+	routes       map[string]*modelRoute
+	routeConfigs map[string]config.ModelRoute
+	// streamRestarts bounds how often a stream failing before its first token is started again
+	streamRestarts int64
+	restartStats   *RestartStats
+	// streamSalvages bounds how often a stream failing mid-output is continued on a fallback provider
+	streamSalvages int64
+	// journal records provider failures; nil records nothing
+	journal *journal.Journal
+	// backends tracks the resources of local backends with telemetry by provider name
+	backends map[string]*backend
+	// idle tracks the models of local backends that unload them once unused
+	idle []*idleProvider
+	// rerankers lists the providers that rerank with each model, by priority
+	rerankers map[string][]providers.Reranker
+	// embedders lists the providers that embed with each model, by priority
+	embedders map[string][]providers.Embedder
+	// slugServers lists the providers serving any vendor/model slug no provider claims, by priority
+	slugServers []providers.Provider
+}
+
+// Option configures a ModelMultiplexer.
+type Option func(*ModelMultiplexer)
+
+// WithNotifier emits standby promotions and demotions to notifier.
+func WithNotifier(notifier *events.Notifier) Option {
+	return func(m *ModelMultiplexer) {
+		m.notifier = notifier
+	}
 }
 
 // New creates a new model multiplexer with the given provider configurations.
-func New(configs []config.Provider) *ModelMultiplexer {
+func New(configs []config.Provider, opts ...Option) *ModelMultiplexer {
 	m := &ModelMultiplexer{
-		providers: make([]providers.Provider, 0),
-		modelMap:  make(map[string]providers.Provider),
+		providers:      make([]providers.Provider, 0),
+		modelMap:       make(map[string]providers.Provider),
+		listTimeout:    defaultListTimeout,
+		modelProviders: make(map[string][]providers.Provider),
+		jurisdictions:  make(map[string]string),
+		standby:        make(map[string]bool),
+		health:         newHealth(),
+		routes:         make(map[string]*modelRoute),
+		backends:       make(map[string]*backend),
+		rerankers:      make(map[string][]providers.Reranker),
+		embedders:      make(map[string][]providers.Embedder),
+	}
+	for _, opt := range opts {
+		opt(m)
 	}
 
+	// Providers are kept in priority order, so the first primary of a model without a route serves it
+	configs = slices.Clone(configs)
+	slices.SortStableFunc(configs, func(a, b config.Provider) int { return a.Priority - b.Priority })
+
 	for _, cfg := range configs {
-		provider := providers.NewProvider(&cfg)
+		if reranker := providers.NewReranker(&cfg); reranker != nil {
+			m.jurisdictions[cfg.Name] = cfg.Jurisdiction
+			for _, model := range cfg.Models {
+				if providers.Reranks(&cfg, model) {
+					m.rerankers[model] = append(m.rerankers[model], reranker)
+				}
+			}
+		}
+		if embedder := providers.NewEmbedder(&cfg); embedder != nil {
+			m.jurisdictions[cfg.Name] = cfg.Jurisdiction
+			for _, model := range cfg.Models {
+				m.embedders[model] = append(m.embedders[model], embedder)
+			}
+		}
+
+		var provider providers.Provider
+		if len(cfg.Regions) > 0 {
+			provider = newRegionalProvider(&cfg, m.journal)
+		} else if provider = providers.NewProvider(&cfg); provider != nil {
+			provider = journaled(provider, "", m.journal)
+			if cfg.KeepAlive.IdleSeconds > 0 {
+				if idle := newIdleProvider(provider, &cfg); idle != nil {
+					m.idle = append(m.idle, idle)
+					provider = idle
+				}
+			}
+		}
+		if provider != nil && (cfg.Throttle.TokensPerSecond > 0 || len(cfg.Throttle.Tenants) > 0) {
+			// Inside fair scheduling, so a throttled generation keeps its slot while held back
+			provider = newThrottledProvider(provider, &cfg.Throttle)
+		}
+		if provider != nil && cfg.Scheduling.MaxConcurrent > 0 {
+			provider = newFairProvider(provider, &cfg.Scheduling)
+		}
 		if provider != nil {
 			m.providers = append(m.providers, provider)
+			m.jurisdictions[cfg.Name] = cfg.Jurisdiction
+			if b := newBackend(&cfg); b != nil && len(cfg.Regions) == 0 {
+				m.backends[cfg.Name] = b
+			}
+			if cfg.Standby {
+				m.standby[cfg.Name] = true
+			}
+			if providers.ServesSlugs(&cfg) {
+				m.slugServers = append(m.slugServers, provider)
+			}
 
 			for _, model := range cfg.Models {
-				if _, exists := m.modelMap[model]; !exists {
+				if providers.Reranks(&cfg, model) {
+					continue
+				}
+				if _, exists := m.modelMap[model]; !exists && !cfg.Standby {
 					m.modelMap[model] = provider
 				}
+				m.modelProviders[model] = append(m.modelProviders[model], provider)
 			}
 		}
 	}
 
-	sort.Slice(m.providers, func(i, j int) bool {
-		return m.providers[i].Priority() < m.providers[j].Priority()
-	})
+	// A model only standbys serve has no primaries to wait for
+	for model, candidates := range m.modelProviders {
+		if _, exists := m.modelMap[model]; !exists {
+			m.modelMap[model] = candidates[0]
+		}
+	}
+
+	for model, route := range m.routeConfigs {
+		m.routes[model] = m.newModelRoute(route)
+	}
 
 	return m
 }
@@ -49,13 +170,54 @@ func (m *ModelMultiplexer) GetProvider(model string) (providers.Provider, error)
 		return provider, nil
 	}
 
-	if len(m.providers) > 0 {
-		return m.providers[0], nil
+	// Unknown models go to the first primary by priority, among the providers serving any slug for
+	// vendor/model slugs
+	candidates := m.slugCandidates(model)
+	if len(candidates) == 0 {
+		candidates = m.providers
+	}
+	for _, provider := range candidates {
+		if !m.standby[provider.Name()] {
+			return provider, nil
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0], nil
 	}
 
 	return nil, fmt.Errorf("no provider available for model: %s", model)
 }
 
+// slugCandidates returns the providers serving any vendor/model slug, such as
+// anthropic/claude-sonnet-4, when model is one.
+func (m *ModelMultiplexer) slugCandidates(model string) []providers.Provider {
+	if !strings.Contains(model, "/") {
+		return nil
+	}
+	return m.slugServers
+}
+
+// Provider returns the configured provider with the given name.
+func (m *ModelMultiplexer) Provider(name string) (providers.Provider, bool) {
+	for _, provider := range m.providers {
+		if provider.Name() == name {
+			return provider, true
+		}
+	}
+	return nil, false
+}
+
+// Headroom returns the capacity left for tenant on every provider with fair scheduling, by priority.
+func (m *ModelMultiplexer) Headroom(tenant string) []Headroom {
+	var headroom []Headroom
+	for _, provider := range m.providers {
+		if fair, ok := provider.(*fairProvider); ok {
+			headroom = append(headroom, fair.headroom(tenant))
+		}
+	}
+	return headroom
+}
+
 // ListModels returns all available models from all configured providers.
 func (m *ModelMultiplexer) ListModels() []string {
 	models := make([]string, 0, len(m.modelMap))
@@ -65,46 +227,97 @@ func (m *ModelMultiplexer) ListModels() []string {
 	return models
 }
 
+// ListModelsContext queries all providers concurrently and merges their models in priority order.
+// Each provider gets its own timeout derived from ctx, so one hung provider cannot stall the listing;
+// providers that fail or time out are reported as warnings, with whatever models they still returned.
+func (m *ModelMultiplexer) ListModelsContext(ctx context.Context) (models, warnings []string) {
+	results := make([][]providers.Model, len(m.providers))
+	errs := make([]error, len(m.providers))
+
+	var g errgroup.Group
+	for i, provider := range m.providers {
+		g.Go(func() error {
+			results[i], errs[i] = m.listProviderModels(ctx, provider)
+			return nil
+		})
+	}
+	_ = g.Wait() // Per-provider errors are collected in errs, never returned
+
+	seen := make(map[string]bool)
+	models = make([]string, 0)
+	for i, provider := range m.providers {
+		if errs[i] != nil {
+			slog.Warn("Failed to list provider models", "provider", provider.Name(), "error", errs[i])
+			warnings = append(warnings, fmt.Sprintf("provider %s: %v", provider.Name(), errs[i]))
+		}
+		for _, model := range results[i] {
+			if !seen[model.ID] {
+				seen[model.ID] = true
+				models = append(models, model.ID)
+			}
+		}
+	}
+
+	return models, warnings
+}
+
+func (m *ModelMultiplexer) listProviderModels(
+	ctx context.Context, provider providers.Provider,
+) ([]providers.Model, error) {
+	timeout := m.listTimeout
+	if timeout <= 0 {
+		timeout = defaultListTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	models, err := provider.ListModels(ctx)
+	if err != nil {
+		return models, fmt.Errorf("listing models: %w", err)
+	}
+	return models, nil
+}
+
 // ChatCompletion routes a chat completion request to the appropriate provider.
 func (m *ModelMultiplexer) ChatCompletion(
 	ctx context.Context, model string, messages []map[string]interface{},
 ) (interface{}, error) {
-	provider, err := m.GetProvider(model)
-	if err != nil {
-		return nil, err
-	}
-
-	return provider.ChatCompletion(ctx, model, messages)
+	return serve(ctx, m, model, func(provider providers.Provider) (interface{}, error) {
+		return provider.ChatCompletion(ctx, model, messages)
+	})
 }
 
 // Completion routes a completion request to the appropriate provider.
 func (m *ModelMultiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
-	provider, err := m.GetProvider(model)
-	if err != nil {
-		return nil, err
-	}
-
-	return provider.Completion(ctx, model, prompt)
+	return serve(ctx, m, model, func(provider providers.Provider) (interface{}, error) {
+		return provider.Completion(ctx, model, prompt)
+	})
 }
 
 // ChatCompletionStream routes a streaming chat completion request to the appropriate provider.
 func (m *ModelMultiplexer) ChatCompletionStream(
 	ctx context.Context, model string, messages []map[string]interface{},
 ) (<-chan interface{}, error) {
-	provider, err := m.GetProvider(model)
-	if err != nil {
-		return nil, err
+	var served providers.Provider
+	stream, err := m.restartable(ctx, model, func(provider providers.Provider) (<-chan interface{}, error) {
+		served = provider
+		return provider.ChatCompletionStream(ctx, model, messages)
+	})
+	if err != nil || m.streamSalvages <= 0 {
+		return stream, err
 	}
-
-	return provider.ChatCompletionStream(ctx, model, messages)
+	return m.salvage(ctx, model, true, served, stream, chatContinuation(ctx, model, messages)), nil
 }
 
 // CompletionStream routes a streaming completion request to the appropriate provider.
 func (m *ModelMultiplexer) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
-	provider, err := m.GetProvider(model)
-	if err != nil {
-		return nil, err
+	var served providers.Provider
+	stream, err := m.restartable(ctx, model, func(provider providers.Provider) (<-chan interface{}, error) {
+		served = provider
+		return provider.CompletionStream(ctx, model, prompt)
+	})
+	if err != nil || m.streamSalvages <= 0 {
+		return stream, err
 	}
-
-	return provider.CompletionStream(ctx, model, prompt)
+	return m.salvage(ctx, model, false, served, stream, completionContinuation(ctx, model, prompt)), nil
 }
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -38,9 +39,10 @@ func (m *MockProvider) Completion(ctx context.Context, model, prompt string) (in
 	return args.Get(0), args.Error(1)
 }
 
-func (m *MockProvider) ListModels() []string {
-	args := m.Called()
-	return args.Get(0).([]string)
+func (m *MockProvider) ListModels(ctx context.Context) ([]providers.Model, error) {
+	args := m.Called(ctx)
+	models, _ := args.Get(0).([]providers.Model)
+	return models, args.Error(1)
 }
 
 func (m *MockProvider) ChatCompletionStream(ctx context.Context, model string, messages []map[string]interface{}) (<-chan interface{}, error) {
@@ -83,12 +85,12 @@ func TestModelMultiplexer_GetProvider(t *testing.T) {
 	provider1 := &MockProvider{}
 	provider1.On("Name").Return("provider1")
 	provider1.On("Priority").Return(1)
-	provider1.On("ListModels").Return([]string{"model1", "model2"})
+	provider1.On("ListModels", mock.Anything).Return(models("model1", "model2"), nil)
 
 	provider2 := &MockProvider{}
 	provider2.On("Name").Return("provider2")
 	provider2.On("Priority").Return(2)
-	provider2.On("ListModels").Return([]string{"model3"})
+	provider2.On("ListModels", mock.Anything).Return(models("model3"), nil)
 
 	// Create multiplexer with manual setup (since we can't easily mock provider creation)
 	mux := &ModelMultiplexer{
@@ -139,6 +141,23 @@ func TestModelMultiplexer_GetProvider(t *testing.T) {
 	}
 }
 
+func TestNew_OpenRouterServesSlugs(t *testing.T) {
+	mux := New([]config.Provider{
+		{Name: "openai", Type: "openai", BaseURL: "https://api.openai.com/v1", Models: []string{"gpt-4"}, Priority: 1},
+		{Name: "openrouter", Type: "openrouter", BaseURL: "https://openrouter.ai/api/v1", Priority: 2},
+	})
+
+	for model, expected := range map[string]string{
+		"anthropic/claude-sonnet-4": "openrouter",
+		"gpt-4":                     "openai",
+		"unknown-model":             "openai",
+	} {
+		provider, err := mux.GetProvider(model)
+		require.NoError(t, err)
+		assert.Equal(t, expected, provider.Name(), model)
+	}
+}
+
 func TestModelMultiplexer_GetProvider_NoProviders(t *testing.T) {
 	mux := &ModelMultiplexer{
 		providers: []providers.Provider{},
@@ -167,6 +186,85 @@ func TestModelMultiplexer_ListModels(t *testing.T) {
 	assert.Contains(t, models, "claude-3-sonnet")
 }
 
+// models returns the models with the given ids.
+func models(ids ...string) []providers.Model {
+	result := make([]providers.Model, len(ids))
+	for i, id := range ids {
+		result[i] = providers.Model{ID: id}
+	}
+	return result
+}
+
+// hangingProvider never finishes listing models until its context is done
+type hangingProvider struct {
+	MockProvider
+}
+
+func (h *hangingProvider) ListModels(ctx context.Context) ([]providers.Model, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func TestModelMultiplexer_ListModelsContext(t *testing.T) {
+	fast := &MockProvider{}
+	fast.On("Name").Return("fast")
+	fast.On("ListModels", mock.Anything).Return(models("model1", "shared"), nil)
+
+	second := &MockProvider{}
+	second.On("Name").Return("second")
+	second.On("ListModels", mock.Anything).Return(models("shared", "model2"), nil)
+
+	hung := &hangingProvider{}
+	hung.On("Name").Return("hung")
+
+	mux := &ModelMultiplexer{
+		providers:   []providers.Provider{fast, hung, second},
+		listTimeout: 50 * time.Millisecond,
+	}
+
+	models, warnings := mux.ListModelsContext(t.Context())
+	assert.Equal(t, []string{"model1", "shared", "model2"}, models)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], "provider hung")
+	assert.Contains(t, warnings[0], context.DeadlineExceeded.Error())
+}
+
+func TestModelMultiplexer_ListModelsContext_Cancelled(t *testing.T) {
+	provider := &hangingProvider{}
+	provider.On("Name").Return("hung")
+
+	mux := &ModelMultiplexer{
+		providers: []providers.Provider{provider},
+	}
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	models, warnings := mux.ListModelsContext(ctx)
+	assert.Empty(t, models)
+	require.Len(t, warnings, 1)
+	assert.Contains(t, warnings[0], context.Canceled.Error())
+}
+
+func TestModelMultiplexer_ListModelsContext_PartialFailure(t *testing.T) {
+	stale := &MockProvider{}
+	stale.On("Name").Return("stale")
+	stale.On("ListModels", mock.Anything).Return(models("cached"), errors.New("refresh failed"))
+
+	failed := &MockProvider{}
+	failed.On("Name").Return("failed")
+	failed.On("ListModels", mock.Anything).Return(nil, errors.New("unauthorized"))
+
+	mux := &ModelMultiplexer{providers: []providers.Provider{stale, failed}}
+
+	listed, warnings := mux.ListModelsContext(t.Context())
+	assert.Equal(t, []string{"cached"}, listed)
+	assert.Equal(t, []string{
+		"provider stale: listing models: refresh failed",
+		"provider failed: listing models: unauthorized",
+	}, warnings)
+}
+
 func TestModelMultiplexer_ChatCompletion(t *testing.T) {
 	provider := &MockProvider{}
 
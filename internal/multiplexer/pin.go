@@ -0,0 +1,37 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+type providerKey struct{}
+
+// WithProvider returns a context whose requests are sent to the provider named name,
+// whichever provider would otherwise serve the model. Data residency still applies.
+func WithProvider(ctx context.Context, name string) context.Context {
+	if name == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, providerKey{}, name)
+}
+
+// pinned returns the provider ctx pins requests to, if any.
+func (m *ModelMultiplexer) pinned(ctx context.Context) (providers.Provider, bool, error) {
+	name, _ := ctx.Value(providerKey{}).(string)
+	if name == "" {
+		return nil, false, nil
+	}
+
+	provider, ok := m.Provider(name)
+	if !ok {
+		return nil, true, fmt.Errorf("no provider named %s", name)
+	}
+	if allowed := residencyFrom(ctx); len(allowed) > 0 && !slices.Contains(allowed, m.jurisdictions[name]) {
+		return nil, true, fmt.Errorf("provider %s is outside jurisdictions %v", name, allowed)
+	}
+	return provider, true, nil
+}
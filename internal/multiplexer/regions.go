@@ -0,0 +1,206 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/journal"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+const (
+	// regionCooldown is how long a failed region is skipped before it is tried again
+	regionCooldown = 30 * time.Second
+	// latencySmoothing weights the newest sample in the per-region latency moving average
+	latencySmoothing = 0.3
+)
+
+// region tracks health and observed latency for one regional endpoint.
+type region struct {
+	name           string
+	provider       providers.Provider
+	latency        time.Duration
+	unhealthyUntil time.Time
+}
+
+// regionalProvider fans a single configured provider out across several regional endpoints.
+// Requests go to the fastest healthy region and fail over to the next one on error.
+type regionalProvider struct {
+	name     string
+	priority int
+	regions  []*region
+	mtx      sync.Mutex
+	now      func() time.Time
+}
+
+// newRegionalProvider builds one underlying provider per region from cfg, recording the failures
+// of each in j. Returns nil if the provider type is unknown.
+func newRegionalProvider(cfg *config.Provider, j *journal.Journal) providers.Provider {
+	rp := &regionalProvider{
+		name:     cfg.Name,
+		priority: cfg.Priority,
+		now:      time.Now,
+	}
+
+	for _, r := range cfg.Regions {
+		regionCfg := *cfg
+		regionCfg.BaseURL = r.BaseURL
+		regionCfg.Regions = nil
+
+		provider := providers.NewProvider(&regionCfg)
+		if provider == nil {
+			return nil
+		}
+
+		name := r.Name
+		if name == "" {
+			name = r.BaseURL
+		}
+		rp.regions = append(rp.regions, &region{name: name, provider: journaled(provider, name, j)})
+	}
+
+	return rp
+}
+
+// Name returns the provider name.
+func (rp *regionalProvider) Name() string {
+	return rp.name
+}
+
+// Priority returns the provider priority for model routing.
+func (rp *regionalProvider) Priority() int {
+	return rp.priority
+}
+
+// ListModels returns the models of the preferred region; all regions serve the same catalog.
+func (rp *regionalProvider) ListModels(ctx context.Context) ([]providers.Model, error) {
+	return rp.ordered()[0].provider.ListModels(ctx)
+}
+
+// ChatCompletion performs a chat completion against the best available region.
+func (rp *regionalProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	return tryRegions(ctx, rp, func(p providers.Provider) (interface{}, error) {
+		return p.ChatCompletion(ctx, model, messages)
+	})
+}
+
+// Completion performs a completion against the best available region.
+func (rp *regionalProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	return tryRegions(ctx, rp, func(p providers.Provider) (interface{}, error) {
+		return p.Completion(ctx, model, prompt)
+	})
+}
+
+// ChatCompletionStream starts a streaming chat completion; failover only covers stream setup.
+func (rp *regionalProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	return tryRegions(ctx, rp, func(p providers.Provider) (<-chan interface{}, error) {
+		return p.ChatCompletionStream(ctx, model, messages)
+	})
+}
+
+// CompletionStream starts a streaming completion; failover only covers stream setup.
+func (rp *regionalProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	return tryRegions(ctx, rp, func(p providers.Provider) (<-chan interface{}, error) {
+		return p.CompletionStream(ctx, model, prompt)
+	})
+}
+
+// ordered returns healthy regions fastest-first, followed by regions still cooling down.
+// Regions without latency samples sort first so they get probed.
+func (rp *regionalProvider) ordered() []*region {
+	rp.mtx.Lock()
+	defer rp.mtx.Unlock()
+
+	now := rp.now()
+	ordered := make([]*region, len(rp.regions))
+	copy(ordered, rp.regions)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		iHealthy := !now.Before(ordered[i].unhealthyUntil)
+		jHealthy := !now.Before(ordered[j].unhealthyUntil)
+		if iHealthy != jHealthy {
+			return iHealthy
+		}
+		return ordered[i].latency < ordered[j].latency
+	})
+	return ordered
+}
+
+func (rp *regionalProvider) recordSuccess(r *region, elapsed time.Duration) {
+	rp.mtx.Lock()
+	defer rp.mtx.Unlock()
+
+	if r.latency == 0 {
+		r.latency = elapsed
+	} else {
+		r.latency = time.Duration(latencySmoothing*float64(elapsed) + (1-latencySmoothing)*float64(r.latency))
+	}
+	r.unhealthyUntil = time.Time{}
+}
+
+func (rp *regionalProvider) recordFailure(r *region) {
+	rp.mtx.Lock()
+	defer rp.mtx.Unlock()
+
+	r.unhealthyUntil = rp.now().Add(regionCooldown)
+}
+
+// tryRegions runs fn against each region in preference order until one succeeds.
+func tryRegions[T any](ctx context.Context, rp *regionalProvider, fn func(providers.Provider) (T, error)) (T, error) {
+	var zero T
+	var attempts []providers.Attempt
+	var errs []error
+
+	for _, r := range rp.ordered() {
+		start := rp.now()
+		result, err := fn(r.provider)
+		if err == nil {
+			rp.recordSuccess(r, rp.now().Sub(start))
+			providers.ReportServingRegion(ctx, rp.name, r.name)
+			slog.Debug("Provider request served", "provider", rp.name, "region", r.name)
+			return result, nil
+		}
+
+		// The caller gave up, the request itself was at fault or traffic is paused; other regions
+		// would fail the same way, and this one isn't to blame
+		if !upstreamFailure(ctx, err) {
+			return zero, err
+		}
+
+		rp.recordFailure(r)
+		slog.Warn("Provider region failed, failing over", "provider", rp.name, "region", r.name, "error", err)
+		attempts = append(attempts, providers.NewAttempt(rp.name, r.name, err))
+		errs = append(errs, fmt.Errorf("region %s: %w", r.name, err))
+	}
+
+	return zero, fmt.Errorf("all regions of provider %s failed: %w", rp.name, providers.NewFailoverError(attempts, errs))
+}
+
+// Forward sends a raw request to the preferred region.
+// There is no failover because the request body can only be read once.
+func (rp *regionalProvider) Forward(req *http.Request, path string) (*http.Response, error) {
+	r := rp.ordered()[0]
+	forwarder, ok := r.provider.(providers.RawForwarder)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support raw requests", rp.name)
+	}
+	return forwarder.Forward(req, path)
+}
+
+// CountTokens counts tokens with the best available region.
+func (rp *regionalProvider) CountTokens(
+	ctx context.Context, model string, request map[string]interface{},
+) (int64, error) {
+	return tryRegions(ctx, rp, func(p providers.Provider) (int64, error) {
+		return providers.CountTokens(ctx, p, model, request)
+	})
+}
@@ -0,0 +1,154 @@
+package multiplexer
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+func newTestRegionalProvider(regions ...*region) *regionalProvider {
+	now := time.Unix(1700000000, 0)
+	return &regionalProvider{
+		name:    "regional",
+		regions: regions,
+		now:     func() time.Time { return now },
+	}
+}
+
+func TestNewRegionalProvider(t *testing.T) {
+	cfg := config.Provider{
+		Name:     "openai",
+		Type:     "openai",
+		Priority: 2,
+		Regions: []config.ProviderRegion{
+			{Name: "us", BaseURL: "https://us.example.com/v1"},
+			{BaseURL: "https://eu.example.com/v1"},
+		},
+	}
+
+	provider := newRegionalProvider(&cfg, nil)
+	require.NotNil(t, provider)
+	assert.Equal(t, "openai", provider.Name())
+	assert.Equal(t, 2, provider.Priority())
+
+	rp := provider.(*regionalProvider)
+	require.Len(t, rp.regions, 2)
+	assert.Equal(t, "us", rp.regions[0].name)
+	assert.Equal(t, "https://eu.example.com/v1", rp.regions[1].name)
+
+	cfg.Type = "unknown"
+	assert.Nil(t, newRegionalProvider(&cfg, nil))
+}
+
+func TestRegionalProvider_Failover(t *testing.T) {
+	messages := []map[string]interface{}{{"role": "user", "content": "Hello"}}
+
+	primary := &MockProvider{}
+	primary.On("ChatCompletion", mock.Anything, "gpt-4", messages).Return(nil, errors.New("regional outage"))
+
+	secondary := &MockProvider{}
+	secondary.On("ChatCompletion", mock.Anything, "gpt-4", messages).Return("ok", nil)
+
+	rp := newTestRegionalProvider(
+		&region{name: "us", provider: primary},
+		&region{name: "eu", provider: secondary},
+	)
+
+	ctx := providers.WithServingProvider(t.Context())
+	result, err := rp.ChatCompletion(ctx, "gpt-4", messages)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", result)
+	assert.Equal(t, "regional", providers.ServingProvider(ctx))
+	assert.Equal(t, "eu", providers.ServingRegion(ctx), "the serving region is recorded")
+
+	// The failed region is cooling down, so the healthy one is preferred
+	ordered := rp.ordered()
+	assert.Equal(t, "eu", ordered[0].name)
+	assert.Equal(t, "us", ordered[1].name)
+
+	result, err = rp.ChatCompletion(t.Context(), "gpt-4", messages)
+	require.NoError(t, err)
+	assert.Equal(t, "ok", result)
+	primary.AssertNumberOfCalls(t, "ChatCompletion", 1)
+	secondary.AssertNumberOfCalls(t, "ChatCompletion", 2)
+}
+
+func TestRegionalProvider_AllRegionsFail(t *testing.T) {
+	failing := &MockProvider{}
+	failing.On("Completion", mock.Anything, "gpt-4", "prompt").Return(nil, errors.New("down"))
+
+	rp := newTestRegionalProvider(
+		&region{name: "us", provider: failing},
+		&region{name: "eu", provider: failing},
+	)
+
+	_, err := rp.Completion(t.Context(), "gpt-4", "prompt")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "all regions of provider regional failed")
+	assert.Contains(t, err.Error(), "region us: down")
+	assert.Contains(t, err.Error(), "region eu: down")
+
+	var failover *providers.FailoverError
+	require.ErrorAs(t, err, &failover)
+	assert.Equal(t, []providers.Attempt{
+		{Provider: "regional", Region: "us", Error: "down"},
+		{Provider: "regional", Region: "eu", Error: "down"},
+	}, failover.Attempts)
+}
+
+func TestRegionalProvider_NoFailoverOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+
+	first := &MockProvider{}
+	first.On("Completion", mock.Anything, "gpt-4", "prompt").Return(nil, context.Canceled)
+	second := &MockProvider{}
+
+	rp := newTestRegionalProvider(
+		&region{name: "us", provider: first},
+		&region{name: "eu", provider: second},
+	)
+
+	_, err := rp.Completion(ctx, "gpt-4", "prompt")
+	assert.ErrorIs(t, err, context.Canceled)
+	second.AssertNotCalled(t, "Completion", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestRegionalProvider_NoFailoverOnClientErrors(t *testing.T) {
+	invalid := &providers.StatusError{StatusCode: 400, Body: "prompt is too long"}
+	first := &MockProvider{}
+	first.On("Completion", mock.Anything, "gpt-4", "prompt").Return(nil, invalid)
+	second := &MockProvider{}
+
+	rp := newTestRegionalProvider(
+		&region{name: "us", provider: first},
+		&region{name: "eu", provider: second},
+	)
+
+	_, err := rp.Completion(t.Context(), "gpt-4", "prompt")
+	assert.ErrorIs(t, err, invalid)
+	second.AssertNotCalled(t, "Completion", mock.Anything, mock.Anything, mock.Anything)
+	assert.Equal(t, "us", rp.ordered()[0].name, "the region isn't cooled down for the client's request")
+}
+
+func TestRegionalProvider_PrefersFastestRegion(t *testing.T) {
+	rp := newTestRegionalProvider(
+		&region{name: "slow", latency: 300 * time.Millisecond},
+		&region{name: "fast", latency: 50 * time.Millisecond},
+	)
+
+	assert.Equal(t, "fast", rp.ordered()[0].name)
+
+	rp.recordSuccess(rp.regions[1], 1000*time.Millisecond)
+	// 0.3*1000ms + 0.7*50ms = 335ms, now slower than the other region
+	assert.Equal(t, 335*time.Millisecond, rp.regions[1].latency)
+	assert.Equal(t, "slow", rp.ordered()[0].name)
+}
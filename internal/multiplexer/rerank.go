@@ -0,0 +1,50 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// Rerank ranks documents by their relevance to a query with model, on the first of its rerankers
+// by priority within the residency requirement of ctx, failing over to the next when one fails.
+func (m *ModelMultiplexer) Rerank(
+	ctx context.Context, model string, req *providers.RerankRequest,
+) (*providers.RerankResponse, error) {
+	candidates := m.rerankers[model]
+	if allowed := residencyFrom(ctx); len(allowed) > 0 {
+		candidates = slices.DeleteFunc(slices.Clone(candidates), func(r providers.Reranker) bool {
+			return !slices.Contains(allowed, m.jurisdictions[r.Name()])
+		})
+		if len(candidates) == 0 {
+			return nil, fmt.Errorf("%w for model %s in jurisdictions %s",
+				providers.ErrNoReranker, model, strings.Join(allowed, ", "))
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("%w for model %s", providers.ErrNoReranker, model)
+	}
+
+	var attempts []providers.Attempt
+	var errs []error
+	for i, reranker := range candidates {
+		response, err := reranker.Rerank(ctx, model, req)
+		if err == nil || !upstreamFailure(ctx, err) {
+			return response, err
+		}
+		if len(candidates) == 1 {
+			return nil, err
+		}
+		attempts = append(attempts, providers.NewAttempt(reranker.Name(), "", err))
+		errs = append(errs, fmt.Errorf("provider %s: %w", reranker.Name(), err))
+		if i < len(candidates)-1 {
+			slog.Warn("Reranker failed, failing over", "model", model, "provider", reranker.Name(),
+				"next", candidates[i+1].Name(), "error", err)
+		}
+	}
+	return nil, providers.NewFailoverError(attempts, errs)
+}
@@ -0,0 +1,57 @@
+package multiplexer
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+func TestRerank_FailsOverBetweenRerankers(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		http.Error(w, `{"message":"overloaded"}`, http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	local := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"index":1,"score":0.9},{"index":0,"score":0.1}]`))
+	}))
+	defer local.Close()
+
+	mux := New([]config.Provider{
+		{Name: "cohere", Type: "cohere", BaseURL: down.URL, Models: []string{"rerank"}, Jurisdiction: "us"},
+		{Name: "local", Type: "tei", BaseURL: local.URL, Models: []string{"rerank"}, Priority: 1, Jurisdiction: "eu"},
+		{Name: "openai", Type: "openai", BaseURL: down.URL, Models: []string{"gpt-4"}},
+	})
+	req := &providers.RerankRequest{Query: "q", Documents: []string{"a", "b"}}
+
+	response, err := mux.Rerank(t.Context(), "rerank", req)
+	require.NoError(t, err)
+	assert.Equal(t, 1, response.Results[0].Index)
+	assert.NotContains(t, mux.ListModels(), "rerank", "rerankers serve no completions")
+
+	_, err = mux.Rerank(WithResidency(t.Context(), []string{"us"}), "rerank", req)
+	var status *providers.StatusError
+	require.True(t, errors.As(err, &status), "got %v", err)
+	assert.Equal(t, http.StatusServiceUnavailable, status.StatusCode, "the only reranker in the jurisdiction")
+
+	_, err = mux.Rerank(WithResidency(t.Context(), []string{"apac"}), "rerank", req)
+	assert.ErrorIs(t, err, providers.ErrNoReranker)
+	_, err = mux.Rerank(t.Context(), "gpt-4", req)
+	assert.ErrorIs(t, err, providers.ErrNoReranker)
+}
+
+func TestNew_CohereRerankAndChatModels(t *testing.T) {
+	mux := New([]config.Provider{
+		{Name: "cohere", Type: "cohere", BaseURL: "http://cohere", Models: []string{"rerank-v3.5", "command-a-03-2025"}},
+	})
+	assert.Equal(t, []string{"command-a-03-2025"}, mux.ListModels(), "rerank models serve no completions")
+	_, err := mux.Rerank(t.Context(), "command-a-03-2025", &providers.RerankRequest{Query: "q"})
+	assert.ErrorIs(t, err, providers.ErrNoReranker, "chat models don't rerank")
+}
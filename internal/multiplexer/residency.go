@@ -0,0 +1,103 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+type residencyKey struct{}
+
+// WithResidency returns a context whose requests may only be routed to providers in one of jurisdictions.
+// An empty list leaves routing unrestricted.
+func WithResidency(ctx context.Context, jurisdictions []string) context.Context {
+	if len(jurisdictions) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, residencyKey{}, jurisdictions)
+}
+
+// residencyFrom returns the jurisdictions requests made with ctx are restricted to, if any.
+func residencyFrom(ctx context.Context) []string {
+	jurisdictions, _ := ctx.Value(residencyKey{}).([]string)
+	return jurisdictions
+}
+
+// Resolve returns the provider a request for model made with ctx is sent to, without sending it.
+func (m *ModelMultiplexer) Resolve(ctx context.Context, model string) (providers.Provider, error) {
+	return m.route(ctx, model)
+}
+
+// route returns the provider for model that satisfies the residency requirement of ctx.
+// A provider pinned with WithProvider is used for any model. Otherwise, without a requirement
+// it is the same as GetProvider. With one, the first provider serving the model in an allowed
+// jurisdiction is chosen; models no provider lists fall back to any allowed provider.
+// Either way a standby stands in once every primary of the model is unhealthy. Models with a
+// configured route take it instead, within the same residency requirement. A local backend short
+// of GPU memory spills requests for models it hasn't loaded over to another provider of the model.
+func (m *ModelMultiplexer) route(ctx context.Context, model string) (providers.Provider, error) {
+	if provider, ok, err := m.pinned(ctx); ok {
+		return provider, err
+	}
+	provider, err := m.choose(ctx, model)
+	if err != nil || len(m.backends) == 0 {
+		return provider, err
+	}
+	return m.spill(ctx, model, provider), nil
+}
+
+// choose returns the provider for model as route does, before spilling over.
+func (m *ModelMultiplexer) choose(ctx context.Context, model string) (providers.Provider, error) {
+	if route, ok := m.routes[model]; ok {
+		return m.routed(ctx, model, route)
+	}
+
+	candidates := m.modelProviders[model]
+	if len(candidates) == 0 {
+		candidates = m.slugCandidates(model)
+	}
+	if len(candidates) == 0 {
+		candidates = m.providers
+	}
+
+	allowed := residencyFrom(ctx)
+	if len(allowed) == 0 {
+		provider, err := m.GetProvider(model)
+		if err != nil {
+			return nil, err
+		}
+		return m.standIn(model, candidates, provider), nil
+	}
+
+	var permitted []providers.Provider
+	for _, provider := range candidates {
+		jurisdiction := m.jurisdictions[provider.Name()]
+		if slices.Contains(allowed, jurisdiction) {
+			permitted = append(permitted, provider)
+			continue
+		}
+		slog.Info("Skipping provider outside data residency",
+			"model", model, "provider", provider.Name(), "jurisdiction", jurisdiction, "allowed", allowed)
+	}
+	if len(permitted) == 0 {
+		slog.Warn("Refusing request, no provider satisfies data residency", "model", model, "allowed", allowed)
+		return nil, fmt.Errorf("no provider for model %s in jurisdictions %s", model, strings.Join(allowed, ", "))
+	}
+
+	// Standbys only serve the model when it has no primary in the allowed jurisdictions
+	chosen := permitted[0]
+	for _, provider := range permitted {
+		if !m.standby[provider.Name()] {
+			chosen = provider
+			break
+		}
+	}
+	provider := m.standIn(model, permitted, chosen)
+	slog.Debug("Routed request within data residency",
+		"model", model, "provider", provider.Name(), "jurisdiction", m.jurisdictions[provider.Name()])
+	return provider, nil
+}
@@ -0,0 +1,54 @@
+package multiplexer
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestRoute_Residency(t *testing.T) {
+	mux := New([]config.Provider{
+		{Name: "us", Type: "openai", BaseURL: "https://us.example.com/v1", Models: []string{"gpt-4"}, Priority: 1, Jurisdiction: "us"},
+		{Name: "eu", Type: "openai", BaseURL: "https://eu.example.com/v1", Models: []string{"gpt-4"}, Priority: 2, Jurisdiction: "eu"},
+		{Name: "local", Type: "ollama", BaseURL: "http://localhost:11434", Models: []string{"llama2"}, Priority: 3},
+	})
+
+	provider, err := mux.route(t.Context(), "gpt-4")
+	require.NoError(t, err)
+	assert.Equal(t, "us", provider.Name(), "unrestricted requests route as before")
+
+	eu := WithResidency(t.Context(), []string{"eu"})
+	provider, err = mux.route(eu, "gpt-4")
+	require.NoError(t, err)
+	assert.Equal(t, "eu", provider.Name())
+
+	// Unknown models fall back to any allowed provider, but known ones never leave their providers
+	provider, err = mux.route(eu, "unknown-model")
+	require.NoError(t, err)
+	assert.Equal(t, "eu", provider.Name())
+	_, err = mux.route(eu, "llama2")
+	assert.Error(t, err)
+
+	_, err = mux.ChatCompletion(WithResidency(t.Context(), []string{"ch"}), "gpt-4", nil)
+	assert.EqualError(t, err, "no provider for model gpt-4 in jurisdictions ch")
+}
+
+func TestRoute_PinnedProvider(t *testing.T) {
+	mux := New([]config.Provider{
+		{Name: "us", Type: "openai", BaseURL: "https://us.example.com/v1", Models: []string{"gpt-4"}, Priority: 1, Jurisdiction: "us"},
+		{Name: "local", Type: "ollama", BaseURL: "http://localhost:11434", Models: []string{"llama2"}, Priority: 2},
+	})
+
+	provider, err := mux.route(WithProvider(t.Context(), "local"), "gpt-4")
+	require.NoError(t, err)
+	assert.Equal(t, "local", provider.Name())
+
+	_, err = mux.route(WithProvider(t.Context(), "missing"), "gpt-4")
+	assert.EqualError(t, err, "no provider named missing")
+
+	_, err = mux.route(WithProvider(WithResidency(t.Context(), []string{"us"}), "local"), "gpt-4")
+	assert.EqualError(t, err, "provider local is outside jurisdictions [us]")
+}
@@ -0,0 +1,261 @@
+package multiplexer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// RestartStats counts per model the streams restarted because they failed before their first token,
+// those given up on once every restart failed too, and those salvaged after failing mid-output. It
+// outlives a multiplexer so counts survive config reloads.
+type RestartStats struct {
+	mtx    sync.Mutex
+	models map[string]*ModelRestarts
+}
+
+// ModelRestarts counts the stream restarts of one model.
+type ModelRestarts struct {
+	// Restarts is the number of streams started again, on the same or the next provider
+	Restarts int64 `json:"restarts"`
+	// Exhausted is the number of requests that failed after their last restart
+	Exhausted int64 `json:"exhausted"`
+	// Salvaged is the number of streams continued on a fallback provider after failing mid-output
+	Salvaged int64 `json:"salvaged"`
+}
+
+// restartOutcome is what a restart counter records.
+type restartOutcome int
+
+const (
+	restarted restartOutcome = iota
+	exhausted
+	salvaged
+)
+
+// NewRestartStats creates empty stats.
+func NewRestartStats() *RestartStats {
+	return &RestartStats{models: make(map[string]*ModelRestarts)}
+}
+
+// Snapshot returns a copy of the current counts keyed by model.
+func (s *RestartStats) Snapshot() map[string]ModelRestarts {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	out := make(map[string]ModelRestarts, len(s.models))
+	for model, restarts := range s.models {
+		out[model] = *restarts
+	}
+	return out
+}
+
+func (s *RestartStats) record(model string, outcome restartOutcome) {
+	if s == nil {
+		return
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	restarts, ok := s.models[model]
+	if !ok {
+		restarts = &ModelRestarts{}
+		s.models[model] = restarts
+	}
+	switch outcome {
+	case restarted:
+		restarts.Restarts++
+	case exhausted:
+		restarts.Exhausted++
+	case salvaged:
+		restarts.Salvaged++
+	}
+}
+
+// WithStreamRestarts starts a stream again, up to attempts times, when it fails before delivering
+// its first token, counting restarts in stats, which may be nil. The client can't tell, since
+// nothing had been sent yet.
+func WithStreamRestarts(attempts int64, stats *RestartStats) Option {
+	return func(m *ModelMultiplexer) {
+		m.streamRestarts = attempts
+		m.restartStats = stats
+	}
+}
+
+// streamFailure is a stream that ended, or sent an error, before its first token.
+type streamFailure struct {
+	provider string
+	// chunk is the error the provider sent, nil when the stream just ended
+	chunk map[string]interface{}
+}
+
+func (e *streamFailure) Error() string {
+	if e.chunk == nil {
+		return fmt.Sprintf("stream from provider %s ended before its first token", e.provider)
+	}
+	message := e.chunk["error"]
+	if details, ok := message.(map[string]interface{}); ok {
+		message = details["message"]
+	}
+	return fmt.Sprintf("stream from provider %s failed before its first token: %v", e.provider, message)
+}
+
+// restartable starts a stream for model with start, and starts it again while it fails before its
+// first token, up to the configured number of restarts. Each start is routed anew, so it may go to
+// the same provider or, once that one counts as unhealthy or through the model's failover, the next.
+func (m *ModelMultiplexer) restartable(
+	ctx context.Context, model string, start func(providers.Provider) (<-chan interface{}, error),
+) (<-chan interface{}, error) {
+	if m.streamRestarts <= 0 {
+		return serve(ctx, m, model, start)
+	}
+
+	var attempts []providers.Attempt
+	var errs []error
+	for restarts := int64(0); ; restarts++ {
+		stream, err := serve(ctx, m, model, func(provider providers.Provider) (<-chan interface{}, error) {
+			stream, err := start(provider)
+			if err != nil {
+				return nil, err
+			}
+			return firstToken(ctx, provider.Name(), stream)
+		})
+		var failure *streamFailure
+		if err == nil || !errors.As(err, &failure) {
+			return stream, err
+		}
+
+		var failover *providers.FailoverError
+		if errors.As(err, &failover) {
+			attempts = append(attempts, failover.Attempts...)
+		} else {
+			attempts = append(attempts, providers.NewAttempt(failure.provider, "", err))
+		}
+		errs = append(errs, err)
+		if restarts == m.streamRestarts {
+			m.restartStats.record(model, exhausted)
+			return nil, providers.NewFailoverError(attempts, errs)
+		}
+		m.restartStats.record(model, restarted)
+		slog.Warn("Stream failed before its first token, restarting", "model", model, "provider", failure.provider,
+			"restart", restarts+1, "error", err)
+	}
+}
+
+// firstToken waits for stream to deliver its first token and returns a stream of everything it
+// sends, or a *streamFailure if it ends or sends an error first. A stream that finishes without
+// any token has not failed.
+func firstToken(ctx context.Context, provider string, stream <-chan interface{}) (<-chan interface{}, error) {
+	var preamble []interface{}
+	for {
+		select {
+		case chunk, ok := <-stream:
+			if !ok {
+				return nil, &streamFailure{provider: provider}
+			}
+			if c, ok := chunk.(map[string]interface{}); ok && isErrorChunk(c) {
+				// The provider may still send more before closing the stream
+				go drain(stream)
+				return nil, &streamFailure{provider: provider, chunk: c}
+			}
+			preamble = append(preamble, chunk)
+			if delivers(chunk) {
+				return replay(ctx, preamble, stream), nil
+			}
+		case <-ctx.Done():
+			go drain(stream)
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// replay returns a stream of sent followed by the rest of stream.
+func replay(ctx context.Context, sent []interface{}, stream <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		send := func(chunk interface{}) bool {
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				drain(stream)
+				return false
+			}
+		}
+		for _, chunk := range sent {
+			if !send(chunk) {
+				return
+			}
+		}
+		for chunk := range stream {
+			if !send(chunk) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// drain reads stream to its end, so the provider's goroutine writing it can finish.
+func drain(stream <-chan interface{}) {
+	for range stream {
+	}
+}
+
+// isErrorChunk reports whether chunk is an error sent in place of stream data, in OpenAI's or Anthropic's shape.
+func isErrorChunk(chunk map[string]interface{}) bool {
+	_, ok := chunk["error"]
+	return ok || chunk["type"] == "error"
+}
+
+// delivers reports whether chunk holds output or ends the stream, rather than preceding them: the
+// role-only first delta of OpenAI, Anthropic's message_start and ping, or a usage chunk. Chunks of
+// unknown shape count as output.
+func delivers(chunk interface{}) bool {
+	c, ok := chunk.(map[string]interface{})
+	if !ok {
+		return true
+	}
+	switch c["type"] {
+	case "message_start", "ping":
+		return false
+	case nil:
+	default:
+		return true
+	}
+
+	// Ollama lines
+	if message, ok := c["message"].(map[string]interface{}); ok {
+		content, _ := message["content"].(string)
+		return c["done"] == true || content != "" || message["tool_calls"] != nil
+	}
+	if text, ok := c["response"].(string); ok {
+		return c["done"] == true || text != ""
+	}
+
+	choices, ok := c["choices"].([]interface{})
+	if !ok {
+		return true
+	}
+	for _, choice := range choices {
+		ch, _ := choice.(map[string]interface{})
+		if ch["finish_reason"] != nil {
+			return true
+		}
+		if text, _ := ch["text"].(string); text != "" {
+			return true
+		}
+		delta, _ := ch["delta"].(map[string]interface{})
+		for key, value := range delta {
+			if key != "role" && value != nil && value != "" {
+				return true
+			}
+		}
+	}
+	return false
+}
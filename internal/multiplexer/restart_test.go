@@ -0,0 +1,150 @@
+package multiplexer
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// flakyStreamServer streams a role-only delta and then, for its first failures requests, an error
+// or nothing more; later requests go on to stream text named after the server.
+func flakyStreamServer(t *testing.T, name string, failures int64, withError bool) (string, *atomic.Int64) {
+	t.Helper()
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\"}}]}\n\n")
+		if requests.Add(1) <= failures {
+			if withError {
+				_, _ = fmt.Fprint(w, "data: {\"error\":{\"message\":\"overloaded\"}}\n\n")
+			}
+			return
+		}
+		_, _ = fmt.Fprintf(w, "data: {\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", name)
+		_, _ = fmt.Fprint(w, "data: {\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, &requests
+}
+
+// streamed returns the text of stream and how many chunks it had.
+func streamed(stream <-chan interface{}) (text string, chunks int) {
+	for chunk := range stream {
+		chunks++
+		choices, _ := chunk.(map[string]interface{})["choices"].([]interface{})
+		for _, choice := range choices {
+			delta, _ := choice.(map[string]interface{})["delta"].(map[string]interface{})
+			content, _ := delta["content"].(string)
+			text += content
+		}
+	}
+	return text, chunks
+}
+
+func TestStreamRestarts_SameProvider(t *testing.T) {
+	for _, withError := range []bool{false, true} {
+		t.Run(fmt.Sprintf("error chunk %v", withError), func(t *testing.T) {
+			url, requests := flakyStreamServer(t, "openai", 2, withError)
+			stats := NewRestartStats()
+			mux := New([]config.Provider{{Name: "openai", Type: "openai", BaseURL: url, Models: []string{"gpt-4"}}},
+				WithStreamRestarts(2, stats))
+
+			stream, err := mux.ChatCompletionStream(t.Context(), "gpt-4", nil)
+			require.NoError(t, err)
+			text, chunks := streamed(stream)
+			assert.Equal(t, "openai", text)
+			assert.Equal(t, 3, chunks, "the client sees a single stream, its role delta included")
+			assert.Equal(t, int64(3), requests.Load())
+			assert.Equal(t, map[string]ModelRestarts{"gpt-4": {Restarts: 2}}, stats.Snapshot())
+		})
+	}
+}
+
+func TestStreamRestarts_Exhausted(t *testing.T) {
+	url, requests := flakyStreamServer(t, "openai", 5, true)
+	stats := NewRestartStats()
+	mux := New([]config.Provider{{Name: "openai", Type: "openai", BaseURL: url, Models: []string{"gpt-4"}}},
+		WithStreamRestarts(1, stats))
+
+	_, err := mux.ChatCompletionStream(t.Context(), "gpt-4", nil)
+	var failover *providers.FailoverError
+	require.True(t, errors.As(err, &failover), "every attempt is reported: %v", err)
+	assert.Len(t, failover.Attempts, 2)
+	assert.Contains(t, failover.Attempts[0].Error, "overloaded")
+	assert.Equal(t, int64(2), requests.Load())
+	assert.Equal(t, map[string]ModelRestarts{"gpt-4": {Restarts: 1, Exhausted: 1}}, stats.Snapshot())
+}
+
+func TestStreamRestarts_NextProvider(t *testing.T) {
+	flaky, flakyRequests := flakyStreamServer(t, "openai", 1, false)
+	healthy, _ := flakyStreamServer(t, "azure", 0, false)
+	mux := New([]config.Provider{
+		{Name: "openai", Type: "openai", BaseURL: flaky, Models: []string{"gpt-4"}},
+		{Name: "azure", Type: "openai", BaseURL: healthy, Models: []string{"gpt-4"}},
+	}, WithRoutes(map[string]config.ModelRoute{
+		"gpt-4": {Targets: []config.RouteTarget{{Provider: "openai", Weight: 1}}, Failover: []string{"azure"}},
+	}), WithStreamRestarts(1, nil))
+
+	stream, err := mux.ChatCompletionStream(t.Context(), "gpt-4", nil)
+	require.NoError(t, err)
+	text, _ := streamed(stream)
+	assert.Equal(t, "azure", text)
+	assert.Equal(t, int64(1), flakyRequests.Load(), "the failover serves the stream without a restart")
+}
+
+func TestStreamRestarts_Disabled(t *testing.T) {
+	url, requests := flakyStreamServer(t, "openai", 1, false)
+	mux := New([]config.Provider{{Name: "openai", Type: "openai", BaseURL: url, Models: []string{"gpt-4"}}})
+
+	stream, err := mux.ChatCompletionStream(t.Context(), "gpt-4", nil)
+	require.NoError(t, err)
+	text, chunks := streamed(stream)
+	assert.Empty(t, text)
+	assert.Equal(t, 1, chunks)
+	assert.Equal(t, int64(1), requests.Load())
+}
+
+func TestDelivers(t *testing.T) {
+	tests := []struct {
+		name     string
+		chunk    interface{}
+		delivers bool
+	}{
+		{"role delta", map[string]interface{}{"choices": []interface{}{
+			map[string]interface{}{"delta": map[string]interface{}{"role": "assistant", "content": ""}},
+		}}, false},
+		{"content delta", map[string]interface{}{"choices": []interface{}{
+			map[string]interface{}{"delta": map[string]interface{}{"content": "Hi"}},
+		}}, true},
+		{"tool call delta", map[string]interface{}{"choices": []interface{}{
+			map[string]interface{}{"delta": map[string]interface{}{"tool_calls": []interface{}{}}},
+		}}, true},
+		{"empty finish", map[string]interface{}{"choices": []interface{}{
+			map[string]interface{}{"delta": map[string]interface{}{}, "finish_reason": "stop"},
+		}}, true},
+		{"completion text", map[string]interface{}{"choices": []interface{}{map[string]interface{}{"text": "Hi"}}}, true},
+		{"usage", map[string]interface{}{"choices": []interface{}{}, "usage": map[string]interface{}{}}, false},
+		{"anthropic message_start", map[string]interface{}{"type": "message_start"}, false},
+		{"anthropic ping", map[string]interface{}{"type": "ping"}, false},
+		{"anthropic content", map[string]interface{}{"type": "content_block_delta"}, true},
+		{"ollama empty", map[string]interface{}{"message": map[string]interface{}{"content": ""}}, false},
+		{"ollama content", map[string]interface{}{"message": map[string]interface{}{"content": "Hi"}}, true},
+		{"ollama done", map[string]interface{}{"response": "", "done": true}, true},
+		{"unknown shape", "Hi", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.delivers, delivers(tt.chunk))
+		})
+	}
+}
@@ -0,0 +1,169 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// modelRoute spreads a model's requests over weighted targets and names where they go when one fails.
+type modelRoute struct {
+	mtx            sync.Mutex
+	targets        []*routeTarget
+	failover       []providers.Provider
+	requireHealthy bool
+}
+
+// routeTarget is a provider's share of a model's requests. current is its standing in the smooth
+// weighted round robin that picks targets, so a 70/30 split holds over every ten requests.
+type routeTarget struct {
+	provider providers.Provider
+	weight   int64
+	current  int64
+}
+
+// WithRoutes sends the requests of each model in routes along its route instead of to the first
+// provider serving it by priority.
+func WithRoutes(routes map[string]config.ModelRoute) Option {
+	return func(m *ModelMultiplexer) {
+		m.routeConfigs = routes
+	}
+}
+
+// newModelRoute resolves the provider names of route; names of unknown providers are left out.
+func (m *ModelMultiplexer) newModelRoute(route config.ModelRoute) *modelRoute {
+	r := &modelRoute{requireHealthy: route.RequireHealthy}
+	for _, target := range route.Targets {
+		if provider, ok := m.Provider(target.Provider); ok && target.Weight > 0 {
+			r.targets = append(r.targets, &routeTarget{provider: provider, weight: target.Weight})
+		}
+	}
+	for _, name := range route.Failover {
+		if provider, ok := m.Provider(name); ok {
+			r.failover = append(r.failover, provider)
+		}
+	}
+	return r
+}
+
+// next picks among eligible targets, each in proportion to its weight.
+func (r *modelRoute) next(eligible []*routeTarget) *routeTarget {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var total int64
+	var best *routeTarget
+	for _, target := range eligible {
+		target.current += target.weight
+		total += target.weight
+		if best == nil || target.current > best.current {
+			best = target
+		}
+	}
+	best.current -= total
+	return best
+}
+
+// routed returns the provider for a request for model along route, among the targets permitted by
+// the residency requirement of ctx. With require_healthy, targets that failed recently are skipped
+// while any other is healthy.
+func (m *ModelMultiplexer) routed(ctx context.Context, model string, route *modelRoute) (providers.Provider, error) {
+	allowed := residencyFrom(ctx)
+	var permitted, healthy []*routeTarget
+	for _, target := range route.targets {
+		name := target.provider.Name()
+		if len(allowed) > 0 && !slices.Contains(allowed, m.jurisdictions[name]) {
+			continue
+		}
+		permitted = append(permitted, target)
+		if m.healthy(name) {
+			healthy = append(healthy, target)
+		}
+	}
+	if len(permitted) == 0 {
+		if len(allowed) > 0 {
+			slog.Warn("Refusing request, no route target satisfies data residency", "model", model, "allowed", allowed)
+			return nil, fmt.Errorf("no provider for model %s in jurisdictions %s", model, strings.Join(allowed, ", "))
+		}
+		return nil, fmt.Errorf("no provider available for model: %s", model)
+	}
+
+	eligible := permitted
+	if route.requireHealthy && len(healthy) > 0 {
+		eligible = healthy
+	}
+	return route.next(eligible).provider, nil
+}
+
+// failover returns the next provider in the failover order of model's route that hasn't been
+// tried, or nil when there is none. Requests pinned to a provider don't fail over.
+func (m *ModelMultiplexer) failover(ctx context.Context, model string, tried []providers.Provider) providers.Provider {
+	route, ok := m.routes[model]
+	if !ok {
+		return nil
+	}
+	if name, _ := ctx.Value(providerKey{}).(string); name != "" {
+		return nil
+	}
+
+	allowed := residencyFrom(ctx)
+	for _, provider := range route.failover {
+		name := provider.Name()
+		switch {
+		case slices.Contains(tried, provider):
+		case len(allowed) > 0 && !slices.Contains(allowed, m.jurisdictions[name]):
+		case route.requireHealthy && !m.healthy(name):
+		default:
+			return provider
+		}
+	}
+	return nil
+}
+
+// serve sends a request for model with call to the provider it is routed to, then along the
+// failover order of the model's route while providers fail. When more than one provider was
+// tried, the error lists every attempt.
+func serve[T any](
+	ctx context.Context, m *ModelMultiplexer, model string, call func(providers.Provider) (T, error),
+) (T, error) {
+	provider, err := m.route(ctx, model)
+	if err != nil {
+		var zero T
+		return zero, err
+	}
+
+	var tried []providers.Provider
+	var attempts []providers.Attempt
+	var errs []error
+	for {
+		result, err := call(provider)
+		m.observe(ctx, model, provider, err)
+		if err == nil {
+			providers.ReportServing(ctx, provider)
+			return result, nil
+		}
+		if !upstreamFailure(ctx, err) {
+			return result, err
+		}
+
+		tried = append(tried, provider)
+		next := m.failover(ctx, model, tried)
+		if next == nil && len(tried) == 1 {
+			return result, err
+		}
+		attempts = append(attempts, providers.NewAttempt(provider.Name(), "", err))
+		errs = append(errs, fmt.Errorf("provider %s: %w", provider.Name(), err))
+		if next == nil {
+			return result, providers.NewFailoverError(attempts, errs)
+		}
+		slog.Warn("Provider failed, failing over", "model", model, "provider", provider.Name(), "next", next.Name(),
+			"error", err)
+		provider = next
+	}
+}
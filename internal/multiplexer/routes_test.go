@@ -0,0 +1,126 @@
+package multiplexer
+
+import (
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+func TestRoutes_SplitByWeight(t *testing.T) {
+	var down atomic.Bool
+	mux := New([]config.Provider{
+		{Name: "openai", Type: "openai", BaseURL: switchableServer(t, "openai", &down), Models: []string{"gpt-4"}},
+		{Name: "azure", Type: "openai", BaseURL: switchableServer(t, "azure", &down), Models: []string{"gpt-4"}},
+	}, WithRoutes(map[string]config.ModelRoute{
+		"gpt-4": {Targets: []config.RouteTarget{{Provider: "openai", Weight: 70}, {Provider: "azure", Weight: 30}}},
+	}))
+
+	served := make(map[interface{}]int)
+	for range 10 {
+		result, err := mux.ChatCompletion(t.Context(), "gpt-4", nil)
+		require.NoError(t, err)
+		served[result.(map[string]interface{})["provider"]]++
+	}
+	assert.Equal(t, map[interface{}]int{"openai": 7, "azure": 3}, served)
+}
+
+func TestRoutes_Failover(t *testing.T) {
+	var openaiDown, azureDown, backupDown atomic.Bool
+	mux := New([]config.Provider{
+		{Name: "openai", Type: "openai", BaseURL: switchableServer(t, "openai", &openaiDown), Models: []string{"gpt-4"}},
+		{Name: "azure", Type: "openai", BaseURL: switchableServer(t, "azure", &azureDown), Models: []string{"gpt-4"}},
+		{Name: "backup", Type: "openai", BaseURL: switchableServer(t, "backup", &backupDown)},
+	}, WithRoutes(map[string]config.ModelRoute{
+		"gpt-4": {
+			Targets:  []config.RouteTarget{{Provider: "openai", Weight: 1}},
+			Failover: []string{"openai", "azure", "backup"},
+		},
+	}))
+
+	served := func() interface{} {
+		result, err := mux.ChatCompletion(t.Context(), "gpt-4", nil)
+		require.NoError(t, err)
+		return result.(map[string]interface{})["provider"]
+	}
+
+	openaiDown.Store(true)
+	assert.Equal(t, "azure", served(), "the chosen target is not tried twice")
+	azureDown.Store(true)
+	assert.Equal(t, "backup", served(), "providers outside the targets serve as failover")
+
+	backupDown.Store(true)
+	_, err := mux.ChatCompletion(t.Context(), "gpt-4", nil)
+	var failover *providers.FailoverError
+	require.True(t, errors.As(err, &failover), "every attempt is reported: %v", err)
+	require.Len(t, failover.Attempts, 3)
+	assert.Equal(t, "openai", failover.Attempts[0].Provider)
+	assert.Equal(t, 500, failover.Attempts[0].StatusCode)
+
+	// Pinned requests go to their provider alone
+	_, err = mux.ChatCompletion(WithProvider(t.Context(), "openai"), "gpt-4", nil)
+	assert.False(t, errors.As(err, &failover))
+}
+
+func TestRoutes_RequireHealthy(t *testing.T) {
+	var openaiDown, azureDown atomic.Bool
+	mux := New([]config.Provider{
+		{Name: "openai", Type: "openai", BaseURL: switchableServer(t, "openai", &openaiDown), Models: []string{"gpt-4"}},
+		{Name: "azure", Type: "openai", BaseURL: switchableServer(t, "azure", &azureDown), Models: []string{"gpt-4"}},
+	}, WithRoutes(map[string]config.ModelRoute{
+		"gpt-4": {
+			Targets:        []config.RouteTarget{{Provider: "openai", Weight: 1}, {Provider: "azure", Weight: 1}},
+			RequireHealthy: true,
+		},
+	}))
+	now := time.Unix(1700000000, 0)
+	mux.health.now = func() time.Time { return now }
+
+	served := func() (interface{}, error) {
+		result, err := mux.ChatCompletion(t.Context(), "gpt-4", nil)
+		if err != nil {
+			return nil, err
+		}
+		return result.(map[string]interface{})["provider"], nil
+	}
+
+	openaiDown.Store(true)
+	_, err := served()
+	require.Error(t, err, "without failover the chosen target's failure is returned")
+	for range 3 {
+		provider, err := served()
+		require.NoError(t, err)
+		assert.Equal(t, "azure", provider, "a target cooling down gets no traffic")
+	}
+
+	// Once every target is unhealthy they are all tried again
+	azureDown.Store(true)
+	_, err = served()
+	require.Error(t, err)
+	openaiDown.Store(false)
+	now = now.Add(providerCooldown / 2)
+	var recovered bool
+	for range 2 {
+		if provider, err := served(); err == nil {
+			recovered = provider == "openai"
+		}
+	}
+	assert.True(t, recovered)
+}
+
+func TestNew_PriorityOrdersProvidersOfAModel(t *testing.T) {
+	mux := New([]config.Provider{
+		{Name: "fallback", Type: "openai", BaseURL: "http://fallback.invalid", Models: []string{"gpt-4"}, Priority: 2},
+		{Name: "preferred", Type: "openai", BaseURL: "http://preferred.invalid", Models: []string{"gpt-4"}, Priority: 1},
+	})
+
+	provider, err := mux.GetProvider("gpt-4")
+	require.NoError(t, err)
+	assert.Equal(t, "preferred", provider.Name())
+}
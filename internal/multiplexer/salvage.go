@@ -0,0 +1,206 @@
+package multiplexer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode"
+
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/strict"
+)
+
+// errStreamCutOff is observed for a provider whose stream ended without finishing.
+var errStreamCutOff = errors.New("stream ended before it finished")
+
+// WithStreamSalvage continues a stream that fails after delivering output, up to attempts times:
+// a fallback provider is asked to go on from the partial output, and its stream is stitched onto
+// the one the client is reading, which is therefore always in OpenAI's chunk shape.
+func WithStreamSalvage(attempts int64) Option {
+	return func(m *ModelMultiplexer) {
+		m.streamSalvages = attempts
+	}
+}
+
+// continuation starts a stream on provider that goes on from partial, the output streamed so far.
+type continuation func(provider providers.Provider, partial string) (<-chan interface{}, error)
+
+// chatContinuation continues a chat with partial as the prefix of the assistant's reply. The
+// prefix loses trailing whitespace, which Anthropic refuses; models emit it again as they go on.
+func chatContinuation(
+	ctx context.Context, model string, messages []map[string]interface{},
+) continuation {
+	return func(provider providers.Provider, partial string) (<-chan interface{}, error) {
+		continued := append(messages[:len(messages):len(messages)], map[string]interface{}{
+			"role": "assistant", "content": strings.TrimRightFunc(partial, unicode.IsSpace),
+		})
+		return provider.ChatCompletionStream(ctx, model, continued)
+	}
+}
+
+// completionContinuation continues a completion by extending its prompt with partial.
+func completionContinuation(ctx context.Context, model, prompt string) continuation {
+	return func(provider providers.Provider, partial string) (<-chan interface{}, error) {
+		return provider.CompletionStream(ctx, model, prompt+partial)
+	}
+}
+
+// salvage returns stream, served by provider, in OpenAI's chunk shape; should it fail after
+// delivering output, the output goes on with streams from fallback providers started by resume.
+// Output with tool calls or several choices can't be continued, so its failure reaches the client.
+func (m *ModelMultiplexer) salvage(
+	ctx context.Context, model string, chat bool, provider providers.Provider, stream <-chan interface{},
+	resume continuation,
+) <-chan interface{} {
+	normalizer := strict.New(true)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		s := &stitch{ctx: ctx, out: out, chat: chat}
+		failure, err := s.relay(normalizer.Stream(stream, model, chat))
+		for salvages := int64(0); err != nil && s.resumable && salvages < m.streamSalvages; salvages++ {
+			m.observe(ctx, model, provider, err)
+			failed := provider.Name()
+			provider = m.fallback(ctx, model, provider)
+			slog.Warn("Stream failed mid-output, continuing on a fallback provider", "model", model,
+				"provider", failed, "fallback", provider.Name(), "partial_length", s.text.Len(), "error", err)
+			m.restartStats.record(model, salvaged)
+
+			s.continuing = true
+			var next <-chan interface{}
+			if next, err = resume(provider, s.text.String()); err == nil {
+				failure, err = s.relay(normalizer.Stream(next, model, chat))
+			}
+		}
+		// Past saving, the client sees the failure as it would have without salvage
+		if err != nil && failure != nil {
+			s.send(failure)
+		}
+	}()
+	return out
+}
+
+// fallback returns the provider to continue a stream of model that failed on provider: the next
+// in the failover order of the model's route, otherwise another provider serving the model within
+// the residency requirement of ctx, preferring healthy ones, otherwise provider itself.
+func (m *ModelMultiplexer) fallback(ctx context.Context, model string, provider providers.Provider) providers.Provider {
+	if name, _ := ctx.Value(providerKey{}).(string); name != "" {
+		return provider
+	}
+	if next := m.failover(ctx, model, []providers.Provider{provider}); next != nil {
+		return next
+	}
+
+	allowed := residencyFrom(ctx)
+	var unhealthy providers.Provider
+	for _, candidate := range m.modelProviders[model] {
+		name := candidate.Name()
+		if candidate == provider || (len(allowed) > 0 && !slices.Contains(allowed, m.jurisdictions[name])) {
+			continue
+		}
+		if m.healthy(name) {
+			return candidate
+		}
+		if unhealthy == nil {
+			unhealthy = candidate
+		}
+	}
+	if unhealthy != nil {
+		return unhealthy
+	}
+	return provider
+}
+
+// stitch relays the streams of a salvaged generation to the client as one: continuations keep
+// the id, creation time and model of the first stream and leave out their preamble.
+type stitch struct {
+	ctx  context.Context
+	out  chan<- interface{}
+	chat bool
+	// text is the output so far; resumable turns false once it can't be continued from text alone
+	text       strings.Builder
+	resumable  bool
+	started    bool
+	continuing bool
+	envelope   map[string]interface{}
+}
+
+// relay sends the chunks of stream to the client, reporting nil once it finished, or why it failed
+// along with the error chunk it sent, if any. The client leaving counts as finishing.
+func (s *stitch) relay(stream <-chan interface{}) (failure interface{}, err error) {
+	delivered, finished := false, false
+	for chunk := range stream {
+		c, ok := chunk.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if e, ok := c["error"].(map[string]interface{}); ok {
+			go drain(stream)
+			message, _ := e["message"].(string)
+			return c, errors.New("stream failed: " + message)
+		}
+		if s.continuing && !delivered && !delivers(c) {
+			continue
+		}
+		delivered = true
+
+		// Chunks after the finishing one, such as usage, are relayed as well
+		finished = s.track(c) || finished
+		if !s.send(c) {
+			drain(stream)
+			return nil, nil
+		}
+	}
+	if !finished {
+		return nil, errStreamCutOff
+	}
+	return nil, nil
+}
+
+// track records the output of chunk, gives it the envelope of the first chunk and reports whether
+// it finishes the generation.
+func (s *stitch) track(chunk map[string]interface{}) bool {
+	if !s.started {
+		s.started, s.resumable = true, true
+		s.envelope = map[string]interface{}{"id": chunk["id"], "created": chunk["created"], "model": chunk["model"]}
+	}
+	for key, value := range s.envelope {
+		chunk[key] = value
+	}
+
+	finished := false
+	choices, _ := chunk["choices"].([]interface{})
+	for _, choice := range choices {
+		c, _ := choice.(map[string]interface{})
+		if index, _ := c["index"].(float64); index != 0 {
+			s.resumable = false
+		}
+		if c["finish_reason"] != nil {
+			finished = true
+		}
+		if !s.chat {
+			text, _ := c["text"].(string)
+			s.text.WriteString(text)
+			continue
+		}
+		delta, _ := c["delta"].(map[string]interface{})
+		if delta["tool_calls"] != nil {
+			s.resumable = false
+		}
+		content, _ := delta["content"].(string)
+		s.text.WriteString(content)
+	}
+	return finished
+}
+
+// send hands chunk to the client, reporting false once the client has left.
+func (s *stitch) send(chunk interface{}) bool {
+	select {
+	case s.out <- chunk:
+		return true
+	case <-s.ctx.Done():
+		return false
+	}
+}
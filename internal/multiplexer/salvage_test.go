@@ -0,0 +1,123 @@
+package multiplexer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// scriptedStreamServer streams the data lines of events, then [DONE] if finish is set, recording
+// the body of each request.
+func scriptedStreamServer(t *testing.T, events []string, finish bool) (string, *[]map[string]interface{}) {
+	t.Helper()
+	var requests []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		requests = append(requests, body)
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range events {
+			_, _ = fmt.Fprintf(w, "data: %s\n\n", event)
+		}
+		if finish {
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server.URL, &requests
+}
+
+func TestStreamSalvage_ContinuesOnFallback(t *testing.T) {
+	failing, _ := scriptedStreamServer(t, []string{
+		`{"id":"chatcmpl-1","created":1700000000,"choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`,
+		`{"id":"chatcmpl-1","created":1700000000,"choices":[{"index":0,"delta":{"content":"Hello "}}]}`,
+		`{"error":{"message":"connection reset"}}`,
+	}, false)
+	fallback, requests := scriptedStreamServer(t, []string{
+		`{"id":"chatcmpl-2","created":1700000005,"choices":[{"index":0,"delta":{"role":"assistant","content":""}}]}`,
+		`{"id":"chatcmpl-2","created":1700000005,"choices":[{"index":0,"delta":{"content":"world"}}]}`,
+		`{"id":"chatcmpl-2","created":1700000005,"choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+	}, true)
+	stats := NewRestartStats()
+	mux := New([]config.Provider{
+		{Name: "openai", Type: "openai", BaseURL: failing, Models: []string{"gpt-4"}},
+		{Name: "azure", Type: "openai", BaseURL: fallback, Models: []string{"gpt-4"}},
+	}, WithStreamRestarts(0, stats), WithStreamSalvage(1))
+
+	messages := []map[string]interface{}{{"role": "user", "content": "Greet the world"}}
+	stream, err := mux.ChatCompletionStream(t.Context(), "gpt-4", messages)
+	require.NoError(t, err)
+
+	var chunks []map[string]interface{}
+	var text string
+	for chunk := range stream {
+		c := chunk.(map[string]interface{})
+		chunks = append(chunks, c)
+		delta, _ := c["choices"].([]interface{})[0].(map[string]interface{})["delta"].(map[string]interface{})
+		content, _ := delta["content"].(string)
+		text += content
+	}
+	assert.Equal(t, "Hello world", text)
+	require.Len(t, chunks, 4, "the fallback's role delta is left out")
+	for _, chunk := range chunks {
+		assert.Equal(t, "chatcmpl-1", chunk["id"])
+		assert.Equal(t, float64(1700000000), chunk["created"])
+		assert.Equal(t, "chat.completion.chunk", chunk["object"])
+	}
+	assert.Equal(t, "stop", chunks[3]["choices"].([]interface{})[0].(map[string]interface{})["finish_reason"])
+
+	require.Len(t, *requests, 1)
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"role": "user", "content": "Greet the world"},
+		map[string]interface{}{"role": "assistant", "content": "Hello"},
+	}, (*requests)[0]["messages"], "the partial output is the start of the reply, without trailing whitespace")
+	assert.Len(t, messages, 1, "the request's messages are left as they were")
+	assert.Equal(t, map[string]ModelRestarts{"gpt-4": {Salvaged: 1}}, stats.Snapshot())
+}
+
+func TestStreamSalvage_Completion(t *testing.T) {
+	failing, _ := scriptedStreamServer(t, []string{
+		`{"id":"cmpl-1","choices":[{"index":0,"text":"Once upon"}]}`,
+	}, false)
+	mux := New([]config.Provider{{Name: "openai", Type: "openai", BaseURL: failing, Models: []string{"gpt-3.5-turbo"}}},
+		WithStreamSalvage(2))
+
+	stream, err := mux.CompletionStream(t.Context(), "gpt-3.5-turbo", "Tell a story: ")
+	require.NoError(t, err)
+	var text string
+	for chunk := range stream {
+		for _, choice := range chunk.(map[string]interface{})["choices"].([]interface{}) {
+			text += choice.(map[string]interface{})["text"].(string)
+		}
+	}
+	assert.Equal(t, "Once uponOnce uponOnce upon", text, "the only provider continues its own stream, twice")
+}
+
+func TestStreamSalvage_ToolCallsAreNotContinued(t *testing.T) {
+	failing, _ := scriptedStreamServer(t, []string{
+		`{"id":"chatcmpl-1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"id":"call_1",` +
+			`"type":"function","function":{"name":"weather","arguments":"{\"ci"}}]}}]}`,
+		`{"error":{"message":"connection reset"}}`,
+	}, false)
+	fallback, requests := scriptedStreamServer(t, nil, true)
+	mux := New([]config.Provider{
+		{Name: "openai", Type: "openai", BaseURL: failing, Models: []string{"gpt-4"}},
+		{Name: "azure", Type: "openai", BaseURL: fallback, Models: []string{"gpt-4"}},
+	}, WithStreamSalvage(1))
+
+	stream, err := mux.ChatCompletionStream(t.Context(), "gpt-4", nil)
+	require.NoError(t, err)
+	var last map[string]interface{}
+	for chunk := range stream {
+		last = chunk.(map[string]interface{})
+	}
+	assert.Equal(t, "connection reset", last["error"].(map[string]interface{})["message"])
+	assert.Empty(t, *requests)
+}
@@ -0,0 +1,134 @@
+package multiplexer
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/events"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// providerCooldown is how long a failed provider counts as unhealthy before it is tried again
+const providerCooldown = 30 * time.Second
+
+// health tracks which providers failed recently and which models a standby is serving.
+// It is only consulted when standbys, model routes or backend telemetry are configured.
+type health struct {
+	mtx            sync.Mutex
+	unhealthyUntil map[string]time.Time
+	// promoted maps a model to the standby serving it while its primaries are down
+	promoted map[string]string
+	now      func() time.Time
+}
+
+func newHealth() *health {
+	return &health{
+		unhealthyUntil: make(map[string]time.Time),
+		promoted:       make(map[string]string),
+		now:            time.Now,
+	}
+}
+
+func (h *health) healthyLocked(name string) bool {
+	return !h.now().Before(h.unhealthyUntil[name])
+}
+
+// healthy reports whether the provider named name is out of its cooldown.
+func (m *ModelMultiplexer) healthy(name string) bool {
+	m.health.mtx.Lock()
+	defer m.health.mtx.Unlock()
+	return m.health.healthyLocked(name)
+}
+
+// standIn returns the provider to serve model instead of chosen, a primary among candidates:
+// chosen itself or another primary while any is healthy, otherwise a healthy standby, which is
+// promoted. With every candidate down, chosen is tried anyway.
+func (m *ModelMultiplexer) standIn(
+	model string, candidates []providers.Provider, chosen providers.Provider,
+) providers.Provider {
+	if len(m.standby) == 0 {
+		return chosen
+	}
+
+	m.health.mtx.Lock()
+	defer m.health.mtx.Unlock()
+
+	if m.standby[chosen.Name()] || m.health.healthyLocked(chosen.Name()) {
+		return chosen
+	}
+	var spare providers.Provider
+	for _, provider := range candidates {
+		name := provider.Name()
+		if !m.health.healthyLocked(name) {
+			continue
+		}
+		if !m.standby[name] {
+			return provider
+		}
+		if spare == nil {
+			spare = provider
+		}
+	}
+	if spare == nil {
+		return chosen
+	}
+
+	if m.health.promoted[model] != spare.Name() {
+		m.health.promoted[model] = spare.Name()
+		slog.Warn("Promoting standby provider, every primary is unhealthy", "model", model, "provider", spare.Name())
+		m.notifier.Emit(events.TypeProviderPromoted, model, map[string]interface{}{"provider": spare.Name()})
+	}
+	return spare
+}
+
+// observe records the outcome of a request to provider for model. A failure makes the provider
+// unhealthy for a while, unless the caller gave up or the request itself was at fault; a success
+// of a primary ends the promotion of the model's standby.
+func (m *ModelMultiplexer) observe(ctx context.Context, model string, provider providers.Provider, err error) {
+	if len(m.standby) == 0 && len(m.routes) == 0 && len(m.backends) == 0 {
+		return
+	}
+
+	if err != nil && !upstreamFailure(ctx, err) {
+		return
+	}
+
+	name := provider.Name()
+	m.health.mtx.Lock()
+	defer m.health.mtx.Unlock()
+
+	if err != nil {
+		m.health.unhealthyUntil[name] = m.health.now().Add(providerCooldown)
+		slog.Warn("Provider failed, marking it unhealthy", "provider", name, "cooldown", providerCooldown, "error", err)
+		return
+	}
+	delete(m.health.unhealthyUntil, name)
+
+	if spare, ok := m.health.promoted[model]; ok && !m.standby[name] {
+		delete(m.health.promoted, model)
+		slog.Info("Primary provider recovered, demoting standby", "model", model, "provider", name, "standby", spare)
+		m.notifier.Emit(events.TypeProviderDemoted, model, map[string]interface{}{"provider": spare, "primary": name})
+	}
+}
+
+// upstreamFailure reports whether err, returned by a provider, reflects on the provider: it
+// doesn't when the caller gave up, the request itself was at fault or outbound traffic is paused.
+func upstreamFailure(ctx context.Context, err error) bool {
+	var unsupported *providers.UnsupportedParamError
+	var invalid *providers.InvalidParamError
+	return ctx.Err() == nil && !errors.As(err, &unsupported) && !errors.As(err, &invalid) &&
+		!errors.Is(err, providers.ErrTrafficPaused) && !clientFault(err)
+}
+
+// clientFault reports whether err is a provider refusing the request itself, as with a 400 for a
+// prompt over the context length, which another provider would refuse as well. Timeouts and rate
+// limits are the provider's.
+func clientFault(err error) bool {
+	var status *providers.StatusError
+	return errors.As(err, &status) && status.StatusCode < http.StatusInternalServerError &&
+		status.StatusCode != http.StatusRequestTimeout && status.StatusCode != http.StatusTooManyRequests
+}
@@ -0,0 +1,160 @@
+package multiplexer
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/events"
+)
+
+// switchableServer answers chat completions with name, or with a 500 while down is set.
+func switchableServer(t *testing.T, name string, down *atomic.Bool) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if down.Load() {
+			http.Error(w, "overloaded", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"provider":"` + name + `"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestStandby_PromotedWhenPrimariesAreDown(t *testing.T) {
+	received := make(chan events.Event, 2)
+	webhook := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		var event events.Event
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&event))
+		received <- event
+	}))
+	defer webhook.Close()
+
+	var primaryDown, spareDown atomic.Bool
+	mux := New([]config.Provider{
+		{Name: "spare", Type: "openai", BaseURL: switchableServer(t, "spare", &spareDown), Models: []string{"gpt-4"},
+			Priority: 1, Standby: true},
+		{Name: "primary", Type: "openai", BaseURL: switchableServer(t, "primary", &primaryDown), Models: []string{"gpt-4"},
+			Priority: 2},
+	}, WithNotifier(events.NewNotifier(&config.EventsConfig{Webhook: webhook.URL})))
+	now := time.Unix(1700000000, 0)
+	mux.health.now = func() time.Time { return now }
+
+	served := func() interface{} {
+		result, err := mux.ChatCompletion(t.Context(), "gpt-4", nil)
+		require.NoError(t, err)
+		return result.(map[string]interface{})["provider"]
+	}
+
+	assert.Equal(t, "primary", served(), "a standby gets no traffic while a primary is healthy")
+
+	primaryDown.Store(true)
+	_, err := mux.ChatCompletion(t.Context(), "gpt-4", nil)
+	require.Error(t, err)
+	assert.Equal(t, "spare", served())
+	assert.Equal(t, "spare", served())
+
+	event := <-received
+	assert.Equal(t, events.TypeProviderPromoted, event.Type)
+	assert.Equal(t, "gpt-4", event.Subject)
+	assert.Equal(t, map[string]interface{}{"provider": "spare"}, event.Data)
+
+	// Once the cooldown has passed the primary is tried again and takes back over
+	primaryDown.Store(false)
+	now = now.Add(providerCooldown)
+	assert.Equal(t, "primary", served())
+
+	event = <-received
+	assert.Equal(t, events.TypeProviderDemoted, event.Type)
+	assert.Equal(t, map[string]interface{}{"provider": "spare", "primary": "primary"}, event.Data)
+}
+
+func TestStandby_ClientErrorsLeavePrimariesHealthy(t *testing.T) {
+	tests := []struct {
+		status   int
+		promoted bool
+	}{
+		{http.StatusBadRequest, false},
+		{http.StatusNotFound, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, tt := range tests {
+		t.Run(http.StatusText(tt.status), func(t *testing.T) {
+			var status atomic.Int32
+			status.Store(int32(tt.status))
+			primary := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+				if code := status.Swap(http.StatusOK); code != http.StatusOK {
+					http.Error(w, `{"error":{"message":"failed"}}`, int(code))
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"provider":"primary"}`))
+			}))
+			t.Cleanup(primary.Close)
+			var spareDown atomic.Bool
+			mux := New([]config.Provider{
+				{Name: "spare", Type: "openai", BaseURL: switchableServer(t, "spare", &spareDown),
+					Models: []string{"gpt-4"}, Priority: 1, Standby: true},
+				{Name: "primary", Type: "openai", BaseURL: primary.URL, Models: []string{"gpt-4"}, Priority: 2},
+			})
+
+			_, err := mux.ChatCompletion(t.Context(), "gpt-4", nil)
+			require.Error(t, err)
+			result, err := mux.ChatCompletion(t.Context(), "gpt-4", nil)
+			require.NoError(t, err)
+			expected := "primary"
+			if tt.promoted {
+				expected = "spare"
+			}
+			assert.Equal(t, expected, result.(map[string]interface{})["provider"])
+		})
+	}
+}
+
+func TestStandby_ServesModelsWithoutPrimaries(t *testing.T) {
+	var down atomic.Bool
+	mux := New([]config.Provider{
+		{Name: "primary", Type: "openai", BaseURL: switchableServer(t, "primary", &down), Models: []string{"gpt-4"}},
+		{Name: "spare", Type: "openai", BaseURL: switchableServer(t, "spare", &down), Models: []string{"llama2"},
+			Standby: true},
+	})
+
+	provider, err := mux.route(t.Context(), "llama2")
+	require.NoError(t, err)
+	assert.Equal(t, "spare", provider.Name())
+
+	provider, err = mux.route(t.Context(), "unknown-model")
+	require.NoError(t, err)
+	assert.Equal(t, "primary", provider.Name(), "unknown models never go to a standby")
+}
+
+func TestStandby_Residency(t *testing.T) {
+	var down atomic.Bool
+	mux := New([]config.Provider{
+		{Name: "eu-spare", Type: "openai", BaseURL: switchableServer(t, "eu-spare", &down), Models: []string{"gpt-4"},
+			Priority: 1, Jurisdiction: "eu", Standby: true},
+		{Name: "eu", Type: "openai", BaseURL: switchableServer(t, "eu", &down), Models: []string{"gpt-4"},
+			Priority: 2, Jurisdiction: "eu"},
+	})
+	eu := WithResidency(t.Context(), []string{"eu"})
+
+	provider, err := mux.route(eu, "gpt-4")
+	require.NoError(t, err)
+	assert.Equal(t, "eu", provider.Name())
+
+	mux.health.unhealthyUntil["eu"] = time.Now().Add(time.Minute)
+	provider, err = mux.route(eu, "gpt-4")
+	require.NoError(t, err)
+	assert.Equal(t, "eu-spare", provider.Name())
+}
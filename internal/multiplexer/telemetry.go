@@ -0,0 +1,206 @@
+package multiplexer
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// mib is the size of the unit GPU memory is configured in
+const mib = 1 << 20
+
+// backend tracks what a local backend last reported of the models it has loaded, polled by Maintain.
+type backend struct {
+	name     string
+	reporter providers.ResourceReporter
+	interval time.Duration
+	// vram is the configured GPU memory and spillBelow the free memory it spills under, in bytes
+	vram       int64
+	spillBelow int64
+	// upstream maps public model names to the backend's
+	upstream map[string]string
+
+	mtx       sync.RWMutex
+	resources BackendResources
+}
+
+// BackendResources is what a local backend reported of its loaded models and GPU memory.
+type BackendResources struct {
+	Provider string                  `json:"provider"`
+	Models   []providers.LoadedModel `json:"models"`
+	// VRAMUsed is the GPU memory the loaded models take, VRAMTotal the configured GPU memory,
+	// 0 when unknown; both in bytes
+	VRAMUsed  int64 `json:"vram_used_bytes"`
+	VRAMTotal int64 `json:"vram_total_bytes"`
+	// Offloaded counts loaded models partly held in system memory for lack of GPU memory
+	Offloaded int `json:"offloaded"`
+	// Spilling is set while requests for models the backend hasn't loaded go to other providers
+	Spilling bool      `json:"spilling"`
+	PolledAt time.Time `json:"polled_at"`
+	// Error is why the last poll failed; the models are then those of the last poll that didn't
+	Error string `json:"error,omitempty"`
+}
+
+// newBackend tracks the backend of cfg, or returns nil when it has no telemetry.
+func newBackend(cfg *config.Provider) *backend {
+	if cfg.Telemetry.IntervalSeconds <= 0 {
+		return nil
+	}
+	reporter := providers.NewResourceReporter(cfg)
+	if reporter == nil {
+		return nil
+	}
+	return &backend{
+		name:       cfg.Name,
+		reporter:   reporter,
+		interval:   time.Duration(cfg.Telemetry.IntervalSeconds) * time.Second,
+		vram:       cfg.Telemetry.VRAMMB * mib,
+		spillBelow: cfg.Telemetry.SpillBelowMB * mib,
+		upstream:   cfg.ModelMap,
+		resources:  BackendResources{Provider: cfg.Name, VRAMTotal: cfg.Telemetry.VRAMMB * mib},
+	}
+}
+
+// Maintain looks after local backends until ctx is done: it polls those with telemetry on their
+// interval and unloads the models of those with an idle period once the models go unused.
+func (m *ModelMultiplexer) Maintain(ctx context.Context) {
+	var g errgroup.Group
+	for _, b := range m.backends {
+		g.Go(func() error {
+			b.run(ctx)
+			return nil
+		})
+	}
+	for _, p := range m.idle {
+		g.Go(func() error {
+			p.run(ctx)
+			return nil
+		})
+	}
+	_ = g.Wait() // Maintenance runs until ctx is done and never fails
+}
+
+// Resources returns what each backend with telemetry last reported, by priority.
+func (m *ModelMultiplexer) Resources() []BackendResources {
+	var resources []BackendResources
+	for _, provider := range m.providers {
+		if b, ok := m.backends[provider.Name()]; ok {
+			resources = append(resources, b.snapshot())
+		}
+	}
+	return resources
+}
+
+// run polls the backend at once and then every interval until ctx is done.
+func (b *backend) run(ctx context.Context) {
+	ticker := time.NewTicker(b.interval)
+	defer ticker.Stop()
+	for {
+		b.poll(ctx)
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// poll asks the backend for its loaded models, keeping the previous ones should it fail.
+func (b *backend) poll(ctx context.Context) {
+	pollCtx, cancel := context.WithTimeout(ctx, b.interval)
+	defer cancel()
+	models, err := b.reporter.LoadedModels(pollCtx)
+	if ctx.Err() != nil {
+		return
+	}
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	b.resources.PolledAt = time.Now()
+	if err != nil {
+		if b.resources.Error == "" {
+			slog.Warn("Backend telemetry poll failed", "provider", b.name, "error", err)
+		}
+		b.resources.Error = err.Error()
+		return
+	}
+	b.resources.Error = ""
+	b.resources.Models = models
+	b.resources.VRAMUsed, b.resources.Offloaded = 0, 0
+	for _, model := range models {
+		b.resources.VRAMUsed += model.SizeVRAM
+		if model.SizeVRAM < model.Size {
+			b.resources.Offloaded++
+		}
+	}
+
+	spilling := b.spillBelow > 0 && (b.vram-b.resources.VRAMUsed < b.spillBelow || b.resources.Offloaded > 0)
+	if spilling != b.resources.Spilling {
+		slog.Info("Backend GPU memory changed", "provider", b.name, "spilling", spilling,
+			"vram_used", b.resources.VRAMUsed, "vram_total", b.vram, "offloaded", b.resources.Offloaded)
+	}
+	b.resources.Spilling = spilling
+}
+
+func (b *backend) snapshot() BackendResources {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	resources := b.resources
+	resources.Models = slices.Clone(b.resources.Models)
+	return resources
+}
+
+// spills reports whether a request for model should go elsewhere: the backend is short of GPU
+// memory and would have to load the model first. A backend whose last poll failed doesn't spill.
+func (b *backend) spills(model string) bool {
+	b.mtx.RLock()
+	defer b.mtx.RUnlock()
+
+	if !b.resources.Spilling || b.resources.Error != "" {
+		return false
+	}
+	if upstream, ok := b.upstream[model]; ok {
+		model = upstream
+	}
+	for _, loaded := range b.resources.Models {
+		// Ollama names models with their tag, which defaults to latest
+		if loaded.Name == model || (!strings.Contains(model, ":") && loaded.Name == model+":latest") {
+			return false
+		}
+	}
+	return true
+}
+
+// spill returns provider, chosen for model, or while its backend spills the model, the first
+// other healthy provider of the model within the residency requirement of ctx that doesn't.
+func (m *ModelMultiplexer) spill(ctx context.Context, model string, provider providers.Provider) providers.Provider {
+	if b, ok := m.backends[provider.Name()]; !ok || !b.spills(model) {
+		return provider
+	}
+
+	allowed := residencyFrom(ctx)
+	for _, candidate := range m.modelProviders[model] {
+		name := candidate.Name()
+		switch {
+		case candidate == provider:
+		case len(allowed) > 0 && !slices.Contains(allowed, m.jurisdictions[name]):
+		case !m.healthy(name):
+		case m.backends[name] != nil && m.backends[name].spills(model):
+		default:
+			slog.Debug("Spilling request over, backend is short of GPU memory",
+				"model", model, "provider", provider.Name(), "spill", name)
+			return candidate
+		}
+	}
+	return provider
+}
@@ -0,0 +1,106 @@
+package multiplexer
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// ollamaServer lists the models in loaded at /api/ps and answers every other request as provider local.
+func ollamaServer(t *testing.T, loaded *atomic.Value) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Path == "/api/ps" {
+			_, _ = w.Write([]byte(`{"models":[` + loaded.Load().(string) + `]}`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"provider":"local"}`))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestTelemetry_SpillsUnloadedModelsWhenShortOfMemory(t *testing.T) {
+	var loaded atomic.Value
+	loaded.Store(`{"name":"llama2:latest","size":7516192768,"size_vram":7516192768}`)
+	var cloudDown atomic.Bool
+	mux := New([]config.Provider{
+		{
+			Name: "local", Type: "ollama", BaseURL: ollamaServer(t, &loaded), Models: []string{"llama2", "mistral"},
+			Telemetry: config.ProviderTelemetry{IntervalSeconds: 60, VRAMMB: 8192, SpillBelowMB: 2048},
+		},
+		{Name: "cloud", Type: "openai", BaseURL: switchableServer(t, "cloud", &cloudDown), Models: []string{"mistral"}},
+	})
+	served := func(model string) interface{} {
+		result, err := mux.ChatCompletion(t.Context(), model, nil)
+		require.NoError(t, err)
+		return result.(map[string]interface{})["provider"]
+	}
+
+	assert.Equal(t, "local", served("mistral"), "nothing spills before the first poll")
+	mux.backends["local"].poll(t.Context())
+	assert.Equal(t, "local", served("llama2"), "loaded models stay local")
+	assert.Equal(t, "cloud", served("mistral"), "1 GiB free is short of 2 GiB")
+
+	resources := mux.Resources()
+	require.Len(t, resources, 1)
+	assert.Equal(t, "local", resources[0].Provider)
+	assert.Equal(t, int64(7516192768), resources[0].VRAMUsed)
+	assert.Equal(t, int64(8192<<20), resources[0].VRAMTotal)
+	assert.True(t, resources[0].Spilling)
+	assert.Equal(t, "llama2:latest", resources[0].Models[0].Name)
+
+	// A model partly offloaded to system memory means memory ran out, however much seems free
+	loaded.Store(`{"name":"llama2:latest","size":4294967296,"size_vram":1073741824}`)
+	mux.backends["local"].poll(t.Context())
+	assert.Equal(t, "cloud", served("mistral"))
+	assert.Equal(t, 1, mux.Resources()[0].Offloaded)
+
+	cloudDown.Store(true)
+	_, err := mux.ChatCompletion(t.Context(), "mistral", nil)
+	require.Error(t, err)
+	assert.Equal(t, "local", served("mistral"), "an unhealthy provider isn't spilled over to")
+
+	loaded.Store(``)
+	cloudDown.Store(false)
+	mux.backends["local"].poll(t.Context())
+	assert.Equal(t, "local", served("mistral"))
+	assert.False(t, mux.Resources()[0].Spilling)
+}
+
+func TestTelemetry_Maintain(t *testing.T) {
+	var loaded atomic.Value
+	loaded.Store(`{"name":"llama2:latest","size":100,"size_vram":100}`)
+	mux := New([]config.Provider{
+		{
+			Name: "local", Type: "ollama", BaseURL: ollamaServer(t, &loaded), Models: []string{"llama2"},
+			Telemetry: config.ProviderTelemetry{IntervalSeconds: 60},
+		},
+		{Name: "unpolled", Type: "ollama", BaseURL: "http://unpolled.invalid", Models: []string{"llama2"}},
+	})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		mux.Maintain(ctx)
+	}()
+	require.Eventually(t, func() bool {
+		resources := mux.Resources()
+		return len(resources) == 1 && !resources[0].PolledAt.IsZero()
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int64(100), mux.Resources()[0].VRAMUsed)
+	assert.False(t, mux.Resources()[0].Spilling, "spilling is off without spill_below_mb")
+
+	cancel()
+	<-done
+}
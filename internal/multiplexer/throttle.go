@@ -0,0 +1,175 @@
+package multiplexer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// throttledProvider holds tenants to a ceiling on the rate they receive output tokens at. A
+// throttled stream is passed on no faster than its ceiling, and the backend, its output left
+// unread, is held back in turn; a non-streaming response is held until its tokens would have
+// streamed. The requests of a tenant share one schedule, so running more of them at once doesn't
+// raise its rate, and an interactive tenant without a ceiling keeps most of a local GPU.
+type throttledProvider struct {
+	providers.Provider
+	rate    float64
+	tenants map[string]float64
+
+	mtx sync.Mutex
+	// next is when each throttled tenant may receive its next token; times passed are forgotten
+	next map[string]time.Time
+}
+
+// newThrottledProvider throttles the output of provider as cfg says.
+func newThrottledProvider(provider providers.Provider, cfg *config.ProviderThrottle) *throttledProvider {
+	return &throttledProvider{
+		Provider: provider,
+		rate:     cfg.TokensPerSecond,
+		tenants:  cfg.Tenants,
+		next:     make(map[string]time.Time),
+	}
+}
+
+// ChatCompletion runs a chat completion, returning it no sooner than its tenant's ceiling allows.
+func (p *throttledProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	start := time.Now()
+	result, err := p.Provider.ChatCompletion(ctx, model, messages)
+	return p.hold(ctx, start, result, err)
+}
+
+// Completion runs a completion, returning it no sooner than its tenant's ceiling allows.
+func (p *throttledProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	start := time.Now()
+	result, err := p.Provider.Completion(ctx, model, prompt)
+	return p.hold(ctx, start, result, err)
+}
+
+// ChatCompletionStream starts a streaming chat completion paced to its tenant's ceiling.
+func (p *throttledProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	stream, err := p.Provider.ChatCompletionStream(ctx, model, messages)
+	return p.pace(ctx, stream, err)
+}
+
+// CompletionStream starts a streaming completion paced to its tenant's ceiling.
+func (p *throttledProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	stream, err := p.Provider.CompletionStream(ctx, model, prompt)
+	return p.pace(ctx, stream, err)
+}
+
+// CountTokens counts tokens unthrottled, since counting decodes nothing.
+func (p *throttledProvider) CountTokens(
+	ctx context.Context, model string, request map[string]interface{},
+) (int64, error) {
+	return providers.CountTokens(ctx, p.Provider, model, request)
+}
+
+// hold returns result once the tokens it decoded, counted from start, fit its tenant's ceiling.
+func (p *throttledProvider) hold(
+	ctx context.Context, start time.Time, result interface{}, err error,
+) (interface{}, error) {
+	tenant := usage.TenantFrom(ctx)
+	if err != nil || p.ceiling(tenant) == 0 {
+		return result, err
+	}
+	_, due := p.reserve(tenant, completionTokens(result), start)
+	if err := pause(ctx, due); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// pace passes stream on with a chunk of output, roughly one token, at most as often as its
+// tenant's ceiling allows; chunks preceding the output or following it aren't held.
+func (p *throttledProvider) pace(
+	ctx context.Context, stream <-chan interface{}, err error,
+) (<-chan interface{}, error) {
+	tenant := usage.TenantFrom(ctx)
+	if err != nil || p.ceiling(tenant) == 0 {
+		return stream, err
+	}
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for chunk := range stream {
+			if delivers(chunk) {
+				due, _ := p.reserve(tenant, 1, time.Now())
+				if pause(ctx, due) != nil {
+					drain(stream)
+					return
+				}
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				drain(stream)
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// ceiling returns the tokens per second tenant may receive, 0 when it isn't throttled.
+func (p *throttledProvider) ceiling(tenant string) float64 {
+	if rate, ok := p.tenants[tenant]; ok {
+		return rate
+	}
+	return p.rate
+}
+
+// reserve schedules tokens for tenant no earlier than since, returning when the first of them is
+// due and when the last is done.
+func (p *throttledProvider) reserve(tenant string, tokens float64, since time.Time) (due, done time.Time) {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	now := time.Now()
+	for t, next := range p.next {
+		if next.Before(now) {
+			delete(p.next, t)
+		}
+	}
+	due = since
+	if next, ok := p.next[tenant]; ok && next.After(due) {
+		due = next
+	}
+	done = due.Add(time.Duration(tokens / p.ceiling(tenant) * float64(time.Second)))
+	p.next[tenant] = done
+	return due, done
+}
+
+// pause waits until t, or until ctx is done.
+func pause(ctx context.Context, t time.Time) error {
+	wait := time.Until(t)
+	if wait <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Forward sends a raw request through the provider unthrottled, since raw responses aren't decoded.
+func (p *throttledProvider) Forward(req *http.Request, path string) (*http.Response, error) {
+	forwarder, ok := p.Provider.(providers.RawForwarder)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support raw requests", p.Name())
+	}
+	return forwarder.Forward(req, path)
+}
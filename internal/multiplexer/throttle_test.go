@@ -0,0 +1,103 @@
+package multiplexer
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// burstProvider streams a role delta and then tokens content deltas as fast as they are read, and
+// answers non-streaming requests at once with tokens completion tokens.
+type burstProvider struct {
+	providers.Provider
+	tokens int
+}
+
+func (p *burstProvider) ChatCompletion(
+	_ context.Context, _ string, _ []map[string]interface{},
+) (interface{}, error) {
+	return map[string]interface{}{"usage": map[string]interface{}{"completion_tokens": float64(p.tokens)}}, nil
+}
+
+func (p *burstProvider) ChatCompletionStream(
+	_ context.Context, _ string, _ []map[string]interface{},
+) (<-chan interface{}, error) {
+	stream := make(chan interface{})
+	go func() {
+		defer close(stream)
+		stream <- map[string]interface{}{"choices": []interface{}{
+			map[string]interface{}{"index": float64(0), "delta": map[string]interface{}{"role": "assistant"}},
+		}}
+		for range p.tokens {
+			stream <- map[string]interface{}{"choices": []interface{}{
+				map[string]interface{}{"index": float64(0), "delta": map[string]interface{}{"content": "a"}},
+			}}
+		}
+	}()
+	return stream, nil
+}
+
+func TestThrottledProvider_PacesStreams(t *testing.T) {
+	p := newThrottledProvider(&burstProvider{tokens: 6}, &config.ProviderThrottle{
+		Tenants: map[string]float64{"batch": 50},
+	})
+
+	elapsed := func(tenant string) time.Duration {
+		start := time.Now()
+		stream, err := p.ChatCompletionStream(usage.WithTenant(t.Context(), tenant), "llama2", nil)
+		require.NoError(t, err)
+		text, chunks := streamed(stream)
+		assert.Equal(t, "aaaaaa", text)
+		assert.Equal(t, 7, chunks)
+		return time.Since(start)
+	}
+	assert.GreaterOrEqual(t, elapsed("batch"), 100*time.Millisecond, "6 tokens at 50 per second")
+	assert.Less(t, elapsed("interactive"), 50*time.Millisecond, "tenants without a ceiling aren't held back")
+}
+
+func TestThrottledProvider_TenantSharesItsCeiling(t *testing.T) {
+	p := newThrottledProvider(&burstProvider{tokens: 3}, &config.ProviderThrottle{TokensPerSecond: 50})
+	ctx := usage.WithTenant(t.Context(), "batch")
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stream, err := p.ChatCompletionStream(ctx, "llama2", nil)
+			assert.NoError(t, err)
+			streamed(stream)
+		}()
+	}
+	wg.Wait()
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "6 tokens between both streams")
+}
+
+func TestThrottledProvider_HoldsResponses(t *testing.T) {
+	p := newThrottledProvider(&burstProvider{tokens: 5}, &config.ProviderThrottle{TokensPerSecond: 50})
+
+	start := time.Now()
+	_, err := p.ChatCompletion(t.Context(), "llama2", nil)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond, "5 tokens at 50 per second")
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err = p.ChatCompletion(ctx, "llama2", nil)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestThrottledProvider_Forward(t *testing.T) {
+	assertForwards(t, config.Provider{
+		Name: "cloud", Type: "openai", Throttle: config.ProviderThrottle{TokensPerSecond: 50},
+	})
+}
@@ -0,0 +1,232 @@
+// Package openapi describes the routes of a router as an OpenAPI 3.1 document. Paths and methods
+// come from the router itself, so every registered endpoint is listed; summaries and schemas come
+// from a table of known operations, and routes missing from it are still listed with a generic one.
+package openapi
+
+import (
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+
+	"github.com/gorilla/mux"
+)
+
+// Version is the OpenAPI version of generated documents.
+const Version = "3.1.0"
+
+// Document is an OpenAPI document.
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+// Info describes the API.
+type Info struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	Version     string `json:"version"`
+}
+
+// PathItem holds the operations of one path, keyed by lower-case method.
+type PathItem map[string]*Operation
+
+// Operation is one method of a path.
+type Operation struct {
+	OperationID string                `json:"operationId"`
+	Summary     string                `json:"summary"`
+	Tags        []string              `json:"tags,omitempty"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]Response   `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+// Parameter is a path or query parameter.
+type Parameter struct {
+	Name     string `json:"name"`
+	In       string `json:"in"`
+	Required bool   `json:"required,omitempty"`
+	Schema   Schema `json:"schema"`
+}
+
+// RequestBody is the body an operation accepts.
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+// Response is a response an operation may answer with.
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+// MediaType gives the schema of a body in one content type.
+type MediaType struct {
+	Schema Schema `json:"schema"`
+}
+
+// Schema is a JSON Schema, as OpenAPI 3.1 uses them.
+type Schema map[string]interface{}
+
+// Components holds the schemas operations refer to.
+type Components struct {
+	Schemas         map[string]Schema         `json:"schemas"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes,omitempty"`
+}
+
+// SecurityScheme describes how requests authenticate.
+type SecurityScheme struct {
+	Type   string `json:"type"`
+	Scheme string `json:"scheme"`
+}
+
+const (
+	// adminScheme names the bearer token scheme of admin routes
+	adminScheme = "admin"
+	// pathParameter names the rest of the path below a prefix route
+	pathParameter = "path"
+)
+
+// prefixMethods are the methods documented for prefix routes, which match any method.
+var prefixMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+
+// variable matches a path variable, with an optional pattern.
+var variable = regexp.MustCompile(`\{([^}:]+)(:[^}]*)?\}`)
+
+// Generate describes every route of router. With adminAuth, routes below adminPrefixes are marked
+// as requiring an admin bearer token.
+func Generate(router *mux.Router, version string, adminAuth bool) (*Document, error) {
+	doc := &Document{
+		OpenAPI: Version,
+		Info: Info{
+			Title:       "modelplex",
+			Description: "OpenAI-compatible API multiplexing models across providers, with MCP and admin endpoints.",
+			Version:     version,
+		},
+		Paths:      make(map[string]PathItem),
+		Components: Components{Schemas: schemas},
+	}
+	if adminAuth {
+		doc.Components.SecuritySchemes = map[string]SecurityScheme{adminScheme: {Type: "http", Scheme: "bearer"}}
+	}
+
+	err := router.Walk(func(route *mux.Route, _ *mux.Router, _ []*mux.Route) error {
+		// Subrouters are walked into; only routes that serve requests are operations
+		if route.GetHandler() == nil {
+			return nil
+		}
+		template, err := route.GetPathTemplate()
+		if err != nil {
+			return nil
+		}
+		pattern, err := route.GetPathRegexp()
+		if err != nil {
+			return err
+		}
+		prefix := !strings.HasSuffix(pattern, "$")
+
+		methods, err := route.GetMethods()
+		if err != nil {
+			methods = prefixMethods
+		}
+
+		path := variable.ReplaceAllString(template, "{$1}")
+		if prefix {
+			path += "{" + pathParameter + "}"
+		}
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = make(PathItem)
+			doc.Paths[path] = item
+		}
+		for _, method := range methods {
+			item[strings.ToLower(method)] = newOperation(method, path, adminAuth)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("walking routes: %w", err)
+	}
+	return doc, nil
+}
+
+func newOperation(method, path string, adminAuth bool) *Operation {
+	known := describe(method, path)
+	op := &Operation{
+		OperationID: operationID(method, path),
+		Summary:     known.summary,
+		Tags:        []string{known.tag},
+		Responses: map[string]Response{
+			"default": {Description: "Error", Content: jsonContent(ref("Error"))},
+		},
+	}
+	if op.Summary == "" {
+		op.Summary = method + " " + path
+	}
+
+	for _, match := range variable.FindAllStringSubmatch(path, -1) {
+		op.Parameters = append(op.Parameters, Parameter{
+			Name: match[1], In: "path", Required: true, Schema: Schema{"type": "string"},
+		})
+	}
+	for _, name := range known.query {
+		op.Parameters = append(op.Parameters, Parameter{Name: name, In: "query", Schema: Schema{"type": "string"}})
+	}
+
+	if known.request != "" {
+		op.RequestBody = &RequestBody{Required: !known.optionalRequest, Content: jsonContent(ref(known.request))}
+	}
+	ok := Response{Description: "OK", Content: make(map[string]MediaType)}
+	if !known.streamOnly {
+		ok.Content["application/json"] = MediaType{Schema: objectOr(known.response)}
+	}
+	if known.stream || known.streamOnly {
+		ok.Content["text/event-stream"] = MediaType{Schema: Schema{"type": "string"}}
+	}
+	op.Responses["200"] = ok
+
+	if adminAuth && isAdmin(path) {
+		op.Security = []map[string][]string{{adminScheme: {}}}
+	}
+	return op
+}
+
+// operationID derives a unique operation id from the method and path, e.g. post_v1_chat_completions.
+func operationID(method, path string) string {
+	words := []string{strings.ToLower(method)}
+	for _, word := range strings.FieldsFunc(path, func(r rune) bool {
+		return (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9')
+	}) {
+		words = append(words, word)
+	}
+	return strings.Join(words, "_")
+}
+
+func isAdmin(path string) bool {
+	for _, prefix := range adminPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+func ref(name string) Schema {
+	return Schema{"$ref": "#/components/schemas/" + name}
+}
+
+// objectOr refers to the named schema, or any object when there is none.
+func objectOr(name string) Schema {
+	if name == "" {
+		return Schema{"type": "object"}
+	}
+	return ref(name)
+}
+
+func jsonContent(schema Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
@@ -0,0 +1,85 @@
+package openapi
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	handler := func(http.ResponseWriter, *http.Request) {}
+	router := mux.NewRouter()
+	v1 := router.PathPrefix("/v1").Subrouter()
+	v1.HandleFunc("/chat/completions", handler).Methods("POST")
+	v1.HandleFunc("/streams/{token}", handler).Methods("GET")
+	internal := router.PathPrefix("/_internal").Subrouter()
+	internal.HandleFunc("/chaos", handler).Methods("GET", "POST")
+	internal.HandleFunc("/widgets/{id:[0-9]+}", handler).Methods("DELETE")
+	router.PathPrefix("/providers/{name}/raw/").HandlerFunc(handler)
+
+	doc, err := Generate(router, "1.2.3", true)
+	require.NoError(t, err)
+
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	assert.Equal(t, "1.2.3", doc.Info.Version)
+	assert.ElementsMatch(t, []string{
+		"/v1/chat/completions", "/v1/streams/{token}", "/_internal/chaos", "/_internal/widgets/{id}",
+		"/providers/{name}/raw/{path}",
+	}, keys(doc.Paths))
+
+	chat := doc.Paths["/v1/chat/completions"]["post"]
+	assert.Equal(t, "post_v1_chat_completions", chat.OperationID)
+	assert.Equal(t, "Create a chat completion", chat.Summary)
+	assert.Equal(t, ref("ChatCompletionRequest"), chat.RequestBody.Content["application/json"].Schema)
+	assert.Contains(t, chat.Responses["200"].Content, "text/event-stream")
+	assert.Nil(t, chat.Security, "API routes don't use admin auth")
+
+	resume := doc.Paths["/v1/streams/{token}"]["get"]
+	assert.Equal(t, []Parameter{{Name: "token", In: "path", Required: true, Schema: Schema{"type": "string"}}},
+		resume.Parameters)
+	assert.NotContains(t, resume.Responses["200"].Content, "application/json")
+
+	assert.ElementsMatch(t, []string{"get", "post"}, keys(doc.Paths["/_internal/chaos"]))
+	assert.Equal(t, []map[string][]string{{adminScheme: {}}}, doc.Paths["/_internal/chaos"]["post"].Security)
+
+	// Routes missing from the table are still listed
+	widget := doc.Paths["/_internal/widgets/{id}"]["delete"]
+	assert.Equal(t, "DELETE /_internal/widgets/{id}", widget.Summary)
+	assert.Equal(t, "id", widget.Parameters[0].Name)
+
+	// Prefix routes take any method
+	assert.Len(t, doc.Paths["/providers/{name}/raw/{path}"], len(prefixMethods))
+	for _, name := range []string{"ChatCompletionRequest", "Error"} {
+		assert.Contains(t, doc.Components.Schemas, name)
+	}
+}
+
+func TestGenerate_WithoutAdminAuth(t *testing.T) {
+	router := mux.NewRouter()
+	router.HandleFunc("/_internal/status", func(http.ResponseWriter, *http.Request) {}).Methods("GET")
+
+	doc, err := Generate(router, "dev", false)
+	require.NoError(t, err)
+	assert.Nil(t, doc.Components.SecuritySchemes)
+	assert.Nil(t, doc.Paths["/_internal/status"]["get"].Security)
+}
+
+func TestSwaggerUI(t *testing.T) {
+	w := httptest.NewRecorder()
+	SwaggerUI("/openapi.json").ServeHTTP(w, httptest.NewRequest("GET", "/docs", http.NoBody))
+
+	assert.Equal(t, "text/html; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Contains(t, w.Body.String(), `url: "/openapi.json"`)
+}
+
+func keys[V any](m map[string]V) []string {
+	out := make([]string, 0, len(m))
+	for key := range m {
+		out = append(out, key)
+	}
+	return out
+}
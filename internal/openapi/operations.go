@@ -0,0 +1,408 @@
+package openapi
+
+import "strings"
+
+// operation is what is known about an endpoint beyond its path and method.
+type operation struct {
+	summary string
+	tag     string
+	// request and response name component schemas of the JSON bodies; an unnamed response is any object
+	request  string
+	response string
+	// optionalRequest operations also accept an empty body
+	optionalRequest bool
+	// stream operations may answer with server-sent events, streamOnly ones only do
+	stream     bool
+	streamOnly bool
+	query      []string
+}
+
+// apiPrefixes are the prefixes the OpenAI-compatible API is served under.
+var apiPrefixes = []string{"/models/v1", "/v1"}
+
+// adminPrefixes are the paths behind admin auth when it is configured.
+var adminPrefixes = []string{"/_internal/", "/providers/"}
+
+// operations describes the endpoints by method and path, API endpoints without their prefix.
+var operations = map[string]operation{
+	"POST /chat/completions": {
+		summary: "Create a chat completion", tag: "api",
+		request: "ChatCompletionRequest", response: "ChatCompletion", stream: true,
+	},
+	"POST /completions": {
+		summary: "Create a completion", tag: "api", request: "CompletionRequest", response: "Completion", stream: true,
+	},
+	"GET /models":           {summary: "List the models served", tag: "api", response: "ModelList"},
+	"GET /engines":          {summary: "List the models served as legacy engines", tag: "api"},
+	"GET /engines/{engine}": {summary: "Describe a model as a legacy engine", tag: "api"},
+	"POST /engines/{engine}/completions": {
+		summary: "Create a completion with the legacy engines API", tag: "api",
+		request: "EngineCompletionRequest", response: "Completion", stream: true,
+	},
+	"POST /rerank": {
+		summary: "Rank documents by their relevance to a query", tag: "api", request: "RerankRequest",
+		response: "RerankResponse",
+	},
+	"POST /embeddings": {
+		summary: "Create embeddings of texts", tag: "api", request: "EmbeddingRequest", response: "EmbeddingResponse",
+	},
+	"POST /estimate": {summary: "Estimate the route and maximum cost of a request", tag: "api"},
+	"POST /messages/count_tokens": {
+		summary: "Count the input tokens of an Anthropic Messages request", tag: "api",
+	},
+	"GET /limits":          {summary: "Show the rate limit, concurrency and budget headroom left", tag: "api"},
+	"GET /streams/{token}": {summary: "Resume an interrupted stream", tag: "api", streamOnly: true},
+
+	"POST /gemini/v1beta/models/{model}:generateContent": {
+		summary: "Generate content with Gemini's API", tag: "gemini",
+	},
+	"POST /gemini/v1beta/models/{model}:streamGenerateContent": {
+		summary: "Stream generated content with Gemini's API", tag: "gemini", stream: true, query: []string{"alt"},
+	},
+
+	"GET /mcp/v1/tools": {summary: "List MCP tools", tag: "mcp"},
+	"POST /mcp/v1/tools/{tool}/call": {
+		summary: "Call an MCP tool", tag: "mcp", request: "ToolCallRequest", optionalRequest: true,
+	},
+
+	"GET /_internal/status":  {summary: "Show server status", tag: "internal"},
+	"GET /_internal/config":  {summary: "Show the configuration without secrets", tag: "internal"},
+	"GET /_internal/version": {summary: "Show the running version and latest release", tag: "internal"},
+	"GET /_internal/metrics": {summary: "Show request metrics", tag: "internal"},
+	"POST /_internal/cache/invalidate": {
+		summary: "Drop cached responses of one model, or all without a body", tag: "internal",
+		request: "CacheInvalidateRequest", optionalRequest: true,
+	},
+	"GET /_internal/chaos":  {summary: "Show whether fault injection is enabled", tag: "internal"},
+	"POST /_internal/chaos": {summary: "Toggle fault injection", tag: "internal", request: "ChaosRequest"},
+	"GET /_internal/pause":  {summary: "Show whether outbound provider traffic is paused", tag: "internal"},
+	"POST /_internal/pause": {
+		summary: "Pause or resume outbound provider traffic", tag: "internal", request: "PauseRequest",
+	},
+	"GET /_internal/maintenance": {summary: "Show whether the model routes are closed for maintenance", tag: "internal"},
+	"POST /_internal/maintenance": {
+		summary: "Close the model routes for maintenance, or open them", tag: "internal", request: "MaintenanceRequest",
+	},
+	"GET /_internal/streams": {
+		summary: "List the streaming generations in progress", tag: "internal",
+	},
+	"GET /_internal/streams/{id}": {summary: "Tail a streaming generation", tag: "internal", streamOnly: true},
+	"GET /_internal/errors": {
+		summary: "List recent provider failures", tag: "internal", query: []string{"provider", "since"},
+	},
+	"GET /_internal/providers/{name}": {
+		summary: "Show a provider, the response headers it captured last, its deprecations and queue times", tag: "internal",
+	},
+	"POST /_internal/forget": {
+		summary: "Purge the stored data of a conversation, tenant or metadata tag", tag: "internal",
+		request: "ForgetRequest",
+	},
+	"GET /_internal/conversations/{id}/export": {
+		summary: "Export the stored state of a conversation, signed, for another instance to import", tag: "internal",
+	},
+	"POST /_internal/conversations/import": {
+		summary: "Import the state of a conversation exported by another instance", tag: "internal",
+		request: "ConversationImportRequest",
+	},
+	"POST /_internal/capabilities": {
+		summary: "Mint a capability token scoping the MCP tools a conversation may call", tag: "internal",
+		request: "CapabilityRequest",
+	},
+	"GET /_internal/approvals": {summary: "List the tool calls waiting for approval", tag: "internal"},
+	"POST /_internal/approvals/{id}": {
+		summary: "Approve or deny a tool call waiting for approval", tag: "internal", request: "ApprovalDecision",
+	},
+	"GET /_internal/sessions": {summary: "List the terminated conversations and tenants", tag: "internal"},
+	"POST /_internal/sessions/{id}/terminate": {
+		summary: "Cancel the requests of a conversation or tenant and refuse its later ones until resumed",
+		tag:     "internal", request: "SessionRequest", optionalRequest: true,
+	},
+	"POST /_internal/sessions/{id}/resume": {
+		summary: "Let a terminated conversation or tenant make requests again", tag: "internal",
+		request: "SessionRequest", optionalRequest: true,
+	},
+
+	"GET /health":       {summary: "Check the server is up", tag: "meta"},
+	"GET /ready":        {summary: "Check the startup dependencies are up", tag: "meta"},
+	"GET /openapi.json": {summary: "Get this OpenAPI document", tag: "meta"},
+	"GET /docs":         {summary: "Browse this document with Swagger UI", tag: "meta"},
+}
+
+// rawOperation describes every method of the raw provider passthrough.
+var rawOperation = operation{summary: "Forward a request to a provider's API as is", tag: "internal", stream: true}
+
+// describe returns what is known about method on path.
+func describe(method, path string) operation {
+	if strings.HasPrefix(path, "/providers/{name}/raw/") {
+		return rawOperation
+	}
+	if op, ok := operations[method+" "+path]; ok {
+		return op
+	}
+	for _, prefix := range apiPrefixes {
+		if rest, ok := strings.CutPrefix(path, prefix); ok {
+			if op, ok := operations[method+" "+rest]; ok {
+				return op
+			}
+		}
+	}
+	return operation{tag: "other"}
+}
+
+// schemas are the component schemas. Request schemas allow further fields, which are passed
+// on to providers as parameters.
+var schemas = map[string]Schema{
+	"Error": {
+		"type": "object",
+		"properties": map[string]interface{}{
+			"error": Schema{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"message": Schema{"type": "string"},
+					"type":    Schema{"type": "string"},
+				},
+			},
+		},
+	},
+	"Message": {
+		"type":     "object",
+		"required": []string{"role"},
+		"properties": map[string]interface{}{
+			"role":    Schema{"type": "string"},
+			"content": Schema{"type": []string{"string", "array", "null"}},
+		},
+	},
+	"Metadata": {
+		"type":                 "object",
+		"description":          "String pairs recorded with the request's usage; allowed keys become tags.",
+		"additionalProperties": Schema{"type": "string"},
+	},
+	"ChatCompletionRequest": {
+		"type":     "object",
+		"required": []string{"model", "messages"},
+		"properties": map[string]interface{}{
+			"model":    Schema{"type": "string"},
+			"messages": Schema{"type": "array", "items": ref("Message")},
+			"stream":   Schema{"type": "boolean"},
+			"metadata": ref("Metadata"),
+		},
+		"additionalProperties": true,
+	},
+	"CompletionRequest": {
+		"type":     "object",
+		"required": []string{"model", "prompt"},
+		"properties": map[string]interface{}{
+			"model":    Schema{"type": "string"},
+			"prompt":   Schema{"type": "string"},
+			"stream":   Schema{"type": "boolean"},
+			"metadata": ref("Metadata"),
+		},
+		"additionalProperties": true,
+	},
+	// RerankRequest takes documents as strings or objects with text, and top_k for top_n as Voyage does
+	"RerankRequest": {
+		"type":     "object",
+		"required": []string{"model", "query", "documents"},
+		"properties": map[string]interface{}{
+			"model":            Schema{"type": "string"},
+			"query":            Schema{"type": "string"},
+			"documents":        Schema{"type": "array"},
+			"top_n":            Schema{"type": "integer"},
+			"top_k":            Schema{"type": "integer"},
+			"return_documents": Schema{"type": "boolean"},
+		},
+	},
+	"RerankResponse": {
+		"type":     "object",
+		"required": []string{"model", "results"},
+		"properties": map[string]interface{}{
+			"id":    Schema{"type": "string"},
+			"model": Schema{"type": "string"},
+			"results": Schema{"type": "array", "items": Schema{
+				"type":     "object",
+				"required": []string{"index", "relevance_score"},
+				"properties": map[string]interface{}{
+					"index":           Schema{"type": "integer"},
+					"relevance_score": Schema{"type": "number"},
+					"document": Schema{
+						"type": "object", "properties": map[string]interface{}{"text": Schema{"type": "string"}},
+					},
+				},
+			}},
+		},
+	},
+	// EmbeddingRequest takes truncation as Jina's truncate or Voyage's truncation
+	"EmbeddingRequest": {
+		"type":     "object",
+		"required": []string{"model", "input"},
+		"properties": map[string]interface{}{
+			"model": Schema{"type": "string"},
+			"input": Schema{"oneOf": []interface{}{
+				Schema{"type": "string"}, Schema{"type": "array", "items": Schema{"type": "string"}},
+			}},
+			"dimensions":      Schema{"type": "integer"},
+			"encoding_format": Schema{"type": "string", "enum": []string{"float", "base64"}},
+			"truncate":        Schema{"type": "boolean"},
+			"truncation":      Schema{"type": "boolean"},
+		},
+	},
+	"EmbeddingResponse": {
+		"type":     "object",
+		"required": []string{"object", "data", "model"},
+		"properties": map[string]interface{}{
+			"object": Schema{"type": "string"},
+			"model":  Schema{"type": "string"},
+			"data": Schema{"type": "array", "items": Schema{
+				"type":     "object",
+				"required": []string{"index", "embedding"},
+				"properties": map[string]interface{}{
+					"object":    Schema{"type": "string"},
+					"index":     Schema{"type": "integer"},
+					"embedding": Schema{"type": "array", "items": Schema{"type": "number"}},
+				},
+			}},
+			"usage": Schema{"type": "object"},
+		},
+	},
+	// EngineCompletionRequest is a completion request of the engines API, which names the model in the path
+	"EngineCompletionRequest": {
+		"type":     "object",
+		"required": []string{"prompt"},
+		"properties": map[string]interface{}{
+			"prompt":   Schema{"type": "string"},
+			"stream":   Schema{"type": "boolean"},
+			"metadata": ref("Metadata"),
+		},
+		"additionalProperties": true,
+	},
+	"Usage": {
+		"type": "object",
+		"properties": map[string]interface{}{
+			"prompt_tokens":     Schema{"type": "integer"},
+			"completion_tokens": Schema{"type": "integer"},
+			"total_tokens":      Schema{"type": "integer"},
+		},
+	},
+	"ChatCompletion": {
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":     Schema{"type": "string"},
+			"object": Schema{"const": "chat.completion"},
+			"model":  Schema{"type": "string"},
+			"choices": Schema{"type": "array", "items": Schema{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"index":         Schema{"type": "integer"},
+					"message":       ref("Message"),
+					"finish_reason": Schema{"type": []string{"string", "null"}},
+				},
+			}},
+			"usage": ref("Usage"),
+		},
+	},
+	"Completion": {
+		"type": "object",
+		"properties": map[string]interface{}{
+			"id":     Schema{"type": "string"},
+			"object": Schema{"const": "text_completion"},
+			"model":  Schema{"type": "string"},
+			"choices": Schema{"type": "array", "items": Schema{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"index":         Schema{"type": "integer"},
+					"text":          Schema{"type": "string"},
+					"finish_reason": Schema{"type": []string{"string", "null"}},
+				},
+			}},
+			"usage": ref("Usage"),
+		},
+	},
+	"ModelList": {
+		"type": "object",
+		"properties": map[string]interface{}{
+			"object": Schema{"const": "list"},
+			"data": Schema{"type": "array", "items": Schema{
+				"type":       "object",
+				"properties": map[string]interface{}{"id": Schema{"type": "string"}},
+			}},
+		},
+	},
+	"CacheInvalidateRequest": {
+		"type":       "object",
+		"properties": map[string]interface{}{"model": Schema{"type": "string"}},
+	},
+	"ChaosRequest": {
+		"type":       "object",
+		"required":   []string{"enabled"},
+		"properties": map[string]interface{}{"enabled": Schema{"type": "boolean"}},
+	},
+	"PauseRequest": {
+		"type":       "object",
+		"required":   []string{"paused"},
+		"properties": map[string]interface{}{"paused": Schema{"type": "boolean"}},
+	},
+	// MaintenanceRequest may replace the configured client-facing message while maintenance lasts
+	"MaintenanceRequest": {
+		"type":     "object",
+		"required": []string{"enabled"},
+		"properties": map[string]interface{}{
+			"enabled": Schema{"type": "boolean"},
+			"message": Schema{"type": "string"},
+		},
+	},
+	// ToolCallRequest carries a tool's arguments, and metadata naming the conversation whose capability
+	// token permits the call
+	"ToolCallRequest": {
+		"type": "object",
+		"properties": map[string]interface{}{
+			"arguments": Schema{"type": "object"},
+			"metadata":  Schema{"type": "object", "additionalProperties": Schema{"type": "string"}},
+		},
+	},
+	// CapabilityRequest lists the tools a token permits, each with patterns its arguments must match by name
+	"CapabilityRequest": {
+		"type":     "object",
+		"required": []string{"conversation_id", "tools"},
+		"properties": map[string]interface{}{
+			"conversation_id": Schema{"type": "string"},
+			"tools": Schema{
+				"type":                 "object",
+				"additionalProperties": Schema{"type": "object", "additionalProperties": Schema{"type": "string"}},
+			},
+			"ttl_seconds": Schema{"type": "integer"},
+		},
+	},
+	// ApprovalDecision approves or denies a waiting tool call; the reason is passed on to the agent
+	"ApprovalDecision": {
+		"type":     "object",
+		"required": []string{"approved"},
+		"properties": map[string]interface{}{
+			"approved": Schema{"type": "boolean"},
+			"reason":   Schema{"type": "string"},
+		},
+	},
+	// ConversationImportRequest carries the export of a conversation, as returned by its export endpoint
+	"ConversationImportRequest": {
+		"type":       "object",
+		"required":   []string{"export"},
+		"properties": map[string]interface{}{"export": Schema{"type": "string"}},
+	},
+	// ForgetRequest selects the requests whose data is purged; every criterion given must match
+	"ForgetRequest": {
+		"type": "object",
+		"properties": map[string]interface{}{
+			"conversation_id": Schema{"type": "string"},
+			"tenant":          Schema{"type": "string"},
+			"metadata":        Schema{"type": "object", "additionalProperties": Schema{"type": "string"}},
+		},
+	},
+	// SessionRequest says what the session id names, a conversation unless scope is tenant, and
+	// why it is terminated, which its clients are told
+	"SessionRequest": {
+		"type": "object",
+		"properties": map[string]interface{}{
+			"scope":  Schema{"type": "string", "enum": []string{"conversation", "tenant"}},
+			"reason": Schema{"type": "string"},
+		},
+	},
+}
@@ -0,0 +1,38 @@
+package openapi
+
+import (
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+)
+
+// swaggerUIVersion is the Swagger UI release the page loads from its CDN.
+const swaggerUIVersion = "5"
+
+const swaggerUIPage = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>modelplex API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@%[1]s/swagger-ui-bundle.js"></script>
+  <script>window.ui = SwaggerUIBundle({url: "%[2]s", dom_id: "#swagger-ui"});</script>
+</body>
+</html>
+`
+
+// SwaggerUI serves a Swagger UI page browsing the document at specURL. The page loads Swagger UI
+// itself from a CDN, so browsing needs internet access.
+func SwaggerUI(specURL string) http.Handler {
+	page := fmt.Sprintf(swaggerUIPage, swaggerUIVersion, html.EscapeString(specURL))
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		if _, err := w.Write([]byte(page)); err != nil {
+			slog.Error("Error writing Swagger UI page", "error", err)
+		}
+	})
+}
@@ -0,0 +1,291 @@
+// Package provenance stamps responses with where they came from through modelplex: the model, the
+// provider that served it, the response id, when it was served and a hash of the generated text,
+// signed with HMAC-SHA256 when a secret is configured. Downstream systems can then attribute
+// generated content to a route, and holders of the secret check that the stamp wasn't forged.
+//
+// The stamp goes into X-Modelplex-Provenance-* headers, a provenance object in the response body,
+// or both. A stream's headers are sent before its text exists, so they carry the model, provider
+// and time only; in body mode a final chunk carries the whole stamp.
+package provenance
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// Field carries the stamp in response bodies and the final chunk of streams
+const Field = "provenance"
+
+// Headers carrying the stamp.
+const (
+	HeaderModel     = "X-Modelplex-Provenance-Model"
+	HeaderProvider  = "X-Modelplex-Provenance-Provider"
+	HeaderRequestID = "X-Modelplex-Provenance-Request-Id"
+	HeaderTimestamp = "X-Modelplex-Provenance-Timestamp"
+	HeaderHash      = "X-Modelplex-Provenance-Hash"
+	HeaderSignature = "X-Modelplex-Provenance-Signature"
+)
+
+// Stamp is the provenance of a response.
+type Stamp struct {
+	Model string `json:"model"`
+	// Provider is empty when no provider served the response, e.g. when it was replayed from the cache
+	Provider string `json:"provider,omitempty"`
+	// RequestID is the id of the response, or of the chunks of a stream
+	RequestID string    `json:"request_id,omitempty"`
+	Timestamp time.Time `json:"timestamp"`
+	// Hash is "sha256:" and the hex SHA-256 of the generated text, the text of every choice in order
+	Hash string `json:"hash"`
+	// Signature is the hex HMAC-SHA256 of SignedText, empty without a secret
+	Signature string `json:"signature,omitempty"`
+}
+
+// SignedText returns what the signature of s is computed over: its fields but the signature, one
+// per line, the timestamp in RFC 3339 with nanoseconds.
+func (s *Stamp) SignedText() string {
+	return strings.Join([]string{
+		s.Model, s.Provider, s.RequestID, s.Timestamp.UTC().Format(time.RFC3339Nano), s.Hash,
+	}, "\n")
+}
+
+// Stamper stamps responses as configured. A nil Stamper leaves them as they are.
+type Stamper struct {
+	headers bool
+	body    bool
+	secret  []byte
+	now     func() time.Time
+}
+
+// New creates a stamper for cfg, or nil when responses aren't stamped.
+func New(cfg *config.ProvenanceConfig) *Stamper {
+	s := &Stamper{now: time.Now}
+	switch cfg.Mode {
+	case config.ProvenanceHeaders:
+		s.headers = true
+	case config.ProvenanceBody:
+		s.body = true
+	case config.ProvenanceBoth:
+		s.headers, s.body = true, true
+	default:
+		return nil
+	}
+	if cfg.Secret != "" {
+		s.secret = []byte(cfg.Secret)
+	}
+	return s
+}
+
+// Response stamps result, the response of provider for model, and returns it. result may be
+// shared, e.g. by coalesced requests, so it is copied before the stamp is added.
+func (s *Stamper) Response(h http.Header, result interface{}, model, provider string) interface{} {
+	if s == nil {
+		return result
+	}
+	response, ok := result.(map[string]interface{})
+	if !ok {
+		return result
+	}
+
+	texts := &choiceTexts{}
+	texts.response(response)
+	id, _ := response["id"].(string)
+	stamp := s.stamp(model, provider, id, s.now(), texts.hash())
+	if s.headers {
+		setHeaders(h, &stamp)
+	}
+	if !s.body {
+		return result
+	}
+	response = maps.Clone(response)
+	response[Field] = stamp
+	return response
+}
+
+// Stream stamps upstream, a stream of provider for model, whose headers are yet to be written to h.
+// In body mode the returned stream ends with a chunk carrying the stamp, unless upstream failed.
+func (s *Stamper) Stream(h http.Header, upstream <-chan interface{}, model, provider string) <-chan interface{} {
+	if s == nil {
+		return upstream
+	}
+	started := s.now()
+	if s.headers {
+		h.Set(HeaderModel, model)
+		if provider != "" {
+			h.Set(HeaderProvider, provider)
+		}
+		h.Set(HeaderTimestamp, started.UTC().Format(time.RFC3339Nano))
+	}
+	if !s.body {
+		return upstream
+	}
+
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		texts := &choiceTexts{}
+		var id, object interface{}
+		failed := false
+		for chunk := range upstream {
+			if c, ok := chunk.(map[string]interface{}); ok {
+				if _, isError := c["error"]; isError || c["type"] == "error" {
+					failed = true
+				}
+				if id == nil {
+					id, object = c["id"], c["object"]
+				}
+				texts.chunk(c)
+			}
+			out <- chunk
+		}
+		if failed {
+			return
+		}
+
+		requestID, _ := id.(string)
+		out <- map[string]interface{}{
+			"id":      id,
+			"object":  object,
+			"model":   model,
+			"choices": []interface{}{},
+			Field:     s.stamp(model, provider, requestID, started, texts.hash()),
+		}
+	}()
+	return out
+}
+
+// stamp returns the stamp with the given fields, signed when there is a secret.
+func (s *Stamper) stamp(model, provider, requestID string, at time.Time, hash string) Stamp {
+	stamp := Stamp{Model: model, Provider: provider, RequestID: requestID, Timestamp: at.UTC(), Hash: hash}
+	if s.secret != nil {
+		mac := hmac.New(sha256.New, s.secret)
+		mac.Write([]byte(stamp.SignedText()))
+		stamp.Signature = hex.EncodeToString(mac.Sum(nil))
+	}
+	return stamp
+}
+
+func setHeaders(h http.Header, stamp *Stamp) {
+	h.Set(HeaderModel, stamp.Model)
+	if stamp.Provider != "" {
+		h.Set(HeaderProvider, stamp.Provider)
+	}
+	if stamp.RequestID != "" {
+		h.Set(HeaderRequestID, stamp.RequestID)
+	}
+	h.Set(HeaderTimestamp, stamp.Timestamp.Format(time.RFC3339Nano))
+	h.Set(HeaderHash, stamp.Hash)
+	if stamp.Signature != "" {
+		h.Set(HeaderSignature, stamp.Signature)
+	}
+}
+
+// choiceTexts collects the generated text of each choice of a response, or of a stream as its
+// chunks arrive, across the shapes providers return: OpenAI choices, Anthropic content blocks and
+// Ollama messages.
+type choiceTexts struct {
+	texts map[int]*strings.Builder
+}
+
+func (c *choiceTexts) add(index int, text string) {
+	if text == "" {
+		return
+	}
+	if c.texts == nil {
+		c.texts = make(map[int]*strings.Builder)
+	}
+	b, ok := c.texts[index]
+	if !ok {
+		b = &strings.Builder{}
+		c.texts[index] = b
+	}
+	b.WriteString(text)
+}
+
+// response collects the text of a complete response.
+func (c *choiceTexts) response(response map[string]interface{}) {
+	if choices, ok := response["choices"].([]interface{}); ok {
+		for i, choice := range choices {
+			ch, _ := choice.(map[string]interface{})
+			message, _ := ch["message"].(map[string]interface{})
+			text, ok := message["content"].(string)
+			if !ok {
+				text, _ = ch["text"].(string)
+			}
+			c.add(choiceIndex(ch, i), text)
+		}
+		return
+	}
+	if blocks, ok := response["content"].([]interface{}); ok {
+		for _, block := range blocks {
+			if b, ok := block.(map[string]interface{}); ok && b["type"] == "text" {
+				text, _ := b["text"].(string)
+				c.add(0, text)
+			}
+		}
+		return
+	}
+	c.ollama(response)
+}
+
+// chunk collects the text a streaming chunk adds.
+func (c *choiceTexts) chunk(chunk map[string]interface{}) {
+	if chunk["type"] == "content_block_delta" {
+		if delta, ok := chunk["delta"].(map[string]interface{}); ok && delta["type"] == "text_delta" {
+			text, _ := delta["text"].(string)
+			c.add(0, text)
+		}
+		return
+	}
+	if choices, ok := chunk["choices"].([]interface{}); ok {
+		for i, choice := range choices {
+			ch, _ := choice.(map[string]interface{})
+			delta, _ := ch["delta"].(map[string]interface{})
+			text, ok := delta["content"].(string)
+			if !ok {
+				text, _ = ch["text"].(string)
+			}
+			c.add(choiceIndex(ch, i), text)
+		}
+		return
+	}
+	c.ollama(chunk)
+}
+
+// ollama collects the text of an Ollama chat message or generation, whole or a streamed line.
+func (c *choiceTexts) ollama(response map[string]interface{}) {
+	if message, ok := response["message"].(map[string]interface{}); ok {
+		text, _ := message["content"].(string)
+		c.add(0, text)
+		return
+	}
+	text, _ := response["response"].(string)
+	c.add(0, text)
+}
+
+// hash returns the hash of the collected text, the choices' texts concatenated in index order.
+func (c *choiceTexts) hash() string {
+	sum := sha256.New()
+	for _, index := range slices.Sorted(maps.Keys(c.texts)) {
+		sum.Write([]byte(c.texts[index].String()))
+	}
+	return "sha256:" + hex.EncodeToString(sum.Sum(nil))
+}
+
+// choiceIndex returns the index of choice, which is its position i unless it says otherwise.
+func choiceIndex(choice map[string]interface{}, i int) int {
+	switch index := choice["index"].(type) {
+	case float64:
+		return int(index)
+	case int:
+		return index
+	}
+	return i
+}
@@ -0,0 +1,104 @@
+package provenance
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func newStamper(t *testing.T, mode, secret string) *Stamper {
+	t.Helper()
+	s := New(&config.ProvenanceConfig{Mode: mode, Secret: secret})
+	require.NotNil(t, s)
+	s.now = func() time.Time { return time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC) }
+	return s
+}
+
+func sha256Hex(text string) string {
+	sum := sha256.Sum256([]byte(text))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestNew(t *testing.T) {
+	assert.Nil(t, New(&config.ProvenanceConfig{}))
+	var s *Stamper
+	response := map[string]interface{}{"id": "chatcmpl-1"}
+	assert.Equal(t, response, s.Response(http.Header{}, response, "gpt-4", "openai"), "a nil stamper stamps nothing")
+}
+
+func TestStamper_Response(t *testing.T) {
+	s := newStamper(t, config.ProvenanceHeaders, "shh")
+	h := http.Header{}
+	response := map[string]interface{}{"id": "chatcmpl-1", "choices": []interface{}{
+		map[string]interface{}{"index": float64(1), "message": map[string]interface{}{"content": " world"}},
+		map[string]interface{}{"index": float64(0), "message": map[string]interface{}{"content": "Hello"}},
+	}}
+
+	result := s.Response(h, response, "gpt-4", "openai")
+	assert.Equal(t, response, result, "headers mode leaves the body alone")
+	assert.Equal(t, "gpt-4", h.Get(HeaderModel))
+	assert.Equal(t, "openai", h.Get(HeaderProvider))
+	assert.Equal(t, "chatcmpl-1", h.Get(HeaderRequestID))
+	assert.Equal(t, "2026-03-04T05:06:07Z", h.Get(HeaderTimestamp))
+	assert.Equal(t, sha256Hex("Hello world"), h.Get(HeaderHash), "choices are hashed in index order")
+
+	mac := hmac.New(sha256.New, []byte("shh"))
+	mac.Write([]byte("gpt-4\nopenai\nchatcmpl-1\n2026-03-04T05:06:07Z\n" + sha256Hex("Hello world")))
+	assert.Equal(t, hex.EncodeToString(mac.Sum(nil)), h.Get(HeaderSignature))
+
+	s = newStamper(t, config.ProvenanceBody, "")
+	h = http.Header{}
+	anthropic := map[string]interface{}{"id": "msg_1", "content": []interface{}{
+		map[string]interface{}{"type": "text", "text": "Hi"},
+	}}
+	stamped := s.Response(h, anthropic, "claude-3-sonnet", "").(map[string]interface{})
+	assert.Empty(t, h)
+	assert.Equal(t, Stamp{
+		Model: "claude-3-sonnet", RequestID: "msg_1", Timestamp: s.now(), Hash: sha256Hex("Hi"),
+	}, stamped[Field])
+	assert.NotContains(t, anthropic, Field)
+}
+
+func TestStamper_Stream(t *testing.T) {
+	s := newStamper(t, config.ProvenanceBoth, "")
+	h := http.Header{}
+	upstream := make(chan interface{}, 3)
+	upstream <- map[string]interface{}{"id": "chatcmpl-2", "object": "chat.completion.chunk", "choices": []interface{}{
+		map[string]interface{}{"index": float64(0), "delta": map[string]interface{}{"content": "Hel"}},
+	}}
+	upstream <- map[string]interface{}{"id": "chatcmpl-2", "object": "chat.completion.chunk", "choices": []interface{}{
+		map[string]interface{}{"index": float64(0), "delta": map[string]interface{}{"content": "lo"}},
+	}}
+	close(upstream)
+
+	var chunks []interface{}
+	for chunk := range s.Stream(h, upstream, "gpt-4", "openai") {
+		chunks = append(chunks, chunk)
+	}
+	assert.Equal(t, "openai", h.Get(HeaderProvider))
+	assert.Empty(t, h.Get(HeaderHash), "stream headers are sent before the text exists")
+	require.Len(t, chunks, 3)
+	last := chunks[2].(map[string]interface{})
+	assert.Equal(t, "chat.completion.chunk", last["object"])
+	assert.Equal(t, []interface{}{}, last["choices"])
+	assert.Equal(t, Stamp{
+		Model: "gpt-4", Provider: "openai", RequestID: "chatcmpl-2", Timestamp: s.now(), Hash: sha256Hex("Hello"),
+	}, last[Field])
+
+	failing := make(chan interface{}, 1)
+	failing <- map[string]interface{}{"error": map[string]interface{}{"message": "overloaded"}}
+	close(failing)
+	chunks = nil
+	for chunk := range s.Stream(http.Header{}, failing, "gpt-4", "openai") {
+		chunks = append(chunks, chunk)
+	}
+	assert.Len(t, chunks, 1, "failed streams aren't stamped")
+}
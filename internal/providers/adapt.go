@@ -0,0 +1,563 @@
+// Package providers implements AI provider abstractions.
+// This file adapts between transports for providers that only support one of them: a streaming
+// request to a provider that can't stream is served from the complete response, chunked into
+// synthetic deltas, and a non-streaming request to a provider that only streams is served by
+// aggregating its stream into one response. Either way the client gets the provider's usual shape.
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// errEmptyStream is returned when a stream to be aggregated ends without a chunk.
+var errEmptyStream = errors.New("stream ended without a response")
+
+// shape is the response format of a provider endpoint.
+type shape int
+
+const (
+	shapeOpenAIChat shape = iota
+	shapeOpenAICompletion
+	shapeAnthropic
+	shapeOllamaChat
+	shapeOllamaGenerate
+)
+
+func shapeOf(kind string, chat bool) shape {
+	switch {
+	case kind == "anthropic" && chat:
+		return shapeAnthropic
+	case kind == "ollama" && chat:
+		return shapeOllamaChat
+	case kind == "ollama":
+		return shapeOllamaGenerate
+	case chat:
+		return shapeOpenAIChat
+	default:
+		// Chat-only backends answer completions in the text completion schema too
+		return shapeOpenAICompletion
+	}
+}
+
+// transportAdapter serves the transport a provider doesn't support through the one it does.
+type transportAdapter struct {
+	Provider
+	kind string
+	// streaming is config.StreamingUnsupported or config.StreamingRequired
+	streaming string
+}
+
+// Forward implements RawForwarder; raw requests reach the provider as they are.
+func (p *transportAdapter) Forward(req *http.Request, path string) (*http.Response, error) {
+	return forwardThrough(p.Provider, req, path)
+}
+
+// ChatCompletion aggregates a stream when the provider only streams.
+func (p *transportAdapter) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	if p.streaming != config.StreamingRequired {
+		return p.Provider.ChatCompletion(ctx, model, messages)
+	}
+	stream, err := p.Provider.ChatCompletionStream(ctx, model, messages)
+	if err != nil {
+		return nil, err
+	}
+	return joinStream(ctx, shapeOf(p.kind, true), stream)
+}
+
+// Completion aggregates a stream when the provider only streams.
+func (p *transportAdapter) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	if p.streaming != config.StreamingRequired {
+		return p.Provider.Completion(ctx, model, prompt)
+	}
+	stream, err := p.Provider.CompletionStream(ctx, model, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return joinStream(ctx, shapeOf(p.kind, false), stream)
+}
+
+// ChatCompletionStream chunks a complete response when the provider can't stream.
+func (p *transportAdapter) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	if p.streaming != config.StreamingUnsupported {
+		return p.Provider.ChatCompletionStream(ctx, model, messages)
+	}
+	response, err := p.Provider.ChatCompletion(ctx, model, messages)
+	if err != nil {
+		return nil, err
+	}
+	return replay(ctx, splitResponse(shapeOf(p.kind, true), response)), nil
+}
+
+// CompletionStream chunks a complete response when the provider can't stream.
+func (p *transportAdapter) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	if p.streaming != config.StreamingUnsupported {
+		return p.Provider.CompletionStream(ctx, model, prompt)
+	}
+	response, err := p.Provider.Completion(ctx, model, prompt)
+	if err != nil {
+		return nil, err
+	}
+	return replay(ctx, splitResponse(shapeOf(p.kind, false), response)), nil
+}
+
+// replay sends chunks on a channel that is closed after the last one or once ctx is done.
+func replay(ctx context.Context, chunks []interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for _, chunk := range chunks {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// splitText splits text into word-sized deltas that join back to it.
+func splitText(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.SplitAfter(text, " ")
+}
+
+// splitResponse chunks a complete response into the deltas the provider would have streamed.
+// A response of an unknown form is sent as a single chunk.
+func splitResponse(s shape, result interface{}) []interface{} {
+	response, ok := result.(map[string]interface{})
+	if !ok {
+		return []interface{}{result}
+	}
+	switch s {
+	case shapeOpenAIChat, shapeOpenAICompletion:
+		return splitOpenAI(response, s == shapeOpenAIChat)
+	case shapeAnthropic:
+		return splitAnthropic(response)
+	default:
+		return splitOllama(response, s == shapeOllamaChat)
+	}
+}
+
+func splitOpenAI(response map[string]interface{}, chat bool) []interface{} {
+	object := "text_completion"
+	if chat {
+		object = "chat.completion.chunk"
+	}
+	chunk := func(choice map[string]interface{}) map[string]interface{} {
+		c := map[string]interface{}{"object": object, "choices": []interface{}{choice}}
+		for _, field := range []string{"id", "created", "model", "system_fingerprint"} {
+			if value, ok := response[field]; ok {
+				c[field] = value
+			}
+		}
+		return c
+	}
+
+	var chunks []interface{}
+	choices, _ := response["choices"].([]interface{})
+	for _, choice := range choices {
+		c, _ := choice.(map[string]interface{})
+		index := c["index"]
+		if !chat {
+			text, _ := c["text"].(string)
+			for _, piece := range splitText(text) {
+				chunks = append(chunks, chunk(map[string]interface{}{"index": index, "text": piece, "finish_reason": nil}))
+			}
+			chunks = append(chunks, chunk(map[string]interface{}{
+				"index": index, "text": "", "finish_reason": c["finish_reason"],
+			}))
+			continue
+		}
+
+		message, _ := c["message"].(map[string]interface{})
+		delta := func(delta map[string]interface{}) {
+			chunks = append(chunks, chunk(map[string]interface{}{"index": index, "delta": delta, "finish_reason": nil}))
+		}
+		delta(map[string]interface{}{"role": message["role"]})
+		if reasoning, ok := message["reasoning_content"].(string); ok {
+			delta(map[string]interface{}{"reasoning_content": reasoning})
+		}
+		content, _ := message["content"].(string)
+		for _, piece := range splitText(content) {
+			delta(map[string]interface{}{"content": piece})
+		}
+		if calls, ok := message["tool_calls"].([]interface{}); ok {
+			indexed := make([]interface{}, 0, len(calls))
+			for i, call := range calls {
+				call, _ := call.(map[string]interface{})
+				call = maps.Clone(call)
+				call["index"] = float64(i)
+				indexed = append(indexed, call)
+			}
+			delta(map[string]interface{}{"tool_calls": indexed})
+		}
+		chunks = append(chunks, chunk(map[string]interface{}{
+			"index": index, "delta": map[string]interface{}{}, "finish_reason": c["finish_reason"],
+		}))
+	}
+	if usage, ok := response["usage"]; ok {
+		last := chunk(nil)
+		last["choices"] = []interface{}{}
+		last["usage"] = usage
+		chunks = append(chunks, last)
+	}
+	return chunks
+}
+
+func splitAnthropic(response map[string]interface{}) []interface{} {
+	message := maps.Clone(response)
+	message["content"] = []interface{}{}
+	message["stop_reason"] = nil
+	chunks := []interface{}{map[string]interface{}{"type": "message_start", "message": message}}
+
+	blocks, _ := response["content"].([]interface{})
+	for i, block := range blocks {
+		b, _ := block.(map[string]interface{})
+		index := float64(i)
+		start := maps.Clone(b)
+		var deltas []map[string]interface{}
+		switch b["type"] {
+		case "text":
+			start["text"] = ""
+			text, _ := b["text"].(string)
+			for _, piece := range splitText(text) {
+				deltas = append(deltas, map[string]interface{}{"type": "text_delta", "text": piece})
+			}
+		case "thinking":
+			start["thinking"] = ""
+			deltas = append(deltas, map[string]interface{}{"type": "thinking_delta", "thinking": b["thinking"]})
+			if signature, ok := b["signature"]; ok {
+				start["signature"] = ""
+				deltas = append(deltas, map[string]interface{}{"type": "signature_delta", "signature": signature})
+			}
+		case "tool_use":
+			start["input"] = map[string]interface{}{}
+			input, _ := json.Marshal(b["input"])
+			deltas = append(deltas, map[string]interface{}{"type": "input_json_delta", "partial_json": string(input)})
+		}
+
+		chunks = append(chunks, map[string]interface{}{"type": "content_block_start", "index": index, "content_block": start})
+		for _, delta := range deltas {
+			chunks = append(chunks, map[string]interface{}{"type": "content_block_delta", "index": index, "delta": delta})
+		}
+		chunks = append(chunks, map[string]interface{}{"type": "content_block_stop", "index": index})
+	}
+
+	messageDelta := map[string]interface{}{
+		"type":  "message_delta",
+		"delta": map[string]interface{}{"stop_reason": response["stop_reason"], "stop_sequence": response["stop_sequence"]},
+	}
+	if usage, ok := response["usage"].(map[string]interface{}); ok {
+		messageDelta["usage"] = map[string]interface{}{"output_tokens": usage["output_tokens"]}
+	}
+	return append(chunks, messageDelta, map[string]interface{}{"type": "message_stop"})
+}
+
+func splitOllama(response map[string]interface{}, chat bool) []interface{} {
+	var text string
+	if chat {
+		message, _ := response["message"].(map[string]interface{})
+		text, _ = message["content"].(string)
+	} else {
+		text, _ = response["response"].(string)
+	}
+
+	var chunks []interface{}
+	for _, piece := range splitText(text) {
+		chunk := map[string]interface{}{"model": response["model"], "created_at": response["created_at"], "done": false}
+		if chat {
+			chunk["message"] = map[string]interface{}{"role": "assistant", "content": piece}
+		} else {
+			chunk["response"] = piece
+		}
+		chunks = append(chunks, chunk)
+	}
+
+	// The final chunk carries the statistics and everything but the text
+	last := maps.Clone(response)
+	if chat {
+		message, _ := response["message"].(map[string]interface{})
+		message = maps.Clone(message)
+		if message == nil {
+			message = map[string]interface{}{"role": "assistant"}
+		}
+		message["content"] = ""
+		last["message"] = message
+	} else {
+		last["response"] = ""
+	}
+	return append(chunks, last)
+}
+
+// joinStream aggregates a stream into the response the provider would have returned without streaming.
+func joinStream(ctx context.Context, s shape, stream <-chan interface{}) (interface{}, error) {
+	var chunks []map[string]interface{}
+	for {
+		select {
+		case chunk, ok := <-stream:
+			if !ok {
+				if len(chunks) == 0 {
+					return nil, errEmptyStream
+				}
+				switch s {
+				case shapeOpenAIChat, shapeOpenAICompletion:
+					return joinOpenAI(chunks, s == shapeOpenAIChat), nil
+				case shapeAnthropic:
+					return joinAnthropic(chunks), nil
+				default:
+					return joinOllama(chunks, s == shapeOllamaChat), nil
+				}
+			}
+			if c, ok := chunk.(map[string]interface{}); ok {
+				chunks = append(chunks, c)
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// joinedChoice is an OpenAI choice being aggregated.
+type joinedChoice struct {
+	role, finishReason interface{}
+	content, reasoning strings.Builder
+	hasReasoning       bool
+	// calls are the tool calls by index; fragments after the first only add to the arguments
+	calls map[float64]map[string]interface{}
+}
+
+func joinOpenAI(chunks []map[string]interface{}, chat bool) map[string]interface{} {
+	object := "text_completion"
+	if chat {
+		object = "chat.completion"
+	}
+	response := map[string]interface{}{"object": object}
+	choices := make(map[float64]*joinedChoice)
+	for _, chunk := range chunks {
+		for _, field := range []string{"id", "created", "model", "system_fingerprint"} {
+			if value, ok := chunk[field]; ok {
+				response[field] = value
+			}
+		}
+		if usage, ok := chunk["usage"]; ok && usage != nil {
+			response["usage"] = usage
+		}
+
+		list, _ := chunk["choices"].([]interface{})
+		for _, choice := range list {
+			c, _ := choice.(map[string]interface{})
+			index, _ := c["index"].(float64)
+			joined, ok := choices[index]
+			if !ok {
+				joined = &joinedChoice{calls: make(map[float64]map[string]interface{})}
+				choices[index] = joined
+			}
+			if c["finish_reason"] != nil {
+				joined.finishReason = c["finish_reason"]
+			}
+			if !chat {
+				text, _ := c["text"].(string)
+				joined.content.WriteString(text)
+				continue
+			}
+
+			delta, _ := c["delta"].(map[string]interface{})
+			if role, ok := delta["role"]; ok && role != nil {
+				joined.role = role
+			}
+			if content, ok := delta["content"].(string); ok {
+				joined.content.WriteString(content)
+			}
+			for _, field := range []string{"reasoning_content", "reasoning"} {
+				if reasoning, ok := delta[field].(string); ok {
+					joined.reasoning.WriteString(reasoning)
+					joined.hasReasoning = true
+					break
+				}
+			}
+			fragments, _ := delta["tool_calls"].([]interface{})
+			for _, fragment := range fragments {
+				joined.addCall(fragment)
+			}
+		}
+	}
+
+	out := make([]interface{}, 0, len(choices))
+	for _, index := range slices.Sorted(maps.Keys(choices)) {
+		joined := choices[index]
+		choice := map[string]interface{}{"index": index, "finish_reason": joined.finishReason}
+		if !chat {
+			choice["text"] = joined.content.String()
+			out = append(out, choice)
+			continue
+		}
+
+		message := map[string]interface{}{"role": joined.role, "content": joined.content.String()}
+		if joined.role == nil {
+			message["role"] = "assistant"
+		}
+		if joined.hasReasoning {
+			message["reasoning_content"] = joined.reasoning.String()
+		}
+		if len(joined.calls) > 0 {
+			calls := make([]interface{}, 0, len(joined.calls))
+			for _, i := range slices.Sorted(maps.Keys(joined.calls)) {
+				call := joined.calls[i]
+				delete(call, "index")
+				calls = append(calls, call)
+			}
+			message["tool_calls"] = calls
+			if joined.content.Len() == 0 {
+				message["content"] = nil
+			}
+		}
+		choice["message"] = message
+		out = append(out, choice)
+	}
+	response["choices"] = out
+	return response
+}
+
+func (c *joinedChoice) addCall(fragment interface{}) {
+	f, _ := fragment.(map[string]interface{})
+	index, _ := f["index"].(float64)
+	function, _ := f["function"].(map[string]interface{})
+	arguments, _ := function["arguments"].(string)
+
+	call, ok := c.calls[index]
+	if !ok {
+		call = maps.Clone(f)
+		function = maps.Clone(function)
+		if function == nil {
+			function = make(map[string]interface{})
+		}
+		function["arguments"] = arguments
+		call["function"] = function
+		c.calls[index] = call
+		return
+	}
+	function, _ = call["function"].(map[string]interface{})
+	previous, _ := function["arguments"].(string)
+	function["arguments"] = previous + arguments
+}
+
+func joinAnthropic(chunks []map[string]interface{}) map[string]interface{} {
+	response := map[string]interface{}{"type": "message", "role": "assistant"}
+	blocks := make(map[float64]map[string]interface{})
+	inputs := make(map[float64]*strings.Builder)
+	for _, chunk := range chunks {
+		index, _ := chunk["index"].(float64)
+		switch chunk["type"] {
+		case "message_start":
+			message, _ := chunk["message"].(map[string]interface{})
+			maps.Copy(response, message)
+		case "content_block_start":
+			block, _ := chunk["content_block"].(map[string]interface{})
+			blocks[index] = maps.Clone(block)
+		case "content_block_delta":
+			block, ok := blocks[index]
+			if !ok {
+				continue
+			}
+			delta, _ := chunk["delta"].(map[string]interface{})
+			switch delta["type"] {
+			case "text_delta":
+				appendField(block, "text", delta["text"])
+			case "thinking_delta":
+				appendField(block, "thinking", delta["thinking"])
+			case "signature_delta":
+				appendField(block, "signature", delta["signature"])
+			case "input_json_delta":
+				if inputs[index] == nil {
+					inputs[index] = &strings.Builder{}
+				}
+				partial, _ := delta["partial_json"].(string)
+				inputs[index].WriteString(partial)
+			}
+		case "message_delta":
+			delta, _ := chunk["delta"].(map[string]interface{})
+			maps.Copy(response, delta)
+			if usage, ok := chunk["usage"].(map[string]interface{}); ok {
+				merged, _ := response["usage"].(map[string]interface{})
+				merged = maps.Clone(merged)
+				if merged == nil {
+					merged = make(map[string]interface{})
+				}
+				maps.Copy(merged, usage)
+				response["usage"] = merged
+			}
+		}
+	}
+
+	content := make([]interface{}, 0, len(blocks))
+	for _, index := range slices.Sorted(maps.Keys(blocks)) {
+		block := blocks[index]
+		if input, ok := inputs[index]; ok {
+			var value interface{}
+			if err := json.Unmarshal([]byte(input.String()), &value); err == nil {
+				block["input"] = value
+			}
+		}
+		content = append(content, block)
+	}
+	response["content"] = content
+	return response
+}
+
+func appendField(block map[string]interface{}, field string, value interface{}) {
+	previous, _ := block[field].(string)
+	text, _ := value.(string)
+	block[field] = previous + text
+}
+
+func joinOllama(chunks []map[string]interface{}, chat bool) map[string]interface{} {
+	var text, thinking strings.Builder
+	for _, chunk := range chunks {
+		if chat {
+			message, _ := chunk["message"].(map[string]interface{})
+			content, _ := message["content"].(string)
+			text.WriteString(content)
+			reasoning, _ := message["thinking"].(string)
+			thinking.WriteString(reasoning)
+		} else {
+			content, _ := chunk["response"].(string)
+			text.WriteString(content)
+			reasoning, _ := chunk["thinking"].(string)
+			thinking.WriteString(reasoning)
+		}
+	}
+
+	// The last chunk carries the statistics
+	response := maps.Clone(chunks[len(chunks)-1])
+	target := response
+	if chat {
+		message, _ := response["message"].(map[string]interface{})
+		target = maps.Clone(message)
+		if target == nil {
+			target = map[string]interface{}{"role": "assistant"}
+		}
+		response["message"] = target
+		target["content"] = text.String()
+	} else {
+		target["response"] = text.String()
+	}
+	if thinking.Len() > 0 {
+		target["thinking"] = thinking.String()
+	}
+	return response
+}
@@ -0,0 +1,163 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func decodeJSON(t *testing.T, data string) interface{} {
+	t.Helper()
+	var value interface{}
+	require.NoError(t, json.Unmarshal([]byte(data), &value))
+	return value
+}
+
+// roundTrip chunks response and aggregates the chunks again, as JSON so numbers compare equal.
+func roundTrip(t *testing.T, s shape, response string) ([]interface{}, interface{}) {
+	t.Helper()
+	chunks := splitResponse(s, decodeJSON(t, response))
+
+	data, err := json.Marshal(chunks)
+	require.NoError(t, err)
+	var decoded []interface{}
+	require.NoError(t, json.Unmarshal(data, &decoded))
+
+	joined, err := joinStream(t.Context(), s, replay(t.Context(), decoded))
+	require.NoError(t, err)
+	return decoded, joined
+}
+
+func TestTransportAdaptation_RoundTrip(t *testing.T) {
+	tests := []struct {
+		name     string
+		shape    shape
+		response string
+		chunks   int
+	}{
+		{
+			name:  "openai chat",
+			shape: shapeOpenAIChat,
+			response: `{"id":"c1","object":"chat.completion","created":1,"model":"gpt-4","choices":[{"index":0,` +
+				`"message":{"role":"assistant","content":"Hello there world"},"finish_reason":"stop"}],` +
+				`"usage":{"prompt_tokens":3,"completion_tokens":3}}`,
+			// role, three words, finish and usage
+			chunks: 6,
+		},
+		{
+			name:  "openai tool calls",
+			shape: shapeOpenAIChat,
+			response: `{"id":"c1","object":"chat.completion","created":1,"model":"gpt-4","choices":[{"index":0,` +
+				`"message":{"role":"assistant","content":null,"tool_calls":[{"id":"call_1","type":"function",` +
+				`"function":{"name":"ping","arguments":"{}"}}]},"finish_reason":"tool_calls"}]}`,
+			chunks: 3,
+		},
+		{
+			name:  "openai completion",
+			shape: shapeOpenAICompletion,
+			response: `{"id":"c1","object":"text_completion","created":1,"model":"gpt-3.5-turbo-instruct",` +
+				`"choices":[{"index":0,"text":"Hello there","finish_reason":"length"}]}`,
+			chunks: 3,
+		},
+		{
+			name:  "anthropic",
+			shape: shapeAnthropic,
+			response: `{"id":"msg_1","type":"message","role":"assistant","model":"claude-3-sonnet","content":[` +
+				`{"type":"thinking","thinking":"hmm","signature":"sig"},{"type":"text","text":"Hello there"},` +
+				`{"type":"tool_use","id":"tu_1","name":"ping","input":{"host":"example.com"}}],` +
+				`"stop_reason":"tool_use","stop_sequence":null,"usage":{"input_tokens":3,"output_tokens":7}}`,
+			// message start, three blocks with 2, 2 and 1 deltas, message delta and stop
+			chunks: 1 + (2 + 2) + (2 + 2) + (2 + 1) + 2,
+		},
+		{
+			name:     "ollama chat",
+			shape:    shapeOllamaChat,
+			response: `{"model":"llama2","created_at":"t","message":{"role":"assistant","content":"Hello there"},"done":true,"eval_count":2}`,
+			chunks:   3,
+		},
+		{
+			name:     "ollama generate",
+			shape:    shapeOllamaGenerate,
+			response: `{"model":"llama2","created_at":"t","response":"Hello there","done":true,"eval_count":2}`,
+			chunks:   3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chunks, joined := roundTrip(t, tt.shape, tt.response)
+			assert.Len(t, chunks, tt.chunks)
+			assert.Equal(t, decodeJSON(t, tt.response), joined)
+		})
+	}
+}
+
+func TestTransportAdaptation_EmptyStream(t *testing.T) {
+	_, err := joinStream(t.Context(), shapeOpenAIChat, replay(t.Context(), nil))
+	assert.ErrorIs(t, err, errEmptyStream)
+}
+
+func TestTransportAdapter_StreamingUnsupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.NotContains(t, req, "stream")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"c1","choices":[{"index":0,"message":{"role":"assistant","content":"Hi you"},` +
+			`"finish_reason":"stop"}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(&config.Provider{
+		Name: "openai", Type: "openai", BaseURL: server.URL, Streaming: config.StreamingUnsupported,
+	})
+	stream, err := provider.ChatCompletionStream(t.Context(), "gpt-4", userMessage)
+	require.NoError(t, err)
+
+	var content string
+	for chunk := range stream {
+		choices := chunk.(map[string]interface{})["choices"].([]interface{})
+		delta := choices[0].(map[string]interface{})["delta"].(map[string]interface{})
+		text, _ := delta["content"].(string)
+		content += text
+	}
+	assert.Equal(t, "Hi you", content)
+}
+
+func TestTransportAdapter_StreamingRequired(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, true, req["stream"])
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, text := range []string{"Hi ", "you"} {
+			_, _ = fmt.Fprintf(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{\"content\":%q}}]}\n\n", text)
+		}
+		_, _ = fmt.Fprint(w, "data: {\"id\":\"c1\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n")
+		_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+	}))
+	defer server.Close()
+
+	provider := NewProvider(&config.Provider{
+		Name: "openai", Type: "openai", BaseURL: server.URL, Streaming: config.StreamingRequired,
+	})
+	result, err := provider.ChatCompletion(t.Context(), "gpt-4", userMessage)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{
+		"id":     "c1",
+		"object": "chat.completion",
+		"choices": []interface{}{map[string]interface{}{
+			"index":         float64(0),
+			"message":       map[string]interface{}{"role": "assistant", "content": "Hi you"},
+			"finish_reason": "stop",
+		}},
+	}, result)
+}
@@ -1,30 +1,61 @@
 // Package providers implements AI provider abstractions.
 // AnthropicProvider provides Anthropic Claude API integration with key differences from OpenAI:
 // - Uses "x-api-key" header instead of "Authorization: Bearer"
-// - Requires "anthropic-version" header for API versioning
-// - Transforms OpenAI message format: system messages become separate "system" field
+// - Requires "anthropic-version" header for API versioning; beta features are enabled with "anthropic-beta"
+// - Transforms OpenAI messages with pkg/convert: system messages become the "system" field, tool calls content blocks
 // - Uses "/messages" endpoint instead of "/chat/completions"
+// - Has no completions endpoint: prompts are sent as a user message, replies unwrapped into the text completion schema
 // - Requires explicit max_tokens parameter (defaults to 4096)
+// - Lists models via a paginated "/models" endpoint (after_id/has_more cursor)
 package providers
 
 import (
 	"bytes"
+	"cmp"
 	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
-	"os"
+	"net/url"
+	"slices"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/pkg/convert"
 )
 
 const (
-	// Default max tokens for Anthropic API
+	// defaultMaxTokens is sent as max_tokens, which the API requires, for models without configured limits
 	defaultMaxTokens = 4096
+	// anthropicModelsPageSize is the largest page the models endpoint accepts
+	anthropicModelsPageSize = 1000
+	// anthropicModelsCacheTTL controls how long a fetched model catalog is reused
+	anthropicModelsCacheTTL = 10 * time.Minute
 )
 
+// anthropicParams translates OpenAI request parameters to the Messages API.
+// logit_bias, penalties, seed, n and logprobs have no Anthropic equivalent.
+var anthropicParams = &paramRules{rules: map[string]paramRule{
+	"temperature":           {set: rename("temperature")},
+	"top_p":                 {set: rename("top_p")},
+	"max_tokens":            {set: rename("max_tokens")},
+	"max_completion_tokens": {set: rename("max_tokens")},
+	"stop": stopRule(stopSpec{requireText: true, set: func(payload map[string]interface{}, sequences []string) {
+		payload["stop_sequences"] = sequences
+	}}),
+	"user": {set: func(payload map[string]interface{}, value interface{}) error {
+		payload["metadata"] = map[string]interface{}{"user_id": value}
+		return nil
+	}},
+	// There is no JSON mode, but asking for JSON in the system prompt gets close
+	"response_format": {emulate: emulateJSONMode},
+	// Extended thinking, e.g. {"type": "enabled", "budget_tokens": 2048}, is passed through as is
+	"thinking": {set: rename("thinking")},
+}}
+
 // AnthropicProvider implements the Provider interface for Anthropic Claude API.
 type AnthropicProvider struct {
 	name     string
@@ -33,24 +64,68 @@ type AnthropicProvider struct {
 	models   []string
 	priority int
 	client   *http.Client
+	tokens   *tokenSource // nil when authenticating with the static API key
+	version  string
+	// betas is the anthropic-beta header value, empty without beta features
+	betas string
+	// limits holds the configured token limits per model, for defaulting max_tokens
+	limits map[string]config.ModelLimits
+
+	// Remote model catalog, only used when no models are configured
+	modelsMtx      sync.Mutex
+	cachedModels   []string
+	modelsCachedAt time.Time
+}
+
+// anthropicModelsPage is a single page of the Anthropic models listing.
+type anthropicModelsPage struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+	HasMore bool   `json:"has_more"`
+	LastID  string `json:"last_id"`
 }
 
 // NewAnthropicProvider creates a new Anthropic provider instance.
 func NewAnthropicProvider(cfg *config.Provider) *AnthropicProvider {
-	apiKey := cfg.APIKey
-	if strings.HasPrefix(apiKey, "${") && strings.HasSuffix(apiKey, "}") {
-		envVar := strings.TrimSuffix(strings.TrimPrefix(apiKey, "${"), "}")
-		apiKey = os.Getenv(envVar)
-	}
-
 	return &AnthropicProvider{
 		name:     cfg.Name,
 		baseURL:  cfg.BaseURL,
-		apiKey:   apiKey,
+		apiKey:   resolveEnv(cfg.APIKey),
 		models:   cfg.Models,
 		priority: cfg.Priority,
-		client:   &http.Client{},
+		client:   newHTTPClient(cfg),
+		// The token endpoint is not the gateway, so it doesn't get the extras
+		tokens:  newTokenSource(&cfg.Auth, &http.Client{}),
+		version: cmp.Or(cfg.Anthropic.Version, config.DefaultAnthropicVersion),
+		betas:   anthropicBetaHeader(cfg.Anthropic.Betas),
+		limits:  cfg.ModelLimits,
+	}
+}
+
+// maxTokens returns the max_tokens sent when the request doesn't set it: what is left of the
+// model's context window after the estimated prompt, capped at its output limit, or
+// defaultMaxTokens when its limits aren't configured.
+func (p *AnthropicProvider) maxTokens(model string, messages []map[string]interface{}) int64 {
+	if tokens, ok := completionLimit(p.limits[model], EstimateMessagesTokens(messages)); ok {
+		return tokens
 	}
+	return defaultMaxTokens
+}
+
+// anthropicBetaHeader resolves beta feature names to header values; raw values pass through
+// as they are, so betas released after this build can be enabled too.
+func anthropicBetaHeader(betas []string) string {
+	values := make([]string, 0, len(betas))
+	for _, beta := range betas {
+		if value, ok := config.AnthropicBetas[beta]; ok {
+			beta = value
+		}
+		if !slices.Contains(values, beta) {
+			values = append(values, beta)
+		}
+	}
+	return strings.Join(values, ",")
 }
 
 // Name returns the provider name.
@@ -64,50 +139,129 @@ func (p *AnthropicProvider) Priority() int {
 }
 
 // ListModels returns the list of available models for this provider.
-func (p *AnthropicProvider) ListModels() []string {
-	return p.models
+// Configured models take precedence; without them the full catalog is fetched from the API and cached.
+// When a refresh fails the stale catalog, if any, is returned along with the error.
+func (p *AnthropicProvider) ListModels(ctx context.Context) ([]Model, error) {
+	if len(p.models) > 0 {
+		return modelsOf(p.models), nil
+	}
+
+	p.modelsMtx.Lock()
+	defer p.modelsMtx.Unlock()
+
+	if p.cachedModels != nil && time.Since(p.modelsCachedAt) < anthropicModelsCacheTTL {
+		return modelsOf(p.cachedModels), nil
+	}
+
+	models, err := p.fetchModels(ctx)
+	if err != nil {
+		return modelsOf(p.cachedModels), fmt.Errorf("fetching Anthropic models: %w", err)
+	}
+
+	p.cachedModels = models
+	p.modelsCachedAt = time.Now()
+	return modelsOf(models), nil
+}
+
+// fetchModels walks every page of the models endpoint using the after_id cursor.
+func (p *AnthropicProvider) fetchModels(ctx context.Context) ([]string, error) {
+	models := make([]string, 0)
+	afterID := ""
+
+	for {
+		query := url.Values{}
+		query.Set("limit", fmt.Sprint(anthropicModelsPageSize))
+		if afterID != "" {
+			query.Set("after_id", afterID)
+		}
+
+		page, err := p.fetchModelsPage(ctx, query)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, model := range page.Data {
+			models = append(models, model.ID)
+		}
+
+		// An empty cursor would restart from the first page forever
+		if !page.HasMore || page.LastID == "" {
+			return models, nil
+		}
+		afterID = page.LastID
+	}
+}
+
+func (p *AnthropicProvider) fetchModelsPage(ctx context.Context, query url.Values) (*anthropicModelsPage, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.baseURL+"/models?"+query.Encode(), http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := p.authHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var page anthropicModelsPage
+	if err := json.Unmarshal(body, &page); err != nil {
+		return nil, err
+	}
+
+	return &page, nil
 }
 
 // ChatCompletion performs a chat completion request with Anthropic-specific formatting.
 func (p *AnthropicProvider) ChatCompletion(
 	ctx context.Context, model string, messages []map[string]interface{},
 ) (interface{}, error) {
-	anthropicMessages := make([]map[string]interface{}, 0)
-	var systemMessage string
-
-	for _, msg := range messages {
-		role := msg["role"].(string)
-		content := msg["content"].(string)
-
-		if role == "system" {
-			systemMessage = content
-		} else {
-			anthropicMessages = append(anthropicMessages, map[string]interface{}{
-				"role":    role,
-				"content": content,
-			})
-		}
-	}
-
+	systemMessage, anthropicMessages := convert.OpenAIToAnthropicMessages(messages)
 	payload := map[string]interface{}{
 		"model":      model,
 		"messages":   anthropicMessages,
-		"max_tokens": defaultMaxTokens,
+		"max_tokens": p.maxTokens(model, messages),
 	}
 
 	if systemMessage != "" {
 		payload["system"] = systemMessage
 	}
+	if err := applyParams(ctx, p.name, payload, anthropicParams); err != nil {
+		return nil, err
+	}
 
 	return p.makeRequest(ctx, "/messages", payload)
 }
 
-// Completion performs a completion request by converting to chat format.
+// Completion performs a completion request by sending the prompt as a user message, and returns
+// the reply in the legacy text completion schema.
 func (p *AnthropicProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
 	messages := []map[string]interface{}{
 		{"role": "user", "content": prompt},
 	}
-	return p.ChatCompletion(ctx, model, messages)
+	result, err := p.ChatCompletion(ctx, model, messages)
+	if err != nil {
+		return nil, err
+	}
+	message, _ := result.(map[string]interface{})
+	return anthropicTextCompletion(message, time.Now()), nil
 }
 
 func (p *AnthropicProvider) makeRequest(
@@ -123,9 +277,15 @@ func (p *AnthropicProvider) makeRequest(
 		return nil, err
 	}
 
+	headers, err := p.authHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("x-api-key", p.apiKey)
-	req.Header.Set("anthropic-version", "2023-06-01")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -139,7 +299,7 @@ func (p *AnthropicProvider) makeRequest(
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result interface{}
@@ -154,56 +314,163 @@ func (p *AnthropicProvider) makeRequest(
 func (p *AnthropicProvider) ChatCompletionStream(
 	ctx context.Context, model string, messages []map[string]interface{},
 ) (<-chan interface{}, error) {
-	// Transform messages to Anthropic format (same as non-streaming)
-	var systemMessage string
-	var anthropicMessages []map[string]interface{}
-
-	for _, msg := range messages {
-		role := msg["role"].(string)
-		content := msg["content"].(string)
-
-		if role == "system" {
-			systemMessage = content
-		} else {
-			anthropicMessages = append(anthropicMessages, map[string]interface{}{
-				"role":    role,
-				"content": content,
-			})
-		}
-	}
-
+	systemMessage, anthropicMessages := convert.OpenAIToAnthropicMessages(messages)
 	payload := map[string]interface{}{
 		"model":      model,
 		"messages":   anthropicMessages,
-		"max_tokens": defaultMaxTokens,
+		"max_tokens": p.maxTokens(model, messages),
 		"stream":     true,
 	}
 
 	if systemMessage != "" {
 		payload["system"] = systemMessage
 	}
+	if err := applyParams(ctx, p.name, payload, anthropicParams); err != nil {
+		return nil, err
+	}
 
 	return p.makeStreamingRequest(ctx, "/messages", payload)
 }
 
-// CompletionStream performs a streaming completion request.
+// CompletionStream performs a streaming completion request, streaming the reply as legacy text
+// completion chunks.
 func (p *AnthropicProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
 	messages := []map[string]interface{}{
 		{"role": "user", "content": prompt},
 	}
-	return p.ChatCompletionStream(ctx, model, messages)
+	events, err := p.ChatCompletionStream(ctx, model, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		completion := &anthropicCompletionStream{created: time.Now().Unix()}
+		for event := range events {
+			chunk := completion.chunk(event)
+			if chunk == nil {
+				continue
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// anthropicTextCompletion unwraps a Messages API reply into the legacy text completion schema.
+func anthropicTextCompletion(message map[string]interface{}, now time.Time) map[string]interface{} {
+	var text strings.Builder
+	blocks, _ := message["content"].([]interface{})
+	for _, block := range blocks {
+		if b, ok := block.(map[string]interface{}); ok && b["type"] == "text" {
+			s, _ := b["text"].(string)
+			text.WriteString(s)
+		}
+	}
+
+	stopReason, _ := message["stop_reason"].(string)
+	completion := map[string]interface{}{
+		"id":      message["id"],
+		"object":  "text_completion",
+		"created": now.Unix(),
+		"model":   message["model"],
+		"choices": []interface{}{map[string]interface{}{
+			"text":          text.String(),
+			"index":         0,
+			"logprobs":      nil,
+			"finish_reason": convert.AnthropicFinishReason(stopReason),
+		}},
+	}
+	if usage, ok := message["usage"].(map[string]interface{}); ok {
+		input, _ := usage["input_tokens"].(float64)
+		output, _ := usage["output_tokens"].(float64)
+		completion["usage"] = map[string]interface{}{
+			"prompt_tokens":     input,
+			"completion_tokens": output,
+			"total_tokens":      input + output,
+		}
+	}
+	return completion
+}
+
+// anthropicCompletionStream turns Messages API stream events into legacy text completion chunks.
+type anthropicCompletionStream struct {
+	id, model interface{}
+	created   int64
+	// inputTokens is reported by message_start, output tokens only at the end
+	inputTokens float64
+}
+
+// chunk returns the completion chunk for event, or nil when it carries no text or finish reason.
+// Error events are passed on as they are.
+func (s *anthropicCompletionStream) chunk(event interface{}) interface{} {
+	e, ok := event.(map[string]interface{})
+	if !ok {
+		return event
+	}
+
+	switch e["type"] {
+	case "message_start":
+		message, _ := e["message"].(map[string]interface{})
+		s.id, s.model = message["id"], message["model"]
+		usage, _ := message["usage"].(map[string]interface{})
+		s.inputTokens, _ = usage["input_tokens"].(float64)
+	case "content_block_delta":
+		delta, _ := e["delta"].(map[string]interface{})
+		if text, ok := delta["text"].(string); ok && delta["type"] == "text_delta" {
+			return s.completionChunk(text, nil)
+		}
+	case "message_delta":
+		delta, _ := e["delta"].(map[string]interface{})
+		stopReason, _ := delta["stop_reason"].(string)
+		chunk := s.completionChunk("", convert.AnthropicFinishReason(stopReason))
+		if usage, ok := e["usage"].(map[string]interface{}); ok {
+			output, _ := usage["output_tokens"].(float64)
+			chunk["usage"] = map[string]interface{}{
+				"prompt_tokens":     s.inputTokens,
+				"completion_tokens": output,
+				"total_tokens":      s.inputTokens + output,
+			}
+		}
+		return chunk
+	case "error":
+		return e
+	}
+	return nil
+}
+
+func (s *anthropicCompletionStream) completionChunk(text string, finishReason interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"id":      s.id,
+		"object":  "text_completion",
+		"created": s.created,
+		"model":   s.model,
+		"choices": []interface{}{map[string]interface{}{
+			"text":          text,
+			"index":         0,
+			"logprobs":      nil,
+			"finish_reason": finishReason,
+		}},
+	}
 }
 
 func (p *AnthropicProvider) makeStreamingRequest(ctx context.Context, endpoint string,
 	payload interface{}) (<-chan interface{}, error) {
+	headers, err := p.authHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	reqConfig := StreamingRequestConfig{
-		BaseURL:  p.baseURL,
-		Endpoint: endpoint,
-		Payload:  payload,
-		Headers: map[string]string{
-			"x-api-key":         p.apiKey,
-			"anthropic-version": "2023-06-01",
-		},
+		BaseURL:     p.baseURL,
+		Endpoint:    endpoint,
+		Payload:     payload,
+		Headers:     headers,
 		UseSSE:      true,
 		Transformer: p.transformStreamingResponse,
 	}
@@ -211,6 +478,29 @@ func (p *AnthropicProvider) makeStreamingRequest(ctx context.Context, endpoint s
 	return makeStreamingRequest(ctx, p.client, reqConfig)
 }
 
+// authHeaders returns the Anthropic authentication and versioning headers.
+// With token auth configured (e.g. behind an enterprise gateway) a bearer token replaces x-api-key.
+func (p *AnthropicProvider) authHeaders(ctx context.Context) (map[string]string, error) {
+	headers := map[string]string{
+		"anthropic-version": p.version,
+	}
+	if p.betas != "" {
+		headers["anthropic-beta"] = p.betas
+	}
+
+	if p.tokens != nil {
+		token, err := p.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		headers["Authorization"] = "Bearer " + token
+	} else {
+		headers["x-api-key"] = p.apiKey
+	}
+
+	return headers, nil
+}
+
 // transformStreamingResponse transforms Anthropic streaming response to OpenAI format
 func (p *AnthropicProvider) transformStreamingResponse(chunk interface{}) interface{} {
 	// For now, pass through as-is. In a full implementation, we would
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -27,10 +28,94 @@ func TestNewAnthropicProvider(t *testing.T) {
 	assert.Equal(t, "anthropic", provider.Name())
 	assert.Equal(t, "https://api.anthropic.com/v1", provider.baseURL)
 	assert.Equal(t, "sk-ant-test123", provider.apiKey)
-	assert.Equal(t, []string{"claude-3-sonnet", "claude-3-haiku"}, provider.ListModels())
+	assert.Equal(t, []string{"claude-3-sonnet", "claude-3-haiku"}, listModelIDs(t, provider))
 	assert.Equal(t, 1, provider.Priority())
 }
 
+func TestAnthropicProvider_ListModelsPagination(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/models", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		assert.Equal(t, "2023-06-01", r.Header.Get("anthropic-version"))
+
+		var response map[string]interface{}
+		switch r.URL.Query().Get("after_id") {
+		case "":
+			response = map[string]interface{}{
+				"data":     []map[string]interface{}{{"id": "claude-3-opus"}, {"id": "claude-3-sonnet"}},
+				"has_more": true,
+				"last_id":  "claude-3-sonnet",
+			}
+		case "claude-3-sonnet":
+			response = map[string]interface{}{
+				"data":     []map[string]interface{}{{"id": "claude-3-haiku"}},
+				"has_more": false,
+				"last_id":  "claude-3-haiku",
+			}
+		default:
+			t.Errorf("unexpected after_id: %s", r.URL.Query().Get("after_id"))
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(response); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(&config.Provider{
+		Name:    "test",
+		BaseURL: server.URL,
+		APIKey:  "test-key",
+	})
+
+	expected := []string{"claude-3-opus", "claude-3-sonnet", "claude-3-haiku"}
+	assert.Equal(t, expected, listModelIDs(t, provider))
+	assert.Equal(t, 2, requests)
+
+	// Second call is served from the cache
+	assert.Equal(t, expected, listModelIDs(t, provider))
+	assert.Equal(t, 2, requests)
+}
+
+func TestAnthropicProvider_ListModelsError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(&config.Provider{
+		Name:    "test",
+		BaseURL: server.URL,
+		APIKey:  "bad-key",
+	})
+
+	models, err := provider.ListModels(t.Context())
+	assert.Empty(t, models)
+	var status *StatusError
+	require.ErrorAs(t, err, &status)
+	assert.Equal(t, http.StatusUnauthorized, status.StatusCode)
+}
+
+func TestAnthropicProvider_ListModelsCancelled(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	provider := NewAnthropicProvider(&config.Provider{Name: "test", BaseURL: server.URL, APIKey: "test-key"})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := provider.ListModels(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
 func TestAnthropicProvider_ChatCompletion(t *testing.T) {
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		assert.Equal(t, "POST", r.Method)
@@ -154,12 +239,15 @@ func TestAnthropicProvider_Completion(t *testing.T) {
 		assert.Equal(t, "Complete this sentence", msg["content"])
 
 		response := map[string]interface{}{
-			"id":   "msg_123",
-			"type": "message",
-			"role": "assistant",
+			"id":    "msg_123",
+			"type":  "message",
+			"role":  "assistant",
+			"model": "claude-3-sonnet",
 			"content": []map[string]interface{}{
 				{"type": "text", "text": "I'll complete it for you."},
 			},
+			"stop_reason": "max_tokens",
+			"usage":       map[string]interface{}{"input_tokens": 4, "output_tokens": 6},
 		}
 
 		w.Header().Set("Content-Type", "application/json")
@@ -178,5 +266,155 @@ func TestAnthropicProvider_Completion(t *testing.T) {
 
 	result, err := provider.Completion(context.Background(), "claude-3-sonnet", "Complete this sentence")
 	require.NoError(t, err)
-	require.NotNil(t, result)
+
+	// The reply is unwrapped into the legacy text completion schema
+	completion := result.(map[string]interface{})
+	assert.Equal(t, "msg_123", completion["id"])
+	assert.Equal(t, "text_completion", completion["object"])
+	assert.Equal(t, "claude-3-sonnet", completion["model"])
+	assert.Equal(t, []interface{}{map[string]interface{}{
+		"text": "I'll complete it for you.", "index": 0, "logprobs": nil, "finish_reason": "length",
+	}}, completion["choices"])
+	assert.Equal(t, map[string]interface{}{
+		"prompt_tokens": 4.0, "completion_tokens": 6.0, "total_tokens": 10.0,
+	}, completion["usage"])
+}
+
+func TestAnthropicProvider_CompletionStream(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, event := range []string{
+			`{"type":"message_start","message":{"id":"msg_1","model":"claude-3-haiku","usage":{"input_tokens":3}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Hello"}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":" world"}}`,
+			`{"type":"content_block_stop","index":0}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":2}}`,
+			`{"type":"message_stop"}`,
+		} {
+			_, _ = w.Write([]byte("data: " + event + "\n\n"))
+		}
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(&config.Provider{Name: "test", BaseURL: server.URL})
+	stream, err := provider.CompletionStream(t.Context(), "claude-3-haiku", "Say hello")
+	require.NoError(t, err)
+
+	var chunks []map[string]interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk.(map[string]interface{}))
+	}
+	require.Len(t, chunks, 3)
+
+	var text string
+	for _, chunk := range chunks {
+		assert.Equal(t, "msg_1", chunk["id"])
+		assert.Equal(t, "text_completion", chunk["object"])
+		text += chunk["choices"].([]interface{})[0].(map[string]interface{})["text"].(string)
+	}
+	assert.Equal(t, "Hello world", text)
+	assert.Equal(t, "stop", chunks[2]["choices"].([]interface{})[0].(map[string]interface{})["finish_reason"])
+	assert.Equal(t, 5.0, chunks[2]["usage"].(map[string]interface{})["total_tokens"])
+}
+
+func TestAnthropicProvider_VersionAndBetas(t *testing.T) {
+	var headers http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		headers = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"content":[]}`))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(&config.Provider{
+		Name:    "anthropic",
+		BaseURL: server.URL,
+		Anthropic: config.ProviderAnthropic{
+			Version: "2024-01-01",
+			Betas:   []string{"prompt_caching", "context-1m-2025-08-07", "context_1m", "new-feature-2026-09-01"},
+		},
+	})
+	_, err := provider.ChatCompletion(t.Context(), "claude-3-sonnet", userMessage)
+	require.NoError(t, err)
+
+	assert.Equal(t, "2024-01-01", headers.Get("anthropic-version"))
+	assert.Equal(t, "prompt-caching-2024-07-31,context-1m-2025-08-07,new-feature-2026-09-01", headers.Get("anthropic-beta"))
+
+	// Without betas the header is left out entirely
+	provider = NewAnthropicProvider(&config.Provider{Name: "anthropic", BaseURL: server.URL})
+	_, err = provider.ChatCompletion(t.Context(), "claude-3-sonnet", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, config.DefaultAnthropicVersion, headers.Get("anthropic-version"))
+	assert.NotContains(t, headers, "Anthropic-Beta")
+}
+
+func TestAnthropicProvider_Thinking(t *testing.T) {
+	var req map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"content":[]}`))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(&config.Provider{Name: "anthropic", BaseURL: server.URL})
+	thinking := map[string]interface{}{"type": "enabled", "budget_tokens": float64(2048)}
+	ctx := WithParams(t.Context(), NewParams(map[string]interface{}{"thinking": thinking}, nil))
+
+	// An earlier assistant turn keeps its thinking blocks, which must go back unchanged
+	previous := []interface{}{
+		map[string]interface{}{"type": "thinking", "thinking": "2 + 2 is 4", "signature": "sig"},
+		map[string]interface{}{"type": "text", "text": "4"},
+	}
+	messages := []map[string]interface{}{
+		{"role": "user", "content": "What is 2 + 2?"},
+		{"role": "assistant", "content": previous},
+		{"role": "user", "content": "And doubled?"},
+	}
+	_, err := provider.ChatCompletion(ctx, "claude-3-sonnet", messages)
+	require.NoError(t, err)
+
+	assert.Equal(t, thinking, req["thinking"])
+	sent := req["messages"].([]interface{})
+	require.Len(t, sent, 3)
+	assert.Equal(t, previous, sent[1].(map[string]interface{})["content"])
+}
+
+func TestAnthropicProvider_DefaultMaxTokens(t *testing.T) {
+	server, body := captureServer(t)
+	provider := NewAnthropicProvider(&config.Provider{
+		Name:    "anthropic",
+		BaseURL: server.URL,
+		ModelLimits: map[string]config.ModelLimits{
+			"claude-3-haiku":  {ContextWindow: 200000, MaxOutputTokens: 4096},
+			"claude-small":    {ContextWindow: 1000, MaxOutputTokens: 800},
+			"claude-3-sonnet": {MaxOutputTokens: 8192},
+		},
+	})
+	// 2000 characters estimate to 500 prompt tokens
+	long := []map[string]interface{}{{"role": "user", "content": strings.Repeat("x", 2000)}}
+
+	tests := []struct {
+		model    string
+		messages []map[string]interface{}
+		expected float64
+	}{
+		{"claude-3-haiku", long, 4096},
+		{"claude-small", long, 500},
+		{"claude-small", userMessage, 800},
+		{"claude-3-sonnet", long, 8192},
+		{"claude-unknown", long, defaultMaxTokens},
+	}
+	for _, tt := range tests {
+		_, err := provider.ChatCompletion(t.Context(), tt.model, tt.messages)
+		require.NoError(t, err)
+		assert.Equal(t, tt.expected, (*body)["max_tokens"], tt.model)
+	}
+
+	// A max_tokens sent by the client is kept
+	ctx, _ := paramsContext(t.Context(), map[string]interface{}{"max_tokens": 100.0}, nil)
+	_, err := provider.ChatCompletion(ctx, "claude-small", long)
+	require.NoError(t, err)
+	assert.Equal(t, 100.0, (*body)["max_tokens"])
 }
@@ -0,0 +1,191 @@
+// Package providers implements AI provider abstractions.
+// This file contains OAuth2 client-credentials token acquisition, used in place of
+// static API keys for deployments (e.g. Azure AD) that forbid long-lived secrets.
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+const (
+	// AuthTypeOAuth2 selects a generic OAuth2 client-credentials grant
+	AuthTypeOAuth2 = "oauth2"
+	// AuthTypeAzureAD selects Azure AD client-credentials with tenant-derived endpoints
+	AuthTypeAzureAD = "azure_ad"
+
+	// azureADTokenURLFormat is the v2.0 token endpoint for an Azure AD tenant
+	azureADTokenURLFormat = "https://login.microsoftonline.com/%s/oauth2/v2.0/token"
+	// azureADDefaultScope is the scope for Azure OpenAI / Cognitive Services
+	azureADDefaultScope = "https://cognitiveservices.azure.com/.default"
+	// tokenExpirySkew refreshes tokens early so in-flight requests never carry an expired one
+	tokenExpirySkew = 60 * time.Second
+	// tokenDefaultLifetime is how long a token is used when its response doesn't say when it expires
+	tokenDefaultLifetime = time.Hour
+	// tokenFetchTimeout bounds a token request, which outlives the request that started it
+	tokenFetchTimeout = 30 * time.Second
+)
+
+// tokenSource fetches and caches access tokens, refreshing them shortly before they expire.
+type tokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	client       *http.Client
+
+	mtx    sync.Mutex
+	token  string
+	expiry time.Time
+	// requests shares a token request between the callers needing a token while it runs
+	requests singleflight.Group
+}
+
+// tokenResponse is the subset of an OAuth2 token response we rely on.
+type tokenResponse struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// resolveEnv expands a "${ENV_VAR}" reference to its value, returning other strings unchanged.
+func resolveEnv(value string) string {
+	if strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}") {
+		envVar := strings.TrimSuffix(strings.TrimPrefix(value, "${"), "}")
+		return os.Getenv(envVar)
+	}
+	return value
+}
+
+// newTokenSource builds a token source from provider auth config.
+// Returns nil when the provider uses a static API key.
+func newTokenSource(auth *config.ProviderAuth, client *http.Client) *tokenSource {
+	ts := &tokenSource{
+		tokenURL:     auth.TokenURL,
+		clientID:     resolveEnv(auth.ClientID),
+		clientSecret: resolveEnv(auth.ClientSecret),
+		scopes:       auth.Scopes,
+		client:       client,
+	}
+
+	switch auth.Type {
+	case AuthTypeOAuth2:
+	case AuthTypeAzureAD:
+		if ts.tokenURL == "" {
+			ts.tokenURL = fmt.Sprintf(azureADTokenURLFormat, url.PathEscape(resolveEnv(auth.TenantID)))
+		}
+		if len(ts.scopes) == 0 {
+			ts.scopes = []string{azureADDefaultScope}
+		}
+	default:
+		return nil
+	}
+
+	return ts
+}
+
+// Token returns a valid access token, acquiring a new one if the cached token is missing or near expiry.
+// The token is requested outside the lock, and callers needing one meanwhile share the request; a
+// caller whose ctx ends stops waiting without cancelling it for the others.
+func (ts *tokenSource) Token(ctx context.Context) (string, error) {
+	if token, ok := ts.cached(); ok {
+		return token, nil
+	}
+
+	requested := ts.requests.DoChan("token", func() (interface{}, error) {
+		return ts.refresh(context.WithoutCancel(ctx))
+	})
+	var result singleflight.Result
+	select {
+	case result = <-requested:
+	case <-ctx.Done():
+		return "", ctx.Err()
+	}
+	if result.Err != nil {
+		return "", fmt.Errorf("failed to acquire access token: %w", result.Err)
+	}
+	return result.Val.(string), nil
+}
+
+// cached returns the cached token while it isn't near expiry.
+func (ts *tokenSource) cached() (string, bool) {
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	return ts.token, ts.token != "" && time.Now().Add(tokenExpirySkew).Before(ts.expiry)
+}
+
+// refresh requests a token and caches it, unless a request that just ended already did.
+func (ts *tokenSource) refresh(ctx context.Context) (string, error) {
+	if token, ok := ts.cached(); ok {
+		return token, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, tokenFetchTimeout)
+	defer cancel()
+	token, expiry, err := ts.request(ctx)
+	if err != nil {
+		return "", err
+	}
+	ts.mtx.Lock()
+	defer ts.mtx.Unlock()
+	ts.token, ts.expiry = token, expiry
+	return token, nil
+}
+
+// request asks the token endpoint for a token, returning it and when it expires.
+func (ts *tokenSource) request(ctx context.Context) (string, time.Time, error) {
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("client_id", ts.clientID)
+	form.Set("client_secret", ts.clientSecret)
+	if len(ts.scopes) > 0 {
+		form.Set("scope", strings.Join(ts.scopes, " "))
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", ts.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := ts.client.Do(req)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", time.Time{}, fmt.Errorf("token request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var tr tokenResponse
+	if err := json.Unmarshal(body, &tr); err != nil {
+		return "", time.Time{}, err
+	}
+	if tr.AccessToken == "" {
+		return "", time.Time{}, fmt.Errorf("token response did not contain an access token")
+	}
+
+	lifetime := time.Duration(tr.ExpiresIn) * time.Second
+	if lifetime <= 0 {
+		lifetime = tokenDefaultLifetime
+	}
+	return tr.AccessToken, time.Now().Add(lifetime), nil
+}
@@ -0,0 +1,177 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestNewTokenSource(t *testing.T) {
+	t.Setenv("TEST_CLIENT_SECRET", "secret-from-env")
+
+	assert.Nil(t, newTokenSource(&config.ProviderAuth{}, http.DefaultClient))
+
+	ts := newTokenSource(&config.ProviderAuth{
+		Type:         AuthTypeAzureAD,
+		TenantID:     "my-tenant",
+		ClientID:     "client",
+		ClientSecret: "${TEST_CLIENT_SECRET}",
+	}, http.DefaultClient)
+	require.NotNil(t, ts)
+	assert.Equal(t, "https://login.microsoftonline.com/my-tenant/oauth2/v2.0/token", ts.tokenURL)
+	assert.Equal(t, []string{azureADDefaultScope}, ts.scopes)
+	assert.Equal(t, "secret-from-env", ts.clientSecret)
+}
+
+func TestTokenSource_TokenCachingAndRefresh(t *testing.T) {
+	issued := 0
+	expiresIn := 3600
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issued++
+		require.NoError(t, r.ParseForm())
+		assert.Equal(t, "client_credentials", r.PostForm.Get("grant_type"))
+		assert.Equal(t, "client", r.PostForm.Get("client_id"))
+		assert.Equal(t, "secret", r.PostForm.Get("client_secret"))
+		assert.Equal(t, "api.read api.write", r.PostForm.Get("scope"))
+
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token-%d", issued),
+			"token_type":   "Bearer",
+			"expires_in":   expiresIn,
+		}); err != nil {
+			t.Errorf("Failed to encode response: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	ts := newTokenSource(&config.ProviderAuth{
+		Type:         AuthTypeOAuth2,
+		TokenURL:     server.URL,
+		ClientID:     "client",
+		ClientSecret: "secret",
+		Scopes:       []string{"api.read", "api.write"},
+	}, server.Client())
+
+	token, err := ts.Token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+
+	token, err = ts.Token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "token-1", token)
+	assert.Equal(t, 1, issued)
+
+	// A token inside the expiry skew window is refreshed
+	ts.expiry = ts.expiry.Add(-time.Hour)
+	token, err = ts.Token(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, "token-2", token)
+	assert.Equal(t, 2, issued)
+}
+
+func TestTokenSource_DefaultLifetime(t *testing.T) {
+	var issued atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		issued.Add(1)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token","token_type":"Bearer"}`))
+	}))
+	defer server.Close()
+
+	ts := newTokenSource(&config.ProviderAuth{Type: AuthTypeOAuth2, TokenURL: server.URL}, server.Client())
+	for range 3 {
+		token, err := ts.Token(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, "token", token)
+	}
+	assert.Equal(t, int32(1), issued.Load(), "a token without expires_in is reused")
+	assert.WithinDuration(t, time.Now().Add(tokenDefaultLifetime), ts.expiry, time.Minute)
+}
+
+func TestTokenSource_SharesRequests(t *testing.T) {
+	var issued atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		issued.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"token","expires_in":3600}`))
+	}))
+	defer server.Close()
+	ts := newTokenSource(&config.ProviderAuth{Type: AuthTypeOAuth2, TokenURL: server.URL}, server.Client())
+
+	ctx, cancel := context.WithCancel(t.Context())
+	cancel()
+	_, err := ts.Token(ctx)
+	assert.ErrorIs(t, err, context.Canceled, "a caller stops waiting when its request ends")
+
+	var wg sync.WaitGroup
+	for range 5 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			token, err := ts.Token(t.Context())
+			assert.NoError(t, err)
+			assert.Equal(t, "token", token)
+		}()
+	}
+	close(release)
+	wg.Wait()
+	assert.Equal(t, int32(1), issued.Load(), "the cancelled caller's request serves the others")
+}
+
+func TestTokenSource_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"error":"invalid_client"}`))
+	}))
+	defer server.Close()
+
+	ts := newTokenSource(&config.ProviderAuth{Type: AuthTypeOAuth2, TokenURL: server.URL}, server.Client())
+
+	_, err := ts.Token(t.Context())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "status 401")
+}
+
+func TestOpenAIProvider_TokenAuth(t *testing.T) {
+	tokenServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"access_token":"oauth-token","expires_in":3600}`))
+	}))
+	defer tokenServer.Close()
+
+	apiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer oauth-token", r.Header.Get("Authorization"))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1"}`))
+	}))
+	defer apiServer.Close()
+
+	provider := NewOpenAIProvider(&config.Provider{
+		Name:    "azure",
+		BaseURL: apiServer.URL,
+		Auth: config.ProviderAuth{
+			Type:     AuthTypeOAuth2,
+			TokenURL: tokenServer.URL,
+		},
+	})
+
+	result, err := provider.ChatCompletion(t.Context(), "gpt-4", []map[string]interface{}{
+		{"role": "user", "content": "Hello"},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"id": "chatcmpl-1"}, result)
+}
@@ -0,0 +1,312 @@
+// Package providers implements AI provider abstractions.
+// This file contains AWS Signature Version 4 request signing and the credentials requests are
+// signed with, found the way the AWS SDKs find them, for Bedrock.
+package providers
+
+import (
+	"bufio"
+	"cmp"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+const (
+	// awsSigningAlgorithm is the only algorithm of Signature Version 4
+	awsSigningAlgorithm = "AWS4-HMAC-SHA256"
+	// awsTimeFormat is the format of X-Amz-Date, awsDateFormat that of the credential scope
+	awsTimeFormat = "20060102T150405Z"
+	awsDateFormat = "20060102"
+	// stsURLFormat is the regional STS endpoint web identities are exchanged at
+	stsURLFormat = "https://sts.%s.amazonaws.com/"
+)
+
+// awsCredentials are AWS access keys; Expires is zero for long-lived ones.
+type awsCredentials struct {
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expires         time.Time
+}
+
+// awsCredentialSource finds the credentials to sign with: the configured keys, else those of the
+// configured profile, else the environment's, like the AWS SDKs' default chain. The temporary
+// credentials of a web identity are cached until shortly before they expire; the others are read
+// on every request, so rotated keys are picked up.
+type awsCredentialSource struct {
+	static  awsCredentials
+	profile string
+	// stsURL is where web identity tokens are exchanged for credentials
+	stsURL string
+	client *http.Client
+
+	mtx    sync.Mutex
+	cached awsCredentials
+}
+
+// newAWSCredentialSource creates the credential source of a bedrock provider.
+func newAWSCredentialSource(cfg *config.ProviderBedrock, client *http.Client) *awsCredentialSource {
+	return &awsCredentialSource{
+		static: awsCredentials{
+			AccessKeyID:     resolveEnv(cfg.AccessKeyID),
+			SecretAccessKey: resolveEnv(cfg.SecretAccessKey),
+			SessionToken:    resolveEnv(cfg.SessionToken),
+		},
+		profile: cfg.Profile,
+		stsURL:  fmt.Sprintf(stsURLFormat, cfg.Region),
+		client:  client,
+	}
+}
+
+// Credentials returns the credentials to sign the next request with.
+func (s *awsCredentialSource) Credentials(ctx context.Context) (awsCredentials, error) {
+	switch {
+	case s.static.AccessKeyID != "":
+		return s.static, nil
+	case s.profile != "":
+		return profileCredentials(s.profile)
+	case os.Getenv("AWS_ACCESS_KEY_ID") != "":
+		return awsCredentials{
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}, nil
+	case os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE") != "" && os.Getenv("AWS_ROLE_ARN") != "":
+		return s.webIdentityCredentials(ctx)
+	default:
+		return profileCredentials(cmp.Or(os.Getenv("AWS_PROFILE"), "default"))
+	}
+}
+
+// webIdentityCredentials assumes AWS_ROLE_ARN with the token in AWS_WEB_IDENTITY_TOKEN_FILE, as
+// EKS sets them up for pods of a service account with an IAM role (IRSA). The token file is read
+// on every exchange, as it is rotated.
+func (s *awsCredentialSource) webIdentityCredentials(ctx context.Context) (awsCredentials, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	if s.cached.AccessKeyID != "" && time.Now().Add(tokenExpirySkew).Before(s.cached.Expires) {
+		return s.cached, nil
+	}
+	token, err := os.ReadFile(os.Getenv("AWS_WEB_IDENTITY_TOKEN_FILE"))
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to read web identity token: %w", err)
+	}
+	creds, err := s.assumeRoleWithWebIdentity(ctx, strings.TrimSpace(string(token)))
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("failed to assume role with web identity: %w", err)
+	}
+	s.cached = creds
+	return creds, nil
+}
+
+// assumeRoleResponse is the subset of an AssumeRoleWithWebIdentity response we rely on.
+type assumeRoleResponse struct {
+	Credentials struct {
+		AccessKeyID     string    `xml:"AccessKeyId"`
+		SecretAccessKey string    `xml:"SecretAccessKey"`
+		SessionToken    string    `xml:"SessionToken"`
+		Expiration      time.Time `xml:"Expiration"`
+	} `xml:"AssumeRoleWithWebIdentityResult>Credentials"`
+}
+
+func (s *awsCredentialSource) assumeRoleWithWebIdentity(ctx context.Context, token string) (awsCredentials, error) {
+	form := url.Values{}
+	form.Set("Action", "AssumeRoleWithWebIdentity")
+	form.Set("Version", "2011-06-15")
+	form.Set("RoleArn", os.Getenv("AWS_ROLE_ARN"))
+	form.Set("RoleSessionName", cmp.Or(os.Getenv("AWS_ROLE_SESSION_NAME"), fmt.Sprintf("modelplex-%d", time.Now().Unix())))
+	form.Set("WebIdentityToken", token)
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.stsURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return awsCredentials{}, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return awsCredentials{}, fmt.Errorf("STS request failed with status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var result assumeRoleResponse
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return awsCredentials{}, err
+	}
+	if result.Credentials.AccessKeyID == "" {
+		return awsCredentials{}, errors.New("STS response did not contain credentials")
+	}
+	return awsCredentials{
+		AccessKeyID:     result.Credentials.AccessKeyID,
+		SecretAccessKey: result.Credentials.SecretAccessKey,
+		SessionToken:    result.Credentials.SessionToken,
+		Expires:         result.Credentials.Expiration,
+	}, nil
+}
+
+// profileCredentials reads the keys of profile from the shared credentials file, at
+// AWS_SHARED_CREDENTIALS_FILE or ~/.aws/credentials.
+func profileCredentials(profile string) (awsCredentials, error) {
+	path := os.Getenv("AWS_SHARED_CREDENTIALS_FILE")
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return awsCredentials{}, fmt.Errorf("no AWS credentials found: %w", err)
+		}
+		path = filepath.Join(home, ".aws", "credentials")
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return awsCredentials{}, fmt.Errorf("no AWS credentials found: %w", err)
+	}
+	defer file.Close()
+
+	var creds awsCredentials
+	section := ""
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		key, value, ok := strings.Cut(line, "=")
+		if !ok || section != profile {
+			continue
+		}
+		switch strings.TrimSpace(key) {
+		case "aws_access_key_id":
+			creds.AccessKeyID = strings.TrimSpace(value)
+		case "aws_secret_access_key":
+			creds.SecretAccessKey = strings.TrimSpace(value)
+		case "aws_session_token":
+			creds.SessionToken = strings.TrimSpace(value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return awsCredentials{}, err
+	}
+	if creds.AccessKeyID == "" || creds.SecretAccessKey == "" {
+		return awsCredentials{}, fmt.Errorf("profile %q in %s has no access keys", profile, path)
+	}
+	return creds, nil
+}
+
+// signV4 signs req, whose body is body, for service in region with creds at now. The host, the
+// content type and the X-Amz headers are signed; headers added later, such as a gateway's extra
+// headers, don't invalidate the signature, but changes to the URL do.
+func signV4(req *http.Request, body []byte, creds awsCredentials, region, service string, now time.Time) {
+	now = now.UTC()
+	req.Header.Set("X-Amz-Date", now.Format(awsTimeFormat))
+	if creds.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", creds.SessionToken)
+	}
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		lower := strings.ToLower(name)
+		if lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			headers[lower] = strings.Join(strings.Fields(strings.Join(values, ",")), " ")
+		}
+	}
+	names := slices.Sorted(func(yield func(string) bool) {
+		for name := range headers {
+			if !yield(name) {
+				return
+			}
+		}
+	})
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	payloadHash := sha256.Sum256(body)
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsURIEncode(req.URL.EscapedPath(), false),
+		awsCanonicalQuery(req.URL.Query()),
+		canonicalHeaders.String(),
+		signedHeaders,
+		hex.EncodeToString(payloadHash[:]),
+	}, "\n")
+
+	date := now.Format(awsDateFormat)
+	scope := date + "/" + region + "/" + service + "/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := awsSigningAlgorithm + "\n" + now.Format(awsTimeFormat) + "\n" + scope + "\n" +
+		hex.EncodeToString(requestHash[:])
+
+	key := []byte("AWS4" + creds.SecretAccessKey)
+	for _, part := range []string{date, region, service, "aws4_request"} {
+		key = hmacSHA256(key, part)
+	}
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		awsSigningAlgorithm, creds.AccessKeyID, scope, signedHeaders, signature))
+}
+
+// awsCanonicalQuery returns query sorted by name and value, each encoded as SigV4 requires.
+func awsCanonicalQuery(query url.Values) string {
+	var pairs []string
+	for name, values := range query {
+		for _, value := range values {
+			pairs = append(pairs, awsURIEncode(name, true)+"="+awsURIEncode(value, true))
+		}
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// awsURIEncode percent-encodes every byte of s but the unreserved characters, and slashes unless
+// encodeSlash is set. Paths are encoded once more on top of their escaping in the URL, as every
+// service but S3 expects.
+func awsURIEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		switch {
+		case 'A' <= c && c <= 'Z', 'a' <= c && c <= 'z', '0' <= c && c <= '9', c == '-', c == '_', c == '.', c == '~':
+			b.WriteByte(c)
+		case c == '/' && !encodeSlash:
+			b.WriteByte(c)
+		default:
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,136 @@
+package providers
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestSignV4(t *testing.T) {
+	// Vectors of the AWS Signature Version 4 test suite
+	creds := awsCredentials{AccessKeyID: "AKIDEXAMPLE", SecretAccessKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY"}
+	at := time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		url       string
+		signature string
+	}{
+		{"get-vanilla", "https://example.amazonaws.com/",
+			"5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"},
+		{"get-vanilla-query-order-key-case", "https://example.amazonaws.com/?Param2=value2&Param1=value1",
+			"b97d918cfa904a5beff61c982a1b6f458b799221646efd99d3219ec94cdf2500"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, err := http.NewRequest("GET", tt.url, http.NoBody)
+			require.NoError(t, err)
+			signV4(req, nil, creds, "us-east-1", "service", at)
+			assert.Equal(t, "20150830T123600Z", req.Header.Get("X-Amz-Date"))
+			assert.Equal(t, "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, "+
+				"SignedHeaders=host;x-amz-date, Signature="+tt.signature, req.Header.Get("Authorization"))
+		})
+	}
+
+	req, err := http.NewRequest("POST", "https://example.amazonaws.com/", http.NoBody)
+	require.NoError(t, err)
+	creds.SessionToken = "session"
+	signV4(req, nil, creds, "us-east-1", "service", at)
+	assert.Equal(t, "session", req.Header.Get("X-Amz-Security-Token"))
+	assert.Contains(t, req.Header.Get("Authorization"), "SignedHeaders=host;x-amz-date;x-amz-security-token,")
+}
+
+func TestAWSURIEncode(t *testing.T) {
+	assert.Equal(t, "anthropic.claude-3-5-sonnet-20240620-v1%3A0",
+		awsURIEncode("anthropic.claude-3-5-sonnet-20240620-v1:0", true))
+	assert.Equal(t, "/model/a%253Ab/converse", awsURIEncode("/model/a%3Ab/converse", false),
+		"paths are encoded on top of their escaping")
+}
+
+func TestAWSCredentialSource(t *testing.T) {
+	dir := t.TempDir()
+	credentialsFile := filepath.Join(dir, "credentials")
+	require.NoError(t, os.WriteFile(credentialsFile, []byte(`[default]
+aws_access_key_id = AKIDDEFAULT
+aws_secret_access_key = default-secret
+
+# Temporary keys
+[work]
+aws_access_key_id=AKIDWORK
+aws_secret_access_key=work-secret
+aws_session_token=work-session
+`), 0o600))
+	t.Setenv("AWS_SHARED_CREDENTIALS_FILE", credentialsFile)
+	for _, name := range []string{"AWS_ACCESS_KEY_ID", "AWS_SECRET_ACCESS_KEY", "AWS_SESSION_TOKEN", "AWS_PROFILE",
+		"AWS_WEB_IDENTITY_TOKEN_FILE", "AWS_ROLE_ARN"} {
+		t.Setenv(name, "")
+	}
+
+	credentials := func(cfg config.ProviderBedrock) awsCredentials {
+		t.Helper()
+		creds, err := newAWSCredentialSource(&cfg, http.DefaultClient).Credentials(t.Context())
+		require.NoError(t, err)
+		return creds
+	}
+
+	assert.Equal(t, "AKIDDEFAULT", credentials(config.ProviderBedrock{}).AccessKeyID)
+	assert.Equal(t, awsCredentials{AccessKeyID: "AKIDWORK", SecretAccessKey: "work-secret", SessionToken: "work-session"},
+		credentials(config.ProviderBedrock{Profile: "work"}))
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDENV")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "env-secret")
+	assert.Equal(t, "AKIDENV", credentials(config.ProviderBedrock{}).AccessKeyID)
+	assert.Equal(t, "AKIDWORK", credentials(config.ProviderBedrock{Profile: "work"}).AccessKeyID,
+		"a configured profile comes before the environment")
+	assert.Equal(t, "AKIDSTATIC", credentials(config.ProviderBedrock{
+		AccessKeyID: "AKIDSTATIC", SecretAccessKey: "static-secret", Profile: "work",
+	}).AccessKeyID)
+
+	_, err := newAWSCredentialSource(&config.ProviderBedrock{Profile: "missing"}, nil).Credentials(t.Context())
+	assert.ErrorContains(t, err, `profile "missing"`)
+}
+
+func TestAWSCredentialSource_WebIdentity(t *testing.T) {
+	exchanges := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		exchanges++
+		assert.NoError(t, r.ParseForm())
+		assert.Equal(t, "AssumeRoleWithWebIdentity", r.PostForm.Get("Action"))
+		assert.Equal(t, "arn:aws:iam::123456789012:role/modelplex", r.PostForm.Get("RoleArn"))
+		assert.Equal(t, "service-account-token", r.PostForm.Get("WebIdentityToken"))
+		_, _ = fmt.Fprintf(w, `<AssumeRoleWithWebIdentityResponse xmlns="https://sts.amazonaws.com/doc/2011-06-15/">
+  <AssumeRoleWithWebIdentityResult>
+    <Credentials>
+      <AccessKeyId>ASIATEMP</AccessKeyId>
+      <SecretAccessKey>temp-secret</SecretAccessKey>
+      <SessionToken>temp-session</SessionToken>
+      <Expiration>%s</Expiration>
+    </Credentials>
+  </AssumeRoleWithWebIdentityResult>
+</AssumeRoleWithWebIdentityResponse>`, time.Now().Add(time.Hour).UTC().Format(time.RFC3339))
+	}))
+	t.Cleanup(server.Close)
+
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	require.NoError(t, os.WriteFile(tokenFile, []byte("service-account-token\n"), 0o600))
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_WEB_IDENTITY_TOKEN_FILE", tokenFile)
+	t.Setenv("AWS_ROLE_ARN", "arn:aws:iam::123456789012:role/modelplex")
+
+	source := newAWSCredentialSource(&config.ProviderBedrock{Region: "us-east-1"}, server.Client())
+	source.stsURL = server.URL
+	for range 2 {
+		creds, err := source.Credentials(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, "ASIATEMP", creds.AccessKeyID)
+		assert.Equal(t, "temp-session", creds.SessionToken)
+	}
+	assert.Equal(t, 1, exchanges, "credentials are cached until they expire")
+}
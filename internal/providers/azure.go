@@ -0,0 +1,34 @@
+// Package providers implements AI provider abstractions.
+// AzureOpenAIProvider serves models deployed on Azure OpenAI, which speaks OpenAI's API with these
+// differences:
+// - Requests go to the deployment's URL, /openai/deployments/{deployment}/chat/completions, with an
+// api-version query parameter; model_map maps model names to deployment names, so clients can keep
+// asking for e.g. gpt-4o
+// - The static key is sent in the api-key header; Azure AD tokens (auth.type = "azure_ad") are
+// sent as bearer tokens
+// - The base URL is the resource's endpoint, e.g. https://my-resource.openai.azure.com
+package providers
+
+import (
+	"net/url"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// AzureOpenAIProvider implements the Provider interface for Azure OpenAI.
+type AzureOpenAIProvider struct {
+	*OpenAIProvider
+}
+
+// NewAzureOpenAIProvider creates a new Azure OpenAI provider instance.
+func NewAzureOpenAIProvider(cfg *config.Provider) *AzureOpenAIProvider {
+	provider := NewOpenAIProvider(cfg)
+	provider.baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	provider.keyHeader = "api-key"
+	query := url.Values{"api-version": {cfg.Azure.APIVersion}}.Encode()
+	provider.path = func(deployment, endpoint string) string {
+		return "/openai/deployments/" + url.PathEscape(deployment) + endpoint + "?" + query
+	}
+	return &AzureOpenAIProvider{OpenAIProvider: provider}
+}
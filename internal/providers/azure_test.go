@@ -0,0 +1,65 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestAzureOpenAIProvider_Deployments(t *testing.T) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"model\":\"gpt-4o-2024-08-06\",\"choices\":[{\"delta\":{\"content\":\"Hi\"}}]}\n\n"+
+				"data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"model":"gpt-4o-2024-08-06","choices":[{"message":{"content":"Hi"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Provider{
+		Name: "azure", Type: "azure-openai", BaseURL: server.URL + "/", APIKey: "azure-key",
+		Models: []string{"gpt-4o"}, ModelMap: map[string]string{"gpt-4o": "prod-4o"},
+		Azure: config.ProviderAzure{APIVersion: "2024-10-21"},
+	}
+	provider := NewProvider(cfg)
+
+	result, err := provider.ChatCompletion(t.Context(), "gpt-4o", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o", result.(map[string]interface{})["model"], "clients see the model they asked for")
+	require.Len(t, requests, 1)
+	assert.Equal(t, "/openai/deployments/prod-4o/chat/completions", requests[0].URL.Path)
+	assert.Equal(t, "2024-10-21", requests[0].URL.Query().Get("api-version"))
+	assert.Equal(t, "azure-key", requests[0].Header.Get("api-key"))
+	assert.Empty(t, requests[0].Header.Get("Authorization"))
+
+	stream, err := provider.ChatCompletionStream(t.Context(), "gpt-4o", userMessage)
+	require.NoError(t, err)
+	var chunks []interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/openai/deployments/prod-4o/chat/completions", requests[1].URL.Path)
+	assert.Equal(t, "2024-10-21", requests[1].URL.Query().Get("api-version"))
+	require.Len(t, chunks, 1)
+	assert.Equal(t, "gpt-4o", chunks[0].(map[string]interface{})["model"])
+
+	_, err = provider.Completion(t.Context(), "davinci", "Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "/openai/deployments/davinci/completions", requests[2].URL.Path,
+		"models without a model_map entry are deployed under their own name")
+}
@@ -0,0 +1,388 @@
+// Package providers implements AI provider abstractions.
+// BedrockProvider serves models on AWS Bedrock through its Converse API, which differs from OpenAI:
+// - Requests are signed with AWS Signature Version 4 instead of carrying an API key, with static
+// keys, a shared credentials profile, the environment's keys or a web identity (IRSA)
+// - The model is part of the URL, /model/{model}/converse, and model ids such as
+// anthropic.claude-3-5-sonnet-20240620-v1:0 or inference profile ARNs are escaped into it
+// - Transforms OpenAI messages with pkg/convert: system messages become the "system" blocks, tool
+// calls toolUse and toolResult blocks; sampling parameters go into "inferenceConfig"
+// - Streams are AWS event streams, binary frames each carrying one JSON event, from /converse-stream
+// - Has no completions endpoint: prompts are sent as a user message, replies unwrapped into the text
+// completion schema
+// Replies are converted to OpenAI's schema, so the gateway serves them like an OpenAI provider's.
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/pkg/convert"
+)
+
+const (
+	// bedrockService is the service name requests are signed for
+	bedrockService = "bedrock"
+	// bedrockRequestIDHeader carries the request id, which responses get their id from
+	bedrockRequestIDHeader = "X-Amzn-Requestid"
+	// maxEventStreamMessage bounds an event stream frame, as AWS does
+	maxEventStreamMessage = 16 << 20
+)
+
+// bedrockParams translates OpenAI request parameters to the Converse API.
+// logit_bias, penalties, seed, n, user and logprobs have no Converse equivalent.
+var bedrockParams = &paramRules{rules: map[string]paramRule{
+	"temperature":           {set: inferenceConfig("temperature")},
+	"top_p":                 {set: inferenceConfig("topP")},
+	"max_tokens":            {set: inferenceConfig("maxTokens")},
+	"max_completion_tokens": {set: inferenceConfig("maxTokens")},
+	"stop": stopRule(stopSpec{set: func(payload map[string]interface{}, sequences []string) {
+		_ = inferenceConfig("stopSequences")(payload, sequences)
+	}}),
+	"tools": {set: func(payload map[string]interface{}, value interface{}) error {
+		tools, ok := value.([]interface{})
+		if !ok {
+			return errors.New("must be an array of tools")
+		}
+		if len(tools) > 0 {
+			toolConfig(payload)["tools"] = convert.OpenAIToConverseTools(tools)
+		}
+		return nil
+	}},
+	"tool_choice": {set: func(payload map[string]interface{}, value interface{}) error {
+		choice, err := convert.OpenAIToConverseToolChoice(value)
+		if err != nil {
+			return err
+		}
+		toolConfig(payload)["toolChoice"] = choice
+		return nil
+	}},
+	// There is no JSON mode, but asking for JSON in the system prompt gets close
+	"response_format": {emulate: func(payload map[string]interface{}, value interface{}) {
+		if instruction := jsonModeInstruction(value); instruction != "" {
+			system, _ := payload["system"].([]interface{})
+			payload["system"] = append(system, map[string]interface{}{"text": instruction})
+		}
+	}},
+}}
+
+// inferenceConfig returns a setter that copies a parameter into the payload's inferenceConfig.
+func inferenceConfig(name string) func(map[string]interface{}, interface{}) error {
+	return func(payload map[string]interface{}, value interface{}) error {
+		cfg, ok := payload["inferenceConfig"].(map[string]interface{})
+		if !ok {
+			cfg = make(map[string]interface{})
+			payload["inferenceConfig"] = cfg
+		}
+		cfg[name] = value
+		return nil
+	}
+}
+
+// toolConfig returns the payload's toolConfig, adding it when missing.
+func toolConfig(payload map[string]interface{}) map[string]interface{} {
+	cfg, ok := payload["toolConfig"].(map[string]interface{})
+	if !ok {
+		cfg = make(map[string]interface{})
+		payload["toolConfig"] = cfg
+	}
+	return cfg
+}
+
+// BedrockProvider implements the Provider interface for AWS Bedrock.
+type BedrockProvider struct {
+	name        string
+	baseURL     string
+	region      string
+	models      []string
+	priority    int
+	client      *http.Client
+	credentials *awsCredentialSource
+}
+
+// NewBedrockProvider creates a new Bedrock provider instance.
+func NewBedrockProvider(cfg *config.Provider) *BedrockProvider {
+	return &BedrockProvider{
+		name:     cfg.Name,
+		baseURL:  strings.TrimSuffix(cfg.BaseURL, "/"),
+		region:   cfg.Bedrock.Region,
+		models:   cfg.Models,
+		priority: cfg.Priority,
+		client:   newHTTPClient(cfg),
+		// STS is not the gateway, so it doesn't get the extras
+		credentials: newAWSCredentialSource(&cfg.Bedrock, &http.Client{}),
+	}
+}
+
+// Name returns the provider name.
+func (p *BedrockProvider) Name() string {
+	return p.name
+}
+
+// Priority returns the provider priority for model routing.
+func (p *BedrockProvider) Priority() int {
+	return p.priority
+}
+
+// ListModels returns the configured models; Bedrock's catalog lists models the account may not
+// have access to, so it isn't fetched.
+func (p *BedrockProvider) ListModels(_ context.Context) ([]Model, error) {
+	return modelsOf(p.models), nil
+}
+
+// ChatCompletion performs a chat completion request through the Converse API.
+func (p *BedrockProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	payload, err := p.payload(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.do(ctx, model, "/converse", payload)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var result map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+
+	response := convert.ConverseToOpenAIResponse(result)
+	response["id"] = bedrockResponseID(resp)
+	response["model"] = model
+	response["created"] = time.Now().Unix()
+	return response, nil
+}
+
+// Completion performs a completion request by sending the prompt as a user message, and returns
+// the reply in the legacy text completion schema.
+func (p *BedrockProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	return completionThroughChat(ctx, p.ChatCompletion, model, prompt)
+}
+
+// ChatCompletionStream performs a streaming chat completion request through the Converse API.
+// An exception in the stream ends it with an error chunk.
+func (p *BedrockProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	payload, err := p.payload(ctx, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.do(ctx, model, "/converse-stream", payload)
+	if err != nil {
+		return nil, err
+	}
+
+	id, created := bedrockResponseID(resp), time.Now().Unix()
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		defer func() { _ = resp.Body.Close() }()
+
+		events := newEventStreamReader(resp.Body)
+		converse := &convert.ConverseStream{}
+		for {
+			var chunk map[string]interface{}
+			headers, data, err := events.next()
+			switch {
+			case errors.Is(err, io.EOF):
+				return
+			case err != nil:
+				chunk = bedrockErrorChunk("stream_error", err.Error())
+			case headers[":message-type"] == "exception":
+				var exception struct {
+					Message string `json:"message"`
+				}
+				_ = json.Unmarshal(data, &exception)
+				chunk = bedrockErrorChunk(headers[":exception-type"], exception.Message)
+			case headers[":message-type"] == "error":
+				chunk = bedrockErrorChunk(headers[":error-code"], headers[":error-message"])
+			default:
+				var event map[string]interface{}
+				if err := json.Unmarshal(data, &event); err != nil {
+					continue // Skip malformed events
+				}
+				if chunk = converse.Chunk(headers[":event-type"], event); chunk == nil {
+					continue
+				}
+				chunk["id"], chunk["model"], chunk["created"] = id, model, created
+			}
+
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+			if _, failed := chunk["error"]; failed {
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// CompletionStream performs a streaming completion request, streaming the reply as legacy text
+// completion chunks.
+func (p *BedrockProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	return completionStreamThroughChat(ctx, p.ChatCompletionStream, model, prompt)
+}
+
+// payload builds the Converse request for messages and the request's parameters.
+func (p *BedrockProvider) payload(
+	ctx context.Context, messages []map[string]interface{},
+) (map[string]interface{}, error) {
+	system, converseMessages := convert.OpenAIToConverseMessages(messages)
+	payload := map[string]interface{}{
+		"messages": converseMessages,
+	}
+	if len(system) > 0 {
+		payload["system"] = system
+	}
+	if err := applyParams(ctx, p.name, payload, bedrockParams); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+// do signs and sends payload to the endpoint of model, returning the response when it succeeded.
+func (p *BedrockProvider) do(ctx context.Context, model, endpoint string, payload interface{}) (*http.Response, error) {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	url := p.baseURL + "/model/" + awsURIEncode(model, true) + endpoint
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	creds, err := p.credentials.Credentials(ctx)
+	if err != nil {
+		return nil, err
+	}
+	signV4(req, jsonData, creds, p.region, bedrockService, time.Now())
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		_ = resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return resp, nil
+}
+
+// bedrockResponseID returns the id of the chat completion in resp: its request id, which AWS
+// support can look up, or a random one.
+func bedrockResponseID(resp *http.Response) string {
+	if id := resp.Header.Get(bedrockRequestIDHeader); id != "" {
+		return "chatcmpl-" + id
+	}
+	return newChatCompletionID()
+}
+
+func bedrockErrorChunk(kind, message string) map[string]interface{} {
+	return map[string]interface{}{"error": map[string]interface{}{"type": kind, "message": message}}
+}
+
+// eventStreamReader reads the messages of an AWS event stream: a prelude with the message and
+// header lengths and its checksum, the headers, the payload and the message's checksum.
+type eventStreamReader struct {
+	r *bufio.Reader
+}
+
+func newEventStreamReader(r io.Reader) *eventStreamReader {
+	return &eventStreamReader{r: bufio.NewReader(r)}
+}
+
+// next returns the string headers and the payload of the next message, or io.EOF at the end.
+func (e *eventStreamReader) next() (map[string]string, []byte, error) {
+	prelude := make([]byte, 12)
+	if _, err := io.ReadFull(e.r, prelude); err != nil {
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			return nil, nil, errors.New("event stream truncated")
+		}
+		return nil, nil, err
+	}
+	total := binary.BigEndian.Uint32(prelude[0:4])
+	headersLength := binary.BigEndian.Uint32(prelude[4:8])
+	if crc32.ChecksumIEEE(prelude[:8]) != binary.BigEndian.Uint32(prelude[8:12]) {
+		return nil, nil, errors.New("event stream prelude checksum mismatch")
+	}
+	if total > maxEventStreamMessage || uint64(headersLength)+16 > uint64(total) {
+		return nil, nil, fmt.Errorf("invalid event stream message length %d", total)
+	}
+
+	message := make([]byte, total)
+	copy(message, prelude)
+	if _, err := io.ReadFull(e.r, message[12:]); err != nil {
+		return nil, nil, errors.New("event stream truncated")
+	}
+	if crc32.ChecksumIEEE(message[:total-4]) != binary.BigEndian.Uint32(message[total-4:]) {
+		return nil, nil, errors.New("event stream message checksum mismatch")
+	}
+
+	headers, err := eventStreamHeaders(message[12 : 12+headersLength])
+	if err != nil {
+		return nil, nil, err
+	}
+	return headers, message[12+headersLength : total-4], nil
+}
+
+// eventStreamValueLengths are the lengths of the fixed size header value types, by type; -1 marks
+// the types prefixed by their length
+var eventStreamValueLengths = map[byte]int{0: 0, 1: 0, 2: 1, 3: 2, 4: 4, 5: 8, 6: -1, 7: -1, 8: 8, 9: 16}
+
+// eventStreamHeaders decodes message headers, keeping those of the string type, which are the
+// ones Bedrock sends.
+func eventStreamHeaders(data []byte) (map[string]string, error) {
+	headers := make(map[string]string)
+	for len(data) > 0 {
+		nameLength := int(data[0])
+		if len(data) < 1+nameLength+1 {
+			return nil, errors.New("malformed event stream header")
+		}
+		name := string(data[1 : 1+nameLength])
+		kind := data[1+nameLength]
+		data = data[2+nameLength:]
+
+		length, known := eventStreamValueLengths[kind]
+		if !known {
+			return nil, fmt.Errorf("unknown event stream header type %d", kind)
+		}
+		if length < 0 {
+			if len(data) < 2 {
+				return nil, errors.New("malformed event stream header")
+			}
+			length = int(binary.BigEndian.Uint16(data))
+			data = data[2:]
+		}
+		if len(data) < length {
+			return nil, errors.New("malformed event stream header")
+		}
+		if kind == 7 {
+			headers[name] = string(data[:length])
+		}
+		data = data[length:]
+	}
+	return headers, nil
+}
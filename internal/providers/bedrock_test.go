@@ -0,0 +1,201 @@
+package providers
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"hash/crc32"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// eventStreamMessage encodes an event stream message with string headers.
+func eventStreamMessage(headers map[string]string, payload string) []byte {
+	var h bytes.Buffer
+	for name, value := range headers {
+		h.WriteByte(byte(len(name)))
+		h.WriteString(name)
+		h.WriteByte(7)
+		_ = binary.Write(&h, binary.BigEndian, uint16(len(value)))
+		h.WriteString(value)
+	}
+	var m bytes.Buffer
+	_ = binary.Write(&m, binary.BigEndian, uint32(16+h.Len()+len(payload)))
+	_ = binary.Write(&m, binary.BigEndian, uint32(h.Len()))
+	_ = binary.Write(&m, binary.BigEndian, crc32.ChecksumIEEE(m.Bytes()))
+	m.Write(h.Bytes())
+	m.WriteString(payload)
+	_ = binary.Write(&m, binary.BigEndian, crc32.ChecksumIEEE(m.Bytes()))
+	return m.Bytes()
+}
+
+func converseEvent(eventType, payload string) []byte {
+	return eventStreamMessage(map[string]string{
+		":message-type": "event", ":event-type": eventType, ":content-type": "application/json",
+	}, payload)
+}
+
+func newBedrockTestServer(t *testing.T, handler func(http.ResponseWriter, *http.Request, map[string]interface{})) (
+	*httptest.Server, *[]*http.Request,
+) {
+	var requests []*http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		handler(w, r, body)
+	}))
+	t.Cleanup(server.Close)
+	return server, &requests
+}
+
+func TestBedrockProvider_ChatCompletion(t *testing.T) {
+	var payload map[string]interface{}
+	server, requests := newBedrockTestServer(t, func(w http.ResponseWriter, _ *http.Request, body map[string]interface{}) {
+		payload = body
+		w.Header().Set("X-Amzn-Requestid", "b0d4")
+		_, _ = w.Write([]byte(`{"output":{"message":{"role":"assistant","content":[{"text":"Hi there"}]}},` +
+			`"stopReason":"end_turn","usage":{"inputTokens":12,"outputTokens":3,"totalTokens":15}}`))
+	})
+	provider := NewProvider(&config.Provider{
+		Name: "bedrock", Type: "bedrock", BaseURL: server.URL, Models: []string{"anthropic.claude-3-haiku"},
+		Bedrock: config.ProviderBedrock{Region: "eu-west-1", AccessKeyID: "AKIDTEST", SecretAccessKey: "secret"},
+	})
+
+	ctx := WithParams(t.Context(), NewParams(map[string]interface{}{"temperature": 0.2, "max_tokens": 100}, nil))
+	result, err := provider.ChatCompletion(ctx, "anthropic.claude-3-haiku-20240307-v1:0", []map[string]interface{}{
+		{"role": "system", "content": "Be brief."},
+		{"role": "user", "content": "Hello"},
+	})
+	require.NoError(t, err)
+
+	require.Len(t, *requests, 1)
+	req := (*requests)[0]
+	assert.Equal(t, "/model/anthropic.claude-3-haiku-20240307-v1%3A0/converse", req.URL.EscapedPath())
+	assert.True(t, strings.HasPrefix(req.Header.Get("Authorization"),
+		"AWS4-HMAC-SHA256 Credential=AKIDTEST/"), req.Header.Get("Authorization"))
+	assert.Contains(t, req.Header.Get("Authorization"), "/eu-west-1/bedrock/aws4_request")
+	assert.Equal(t, []interface{}{map[string]interface{}{"text": "Be brief."}}, payload["system"])
+	assert.Equal(t, map[string]interface{}{"temperature": 0.2, "maxTokens": float64(100)}, payload["inferenceConfig"])
+
+	response := result.(map[string]interface{})
+	assert.Equal(t, "chatcmpl-b0d4", response["id"])
+	assert.Equal(t, "anthropic.claude-3-haiku-20240307-v1:0", response["model"])
+	choice := response["choices"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Hi there", choice["message"].(map[string]interface{})["content"])
+	assert.Equal(t, "stop", choice["finish_reason"])
+
+	completion, err := provider.Completion(t.Context(), "anthropic.claude-3-haiku", "Hello")
+	require.NoError(t, err)
+	c := completion.(map[string]interface{})
+	assert.Equal(t, "text_completion", c["object"])
+	assert.Equal(t, "cmpl-b0d4", c["id"])
+	assert.Equal(t, "Hi there", c["choices"].([]interface{})[0].(map[string]interface{})["text"])
+}
+
+func TestBedrockProvider_ChatCompletionStream(t *testing.T) {
+	server, requests := newBedrockTestServer(t, func(w http.ResponseWriter, _ *http.Request, _ map[string]interface{}) {
+		w.Header().Set("Content-Type", "application/vnd.amazon.eventstream")
+		for _, message := range [][]byte{
+			converseEvent("messageStart", `{"role":"assistant"}`),
+			converseEvent("contentBlockDelta", `{"contentBlockIndex":0,"delta":{"text":"Hi"}}`),
+			converseEvent("contentBlockStop", `{"contentBlockIndex":0}`),
+			converseEvent("messageStop", `{"stopReason":"max_tokens"}`),
+			converseEvent("metadata", `{"usage":{"inputTokens":5,"outputTokens":1}}`),
+		} {
+			_, _ = w.Write(message)
+		}
+	})
+	provider := NewProvider(&config.Provider{
+		Name: "bedrock", Type: "bedrock", BaseURL: server.URL,
+		Bedrock: config.ProviderBedrock{Region: "us-east-1", AccessKeyID: "AKIDTEST", SecretAccessKey: "secret"},
+	})
+
+	stream, err := provider.ChatCompletionStream(t.Context(), "amazon.nova-lite-v1:0", userMessage)
+	require.NoError(t, err)
+	var chunks []map[string]interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk.(map[string]interface{}))
+	}
+	assert.Equal(t, "/model/amazon.nova-lite-v1%3A0/converse-stream", (*requests)[0].URL.EscapedPath())
+	require.Len(t, chunks, 4)
+	assert.Equal(t, "amazon.nova-lite-v1:0", chunks[1]["model"])
+	assert.Equal(t, map[string]interface{}{"content": "Hi"},
+		chunks[1]["choices"].([]interface{})[0].(map[string]interface{})["delta"])
+	assert.Equal(t, "length", chunks[2]["choices"].([]interface{})[0].(map[string]interface{})["finish_reason"])
+	assert.Equal(t, float64(6), chunks[3]["usage"].(map[string]interface{})["total_tokens"])
+
+	completions, err := provider.CompletionStream(t.Context(), "amazon.nova-lite-v1:0", "Hello")
+	require.NoError(t, err)
+	var texts []interface{}
+	for chunk := range completions {
+		for _, choice := range chunk.(map[string]interface{})["choices"].([]interface{}) {
+			texts = append(texts, choice.(map[string]interface{})["text"])
+		}
+	}
+	assert.Equal(t, []interface{}{"Hi", ""}, texts)
+}
+
+func TestBedrockProvider_StreamException(t *testing.T) {
+	server, _ := newBedrockTestServer(t, func(w http.ResponseWriter, _ *http.Request, _ map[string]interface{}) {
+		_, _ = w.Write(converseEvent("messageStart", `{"role":"assistant"}`))
+		_, _ = w.Write(eventStreamMessage(map[string]string{
+			":message-type": "exception", ":exception-type": "throttlingException",
+		}, `{"message":"Too many requests"}`))
+		_, _ = w.Write(converseEvent("messageStop", `{"stopReason":"end_turn"}`))
+	})
+	provider := NewProvider(&config.Provider{
+		Name: "bedrock", Type: "bedrock", BaseURL: server.URL,
+		Bedrock: config.ProviderBedrock{Region: "us-east-1", AccessKeyID: "AKIDTEST", SecretAccessKey: "secret"},
+	})
+
+	stream, err := provider.ChatCompletionStream(t.Context(), "amazon.nova-lite-v1:0", userMessage)
+	require.NoError(t, err)
+	var chunks []map[string]interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk.(map[string]interface{}))
+	}
+	require.Len(t, chunks, 2, "the exception ends the stream")
+	assert.Equal(t, map[string]interface{}{"type": "throttlingException", "message": "Too many requests"},
+		chunks[1]["error"])
+}
+
+func TestEventStreamReader_Corrupt(t *testing.T) {
+	message := converseEvent("messageStart", `{"role":"assistant"}`)
+	message[len(message)-6] ^= 0xff
+	_, _, err := newEventStreamReader(bytes.NewReader(message)).next()
+	assert.ErrorContains(t, err, "checksum mismatch")
+
+	_, _, err = newEventStreamReader(bytes.NewReader(message[:20])).next()
+	assert.ErrorContains(t, err, "truncated")
+}
+
+func TestBedrockParams(t *testing.T) {
+	payload := map[string]interface{}{}
+	ctx := WithParams(t.Context(), NewParams(map[string]interface{}{
+		"stop":        "END",
+		"tool_choice": "required",
+		"tools": []interface{}{map[string]interface{}{"type": "function", "function": map[string]interface{}{
+			"name": "get_weather", "parameters": map[string]interface{}{"type": "object"},
+		}}},
+	}, nil))
+	require.NoError(t, applyParams(ctx, "bedrock", payload, bedrockParams))
+	assert.Equal(t, map[string]interface{}{"stopSequences": []string{"END"}}, payload["inferenceConfig"])
+	assert.Equal(t, map[string]interface{}{
+		"toolChoice": map[string]interface{}{"any": map[string]interface{}{}},
+		"tools": []interface{}{map[string]interface{}{"toolSpec": map[string]interface{}{
+			"name": "get_weather", "inputSchema": map[string]interface{}{"json": map[string]interface{}{"type": "object"}},
+		}}},
+	}, payload["toolConfig"])
+
+	ctx = WithParams(t.Context(), NewParams(map[string]interface{}{"tool_choice": "none"}, nil))
+	var invalid *InvalidParamError
+	assert.ErrorAs(t, applyParams(ctx, "bedrock", map[string]interface{}{}, bedrockParams), &invalid)
+}
@@ -0,0 +1,108 @@
+// Package providers implements AI provider abstractions.
+// This file serves text completions through chat APIs, for providers without a completions endpoint:
+// the prompt is sent as a user message and the reply returned in the legacy text completion schema.
+package providers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// chatFunc performs a chat completion request.
+type chatFunc func(ctx context.Context, model string, messages []map[string]interface{}) (interface{}, error)
+
+// chatStreamFunc performs a streaming chat completion request.
+type chatStreamFunc func(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error)
+
+// completionThroughChat completes prompt with chat.
+func completionThroughChat(ctx context.Context, chat chatFunc, model, prompt string) (interface{}, error) {
+	result, err := chat(ctx, model, promptMessages(prompt))
+	if err != nil {
+		return nil, err
+	}
+	response, _ := result.(map[string]interface{})
+	return chatTextCompletion(response), nil
+}
+
+// completionStreamThroughChat streams the completion of prompt with chat, as text completion chunks.
+func completionStreamThroughChat(
+	ctx context.Context, chat chatStreamFunc, model, prompt string,
+) (<-chan interface{}, error) {
+	chunks, err := chat(ctx, model, promptMessages(prompt))
+	if err != nil {
+		return nil, err
+	}
+	return textCompletionChunks(ctx, chunks), nil
+}
+
+// textCompletionChunks converts chat completion chunks into text completion chunks, passing error
+// chunks on as they are.
+func textCompletionChunks(ctx context.Context, chunks <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for chunk := range chunks {
+			c, _ := chunk.(map[string]interface{})
+			if _, failed := c["error"]; !failed {
+				if c = chatTextCompletion(c); c == nil {
+					continue
+				}
+			}
+			select {
+			case out <- c:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+func promptMessages(prompt string) []map[string]interface{} {
+	return []map[string]interface{}{
+		{"role": "user", "content": prompt},
+	}
+}
+
+// chatTextCompletion converts a chat completion, or a chunk of one, into the legacy text
+// completion schema. Chunks without text or a finish reason, such as tool call deltas, become nil.
+func chatTextCompletion(response map[string]interface{}) map[string]interface{} {
+	choices, _ := response["choices"].([]interface{})
+	out := make([]interface{}, 0, len(choices))
+	for _, choice := range choices {
+		c, _ := choice.(map[string]interface{})
+		message, ok := c["message"].(map[string]interface{})
+		if !ok {
+			message, _ = c["delta"].(map[string]interface{})
+		}
+		text, _ := message["content"].(string)
+		if text == "" && c["finish_reason"] == nil {
+			continue
+		}
+		out = append(out, map[string]interface{}{
+			"text":          text,
+			"index":         c["index"],
+			"logprobs":      nil,
+			"finish_reason": c["finish_reason"],
+		})
+	}
+	_, hasUsage := response["usage"]
+	if len(out) == 0 && !hasUsage {
+		return nil
+	}
+
+	completion := map[string]interface{}{
+		"id":      strings.Replace(fmt.Sprint(response["id"]), "chatcmpl-", "cmpl-", 1),
+		"object":  "text_completion",
+		"created": response["created"],
+		"model":   response["model"],
+		"choices": out,
+	}
+	if hasUsage {
+		completion["usage"] = response["usage"]
+	}
+	return completion
+}
@@ -0,0 +1,216 @@
+// Package providers implements AI provider abstractions.
+// CohereProvider serves Cohere's v2 API, which reranks documents, e.g. with rerank-v3.5, and chats,
+// e.g. with command-a-03-2025:
+// - The base URL is the API's, e.g. https://api.cohere.com/v2
+// - Authentication is a bearer API key
+// - Models named rerank-* serve the rerank route; the others serve chat through /chat, whose
+// messages pkg/convert translates: system messages become a leading system message, v2's preamble
+// - Streams are SSE events of their own, content-delta, tool-call-delta and message-end among
+// them, translated into OpenAI chunks
+// - Has no completions endpoint: prompts are sent as a user message, replies unwrapped into the text
+// completion schema
+// A provider without chat models serves no completions, only the rerank route.
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/pkg/convert"
+)
+
+// cohereParams translates OpenAI request parameters to Cohere's v2 chat API.
+// logit_bias, n, logprobs and user have no Cohere equivalent.
+var cohereParams = &paramRules{rules: map[string]paramRule{
+	"temperature":           {set: rename("temperature")},
+	"top_p":                 {set: rename("p")},
+	"max_tokens":            {set: rename("max_tokens")},
+	"max_completion_tokens": {set: rename("max_tokens")},
+	"seed":                  {set: rename("seed")},
+	"frequency_penalty":     {set: rename("frequency_penalty")},
+	"presence_penalty":      {set: rename("presence_penalty")},
+	"stop": stopRule(stopSpec{max: 5, set: func(payload map[string]interface{}, sequences []string) {
+		payload["stop_sequences"] = sequences
+	}}),
+	// Cohere takes OpenAI's function tools as they are
+	"tools": {set: rename("tools")},
+	"tool_choice": {set: func(payload map[string]interface{}, value interface{}) error {
+		switch value {
+		case "auto":
+		case "required":
+			payload["tool_choice"] = "REQUIRED"
+		case "none":
+			payload["tool_choice"] = "NONE"
+		default:
+			return errors.New(`must be "auto", "required" or "none", Cohere can't be told which function to call`)
+		}
+		return nil
+	}},
+	"response_format": {set: func(payload map[string]interface{}, value interface{}) error {
+		format, _ := value.(map[string]interface{})
+		switch format["type"] {
+		case "text":
+		case "json_object":
+			payload["response_format"] = map[string]interface{}{"type": "json_object"}
+		case "json_schema":
+			schema, _ := format["json_schema"].(map[string]interface{})
+			payload["response_format"] = map[string]interface{}{"type": "json_object", "json_schema": schema["schema"]}
+		default:
+			return errors.New(`must have type "text", "json_object" or "json_schema"`)
+		}
+		return nil
+	}},
+}}
+
+// CohereProvider implements the Reranker and Provider interfaces for Cohere's API.
+type CohereProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	client  *http.Client
+	// models are the configured chat models, the rerank ones left out
+	models   []string
+	priority int
+}
+
+// NewCohereProvider creates a new Cohere provider instance.
+func NewCohereProvider(cfg *config.Provider) *CohereProvider {
+	var models []string
+	for _, model := range cfg.Models {
+		if !Reranks(cfg, model) {
+			models = append(models, model)
+		}
+	}
+	return &CohereProvider{
+		name:     cfg.Name,
+		baseURL:  strings.TrimSuffix(cfg.BaseURL, "/"),
+		apiKey:   resolveEnv(cfg.APIKey),
+		client:   newHTTPClient(cfg),
+		models:   models,
+		priority: cfg.Priority,
+	}
+}
+
+// Name returns the provider name.
+func (p *CohereProvider) Name() string {
+	return p.name
+}
+
+// Priority returns the provider priority for model routing.
+func (p *CohereProvider) Priority() int {
+	return p.priority
+}
+
+// ListModels returns the configured chat models.
+func (p *CohereProvider) ListModels(_ context.Context) ([]Model, error) {
+	return modelsOf(p.models), nil
+}
+
+// Rerank implements Reranker.
+func (p *CohereProvider) Rerank(ctx context.Context, model string, req *RerankRequest) (*RerankResponse, error) {
+	payload := map[string]interface{}{
+		"model":     model,
+		"query":     req.Query,
+		"documents": req.Documents,
+	}
+	if req.TopN > 0 {
+		payload["top_n"] = req.TopN
+	}
+
+	var response RerankResponse
+	if err := postJSON(ctx, p.client, p.baseURL+"/rerank", p.headers(), payload, &response); err != nil {
+		return nil, err
+	}
+	response.Model = model
+	return &response, nil
+}
+
+// ChatCompletion performs a chat completion request through the v2 chat API.
+func (p *CohereProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	payload, err := p.payload(ctx, model, messages)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := postJSON(ctx, p.client, p.baseURL+"/chat", p.headers(), payload, &result); err != nil {
+		return nil, err
+	}
+
+	response := convert.CohereToOpenAIResponse(result)
+	response["model"] = model
+	response["created"] = time.Now().Unix()
+	return response, nil
+}
+
+// Completion performs a completion request by sending the prompt as a user message, and returns
+// the reply in the legacy text completion schema.
+func (p *CohereProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	return completionThroughChat(ctx, p.ChatCompletion, model, prompt)
+}
+
+// ChatCompletionStream performs a streaming chat completion request through the v2 chat API,
+// translating its events into OpenAI chunks.
+func (p *CohereProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	payload, err := p.payload(ctx, model, messages)
+	if err != nil {
+		return nil, err
+	}
+	payload["stream"] = true
+
+	stream := &convert.CohereStream{}
+	var id interface{}
+	created := time.Now().Unix()
+	reqConfig := StreamingRequestConfig{
+		BaseURL:  p.baseURL,
+		Endpoint: "/chat",
+		Payload:  payload,
+		Headers:  p.headers(),
+		UseSSE:   true,
+		Transformer: func(event interface{}) interface{} {
+			e, _ := event.(map[string]interface{})
+			if e["type"] == "message-start" {
+				id = e["id"]
+			}
+			chunk := stream.Chunk(e)
+			if chunk == nil {
+				return nil
+			}
+			chunk["id"], chunk["model"], chunk["created"] = id, model, created
+			return chunk
+		},
+	}
+	return makeStreamingRequest(ctx, p.client, reqConfig)
+}
+
+// CompletionStream performs a streaming completion request, streaming the reply as legacy text
+// completion chunks.
+func (p *CohereProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	return completionStreamThroughChat(ctx, p.ChatCompletionStream, model, prompt)
+}
+
+// payload builds the chat request for messages and the request's parameters.
+func (p *CohereProvider) payload(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (map[string]interface{}, error) {
+	payload := map[string]interface{}{
+		"model":    model,
+		"messages": convert.OpenAIToCohereMessages(messages),
+	}
+	if err := applyParams(ctx, p.name, payload, cohereParams); err != nil {
+		return nil, err
+	}
+	return payload, nil
+}
+
+func (p *CohereProvider) headers() map[string]string {
+	return map[string]string{"Authorization": "Bearer " + p.apiKey}
+}
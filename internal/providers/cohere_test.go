@@ -0,0 +1,106 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestCohereProvider_Chat(t *testing.T) {
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat", r.URL.Path)
+		assert.Equal(t, "Bearer co-key", r.Header.Get("Authorization"))
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+		if payload["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "event: message-start\n"+
+				"data: {\"type\":\"message-start\",\"id\":\"co-2\",\"delta\":{\"message\":{\"role\":\"assistant\"}}}\n\n"+
+				"event: content-delta\n"+
+				"data: {\"type\":\"content-delta\",\"index\":0,\"delta\":{\"message\":{\"content\":{\"text\":\"Hi\"}}}}\n\n"+
+				"event: content-end\n"+
+				"data: {\"type\":\"content-end\",\"index\":0}\n\n"+
+				"event: message-end\n"+
+				"data: {\"type\":\"message-end\",\"delta\":{\"finish_reason\":\"COMPLETE\","+
+				"\"usage\":{\"tokens\":{\"input_tokens\":4,\"output_tokens\":1}}}}\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"co-1","finish_reason":"COMPLETE","message":{"role":"assistant",` +
+			`"content":[{"type":"text","text":"Hi there"}]},"usage":{"tokens":{"input_tokens":4,"output_tokens":2}}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Provider{
+		Name: "cohere", Type: "cohere", BaseURL: server.URL, APIKey: "co-key",
+		Models: []string{"rerank-v3.5", "command-a-03-2025"},
+	}
+	provider := NewProvider(cfg)
+	require.NotNil(t, provider)
+	models, err := provider.ListModels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"command-a-03-2025"}, ModelIDs(models))
+
+	params := NewParams(map[string]interface{}{
+		"top_p": 0.9, "stop": "END", "tool_choice": "required", "logit_bias": map[string]interface{}{"1": float64(-100)},
+		"response_format": map[string]interface{}{"type": "json_schema",
+			"json_schema": map[string]interface{}{"name": "answer", "schema": map[string]interface{}{"type": "object"}}},
+	}, nil)
+	messages := []map[string]interface{}{
+		{"role": "system", "content": "Be brief."},
+		{"role": "user", "content": "Hello"},
+	}
+	result, err := provider.ChatCompletion(WithParams(t.Context(), params), "command-a-03-2025", messages)
+	require.NoError(t, err)
+	response := result.(map[string]interface{})
+	assert.Equal(t, "co-1", response["id"])
+	assert.Equal(t, "command-a-03-2025", response["model"])
+	choice := response["choices"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Hi there", choice["message"].(map[string]interface{})["content"])
+	assert.Equal(t, "stop", choice["finish_reason"])
+
+	assert.Equal(t, []interface{}{
+		map[string]interface{}{"role": "system", "content": "Be brief."},
+		map[string]interface{}{"role": "user", "content": "Hello"},
+	}, payloads[0]["messages"])
+	assert.Equal(t, 0.9, payloads[0]["p"])
+	assert.Equal(t, []interface{}{"END"}, payloads[0]["stop_sequences"])
+	assert.Equal(t, "REQUIRED", payloads[0]["tool_choice"])
+	assert.Equal(t, map[string]interface{}{
+		"type": "json_object", "json_schema": map[string]interface{}{"type": "object"},
+	}, payloads[0]["response_format"])
+	assert.NotContains(t, payloads[0], "logit_bias")
+	assert.Equal(t, []string{`parameter "logit_bias" was dropped, provider cohere can't honor it`}, params.Warnings())
+
+	stream, err := provider.CompletionStream(t.Context(), "command-a-03-2025", "Hello")
+	require.NoError(t, err)
+	var chunks []map[string]interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk.(map[string]interface{}))
+	}
+	require.Len(t, chunks, 2)
+	assert.Equal(t, "co-2", chunks[0]["id"])
+	assert.Equal(t, "Hi", chunks[0]["choices"].([]interface{})[0].(map[string]interface{})["text"])
+	assert.Equal(t, "stop", chunks[1]["choices"].([]interface{})[0].(map[string]interface{})["finish_reason"])
+	assert.Equal(t, float64(5), chunks[1]["usage"].(map[string]interface{})["total_tokens"])
+}
+
+func TestCohereProvider_ToolChoiceFunction(t *testing.T) {
+	provider := NewCohereProvider(&config.Provider{Name: "cohere", Type: "cohere", Models: []string{"command-r"}})
+	ctx := WithParams(t.Context(), NewParams(map[string]interface{}{
+		"tool_choice": map[string]interface{}{"type": "function", "function": map[string]interface{}{"name": "f"}},
+	}, nil))
+	_, err := provider.ChatCompletion(ctx, "command-r", userMessage)
+	var invalid *InvalidParamError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "tool_choice", invalid.Param)
+}
@@ -0,0 +1,129 @@
+// Package providers implements AI provider abstractions.
+// OpenAICompatibleProvider serves gateways and inference servers that speak OpenAI's API, such as
+// vLLM, LiteLLM, Together or Fireworks, with these differences from OpenAI itself:
+// - The API may live below a path prefix of the base URL, e.g. /openai/v1
+// - Parameters pass through unchecked, since the backend decides which it supports
+// - Without an API key no Authorization header is sent, as local servers often need none
+// - Without configured models, the served ones are listed from the models endpoint
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// compatibleModelsCacheTTL controls how long a listed set of models is reused
+const compatibleModelsCacheTTL = 10 * time.Minute
+
+// compatibleParams passes every parameter through to the backend.
+var compatibleParams = &paramRules{passthrough: true}
+
+// OpenAICompatibleProvider implements the Provider interface for OpenAI-compatible backends.
+type OpenAICompatibleProvider struct {
+	*OpenAIProvider
+	// modelsURL lists the backend's models
+	modelsURL string
+
+	modelsMtx      sync.Mutex
+	cachedModels   []string
+	modelsCachedAt time.Time
+}
+
+// NewOpenAICompatibleProvider creates a new OpenAI-compatible provider instance.
+func NewOpenAICompatibleProvider(cfg *config.Provider) *OpenAICompatibleProvider {
+	provider := NewOpenAIProvider(cfg)
+	provider.baseURL = strings.TrimSuffix(cfg.BaseURL, "/")
+	prefix := strings.TrimSuffix(cfg.Compatible.PathPrefix, "/")
+	provider.path = func(_, endpoint string) string { return prefix + endpoint }
+	provider.params = func(string) *paramRules { return compatibleParams }
+
+	modelsURL := cfg.Compatible.ModelsEndpoint
+	switch {
+	case modelsURL == "":
+		modelsURL = provider.baseURL + prefix + "/models"
+	case strings.HasPrefix(modelsURL, "/"):
+		modelsURL = provider.baseURL + modelsURL
+	}
+	return &OpenAICompatibleProvider{OpenAIProvider: provider, modelsURL: modelsURL}
+}
+
+// ListModels returns the list of available models for this provider.
+// Configured models take precedence; without them the models endpoint is listed and cached.
+// When a refresh fails the stale list, if any, is returned along with the error.
+func (p *OpenAICompatibleProvider) ListModels(ctx context.Context) ([]Model, error) {
+	if len(p.models) > 0 {
+		return modelsOf(p.models), nil
+	}
+
+	p.modelsMtx.Lock()
+	defer p.modelsMtx.Unlock()
+
+	if p.cachedModels != nil && time.Since(p.modelsCachedAt) < compatibleModelsCacheTTL {
+		return modelsOf(p.cachedModels), nil
+	}
+
+	models, err := p.fetchModels(ctx)
+	if err != nil {
+		return modelsOf(p.cachedModels), fmt.Errorf("listing models of %s: %w", p.name, err)
+	}
+
+	p.cachedModels = models
+	p.modelsCachedAt = time.Now()
+	return modelsOf(models), nil
+}
+
+// compatibleModelsList is the subset of an OpenAI model list we rely on.
+type compatibleModelsList struct {
+	Data []struct {
+		ID string `json:"id"`
+	} `json:"data"`
+}
+
+func (p *OpenAICompatibleProvider) fetchModels(ctx context.Context) ([]string, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", p.modelsURL, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+
+	headers, err := p.authHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	var list compatibleModelsList
+	if err := json.Unmarshal(body, &list); err != nil {
+		return nil, err
+	}
+
+	models := make([]string, 0, len(list.Data))
+	for _, model := range list.Data {
+		models = append(models, model.ID)
+	}
+	return models, nil
+}
@@ -0,0 +1,85 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestOpenAICompatibleProvider(t *testing.T) {
+	var requests []*http.Request
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == "GET" {
+			_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"meta-llama/Llama-3.1-8B-Instruct"},{"id":"qwen2.5"}]}`))
+			return
+		}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		_, _ = fmt.Fprint(w, `{"model":"qwen2.5","choices":[{"message":{"content":"Hi"}}]}`)
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(&config.Provider{
+		Name: "vllm", Type: "openai-compatible", BaseURL: server.URL + "/",
+		ExtraHeaders: map[string]string{"X-Tenant": "research"},
+		Compatible:   config.ProviderCompatible{PathPrefix: "/openai/v1/"},
+	})
+
+	models, err := provider.ListModels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"meta-llama/Llama-3.1-8B-Instruct", "qwen2.5"}, ModelIDs(models))
+	_, err = provider.ListModels(t.Context())
+	require.NoError(t, err)
+	require.Len(t, requests, 1, "listed models are cached")
+	assert.Equal(t, "/openai/v1/models", requests[0].URL.Path)
+
+	ctx := WithParams(t.Context(), NewParams(map[string]interface{}{
+		"stop": []interface{}{"a", "b", "c", "d", "e"}, "guided_json": map[string]interface{}{"type": "object"},
+	}, nil))
+	_, err = provider.ChatCompletion(ctx, "qwen2.5", userMessage)
+	require.NoError(t, err)
+	require.Len(t, requests, 2)
+	assert.Equal(t, "/openai/v1/chat/completions", requests[1].URL.Path)
+	assert.Equal(t, "research", requests[1].Header.Get("X-Tenant"))
+	assert.Empty(t, requests[1].Header.Get("Authorization"), "no key, no Authorization header")
+	assert.Len(t, payload["stop"], 5, "the backend decides which parameters it supports")
+	assert.Equal(t, map[string]interface{}{"type": "object"}, payload["guided_json"])
+}
+
+func TestOpenAICompatibleProvider_ModelsEndpoint(t *testing.T) {
+	var paths []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+		assert.Equal(t, "Bearer sk-test", r.Header.Get("Authorization"))
+		_, _ = w.Write([]byte(`{"data":[{"id":"llama-3.3-70b"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, endpoint := range []string{"/v1/catalog", server.URL + "/v1/catalog"} {
+		provider := NewProvider(&config.Provider{
+			Name: "gateway", Type: "openai-compatible", BaseURL: server.URL, APIKey: "sk-test",
+			Compatible: config.ProviderCompatible{PathPrefix: "/api", ModelsEndpoint: endpoint},
+		})
+		models, err := provider.ListModels(t.Context())
+		require.NoError(t, err)
+		assert.Equal(t, []string{"llama-3.3-70b"}, ModelIDs(models))
+	}
+	assert.Equal(t, []string{"/v1/catalog", "/v1/catalog"}, paths)
+
+	provider := NewProvider(&config.Provider{
+		Name: "gateway", Type: "openai-compatible", BaseURL: server.URL, Models: []string{"configured"},
+	})
+	models, err := provider.ListModels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"configured"}, ModelIDs(models))
+	assert.Len(t, paths, 2, "configured models are not listed")
+}
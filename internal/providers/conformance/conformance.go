@@ -0,0 +1,238 @@
+// Package conformance verifies provider implementations against recorded responses of their APIs.
+// A fixture holds one exchange recorded from a provider's real API and what the provider must make
+// of it; Run replays each fixture to the provider under test and compares its output. Fixtures of
+// calls served in the OpenAI format are also checked against the OpenAI-compatibility contract, so
+// a new provider type is verified by recording fixtures for it and running the suite.
+//
+// Fixtures are JSON files, recorded by sending the request a provider makes to the real API, e.g.
+// with curl -N for streams, and keeping the response body:
+//
+//	{
+//	  "call": "chat_stream",
+//	  "model": "gpt-4o-mini",
+//	  "messages": [{"role": "user", "content": "Say hi"}],
+//	  "openai_compatible": true,
+//	  "upstream": {"path": "/chat/completions", "stream": ["data: {...}", "", "data: [DONE]"]},
+//	  "expected_chunks": [{...}]
+//	}
+package conformance
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// Calls a fixture can make.
+const (
+	CallChat             = "chat"
+	CallCompletion       = "completion"
+	CallChatStream       = "chat_stream"
+	CallCompletionStream = "completion_stream"
+)
+
+// volatileFields are response fields that differ on every call, such as timestamps a provider
+// sets itself. They are only compared when a fixture's expected output has them.
+var volatileFields = []string{"created"}
+
+// Fixture is an exchange recorded from a provider's API and the output the provider must make of it.
+type Fixture struct {
+	// Name is the file name without extension
+	Name     string                   `json:"-"`
+	Call     string                   `json:"call"`
+	Model    string                   `json:"model"`
+	Messages []map[string]interface{} `json:"messages,omitempty"`
+	Prompt   string                   `json:"prompt,omitempty"`
+	// OpenAICompatible fixtures must be answered in the OpenAI format
+	OpenAICompatible bool      `json:"openai_compatible"`
+	Upstream         Recording `json:"upstream"`
+	// Expected is the response of a non-streaming call, ExpectedChunks the chunks of a streaming one
+	Expected       json.RawMessage   `json:"expected,omitempty"`
+	ExpectedChunks []json.RawMessage `json:"expected_chunks,omitempty"`
+	// ExpectedStatus is the status of the providers.StatusError the call must fail with
+	ExpectedStatus int `json:"expected_status,omitempty"`
+}
+
+// Recording is a response recorded from a provider's API.
+type Recording struct {
+	// Path is the request path the provider must call
+	Path string `json:"path"`
+	// Status defaults to 200
+	Status      int    `json:"status,omitempty"`
+	ContentType string `json:"content_type,omitempty"`
+	// Body is a JSON response, Stream the lines of a streamed one
+	Body   json.RawMessage `json:"body,omitempty"`
+	Stream []string        `json:"stream,omitempty"`
+}
+
+// Factory creates the provider under test from a config pointing at the replayed API.
+type Factory func(cfg *config.Provider) providers.Provider
+
+// Load reads the fixtures in dir, in file name order.
+func Load(dir string) ([]Fixture, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	fixtures := make([]Fixture, 0, len(paths))
+	for _, path := range paths {
+		data, err := os.ReadFile(path) // #nosec G304 -- fixture paths come from the test's directory
+		if err != nil {
+			return nil, err
+		}
+		var f Fixture
+		if err := json.Unmarshal(data, &f); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		f.Name = strings.TrimSuffix(filepath.Base(path), ".json")
+		if err := f.validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		fixtures = append(fixtures, f)
+	}
+	return fixtures, nil
+}
+
+func (f *Fixture) validate() error {
+	switch {
+	case !slices.Contains([]string{CallChat, CallCompletion, CallChatStream, CallCompletionStream}, f.Call):
+		return fmt.Errorf("unknown call %q", f.Call)
+	case f.Upstream.Path == "":
+		return fmt.Errorf("upstream.path is required")
+	case f.ExpectedStatus == 0 && f.streaming() && len(f.ExpectedChunks) == 0:
+		return fmt.Errorf("expected_chunks is required for a successful %s", f.Call)
+	case f.ExpectedStatus == 0 && !f.streaming() && len(f.Expected) == 0:
+		return fmt.Errorf("expected is required for a successful %s", f.Call)
+	}
+	return nil
+}
+
+func (f *Fixture) streaming() bool {
+	return f.Call == CallChatStream || f.Call == CallCompletionStream
+}
+
+// Run replays every fixture in dir to a provider of providerType created by factory, each as a subtest.
+func Run(t *testing.T, dir, providerType string, factory Factory) {
+	t.Helper()
+	fixtures, err := Load(dir)
+	require.NoError(t, err)
+	require.NotEmpty(t, fixtures, "no fixtures in %s", dir)
+
+	for _, f := range fixtures {
+		t.Run(f.Name, func(t *testing.T) {
+			server := Replay(t, &f.Upstream)
+			defer server.Close()
+
+			provider := factory(&config.Provider{
+				Name: "conformance", Type: providerType, BaseURL: server.URL, APIKey: "test-key",
+				Models: []string{f.Model}, Priority: 1,
+			})
+			require.NotNil(t, provider, "no provider of type %s", providerType)
+			runFixture(t, provider, &f)
+		})
+	}
+}
+
+// Replay serves rec to the first request, failing t when it isn't a POST to the recorded path.
+func Replay(t *testing.T, rec *Recording) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, rec.Path, r.URL.Path)
+
+		contentType := rec.ContentType
+		if contentType == "" {
+			contentType = "application/json"
+			if rec.Stream != nil {
+				contentType = "text/event-stream"
+			}
+		}
+		w.Header().Set("Content-Type", contentType)
+		if rec.Status != 0 {
+			w.WriteHeader(rec.Status)
+		}
+		if rec.Stream != nil {
+			_, _ = w.Write([]byte(strings.Join(rec.Stream, "\n") + "\n"))
+			return
+		}
+		_, _ = w.Write(rec.Body)
+	}))
+}
+
+func runFixture(t *testing.T, provider providers.Provider, f *Fixture) {
+	ctx := t.Context()
+	var result interface{}
+	var stream <-chan interface{}
+	var err error
+	switch f.Call {
+	case CallChat:
+		result, err = provider.ChatCompletion(ctx, f.Model, f.Messages)
+	case CallCompletion:
+		result, err = provider.Completion(ctx, f.Model, f.Prompt)
+	case CallChatStream:
+		stream, err = provider.ChatCompletionStream(ctx, f.Model, f.Messages)
+	case CallCompletionStream:
+		stream, err = provider.CompletionStream(ctx, f.Model, f.Prompt)
+	}
+
+	if f.ExpectedStatus != 0 {
+		var status *providers.StatusError
+		require.ErrorAs(t, err, &status)
+		assert.Equal(t, f.ExpectedStatus, status.StatusCode)
+		return
+	}
+	require.NoError(t, err)
+
+	if !f.streaming() {
+		actual := normalize(t, result, f.Expected)
+		assert.JSONEq(t, string(f.Expected), actual)
+		if f.OpenAICompatible {
+			assert.NoError(t, Check(f.Call, result))
+		}
+		return
+	}
+
+	var chunks []interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	require.Len(t, chunks, len(f.ExpectedChunks))
+	for i, chunk := range chunks {
+		actual := normalize(t, chunk, f.ExpectedChunks[i])
+		assert.JSONEq(t, string(f.ExpectedChunks[i]), actual, "chunk %d", i)
+	}
+	if f.OpenAICompatible {
+		assert.NoError(t, CheckStream(f.Call, chunks))
+	}
+}
+
+// normalize encodes value as JSON without the volatile fields expected doesn't have.
+func normalize(t *testing.T, value interface{}, expected json.RawMessage) string {
+	data, err := json.Marshal(value)
+	require.NoError(t, err)
+
+	var actual, want map[string]interface{}
+	if json.Unmarshal(data, &actual) != nil || json.Unmarshal(expected, &want) != nil {
+		return string(data)
+	}
+	for _, field := range volatileFields {
+		if _, ok := want[field]; !ok {
+			delete(actual, field)
+		}
+	}
+	data, err = json.Marshal(actual)
+	require.NoError(t, err)
+	return string(data)
+}
@@ -0,0 +1,81 @@
+package conformance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// TestProviders replays the fixtures of every provider type, in testdata/<type>.
+func TestProviders(t *testing.T) {
+	entries, err := os.ReadDir("testdata")
+	require.NoError(t, err)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		t.Run(entry.Name(), func(t *testing.T) {
+			Run(t, filepath.Join("testdata", entry.Name()), entry.Name(), providers.NewProvider)
+		})
+	}
+}
+
+func TestLoad_RejectsIncompleteFixtures(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "bad.json"),
+		[]byte(`{"call": "chat", "upstream": {"path": "/chat/completions"}}`), 0o600))
+	_, err := Load(dir)
+	assert.ErrorContains(t, err, "expected is required for a successful chat")
+}
+
+func TestCheck(t *testing.T) {
+	valid := map[string]interface{}{
+		"object": "chat.completion",
+		"choices": []interface{}{map[string]interface{}{
+			"index": 0, "message": map[string]interface{}{"role": "assistant", "content": "Hi"}, "finish_reason": "stop",
+		}},
+		"usage": map[string]interface{}{"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 4},
+	}
+	assert.NoError(t, Check(CallChat, valid))
+
+	native := map[string]interface{}{"type": "message", "content": []interface{}{}, "stop_reason": "end_turn"}
+	err := Check(CallChat, native)
+	assert.ErrorContains(t, err, "object is <nil>, expected chat.completion")
+	assert.ErrorContains(t, err, "choices is empty")
+
+	broken := map[string]interface{}{
+		"object": "chat.completion",
+		"choices": []interface{}{map[string]interface{}{
+			"message": map[string]interface{}{"role": "model"}, "finish_reason": "end_turn",
+		}},
+		"usage": map[string]interface{}{"prompt_tokens": 3, "completion_tokens": 1, "total_tokens": 5},
+	}
+	err = Check(CallChat, broken)
+	assert.ErrorContains(t, err, "choices[0].message.role is model, expected assistant")
+	assert.ErrorContains(t, err, "choices[0].finish_reason end_turn is not one of")
+	assert.ErrorContains(t, err, "usage.total_tokens 5 is not prompt_tokens plus completion_tokens")
+}
+
+func TestCheckStream(t *testing.T) {
+	chunk := func(delta map[string]interface{}, finishReason interface{}) map[string]interface{} {
+		return map[string]interface{}{
+			"object":  "chat.completion.chunk",
+			"choices": []interface{}{map[string]interface{}{"index": 0, "delta": delta, "finish_reason": finishReason}},
+		}
+	}
+	assert.NoError(t, CheckStream(CallChatStream, []interface{}{
+		chunk(map[string]interface{}{"role": "assistant", "content": "Hi"}, nil),
+		chunk(map[string]interface{}{}, "stop"),
+	}))
+
+	err := CheckStream(CallChatStream, []interface{}{
+		chunk(map[string]interface{}{"content": 1}, nil),
+	})
+	assert.ErrorContains(t, err, "chunk 0: choices[0].delta.content is not a string")
+	assert.ErrorContains(t, err, "no chunk has a finish_reason")
+}
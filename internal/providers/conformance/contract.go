@@ -0,0 +1,165 @@
+package conformance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// finishReasons are the finish reasons the OpenAI API reports.
+var finishReasons = []string{"stop", "length", "tool_calls", "content_filter", "function_call"}
+
+// Check reports how result, the response to a non-streaming call, breaks the OpenAI-compatibility
+// contract: chat calls must return a chat.completion and completion calls a text_completion, with
+// at least one choice and, when reported, consistent token usage.
+func Check(call string, result interface{}) error {
+	response, err := decode(result)
+	if err != nil {
+		return err
+	}
+
+	chat := call == CallChat || call == CallChatStream
+	object := "text_completion"
+	if chat {
+		object = "chat.completion"
+	}
+	errs := []error{checkObject(response, object)}
+	choices, _ := response["choices"].([]interface{})
+	if len(choices) == 0 {
+		errs = append(errs, errors.New("choices is empty"))
+	}
+	for i, c := range choices {
+		choice, _ := c.(map[string]interface{})
+		if choice == nil {
+			errs = append(errs, fmt.Errorf("choices[%d] is not an object", i))
+			continue
+		}
+		if chat {
+			errs = append(errs, checkMessage(i, choice["message"], "message"))
+		} else if _, ok := choice["text"].(string); !ok {
+			errs = append(errs, fmt.Errorf("choices[%d].text is not a string", i))
+		}
+		if reason, _ := choice["finish_reason"].(string); !slices.Contains(finishReasons, reason) {
+			errs = append(errs, fmt.Errorf("choices[%d].finish_reason %v is not one of %v", i, choice["finish_reason"],
+				finishReasons))
+		}
+	}
+	errs = append(errs, checkUsage(response))
+	return errors.Join(errs...)
+}
+
+// CheckStream reports how chunks, the stream of a streaming call, break the OpenAI-compatibility
+// contract: each chunk must be a chat.completion.chunk with deltas, or a text_completion for
+// completions, and the stream must finish with a finish reason.
+func CheckStream(call string, chunks []interface{}) error {
+	chat := call == CallChat || call == CallChatStream
+	object := "text_completion"
+	if chat {
+		object = "chat.completion.chunk"
+	}
+
+	var errs []error
+	finished := false
+	for n, chunk := range chunks {
+		response, err := decode(chunk)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("chunk %d: %w", n, err))
+			continue
+		}
+		chunkErrs := []error{checkObject(response, object), checkUsage(response)}
+		// A chunk carrying only the usage has no choices
+		choices, _ := response["choices"].([]interface{})
+		for i, c := range choices {
+			choice, _ := c.(map[string]interface{})
+			if choice == nil {
+				chunkErrs = append(chunkErrs, fmt.Errorf("choices[%d] is not an object", i))
+				continue
+			}
+			if chat {
+				chunkErrs = append(chunkErrs, checkMessage(i, choice["delta"], "delta"))
+			} else if _, ok := choice["text"].(string); !ok {
+				chunkErrs = append(chunkErrs, fmt.Errorf("choices[%d].text is not a string", i))
+			}
+			switch reason := choice["finish_reason"].(type) {
+			case nil:
+			case string:
+				if !slices.Contains(finishReasons, reason) {
+					chunkErrs = append(chunkErrs, fmt.Errorf("choices[%d].finish_reason %q is not one of %v",
+						i, reason, finishReasons))
+				}
+				finished = true
+			default:
+				chunkErrs = append(chunkErrs, fmt.Errorf("choices[%d].finish_reason is not a string", i))
+			}
+		}
+		if err := errors.Join(chunkErrs...); err != nil {
+			errs = append(errs, fmt.Errorf("chunk %d: %w", n, err))
+		}
+	}
+	if !finished {
+		errs = append(errs, errors.New("no chunk has a finish_reason"))
+	}
+	return errors.Join(errs...)
+}
+
+// decode returns value as the JSON object the client receives.
+func decode(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(data, &response); err != nil || response == nil {
+		return nil, fmt.Errorf("response is not a JSON object: %s", data)
+	}
+	return response, nil
+}
+
+func checkObject(response map[string]interface{}, object string) error {
+	if response["object"] != object {
+		return fmt.Errorf("object is %v, expected %s", response["object"], object)
+	}
+	return nil
+}
+
+// checkMessage checks the message, or delta, of choice i. Assistant messages carrying tool calls
+// may have no content.
+func checkMessage(i int, value interface{}, field string) error {
+	message, _ := value.(map[string]interface{})
+	if message == nil {
+		return fmt.Errorf("choices[%d].%s is not an object", i, field)
+	}
+	if role, ok := message["role"]; (ok || field == "message") && role != "assistant" {
+		return fmt.Errorf("choices[%d].%s.role is %v, expected assistant", i, field, role)
+	}
+	switch message["content"].(type) {
+	case string:
+	case nil:
+		if _, ok := message["tool_calls"]; !ok && field == "message" {
+			return fmt.Errorf("choices[%d].message has neither content nor tool_calls", i)
+		}
+	default:
+		return fmt.Errorf("choices[%d].%s.content is not a string", i, field)
+	}
+	return nil
+}
+
+// checkUsage checks the token counts of response, when it reports them.
+func checkUsage(response map[string]interface{}) error {
+	value, ok := response["usage"]
+	if !ok || value == nil {
+		return nil
+	}
+	usage, _ := value.(map[string]interface{})
+	prompt, promptOK := usage["prompt_tokens"].(float64)
+	completion, completionOK := usage["completion_tokens"].(float64)
+	total, totalOK := usage["total_tokens"].(float64)
+	switch {
+	case !promptOK || !completionOK || !totalOK:
+		return errors.New("usage lacks prompt_tokens, completion_tokens or total_tokens")
+	case total != prompt+completion:
+		return fmt.Errorf("usage.total_tokens %v is not prompt_tokens plus completion_tokens", total)
+	}
+	return nil
+}
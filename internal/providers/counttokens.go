@@ -0,0 +1,95 @@
+// Package providers implements AI provider abstractions.
+// This file contains token counting, which lets clients size a prompt before sending it: providers
+// whose API counts tokens do so, and requests for the others are estimated.
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrTokenCountUnsupported is returned when a provider's API can't count tokens.
+var ErrTokenCountUnsupported = errors.New("provider does not count tokens")
+
+// countTokensFields are the fields of an Anthropic Messages request that count towards its input.
+var countTokensFields = []string{"messages", "system", "tools", "tool_choice", "thinking"}
+
+// TokenCounter is implemented by providers whose API counts the input tokens of a request.
+type TokenCounter interface {
+	// CountTokens returns the input tokens of request, an Anthropic Messages request, for model.
+	CountTokens(ctx context.Context, model string, request map[string]interface{}) (int64, error)
+}
+
+// CountTokens counts the input tokens of request with provider, for wrappers around it that
+// would otherwise hide that it is a TokenCounter.
+func CountTokens(ctx context.Context, provider Provider, model string, request map[string]interface{}) (int64, error) {
+	counter, ok := provider.(TokenCounter)
+	if !ok {
+		return 0, fmt.Errorf("%w: %s", ErrTokenCountUnsupported, provider.Name())
+	}
+	return counter.CountTokens(ctx, model, request)
+}
+
+// CountTokens implements TokenCounter with the count_tokens endpoint.
+func (p *AnthropicProvider) CountTokens(
+	ctx context.Context, model string, request map[string]interface{},
+) (int64, error) {
+	payload := map[string]interface{}{"model": model}
+	for _, field := range countTokensFields {
+		if value, ok := request[field]; ok {
+			payload[field] = value
+		}
+	}
+
+	result, err := p.makeRequest(ctx, "/messages/count_tokens", payload)
+	if err != nil {
+		return 0, err
+	}
+	response, _ := result.(map[string]interface{})
+	tokens, ok := response["input_tokens"].(float64)
+	if !ok {
+		return 0, errors.New("count_tokens response has no input_tokens")
+	}
+	return int64(tokens), nil
+}
+
+// CountTokens implements TokenCounter; counting doesn't depend on the transport.
+func (p *transportAdapter) CountTokens(
+	ctx context.Context, model string, request map[string]interface{},
+) (int64, error) {
+	return CountTokens(ctx, p.Provider, model, request)
+}
+
+// CountTokens implements TokenCounter with the backend's name for model.
+func (p *mappedProvider) CountTokens(
+	ctx context.Context, model string, request map[string]interface{},
+) (int64, error) {
+	upstream, _ := p.resolve(model)
+	return CountTokens(ctx, p.Provider, upstream, request)
+}
+
+// EstimateRequestTokens estimates the input of an Anthropic Messages request from the text of its
+// messages and system prompt and the size of its tool definitions.
+func EstimateRequestTokens(request map[string]interface{}) int64 {
+	raw, _ := request["messages"].([]interface{})
+	messages := make([]map[string]interface{}, 0, len(raw)+1)
+	for _, message := range raw {
+		if m, ok := message.(map[string]interface{}); ok {
+			messages = append(messages, m)
+		}
+	}
+	// The system prompt is a string or text blocks, like message content
+	if system, ok := request["system"]; ok {
+		messages = append(messages, map[string]interface{}{"content": system})
+	}
+	tokens := EstimateMessagesTokens(messages)
+
+	if tools, ok := request["tools"]; ok {
+		if data, err := json.Marshal(tools); err == nil {
+			tokens += EstimateTextTokens(string(data))
+		}
+	}
+	return tokens
+}
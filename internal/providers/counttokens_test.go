@@ -0,0 +1,64 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestCountTokens(t *testing.T) {
+	var payload map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/messages/count_tokens", r.URL.Path)
+		assert.Equal(t, "test-key", r.Header.Get("x-api-key"))
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		_, _ = w.Write([]byte(`{"input_tokens": 14}`))
+	}))
+	defer server.Close()
+
+	provider := NewProvider(&config.Provider{
+		Name: "anthropic", Type: "anthropic", BaseURL: server.URL, APIKey: "test-key",
+		Models: []string{"claude"}, ModelMap: map[string]string{"claude": "claude-sonnet-4-20250514"},
+	})
+	request := map[string]interface{}{
+		"model": "claude", "system": "Be brief", "max_tokens": float64(100),
+		"messages": []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}},
+	}
+
+	tokens, err := CountTokens(t.Context(), provider, "claude", request)
+	require.NoError(t, err)
+	assert.Equal(t, int64(14), tokens)
+	// The backend's model name is counted against, and fields that don't count are left out
+	assert.Equal(t, map[string]interface{}{
+		"model": "claude-sonnet-4-20250514", "system": "Be brief", "messages": request["messages"],
+	}, payload)
+}
+
+func TestCountTokens_Unsupported(t *testing.T) {
+	provider := NewProvider(&config.Provider{Name: "local", Type: "ollama", BaseURL: "http://localhost:11434"})
+	_, err := CountTokens(t.Context(), provider, "llama3", map[string]interface{}{})
+	assert.ErrorIs(t, err, ErrTokenCountUnsupported)
+}
+
+func TestEstimateRequestTokens(t *testing.T) {
+	request := map[string]interface{}{
+		"system": []interface{}{map[string]interface{}{"type": "text", "text": "12345678"}},
+		"messages": []interface{}{
+			map[string]interface{}{"role": "user", "content": "1234"},
+			map[string]interface{}{"role": "user", "content": []interface{}{
+				map[string]interface{}{"type": "text", "text": "1234"},
+			}},
+		},
+	}
+	assert.Equal(t, int64(4), EstimateRequestTokens(request))
+
+	request["tools"] = []interface{}{map[string]interface{}{"name": "x"}}
+	// [{"name":"x"}] is 14 characters
+	assert.Equal(t, int64(8), EstimateRequestTokens(request))
+}
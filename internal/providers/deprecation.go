@@ -0,0 +1,173 @@
+// Package providers implements AI provider abstractions.
+// This file tracks deprecation notices: providers announce that a model is going away with the
+// Deprecation and Sunset headers (RFC 9745 and RFC 8594) or a warning field in the response body,
+// and operators should hear of it before the model disappears.
+package providers
+
+import (
+	"cmp"
+	"context"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Deprecation is what a provider last announced about a model going away.
+type Deprecation struct {
+	Provider string `json:"provider"`
+	Model    string `json:"model"`
+	// Deprecation and Sunset are the values of the headers of the same name: when the model was
+	// deprecated, and when it stops being served
+	Deprecation string `json:"deprecation,omitempty"`
+	Sunset      string `json:"sunset,omitempty"`
+	// Warning is the warning field of the response body
+	Warning   string    `json:"warning,omitempty"`
+	FirstSeen time.Time `json:"first_seen"`
+	LastSeen  time.Time `json:"last_seen"`
+}
+
+type deprecationKey struct {
+	provider, model string
+}
+
+// deprecations holds the notices of every provider. Like the captured headers it outlives config
+// reloads, so a notice stays known after the model is moved elsewhere.
+var deprecations = struct {
+	mtx     sync.Mutex
+	notices map[deprecationKey]*Deprecation
+}{notices: make(map[deprecationKey]*Deprecation)}
+
+// Deprecations returns the deprecation notices providers sent, by provider and model.
+func Deprecations() []Deprecation {
+	deprecations.mtx.Lock()
+	defer deprecations.mtx.Unlock()
+
+	notices := make([]Deprecation, 0, len(deprecations.notices))
+	for _, notice := range deprecations.notices {
+		notices = append(notices, *notice)
+	}
+	slices.SortFunc(notices, func(a, b Deprecation) int {
+		return cmp.Or(cmp.Compare(a.Provider, b.Provider), cmp.Compare(a.Model, b.Model))
+	})
+	return notices
+}
+
+// noteDeprecation records a notice about model from provider, logging it when it is news.
+func noteDeprecation(provider, model, deprecation, sunset, warning string, now time.Time) {
+	if deprecation == "" && sunset == "" && warning == "" {
+		return
+	}
+	deprecations.mtx.Lock()
+	defer deprecations.mtx.Unlock()
+
+	key := deprecationKey{provider: provider, model: model}
+	notice, ok := deprecations.notices[key]
+	if !ok {
+		notice = &Deprecation{Provider: provider, Model: model, FirstSeen: now}
+		deprecations.notices[key] = notice
+	}
+	if !ok || notice.Deprecation != deprecation || notice.Sunset != sunset || notice.Warning != warning {
+		slog.Warn("Provider announced a model deprecation", "provider", provider, "model", model,
+			"deprecation", deprecation, "sunset", sunset, "warning", warning)
+	}
+	notice.Deprecation, notice.Sunset, notice.Warning = deprecation, sunset, warning
+	notice.LastSeen = now
+}
+
+// deprecationWatcher records the deprecation notices of a provider's responses under the public
+// model names. The headers are seen when the provider captures them, as it does by default.
+type deprecationWatcher struct {
+	Provider
+	now func() time.Time
+}
+
+// ChatCompletion performs a chat completion, noting a deprecation notice in its response.
+func (p *deprecationWatcher) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	ctx, headers := WithResponseHeaders(ctx)
+	result, err := p.Provider.ChatCompletion(ctx, model, messages)
+	p.note(model, headers, result)
+	return result, err
+}
+
+// Completion performs a completion, noting a deprecation notice in its response.
+func (p *deprecationWatcher) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	ctx, headers := WithResponseHeaders(ctx)
+	result, err := p.Provider.Completion(ctx, model, prompt)
+	p.note(model, headers, result)
+	return result, err
+}
+
+// ChatCompletionStream performs a streaming chat completion, noting a deprecation notice in its
+// response headers or chunks.
+func (p *deprecationWatcher) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	ctx, headers := WithResponseHeaders(ctx)
+	stream, err := p.Provider.ChatCompletionStream(ctx, model, messages)
+	return p.watch(ctx, model, headers, stream, err)
+}
+
+// CompletionStream performs a streaming completion, noting a deprecation notice in its response
+// headers or chunks.
+func (p *deprecationWatcher) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	ctx, headers := WithResponseHeaders(ctx)
+	stream, err := p.Provider.CompletionStream(ctx, model, prompt)
+	return p.watch(ctx, model, headers, stream, err)
+}
+
+// CountTokens implements TokenCounter; counting isn't watched.
+func (p *deprecationWatcher) CountTokens(
+	ctx context.Context, model string, request map[string]interface{},
+) (int64, error) {
+	return CountTokens(ctx, p.Provider, model, request)
+}
+
+// Forward implements RawForwarder; raw requests aren't watched.
+func (p *deprecationWatcher) Forward(req *http.Request, path string) (*http.Response, error) {
+	return forwardThrough(p.Provider, req, path)
+}
+
+// watch notes the notice in the headers of stream, then passes its chunks on until one carries
+// a warning, which is noted too.
+func (p *deprecationWatcher) watch(
+	ctx context.Context, model string, headers *ResponseHeaders, stream <-chan interface{}, err error,
+) (<-chan interface{}, error) {
+	if err != nil {
+		return nil, err
+	}
+	p.note(model, headers, nil)
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		warned := false
+		for chunk := range stream {
+			if !warned && responseWarning(chunk) != "" {
+				warned = true
+				p.note(model, headers, chunk)
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+func (p *deprecationWatcher) note(model string, headers *ResponseHeaders, result interface{}) {
+	values := headers.Values()
+	noteDeprecation(p.Name(), model, values["deprecation"], values["sunset"], responseWarning(result), p.now())
+}
+
+// responseWarning returns the warning field of a response or chunk, which OpenAI sets when the
+// model is deprecated.
+func responseWarning(result interface{}) string {
+	response, _ := result.(map[string]interface{})
+	warning, _ := response["warning"].(string)
+	return warning
+}
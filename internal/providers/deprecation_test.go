@@ -0,0 +1,66 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func deprecationsOf(provider string) []Deprecation {
+	return slices.DeleteFunc(Deprecations(), func(d Deprecation) bool { return d.Provider != provider })
+}
+
+func TestDeprecationWatcher(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		if body["model"] == "gpt-4-0314" {
+			w.Header().Set("Deprecation", "@1688169599")
+			w.Header().Set("Sunset", "Thu, 13 Jun 2024 00:00:00 GMT")
+		}
+		if body["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte(`data: {"choices":[{"text":"Hi"}],"warning":"This model is deprecated."}` + "\n\n" +
+				"data: [DONE]\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`))
+	}))
+	defer server.Close()
+
+	cfg := &config.Provider{
+		Name: "deprecation-test", Type: "openai", BaseURL: server.URL,
+		Models:         []string{"gpt-4", "gpt-4-legacy", "text-davinci-003"},
+		ModelMap:       map[string]string{"gpt-4-legacy": "gpt-4-0314"},
+		CaptureHeaders: config.DefaultCaptureHeaders,
+	}
+	provider := NewProvider(cfg)
+
+	_, err := provider.ChatCompletion(t.Context(), "gpt-4", userMessage)
+	require.NoError(t, err)
+	assert.Empty(t, deprecationsOf(cfg.Name))
+
+	_, err = provider.ChatCompletion(t.Context(), "gpt-4-legacy", userMessage)
+	require.NoError(t, err)
+	stream, err := provider.CompletionStream(t.Context(), "text-davinci-003", "Hello")
+	require.NoError(t, err)
+	for range stream {
+	}
+
+	notices := deprecationsOf(cfg.Name)
+	require.Len(t, notices, 2)
+	assert.Equal(t, "gpt-4-legacy", notices[0].Model, "notices are recorded under the public name")
+	assert.Equal(t, "@1688169599", notices[0].Deprecation)
+	assert.Equal(t, "Thu, 13 Jun 2024 00:00:00 GMT", notices[0].Sunset)
+	assert.Equal(t, "text-davinci-003", notices[1].Model)
+	assert.Equal(t, "This model is deprecated.", notices[1].Warning)
+	assert.False(t, notices[1].FirstSeen.IsZero())
+}
@@ -0,0 +1,86 @@
+// Package providers implements AI provider abstractions.
+// This file defines embeddings: vectors that represent texts for search and retrieval. Embedding
+// providers serve no completions, so they are configured and routed apart from the providers that
+// do, and chat and embeddings may be served by different vendors.
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// ErrNoEmbedder is returned for embedding requests no configured embedding provider may serve.
+var ErrNoEmbedder = errors.New("no embedding provider")
+
+// Embedder is implemented by providers that embed texts.
+type Embedder interface {
+	Name() string
+	// Embed embeds the inputs of req with model, in their order.
+	Embed(ctx context.Context, model string, req *EmbeddingRequest) (*EmbeddingResponse, error)
+}
+
+// EmbeddingRequest asks for texts to be embedded.
+type EmbeddingRequest struct {
+	Input []string
+	// Dimensions shortens the embeddings to this many dimensions, the model's default when 0
+	Dimensions int
+	// Truncate cuts inputs longer than the model's context instead of failing; nil leaves it to the provider
+	Truncate *bool
+}
+
+// EmbeddingResponse is the embeddings of a request's inputs, in OpenAI's shape.
+type EmbeddingResponse struct {
+	Object string         `json:"object"`
+	Data   []Embedding    `json:"data"`
+	Model  string         `json:"model"`
+	Usage  EmbeddingUsage `json:"usage"`
+}
+
+// Embedding is the embedding of one input, given by its index in the request.
+type Embedding struct {
+	Object    string    `json:"object"`
+	Embedding []float64 `json:"embedding"`
+	Index     int       `json:"index"`
+}
+
+// EmbeddingUsage counts the tokens embedded.
+type EmbeddingUsage struct {
+	PromptTokens int `json:"prompt_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// NewEmbedder returns the embedder for the provider of cfg, or nil when its type doesn't embed.
+func NewEmbedder(cfg *config.Provider) Embedder {
+	switch cfg.Type {
+	case "voyage":
+		return NewVoyageProvider(cfg)
+	case "jina":
+		return NewJinaProvider(cfg)
+	}
+	return nil
+}
+
+// embed posts payload to url and normalizes the OpenAI-like answer Voyage and Jina share.
+func embed(
+	ctx context.Context, client *http.Client, apiKey, url, model string, payload map[string]interface{},
+) (*EmbeddingResponse, error) {
+	var response EmbeddingResponse
+	headers := map[string]string{"Authorization": "Bearer " + apiKey}
+	if err := postJSON(ctx, client, url, headers, payload, &response); err != nil {
+		return nil, err
+	}
+
+	response.Object = "list"
+	response.Model = model
+	for i := range response.Data {
+		response.Data[i].Object = "embedding"
+	}
+	if response.Usage.PromptTokens == 0 {
+		// Voyage counts only the total, which for embeddings is all prompt
+		response.Usage.PromptTokens = response.Usage.TotalTokens
+	}
+	return &response, nil
+}
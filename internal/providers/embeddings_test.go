@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestEmbedders_PassDimensionsAndTruncation(t *testing.T) {
+	truncate := false
+	tests := []struct {
+		name     string
+		typ      string
+		expected map[string]interface{}
+		usage    string
+	}{
+		{
+			name: "voyage", typ: "voyage", usage: `{"total_tokens":7}`,
+			expected: map[string]interface{}{
+				"model": "voyage-3.5", "input": []interface{}{"a", "b"}, "output_dimension": 256.0, "truncation": false,
+			},
+		},
+		{
+			name: "jina", typ: "jina", usage: `{"prompt_tokens":7,"total_tokens":7}`,
+			expected: map[string]interface{}{
+				"model": "voyage-3.5", "input": []interface{}{"a", "b"}, "dimensions": 256.0, "truncate": false,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				assert.Equal(t, "/embeddings", r.URL.Path)
+				assert.Equal(t, "Bearer key", r.Header.Get("Authorization"))
+				var body map[string]interface{}
+				assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+				assert.Equal(t, tt.expected, body)
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = w.Write([]byte(`{"object":"list","data":[{"object":"embedding","embedding":[0.1,0.2],"index":0},` +
+					`{"embedding":[0.3,0.4],"index":1}],"model":"upstream","usage":` + tt.usage + `}`))
+			}))
+			defer server.Close()
+
+			embedder := NewEmbedder(&config.Provider{Name: tt.name, Type: tt.typ, BaseURL: server.URL, APIKey: "key"})
+			response, err := embedder.Embed(t.Context(), "voyage-3.5", &EmbeddingRequest{
+				Input: []string{"a", "b"}, Dimensions: 256, Truncate: &truncate,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, &EmbeddingResponse{
+				Object: "list", Model: "voyage-3.5", Usage: EmbeddingUsage{PromptTokens: 7, TotalTokens: 7},
+				Data: []Embedding{
+					{Object: "embedding", Embedding: []float64{0.1, 0.2}, Index: 0},
+					{Object: "embedding", Embedding: []float64{0.3, 0.4}, Index: 1},
+				},
+			}, response)
+		})
+	}
+}
+
+func TestNewEmbedder_CompletionProviders(t *testing.T) {
+	assert.Nil(t, NewEmbedder(&config.Provider{Name: "openai", Type: "openai"}))
+	assert.Nil(t, NewProvider(&config.Provider{Name: "voyage", Type: "voyage"}), "embedding providers serve no completions")
+	assert.Nil(t, NewReranker(&config.Provider{Name: "jina", Type: "jina"}))
+}
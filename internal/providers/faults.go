@@ -0,0 +1,131 @@
+// Package providers implements AI provider abstractions.
+// This file contains fault injection for resilience testing: synthetic latency, 429s, 500s
+// and truncated responses, injected below the provider so every caller sees realistic failures.
+package providers
+
+import (
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// FaultHeader marks synthetic responses so they can't be mistaken for real upstream failures.
+const FaultHeader = "X-Modelplex-Fault"
+
+// faultsEnabled is the process-wide chaos switch; providers keep their configured rates either way.
+var faultsEnabled atomic.Bool
+
+// SetFaultInjection turns fault injection on or off for every provider.
+func SetFaultInjection(enabled bool) {
+	faultsEnabled.Store(enabled)
+}
+
+// FaultInjectionEnabled reports whether fault injection is on.
+func FaultInjectionEnabled() bool {
+	return faultsEnabled.Load()
+}
+
+// faultTransport injects the configured faults into requests while fault injection is enabled.
+type faultTransport struct {
+	provider string
+	base     http.RoundTripper
+	faults   config.ProviderFaults
+	// rand is swappable so tests can force faults
+	rand func() float64
+}
+
+// hasFaults reports whether any fault is configured with a non-zero rate.
+func hasFaults(f *config.ProviderFaults) bool {
+	return f.LatencyRate > 0 || f.RateLimitRate > 0 || f.ErrorRate > 0 || f.DisconnectRate > 0
+}
+
+func newFaultTransport(provider string, base http.RoundTripper, faults config.ProviderFaults) *faultTransport {
+	return &faultTransport{
+		provider: provider,
+		base:     base,
+		faults:   faults,
+		rand:     rand.Float64, // #nosec G404 -- fault injection doesn't need cryptographic randomness
+	}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *faultTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !faultsEnabled.Load() {
+		return t.base.RoundTrip(req)
+	}
+
+	if t.hit(t.faults.LatencyRate) {
+		delay := time.Duration(t.faults.LatencyMS) * time.Millisecond
+		slog.Debug("Injecting latency", "provider", t.provider, "delay", delay)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+	}
+
+	if t.hit(t.faults.RateLimitRate) {
+		slog.Debug("Injecting rate limit", "provider", t.provider)
+		return syntheticResponse(req, http.StatusTooManyRequests, "rate_limit_error"), nil
+	}
+	if t.hit(t.faults.ErrorRate) {
+		slog.Debug("Injecting server error", "provider", t.provider)
+		return syntheticResponse(req, http.StatusInternalServerError, "server_error"), nil
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err == nil && t.hit(t.faults.DisconnectRate) {
+		slog.Debug("Injecting disconnect", "provider", t.provider)
+		resp.Body = &disconnectingBody{ReadCloser: resp.Body}
+	}
+	return resp, err
+}
+
+func (t *faultTransport) hit(rate float64) bool {
+	return rate > 0 && t.rand() < rate
+}
+
+// syntheticResponse builds an OpenAI-style error response without contacting the provider.
+func syntheticResponse(req *http.Request, status int, errorType string) *http.Response {
+	body := `{"error":{"message":"injected fault","type":"` + errorType + `"}}`
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+	header.Set(FaultHeader, errorType)
+	if status == http.StatusTooManyRequests {
+		header.Set("Retry-After", "1")
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(status),
+		StatusCode:    status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(strings.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}
+}
+
+// disconnectingBody lets the first read through and then fails as if the connection dropped.
+type disconnectingBody struct {
+	io.ReadCloser
+	read bool
+}
+
+func (b *disconnectingBody) Read(p []byte) (int, error) {
+	if b.read {
+		return 0, io.ErrUnexpectedEOF
+	}
+	b.read = true
+	return b.ReadCloser.Read(p)
+}
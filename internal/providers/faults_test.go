@@ -0,0 +1,113 @@
+package providers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func enableFaults(t *testing.T) {
+	SetFaultInjection(true)
+	t.Cleanup(func() { SetFaultInjection(false) })
+}
+
+func TestFaultTransport(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls++
+		_, _ = w.Write([]byte(strings.Repeat("x", 1024)))
+	}))
+	defer server.Close()
+
+	do := func(faults config.ProviderFaults) (*http.Response, error) {
+		transport := newFaultTransport("test", http.DefaultTransport, faults)
+		transport.rand = func() float64 { return 0 } // every configured fault fires
+		req, err := http.NewRequestWithContext(t.Context(), "GET", server.URL, http.NoBody)
+		require.NoError(t, err)
+		return transport.RoundTrip(req)
+	}
+
+	t.Run("disabled", func(t *testing.T) {
+		resp, err := do(config.ProviderFaults{ErrorRate: 1})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, 1, calls)
+	})
+
+	enableFaults(t)
+
+	t.Run("rate limit", func(t *testing.T) {
+		resp, err := do(config.ProviderFaults{RateLimitRate: 1, ErrorRate: 1})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+		assert.Equal(t, "rate_limit_error", resp.Header.Get(FaultHeader))
+		assert.Equal(t, 1, calls, "synthetic responses never reach the provider")
+	})
+
+	t.Run("server error", func(t *testing.T) {
+		resp, err := do(config.ProviderFaults{ErrorRate: 1})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusInternalServerError, resp.StatusCode)
+	})
+
+	t.Run("disconnect", func(t *testing.T) {
+		resp, err := do(config.ProviderFaults{DisconnectRate: 1})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		buf := make([]byte, 16)
+		n, err := resp.Body.Read(buf)
+		require.NoError(t, err)
+		assert.Equal(t, 16, n)
+		_, err = io.ReadAll(resp.Body)
+		assert.ErrorIs(t, err, io.ErrUnexpectedEOF)
+	})
+
+	t.Run("latency respects cancellation", func(t *testing.T) {
+		faults := config.ProviderFaults{LatencyRate: 1, LatencyMS: 60_000}
+		transport := newFaultTransport("test", http.DefaultTransport, faults)
+		transport.rand = func() float64 { return 0 }
+
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+		req, err := http.NewRequestWithContext(ctx, "GET", server.URL, http.NoBody)
+		require.NoError(t, err)
+
+		_, err = transport.RoundTrip(req)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("rate zero never fires", func(t *testing.T) {
+		before := calls
+		resp, err := do(config.ProviderFaults{})
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, before+1, calls)
+	})
+}
+
+func TestNewHTTPClient_Faults(t *testing.T) {
+	client := newHTTPClient(&config.Provider{
+		Name:       "test",
+		ExtraQuery: map[string]string{"api-version": "v1"},
+		Faults:     config.ProviderFaults{ErrorRate: 0.5},
+	})
+
+	paused, ok := client.Transport.(*pauseTransport)
+	require.True(t, ok)
+	transport, ok := paused.base.(*faultTransport)
+	require.True(t, ok)
+	assert.IsType(t, &extrasTransport{}, transport.base, "faults wrap the extras so synthetic responses skip the network")
+}
@@ -0,0 +1,195 @@
+// Package providers implements AI provider abstractions.
+// GroqProvider serves GroqCloud, whose chat API is OpenAI's with these differences:
+// - logprobs, top_logprobs and logit_bias are unsupported, and n must be 1
+// - Rate limits are per model; a 429 names the exhausted limit, e.g. tokens per minute (TPM), and
+// how long to wait in its message, which is returned as a RateLimitError
+// - Responses report how long the request was queued, usage.queue_time, and streams report their
+// usage in a final x_groq field; both are recorded, see QueueTimes
+// - There is no completions endpoint, so text completions go through chat
+// - Without configured models, the served ones are listed from /models
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// groqParams adapts OpenAI parameters to Groq's chat API.
+var groqParams = &paramRules{
+	rules: map[string]paramRule{
+		"n": {set: func(payload map[string]interface{}, value interface{}) error {
+			if n, _ := paramNumber(value); n != 1 {
+				return errors.New("must be 1, Groq generates a single choice")
+			}
+			payload["n"] = value
+			return nil
+		}},
+		"logit_bias":   {},
+		"logprobs":     {},
+		"top_logprobs": {},
+	},
+	passthrough: true,
+}
+
+var (
+	// groqLimit matches the limit a 429 names, e.g. "on tokens per minute (TPM): Limit 6000"
+	groqLimit = regexp.MustCompile(`\(([A-Z]+)\): Limit`)
+	// groqRetryIn matches the wait a 429 asks for, a Go duration such as 7.66s or 1m32.5s
+	groqRetryIn = regexp.MustCompile(`try again in ((?:\d+(?:\.\d+)?(?:ms|h|m|s))+)`)
+)
+
+// GroqProvider implements the Provider interface for GroqCloud.
+type GroqProvider struct {
+	*OpenAICompatibleProvider
+}
+
+// NewGroqProvider creates a new Groq provider instance.
+func NewGroqProvider(cfg *config.Provider) *GroqProvider {
+	provider := NewOpenAICompatibleProvider(cfg)
+	provider.params = func(string) *paramRules { return groqParams }
+	return &GroqProvider{OpenAICompatibleProvider: provider}
+}
+
+// ChatCompletion performs a chat completion request, recording the time it was queued.
+func (p *GroqProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	result, err := p.OpenAICompatibleProvider.ChatCompletion(ctx, model, messages)
+	if err != nil {
+		return nil, p.rateLimitError(model, err)
+	}
+	response, _ := result.(map[string]interface{})
+	usage, _ := response["usage"].(map[string]interface{})
+	p.recordQueueTime(usage)
+	return result, nil
+}
+
+// Completion performs a completion request by sending the prompt as a user message, and returns
+// the reply in the legacy text completion schema.
+func (p *GroqProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	return completionThroughChat(ctx, p.ChatCompletion, model, prompt)
+}
+
+// ChatCompletionStream performs a streaming chat completion request. The usage Groq reports in
+// the x_groq field of the final chunk is copied to its usage field, where clients expect it.
+func (p *GroqProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	chunks, err := p.OpenAICompatibleProvider.ChatCompletionStream(ctx, model, messages)
+	if err != nil {
+		return nil, p.rateLimitError(model, err)
+	}
+
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for chunk := range chunks {
+			c, _ := chunk.(map[string]interface{})
+			groq, _ := c["x_groq"].(map[string]interface{})
+			if usage, ok := groq["usage"].(map[string]interface{}); ok {
+				p.recordQueueTime(usage)
+				if _, ok := c["usage"]; !ok {
+					c["usage"] = usage
+				}
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// CompletionStream performs a streaming completion request, streaming the reply as legacy text
+// completion chunks.
+func (p *GroqProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	return completionStreamThroughChat(ctx, p.ChatCompletionStream, model, prompt)
+}
+
+// rateLimitError returns the RateLimitError of a 429 Groq explained, or err as it is.
+func (p *GroqProvider) rateLimitError(model string, err error) error {
+	var status *StatusError
+	if !errors.As(err, &status) || status.StatusCode != http.StatusTooManyRequests {
+		return err
+	}
+	var body struct {
+		Error struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	if json.Unmarshal([]byte(status.Body), &body) != nil || body.Error.Message == "" {
+		return err
+	}
+
+	limited := &RateLimitError{Provider: p.name, Model: model, Message: body.Error.Message, Status: status}
+	if match := groqLimit.FindStringSubmatch(body.Error.Message); match != nil {
+		limited.Limit = match[1]
+	}
+	if match := groqRetryIn.FindStringSubmatch(body.Error.Message); match != nil {
+		limited.RetryAfter, _ = time.ParseDuration(match[1])
+	}
+	return limited
+}
+
+// recordQueueTime records the queue_time of usage, when reported.
+func (p *GroqProvider) recordQueueTime(usage map[string]interface{}) {
+	seconds, ok := usage["queue_time"].(float64)
+	if !ok {
+		return
+	}
+	value, _ := queueLogs.LoadOrStore(p.name, &queueLog{})
+	log, _ := value.(*queueLog)
+	log.record(seconds, time.Now())
+}
+
+// QueueTime summarizes how long a provider queued requests before serving them, as it reported.
+type QueueTime struct {
+	Requests    int64     `json:"requests"`
+	LastSeconds float64   `json:"last_seconds"`
+	MeanSeconds float64   `json:"mean_seconds"`
+	MaxSeconds  float64   `json:"max_seconds"`
+	SeenAt      time.Time `json:"seen_at"`
+}
+
+// queueLogs holds the queue times of every provider reporting them by name. Like headerLogs, it
+// outlives config reloads.
+var queueLogs sync.Map
+
+type queueLog struct {
+	mtx   sync.Mutex
+	time  QueueTime
+	total float64
+}
+
+func (l *queueLog) record(seconds float64, now time.Time) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.time.Requests++
+	l.total += seconds
+	l.time.LastSeconds = seconds
+	l.time.MeanSeconds = l.total / float64(l.time.Requests)
+	l.time.MaxSeconds = max(l.time.MaxSeconds, seconds)
+	l.time.SeenAt = now
+}
+
+// QueueTimes returns the queue times provider reported, or nil when it hasn't reported any.
+func QueueTimes(provider string) *QueueTime {
+	value, ok := queueLogs.Load(provider)
+	if !ok {
+		return nil
+	}
+	log, _ := value.(*queueLog)
+	log.mtx.Lock()
+	defer log.mtx.Unlock()
+	queued := log.time
+	return &queued
+}
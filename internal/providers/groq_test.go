@@ -0,0 +1,110 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestGroqProvider(t *testing.T) {
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		assert.Equal(t, "Bearer gsk-key", r.Header.Get("Authorization"))
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+		if payload["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"id\":\"c2\",\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n"+
+				"data: {\"id\":\"c2\",\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}],"+
+				"\"x_groq\":{\"id\":\"req_1\",\"usage\":{\"queue_time\":0.3,\"prompt_tokens\":4,"+
+				"\"completion_tokens\":1,\"total_tokens\":5}}}\n\n"+
+				"data: [DONE]\n\n")
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"c1","object":"chat.completion","choices":[{"index":0,` +
+			`"message":{"role":"assistant","content":"Hi there"},"finish_reason":"stop"}],` +
+			`"usage":{"queue_time":0.1,"prompt_tokens":4,"completion_tokens":2,"total_tokens":6}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	cfg := &config.Provider{
+		Name: "groq-queue", Type: "groq", BaseURL: server.URL, APIKey: "gsk-key", Models: []string{"llama-3.1-8b-instant"},
+	}
+	provider := NewProvider(cfg)
+	require.NotNil(t, provider)
+	assert.Nil(t, QueueTimes("groq-queue"))
+
+	params := NewParams(map[string]interface{}{"temperature": 0.2, "logprobs": true}, nil)
+	result, err := provider.ChatCompletion(WithParams(t.Context(), params), "llama-3.1-8b-instant", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "c1", result.(map[string]interface{})["id"])
+	assert.Equal(t, 0.2, payloads[0]["temperature"])
+	assert.NotContains(t, payloads[0], "logprobs")
+	assert.Equal(t, []string{`parameter "logprobs" was dropped, provider groq-queue can't honor it`}, params.Warnings())
+
+	stream, err := provider.ChatCompletionStream(t.Context(), "llama-3.1-8b-instant", userMessage)
+	require.NoError(t, err)
+	var chunks []map[string]interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk.(map[string]interface{}))
+	}
+	require.Len(t, chunks, 2)
+	assert.Equal(t, float64(5), chunks[1]["usage"].(map[string]interface{})["total_tokens"])
+
+	queued := QueueTimes("groq-queue")
+	require.NotNil(t, queued)
+	assert.Equal(t, int64(2), queued.Requests)
+	assert.InDelta(t, 0.3, queued.LastSeconds, 1e-9)
+	assert.InDelta(t, 0.2, queued.MeanSeconds, 1e-9)
+	assert.InDelta(t, 0.3, queued.MaxSeconds, 1e-9)
+
+	one := NewParams(map[string]interface{}{"n": json.Number("1")}, nil)
+	_, err = provider.ChatCompletion(WithParams(t.Context(), one), "llama-3.1-8b-instant", userMessage)
+	require.NoError(t, err, "n is decoded as a json.Number")
+	_, err = provider.ChatCompletion(WithParams(t.Context(), NewParams(map[string]interface{}{"n": float64(2)}, nil)),
+		"llama-3.1-8b-instant", userMessage)
+	var invalid *InvalidParamError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "n", invalid.Param)
+}
+
+func TestGroqProvider_RateLimited(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"error":{"message":"Rate limit reached for model ` + "`llama-3.3-70b-versatile`" +
+			` in organization ` + "`org_1`" + ` service tier ` + "`on_demand`" + ` on tokens per minute (TPM): ` +
+			`Limit 12000, Used 11512, Requested 1337. Please try again in 1m4.245s. Need more tokens?",` +
+			`"type":"tokens","code":"rate_limit_exceeded"}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewGroqProvider(&config.Provider{Name: "groq", Type: "groq", BaseURL: server.URL})
+	for _, call := range []func() error{
+		func() error {
+			_, err := provider.ChatCompletion(t.Context(), "llama-3.3-70b-versatile", userMessage)
+			return err
+		},
+		func() error {
+			_, err := provider.CompletionStream(t.Context(), "llama-3.3-70b-versatile", "Hello")
+			return err
+		},
+	} {
+		err := call()
+		var limited *RateLimitError
+		require.ErrorAs(t, err, &limited)
+		assert.Equal(t, "llama-3.3-70b-versatile", limited.Model)
+		assert.Equal(t, "TPM", limited.Limit)
+		assert.Equal(t, time.Minute+4245*time.Millisecond, limited.RetryAfter)
+		assert.Equal(t, http.StatusTooManyRequests, NewAttempt("groq", "", err).StatusCode, "still a status error")
+	}
+}
@@ -0,0 +1,118 @@
+// Package providers implements AI provider abstractions.
+// This file captures selected upstream response headers, such as request ids, rate limit state
+// and deprecation notices, so operators can see what each provider last reported.
+package providers
+
+import (
+	"context"
+	"maps"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CapturedHeader is the last value a provider sent for a captured response header.
+type CapturedHeader struct {
+	Value  string    `json:"value"`
+	SeenAt time.Time `json:"seen_at"`
+}
+
+// headerLogs holds the captured headers of every provider by name. It outlives config reloads, so
+// the last values stay known until the provider responds again.
+var headerLogs sync.Map
+
+type headerLog struct {
+	mtx     sync.Mutex
+	headers map[string]CapturedHeader
+}
+
+// CapturedHeaders returns the headers provider last responded with among those it captures, by
+// lowercase name, or nil when it hasn't sent any.
+func CapturedHeaders(provider string) map[string]CapturedHeader {
+	value, ok := headerLogs.Load(provider)
+	if !ok {
+		return nil
+	}
+	log, _ := value.(*headerLog)
+	log.mtx.Lock()
+	defer log.mtx.Unlock()
+	return maps.Clone(log.headers)
+}
+
+type headersKey struct{}
+
+// ResponseHeaders collects the captured headers of the responses to one request, e.g. to record
+// the upstream request id along with a failure.
+type ResponseHeaders struct {
+	mtx     sync.Mutex
+	headers map[string]string
+	// parent is the collector of an enclosing context, which sees the same headers
+	parent *ResponseHeaders
+}
+
+// WithResponseHeaders returns a context whose provider responses have their captured headers
+// collected in the returned ResponseHeaders, as well as in any collector ctx already carries.
+func WithResponseHeaders(ctx context.Context) (context.Context, *ResponseHeaders) {
+	parent, _ := ctx.Value(headersKey{}).(*ResponseHeaders)
+	collected := &ResponseHeaders{parent: parent}
+	return context.WithValue(ctx, headersKey{}, collected), collected
+}
+
+// Values returns the collected headers by lowercase name, the last response's winning, or nil
+// when none were collected.
+func (h *ResponseHeaders) Values() map[string]string {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+	return maps.Clone(h.headers)
+}
+
+func (h *ResponseHeaders) set(name, value string) {
+	for ; h != nil; h = h.parent {
+		h.mtx.Lock()
+		if h.headers == nil {
+			h.headers = make(map[string]string)
+		}
+		h.headers[name] = value
+		h.mtx.Unlock()
+	}
+}
+
+// headerTransport captures the configured headers of a provider's responses.
+type headerTransport struct {
+	base  http.RoundTripper
+	names []string
+	log   *headerLog
+	now   func() time.Time
+}
+
+func newHeaderTransport(provider string, base http.RoundTripper, names []string) *headerTransport {
+	value, _ := headerLogs.LoadOrStore(provider, &headerLog{headers: make(map[string]CapturedHeader)})
+	lower := make([]string, len(names))
+	for i, name := range names {
+		lower[i] = strings.ToLower(name)
+	}
+	return &headerTransport{base: base, names: lower, log: value.(*headerLog), now: time.Now}
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return resp, err
+	}
+	collected, _ := req.Context().Value(headersKey{}).(*ResponseHeaders)
+	now := t.now()
+
+	t.log.mtx.Lock()
+	defer t.log.mtx.Unlock()
+	for _, name := range t.names {
+		value := resp.Header.Get(name)
+		if value == "" {
+			continue
+		}
+		collected.set(name, value)
+		t.log.headers[name] = CapturedHeader{Value: value, SeenAt: now}
+	}
+	return resp, nil
+}
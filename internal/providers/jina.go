@@ -0,0 +1,52 @@
+// Package providers implements AI provider abstractions.
+// JinaProvider embeds texts with Jina AI's embeddings API, e.g. jina-embeddings-v3:
+// - The base URL is the API's, e.g. https://api.jina.ai/v1
+// - Authentication is a bearer API key
+// - dimensions is sent as it is and truncation as truncate
+// - It serves no completions, only the embeddings route
+package providers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// JinaProvider implements the Embedder interface for Jina AI's API.
+type JinaProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewJinaProvider creates a new Jina AI provider instance.
+func NewJinaProvider(cfg *config.Provider) *JinaProvider {
+	return &JinaProvider{
+		name:    cfg.Name,
+		baseURL: cfg.BaseURL,
+		apiKey:  resolveEnv(cfg.APIKey),
+		client:  newHTTPClient(cfg),
+	}
+}
+
+// Name returns the provider name.
+func (p *JinaProvider) Name() string {
+	return p.name
+}
+
+// Embed implements Embedder.
+func (p *JinaProvider) Embed(ctx context.Context, model string, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	payload := map[string]interface{}{
+		"model": model,
+		"input": req.Input,
+	}
+	if req.Dimensions > 0 {
+		payload["dimensions"] = req.Dimensions
+	}
+	if req.Truncate != nil {
+		payload["truncate"] = *req.Truncate
+	}
+	return embed(ctx, p.client, p.apiKey, p.baseURL+"/embeddings", model, payload)
+}
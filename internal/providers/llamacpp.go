@@ -0,0 +1,63 @@
+// Package providers implements AI provider abstractions.
+// LlamaCppProvider serves models from llama.cpp's server (llama-server) through its
+// OpenAI-compatible endpoints, with these differences from OpenAI:
+// - Sampling can be constrained by a GBNF grammar ("grammar") or a JSON schema ("json_schema"),
+// which are passed through as they are
+// - response_format is translated to json_schema, which llama.cpp turns into a grammar
+// - Authentication is optional, with the key given to llama-server's --api-key
+// - The base URL is the server's /v1, e.g. http://localhost:8080/v1
+package providers
+
+import (
+	"errors"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// llamaCppParams passes parameters through, since llama-server takes OpenAI's along with its own
+// sampling parameters such as top_k, min_p and grammar; response_format is translated.
+var llamaCppParams = &paramRules{
+	rules: map[string]paramRule{
+		"response_format": {set: llamaCppFormat},
+	},
+	passthrough: true,
+}
+
+// llamaCppFormat maps response_format to a json_schema constraint: any JSON object for json_object,
+// the given schema for json_schema. llama-server takes a single constraint, so one already set by
+// the request's own grammar or json_schema, applied first by name, is an error.
+func llamaCppFormat(payload map[string]interface{}, value interface{}) error {
+	format, _ := value.(map[string]interface{})
+	var schema interface{}
+	switch format["type"] {
+	case "json_object":
+		schema = map[string]interface{}{"type": "object"}
+	case "json_schema":
+		spec, _ := format["json_schema"].(map[string]interface{})
+		if schema = spec["schema"]; schema == nil {
+			schema = map[string]interface{}{"type": "object"}
+		}
+	default:
+		return nil
+	}
+	if _, ok := payload["grammar"]; ok {
+		return errors.New("can't be combined with grammar")
+	}
+	if _, ok := payload["json_schema"]; ok {
+		return errors.New("can't be combined with json_schema")
+	}
+	payload["json_schema"] = schema
+	return nil
+}
+
+// LlamaCppProvider implements the Provider interface for llama.cpp's server.
+type LlamaCppProvider struct {
+	*OpenAIProvider
+}
+
+// NewLlamaCppProvider creates a new llama.cpp provider instance.
+func NewLlamaCppProvider(cfg *config.Provider) *LlamaCppProvider {
+	provider := NewOpenAIProvider(cfg)
+	provider.params = func(string) *paramRules { return llamaCppParams }
+	return &LlamaCppProvider{OpenAIProvider: provider}
+}
@@ -0,0 +1,77 @@
+package providers
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestLlamaCppProvider_Constraints(t *testing.T) {
+	schema := map[string]interface{}{
+		"type":       "object",
+		"properties": map[string]interface{}{"city": map[string]interface{}{"type": "string"}},
+	}
+	grammar := `root ::= "yes" | "no"`
+	tests := []struct {
+		name     string
+		params   map[string]interface{}
+		expected map[string]interface{}
+		err      string
+	}{
+		{
+			name:     "grammar passes through",
+			params:   map[string]interface{}{"grammar": grammar, "top_k": 40.0},
+			expected: map[string]interface{}{"grammar": grammar, "top_k": 40.0},
+		},
+		{
+			name: "json_schema format",
+			params: map[string]interface{}{"response_format": map[string]interface{}{
+				"type": "json_schema", "json_schema": map[string]interface{}{"name": "place", "schema": schema},
+			}},
+			expected: map[string]interface{}{"json_schema": schema},
+		},
+		{
+			name:     "json_object format",
+			params:   map[string]interface{}{"response_format": map[string]interface{}{"type": "json_object"}},
+			expected: map[string]interface{}{"json_schema": map[string]interface{}{"type": "object"}},
+		},
+		{
+			name:     "text format",
+			params:   map[string]interface{}{"response_format": map[string]interface{}{"type": "text"}},
+			expected: map[string]interface{}{},
+		},
+		{
+			name: "format and grammar",
+			params: map[string]interface{}{
+				"grammar": grammar, "response_format": map[string]interface{}{"type": "json_object"},
+			},
+			err: "can't be combined with grammar",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, body := captureServer(t)
+			provider := NewLlamaCppProvider(&config.Provider{Name: "llamacpp", BaseURL: server.URL})
+			ctx, params := paramsContext(t.Context(), tt.params, nil)
+
+			_, err := provider.ChatCompletion(ctx, "qwen2.5-7b-instruct", userMessage)
+			if tt.err != "" {
+				var invalid *InvalidParamError
+				require.True(t, errors.As(err, &invalid), "got %v", err)
+				assert.Equal(t, "response_format", invalid.Param)
+				assert.Equal(t, tt.err, invalid.Reason)
+				return
+			}
+			require.NoError(t, err)
+			for _, key := range []string{"model", "messages"} {
+				delete(*body, key)
+			}
+			assert.Equal(t, tt.expected, *body)
+			assert.Empty(t, params.Warnings())
+		})
+	}
+}
@@ -0,0 +1,63 @@
+// Package providers implements AI provider abstractions.
+// MistralProvider serves Mistral AI's La Plateforme, whose chat API is OpenAI's with these
+// differences:
+// - Unknown fields are rejected, so parameters Mistral lacks, such as logit_bias or user, are
+// unsupported rather than passed through; seed is sent as random_seed
+// - tool_choice "required" is called "any"
+// - There is no completions endpoint for chat models, so text completions go through chat
+// - Without configured models, the served ones are listed from /models
+package providers
+
+import (
+	"context"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// mistralParams adapts OpenAI parameters to Mistral's chat API.
+var mistralParams = &paramRules{
+	rules: map[string]paramRule{
+		"seed": {set: rename("random_seed")},
+		// Parameters are applied in name order, so max_tokens overwrites this when both are sent
+		"max_completion_tokens": {set: rename("max_tokens")},
+		"tool_choice": {set: func(payload map[string]interface{}, value interface{}) error {
+			if value == "required" {
+				value = "any"
+			}
+			payload["tool_choice"] = value
+			return nil
+		}},
+		"logit_bias":     {},
+		"logprobs":       {},
+		"top_logprobs":   {},
+		"user":           {},
+		"store":          {},
+		"service_tier":   {},
+		"stream_options": {},
+	},
+	passthrough: true,
+}
+
+// MistralProvider implements the Provider interface for Mistral AI.
+type MistralProvider struct {
+	*OpenAICompatibleProvider
+}
+
+// NewMistralProvider creates a new Mistral AI provider instance.
+func NewMistralProvider(cfg *config.Provider) *MistralProvider {
+	provider := NewOpenAICompatibleProvider(cfg)
+	provider.params = func(string) *paramRules { return mistralParams }
+	return &MistralProvider{OpenAICompatibleProvider: provider}
+}
+
+// Completion performs a completion request by sending the prompt as a user message, and returns
+// the reply in the legacy text completion schema.
+func (p *MistralProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	return completionThroughChat(ctx, p.ChatCompletion, model, prompt)
+}
+
+// CompletionStream performs a streaming completion request, streaming the reply as legacy text
+// completion chunks.
+func (p *MistralProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	return completionStreamThroughChat(ctx, p.ChatCompletionStream, model, prompt)
+}
@@ -0,0 +1,86 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestMistralProvider(t *testing.T) {
+	var requests []*http.Request
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.Method == "GET" {
+			_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"mistral-large-latest"},{"id":"codestral-latest"}]}`))
+			return
+		}
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+		if payload["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"id\":\"c2\",\"model\":\"mistral-small-latest\","+
+				"\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hi\"}}]}\n\n"+
+				"data: {\"id\":\"c2\",\"model\":\"mistral-small-latest\","+
+				"\"choices\":[{\"index\":0,\"delta\":{\"content\":\"\"},\"finish_reason\":\"stop\"}]}\n\n"+
+				"data: [DONE]\n\n")
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"c1","object":"chat.completion","model":"mistral-small-latest",` +
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"Hi there"},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(&config.Provider{Name: "mistral", Type: "mistral", BaseURL: server.URL, APIKey: "key"})
+
+	models, err := provider.ListModels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"mistral-large-latest", "codestral-latest"}, ModelIDs(models))
+	assert.Equal(t, "/models", requests[0].URL.Path)
+
+	ctx := WithParams(t.Context(), NewParams(map[string]interface{}{
+		"seed": float64(7), "tool_choice": "required", "max_completion_tokens": float64(64), "temperature": 0.3,
+		"logit_bias": map[string]interface{}{"50256": float64(-100)},
+	}, nil))
+	result, err := provider.ChatCompletion(ctx, "mistral-small-latest", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "/chat/completions", requests[1].URL.Path)
+	assert.Equal(t, "Bearer key", requests[1].Header.Get("Authorization"))
+	assert.Equal(t, float64(7), payloads[0]["random_seed"])
+	assert.Equal(t, "any", payloads[0]["tool_choice"])
+	assert.Equal(t, float64(64), payloads[0]["max_tokens"])
+	assert.Equal(t, 0.3, payloads[0]["temperature"])
+	for _, name := range []string{"seed", "max_completion_tokens", "logit_bias"} {
+		assert.NotContains(t, payloads[0], name, "Mistral rejects unknown fields")
+	}
+	assert.Equal(t, []string{`parameter "logit_bias" was dropped, provider mistral can't honor it`},
+		ParamsFrom(ctx).Warnings())
+	choice := result.(map[string]interface{})["choices"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Hi there", choice["message"].(map[string]interface{})["content"])
+
+	completion, err := provider.Completion(t.Context(), "mistral-small-latest", "Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "/chat/completions", requests[2].URL.Path, "text completions go through chat")
+	assert.Equal(t, []interface{}{map[string]interface{}{"role": "user", "content": "Hello"}}, payloads[1]["messages"])
+	c := completion.(map[string]interface{})
+	assert.Equal(t, "text_completion", c["object"])
+	assert.Equal(t, "Hi there", c["choices"].([]interface{})[0].(map[string]interface{})["text"])
+
+	stream, err := provider.CompletionStream(t.Context(), "mistral-small-latest", "Hello")
+	require.NoError(t, err)
+	var texts []interface{}
+	for chunk := range stream {
+		for _, choice := range chunk.(map[string]interface{})["choices"].([]interface{}) {
+			texts = append(texts, choice.(map[string]interface{})["text"])
+		}
+	}
+	assert.Equal(t, []interface{}{"Hi", ""}, texts)
+}
@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+)
+
+// mappedProvider serves public model names under the names the backend knows them by, and
+// reports the public name back in responses, so clients never see the backend's names.
+type mappedProvider struct {
+	Provider
+	// upstream maps public model names to the backend's
+	upstream map[string]string
+}
+
+// ChatCompletion performs a chat completion with the backend's name for model.
+func (p *mappedProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	upstream, mapped := p.resolve(model)
+	result, err := p.Provider.ChatCompletion(ctx, upstream, messages)
+	if err != nil || !mapped {
+		return result, err
+	}
+	return renameModel(result, model), nil
+}
+
+// Completion performs a completion with the backend's name for model.
+func (p *mappedProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	upstream, mapped := p.resolve(model)
+	result, err := p.Provider.Completion(ctx, upstream, prompt)
+	if err != nil || !mapped {
+		return result, err
+	}
+	return renameModel(result, model), nil
+}
+
+// ChatCompletionStream performs a streaming chat completion with the backend's name for model.
+func (p *mappedProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	upstream, mapped := p.resolve(model)
+	stream, err := p.Provider.ChatCompletionStream(ctx, upstream, messages)
+	if err != nil || !mapped {
+		return stream, err
+	}
+	return renameStream(ctx, stream, model), nil
+}
+
+// CompletionStream performs a streaming completion with the backend's name for model.
+func (p *mappedProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	upstream, mapped := p.resolve(model)
+	stream, err := p.Provider.CompletionStream(ctx, upstream, prompt)
+	if err != nil || !mapped {
+		return stream, err
+	}
+	return renameStream(ctx, stream, model), nil
+}
+
+// Forward implements RawForwarder. Raw requests are passed on as they are, so they must use the
+// backend's model names.
+func (p *mappedProvider) Forward(req *http.Request, path string) (*http.Response, error) {
+	return forwardThrough(p.Provider, req, path)
+}
+
+func (p *mappedProvider) resolve(model string) (string, bool) {
+	if upstream, ok := p.upstream[model]; ok {
+		return upstream, true
+	}
+	return model, false
+}
+
+// renameModel replaces the model a response names, at the top level or, for Anthropic stream
+// events, in the message, with model. Responses are fresh from the backend, so it works in place.
+func renameModel(result interface{}, model string) interface{} {
+	response, ok := result.(map[string]interface{})
+	if !ok {
+		return result
+	}
+	if _, ok := response["model"]; ok {
+		response["model"] = model
+	}
+	if message, ok := response["message"].(map[string]interface{}); ok && response["type"] == "message_start" {
+		message["model"] = model
+	}
+	return response
+}
+
+func renameStream(ctx context.Context, stream <-chan interface{}, model string) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		for chunk := range stream {
+			select {
+			case out <- renameModel(chunk, model):
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
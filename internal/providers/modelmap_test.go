@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestModelMap(t *testing.T) {
+	var requested []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		model, _ := req["model"].(string)
+		requested = append(requested, model)
+
+		if req["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprintf(w, "data: {\"model\":%q,\"choices\":[{\"index\":0,\"delta\":{\"content\":\"Hi\"}}]}\n\n", model)
+			_, _ = fmt.Fprint(w, "data: [DONE]\n\n")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = fmt.Fprintf(w, `{"model":%q,"choices":[]}`, model)
+	}))
+	defer server.Close()
+
+	provider := NewProvider(&config.Provider{
+		Name:     "local",
+		Type:     "openai",
+		BaseURL:  server.URL,
+		Models:   []string{"gpt-4o-mini", "llama2"},
+		ModelMap: map[string]string{"gpt-4o-mini": "llama3.1:8b-instruct"},
+	})
+
+	result, err := provider.ChatCompletion(t.Context(), "gpt-4o-mini", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o-mini", result.(map[string]interface{})["model"])
+
+	stream, err := provider.ChatCompletionStream(t.Context(), "gpt-4o-mini", userMessage)
+	require.NoError(t, err)
+	for chunk := range stream {
+		assert.Equal(t, "gpt-4o-mini", chunk.(map[string]interface{})["model"])
+	}
+
+	// Models without an entry are requested as they are
+	result, err = provider.ChatCompletion(t.Context(), "llama2", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "llama2", result.(map[string]interface{})["model"])
+
+	assert.Equal(t, []string{"llama3.1:8b-instruct", "llama3.1:8b-instruct", "llama2"}, requested)
+	assert.Equal(t, []string{"gpt-4o-mini", "llama2"}, listModelIDs(t, provider))
+}
+
+func TestRenameModel_AnthropicEvents(t *testing.T) {
+	event := map[string]interface{}{
+		"type":    "message_start",
+		"message": map[string]interface{}{"id": "msg_1", "model": "claude-3-5-haiku-latest"},
+	}
+	renamed := renameModel(event, "fast")
+	assert.Equal(t, "fast", renamed.(map[string]interface{})["message"].(map[string]interface{})["model"])
+	assert.NotContains(t, renamed, "model")
+}
@@ -11,13 +11,49 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
 
 	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/pkg/convert"
 )
 
+// ollamaParams translates OpenAI request parameters to Ollama's model options.
+// logit_bias, n and logprobs have no Ollama equivalent.
+var ollamaParams = &paramRules{rules: map[string]paramRule{
+	"temperature":           {set: option("temperature")},
+	"top_p":                 {set: option("top_p")},
+	"seed":                  {set: option("seed")},
+	"presence_penalty":      {set: option("presence_penalty")},
+	"frequency_penalty":     {set: option("frequency_penalty")},
+	"max_tokens":            {set: option("num_predict")},
+	"max_completion_tokens": {set: option("num_predict")},
+	"stop": stopRule(stopSpec{set: func(payload map[string]interface{}, sequences []string) {
+		setOption(payload, "stop", sequences)
+	}}),
+	"response_format": {set: ollamaFormat},
+	// Turns thinking on or off for reasoning models; their thinking comes back in a separate field
+	"think": {set: rename("think")},
+	// How long the model stays loaded after the request, e.g. "10m", or 0 to unload it right away
+	"keep_alive": {set: rename("keep_alive")},
+}}
+
+// ollamaFormat maps response_format to Ollama's format, "json" or a JSON schema.
+func ollamaFormat(payload map[string]interface{}, value interface{}) error {
+	format, _ := value.(map[string]interface{})
+	switch format["type"] {
+	case "json_object":
+		payload["format"] = "json"
+	case "json_schema":
+		if spec, ok := format["json_schema"].(map[string]interface{}); ok && spec["schema"] != nil {
+			payload["format"] = spec["schema"]
+		} else {
+			payload["format"] = "json"
+		}
+	}
+	return nil
+}
+
 // OllamaProvider implements the Provider interface for Ollama local API.
 type OllamaProvider struct {
 	name     string
@@ -34,7 +70,7 @@ func NewOllamaProvider(cfg *config.Provider) *OllamaProvider {
 		baseURL:  cfg.BaseURL,
 		models:   cfg.Models,
 		priority: cfg.Priority,
-		client:   &http.Client{},
+		client:   newHTTPClient(cfg),
 	}
 }
 
@@ -48,9 +84,9 @@ func (p *OllamaProvider) Priority() int {
 	return p.priority
 }
 
-// ListModels returns the list of available models for this provider.
-func (p *OllamaProvider) ListModels() []string {
-	return p.models
+// ListModels returns the configured models of this provider.
+func (p *OllamaProvider) ListModels(_ context.Context) ([]Model, error) {
+	return modelsOf(p.models), nil
 }
 
 // ChatCompletion performs a chat completion request with Ollama-specific parameters.
@@ -59,10 +95,14 @@ func (p *OllamaProvider) ChatCompletion(
 ) (interface{}, error) {
 	payload := map[string]interface{}{
 		"model":    model,
-		"messages": messages,
+		"messages": convert.OpenAIToOllamaMessages(messages),
 		"stream":   false,
 	}
 
+	if err := applyParams(ctx, p.name, payload, ollamaParams); err != nil {
+		return nil, err
+	}
+
 	return p.makeRequest(ctx, "/api/chat", payload)
 }
 
@@ -74,6 +114,10 @@ func (p *OllamaProvider) Completion(ctx context.Context, model, prompt string) (
 		"stream": false,
 	}
 
+	if err := applyParams(ctx, p.name, payload, ollamaParams); err != nil {
+		return nil, err
+	}
+
 	return p.makeRequest(ctx, "/api/generate", payload)
 }
 
@@ -102,7 +146,7 @@ func (p *OllamaProvider) makeRequest(ctx context.Context, endpoint string, paylo
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result interface{}
@@ -113,16 +157,52 @@ func (p *OllamaProvider) makeRequest(ctx context.Context, endpoint string, paylo
 	return result, nil
 }
 
+// LoadedModels implements ResourceReporter with the models Ollama lists at /api/ps.
+func (p *OllamaProvider) LoadedModels(ctx context.Context) ([]LoadedModel, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/api/ps", http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	var result struct {
+		Models []LoadedModel `json:"models"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Models, nil
+}
+
+// Unload implements ModelUnloader with a generate request that loads nothing and keeps the model
+// alive for no time, which is how Ollama is asked to free a model's memory.
+func (p *OllamaProvider) Unload(ctx context.Context, model string) error {
+	_, err := p.makeRequest(ctx, "/api/generate", map[string]interface{}{"model": model, "keep_alive": 0})
+	return err
+}
+
 // ChatCompletionStream performs a streaming chat completion request.
 func (p *OllamaProvider) ChatCompletionStream(
 	ctx context.Context, model string, messages []map[string]interface{},
 ) (<-chan interface{}, error) {
 	payload := map[string]interface{}{
 		"model":    model,
-		"messages": messages,
+		"messages": convert.OpenAIToOllamaMessages(messages),
 		"stream":   true, // Enable streaming for Ollama
 	}
 
+	if err := applyParams(ctx, p.name, payload, ollamaParams); err != nil {
+		return nil, err
+	}
+
 	return p.makeStreamingRequest(ctx, "/api/chat", payload)
 }
 
@@ -134,6 +214,10 @@ func (p *OllamaProvider) CompletionStream(ctx context.Context, model, prompt str
 		"stream": true, // Enable streaming for Ollama
 	}
 
+	if err := applyParams(ctx, p.name, payload, ollamaParams); err != nil {
+		return nil, err
+	}
+
 	return p.makeStreamingRequest(ctx, "/api/generate", payload)
 }
 
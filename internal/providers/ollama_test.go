@@ -25,7 +25,7 @@ func TestNewOllamaProvider(t *testing.T) {
 
 	assert.Equal(t, "local", provider.Name())
 	assert.Equal(t, "http://localhost:11434", provider.baseURL)
-	assert.Equal(t, []string{"llama2", "codellama"}, provider.ListModels())
+	assert.Equal(t, []string{"llama2", "codellama"}, listModelIDs(t, provider))
 	assert.Equal(t, 3, provider.Priority())
 }
 
@@ -162,3 +162,41 @@ func TestOllamaProvider_Error(t *testing.T) {
 	assert.Nil(t, result)
 	assert.Contains(t, err.Error(), "404")
 }
+
+func TestOllamaProvider_LoadedModels(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "GET", r.Method)
+		assert.Equal(t, "/api/ps", r.URL.Path)
+		_, _ = w.Write([]byte(`{"models":[{"name":"llama2:latest","model":"llama2:latest","size":5137025024,` +
+			`"size_vram":4000000000,"digest":"78e26419b446","expires_at":"2024-06-04T14:38:31.83753-07:00"}]}`))
+	}))
+	defer server.Close()
+
+	reporter := NewResourceReporter(&config.Provider{Name: "local", Type: "ollama", BaseURL: server.URL})
+	require.NotNil(t, reporter)
+	models, err := reporter.LoadedModels(context.Background())
+	require.NoError(t, err)
+	require.Len(t, models, 1)
+	assert.Equal(t, "llama2:latest", models[0].Name)
+	assert.Equal(t, int64(5137025024), models[0].Size)
+	assert.Equal(t, int64(4000000000), models[0].SizeVRAM)
+	assert.Equal(t, 2024, models[0].ExpiresAt.Year())
+
+	assert.Nil(t, NewResourceReporter(&config.Provider{Type: "openai"}))
+}
+
+func TestOllamaProvider_Unload(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/api/generate", r.URL.Path)
+		var req map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&req))
+		assert.Equal(t, map[string]interface{}{"model": "llama2", "keep_alive": float64(0)}, req)
+		_, _ = w.Write([]byte(`{"model":"llama2","response":"","done":true,"done_reason":"unload"}`))
+	}))
+	defer server.Close()
+
+	unloader := NewModelUnloader(&config.Provider{Name: "local", Type: "ollama", BaseURL: server.URL})
+	require.NotNil(t, unloader)
+	require.NoError(t, unloader.Unload(context.Background(), "llama2"))
+	assert.Nil(t, NewModelUnloader(&config.Provider{Type: "anthropic"}))
+}
@@ -8,15 +8,62 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"fmt"
 	"io"
 	"net/http"
-	"os"
 	"strings"
 
 	"github.com/modelplex/modelplex/internal/config"
 )
 
+// openAIMaxStop is the most stop sequences the OpenAI API accepts.
+const openAIMaxStop = 4
+
+// openAIParams passes parameters through, since they are OpenAI's own; stop is checked up front
+// so too many sequences get a clear error instead of an upstream failure.
+var openAIParams = &paramRules{
+	rules: map[string]paramRule{
+		"stop": stopRule(stopSpec{max: openAIMaxStop, set: func(payload map[string]interface{}, sequences []string) {
+			payload["stop"] = sequences
+		}}),
+	},
+	passthrough: true,
+}
+
+// openAIReasoningParams adapts parameters for OpenAI reasoning models, which take max_completion_tokens
+// instead of max_tokens and reject sampling parameters; reasoning_effort passes through.
+var openAIReasoningParams = &paramRules{
+	rules: map[string]paramRule{
+		// Parameters are applied in name order, so max_completion_tokens is already set when both are sent
+		"max_tokens": {set: func(payload map[string]interface{}, value interface{}) error {
+			if _, ok := payload["max_completion_tokens"]; !ok {
+				payload["max_completion_tokens"] = value
+			}
+			return nil
+		}},
+		"temperature":       {},
+		"top_p":             {},
+		"presence_penalty":  {},
+		"frequency_penalty": {},
+		"logit_bias":        {},
+		"logprobs":          {},
+		"top_logprobs":      {},
+		"stop":              {},
+	},
+	passthrough: true,
+}
+
+// openAIParamsFor returns the parameter rules for model: reasoning models are the o-series,
+// e.g. o1 or o3-mini, and the GPT-5 family apart from its chat models.
+func openAIParamsFor(model string) *paramRules {
+	name := strings.ToLower(model)
+	oSeries := len(name) > 1 && name[0] == 'o' && name[1] >= '0' && name[1] <= '9'
+	gpt5 := strings.HasPrefix(name, "gpt-5") && !strings.Contains(name, "-chat")
+	if oSeries || gpt5 {
+		return openAIReasoningParams
+	}
+	return openAIParams
+}
+
 // OpenAIProvider implements the Provider interface for OpenAI API.
 type OpenAIProvider struct {
 	name     string
@@ -25,23 +72,28 @@ type OpenAIProvider struct {
 	models   []string
 	priority int
 	client   *http.Client
+	tokens   *tokenSource // nil when authenticating with the static API key
+	// params returns the parameter rules for a model
+	params func(model string) *paramRules
+	// path returns the path below the base URL of an endpoint such as /chat/completions for a model
+	path func(model, endpoint string) string
+	// keyHeader carries the static API key as it is, e.g. Azure's api-key; empty sends it as a bearer token
+	keyHeader string
 }
 
 // NewOpenAIProvider creates a new OpenAI provider instance.
 func NewOpenAIProvider(cfg *config.Provider) *OpenAIProvider {
-	apiKey := cfg.APIKey
-	if strings.HasPrefix(apiKey, "${") && strings.HasSuffix(apiKey, "}") {
-		envVar := strings.TrimSuffix(strings.TrimPrefix(apiKey, "${"), "}")
-		apiKey = os.Getenv(envVar)
-	}
-
 	return &OpenAIProvider{
 		name:     cfg.Name,
 		baseURL:  cfg.BaseURL,
-		apiKey:   apiKey,
+		apiKey:   resolveEnv(cfg.APIKey),
 		models:   cfg.Models,
 		priority: cfg.Priority,
-		client:   &http.Client{},
+		client:   newHTTPClient(cfg),
+		// The token endpoint is not the gateway, so it doesn't get the extras
+		tokens: newTokenSource(&cfg.Auth, &http.Client{}),
+		params: openAIParamsFor,
+		path:   func(_, endpoint string) string { return endpoint },
 	}
 }
 
@@ -55,9 +107,9 @@ func (p *OpenAIProvider) Priority() int {
 	return p.priority
 }
 
-// ListModels returns the list of available models for this provider.
-func (p *OpenAIProvider) ListModels() []string {
-	return p.models
+// ListModels returns the configured models of this provider.
+func (p *OpenAIProvider) ListModels(_ context.Context) ([]Model, error) {
+	return modelsOf(p.models), nil
 }
 
 // ChatCompletion performs a chat completion request.
@@ -69,7 +121,11 @@ func (p *OpenAIProvider) ChatCompletion(
 		"messages": messages,
 	}
 
-	return p.makeRequest(ctx, "/chat/completions", payload)
+	if err := applyParams(ctx, p.name, payload, p.params(model)); err != nil {
+		return nil, err
+	}
+
+	return p.makeRequest(ctx, p.path(model, "/chat/completions"), payload)
 }
 
 // Completion performs a completion request.
@@ -79,7 +135,11 @@ func (p *OpenAIProvider) Completion(ctx context.Context, model, prompt string) (
 		"prompt": prompt,
 	}
 
-	return p.makeRequest(ctx, "/completions", payload)
+	if err := applyParams(ctx, p.name, payload, p.params(model)); err != nil {
+		return nil, err
+	}
+
+	return p.makeRequest(ctx, p.path(model, "/completions"), payload)
 }
 
 func (p *OpenAIProvider) makeRequest(ctx context.Context, endpoint string, payload interface{}) (interface{}, error) {
@@ -93,8 +153,15 @@ func (p *OpenAIProvider) makeRequest(ctx context.Context, endpoint string, paylo
 		return nil, err
 	}
 
+	headers, err := p.authHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("Authorization", "Bearer "+p.apiKey)
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
 
 	resp, err := p.client.Do(req)
 	if err != nil {
@@ -108,7 +175,7 @@ func (p *OpenAIProvider) makeRequest(ctx context.Context, endpoint string, paylo
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	var result interface{}
@@ -129,7 +196,11 @@ func (p *OpenAIProvider) ChatCompletionStream(
 		"stream":   true,
 	}
 
-	return p.makeStreamingRequest(ctx, "/chat/completions", payload)
+	if err := applyParams(ctx, p.name, payload, p.params(model)); err != nil {
+		return nil, err
+	}
+
+	return p.makeStreamingRequest(ctx, p.path(model, "/chat/completions"), payload)
 }
 
 // CompletionStream performs a streaming completion request.
@@ -140,21 +211,49 @@ func (p *OpenAIProvider) CompletionStream(ctx context.Context, model, prompt str
 		"stream": true,
 	}
 
-	return p.makeStreamingRequest(ctx, "/completions", payload)
+	if err := applyParams(ctx, p.name, payload, p.params(model)); err != nil {
+		return nil, err
+	}
+
+	return p.makeStreamingRequest(ctx, p.path(model, "/completions"), payload)
 }
 
 func (p *OpenAIProvider) makeStreamingRequest(ctx context.Context, endpoint string,
 	payload interface{}) (<-chan interface{}, error) {
+	headers, err := p.authHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	reqConfig := StreamingRequestConfig{
-		BaseURL:  p.baseURL,
-		Endpoint: endpoint,
-		Payload:  payload,
-		Headers: map[string]string{
-			"Authorization": "Bearer " + p.apiKey,
-		},
+		BaseURL:     p.baseURL,
+		Endpoint:    endpoint,
+		Payload:     payload,
+		Headers:     headers,
 		UseSSE:      true,
 		Transformer: nil, // OpenAI doesn't need response transformation
 	}
 
 	return makeStreamingRequest(ctx, p.client, reqConfig)
 }
+
+// authHeaders returns the Authorization header, using an OAuth2 token when configured, or the
+// static key in keyHeader when set. Without a key none is sent.
+func (p *OpenAIProvider) authHeaders(ctx context.Context) (map[string]string, error) {
+	if p.tokens == nil && p.apiKey == "" {
+		return nil, nil
+	}
+	if p.tokens == nil && p.keyHeader != "" {
+		return map[string]string{p.keyHeader: p.apiKey}, nil
+	}
+	credential := p.apiKey
+	if p.tokens != nil {
+		token, err := p.tokens.Token(ctx)
+		if err != nil {
+			return nil, err
+		}
+		credential = token
+	}
+
+	return map[string]string{"Authorization": "Bearer " + credential}, nil
+}
@@ -80,7 +80,7 @@ func TestNewOpenAIProvider(t *testing.T) {
 			assert.Equal(t, tt.expected.name, provider.Name())
 			assert.Equal(t, tt.expected.baseURL, provider.baseURL)
 			assert.Equal(t, tt.expected.apiKey, provider.apiKey)
-			assert.Equal(t, tt.expected.models, provider.ListModels())
+			assert.Equal(t, tt.expected.models, listModelIDs(t, provider))
 			assert.Equal(t, tt.expected.priority, provider.Priority())
 		})
 	}
@@ -0,0 +1,57 @@
+// Package providers implements AI provider abstractions.
+// OpenRouterProvider serves OpenRouter, which routes OpenAI's chat API to models of many vendors,
+// with these differences from OpenAI itself:
+// - Models are vendor/model slugs, e.g. anthropic/claude-sonnet-4, sent as they are; without
+// configured models the provider also serves any slug no other provider claims
+// - The app's URL and name, from openrouter.referer and openrouter.title, are sent as the
+// HTTP-Referer and X-Title attribution headers
+// - Parameters pass through, OpenRouter's own such as provider routing preferences included
+// - Usage carries the request's cost in credits, which usage events report instead of a price
+// - Without configured models, the served ones are listed from /models
+package providers
+
+import (
+	"maps"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// OpenRouterProvider implements the Provider interface for OpenRouter.
+type OpenRouterProvider struct {
+	*OpenAICompatibleProvider
+}
+
+// NewOpenRouterProvider creates a new OpenRouter provider instance.
+func NewOpenRouterProvider(cfg *config.Provider) *OpenRouterProvider {
+	return &OpenRouterProvider{OpenAICompatibleProvider: NewOpenAICompatibleProvider(withAttribution(cfg))}
+}
+
+// withAttribution returns cfg with the attribution headers among its extra headers, where they go
+// out on every request. Extra headers of the same name take precedence.
+func withAttribution(cfg *config.Provider) *config.Provider {
+	attribution := map[string]string{}
+	if cfg.OpenRouter.Referer != "" {
+		attribution["HTTP-Referer"] = cfg.OpenRouter.Referer
+	}
+	if cfg.OpenRouter.Title != "" {
+		attribution["X-Title"] = cfg.OpenRouter.Title
+	}
+	for name := range cfg.ExtraHeaders {
+		maps.DeleteFunc(attribution, func(header, _ string) bool { return strings.EqualFold(header, name) })
+	}
+	if len(attribution) == 0 {
+		return cfg
+	}
+
+	attributed := *cfg
+	attributed.ExtraHeaders = attribution
+	maps.Copy(attributed.ExtraHeaders, cfg.ExtraHeaders)
+	return &attributed
+}
+
+// ServesSlugs reports whether providers of cfg serve any vendor/model slug, not only their models:
+// OpenRouter providers without configured models do.
+func ServesSlugs(cfg *config.Provider) bool {
+	return cfg.Type == "openrouter" && len(cfg.Models) == 0
+}
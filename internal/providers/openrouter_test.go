@@ -0,0 +1,65 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestOpenRouterProvider(t *testing.T) {
+	var requests []*http.Request
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.Method == "GET" {
+			_, _ = w.Write([]byte(`{"data":[{"id":"anthropic/claude-sonnet-4"},{"id":"openai/gpt-4o"}]}`))
+			return
+		}
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+		_, _ = w.Write([]byte(`{"id":"gen-1","object":"chat.completion","model":"anthropic/claude-sonnet-4",` +
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"Hi"},"finish_reason":"stop"}],` +
+			`"usage":{"prompt_tokens":4,"completion_tokens":1,"total_tokens":5,"cost":0.00002}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(&config.Provider{
+		Name: "openrouter", Type: "openrouter", BaseURL: server.URL, APIKey: "sk-or-key",
+		OpenRouter:   config.ProviderOpenRouter{Referer: "https://agents.example.com", Title: "Agents"},
+		ExtraHeaders: map[string]string{"x-title": "Agents (staging)"},
+	})
+	require.NotNil(t, provider)
+
+	models, err := provider.ListModels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"anthropic/claude-sonnet-4", "openai/gpt-4o"}, ModelIDs(models))
+
+	ctx := WithParams(t.Context(), NewParams(map[string]interface{}{
+		"provider": map[string]interface{}{"order": []interface{}{"anthropic"}},
+	}, nil))
+	result, err := provider.ChatCompletion(ctx, "anthropic/claude-sonnet-4", userMessage)
+	require.NoError(t, err)
+	usage := result.(map[string]interface{})["usage"].(map[string]interface{})
+	assert.Equal(t, 0.00002, usage["cost"])
+
+	chat := requests[1]
+	assert.Equal(t, "/chat/completions", chat.URL.Path)
+	assert.Equal(t, "Bearer sk-or-key", chat.Header.Get("Authorization"))
+	assert.Equal(t, "https://agents.example.com", chat.Header.Get("HTTP-Referer"))
+	assert.Equal(t, "Agents (staging)", chat.Header.Get("X-Title"), "extra headers take precedence")
+	assert.Equal(t, "anthropic/claude-sonnet-4", payloads[0]["model"], "slugs are sent as they are")
+	assert.Equal(t, map[string]interface{}{"order": []interface{}{"anthropic"}}, payloads[0]["provider"])
+}
+
+func TestServesSlugs(t *testing.T) {
+	assert.True(t, ServesSlugs(&config.Provider{Type: "openrouter"}))
+	assert.False(t, ServesSlugs(&config.Provider{Type: "openrouter", Models: []string{"openai/gpt-4o"}}))
+	assert.False(t, ServesSlugs(&config.Provider{Type: "openai-compatible"}))
+}
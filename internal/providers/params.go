@@ -0,0 +1,299 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"maps"
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// WarningHeader carries a warning about the request to the client, e.g. a dropped parameter.
+const WarningHeader = "X-Modelplex-Warning"
+
+type paramsKey struct{}
+
+// Params carries the request parameters beyond the model, messages and prompt, such as temperature,
+// from the API handler to the provider that serves the request, and collects the warnings the
+// provider raises while translating them.
+type Params struct {
+	values   map[string]interface{}
+	policies *config.ParametersConfig
+	// warnings is shared with the params derived by Override, so the handler sees every warning
+	warnings *warningLog
+}
+
+type warningLog struct {
+	mtx      sync.Mutex
+	warnings []string
+}
+
+// NewParams creates params from the request's extra fields; parameters a provider can't honor
+// are handled as policies says, and dropped with a warning when policies is nil.
+func NewParams(values map[string]interface{}, policies *config.ParametersConfig) *Params {
+	if policies == nil {
+		policies = &config.ParametersConfig{Unsupported: config.ParameterPolicyWarn}
+	}
+	return &Params{values: values, policies: policies, warnings: &warningLog{}}
+}
+
+// Override returns params with overrides replacing the values of the same name. Warnings raised
+// with the result are reported with p's. Overriding nil params applies the default policy.
+func (p *Params) Override(overrides map[string]interface{}) *Params {
+	if p == nil {
+		p = NewParams(nil, nil)
+	}
+	values := maps.Clone(p.values)
+	if values == nil {
+		values = make(map[string]interface{}, len(overrides))
+	}
+	maps.Copy(values, overrides)
+	return &Params{values: values, policies: p.policies, warnings: p.warnings}
+}
+
+// WithParams returns a context carrying params to the provider.
+func WithParams(ctx context.Context, params *Params) context.Context {
+	return context.WithValue(ctx, paramsKey{}, params)
+}
+
+// ParamsFrom returns the params carried by ctx, or nil when there are none.
+func ParamsFrom(ctx context.Context) *Params {
+	params, _ := ctx.Value(paramsKey{}).(*Params)
+	return params
+}
+
+// Values returns the request parameters. Callers must not modify them.
+// A nil Params has no values, so callers building keys from them needn't check.
+func (p *Params) Values() map[string]interface{} {
+	if p == nil {
+		return nil
+	}
+	return p.values
+}
+
+// Warnings returns the warnings raised so far, without duplicates.
+func (p *Params) Warnings() []string {
+	if p == nil {
+		return nil
+	}
+	p.warnings.mtx.Lock()
+	defer p.warnings.mtx.Unlock()
+	return slices.Clone(p.warnings.warnings)
+}
+
+func (p *Params) warn(warning string) {
+	p.warnings.mtx.Lock()
+	defer p.warnings.mtx.Unlock()
+	// Failover to another provider of the same kind raises the same warning again
+	if !slices.Contains(p.warnings.warnings, warning) {
+		p.warnings.warnings = append(p.warnings.warnings, warning)
+	}
+}
+
+// UnsupportedParamError reports a parameter a provider can't honor under the reject policy.
+type UnsupportedParamError struct {
+	Provider string
+	Param    string
+}
+
+func (e *UnsupportedParamError) Error() string {
+	return fmt.Sprintf("provider %s does not support parameter %q", e.Provider, e.Param)
+}
+
+// InvalidParamError reports a parameter value the serving provider can't accept, such as too many stop sequences.
+type InvalidParamError struct {
+	Provider string
+	Param    string
+	Reason   string
+}
+
+func (e *InvalidParamError) Error() string {
+	return fmt.Sprintf("parameter %q is invalid for provider %s: %s", e.Param, e.Provider, e.Reason)
+}
+
+// paramRule describes how a provider handles one OpenAI request parameter.
+type paramRule struct {
+	// set translates a supported parameter into the payload, returning the reason a value can't be
+	// translated; nil means the provider can't honor the parameter
+	set func(payload map[string]interface{}, value interface{}) error
+	// emulate approximates an unsupported parameter under the emulate policy; nil when infeasible
+	emulate func(payload map[string]interface{}, value interface{})
+}
+
+// paramRules describes how a provider handles OpenAI request parameters.
+type paramRules struct {
+	rules map[string]paramRule
+	// passthrough copies parameters without a rule as they are, for providers speaking the OpenAI API
+	passthrough bool
+}
+
+// applyParams translates the request parameters carried by ctx into payload following rules.
+// Parameters without a rule, or whose rule can't set them, are unsupported and follow their policy.
+func applyParams(ctx context.Context, provider string, payload map[string]interface{}, rules *paramRules) error {
+	params := ParamsFrom(ctx)
+	if params == nil {
+		return nil
+	}
+
+	// Sorted so emulations that build on each other, like system prompt additions, are deterministic
+	for _, name := range slices.Sorted(maps.Keys(params.values)) {
+		value := params.values[name]
+		rule, ok := rules.rules[name]
+		if !ok && rules.passthrough {
+			payload[name] = value
+			continue
+		}
+
+		if rule.set != nil {
+			if err := rule.set(payload, value); err != nil {
+				return &InvalidParamError{Provider: provider, Param: name, Reason: err.Error()}
+			}
+			continue
+		}
+
+		switch params.policies.Policy(name) {
+		case config.ParameterPolicyReject:
+			return &UnsupportedParamError{Provider: provider, Param: name}
+		case config.ParameterPolicyEmulate:
+			if rule.emulate != nil {
+				rule.emulate(payload, value)
+				slog.Debug("Emulating unsupported parameter", "provider", provider, "param", name)
+				params.warn(fmt.Sprintf("parameter %q is emulated by provider %s", name, provider))
+				continue
+			}
+			params.warn(fmt.Sprintf("parameter %q was dropped, provider %s can't honor or emulate it", name, provider))
+		default:
+			params.warn(fmt.Sprintf("parameter %q was dropped, provider %s can't honor it", name, provider))
+		}
+		slog.Debug("Dropping unsupported parameter", "provider", provider, "param", name)
+	}
+	return nil
+}
+
+// rename returns a setter that copies a parameter under the provider's name for it.
+func rename(name string) func(map[string]interface{}, interface{}) error {
+	return func(payload map[string]interface{}, value interface{}) error {
+		payload[name] = value
+		return nil
+	}
+}
+
+// paramNumber returns a numeric parameter value, which requests decoded with UseNumber carry as a
+// json.Number, as a float64; ok is false when value isn't a number.
+func paramNumber(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case float64:
+		return v, true
+	case json.Number:
+		f, err := v.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+// option returns a setter that copies a parameter into the payload's options object, as Ollama expects.
+func option(name string) func(map[string]interface{}, interface{}) error {
+	return func(payload map[string]interface{}, value interface{}) error {
+		setOption(payload, name, value)
+		return nil
+	}
+}
+
+func setOption(payload map[string]interface{}, name string, value interface{}) {
+	options, ok := payload["options"].(map[string]interface{})
+	if !ok {
+		options = make(map[string]interface{})
+		payload["options"] = options
+	}
+	options[name] = value
+}
+
+// stopSpec describes how a provider takes stop sequences.
+type stopSpec struct {
+	// max is the most sequences the provider accepts; 0 means no limit
+	max int
+	// requireText refuses sequences of only whitespace, which the provider rejects
+	requireText bool
+	// set stores the normalized sequences in the payload
+	set func(payload map[string]interface{}, sequences []string)
+}
+
+// stopRule normalizes stop, which clients send as a string or an array of strings, into an array
+// without empty or repeated sequences and checks it against the provider's limits.
+func stopRule(spec stopSpec) paramRule {
+	return paramRule{set: func(payload map[string]interface{}, value interface{}) error {
+		var raw []interface{}
+		switch v := value.(type) {
+		case nil:
+			return nil
+		case string:
+			raw = []interface{}{v}
+		case []interface{}:
+			raw = v
+		default:
+			return fmt.Errorf("must be a string or an array of strings")
+		}
+
+		sequences := make([]string, 0, len(raw))
+		for _, item := range raw {
+			sequence, ok := item.(string)
+			if !ok {
+				return fmt.Errorf("must be a string or an array of strings")
+			}
+			if sequence == "" || slices.Contains(sequences, sequence) {
+				continue
+			}
+			if spec.requireText && strings.TrimSpace(sequence) == "" {
+				return fmt.Errorf("stop sequence %q is only whitespace, which the provider doesn't accept", sequence)
+			}
+			sequences = append(sequences, sequence)
+		}
+
+		if spec.max > 0 && len(sequences) > spec.max {
+			return fmt.Errorf("at most %d stop sequences are supported, got %d", spec.max, len(sequences))
+		}
+		if len(sequences) > 0 {
+			spec.set(payload, sequences)
+		}
+		return nil
+	}}
+}
+
+// jsonInstruction is added to the system prompt to emulate response_format on providers without a JSON mode.
+const jsonInstruction = "Respond with a single valid JSON object and nothing else."
+
+// emulateJSONMode asks for JSON in the system prompt; a json_schema format includes the schema.
+func emulateJSONMode(payload map[string]interface{}, value interface{}) {
+	instruction := jsonModeInstruction(value)
+	if instruction == "" {
+		return
+	}
+
+	if system, _ := payload["system"].(string); system != "" {
+		payload["system"] = system + "\n\n" + instruction
+	} else {
+		payload["system"] = instruction
+	}
+}
+
+// jsonModeInstruction returns the system prompt addition emulating the response_format value, or
+// "" for the text format, which needs none.
+func jsonModeInstruction(value interface{}) string {
+	format, _ := value.(map[string]interface{})
+	if format["type"] == "text" {
+		return ""
+	}
+
+	instruction := jsonInstruction
+	if spec, ok := format["json_schema"].(map[string]interface{}); ok && spec["schema"] != nil {
+		if schema, err := json.Marshal(spec["schema"]); err == nil {
+			instruction += " It must match this JSON schema: " + string(schema)
+		}
+	}
+	return instruction
+}
@@ -0,0 +1,237 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// captureServer answers every request with an empty JSON object and records the last request body.
+func captureServer(t *testing.T) (*httptest.Server, *map[string]interface{}) {
+	t.Helper()
+	var body map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body = nil
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{}`))
+	}))
+	t.Cleanup(server.Close)
+	return server, &body
+}
+
+func paramsContext(ctx context.Context, values map[string]interface{}, policies *config.ParametersConfig) (
+	context.Context, *Params) {
+	params := NewParams(values, policies)
+	return WithParams(ctx, params), params
+}
+
+var userMessage = []map[string]interface{}{{"role": "user", "content": "Hello"}}
+
+func TestApplyParams_OpenAIPassesEverythingThrough(t *testing.T) {
+	server, body := captureServer(t)
+	provider := NewOpenAIProvider(&config.Provider{Name: "openai", BaseURL: server.URL})
+
+	ctx, params := paramsContext(t.Context(), map[string]interface{}{
+		"temperature": 0.2,
+		"logit_bias":  map[string]interface{}{"50256": -100.0},
+	}, nil)
+	_, err := provider.ChatCompletion(ctx, "gpt-4", userMessage)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0.2, (*body)["temperature"])
+	assert.Equal(t, map[string]interface{}{"50256": -100.0}, (*body)["logit_bias"])
+	assert.Empty(t, params.Warnings())
+}
+
+func TestApplyParams_OpenAIReasoningModels(t *testing.T) {
+	server, body := captureServer(t)
+	provider := NewOpenAIProvider(&config.Provider{Name: "openai", BaseURL: server.URL})
+
+	values := map[string]interface{}{
+		"temperature":      0.2,
+		"max_tokens":       100.0,
+		"reasoning_effort": "low",
+	}
+	for _, model := range []string{"o1", "o3-mini", "o4-mini-2025-04-16", "gpt-5"} {
+		ctx, params := paramsContext(t.Context(), values, nil)
+		_, err := provider.ChatCompletion(ctx, model, userMessage)
+		require.NoError(t, err)
+
+		assert.Equal(t, 100.0, (*body)["max_completion_tokens"], model)
+		assert.Equal(t, "low", (*body)["reasoning_effort"], model)
+		assert.NotContains(t, *body, "max_tokens", model)
+		assert.NotContains(t, *body, "temperature", model)
+		assert.Equal(t, []string{`parameter "temperature" was dropped, provider openai can't honor it`},
+			params.Warnings(), model)
+	}
+
+	// An explicit max_completion_tokens wins over max_tokens
+	ctx, _ := paramsContext(t.Context(), map[string]interface{}{"max_tokens": 100.0, "max_completion_tokens": 50.0}, nil)
+	_, err := provider.ChatCompletionStream(ctx, "o3", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, 50.0, (*body)["max_completion_tokens"])
+
+	// Other models get every parameter as sent
+	for _, model := range []string{"gpt-4o", "gpt-5-chat-latest", "omni-moderation"} {
+		ctx, params := paramsContext(t.Context(), values, nil)
+		_, err := provider.ChatCompletion(ctx, model, userMessage)
+		require.NoError(t, err)
+
+		assert.Equal(t, 0.2, (*body)["temperature"], model)
+		assert.Equal(t, 100.0, (*body)["max_tokens"], model)
+		assert.Empty(t, params.Warnings(), model)
+	}
+}
+
+func TestApplyParams_AnthropicTranslatesAndWarns(t *testing.T) {
+	server, body := captureServer(t)
+	provider := NewAnthropicProvider(&config.Provider{Name: "anthropic", BaseURL: server.URL})
+
+	ctx, params := paramsContext(t.Context(), map[string]interface{}{
+		"temperature":           0.5,
+		"max_completion_tokens": 100.0,
+		"user":                  "agent-7",
+		"logit_bias":            map[string]interface{}{"50256": -100.0},
+	}, nil)
+	_, err := provider.ChatCompletion(ctx, "claude-3-haiku", userMessage)
+	require.NoError(t, err)
+
+	assert.Equal(t, 0.5, (*body)["temperature"])
+	assert.Equal(t, 100.0, (*body)["max_tokens"])
+	assert.Equal(t, map[string]interface{}{"user_id": "agent-7"}, (*body)["metadata"])
+	assert.NotContains(t, *body, "logit_bias")
+	assert.Equal(t, []string{`parameter "logit_bias" was dropped, provider anthropic can't honor it`}, params.Warnings())
+}
+
+func TestApplyParams_Reject(t *testing.T) {
+	server, _ := captureServer(t)
+	provider := NewAnthropicProvider(&config.Provider{Name: "anthropic", BaseURL: server.URL})
+
+	policies := &config.ParametersConfig{
+		Unsupported: config.ParameterPolicyWarn,
+		Policies:    map[string]string{"logit_bias": config.ParameterPolicyReject},
+	}
+	ctx, _ := paramsContext(t.Context(), map[string]interface{}{"logit_bias": map[string]interface{}{}}, policies)
+
+	_, err := provider.ChatCompletionStream(ctx, "claude-3-haiku", userMessage)
+	var unsupported *UnsupportedParamError
+	require.ErrorAs(t, err, &unsupported)
+	assert.Equal(t, "logit_bias", unsupported.Param)
+	assert.Equal(t, "anthropic", unsupported.Provider)
+}
+
+func TestApplyParams_Emulate(t *testing.T) {
+	server, body := captureServer(t)
+	provider := NewAnthropicProvider(&config.Provider{Name: "anthropic", BaseURL: server.URL})
+
+	policies := &config.ParametersConfig{Unsupported: config.ParameterPolicyEmulate}
+	ctx, params := paramsContext(t.Context(), map[string]interface{}{
+		"response_format": map[string]interface{}{"type": "json_object"},
+		"seed":            42.0,
+	}, policies)
+	messages := []map[string]interface{}{
+		{"role": "system", "content": "You are terse."},
+		{"role": "user", "content": "Hello"},
+	}
+	_, err := provider.ChatCompletion(ctx, "claude-3-haiku", messages)
+	require.NoError(t, err)
+
+	assert.Equal(t, "You are terse.\n\n"+jsonInstruction, (*body)["system"])
+	assert.NotContains(t, *body, "seed")
+	assert.Equal(t, []string{
+		`parameter "response_format" is emulated by provider anthropic`,
+		`parameter "seed" was dropped, provider anthropic can't honor or emulate it`,
+	}, params.Warnings())
+}
+
+func TestApplyParams_OllamaOptions(t *testing.T) {
+	server, body := captureServer(t)
+	provider := NewOllamaProvider(&config.Provider{Name: "local", BaseURL: server.URL})
+
+	ctx, params := paramsContext(t.Context(), map[string]interface{}{
+		"temperature":     0.1,
+		"seed":            7.0,
+		"max_tokens":      64.0,
+		"response_format": map[string]interface{}{"type": "json_object"},
+		"keep_alive":      "10m",
+	}, nil)
+	_, err := provider.Completion(ctx, "llama2", "Hello")
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]interface{}{"temperature": 0.1, "seed": 7.0, "num_predict": 64.0}, (*body)["options"])
+	assert.Equal(t, "json", (*body)["format"])
+	assert.Equal(t, "10m", (*body)["keep_alive"])
+	assert.Empty(t, params.Warnings())
+}
+
+func TestStopSequences(t *testing.T) {
+	server, body := captureServer(t)
+	cfg := &config.Provider{Name: "p", BaseURL: server.URL}
+	chat := map[string]func(ctx context.Context) error{
+		"openai": func(ctx context.Context) error {
+			_, err := NewOpenAIProvider(cfg).ChatCompletion(ctx, "m", userMessage)
+			return err
+		},
+		"anthropic": func(ctx context.Context) error {
+			_, err := NewAnthropicProvider(cfg).ChatCompletion(ctx, "m", userMessage)
+			return err
+		},
+		"ollama": func(ctx context.Context) error {
+			_, err := NewOllamaProvider(cfg).ChatCompletion(ctx, "m", userMessage)
+			return err
+		},
+	}
+	// sent reads the translated sequences from where each provider expects them
+	sent := map[string]func(body map[string]interface{}) interface{}{
+		"openai":    func(body map[string]interface{}) interface{} { return body["stop"] },
+		"anthropic": func(body map[string]interface{}) interface{} { return body["stop_sequences"] },
+		"ollama": func(body map[string]interface{}) interface{} {
+			options, _ := body["options"].(map[string]interface{})
+			return options["stop"]
+		},
+	}
+
+	tests := []struct {
+		name     string
+		provider string
+		stop     interface{}
+		expected interface{}
+		err      string
+	}{
+		{"openai string", "openai", "END", []interface{}{"END"}, ""},
+		{"openai merges duplicates", "openai", []interface{}{"a", "b", "a", ""}, []interface{}{"a", "b"}, ""},
+		{"openai too many", "openai", []interface{}{"a", "b", "c", "d", "e"}, nil,
+			"at most 4 stop sequences are supported, got 5"},
+		{"openai wrong type", "openai", 3.0, nil, "must be a string or an array of strings"},
+		{"anthropic string", "anthropic", "END", []interface{}{"END"}, ""},
+		{"anthropic no limit", "anthropic", []interface{}{"a", "b", "c", "d", "e"},
+			[]interface{}{"a", "b", "c", "d", "e"}, ""},
+		{"anthropic whitespace", "anthropic", []interface{}{"\n"}, nil, "is only whitespace"},
+		{"anthropic empty", "anthropic", []interface{}{""}, nil, ""},
+		{"ollama array", "ollama", []interface{}{"\n", "User:"}, []interface{}{"\n", "User:"}, ""},
+		{"ollama item type", "ollama", []interface{}{"a", 1.0}, nil, "must be a string or an array of strings"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := paramsContext(t.Context(), map[string]interface{}{"stop": tt.stop}, nil)
+			err := chat[tt.provider](ctx)
+			if tt.err != "" {
+				var invalid *InvalidParamError
+				require.ErrorAs(t, err, &invalid)
+				assert.Equal(t, "stop", invalid.Param)
+				assert.Contains(t, invalid.Reason, tt.err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, sent[tt.provider](*body))
+		})
+	}
+}
@@ -0,0 +1,226 @@
+// Package providers implements AI provider abstractions.
+// This file contains the pause of outbound traffic for incident response: while paused, no
+// provider is called, new calls waiting for traffic to resume or being refused, and calls in
+// flight finishing or being cancelled, as configured.
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// ErrTrafficPaused is returned for provider calls refused or cancelled while outbound traffic is paused.
+var ErrTrafficPaused = errors.New("outbound traffic is paused by an operator")
+
+// PauseState is the state of the outbound traffic switch.
+type PauseState struct {
+	Paused bool `json:"paused"`
+	// Since is when traffic was paused; nil while it flows
+	Since *time.Time `json:"since,omitempty"`
+	// Queued counts the calls waiting for traffic to resume
+	Queued int `json:"queued"`
+	// InFlight counts the calls under way
+	InFlight int `json:"in_flight"`
+	// Policy is how calls are handled while paused
+	Policy config.PauseConfig `json:"policy"`
+}
+
+// trafficSwitch is the process-wide pause of outbound traffic, shared by every provider like the
+// chaos switch.
+type trafficSwitch struct {
+	mtx    sync.Mutex
+	policy config.PauseConfig
+	paused bool
+	since  time.Time
+	// resumed is closed when traffic resumes, waking the queued calls
+	resumed  chan struct{}
+	queued   int
+	inFlight map[*pausableCall]struct{}
+}
+
+// pausableCall is a call in flight, cancelled if traffic is paused with the cancel policy.
+type pausableCall struct {
+	cancel context.CancelCauseFunc
+}
+
+var traffic = &trafficSwitch{
+	policy: config.PauseConfig{
+		InFlight:            config.DefaultPauseInFlight,
+		Inbound:             config.DefaultPauseInbound,
+		QueueTimeoutSeconds: config.DefaultPauseQueueTimeoutSeconds,
+	},
+	inFlight: make(map[*pausableCall]struct{}),
+}
+
+// SetPausePolicy sets how calls are handled while outbound traffic is paused.
+func SetPausePolicy(policy config.PauseConfig) {
+	traffic.mtx.Lock()
+	defer traffic.mtx.Unlock()
+	traffic.policy = policy
+}
+
+// PauseTraffic pauses outbound traffic, cancelling the calls in flight under the cancel policy.
+// It returns how many were cancelled, and false when traffic was paused already.
+func PauseTraffic() (int, bool) {
+	traffic.mtx.Lock()
+	defer traffic.mtx.Unlock()
+
+	if traffic.paused {
+		return 0, false
+	}
+	traffic.paused = true
+	traffic.since = time.Now()
+	traffic.resumed = make(chan struct{})
+	if traffic.policy.InFlight != config.PauseCancel {
+		return 0, true
+	}
+	for call := range traffic.inFlight {
+		call.cancel(ErrTrafficPaused)
+	}
+	return len(traffic.inFlight), true
+}
+
+// ResumeTraffic lets outbound traffic flow again, starting the queued calls. It returns false
+// when traffic wasn't paused.
+func ResumeTraffic() bool {
+	traffic.mtx.Lock()
+	defer traffic.mtx.Unlock()
+
+	if !traffic.paused {
+		return false
+	}
+	traffic.paused = false
+	close(traffic.resumed)
+	return true
+}
+
+// TrafficState reports the state of outbound traffic.
+func TrafficState() PauseState {
+	traffic.mtx.Lock()
+	defer traffic.mtx.Unlock()
+
+	state := PauseState{
+		Paused:   traffic.paused,
+		Queued:   traffic.queued,
+		InFlight: len(traffic.inFlight),
+		Policy:   traffic.policy,
+	}
+	if traffic.paused {
+		since := traffic.since
+		state.Since = &since
+	}
+	return state
+}
+
+// admit returns a call to make with ctx once traffic flows, queueing or refusing it while paused.
+func (s *trafficSwitch) admit(ctx context.Context) (*pausableCall, context.Context, error) {
+	var deadline <-chan time.Time
+	for {
+		s.mtx.Lock()
+		if !s.paused {
+			ctx, cancel := context.WithCancelCause(ctx)
+			call := &pausableCall{cancel: cancel}
+			s.inFlight[call] = struct{}{}
+			s.mtx.Unlock()
+			return call, ctx, nil
+		}
+		if s.policy.Inbound == config.PauseReject {
+			s.mtx.Unlock()
+			return nil, nil, ErrTrafficPaused
+		}
+		if deadline == nil {
+			deadline = time.After(time.Duration(s.policy.QueueTimeoutSeconds) * time.Second)
+		}
+		resumed := s.resumed
+		s.queued++
+		s.mtx.Unlock()
+
+		var err error
+		select {
+		case <-resumed:
+		case <-deadline:
+			err = ErrTrafficPaused
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+		s.mtx.Lock()
+		s.queued--
+		s.mtx.Unlock()
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+}
+
+// done ends call.
+func (s *trafficSwitch) done(call *pausableCall) {
+	s.mtx.Lock()
+	delete(s.inFlight, call)
+	s.mtx.Unlock()
+	call.cancel(nil)
+}
+
+// pauseTransport holds requests while outbound traffic is paused, and cancels those in flight
+// when it is paused under the cancel policy. A call is in flight until its response body is closed.
+type pauseTransport struct {
+	provider string
+	base     http.RoundTripper
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *pauseTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	call, ctx, err := traffic.admit(req.Context())
+	if err != nil {
+		if errors.Is(err, ErrTrafficPaused) {
+			slog.Debug("Provider call refused, outbound traffic is paused", "provider", t.provider)
+		}
+		return nil, err
+	}
+
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		traffic.done(call)
+		return nil, pausedCause(ctx, err)
+	}
+	resp.Body = &pausableBody{ReadCloser: resp.Body, ctx: ctx, done: func() { traffic.done(call) }}
+	return resp, nil
+}
+
+// pausableBody ends its call when closed, and fails reads with ErrTrafficPaused once the call
+// was cancelled by the pause.
+type pausableBody struct {
+	io.ReadCloser
+	ctx  context.Context
+	done func()
+	once sync.Once
+}
+
+func (b *pausableBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = pausedCause(b.ctx, err)
+	}
+	return n, err
+}
+
+func (b *pausableBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.once.Do(b.done)
+	return err
+}
+
+// pausedCause returns ErrTrafficPaused in place of err when the pause cancelled ctx, err being
+// only its consequence, or err as it is.
+func pausedCause(ctx context.Context, err error) error {
+	if errors.Is(context.Cause(ctx), ErrTrafficPaused) {
+		return ErrTrafficPaused
+	}
+	return err
+}
@@ -0,0 +1,98 @@
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// pauseWith sets policy for the test, and resumes traffic and restores the default policy after it.
+func pauseWith(t *testing.T, policy config.PauseConfig) {
+	SetPausePolicy(policy)
+	t.Cleanup(func() {
+		ResumeTraffic()
+		SetPausePolicy(config.PauseConfig{
+			InFlight: config.DefaultPauseInFlight, Inbound: config.DefaultPauseInbound,
+			QueueTimeoutSeconds: config.DefaultPauseQueueTimeoutSeconds,
+		})
+	})
+}
+
+func TestPauseTraffic_Inbound(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"Hi"}}]}`))
+	}))
+	t.Cleanup(server.Close)
+	provider := NewOpenAIProvider(&config.Provider{Name: "openai", BaseURL: server.URL, Models: []string{"gpt-4"}})
+
+	t.Run("reject", func(t *testing.T) {
+		pauseWith(t, config.PauseConfig{Inbound: config.PauseReject, QueueTimeoutSeconds: 30})
+		_, paused := PauseTraffic()
+		require.True(t, paused)
+		_, paused = PauseTraffic()
+		assert.False(t, paused, "pausing paused traffic changes nothing")
+
+		_, err := provider.ChatCompletion(t.Context(), "gpt-4", userMessage)
+		require.ErrorIs(t, err, ErrTrafficPaused)
+		assert.Zero(t, calls.Load())
+
+		require.True(t, ResumeTraffic())
+		_, err = provider.ChatCompletion(t.Context(), "gpt-4", userMessage)
+		require.NoError(t, err)
+		assert.Equal(t, int32(1), calls.Load())
+	})
+
+	t.Run("queue", func(t *testing.T) {
+		pauseWith(t, config.PauseConfig{Inbound: config.PauseQueue, QueueTimeoutSeconds: 30})
+		PauseTraffic()
+
+		errs := make(chan error)
+		go func() {
+			_, err := provider.ChatCompletion(t.Context(), "gpt-4", userMessage)
+			errs <- err
+		}()
+		require.Eventually(t, func() bool { return TrafficState().Queued == 1 }, time.Second, time.Millisecond)
+		state := TrafficState()
+		assert.True(t, state.Paused)
+		assert.NotNil(t, state.Since)
+
+		ResumeTraffic()
+		require.NoError(t, <-errs, "queued calls are made once traffic resumes")
+		assert.Equal(t, int32(2), calls.Load())
+		assert.Nil(t, TrafficState().Since)
+	})
+}
+
+func TestPauseTraffic_InFlight(t *testing.T) {
+	started := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		w.(http.Flusher).Flush()
+		started <- struct{}{}
+		<-r.Context().Done()
+	}))
+	t.Cleanup(server.Close)
+	provider := NewOpenAIProvider(&config.Provider{Name: "openai", BaseURL: server.URL, Models: []string{"gpt-4"}})
+
+	pauseWith(t, config.PauseConfig{InFlight: config.PauseCancel, Inbound: config.PauseReject})
+	errs := make(chan error)
+	go func() {
+		_, err := provider.ChatCompletion(t.Context(), "gpt-4", userMessage)
+		errs <- err
+	}()
+	<-started
+
+	cancelled, _ := PauseTraffic()
+	assert.Equal(t, 1, cancelled)
+	require.ErrorIs(t, <-errs, ErrTrafficPaused, "the cancelled response body fails with the pause")
+	assert.Zero(t, TrafficState().InFlight)
+}
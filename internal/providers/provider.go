@@ -2,6 +2,13 @@ package providers
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
 
 	"github.com/modelplex/modelplex/internal/config"
 )
@@ -12,23 +19,237 @@ type Provider interface {
 	Priority() int
 	ChatCompletion(ctx context.Context, model string, messages []map[string]interface{}) (interface{}, error)
 	Completion(ctx context.Context, model, prompt string) (interface{}, error)
-	ListModels() []string
+	ListModels(ctx context.Context) ([]Model, error)
 
 	// Streaming methods
 	ChatCompletionStream(ctx context.Context, model string, messages []map[string]interface{}) (<-chan interface{}, error)
 	CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error)
 }
 
+// Model is a model served by a provider.
+type Model struct {
+	ID string `json:"id"`
+}
+
+// modelsOf returns the models with the given ids.
+func modelsOf(ids []string) []Model {
+	models := make([]Model, len(ids))
+	for i, id := range ids {
+		models[i] = Model{ID: id}
+	}
+	return models
+}
+
+// newChatCompletionID returns a random chat completion id, for backends that don't send one.
+func newChatCompletionID() string {
+	b := make([]byte, 12)
+	_, _ = rand.Read(b) // crypto/rand.Read never returns an error
+	return "chatcmpl-" + hex.EncodeToString(b)
+}
+
+// ModelIDs returns the ids of models.
+func ModelIDs(models []Model) []string {
+	ids := make([]string, len(models))
+	for i, model := range models {
+		ids[i] = model.ID
+	}
+	return ids
+}
+
+// StatusError is returned when a provider's API answers with an error status.
+type StatusError struct {
+	StatusCode int
+	// Body is the response body, which usually explains the error
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API request failed with status %d: %s", e.StatusCode, e.Body)
+}
+
+// maxErrorSnippet bounds how much of an upstream error an Attempt reports
+const maxErrorSnippet = 200
+
+// Attempt is a failed try at serving a request, as reported to the client.
+type Attempt struct {
+	Provider string `json:"provider"`
+	Region   string `json:"region,omitempty"`
+	// StatusCode is the status the provider answered with, or 0 when it couldn't be reached
+	StatusCode int `json:"status_code,omitempty"`
+	// Error is the start of the error, without request URLs, which may carry credentials
+	Error string `json:"error"`
+}
+
+// NewAttempt describes err, returned by region of provider, for the client.
+func NewAttempt(provider, region string, err error) Attempt {
+	attempt := Attempt{Provider: provider, Region: region}
+	var status *StatusError
+	var urlErr *url.Error
+	switch {
+	case errors.As(err, &status):
+		attempt.StatusCode = status.StatusCode
+		attempt.Error = snippet(status.Body)
+	case errors.As(err, &urlErr):
+		attempt.Error = snippet(urlErr.Err.Error())
+	default:
+		attempt.Error = snippet(err.Error())
+	}
+	return attempt
+}
+
+// FailoverError is returned when every provider a request failed over between failed it.
+type FailoverError struct {
+	Attempts []Attempt
+	errs     []error
+}
+
+// NewFailoverError returns the error for failed attempts, errs holding the error of each.
+func NewFailoverError(attempts []Attempt, errs []error) *FailoverError {
+	return &FailoverError{Attempts: attempts, errs: errs}
+}
+
+func (e *FailoverError) Error() string {
+	return errors.Join(e.errs...).Error()
+}
+
+func (e *FailoverError) Unwrap() []error {
+	return e.errs
+}
+
+// BlockedError is returned when the gateway refuses a request by policy, such as one carrying a
+// likely prompt injection, before any provider sees it.
+type BlockedError struct {
+	Reason string
+}
+
+func (e *BlockedError) Error() string {
+	return "request blocked: " + e.Reason
+}
+
+// BudgetExceededError is returned when a conversation has used up its turn or token budget, so
+// the request is refused before any provider sees it.
+type BudgetExceededError struct {
+	ConversationID string
+	// Budget is the exhausted budget, "turns" or "tokens"
+	Budget string
+	Limit  int64
+	Used   int64
+}
+
+func (e *BudgetExceededError) Error() string {
+	return fmt.Sprintf("conversation %q exceeded its budget of %d %s", e.ConversationID, e.Limit, e.Budget)
+}
+
+// LoopDetectedError is returned when a client keeps sending nearly identical requests, likely an
+// agent stuck in a loop, so the request is refused before any provider sees it.
+type LoopDetectedError struct {
+	// Repeats counts the nearly identical requests before this one within the window
+	Repeats int64
+	Window  time.Duration
+}
+
+func (e *LoopDetectedError) Error() string {
+	return fmt.Sprintf("request repeated %d times in the last %s, likely an agent loop", e.Repeats, e.Window)
+}
+
+// SessionTerminatedError is returned for the requests of a session an operator terminated, the
+// ones in flight then and those made until it is resumed.
+type SessionTerminatedError struct {
+	// Scope is what the session is, "conversation" or "tenant"
+	Scope string
+	ID    string
+	// Reason is the operator's explanation; empty when none was given
+	Reason string
+}
+
+func (e *SessionTerminatedError) Error() string {
+	message := fmt.Sprintf("%s %q was terminated by an operator", e.Scope, e.ID)
+	if e.Reason != "" {
+		message += ": " + e.Reason
+	}
+	return message
+}
+
+// RateLimitError is returned when a provider refuses a request over one of its rate limits and
+// explains which, so clients can wait instead of retrying at once. It wraps the StatusError.
+type RateLimitError struct {
+	Provider string
+	Model    string
+	// Limit is the exhausted limit as the provider names it, e.g. "TPM" or "RPD"; empty when unknown
+	Limit string
+	// RetryAfter is how long the provider asked to wait; zero when it didn't say
+	RetryAfter time.Duration
+	// Message is the provider's explanation
+	Message string
+	Status  *StatusError
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("provider %s rate limited model %s: %s", e.Provider, e.Model, e.Message)
+}
+
+func (e *RateLimitError) Unwrap() error {
+	return e.Status
+}
+
+// snippet returns the start of message on one line.
+func snippet(message string) string {
+	message = strings.Join(strings.Fields(message), " ")
+	if len(message) <= maxErrorSnippet {
+		return message
+	}
+	return strings.ToValidUTF8(message[:maxErrorSnippet], "") + "..."
+}
+
 // NewProvider creates a new provider instance based on the configuration type.
+// A provider supporting only one transport serves the other through it, and models with an
+// entry in the model map are requested under the backend's name for them, decoded speculatively
+// when paired with a draft model. Deprecation notices in the responses are recorded.
 func NewProvider(cfg *config.Provider) Provider {
+	var provider Provider
 	switch cfg.Type {
 	case "openai":
-		return NewOpenAIProvider(cfg)
+		provider = NewOpenAIProvider(cfg)
 	case "anthropic":
-		return NewAnthropicProvider(cfg)
+		provider = NewAnthropicProvider(cfg)
 	case "ollama":
-		return NewOllamaProvider(cfg)
+		provider = NewOllamaProvider(cfg)
+	case "llamacpp":
+		provider = NewLlamaCppProvider(cfg)
+	case "azure-openai":
+		provider = NewAzureOpenAIProvider(cfg)
+	case "bedrock":
+		provider = NewBedrockProvider(cfg)
+	case "openai-compatible":
+		provider = NewOpenAICompatibleProvider(cfg)
+	case "mistral":
+		provider = NewMistralProvider(cfg)
+	case "groq":
+		provider = NewGroqProvider(cfg)
+	case "openrouter":
+		provider = NewOpenRouterProvider(cfg)
+	case "tgi":
+		provider = NewTGIProvider(cfg)
+	case "xai":
+		provider = NewXAIProvider(cfg)
+	case "cohere":
+		// Without chat models, it only reranks
+		cohere := NewCohereProvider(cfg)
+		if len(cohere.models) == 0 {
+			return nil
+		}
+		provider = cohere
 	default:
 		return nil
 	}
+	if cfg.Streaming != "" {
+		provider = &transportAdapter{Provider: provider, kind: cfg.Type, streaming: cfg.Streaming}
+	}
+	if len(cfg.ModelMap) > 0 {
+		provider = &mappedProvider{Provider: provider, upstream: cfg.ModelMap}
+	}
+	if len(cfg.Speculative) > 0 {
+		provider = newSpeculativeProvider(provider, cfg)
+	}
+	return &deprecationWatcher{Provider: provider, now: time.Now}
 }
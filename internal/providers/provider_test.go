@@ -0,0 +1,33 @@
+package providers
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// listModelIDs returns the ids of the models p lists, failing t if listing fails.
+func listModelIDs(t *testing.T, p Provider) []string {
+	t.Helper()
+	models, err := p.ListModels(t.Context())
+	require.NoError(t, err)
+	return ModelIDs(models)
+}
+
+func TestNewAttempt(t *testing.T) {
+	status := &StatusError{StatusCode: 503, Body: "{\n  \"error\": \"overloaded\"\n}"}
+	assert.Equal(t, Attempt{Provider: "openai", Region: "us", StatusCode: 503, Error: `{ "error": "overloaded" }`},
+		NewAttempt("openai", "us", fmt.Errorf("request: %w", status)))
+
+	// Request URLs may carry credentials, so only the cause is reported
+	unreachable := &url.Error{Op: "Post", URL: "https://example.com/v1?key=secret", Err: errors.New("connection refused")}
+	assert.Equal(t, Attempt{Provider: "openai", Error: "connection refused"}, NewAttempt("openai", "", unreachable))
+
+	long := NewAttempt("openai", "", &StatusError{StatusCode: 500, Body: strings.Repeat("x", 500)})
+	assert.Equal(t, strings.Repeat("x", maxErrorSnippet)+"...", long.Error)
+}
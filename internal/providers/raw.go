@@ -0,0 +1,83 @@
+// Package providers implements AI provider abstractions.
+// This file contains raw request forwarding, which lets callers reach upstream endpoints
+// modelplex has no first-class support for yet, with the provider's credentials injected.
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// RawForwarder is implemented by providers that can forward arbitrary requests upstream.
+type RawForwarder interface {
+	// Forward sends req to path below the provider's base URL with the provider's credentials added.
+	// The request method, query, headers and body are passed through unchanged.
+	Forward(req *http.Request, path string) (*http.Response, error)
+}
+
+// credentialHeaders are always taken from the provider, never from the caller.
+var credentialHeaders = []string{"Authorization", "X-Api-Key", "Api-Key"}
+
+// Forward implements RawForwarder.
+func (p *OpenAIProvider) Forward(req *http.Request, path string) (*http.Response, error) {
+	return forward(p.client, p.baseURL, path, req, p.authHeaders)
+}
+
+// Forward implements RawForwarder.
+func (p *AnthropicProvider) Forward(req *http.Request, path string) (*http.Response, error) {
+	return forward(p.client, p.baseURL, path, req, p.authHeaders)
+}
+
+// Forward implements RawForwarder.
+func (p *OllamaProvider) Forward(req *http.Request, path string) (*http.Response, error) {
+	noAuth := func(context.Context) (map[string]string, error) { return nil, nil }
+	return forward(p.client, p.baseURL, path, req, noAuth)
+}
+
+// forwardThrough forwards req with provider, for wrappers around it that would otherwise hide
+// that it is a RawForwarder.
+func forwardThrough(provider Provider, req *http.Request, path string) (*http.Response, error) {
+	forwarder, ok := provider.(RawForwarder)
+	if !ok {
+		return nil, fmt.Errorf("provider %s does not support raw requests", provider.Name())
+	}
+	return forwarder.Forward(req, path)
+}
+
+// forward rewrites req to target baseURL/path and sends it with client.
+// Headers from authHeaders replace the caller's credentials; other provider headers
+// (e.g. anthropic-version) only fill in what the caller didn't send.
+func forward(
+	client *http.Client, baseURL, path string, req *http.Request,
+	authHeaders func(context.Context) (map[string]string, error),
+) (*http.Response, error) {
+	ctx := req.Context()
+	target, err := url.Parse(strings.TrimSuffix(baseURL, "/") + "/" + strings.TrimPrefix(path, "/"))
+	if err != nil {
+		return nil, err
+	}
+	target.RawQuery = req.URL.RawQuery
+
+	headers, err := authHeaders(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	out := req.Clone(ctx)
+	out.URL = target
+	out.Host = ""
+	out.RequestURI = ""
+	for _, name := range credentialHeaders {
+		out.Header.Del(name)
+	}
+	for key, value := range headers {
+		if out.Header.Get(key) == "" {
+			out.Header.Set(key, value)
+		}
+	}
+
+	return client.Do(out)
+}
@@ -0,0 +1,71 @@
+package providers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestForward(t *testing.T) {
+	var upstream *http.Request
+	var upstreamBody string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstream = r
+		body, _ := io.ReadAll(r.Body)
+		upstreamBody = string(body)
+		w.WriteHeader(http.StatusCreated)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(&config.Provider{
+		Name:    "anthropic",
+		BaseURL: server.URL + "/v1",
+		APIKey:  "sk-provider",
+	})
+
+	req := httptest.NewRequest("POST", "/providers/anthropic/raw/messages/batches?beta=true",
+		strings.NewReader(`{"requests":[]}`))
+	req.Header.Set("x-api-key", "caller-key")
+	req.Header.Set("anthropic-version", "2024-10-22")
+	req.Header.Set("anthropic-beta", "message-batches-2024-09-24")
+
+	resp, err := provider.Forward(req, "/messages/batches")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	assert.Equal(t, http.StatusCreated, resp.StatusCode)
+	assert.Equal(t, "POST", upstream.Method)
+	assert.Equal(t, "/v1/messages/batches", upstream.URL.Path)
+	assert.Equal(t, "beta=true", upstream.URL.RawQuery)
+	assert.Equal(t, `{"requests":[]}`, upstreamBody)
+	assert.Equal(t, "sk-provider", upstream.Header.Get("x-api-key"), "credentials always come from the provider")
+	assert.Equal(t, "2024-10-22", upstream.Header.Get("anthropic-version"), "caller headers take precedence")
+	assert.Equal(t, "message-batches-2024-09-24", upstream.Header.Get("anthropic-beta"))
+}
+
+func TestForward_ThroughWrappers(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/files", r.URL.Path)
+	}))
+	defer server.Close()
+
+	provider := NewProvider(&config.Provider{
+		Name: "openai", Type: "openai", BaseURL: server.URL + "/v1", Streaming: config.StreamingRequired,
+		ModelMap: map[string]string{"gpt-4": "gpt-4-0613"},
+	})
+	forwarder, ok := provider.(RawForwarder)
+	require.True(t, ok)
+
+	resp, err := forwarder.Forward(httptest.NewRequest("GET", "/providers/openai/raw/files", nil), "/files")
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+}
@@ -0,0 +1,111 @@
+// Package providers implements AI provider abstractions.
+// This file defines reranking: ordering documents by their relevance to a query, as retrieval
+// pipelines do before passing the best documents to a model. Rerankers serve no completions, so
+// they are configured and routed apart from the providers that do.
+package providers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// ErrNoReranker is returned for rerank requests no configured reranker may serve.
+var ErrNoReranker = errors.New("no reranker")
+
+// Reranker is implemented by providers that rank documents by their relevance to a query.
+type Reranker interface {
+	Name() string
+	// Rerank ranks the documents of req with model, most relevant first.
+	Rerank(ctx context.Context, model string, req *RerankRequest) (*RerankResponse, error)
+}
+
+// RerankRequest asks for documents to be ranked by their relevance to a query.
+type RerankRequest struct {
+	Query     string
+	Documents []string
+	// TopN is how many of the most relevant documents to return, all of them when 0
+	TopN int
+}
+
+// RerankResponse is the ranking of a request's documents, in Cohere's shape.
+type RerankResponse struct {
+	ID      string         `json:"id,omitempty"`
+	Model   string         `json:"model"`
+	Results []RerankResult `json:"results"`
+}
+
+// RerankResult is the relevance of one document, given by its index in the request.
+type RerankResult struct {
+	Index          int             `json:"index"`
+	RelevanceScore float64         `json:"relevance_score"`
+	Document       *RerankDocument `json:"document,omitempty"`
+}
+
+// RerankDocument is a ranked document, returned when the client asks for the documents back.
+type RerankDocument struct {
+	Text string `json:"text"`
+}
+
+// NewReranker returns the reranker for the provider of cfg, or nil when its type doesn't rerank.
+func NewReranker(cfg *config.Provider) Reranker {
+	switch cfg.Type {
+	case "cohere":
+		return NewCohereProvider(cfg)
+	case "tei":
+		return NewTEIProvider(cfg)
+	}
+	return nil
+}
+
+// Reranks reports whether model, served by the provider of cfg, ranks documents rather than
+// serving completions. Cohere's API does both, its rerank models being named rerank-*; the models
+// of the other rerankers all rank.
+func Reranks(cfg *config.Provider, model string) bool {
+	switch cfg.Type {
+	case "cohere":
+		return strings.HasPrefix(model, "rerank")
+	case "tei":
+		return true
+	}
+	return false
+}
+
+// postJSON posts payload to url and decodes the response into result.
+func postJSON(
+	ctx context.Context, client *http.Client, url string, headers map[string]string, payload, result interface{},
+) error {
+	jsonData, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, "POST", url, bytes.NewReader(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	return json.Unmarshal(body, result)
+}
@@ -0,0 +1,70 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+var rerankRequest = &RerankRequest{
+	Query:     "What is the capital of France?",
+	Documents: []string{"Berlin is in Germany.", "Paris is the capital of France.", "France is in Europe."},
+	TopN:      2,
+}
+
+func TestCohereProvider_Rerank(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rerank", r.URL.Path)
+		assert.Equal(t, "Bearer co-key", r.Header.Get("Authorization"))
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, map[string]interface{}{
+			"model": "rerank-v3.5", "query": rerankRequest.Query, "top_n": 2.0,
+			"documents": []interface{}{rerankRequest.Documents[0], rerankRequest.Documents[1], rerankRequest.Documents[2]},
+		}, body)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"r-1","results":[{"index":1,"relevance_score":0.98},` +
+			`{"index":2,"relevance_score":0.4}],"meta":{"billed_units":{"search_units":1}}}`))
+	}))
+	defer server.Close()
+
+	reranker := NewReranker(&config.Provider{Name: "cohere", Type: "cohere", BaseURL: server.URL, APIKey: "co-key"})
+	response, err := reranker.Rerank(t.Context(), "rerank-v3.5", rerankRequest)
+	require.NoError(t, err)
+	assert.Equal(t, &RerankResponse{ID: "r-1", Model: "rerank-v3.5", Results: []RerankResult{
+		{Index: 1, RelevanceScore: 0.98}, {Index: 2, RelevanceScore: 0.4},
+	}}, response)
+}
+
+func TestTEIProvider_Rerank(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/rerank", r.URL.Path)
+		assert.Empty(t, r.Header.Get("Authorization"))
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, rerankRequest.Query, body["query"])
+		assert.Len(t, body["texts"], 3)
+		assert.NotContains(t, body, "model", "TEI serves a single model")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`[{"index":2,"score":0.4},{"index":0,"score":0.01},{"index":1,"score":0.98}]`))
+	}))
+	defer server.Close()
+
+	reranker := NewReranker(&config.Provider{Name: "local", Type: "tei", BaseURL: server.URL})
+	response, err := reranker.Rerank(t.Context(), "bge-reranker-v2-m3", rerankRequest)
+	require.NoError(t, err)
+	assert.Equal(t, &RerankResponse{Model: "bge-reranker-v2-m3", Results: []RerankResult{
+		{Index: 1, RelevanceScore: 0.98}, {Index: 2, RelevanceScore: 0.4},
+	}}, response, "ordered by score and cut to top_n")
+}
+
+func TestNewReranker_CompletionProviders(t *testing.T) {
+	assert.Nil(t, NewReranker(&config.Provider{Name: "openai", Type: "openai"}))
+	assert.Nil(t, NewProvider(&config.Provider{Name: "cohere", Type: "cohere"}), "rerankers serve no completions")
+}
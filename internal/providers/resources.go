@@ -0,0 +1,49 @@
+package providers
+
+import (
+	"context"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// ResourceReporter is implemented by local backends that report the models they hold in memory.
+type ResourceReporter interface {
+	// LoadedModels returns the models the backend has loaded, under the backend's names for them.
+	LoadedModels(ctx context.Context) ([]LoadedModel, error)
+}
+
+// ModelUnloader is implemented by local backends that can be asked to free a model's memory.
+type ModelUnloader interface {
+	// Unload frees the memory of model, under the backend's name for it.
+	Unload(ctx context.Context, model string) error
+}
+
+// LoadedModel is a model a backend holds in memory.
+type LoadedModel struct {
+	Name string `json:"name"`
+	// Size is the memory the model takes in bytes, SizeVRAM the part of it in GPU memory; the rest
+	// was offloaded to system memory for lack of GPU memory
+	Size     int64 `json:"size"`
+	SizeVRAM int64 `json:"size_vram"`
+	// ExpiresAt is when the backend unloads the model unless it is used again
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// NewModelUnloader returns the model unloader for the backend of cfg, or nil when its type can't
+// unload models.
+func NewModelUnloader(cfg *config.Provider) ModelUnloader {
+	if cfg.Type == "ollama" {
+		return NewOllamaProvider(cfg)
+	}
+	return nil
+}
+
+// NewResourceReporter returns the resource reporter for the backend of cfg, or nil when its type
+// reports none.
+func NewResourceReporter(cfg *config.Provider) ResourceReporter {
+	if cfg.Type == "ollama" {
+		return NewOllamaProvider(cfg)
+	}
+	return nil
+}
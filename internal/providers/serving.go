@@ -0,0 +1,74 @@
+// Package providers implements AI provider abstractions.
+// This file records which provider, and region of it, served a request, for attributing its response.
+package providers
+
+import (
+	"context"
+	"sync"
+)
+
+type servingKey struct{}
+
+// serving is the provider recorded as serving a request, and its region when it has several.
+type serving struct {
+	mtx    sync.Mutex
+	name   string
+	region string
+}
+
+// WithServingProvider returns a context that records the provider serving its request, as
+// reported by the multiplexer, for ServingProvider. A context already recording it is returned
+// as is, so every layer asking reads the same provider.
+func WithServingProvider(ctx context.Context) context.Context {
+	if _, ok := ctx.Value(servingKey{}).(*serving); ok {
+		return ctx
+	}
+	return context.WithValue(ctx, servingKey{}, &serving{})
+}
+
+// ReportServing records provider as the one serving the request of ctx, if ctx records it. The
+// region reported for another provider is dropped.
+func ReportServing(ctx context.Context, provider Provider) {
+	if s, ok := ctx.Value(servingKey{}).(*serving); ok {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		if s.name != provider.Name() {
+			s.region = ""
+		}
+		s.name = provider.Name()
+	}
+}
+
+// ReportServingRegion records region of the provider named provider as serving the request of
+// ctx, if ctx records it.
+func ReportServingRegion(ctx context.Context, provider, region string) {
+	if s, ok := ctx.Value(servingKey{}).(*serving); ok {
+		s.mtx.Lock()
+		defer s.mtx.Unlock()
+		s.name, s.region = provider, region
+	}
+}
+
+// ServingProvider returns the provider recorded as serving the request of ctx, or "" when none
+// was, e.g. because the response was replayed from the cache.
+func ServingProvider(ctx context.Context) string {
+	s, ok := ctx.Value(servingKey{}).(*serving)
+	if !ok {
+		return ""
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.name
+}
+
+// ServingRegion returns the region of the provider recorded as serving the request of ctx, or ""
+// when it has none or no provider was recorded.
+func ServingRegion(ctx context.Context) string {
+	s, ok := ctx.Value(servingKey{}).(*serving)
+	if !ok {
+		return ""
+	}
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.region
+}
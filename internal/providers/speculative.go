@@ -0,0 +1,107 @@
+package providers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// speculativeProvider has the backend decode configured models speculatively, verifying tokens
+// drafted by a smaller model, by adding the backend's speculative parameters to their requests.
+// It is experimental: backends that don't support speculative decoding ignore the parameters.
+type speculativeProvider struct {
+	Provider
+	// drafts holds the parameters added to the requests for each model, by public name
+	drafts map[string]map[string]interface{}
+}
+
+// newSpeculativeProvider adds the speculative parameters cfg configures to the requests provider
+// serves, or returns provider as it is when there are none.
+func newSpeculativeProvider(provider Provider, cfg *config.Provider) Provider {
+	drafts := make(map[string]map[string]interface{}, len(cfg.Speculative))
+	for model, pair := range cfg.Speculative {
+		params := make(map[string]interface{})
+		switch cfg.Type {
+		case "openai":
+			// LM Studio's name for it; the draft must be loadable alongside the model
+			params["draft_model"] = pair.Draft
+		case "llamacpp":
+			// llama-server drafts with its --model-draft, so only how much it drafts can be tuned
+			if pair.MaxDraftTokens > 0 {
+				params["speculative.n_max"] = pair.MaxDraftTokens
+			}
+			if pair.MinDraftTokens > 0 {
+				params["speculative.n_min"] = pair.MinDraftTokens
+			}
+			if pair.MinProbability > 0 {
+				params["speculative.p_min"] = pair.MinProbability
+			}
+		}
+		if len(params) > 0 {
+			drafts[model] = params
+		}
+	}
+	if len(drafts) == 0 {
+		return provider
+	}
+	return &speculativeProvider{Provider: provider, drafts: drafts}
+}
+
+// ChatCompletion performs a chat completion, decoding model speculatively.
+func (p *speculativeProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	return p.Provider.ChatCompletion(p.draft(ctx, model), model, messages)
+}
+
+// Completion performs a completion, decoding model speculatively.
+func (p *speculativeProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	return p.Provider.Completion(p.draft(ctx, model), model, prompt)
+}
+
+// ChatCompletionStream performs a streaming chat completion, decoding model speculatively.
+func (p *speculativeProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	return p.Provider.ChatCompletionStream(p.draft(ctx, model), model, messages)
+}
+
+// CompletionStream performs a streaming completion, decoding model speculatively.
+func (p *speculativeProvider) CompletionStream(
+	ctx context.Context, model, prompt string,
+) (<-chan interface{}, error) {
+	return p.Provider.CompletionStream(p.draft(ctx, model), model, prompt)
+}
+
+// CountTokens implements TokenCounter; counting doesn't decode.
+func (p *speculativeProvider) CountTokens(
+	ctx context.Context, model string, request map[string]interface{},
+) (int64, error) {
+	return CountTokens(ctx, p.Provider, model, request)
+}
+
+// Forward implements RawForwarder. Raw requests are passed on as they are, without drafting.
+func (p *speculativeProvider) Forward(req *http.Request, path string) (*http.Response, error) {
+	return forwardThrough(p.Provider, req, path)
+}
+
+// draft returns ctx with the speculative parameters for model added to the request's, which take
+// precedence so a client can tune or turn off drafting for a request.
+func (p *speculativeProvider) draft(ctx context.Context, model string) context.Context {
+	drafts, ok := p.drafts[model]
+	if !ok {
+		return ctx
+	}
+	params := ParamsFrom(ctx)
+	overrides := make(map[string]interface{}, len(drafts))
+	for name, value := range drafts {
+		if _, set := params.Values()[name]; !set {
+			overrides[name] = value
+		}
+	}
+	if len(overrides) == 0 {
+		return ctx
+	}
+	return WithParams(ctx, params.Override(overrides))
+}
@@ -0,0 +1,77 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestSpeculativeProvider_AddsDraftParams(t *testing.T) {
+	tests := []struct {
+		name     string
+		cfg      config.Provider
+		model    string
+		params   map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			name: "llamacpp tunes drafting",
+			cfg: config.Provider{Type: "llamacpp", Models: []string{"qwen2.5-7b-instruct"},
+				Speculative: map[string]config.SpeculativeDecoding{
+					"qwen2.5-7b-instruct": {MaxDraftTokens: 16, MinProbability: 0.75},
+				}},
+			model: "qwen2.5-7b-instruct",
+			expected: map[string]interface{}{
+				"model": "qwen2.5-7b-instruct", "speculative.n_max": 16.0, "speculative.p_min": 0.75,
+			},
+		},
+		{
+			name: "request params take precedence",
+			cfg: config.Provider{Type: "llamacpp", Models: []string{"qwen2.5-7b-instruct"},
+				Speculative: map[string]config.SpeculativeDecoding{
+					"qwen2.5-7b-instruct": {MaxDraftTokens: 16, MinProbability: 0.75},
+				}},
+			model:  "qwen2.5-7b-instruct",
+			params: map[string]interface{}{"speculative.n_max": 4.0},
+			expected: map[string]interface{}{
+				"model": "qwen2.5-7b-instruct", "speculative.n_max": 4.0, "speculative.p_min": 0.75,
+			},
+		},
+		{
+			name: "openai names the draft under the public name",
+			cfg: config.Provider{Type: "openai", Models: []string{"gpt-4o"},
+				ModelMap: map[string]string{"gpt-4o": "qwen2.5-32b-instruct"},
+				Speculative: map[string]config.SpeculativeDecoding{
+					"gpt-4o": {Draft: "qwen2.5-0.5b-instruct"},
+				}},
+			model:    "gpt-4o",
+			expected: map[string]interface{}{"model": "qwen2.5-32b-instruct", "draft_model": "qwen2.5-0.5b-instruct"},
+		},
+		{
+			name: "unpaired models",
+			cfg: config.Provider{Type: "openai", Models: []string{"gpt-4o", "gpt-4o-mini"},
+				Speculative: map[string]config.SpeculativeDecoding{
+					"gpt-4o": {Draft: "qwen2.5-0.5b-instruct"},
+				}},
+			model:    "gpt-4o-mini",
+			expected: map[string]interface{}{"model": "gpt-4o-mini"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server, body := captureServer(t)
+			tt.cfg.Name, tt.cfg.BaseURL = "local", server.URL
+			provider := NewProvider(&tt.cfg)
+			ctx, params := paramsContext(t.Context(), tt.params, nil)
+
+			_, err := provider.ChatCompletion(ctx, tt.model, userMessage)
+			require.NoError(t, err)
+			delete(*body, "messages")
+			assert.Equal(t, tt.expected, *body)
+			assert.Empty(t, params.Warnings())
+		})
+	}
+}
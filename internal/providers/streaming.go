@@ -47,24 +47,20 @@ func makeStreamingRequest(ctx context.Context, client *http.Client,
 	if err != nil {
 		return nil, err
 	}
-	// Ensure response body is always closed
-	defer func() {
-		if resp != nil && resp.Body != nil {
-			_ = resp.Body.Close() // Explicitly ignore error in defer
-		}
-	}()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API request failed with status %d: %s", resp.StatusCode, string(body))
+		_ = resp.Body.Close()
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// Create channel for streaming chunks
 	streamChan := make(chan interface{})
 
-	// Start goroutine to read streaming response
+	// Start goroutine to read streaming response; it owns the body, which must stay open until the stream ends
 	go func() {
 		defer close(streamChan)
+		defer func() { _ = resp.Body.Close() }()
 		processStreamingResponse(ctx, resp.Body, streamChan, reqConfig)
 	}()
 
@@ -74,6 +70,7 @@ func makeStreamingRequest(ctx context.Context, client *http.Client,
 // processStreamingResponse handles the streaming response parsing
 func processStreamingResponse(ctx context.Context, body io.ReadCloser,
 	streamChan chan interface{}, reqConfig StreamingRequestConfig) {
+	joiner := newTextJoiner()
 	scanner := bufio.NewScanner(body)
 	for scanner.Scan() {
 		line := strings.TrimSpace(scanner.Text())
@@ -83,7 +80,7 @@ func processStreamingResponse(ctx context.Context, body io.ReadCloser,
 			continue
 		}
 
-		chunk, shouldContinue := parseStreamingLine(line, reqConfig)
+		chunk, shouldContinue := parseStreamingLine(line, reqConfig, joiner)
 		if !shouldContinue {
 			continue
 		}
@@ -97,13 +94,13 @@ func processStreamingResponse(ctx context.Context, body io.ReadCloser,
 	}
 }
 
-// parseStreamingLine parses a single line from the streaming response
-func parseStreamingLine(line string, reqConfig StreamingRequestConfig) (interface{}, bool) {
+// parseStreamingLine parses a single line from the streaming response; joiner keeps characters split across lines whole
+func parseStreamingLine(line string, reqConfig StreamingRequestConfig, joiner *textJoiner) (interface{}, bool) {
 	var chunk interface{}
 	var err error
 
 	if reqConfig.UseSSE {
-		chunk, err = parseSSELine(line)
+		chunk, err = parseSSELine(line, joiner)
 		if err != nil {
 			if err.Error() == "done" {
 				return nil, false // End of stream
@@ -115,7 +112,7 @@ func parseStreamingLine(line string, reqConfig StreamingRequestConfig) (interfac
 		}
 	} else {
 		// Handle line-by-line JSON format (Ollama)
-		err = json.Unmarshal([]byte(line), &chunk)
+		err = json.Unmarshal(joiner.join([]byte(line)), &chunk)
 		if err != nil {
 			return nil, false // Skip malformed chunks
 		}
@@ -133,12 +130,13 @@ func parseStreamingLine(line string, reqConfig StreamingRequestConfig) (interfac
 }
 
 // parseSSELine parses a Server-Sent Events line
-func parseSSELine(line string) (interface{}, error) {
-	if !strings.HasPrefix(line, "data: ") {
+func parseSSELine(line string, joiner *textJoiner) (interface{}, error) {
+	data, ok := strings.CutPrefix(line, "data:")
+	if !ok {
 		return nil, fmt.Errorf("skip") // Skip non-data lines in SSE
 	}
-
-	data := strings.TrimPrefix(line, "data: ")
+	// The space after the colon is optional, and some servers such as TGI leave it out
+	data = strings.TrimPrefix(data, " ")
 
 	// Check for end marker
 	if data == "[DONE]" {
@@ -147,6 +145,6 @@ func parseSSELine(line string) (interface{}, error) {
 
 	// Parse JSON chunk
 	var chunk interface{}
-	err := json.Unmarshal([]byte(data), &chunk)
+	err := json.Unmarshal(joiner.join([]byte(data)), &chunk)
 	return chunk, err
 }
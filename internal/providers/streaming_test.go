@@ -0,0 +1,35 @@
+package providers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeStreamingRequest_ReadsAfterReturning(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		_, _ = w.Write([]byte("data: {\"n\":1}\n\n"))
+		w.(http.Flusher).Flush()
+		// The rest of the stream is only sent once makeStreamingRequest has returned
+		<-release
+		_, _ = w.Write([]byte("data: {\"n\":2}\n\ndata: [DONE]\n\n"))
+	}))
+	defer server.Close()
+
+	stream, err := makeStreamingRequest(t.Context(), server.Client(), StreamingRequestConfig{
+		BaseURL: server.URL, Endpoint: "/chat/completions", UseSSE: true,
+	})
+	require.NoError(t, err)
+	close(release)
+
+	var chunks []interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	assert.Equal(t, []interface{}{map[string]interface{}{"n": 1.0}, map[string]interface{}{"n": 2.0}}, chunks)
+}
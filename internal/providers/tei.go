@@ -0,0 +1,75 @@
+// Package providers implements AI provider abstractions.
+// TEIProvider reranks documents with a local reranker model, e.g. BAAI/bge-reranker-v2-m3, served
+// by Hugging Face's Text Embeddings Inference (TEI):
+// - A TEI server serves the one model it was started with, so the model isn't sent
+// - It answers with every document's score, which is ordered and cut to top_n here
+// - Authentication is optional, with the key given to TEI's --api-key
+// - The base URL is the server's, e.g. http://localhost:8080
+package providers
+
+import (
+	"cmp"
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// TEIProvider implements the Reranker interface for Text Embeddings Inference.
+type TEIProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewTEIProvider creates a new TEI provider instance.
+func NewTEIProvider(cfg *config.Provider) *TEIProvider {
+	return &TEIProvider{
+		name:    cfg.Name,
+		baseURL: cfg.BaseURL,
+		apiKey:  resolveEnv(cfg.APIKey),
+		client:  newHTTPClient(cfg),
+	}
+}
+
+// Name returns the provider name.
+func (p *TEIProvider) Name() string {
+	return p.name
+}
+
+// Rerank implements Reranker.
+func (p *TEIProvider) Rerank(ctx context.Context, model string, req *RerankRequest) (*RerankResponse, error) {
+	payload := map[string]interface{}{
+		"query": req.Query,
+		"texts": req.Documents,
+		// Documents longer than the model's window are cut instead of failing the request
+		"truncate": true,
+	}
+
+	var ranks []struct {
+		Index int     `json:"index"`
+		Score float64 `json:"score"`
+	}
+	if err := postJSON(ctx, p.client, p.baseURL+"/rerank", p.headers(), payload, &ranks); err != nil {
+		return nil, err
+	}
+
+	results := make([]RerankResult, len(ranks))
+	for i, rank := range ranks {
+		results[i] = RerankResult{Index: rank.Index, RelevanceScore: rank.Score}
+	}
+	slices.SortStableFunc(results, func(a, b RerankResult) int { return cmp.Compare(b.RelevanceScore, a.RelevanceScore) })
+	if req.TopN > 0 && req.TopN < len(results) {
+		results = results[:req.TopN]
+	}
+	return &RerankResponse{Model: model, Results: results}, nil
+}
+
+func (p *TEIProvider) headers() map[string]string {
+	if p.apiKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + p.apiKey}
+}
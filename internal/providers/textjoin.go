@@ -0,0 +1,158 @@
+package providers
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// textJoiner keeps a character split across streaming chunks intact. Some backends cut a delta in
+// the middle of a multi-byte UTF-8 sequence or between the two \u escapes of a surrogate pair;
+// decoded on its own, each half becomes U+FFFD. The joiner works on the raw JSON of each chunk:
+// an incomplete character at the end of a string value is held back and prepended to the value
+// at the same path in the next chunk, so every chunk decodes to whole code points.
+// A character still incomplete when the stream ends is dropped, as it could never be decoded.
+type textJoiner struct {
+	// carry maps the path of a string value to the raw literal bytes held back from it
+	carry map[string][]byte
+}
+
+func newTextJoiner() *textJoiner {
+	return &textJoiner{carry: make(map[string][]byte)}
+}
+
+// join returns data with held-back bytes restored and new incomplete characters held back.
+// Data that isn't a well-formed JSON document is returned unchanged for the decoder to reject.
+func (j *textJoiner) join(data []byte) []byte {
+	// Fast path: nothing is pending and no string can end mid-character
+	if len(j.carry) == 0 && utf8.Valid(data) && !bytes.Contains(data, []byte(`\u`)) {
+		return data
+	}
+
+	var out bytes.Buffer
+	out.Grow(len(data))
+
+	// stack tracks the open objects and arrays; each frame knows the key or index it is at
+	type frame struct {
+		array     bool
+		index     int
+		key       string
+		expectKey bool
+	}
+	var stack []frame
+	path := func() string {
+		parts := make([]string, len(stack))
+		for i, f := range stack {
+			if f.array {
+				parts[i] = strconv.Itoa(f.index)
+			} else {
+				parts[i] = f.key
+			}
+		}
+		return strings.Join(parts, ".")
+	}
+
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		switch c {
+		case '{':
+			stack = append(stack, frame{expectKey: true})
+		case '[':
+			stack = append(stack, frame{array: true})
+		case '}', ']':
+			if len(stack) == 0 {
+				return data
+			}
+			stack = stack[:len(stack)-1]
+		case ',':
+			if len(stack) == 0 {
+				return data
+			}
+			top := &stack[len(stack)-1]
+			if top.array {
+				top.index++
+			} else {
+				top.expectKey = true
+			}
+		case '"':
+			end := literalEnd(data, i+1)
+			if end < 0 {
+				return data
+			}
+			content := data[i+1 : end]
+			if len(stack) > 0 && !stack[len(stack)-1].array && stack[len(stack)-1].expectKey {
+				key, err := strconv.Unquote(string(data[i : end+1]))
+				if err != nil {
+					key = string(content)
+				}
+				stack[len(stack)-1].key = key
+				stack[len(stack)-1].expectKey = false
+			} else {
+				content = j.value(path(), content)
+			}
+			out.WriteByte('"')
+			out.Write(content)
+			out.WriteByte('"')
+			i = end
+			continue
+		}
+		out.WriteByte(c)
+	}
+	return out.Bytes()
+}
+
+// value restores the bytes held back for the string at path and holds back its incomplete tail.
+func (j *textJoiner) value(path string, content []byte) []byte {
+	if carried, ok := j.carry[path]; ok {
+		delete(j.carry, path)
+		content = append(carried, content...)
+	}
+	if n := incompleteTail(content); n > 0 {
+		j.carry[path] = bytes.Clone(content[len(content)-n:])
+		content = content[:len(content)-n]
+	}
+	return content
+}
+
+// literalEnd returns the index of the quote closing the string literal starting at start, or -1.
+func literalEnd(data []byte, start int) int {
+	for i := start; i < len(data); i++ {
+		switch data[i] {
+		case '\\':
+			i++
+		case '"':
+			return i
+		}
+	}
+	return -1
+}
+
+// incompleteTail returns the length of an unfinished character at the end of a raw string literal:
+// the leading bytes of a UTF-8 sequence or a \u escape of a high surrogate awaiting its low half.
+func incompleteTail(content []byte) int {
+	// A high surrogate escape is six bytes, and its backslash must not itself be escaped
+	if n := len(content); n >= 6 && content[n-6] == '\\' && content[n-5] == 'u' {
+		backslashes := 0
+		for k := n - 6; k >= 0 && content[k] == '\\'; k-- {
+			backslashes++
+		}
+		if backslashes%2 == 1 {
+			if r, err := strconv.ParseUint(string(content[n-4:]), 16, 32); err == nil && r >= 0xD800 && r < 0xDC00 {
+				return 6
+			}
+		}
+	}
+
+	// Walk back over continuation bytes to the start of the last sequence
+	for k := 1; k <= utf8.UTFMax && k <= len(content); k++ {
+		b := content[len(content)-k]
+		if utf8.RuneStart(b) {
+			if b >= utf8.RuneSelf && !utf8.FullRune(content[len(content)-k:]) {
+				return k
+			}
+			return 0
+		}
+	}
+	return 0
+}
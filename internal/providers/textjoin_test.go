@@ -0,0 +1,96 @@
+package providers
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTextJoiner(t *testing.T) {
+	emoji := "\xf0\x9f\x98\x80" // U+1F600 as UTF-8
+
+	tests := []struct {
+		name     string
+		chunks   []string
+		expected []string
+	}{
+		{
+			name:     "UTF-8 sequence split across chunks",
+			chunks:   []string{`{"choices":[{"delta":{"content":"hi ` + emoji[:2] + `"}}]}`, `{"choices":[{"delta":{"content":"` + emoji[2:] + `!"}}]}`},
+			expected: []string{"hi ", emoji + "!"},
+		},
+		{
+			name:     "surrogate pair split across chunks",
+			chunks:   []string{`{"delta":{"text":"a\ud83d"}}`, `{"delta":{"text":"\ude00b"}}`},
+			expected: []string{"a", emoji + "b"},
+		},
+		{
+			name:     "escaped backslash before u is not a surrogate",
+			chunks:   []string{`{"delta":{"text":"\\ud83d"}}`},
+			expected: []string{`\ud83d`},
+		},
+		{
+			name:     "whole characters pass through",
+			chunks:   []string{`{"message":{"content":"café ` + emoji + `"}}`},
+			expected: []string{"café " + emoji},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			joiner := newTextJoiner()
+			var got []string
+			for _, chunk := range tt.chunks {
+				var decoded interface{}
+				require.NoError(t, json.Unmarshal(joiner.join([]byte(chunk)), &decoded))
+				got = append(got, textOf(decoded))
+			}
+			assert.Equal(t, tt.expected, got)
+			for _, text := range got {
+				assert.NotContains(t, text, "�")
+			}
+		})
+	}
+}
+
+func TestProcessStreamingResponse_JoinsSplitCharacters(t *testing.T) {
+	body := "data: {\"delta\":{\"text\":\"\xe2\x82\"}}\n\n" + // first two bytes of €
+		"data: {\"delta\":{\"text\":\"\xac5\"}}\n\n" +
+		"data: [DONE]\n\n"
+
+	streamChan := make(chan interface{})
+	go func() {
+		defer close(streamChan)
+		processStreamingResponse(t.Context(), io.NopCloser(strings.NewReader(body)), streamChan, StreamingRequestConfig{UseSSE: true})
+	}()
+
+	var text strings.Builder
+	for chunk := range streamChan {
+		text.WriteString(textOf(chunk))
+	}
+	assert.Equal(t, "€5", text.String())
+}
+
+// textOf returns the first string found in a decoded chunk.
+func textOf(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case map[string]interface{}:
+		for _, value := range v {
+			if text := textOf(value); text != "" {
+				return text
+			}
+		}
+	case []interface{}:
+		for _, value := range v {
+			if text := textOf(value); text != "" {
+				return text
+			}
+		}
+	}
+	return ""
+}
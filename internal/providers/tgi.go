@@ -0,0 +1,279 @@
+// Package providers implements AI provider abstractions.
+// TGIProvider serves a model with Hugging Face's Text Generation Inference (TGI), self-hosted or
+// behind an Inference Endpoint, through its native /generate and /generate_stream routes:
+// - A TGI server serves the one model it was started with, so the model isn't sent; without
+// configured models, it is listed from /info
+// - The routes take a prompt, not messages: chat messages are rendered with the chat template of
+// tgi.template, chatml, llama3 or mistral, and generation stops where the assistant's turn ends
+// - The stop sequence a generation ended on is cut from its text, which TGI keeps
+// - Streams are SSE events carrying one token each, translated into OpenAI chunks
+// - TGI doesn't count the prompt's tokens, so usage estimates them
+// - Authentication is optional, a bearer token such as a Hugging Face token for Inference Endpoints
+// - The base URL is the server's, e.g. http://localhost:8080
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/pkg/convert"
+)
+
+// tgiParams translates OpenAI request parameters to the parameters of TGI's generate routes, and
+// passes TGI's own sampling parameters top_k, repetition_penalty and typical_p through.
+// presence_penalty, logit_bias, logprobs and tools have no TGI equivalent.
+var tgiParams = &paramRules{rules: map[string]paramRule{
+	"temperature": {set: func(payload map[string]interface{}, value interface{}) error {
+		// TGI takes only positive temperatures; 0 asks for greedy decoding, which it does unless
+		// told to sample
+		if t, _ := paramNumber(value); t != 0 {
+			payload["temperature"] = value
+			payload["do_sample"] = true
+		}
+		return nil
+	}},
+	"top_p": {set: func(payload map[string]interface{}, value interface{}) error {
+		// TGI takes top_p below 1 only; 1 keeps every token, as without it
+		if p, _ := paramNumber(value); p != 1 {
+			payload["top_p"] = value
+		}
+		return nil
+	}},
+	"max_tokens":            {set: rename("max_new_tokens")},
+	"max_completion_tokens": {set: rename("max_new_tokens")},
+	"seed":                  {set: rename("seed")},
+	"frequency_penalty":     {set: rename("frequency_penalty")},
+	"top_k":                 {set: rename("top_k")},
+	"repetition_penalty":    {set: rename("repetition_penalty")},
+	"typical_p":             {set: rename("typical_p")},
+	"n": {set: func(_ map[string]interface{}, value interface{}) error {
+		if n, _ := paramNumber(value); n != 1 {
+			return errors.New("must be 1, TGI generates a single choice")
+		}
+		return nil
+	}},
+	// TGI takes 4 stop sequences by default, one of which ends the template's assistant turn
+	"stop": stopRule(stopSpec{max: 3, set: func(payload map[string]interface{}, sequences []string) {
+		payload["stop"] = sequences
+	}}),
+	"response_format": {set: func(payload map[string]interface{}, value interface{}) error {
+		format, _ := value.(map[string]interface{})
+		var schema interface{}
+		switch format["type"] {
+		case "text":
+			return nil
+		case "json_object":
+			schema = map[string]interface{}{"type": "object"}
+		case "json_schema":
+			spec, _ := format["json_schema"].(map[string]interface{})
+			if schema = spec["schema"]; schema == nil {
+				schema = map[string]interface{}{"type": "object"}
+			}
+		default:
+			return errors.New(`must have type "text", "json_object" or "json_schema"`)
+		}
+		payload["grammar"] = map[string]interface{}{"type": "json", "value": schema}
+		return nil
+	}},
+}}
+
+// TGIProvider implements the Provider interface for Text Generation Inference.
+type TGIProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	client  *http.Client
+	models  []string
+	// template is the chat template prompts are rendered with
+	template string
+	priority int
+}
+
+// NewTGIProvider creates a new TGI provider instance.
+func NewTGIProvider(cfg *config.Provider) *TGIProvider {
+	return &TGIProvider{
+		name:     cfg.Name,
+		baseURL:  strings.TrimSuffix(cfg.BaseURL, "/"),
+		apiKey:   resolveEnv(cfg.APIKey),
+		client:   newHTTPClient(cfg),
+		models:   cfg.Models,
+		template: cfg.TGI.Template,
+		priority: cfg.Priority,
+	}
+}
+
+// Name returns the provider name.
+func (p *TGIProvider) Name() string {
+	return p.name
+}
+
+// Priority returns the provider priority for model routing.
+func (p *TGIProvider) Priority() int {
+	return p.priority
+}
+
+// tgiInfo is the subset of TGI's /info we rely on.
+type tgiInfo struct {
+	ModelID string `json:"model_id"`
+}
+
+// ListModels returns the configured models, or else the model the server was started with.
+func (p *TGIProvider) ListModels(ctx context.Context) ([]Model, error) {
+	if len(p.models) > 0 {
+		return modelsOf(p.models), nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.baseURL+"/info", http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	for key, value := range p.headers() {
+		req.Header.Set(key, value)
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, &StatusError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+	var info tgiInfo
+	if err := json.Unmarshal(body, &info); err != nil {
+		return nil, err
+	}
+	return modelsOf([]string{info.ModelID}), nil
+}
+
+// ChatCompletion performs a chat completion request by generating from the messages rendered
+// with the chat template.
+func (p *TGIProvider) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	prompt, stop, err := convert.OpenAIToTGIPrompt(messages, p.template)
+	if err != nil {
+		return nil, err
+	}
+	return p.generate(ctx, model, prompt, stop)
+}
+
+// Completion performs a completion request by generating from the prompt as it is, and returns
+// the text in the legacy text completion schema.
+func (p *TGIProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	response, err := p.generate(ctx, model, prompt, "")
+	if err != nil {
+		return nil, err
+	}
+	return chatTextCompletion(response), nil
+}
+
+// ChatCompletionStream performs a streaming chat completion request by generating from the
+// messages rendered with the chat template, translating the tokens into OpenAI chunks.
+func (p *TGIProvider) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	prompt, stop, err := convert.OpenAIToTGIPrompt(messages, p.template)
+	if err != nil {
+		return nil, err
+	}
+	return p.generateStream(ctx, model, prompt, stop)
+}
+
+// CompletionStream performs a streaming completion request by generating from the prompt as it
+// is, streaming the text as legacy text completion chunks.
+func (p *TGIProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	chunks, err := p.generateStream(ctx, model, prompt, "")
+	if err != nil {
+		return nil, err
+	}
+	return textCompletionChunks(ctx, chunks), nil
+}
+
+// generate generates from inputs through /generate, stopping on stop as well when set, and
+// returns the text as an OpenAI chat completion.
+func (p *TGIProvider) generate(ctx context.Context, model, inputs, stop string) (map[string]interface{}, error) {
+	payload, stops, err := p.payload(ctx, inputs, stop)
+	if err != nil {
+		return nil, err
+	}
+
+	var result map[string]interface{}
+	if err := postJSON(ctx, p.client, p.baseURL+"/generate", p.headers(), payload, &result); err != nil {
+		return nil, err
+	}
+
+	response := convert.TGIToOpenAIResponse(result, stops, float64(EstimateTextTokens(inputs)))
+	response["id"] = newChatCompletionID()
+	response["model"] = model
+	response["created"] = time.Now().Unix()
+	return response, nil
+}
+
+// generateStream generates from inputs through /generate_stream, stopping on stop as well when
+// set, and streams the tokens as OpenAI chat completion chunks.
+func (p *TGIProvider) generateStream(
+	ctx context.Context, model, inputs, stop string,
+) (<-chan interface{}, error) {
+	payload, stops, err := p.payload(ctx, inputs, stop)
+	if err != nil {
+		return nil, err
+	}
+
+	stream := &convert.TGIStream{Stops: stops, PromptTokens: float64(EstimateTextTokens(inputs))}
+	id, created := newChatCompletionID(), time.Now().Unix()
+	reqConfig := StreamingRequestConfig{
+		BaseURL:  p.baseURL,
+		Endpoint: "/generate_stream",
+		Payload:  payload,
+		Headers:  p.headers(),
+		UseSSE:   true,
+		Transformer: func(event interface{}) interface{} {
+			e, _ := event.(map[string]interface{})
+			chunk := stream.Chunk(e)
+			if chunk == nil {
+				return nil
+			}
+			if _, failed := chunk["error"]; !failed {
+				chunk["id"], chunk["model"], chunk["created"] = id, model, created
+			}
+			return chunk
+		},
+	}
+	return makeStreamingRequest(ctx, p.client, reqConfig)
+}
+
+// payload builds the generate request for inputs and the request's parameters, with stop among
+// the stop sequences when set. It also returns the stop sequences.
+func (p *TGIProvider) payload(
+	ctx context.Context, inputs, stop string,
+) (map[string]interface{}, []string, error) {
+	parameters := map[string]interface{}{"details": true, "return_full_text": false}
+	if err := applyParams(ctx, p.name, parameters, tgiParams); err != nil {
+		return nil, nil, err
+	}
+	stops, _ := parameters["stop"].([]string)
+	if stop != "" && !slices.Contains(stops, stop) {
+		stops = append(stops, stop)
+		parameters["stop"] = stops
+	}
+	return map[string]interface{}{"inputs": inputs, "parameters": parameters}, stops, nil
+}
+
+func (p *TGIProvider) headers() map[string]string {
+	if p.apiKey == "" {
+		return nil
+	}
+	return map[string]string{"Authorization": "Bearer " + p.apiKey}
+}
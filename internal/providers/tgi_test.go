@@ -0,0 +1,102 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestTGIProvider(t *testing.T) {
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer hf-key", r.Header.Get("Authorization"))
+		switch r.URL.Path {
+		case "/info":
+			_, _ = w.Write([]byte(`{"model_id":"Qwen/Qwen2.5-7B-Instruct","max_stop_sequences":4}`))
+			return
+		case "/generate_stream":
+			// TGI leaves out the space after data:
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data:{\"token\":{\"id\":1,\"text\":\"Hi\",\"special\":false}}\n\n"+
+				"data:{\"token\":{\"id\":2,\"text\":\"<|im_end|>\",\"special\":true},\"generated_text\":\"Hi\","+
+				"\"details\":{\"finish_reason\":\"eos_token\",\"generated_tokens\":2}}\n\n")
+			return
+		}
+		assert.Equal(t, "/generate", r.URL.Path)
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+		_, _ = w.Write([]byte(`{"generated_text":"Hi there END",` +
+			`"details":{"finish_reason":"stop_sequence","generated_tokens":3}}`))
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(&config.Provider{
+		Name: "tgi", Type: "tgi", BaseURL: server.URL, APIKey: "hf-key",
+		TGI: config.ProviderTGI{Template: "chatml"},
+	})
+	require.NotNil(t, provider)
+	models, err := provider.ListModels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"Qwen/Qwen2.5-7B-Instruct"}, ModelIDs(models))
+
+	params := NewParams(map[string]interface{}{
+		"temperature": json.Number("0"), "max_tokens": json.Number("64"), "stop": "END",
+		"response_format": map[string]interface{}{"type": "json_object"},
+	}, nil)
+	messages := []map[string]interface{}{
+		{"role": "system", "content": "Be brief."},
+		{"role": "user", "content": "Hello"},
+	}
+	result, err := provider.ChatCompletion(WithParams(t.Context(), params), "Qwen/Qwen2.5-7B-Instruct", messages)
+	require.NoError(t, err)
+	response := result.(map[string]interface{})
+	assert.Regexp(t, "^chatcmpl-", response["id"])
+	assert.Equal(t, "Qwen/Qwen2.5-7B-Instruct", response["model"])
+	choice := response["choices"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Hi there ", choice["message"].(map[string]interface{})["content"], "the stop sequence is cut")
+	assert.Equal(t, "stop", choice["finish_reason"])
+
+	assert.Equal(t, "<|im_start|>system\nBe brief.<|im_end|>\n<|im_start|>user\nHello<|im_end|>\n"+
+		"<|im_start|>assistant\n", payloads[0]["inputs"])
+	parameters := payloads[0]["parameters"].(map[string]interface{})
+	assert.Equal(t, []interface{}{"END", "<|im_end|>"}, parameters["stop"])
+	assert.Equal(t, float64(64), parameters["max_new_tokens"])
+	assert.NotContains(t, parameters, "temperature", "temperature 0 is greedy decoding")
+	assert.Equal(t, map[string]interface{}{"type": "json", "value": map[string]interface{}{"type": "object"}},
+		parameters["grammar"])
+
+	_, err = provider.Completion(t.Context(), "Qwen/Qwen2.5-7B-Instruct", "Once upon a time")
+	require.NoError(t, err)
+	assert.Equal(t, "Once upon a time", payloads[1]["inputs"], "prompts are sent as they are")
+	assert.NotContains(t, payloads[1]["parameters"], "stop")
+
+	stream, err := provider.ChatCompletionStream(t.Context(), "Qwen/Qwen2.5-7B-Instruct", messages)
+	require.NoError(t, err)
+	var chunks []map[string]interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk.(map[string]interface{}))
+	}
+	require.Len(t, chunks, 2)
+	assert.Equal(t, chunks[0]["id"], chunks[1]["id"])
+	delta := chunks[0]["choices"].([]interface{})[0].(map[string]interface{})["delta"]
+	assert.Equal(t, map[string]interface{}{"role": "assistant", "content": "Hi"}, delta)
+	assert.Equal(t, "stop", chunks[1]["choices"].([]interface{})[0].(map[string]interface{})["finish_reason"])
+	assert.Equal(t, float64(2), chunks[1]["usage"].(map[string]interface{})["completion_tokens"])
+}
+
+func TestTGIProvider_N(t *testing.T) {
+	provider := NewTGIProvider(&config.Provider{Name: "tgi", Type: "tgi", TGI: config.ProviderTGI{Template: "chatml"}})
+	ctx := WithParams(t.Context(), NewParams(map[string]interface{}{"n": json.Number("2")}, nil))
+	_, err := provider.ChatCompletion(ctx, "tgi", userMessage)
+	var invalid *InvalidParamError
+	require.ErrorAs(t, err, &invalid)
+	assert.Equal(t, "n", invalid.Param)
+}
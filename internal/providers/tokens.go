@@ -0,0 +1,65 @@
+package providers
+
+import "github.com/modelplex/modelplex/internal/config"
+
+// charsPerToken is the rough size of a token used to estimate prompt sizes without a tokenizer
+const charsPerToken = 4
+
+// EstimateMessagesTokens estimates the prompt size of messages from their text content.
+func EstimateMessagesTokens(messages []map[string]interface{}) int64 {
+	var chars int
+	for _, message := range messages {
+		switch content := message["content"].(type) {
+		case string:
+			chars += len(content)
+		case []interface{}:
+			// Multi-part content; only text parts are counted
+			for _, part := range content {
+				if part, ok := part.(map[string]interface{}); ok {
+					text, _ := part["text"].(string)
+					chars += len(text)
+				}
+			}
+		}
+	}
+	return int64((chars + charsPerToken - 1) / charsPerToken)
+}
+
+// EstimateTextTokens estimates the prompt size of a completion prompt.
+func EstimateTextTokens(prompt string) int64 {
+	return int64((len(prompt) + charsPerToken - 1) / charsPerToken)
+}
+
+// MaxCompletionTokens returns the most tokens the provider cfg lets a completion of model take
+// after a prompt of promptTokens when the request sets no limit, and whether that is known.
+// It is known when the model's limits are configured, and for Anthropic, which always sends one.
+func MaxCompletionTokens(cfg *config.Provider, model string, promptTokens int64) (int64, bool) {
+	// Limits are keyed by the backend's model names
+	if backend, ok := cfg.ModelMap[model]; ok {
+		model = backend
+	}
+	if tokens, ok := completionLimit(cfg.ModelLimits[model], promptTokens); ok {
+		return tokens, true
+	}
+	if cfg.Type == "anthropic" {
+		return defaultMaxTokens, true
+	}
+	return 0, false
+}
+
+// completionLimit returns what is left of the context window of limits after a prompt of
+// promptTokens, capped at the output limit, or false when neither limit is configured.
+func completionLimit(limits config.ModelLimits, promptTokens int64) (int64, bool) {
+	if limits.ContextWindow == 0 && limits.MaxOutputTokens == 0 {
+		return 0, false
+	}
+	if limits.ContextWindow == 0 {
+		return limits.MaxOutputTokens, true
+	}
+	// A prompt that fills the window is left for the API to refuse
+	tokens := max(limits.ContextWindow-promptTokens, 1)
+	if limits.MaxOutputTokens > 0 {
+		tokens = min(tokens, limits.MaxOutputTokens)
+	}
+	return tokens, true
+}
@@ -0,0 +1,20 @@
+package providers
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEstimateTokens(t *testing.T) {
+	messages := []map[string]interface{}{
+		{"role": "system", "content": "12345678"},
+		{"role": "user", "content": []interface{}{
+			map[string]interface{}{"type": "text", "text": "1234"},
+			map[string]interface{}{"type": "image_url", "image_url": map[string]interface{}{"url": "https://example.com"}},
+		}},
+	}
+	assert.Equal(t, int64(3), EstimateMessagesTokens(messages))
+	assert.Equal(t, int64(1), EstimateTextTokens("a"))
+	assert.Equal(t, int64(0), EstimateTextTokens(""))
+}
@@ -0,0 +1,84 @@
+// Package providers implements AI provider abstractions.
+// This file contains the HTTP transport that applies per-provider extra headers and
+// query parameters, which some gateways require on every call (api-version, tenant IDs).
+package providers
+
+import (
+	"net/http"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// extrasTransport adds configured headers and query parameters to every outgoing request.
+// Working at the transport level covers every request path, streaming and model listing included.
+type extrasTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+	query   map[string]string
+}
+
+// newHTTPClient creates the client a provider uses for its API calls.
+func newHTTPClient(cfg *config.Provider) *http.Client {
+	client := &http.Client{}
+	if len(cfg.ExtraHeaders) > 0 || len(cfg.ExtraQuery) > 0 {
+		client.Transport = newExtrasTransport(cfg)
+	}
+	// Below the faults, so synthetic responses aren't taken for the provider's
+	if len(cfg.CaptureHeaders) > 0 {
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		client.Transport = newHeaderTransport(cfg.Name, base, cfg.CaptureHeaders)
+	}
+	if hasFaults(&cfg.Faults) {
+		base := client.Transport
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		client.Transport = newFaultTransport(cfg.Name, base, cfg.Faults)
+	}
+	// Outermost, so paused calls reach neither the faults nor the provider
+	base := client.Transport
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	client.Transport = &pauseTransport{provider: cfg.Name, base: base}
+	return client
+}
+
+func newExtrasTransport(cfg *config.Provider) *extrasTransport {
+	t := &extrasTransport{
+		base:    http.DefaultTransport,
+		headers: make(map[string]string, len(cfg.ExtraHeaders)),
+		query:   make(map[string]string, len(cfg.ExtraQuery)),
+	}
+	for key, value := range cfg.ExtraHeaders {
+		t.headers[key] = resolveEnv(value)
+	}
+	for key, value := range cfg.ExtraQuery {
+		t.query[key] = resolveEnv(value)
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+// Extras override built-in values of the same name so a gateway can replace e.g. anthropic-version.
+func (t *extrasTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrippers must not modify the caller's request
+	req = req.Clone(req.Context())
+
+	for key, value := range t.headers {
+		req.Header.Set(key, value)
+	}
+
+	if len(t.query) > 0 {
+		query := req.URL.Query()
+		for key, value := range t.query {
+			query.Set(key, value)
+		}
+		req.URL.RawQuery = query.Encode()
+	}
+
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,133 @@
+package providers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestExtraHeadersAndQuery(t *testing.T) {
+	t.Setenv("TEST_GATEWAY_TENANT", "tenant-42")
+
+	type seen struct {
+		path       string
+		apiVersion string
+		tenant     string
+		anthropic  string
+	}
+	requests := make(chan seen, 2)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests <- seen{
+			path:       r.URL.Path,
+			apiVersion: r.URL.Query().Get("api-version"),
+			tenant:     r.Header.Get("X-Tenant-ID"),
+			anthropic:  r.Header.Get("anthropic-version"),
+		}
+		var body struct {
+			Stream bool `json:"stream"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		if body.Stream {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = w.Write([]byte("data: [DONE]\n\n"))
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"msg","content":[{"type":"text","text":"hi"}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider(&config.Provider{
+		Name:    "gateway",
+		BaseURL: server.URL,
+		APIKey:  "key",
+		ExtraHeaders: map[string]string{
+			"X-Tenant-ID":       "${TEST_GATEWAY_TENANT}",
+			"anthropic-version": "2024-01-01",
+		},
+		ExtraQuery: map[string]string{"api-version": "2024-06-01"},
+	})
+
+	messages := []map[string]interface{}{{"role": "user", "content": "hi"}}
+	_, err := provider.ChatCompletion(t.Context(), "claude-3", messages)
+	require.NoError(t, err)
+
+	stream, err := provider.ChatCompletionStream(t.Context(), "claude-3", messages)
+	require.NoError(t, err)
+	for range stream {
+	}
+
+	for range 2 {
+		r := <-requests
+		assert.Equal(t, "/messages", r.path)
+		assert.Equal(t, "2024-06-01", r.apiVersion)
+		assert.Equal(t, "tenant-42", r.tenant)
+		assert.Equal(t, "2024-01-01", r.anthropic, "extras override built-in headers")
+	}
+}
+
+func TestNewHTTPClient_NoExtras(t *testing.T) {
+	client := newHTTPClient(&config.Provider{Name: "plain"})
+	paused, ok := client.Transport.(*pauseTransport)
+	require.True(t, ok, "every client can be paused")
+	assert.Equal(t, http.DefaultTransport, paused.base)
+}
+
+func TestExtrasTransport_KeepsExistingQuery(t *testing.T) {
+	var rawQuery string
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		rawQuery = r.URL.RawQuery
+	}))
+	defer server.Close()
+
+	client := newHTTPClient(&config.Provider{ExtraQuery: map[string]string{"api-version": "v1"}})
+	req, err := http.NewRequestWithContext(t.Context(), "GET", server.URL+"/models?limit=10", http.NoBody)
+	require.NoError(t, err)
+
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	_ = resp.Body.Close()
+
+	assert.Equal(t, "api-version=v1&limit=10", rawQuery)
+	assert.Equal(t, "limit=10", req.URL.RawQuery, "the caller's request must not be modified")
+}
+
+func TestCaptureHeaders(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		remaining := strconv.FormatInt(100-requests.Add(1), 10)
+		w.Header().Set("X-Request-Id", "req_"+remaining)
+		w.Header().Set("X-Ratelimit-Remaining-Requests", remaining)
+		w.Header().Set("X-Internal-Trace", "not captured")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"choices":[{"message":{"role":"assistant","content":"hi"}}]}`))
+	}))
+	defer server.Close()
+
+	provider := NewOpenAIProvider(&config.Provider{
+		Name: "capture-test", BaseURL: server.URL,
+		CaptureHeaders: []string{"x-request-id", "X-RateLimit-Remaining-Requests", "deprecation"},
+	})
+	_, err := provider.ChatCompletion(t.Context(), "gpt-4", userMessage)
+	require.NoError(t, err)
+
+	ctx, headers := WithResponseHeaders(t.Context())
+	_, err = provider.ChatCompletion(ctx, "gpt-4", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"x-request-id": "req_98", "x-ratelimit-remaining-requests": "98"},
+		headers.Values(), "the request's own responses are collected")
+
+	captured := CapturedHeaders("capture-test")
+	assert.Len(t, captured, 2)
+	assert.Equal(t, "98", captured["x-ratelimit-remaining-requests"].Value, "the last value is kept")
+	assert.False(t, captured["x-request-id"].SeenAt.IsZero())
+	assert.Nil(t, CapturedHeaders("never-responded"))
+}
@@ -0,0 +1,52 @@
+// Package providers implements AI provider abstractions.
+// VoyageProvider embeds texts with Voyage AI's embeddings API, e.g. voyage-3.5:
+// - The base URL is the API's, e.g. https://api.voyageai.com/v1
+// - Authentication is a bearer API key
+// - dimensions is sent as output_dimension and truncation as truncation
+// - It serves no completions, only the embeddings route
+package providers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// VoyageProvider implements the Embedder interface for Voyage AI's API.
+type VoyageProvider struct {
+	name    string
+	baseURL string
+	apiKey  string
+	client  *http.Client
+}
+
+// NewVoyageProvider creates a new Voyage AI provider instance.
+func NewVoyageProvider(cfg *config.Provider) *VoyageProvider {
+	return &VoyageProvider{
+		name:    cfg.Name,
+		baseURL: cfg.BaseURL,
+		apiKey:  resolveEnv(cfg.APIKey),
+		client:  newHTTPClient(cfg),
+	}
+}
+
+// Name returns the provider name.
+func (p *VoyageProvider) Name() string {
+	return p.name
+}
+
+// Embed implements Embedder.
+func (p *VoyageProvider) Embed(ctx context.Context, model string, req *EmbeddingRequest) (*EmbeddingResponse, error) {
+	payload := map[string]interface{}{
+		"model": model,
+		"input": req.Input,
+	}
+	if req.Dimensions > 0 {
+		payload["output_dimension"] = req.Dimensions
+	}
+	if req.Truncate != nil {
+		payload["truncation"] = *req.Truncate
+	}
+	return embed(ctx, p.client, p.apiKey, p.baseURL+"/embeddings", model, payload)
+}
@@ -0,0 +1,87 @@
+// Package providers implements AI provider abstractions.
+// XAIProvider serves xAI's API for Grok models, whose chat API is OpenAI's with these differences:
+// - logit_bias is unsupported; xAI's own parameters, such as search_parameters for live search,
+// pass through
+// - Reasoning models, grok-3-mini, grok-4 and grok-code among them, reject presence_penalty,
+// frequency_penalty and stop, and of them only grok-3-mini takes reasoning_effort
+// - Text completions go through chat, which every Grok model serves
+// - Without configured models, the served ones are listed from /models
+package providers
+
+import (
+	"context"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// xaiParams adapts OpenAI parameters to xAI's chat API for non-reasoning models.
+var xaiParams = &paramRules{
+	rules: map[string]paramRule{
+		"logit_bias":       {},
+		"reasoning_effort": {},
+	},
+	passthrough: true,
+}
+
+// xaiReasoningParams adapts OpenAI parameters to xAI's reasoning models, which reject sampling
+// penalties and stop sequences.
+var xaiReasoningParams = &paramRules{
+	rules: map[string]paramRule{
+		"logit_bias":        {},
+		"reasoning_effort":  {},
+		"presence_penalty":  {},
+		"frequency_penalty": {},
+		"stop":              {},
+	},
+	passthrough: true,
+}
+
+// xaiMiniParams adapts OpenAI parameters to grok-3-mini, the reasoning model whose effort is set.
+var xaiMiniParams = &paramRules{
+	rules: map[string]paramRule{
+		"logit_bias":        {},
+		"presence_penalty":  {},
+		"frequency_penalty": {},
+		"stop":              {},
+	},
+	passthrough: true,
+}
+
+// xaiParamsFor returns the parameter rules for model: reasoning models are grok-3-mini, grok-4
+// apart from its non-reasoning variants, and grok-code.
+func xaiParamsFor(model string) *paramRules {
+	name := strings.ToLower(model)
+	switch {
+	case strings.HasPrefix(name, "grok-3-mini"):
+		return xaiMiniParams
+	case strings.HasPrefix(name, "grok-4") && !strings.Contains(name, "non-reasoning"),
+		strings.HasPrefix(name, "grok-code"):
+		return xaiReasoningParams
+	}
+	return xaiParams
+}
+
+// XAIProvider implements the Provider interface for xAI.
+type XAIProvider struct {
+	*OpenAICompatibleProvider
+}
+
+// NewXAIProvider creates a new xAI provider instance.
+func NewXAIProvider(cfg *config.Provider) *XAIProvider {
+	provider := NewOpenAICompatibleProvider(cfg)
+	provider.params = xaiParamsFor
+	return &XAIProvider{OpenAICompatibleProvider: provider}
+}
+
+// Completion performs a completion request by sending the prompt as a user message, and returns
+// the reply in the legacy text completion schema.
+func (p *XAIProvider) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	return completionThroughChat(ctx, p.ChatCompletion, model, prompt)
+}
+
+// CompletionStream performs a streaming completion request, streaming the reply as legacy text
+// completion chunks.
+func (p *XAIProvider) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	return completionStreamThroughChat(ctx, p.ChatCompletionStream, model, prompt)
+}
@@ -0,0 +1,89 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestXAIProvider(t *testing.T) {
+	var requests []*http.Request
+	var payloads []map[string]interface{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r)
+		if r.Method == "GET" {
+			_, _ = w.Write([]byte(`{"object":"list","data":[{"id":"grok-4"},{"id":"grok-3-mini"}]}`))
+			return
+		}
+		var payload map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&payload))
+		payloads = append(payloads, payload)
+		if payload["stream"] == true {
+			w.Header().Set("Content-Type", "text/event-stream")
+			_, _ = fmt.Fprint(w, "data: {\"id\":\"x2\",\"model\":\"grok-3\","+
+				"\"choices\":[{\"index\":0,\"delta\":{\"role\":\"assistant\",\"content\":\"Hi\"}}]}\n\n"+
+				"data: {\"id\":\"x2\",\"model\":\"grok-3\","+
+				"\"choices\":[{\"index\":0,\"delta\":{},\"finish_reason\":\"stop\"}]}\n\n"+
+				"data: [DONE]\n\n")
+			return
+		}
+		_, _ = w.Write([]byte(`{"id":"x1","object":"chat.completion","model":"grok-4",` +
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"Hi there"},"finish_reason":"stop"}]}`))
+	}))
+	t.Cleanup(server.Close)
+
+	provider := NewProvider(&config.Provider{Name: "xai", Type: "xai", BaseURL: server.URL, APIKey: "xai-key"})
+	require.NotNil(t, provider)
+
+	models, err := provider.ListModels(t.Context())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"grok-4", "grok-3-mini"}, ModelIDs(models))
+	assert.Equal(t, "/models", requests[0].URL.Path)
+
+	ctx := WithParams(t.Context(), NewParams(map[string]interface{}{
+		"temperature": 0.3, "stop": "END", "reasoning_effort": "high",
+		"search_parameters": map[string]interface{}{"mode": "auto"},
+	}, nil))
+	result, err := provider.ChatCompletion(ctx, "grok-4", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "/chat/completions", requests[1].URL.Path)
+	assert.Equal(t, "Bearer xai-key", requests[1].Header.Get("Authorization"))
+	assert.Equal(t, 0.3, payloads[0]["temperature"])
+	assert.Equal(t, map[string]interface{}{"mode": "auto"}, payloads[0]["search_parameters"], "xAI's own pass through")
+	for _, name := range []string{"stop", "reasoning_effort"} {
+		assert.NotContains(t, payloads[0], name, "grok-4 rejects it")
+	}
+	choice := result.(map[string]interface{})["choices"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "Hi there", choice["message"].(map[string]interface{})["content"])
+
+	ctx = WithParams(t.Context(), NewParams(map[string]interface{}{"reasoning_effort": "low"}, nil))
+	_, err = provider.ChatCompletion(ctx, "grok-3-mini", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "low", payloads[1]["reasoning_effort"])
+
+	stream, err := provider.CompletionStream(t.Context(), "grok-3", "Hello")
+	require.NoError(t, err)
+	assert.Equal(t, "/chat/completions", requests[3].URL.Path, "text completions go through chat")
+	var texts []interface{}
+	for chunk := range stream {
+		for _, choice := range chunk.(map[string]interface{})["choices"].([]interface{}) {
+			texts = append(texts, choice.(map[string]interface{})["text"])
+		}
+	}
+	assert.Equal(t, []interface{}{"Hi", ""}, texts)
+}
+
+func TestXAIParamsFor(t *testing.T) {
+	assert.Same(t, xaiParams, xaiParamsFor("grok-3"))
+	assert.Same(t, xaiParams, xaiParamsFor("grok-4-fast-non-reasoning"))
+	assert.Same(t, xaiMiniParams, xaiParamsFor("grok-3-mini-fast"))
+	assert.Same(t, xaiReasoningParams, xaiParamsFor("grok-4-0709"))
+	assert.Same(t, xaiReasoningParams, xaiParamsFor("grok-code-fast-1"))
+}
@@ -0,0 +1,116 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// EmbeddingRequest represents an OpenAI embeddings request. Inputs that are lists of token IDs
+// aren't supported, since embedding providers tokenize themselves. Truncation is taken as Jina's
+// truncate or Voyage's truncation.
+type EmbeddingRequest struct {
+	Model          string          `json:"model"`
+	Input          json.RawMessage `json:"input"`
+	Dimensions     int             `json:"dimensions,omitempty"`
+	EncodingFormat string          `json:"encoding_format,omitempty"`
+	Truncate       *bool           `json:"truncate,omitempty"`
+	Truncation     *bool           `json:"truncation,omitempty"`
+}
+
+// HandleEmbeddings handles embedding requests, answering in OpenAI's shape.
+func (p *OpenAIProxy) HandleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	var req EmbeddingRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+	input, ok := embeddingInput(req.Input)
+	switch {
+	case req.Model == "":
+		writeError(w, http.StatusBadRequest, "model is required")
+		return
+	case !ok:
+		writeError(w, http.StatusBadRequest, "input must be a non-empty string or array of strings")
+		return
+	case req.Dimensions < 0:
+		writeError(w, http.StatusBadRequest, "dimensions must not be negative")
+		return
+	case req.EncodingFormat != "" && req.EncodingFormat != "float" && req.EncodingFormat != "base64":
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("unsupported encoding_format %q", req.EncodingFormat))
+		return
+	}
+	if p.embedder == nil {
+		writeError(w, http.StatusNotFound, "Embeddings are not available")
+		return
+	}
+
+	truncate := req.Truncate
+	if truncate == nil {
+		truncate = req.Truncation
+	}
+	model := p.normalizeModel(req.Model)
+	response, err := p.embedder.Embed(r.Context(), model, &providers.EmbeddingRequest{
+		Input: input, Dimensions: req.Dimensions, Truncate: truncate,
+	})
+	if errors.Is(err, providers.ErrNoEmbedder) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	var status *providers.StatusError
+	if errors.As(err, &status) && status.StatusCode < http.StatusInternalServerError {
+		// The request itself is invalid, e.g. an input too long without truncation; the provider explains why
+		slog.Debug("Embedding provider rejected request", "model", model, "status", status.StatusCode)
+		writeError(w, status.StatusCode, status.Body)
+		return
+	}
+	if err != nil {
+		p.writeRequestError(w, err, "embeddings")
+		return
+	}
+
+	response.Model = req.Model
+	if req.EncodingFormat == "base64" {
+		p.writeJSONResponse(w, base64Embeddings(response), "embeddings")
+		return
+	}
+	p.writeJSONResponse(w, response, "embeddings")
+}
+
+// embeddingInput returns the texts of an embedding request's input, given as a string or an array of strings.
+func embeddingInput(raw json.RawMessage) ([]string, bool) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return []string{text}, text != ""
+	}
+	var texts []string
+	if err := json.Unmarshal(raw, &texts); err != nil || len(texts) == 0 {
+		return nil, false
+	}
+	return texts, true
+}
+
+// base64Embeddings encodes the embeddings of response as OpenAI does for encoding_format base64:
+// little-endian float32s, which OpenAI's SDKs ask for by default.
+func base64Embeddings(response *providers.EmbeddingResponse) map[string]interface{} {
+	data := make([]map[string]interface{}, len(response.Data))
+	for i, embedding := range response.Data {
+		buf := make([]byte, 4*len(embedding.Embedding))
+		for j, value := range embedding.Embedding {
+			binary.LittleEndian.PutUint32(buf[4*j:], math.Float32bits(float32(value)))
+		}
+		data[i] = map[string]interface{}{
+			"object": embedding.Object, "index": embedding.Index, "embedding": base64.StdEncoding.EncodeToString(buf),
+		}
+	}
+	return map[string]interface{}{
+		"object": response.Object, "data": data, "model": response.Model, "usage": response.Usage,
+	}
+}
@@ -0,0 +1,94 @@
+package proxy
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+type stubEmbedder struct {
+	request *providers.EmbeddingRequest
+}
+
+func (s *stubEmbedder) Embed(
+	_ context.Context, model string, req *providers.EmbeddingRequest,
+) (*providers.EmbeddingResponse, error) {
+	if model != "voyage-3.5" {
+		return nil, fmt.Errorf("%w for model %s", providers.ErrNoEmbedder, model)
+	}
+	s.request = req
+	return &providers.EmbeddingResponse{Object: "list", Model: model, Data: []providers.Embedding{
+		{Object: "embedding", Embedding: []float64{0.5, -1}, Index: 0},
+	}}, nil
+}
+
+func TestOpenAIProxy_HandleEmbeddings(t *testing.T) {
+	embedder := &stubEmbedder{}
+	proxy := New(&MockMultiplexer{}, WithEmbedder(embedder))
+	truncate := true
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		expected *providers.EmbeddingRequest
+	}{
+		{
+			name:     "string input",
+			body:     `{"model":"modelplex-voyage-3.5","input":"a","dimensions":512,"truncation":true}`,
+			status:   http.StatusOK,
+			expected: &providers.EmbeddingRequest{Input: []string{"a"}, Dimensions: 512, Truncate: &truncate},
+		},
+		{
+			name:     "base64",
+			body:     `{"model":"voyage-3.5","input":["a","b"],"encoding_format":"base64","truncate":true}`,
+			status:   http.StatusOK,
+			expected: &providers.EmbeddingRequest{Input: []string{"a", "b"}, Truncate: &truncate},
+		},
+		{name: "token input", body: `{"model":"voyage-3.5","input":[[1,2]]}`, status: http.StatusBadRequest},
+		{name: "empty input", body: `{"model":"voyage-3.5","input":""}`, status: http.StatusBadRequest},
+		{name: "unknown model", body: `{"model":"gpt-4","input":"a"}`, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			proxy.HandleEmbeddings(w, httptest.NewRequest("POST", "/v1/embeddings", strings.NewReader(tt.body)))
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, tt.expected, embedder.request)
+			var response struct {
+				Model string `json:"model"`
+				Data  []struct {
+					Embedding json.RawMessage `json:"embedding"`
+				} `json:"data"`
+			}
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			assert.True(t, strings.HasSuffix(response.Model, "voyage-3.5"))
+			if !strings.Contains(tt.body, "base64") {
+				assert.JSONEq(t, `[0.5,-1]`, string(response.Data[0].Embedding))
+				return
+			}
+			var encoded string
+			require.NoError(t, json.Unmarshal(response.Data[0].Embedding, &encoded))
+			raw, err := base64.StdEncoding.DecodeString(encoded)
+			require.NoError(t, err)
+			require.Len(t, raw, 8)
+			assert.Equal(t, float32(-1), math.Float32frombits(binary.LittleEndian.Uint32(raw[4:])))
+		})
+	}
+}
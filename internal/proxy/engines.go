@@ -0,0 +1,84 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+
+	"github.com/gorilla/mux"
+)
+
+// EnginesResponse represents the legacy OpenAI engines list response.
+type EnginesResponse struct {
+	Object   string       `json:"object"`
+	Data     []EngineInfo `json:"data"`
+	Warnings []string     `json:"warnings,omitempty"`
+}
+
+// EngineInfo represents a model as a legacy OpenAI engine.
+type EngineInfo struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"`
+	Created int64  `json:"created"`
+	Owner   string `json:"owner"`
+	Ready   bool   `json:"ready"`
+}
+
+// HandleEngines lists the models as engines, for tools written against the API before models
+// replaced engines.
+func (p *OpenAIProxy) HandleEngines(w http.ResponseWriter, r *http.Request) {
+	models, warnings := p.mux.ListModelsContext(r.Context())
+
+	data := make([]EngineInfo, len(models))
+	for i, model := range models {
+		data[i] = engineInfo(model)
+	}
+	p.writeJSONResponse(w, EnginesResponse{Object: "list", Data: data, Warnings: warnings}, "engines")
+}
+
+// HandleEngine describes the model an engine name stands for.
+func (p *OpenAIProxy) HandleEngine(w http.ResponseWriter, r *http.Request) {
+	engine := mux.Vars(r)["engine"]
+	models, _ := p.mux.ListModelsContext(r.Context())
+	if !slices.Contains(models, p.normalizeModel(engine)) {
+		writeError(w, http.StatusNotFound, fmt.Sprintf("The engine %q does not exist", engine))
+		return
+	}
+	p.writeJSONResponse(w, engineInfo(engine), "engine")
+}
+
+// HandleEngineCompletions handles legacy completion requests, which name the model in the path
+// instead of the body; the engine replaces any model the body names.
+func (p *OpenAIProxy) HandleEngineCompletions(w http.ResponseWriter, r *http.Request) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read request body")
+		return
+	}
+	var values map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+	if values == nil {
+		values = make(map[string]interface{})
+	}
+	values["model"] = mux.Vars(r)["engine"]
+	if body, err = json.Marshal(values); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	r.ContentLength = int64(len(body))
+	p.HandleCompletions(w, r)
+}
+
+func engineInfo(model string) EngineInfo {
+	return EngineInfo{ID: model, Object: "engine", Created: defaultModelCreated, Owner: "modelplex", Ready: true}
+}
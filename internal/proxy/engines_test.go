@@ -0,0 +1,59 @@
+package proxy
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAIProxy_HandleEngines(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	proxy := New(mockMux)
+	mockMux.On("ListModelsContext", mock.Anything).Return([]string{"gpt-4", "davinci-002"}, nil)
+
+	w := httptest.NewRecorder()
+	proxy.HandleEngines(w, httptest.NewRequest("GET", "/v1/engines", http.NoBody))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response EnginesResponse
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, "list", response.Object)
+	assert.Equal(t, []EngineInfo{
+		{ID: "gpt-4", Object: "engine", Created: defaultModelCreated, Owner: "modelplex", Ready: true},
+		{ID: "davinci-002", Object: "engine", Created: defaultModelCreated, Owner: "modelplex", Ready: true},
+	}, response.Data)
+
+	for engine, status := range map[string]int{"davinci-002": http.StatusOK, "davinci": http.StatusNotFound} {
+		w = httptest.NewRecorder()
+		req := mux.SetURLVars(httptest.NewRequest("GET", "/v1/engines/"+engine, http.NoBody),
+			map[string]string{"engine": engine})
+		proxy.HandleEngine(w, req)
+		assert.Equal(t, status, w.Code, engine)
+	}
+}
+
+func TestOpenAIProxy_HandleEngineCompletions(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	proxy := New(mockMux)
+	mockResponse := map[string]interface{}{"id": "cmpl-123", "object": "text_completion"}
+	mockMux.On("Completion", mock.Anything, "davinci-002", "Once upon a time").Return(mockResponse, nil)
+
+	body := `{"prompt":"Once upon a time","model":"ignored","max_tokens":16}`
+	req := mux.SetURLVars(httptest.NewRequest("POST", "/v1/engines/davinci-002/completions", strings.NewReader(body)),
+		map[string]string{"engine": "davinci-002"})
+	w := httptest.NewRecorder()
+	proxy.HandleEngineCompletions(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+	assert.Equal(t, mockResponse, response)
+	mockMux.AssertExpectations(t)
+}
@@ -1,14 +1,28 @@
 package proxy
 
-import "context"
+import (
+	"context"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
 
 // Multiplexer defines the interface for model multiplexing
 type Multiplexer interface {
 	ChatCompletion(ctx context.Context, model string, messages []map[string]interface{}) (interface{}, error)
 	Completion(ctx context.Context, model, prompt string) (interface{}, error)
-	ListModels() []string
+	ListModelsContext(ctx context.Context) (models, warnings []string)
 
 	// Streaming methods
 	ChatCompletionStream(ctx context.Context, model string, messages []map[string]interface{}) (<-chan interface{}, error)
 	CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error)
 }
+
+// Reranker ranks documents by their relevance to a query
+type Reranker interface {
+	Rerank(ctx context.Context, model string, req *providers.RerankRequest) (*providers.RerankResponse, error)
+}
+
+// Embedder embeds texts
+type Embedder interface {
+	Embed(ctx context.Context, model string, req *providers.EmbeddingRequest) (*providers.EmbeddingResponse, error)
+}
@@ -2,11 +2,30 @@
 package proxy
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net/http"
+	"strconv"
 	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/modelplex/modelplex/internal/broadcast"
+	"github.com/modelplex/modelplex/internal/catalog"
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/provenance"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/reasoning"
+	"github.com/modelplex/modelplex/internal/resume"
+	"github.com/modelplex/modelplex/internal/strict"
+	"github.com/modelplex/modelplex/internal/toolcalls"
 )
 
 const (
@@ -14,14 +33,137 @@ const (
 	defaultModelCreated = 1677610602
 )
 
+// requestFields are decoded into the request types; every other field is passed on as a parameter.
+var requestFields = []string{"model", "messages", "prompt", "stream"}
+
 // OpenAIProxy provides OpenAI-compatible HTTP endpoints.
 type OpenAIProxy struct {
 	mux Multiplexer
+	// streams is nil unless streaming responses are resumable
+	streams *resume.Registry
+	// parameters decides what happens to parameters a provider can't honor; nil drops them with a warning
+	parameters *config.ParametersConfig
+	// observers also consume every streaming generation besides the client
+	observers []StreamObserver
+	// reasoning normalizes reasoning output in responses; nil passes it through
+	reasoning *reasoning.Normalizer
+	// assembleToolCalls reports whether a request's streamed tool calls are sent whole; nil never does
+	assembleToolCalls func(r *http.Request) bool
+	// describe returns what is known about a model for listings; nil lists names only
+	describe func(model string) (catalog.Model, bool)
+	// tags picks the metadata keys reported as tags; nil reports none
+	tags *config.TagsConfig
+	// strict rewrites responses into exactly the OpenAI schema; nil passes them through
+	strict *strict.Normalizer
+	// provenance stamps responses with where they came from; nil doesn't
+	provenance *provenance.Stamper
+	// reranker serves rerank requests; nil rejects them
+	reranker Reranker
+	// embedder serves embedding requests; nil rejects them
+	embedder Embedder
+}
+
+// StreamObserver is handed each streaming generation as it starts, together with the request and model.
+// It reads the stream at its own pace, e.g. by subscribing; the client is never held up by it.
+type StreamObserver func(r *http.Request, model string, stream *broadcast.Stream)
+
+// Option configures optional proxy behavior.
+type Option func(*OpenAIProxy)
+
+// WithResumableStreams buffers streaming responses in streams so clients can resume them after a disconnect.
+func WithResumableStreams(streams *resume.Registry) Option {
+	return func(p *OpenAIProxy) {
+		p.streams = streams
+	}
+}
+
+// WithParameterPolicies applies cfg to request parameters the serving provider can't honor.
+func WithParameterPolicies(cfg *config.ParametersConfig) Option {
+	return func(p *OpenAIProxy) {
+		p.parameters = cfg
+	}
+}
+
+// WithReasoning returns reasoning models' intermediate output as cfg says.
+func WithReasoning(cfg *config.ReasoningConfig) Option {
+	return func(p *OpenAIProxy) {
+		p.reasoning = reasoning.New(cfg)
+	}
+}
+
+// WithStrictOpenAI rewrites every response into exactly the OpenAI schema when enabled, for SDKs
+// that reject missing or extra fields.
+func WithStrictOpenAI(enabled bool) Option {
+	return func(p *OpenAIProxy) {
+		p.strict = strict.New(enabled)
+	}
+}
+
+// WithProvenance stamps responses with their model, provider, id, time and a hash of their text as cfg says.
+func WithProvenance(cfg *config.ProvenanceConfig) Option {
+	return func(p *OpenAIProxy) {
+		p.provenance = provenance.New(cfg)
+	}
+}
+
+// WithToolCallAssembly sends the streamed tool calls of requests for which assemble returns true
+// whole, once complete, instead of in fragments.
+func WithToolCallAssembly(assemble func(r *http.Request) bool) Option {
+	return func(p *OpenAIProxy) {
+		p.assembleToolCalls = assemble
+	}
+}
+
+// WithCatalog describes listed models by c, with the prices and token limits of cfg taking precedence.
+func WithCatalog(c catalog.Catalog, cfg *config.Config) Option {
+	return func(p *OpenAIProxy) {
+		p.describe = func(model string) (catalog.Model, bool) { return c.Describe(cfg, model) }
+	}
+}
+
+// WithTags attaches the metadata keys cfg allows to requests as their tags, rejecting requests
+// carrying more than cfg.MaxPerRequest of them.
+func WithTags(cfg *config.TagsConfig) Option {
+	return func(p *OpenAIProxy) {
+		if len(cfg.Allowed) > 0 {
+			p.tags = cfg
+		}
+	}
+}
+
+// WithStreamObserver hands every streaming generation to observer as well as the client.
+func WithStreamObserver(observer StreamObserver) Option {
+	return func(p *OpenAIProxy) {
+		p.observers = append(p.observers, observer)
+	}
+}
+
+// WithReranker serves rerank requests with r.
+func WithReranker(r Reranker) Option {
+	return func(p *OpenAIProxy) {
+		p.reranker = r
+	}
+}
+
+// WithEmbedder serves embedding requests with e.
+func WithEmbedder(e Embedder) Option {
+	return func(p *OpenAIProxy) {
+		p.embedder = e
+	}
 }
 
 // New creates a new OpenAI proxy with the given multiplexer.
-func New(mux Multiplexer) *OpenAIProxy {
-	return &OpenAIProxy{mux: mux}
+func New(mux Multiplexer, opts ...Option) *OpenAIProxy {
+	p := &OpenAIProxy{mux: mux}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Multiplexer returns the multiplexer requests are served with, so other APIs can share it.
+func (p *OpenAIProxy) Multiplexer() Multiplexer {
+	return p.mux
 }
 
 // ChatCompletionRequest represents an OpenAI chat completion request.
@@ -40,8 +182,9 @@ type CompletionRequest struct {
 
 // ModelsResponse represents an OpenAI models list response.
 type ModelsResponse struct {
-	Object string      `json:"object"`
-	Data   []ModelInfo `json:"data"`
+	Object   string      `json:"object"`
+	Data     []ModelInfo `json:"data"`
+	Warnings []string    `json:"warnings,omitempty"`
 }
 
 // ModelInfo represents information about a single model.
@@ -50,12 +193,15 @@ type ModelInfo struct {
 	Object  string `json:"object"`
 	Created int64  `json:"created"`
 	OwnedBy string `json:"owned_by"`
+	// Model adds the context window, modalities and pricing of models that are known
+	*catalog.Model
 }
 
 // HandleChatCompletions handles chat completion requests.
 func (p *OpenAIProxy) HandleChatCompletions(w http.ResponseWriter, r *http.Request) {
 	var req ChatCompletionRequest
-	if err := p.decodeJSONRequest(r, &req, w); err != nil {
+	r, err := p.decodeJSONRequest(r, &req, w)
+	if err != nil {
 		return
 	}
 
@@ -71,7 +217,8 @@ func (p *OpenAIProxy) HandleChatCompletions(w http.ResponseWriter, r *http.Reque
 // HandleCompletions handles completion requests.
 func (p *OpenAIProxy) HandleCompletions(w http.ResponseWriter, r *http.Request) {
 	var req CompletionRequest
-	if err := p.decodeJSONRequest(r, &req, w); err != nil {
+	r, err := p.decodeJSONRequest(r, &req, w)
+	if err != nil {
 		return
 	}
 
@@ -85,8 +232,9 @@ func (p *OpenAIProxy) HandleCompletions(w http.ResponseWriter, r *http.Request)
 }
 
 // HandleModels handles model listing requests.
-func (p *OpenAIProxy) HandleModels(w http.ResponseWriter, _ *http.Request) {
-	models := p.mux.ListModels()
+// Partial results are returned when some providers fail, with the failures listed under warnings.
+func (p *OpenAIProxy) HandleModels(w http.ResponseWriter, r *http.Request) {
+	models, warnings := p.mux.ListModelsContext(r.Context())
 
 	data := make([]ModelInfo, len(models))
 	for i, model := range models {
@@ -96,65 +244,391 @@ func (p *OpenAIProxy) HandleModels(w http.ResponseWriter, _ *http.Request) {
 			Created: defaultModelCreated,
 			OwnedBy: "modelplex",
 		}
+		if p.describe != nil {
+			if description, ok := p.describe(model); ok {
+				data[i].Model = &description
+			}
+		}
 	}
 
 	response := ModelsResponse{
-		Object: "list",
-		Data:   data,
+		Object:   "list",
+		Data:     data,
+		Warnings: warnings,
 	}
 
 	p.writeJSONResponse(w, response, "models")
 }
 
+// HandleStreamResume continues a resumable stream from the event after Last-Event-ID, or the
+// "after" query parameter, replaying buffered deltas before following the live generation.
+func (p *OpenAIProxy) HandleStreamResume(w http.ResponseWriter, r *http.Request) {
+	if p.streams == nil {
+		writeError(w, http.StatusNotFound, "Resumable streams are not enabled")
+		return
+	}
+	stream, ok := p.streams.Lookup(r, mux.Vars(r)["token"])
+	if !ok {
+		writeError(w, http.StatusNotFound, "Unknown or expired resume token")
+		return
+	}
+
+	lastEventID := r.Header.Get(resume.LastEventIDHeader)
+	if lastEventID == "" {
+		lastEventID = r.URL.Query().Get("after")
+	}
+	after := 0
+	if lastEventID != "" {
+		var err error
+		after, err = strconv.Atoi(lastEventID)
+		if err != nil || after < 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid last event ID %q", lastEventID))
+			return
+		}
+	}
+
+	slog.Debug("Resuming stream", "after", after)
+	p.writeResumableSSEResponse(w, r, stream, after, "stream resume")
+}
+
 func (p *OpenAIProxy) handleChatCompletionStream(w http.ResponseWriter, r *http.Request,
 	model string, messages []map[string]interface{}) {
-	streamChan, err := p.mux.ChatCompletionStream(r.Context(), model, messages)
+	ctx, cancel := p.streamContext(r)
+	streamChan, err := p.mux.ChatCompletionStream(ctx, model, messages)
 	if err != nil {
-		slog.Error("Chat completion stream failed", "error", err)
-		writeError(w, http.StatusInternalServerError, "Internal server error")
+		cancel()
+		p.writeRequestError(w, err, "chat completion stream")
 		return
 	}
-	p.writeSSEResponse(w, streamChan, "chat completion stream")
+	writeWarnings(w, r)
+	p.writeStream(w, r, model, true, streamChan, cancel, "chat completion stream")
 }
 
 func (p *OpenAIProxy) handleChatCompletion(w http.ResponseWriter, r *http.Request,
 	model string, messages []map[string]interface{}) {
 	result, err := p.mux.ChatCompletion(r.Context(), model, messages)
-	p.handleResponse(w, result, err, "chat completion")
+	p.handleResponse(w, r, model, true, result, err, "chat completion")
 }
 
 func (p *OpenAIProxy) handleCompletionStream(w http.ResponseWriter, r *http.Request, model, prompt string) {
-	streamChan, err := p.mux.CompletionStream(r.Context(), model, prompt)
+	ctx, cancel := p.streamContext(r)
+	streamChan, err := p.mux.CompletionStream(ctx, model, prompt)
+	if err != nil {
+		cancel()
+		p.writeRequestError(w, err, "completion stream")
+		return
+	}
+	writeWarnings(w, r)
+	p.writeStream(w, r, model, false, streamChan, cancel, "completion stream")
+}
+
+// streamContext returns the context for an upstream stream. Resumable streams must outlive
+// the client connection, so their generation is only cancelled by the resume registry.
+func (p *OpenAIProxy) streamContext(r *http.Request) (context.Context, context.CancelFunc) {
+	if p.streams == nil {
+		return r.Context(), func() {}
+	}
+	return context.WithCancel(context.WithoutCancel(r.Context()))
+}
+
+// writeStream writes streamChan, a chat completion stream if chat, as SSE, through the resume registry
+// when streams are resumable. The generation is broadcast, so observers read it alongside the client.
+func (p *OpenAIProxy) writeStream(w http.ResponseWriter, r *http.Request, model string, chat bool,
+	streamChan <-chan interface{}, cancel context.CancelFunc, operation string) {
+	streamChan = p.reasoning.Stream(streamChan)
+	// Before tool call assembly, which only reads OpenAI chunks
+	streamChan = p.strict.Stream(streamChan, model, chat)
+	if p.assembleToolCalls != nil && p.assembleToolCalls(r) {
+		streamChan = toolcalls.Assemble(streamChan)
+	}
+	// Last, so the stamp covers the text as the client reads it
+	streamChan = p.provenance.Stream(w.Header(), streamChan, model, providers.ServingProvider(r.Context()))
+	generation := broadcast.New(streamChan)
+	for _, observe := range p.observers {
+		observe(r, model, generation)
+	}
+
+	if p.streams == nil {
+		p.writeSSEResponse(w, generation.Subscribe(r.Context()), operation)
+		return
+	}
+
+	stream, err := p.streams.Start(r, generation, cancel)
 	if err != nil {
-		slog.Error("Completion stream failed", "error", err)
+		cancel()
+		slog.Error("Failed to start resumable stream", "operation", operation, "error", err)
 		writeError(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
-	p.writeSSEResponse(w, streamChan, "completion stream")
+	w.Header().Set(resume.TokenHeader, stream.Token())
+	p.writeResumableSSEResponse(w, r, stream, 0, operation)
 }
 
 func (p *OpenAIProxy) handleCompletion(w http.ResponseWriter, r *http.Request, model, prompt string) {
 	result, err := p.mux.Completion(r.Context(), model, prompt)
-	p.handleResponse(w, result, err, "completion")
+	p.handleResponse(w, r, model, false, result, err, "completion")
 }
 
-func (p *OpenAIProxy) decodeJSONRequest(r *http.Request, req interface{}, w http.ResponseWriter) error {
-	if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+// decodeJSONRequest decodes the body into req and returns r with the remaining fields attached as parameters
+// and the request's metadata and tags attached for usage records, metrics and routing.
+func (p *OpenAIProxy) decodeJSONRequest(
+	r *http.Request, req interface{}, w http.ResponseWriter,
+) (*http.Request, error) {
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Failed to read request body")
+		return r, err
+	}
+	if err := json.Unmarshal(body, req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return r, err
+	}
+
+	var values map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	// Keeps integers such as seed exact when they are re-encoded for the provider
+	decoder.UseNumber()
+	if err := decoder.Decode(&values); err != nil {
 		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
-		return err
+		return r, err
+	}
+	for _, field := range requestFields {
+		delete(values, field)
 	}
-	return nil
+
+	md, err := metadata.Parse(values["metadata"])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return r, err
+	}
+	// OpenAI only accepts metadata on stored completions; everywhere else it is modelplex's alone
+	if values["store"] != true {
+		delete(values, "metadata")
+	}
+
+	var tags map[string]string
+	if p.tags != nil {
+		tags, err = metadata.SelectTags(md, p.tags.Allowed, p.tags.MaxPerRequest)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error())
+			return r, err
+		}
+	}
+
+	ctx := providers.WithParams(r.Context(), providers.NewParams(values, p.parameters))
+	if md != nil {
+		ctx = metadata.With(ctx, md)
+	}
+	if tags != nil {
+		ctx = metadata.WithTags(ctx, tags)
+	}
+	if p.provenance != nil {
+		ctx = providers.WithServingProvider(ctx)
+	}
+	return r.WithContext(ctx), nil
 }
 
-func (p *OpenAIProxy) handleResponse(w http.ResponseWriter, result interface{}, err error, operation string) {
+func (p *OpenAIProxy) handleResponse(w http.ResponseWriter, r *http.Request, model string, chat bool,
+	result interface{}, err error, operation string) {
 	if err != nil {
-		slog.Error("Operation failed", "operation", operation, "error", err)
-		writeError(w, http.StatusInternalServerError, "Internal server error")
+		p.writeRequestError(w, err, operation)
 		return
 	}
+	result, err = p.strict.Response(p.reasoning.Response(result), model, chat)
+	if err != nil {
+		slog.Error("Response rejected in strict mode", "operation", operation, "model", model, "error", err)
+		writeError(w, http.StatusBadGateway, "Provider response does not match the OpenAI schema")
+		return
+	}
+	result = p.provenance.Response(w.Header(), result, model, providers.ServingProvider(r.Context()))
+	writeWarnings(w, r)
 	p.writeJSONResponse(w, result, operation)
 }
 
+// writeRequestError answers a failed request, as a client error when the request itself can't be served.
+func (p *OpenAIProxy) writeRequestError(w http.ResponseWriter, err error, operation string) {
+	var unsupported *providers.UnsupportedParamError
+	var invalid *providers.InvalidParamError
+	if errors.As(err, &unsupported) || errors.As(err, &invalid) {
+		slog.Debug("Rejected request parameter", "operation", operation, "error", err)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var blocked *providers.BlockedError
+	if errors.As(err, &blocked) {
+		slog.Warn("Blocked request", "operation", operation, "reason", blocked.Reason)
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	var budget *providers.BudgetExceededError
+	if errors.As(err, &budget) {
+		slog.Warn("Conversation budget exceeded", "operation", operation, "conversation_id", budget.ConversationID,
+			"budget", budget.Budget)
+		writeBudgetError(w, budget)
+		return
+	}
+	var loop *providers.LoopDetectedError
+	if errors.As(err, &loop) {
+		slog.Warn("Likely agent loop", "operation", operation, "repeats", loop.Repeats)
+		writeLoopError(w, loop)
+		return
+	}
+	var terminated *providers.SessionTerminatedError
+	if errors.As(err, &terminated) {
+		slog.Warn("Request of a terminated session refused", "operation", operation, "scope", terminated.Scope,
+			"id", terminated.ID)
+		writeSessionTerminatedError(w, terminated)
+		return
+	}
+	if errors.Is(err, providers.ErrTrafficPaused) {
+		slog.Warn("Request refused, outbound traffic is paused", "operation", operation)
+		writeTrafficPausedError(w)
+		return
+	}
+	var failover *providers.FailoverError
+	if errors.As(err, &failover) {
+		slog.Error("Operation failed on every attempt", "operation", operation, "error", err)
+		writeFailoverError(w, failover)
+		return
+	}
+	var limited *providers.RateLimitError
+	if errors.As(err, &limited) {
+		slog.Warn("Provider rate limit reached", "operation", operation, "provider", limited.Provider,
+			"model", limited.Model, "limit", limited.Limit)
+		writeRateLimitError(w, limited)
+		return
+	}
+	slog.Error("Operation failed", "operation", operation, "error", err)
+	writeError(w, http.StatusInternalServerError, "Internal server error")
+}
+
+// writeFailoverError answers with every attempt of a request that failed over, so clients can
+// tell why without the gateway's logs.
+func writeFailoverError(w http.ResponseWriter, failover *providers.FailoverError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadGateway)
+
+	errorResp := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message":  fmt.Sprintf("All %d attempts to serve the request failed", len(failover.Attempts)),
+			"type":     "provider_error",
+			"attempts": failover.Attempts,
+		},
+	}
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+}
+
+// writeSessionTerminatedError answers a request of a session an operator terminated, which no
+// retry will get served until the session is resumed.
+func writeSessionTerminatedError(w http.ResponseWriter, terminated *providers.SessionTerminatedError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusForbidden)
+
+	errorResp := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": terminated.Error(),
+			"type":    "session_terminated",
+			"code":    terminated.Scope + "_terminated",
+		},
+	}
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+}
+
+// writeTrafficPausedError answers a request that couldn't be served while an operator paused
+// outbound traffic, which a later retry may be once traffic resumes.
+func writeTrafficPausedError(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	errorResp := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message": providers.ErrTrafficPaused.Error(),
+			"type":    "traffic_paused",
+			"code":    "traffic_paused",
+		},
+	}
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+}
+
+// writeRateLimitError answers a request a provider refused over one of its rate limits, passing on
+// which limit and how long to wait, in Retry-After too, so clients back off instead of retrying.
+func writeRateLimitError(w http.ResponseWriter, limited *providers.RateLimitError) {
+	errorBody := map[string]interface{}{
+		"message":  limited.Message,
+		"type":     "rate_limit_exceeded",
+		"code":     "rate_limit_exceeded",
+		"provider": limited.Provider,
+	}
+	if limited.Limit != "" {
+		errorBody["limit"] = limited.Limit
+	}
+	if limited.RetryAfter > 0 {
+		errorBody["retry_after_seconds"] = limited.RetryAfter.Seconds()
+		w.Header().Set("Retry-After", strconv.FormatInt(int64(math.Ceil(limited.RetryAfter.Seconds())), 10))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"error": errorBody}); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+}
+
+// writeBudgetError answers a request refused because its conversation is over budget, with which
+// budget and how much of it was used, so agents can tell it from transient rate limiting.
+func writeBudgetError(w http.ResponseWriter, budget *providers.BudgetExceededError) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	errorResp := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message":         budget.Error(),
+			"type":            "budget_exceeded",
+			"code":            budget.Budget + "_budget_exceeded",
+			"conversation_id": budget.ConversationID,
+			"limit":           budget.Limit,
+			"used":            budget.Used,
+		},
+	}
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+}
+
+// writeLoopError answers a request refused as part of a likely agent loop. The warning header
+// carries the reason too, for clients that only surface headers to the agent's operator.
+func writeLoopError(w http.ResponseWriter, loop *providers.LoopDetectedError) {
+	w.Header().Set(providers.WarningHeader, loop.Error())
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+
+	errorResp := map[string]interface{}{
+		"error": map[string]interface{}{
+			"message":        loop.Error(),
+			"type":           "loop_detected",
+			"code":           "repeated_request",
+			"repeats":        loop.Repeats,
+			"window_seconds": int64(loop.Window.Seconds()),
+		},
+	}
+	if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+		slog.Error("Failed to encode error response", "error", err)
+	}
+}
+
+// writeWarnings adds the warnings raised while serving r, such as dropped parameters, as response headers.
+func writeWarnings(w http.ResponseWriter, r *http.Request) {
+	for _, warning := range providers.ParamsFrom(r.Context()).Warnings() {
+		w.Header().Add(providers.WarningHeader, warning)
+	}
+}
+
 func (p *OpenAIProxy) writeJSONResponse(w http.ResponseWriter, data interface{}, responseType string) {
 	w.Header().Set("Content-Type", "application/json")
 	if err := json.NewEncoder(w).Encode(data); err != nil {
@@ -224,3 +698,59 @@ func (p *OpenAIProxy) writeSSEResponse(w http.ResponseWriter, streamChan <-chan
 	}
 	flusher.Flush()
 }
+
+// writeResumableSSEResponse writes the events of stream after the first n and keeps the stream
+// from expiring while the client is attached.
+func (p *OpenAIProxy) writeResumableSSEResponse(w http.ResponseWriter, r *http.Request, stream *resume.Stream,
+	n int, operation string) {
+	stream.Attach()
+	defer stream.Detach()
+	p.WriteEventStream(w, r, stream.Stream, n, operation)
+}
+
+// WriteEventStream writes the events of stream after the first n, numbering them with SSE ids
+// so a client can resume from its Last-Event-ID, and follows the stream until it finishes or the client leaves.
+func (p *OpenAIProxy) WriteEventStream(w http.ResponseWriter, r *http.Request, stream *broadcast.Stream,
+	n int, operation string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		slog.Error("Response writer does not support flushing", "operation", operation)
+		return
+	}
+
+	for {
+		chunks, done, changed := stream.Next(n)
+		for _, chunk := range chunks {
+			n++
+			jsonData, err := json.Marshal(chunk)
+			if err != nil {
+				slog.Error("Failed to marshal streaming chunk", "operation", operation, "error", err)
+				continue
+			}
+			if _, err := fmt.Fprintf(w, "id: %d\ndata: %s\n\n", n, jsonData); err != nil {
+				slog.Error("Failed to write streaming chunk", "operation", operation, "error", err)
+				return
+			}
+		}
+		flusher.Flush()
+
+		if done {
+			break
+		}
+		select {
+		case <-changed:
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if _, err := fmt.Fprint(w, "data: [DONE]\n\n"); err != nil {
+		slog.Error("Failed to write DONE marker", "operation", operation, "error", err)
+	}
+	flusher.Flush()
+}
@@ -5,14 +5,23 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/catalog"
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/resume"
 )
 
 // MockMultiplexer implements the multiplexer interface for testing
@@ -30,9 +39,12 @@ func (m *MockMultiplexer) Completion(ctx context.Context, model, prompt string)
 	return args.Get(0), args.Error(1)
 }
 
-func (m *MockMultiplexer) ListModels() []string {
-	args := m.Called()
-	return args.Get(0).([]string)
+func (m *MockMultiplexer) ListModelsContext(ctx context.Context) (models, warnings []string) {
+	args := m.Called(ctx)
+	if w := args.Get(1); w != nil {
+		warnings = w.([]string)
+	}
+	return args.Get(0).([]string), warnings
 }
 
 // Streaming methods for future interface extension
@@ -151,6 +163,107 @@ func TestOpenAIProxy_HandleChatCompletions(t *testing.T) {
 	}
 }
 
+func TestOpenAIProxy_HandleChatCompletions_AllAttemptsFailed(t *testing.T) {
+	attempts := []providers.Attempt{
+		{Provider: "openai", Region: "us", StatusCode: 503, Error: "overloaded"},
+		{Provider: "openai", Region: "eu", Error: "connection refused"},
+	}
+	failover := providers.NewFailoverError(attempts, []error{errors.New("us"), errors.New("eu")})
+	mockMux := &MockMultiplexer{}
+	mockMux.On("ChatCompletion", mock.Anything, "gpt-4", mock.Anything).
+		Return(nil, fmt.Errorf("all regions of provider openai failed: %w", failover))
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`))
+	w := httptest.NewRecorder()
+	New(mockMux).HandleChatCompletions(w, req)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.JSONEq(t, `{"error":{"message":"All 2 attempts to serve the request failed","type":"provider_error",`+
+		`"attempts":[{"provider":"openai","region":"us","status_code":503,"error":"overloaded"},`+
+		`{"provider":"openai","region":"eu","error":"connection refused"}]}}`, w.Body.String())
+}
+
+func TestOpenAIProxy_HandleChatCompletions_Blocked(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	mockMux.On("ChatCompletion", mock.Anything, "gpt-4", mock.Anything).
+		Return(nil, &providers.BlockedError{Reason: "likely prompt injection"})
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`))
+	w := httptest.NewRecorder()
+	New(mockMux).HandleChatCompletions(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.JSONEq(t, `{"error":{"message":"request blocked: likely prompt injection","type":"invalid_request_error"}}`,
+		w.Body.String())
+}
+
+func TestOpenAIProxy_HandleChatCompletions_BudgetExceeded(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	mockMux.On("ChatCompletion", mock.Anything, "gpt-4", mock.Anything).Return(nil,
+		&providers.BudgetExceededError{ConversationID: "c1", Budget: "turns", Limit: 50, Used: 50})
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`))
+	w := httptest.NewRecorder()
+	New(mockMux).HandleChatCompletions(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.JSONEq(t, `{"error":{"message":"conversation \"c1\" exceeded its budget of 50 turns",
+		"type":"budget_exceeded","code":"turns_budget_exceeded","conversation_id":"c1","limit":50,"used":50}}`,
+		w.Body.String())
+}
+
+func TestOpenAIProxy_HandleChatCompletions_LoopDetected(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	mockMux.On("ChatCompletion", mock.Anything, "gpt-4", mock.Anything).Return(nil,
+		&providers.LoopDetectedError{Repeats: 5, Window: 5 * time.Minute})
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`))
+	w := httptest.NewRecorder()
+	New(mockMux).HandleChatCompletions(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Equal(t, "request repeated 5 times in the last 5m0s, likely an agent loop",
+		w.Header().Get(providers.WarningHeader))
+	assert.JSONEq(t, `{"error":{"message":"request repeated 5 times in the last 5m0s, likely an agent loop",
+		"type":"loop_detected","code":"repeated_request","repeats":5,"window_seconds":300}}`, w.Body.String())
+}
+
+func TestOpenAIProxy_HandleChatCompletions_RateLimited(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	mockMux.On("ChatCompletion", mock.Anything, "llama-3.1-8b-instant", mock.Anything).Return(nil,
+		&providers.RateLimitError{Provider: "groq", Model: "llama-3.1-8b-instant", Limit: "TPM",
+			RetryAfter: 1500 * time.Millisecond, Message: "Rate limit reached"})
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions",
+		strings.NewReader(`{"model":"llama-3.1-8b-instant","messages":[{"role":"user","content":"Hello"}]}`))
+	w := httptest.NewRecorder()
+	New(mockMux).HandleChatCompletions(w, req)
+
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "2", w.Header().Get("Retry-After"))
+	assert.JSONEq(t, `{"error":{"message":"Rate limit reached","type":"rate_limit_exceeded",
+		"code":"rate_limit_exceeded","provider":"groq","limit":"TPM","retry_after_seconds":1.5}}`, w.Body.String())
+}
+
+func TestOpenAIProxy_HandleChatCompletions_TrafficPaused(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	mockMux.On("ChatCompletion", mock.Anything, "gpt-4", mock.Anything).Return(nil,
+		fmt.Errorf("chat completion failed: %w", providers.ErrTrafficPaused))
+
+	req := httptest.NewRequest("POST", "/v1/chat/completions",
+		strings.NewReader(`{"model":"gpt-4","messages":[{"role":"user","content":"Hello"}]}`))
+	w := httptest.NewRecorder()
+	New(mockMux).HandleChatCompletions(w, req)
+
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.JSONEq(t, `{"error":{"message":"outbound traffic is paused by an operator","type":"traffic_paused",
+		"code":"traffic_paused"}}`, w.Body.String())
+}
+
 func TestOpenAIProxy_HandleChatCompletions_InvalidJSON(t *testing.T) {
 	mockMux := &MockMultiplexer{}
 	proxy := New(mockMux)
@@ -165,6 +278,151 @@ func TestOpenAIProxy_HandleChatCompletions_InvalidJSON(t *testing.T) {
 	assert.Contains(t, w.Body.String(), "Invalid JSON")
 }
 
+func TestOpenAIProxy_HandleChatCompletions_Parameters(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	proxy := New(mockMux)
+
+	withParams := mock.MatchedBy(func(ctx context.Context) bool {
+		values := providers.ParamsFrom(ctx).Values()
+		return values["temperature"] == json.Number("0.2") && values["seed"] == json.Number("9007199254740993") &&
+			values["model"] == nil && values["messages"] == nil
+	})
+	mockMux.On("ChatCompletion", withParams, "claude-3-haiku", mock.Anything).
+		Return(nil, fmt.Errorf("routing: %w", &providers.UnsupportedParamError{Provider: "anthropic", Param: "seed"}))
+
+	body := `{"model": "claude-3-haiku", "messages": [{"role": "user", "content": "Hi"}], ` +
+		`"temperature": 0.2, "seed": 9007199254740993}`
+	req := httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body))
+	w := httptest.NewRecorder()
+
+	proxy.HandleChatCompletions(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), `provider anthropic does not support parameter \"seed\"`)
+	mockMux.AssertExpectations(t)
+}
+
+func TestOpenAIProxy_HandleChatCompletions_Metadata(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	proxy := New(mockMux)
+
+	withMetadata := mock.MatchedBy(func(ctx context.Context) bool {
+		_, forwarded := providers.ParamsFrom(ctx).Values()["metadata"]
+		return metadata.From(ctx)["task"] == "summarize" && !forwarded
+	})
+	mockMux.On("ChatCompletion", withMetadata, "gpt-4", mock.Anything).Return(map[string]interface{}{"id": "1"}, nil)
+
+	body := `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}], "metadata": {"task": "summarize"}}`
+	w := httptest.NewRecorder()
+	proxy.HandleChatCompletions(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockMux.AssertExpectations(t)
+
+	body = `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}], "metadata": {"priority": 1}}`
+	w = httptest.NewRecorder()
+	proxy.HandleChatCompletions(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "metadata.priority must be a string")
+}
+
+func TestOpenAIProxy_HandleChatCompletions_Tags(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	proxy := New(mockMux, WithTags(&config.TagsConfig{Allowed: []string{"team", "experiment"}, MaxPerRequest: 1}))
+
+	withTags := mock.MatchedBy(func(ctx context.Context) bool {
+		return assert.ObjectsAreEqual(map[string]string{"team": "search"}, metadata.Tags(ctx))
+	})
+	mockMux.On("ChatCompletion", withTags, "gpt-4", mock.Anything).Return(map[string]interface{}{"id": "1"}, nil)
+
+	body := `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}],
+		"metadata": {"team": "search", "task": "summarize"}}`
+	w := httptest.NewRecorder()
+	proxy.HandleChatCompletions(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	mockMux.AssertExpectations(t)
+
+	body = `{"model": "gpt-4", "messages": [{"role": "user", "content": "Hi"}],
+		"metadata": {"team": "search", "experiment": "b"}}`
+	w = httptest.NewRecorder()
+	proxy.HandleChatCompletions(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.Contains(t, w.Body.String(), "metadata may hold at most 1 tags")
+}
+
+func TestOpenAIProxy_HandleChatCompletions_StrictOpenAI(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	proxy := New(mockMux, WithStrictOpenAI(true))
+
+	native := map[string]interface{}{
+		"id": "msg_1", "type": "message", "model": "claude-3-sonnet", "stop_reason": "end_turn",
+		"content": []interface{}{map[string]interface{}{"type": "text", "text": "4"}},
+	}
+	mockMux.On("ChatCompletion", mock.Anything, "claude-3-sonnet", mock.Anything).Return(native, nil)
+	mockMux.On("ChatCompletion", mock.Anything, "odd-model", mock.Anything).
+		Return(map[string]interface{}{"output": "4"}, nil)
+
+	body := `{"model": "claude-3-sonnet", "messages": [{"role": "user", "content": "What is 2 + 2?"}]}`
+	w := httptest.NewRecorder()
+	proxy.HandleChatCompletions(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+	assert.Equal(t, http.StatusOK, w.Code)
+	var response map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(t, "chat.completion", response["object"])
+	assert.Equal(t, map[string]interface{}{"role": "assistant", "content": "4"},
+		response["choices"].([]interface{})[0].(map[string]interface{})["message"])
+
+	body = `{"model": "odd-model", "messages": [{"role": "user", "content": "What is 2 + 2?"}]}`
+	w = httptest.NewRecorder()
+	proxy.HandleChatCompletions(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+}
+
+func TestOpenAIProxy_HandleChatCompletions_Reasoning(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	proxy := New(mockMux, WithReasoning(&config.ReasoningConfig{Mode: config.ReasoningStrip}))
+
+	response := map[string]interface{}{"content": []interface{}{
+		map[string]interface{}{"type": "thinking", "thinking": "2 + 2 is 4"},
+		map[string]interface{}{"type": "text", "text": "4"},
+	}}
+	mockMux.On("ChatCompletion", mock.Anything, "claude-3-sonnet", mock.Anything).Return(response, nil)
+
+	body := `{"model": "claude-3-sonnet", "messages": [{"role": "user", "content": "What is 2 + 2?"}]}`
+	w := httptest.NewRecorder()
+	proxy.HandleChatCompletions(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.JSONEq(t, `{"content": [{"type": "text", "text": "4"}]}`, w.Body.String())
+}
+
+func TestOpenAIProxy_HandleChatCompletions_Provenance(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	proxy := New(mockMux, WithProvenance(&config.ProvenanceConfig{Mode: config.ProvenanceBoth}))
+
+	response := map[string]interface{}{"id": "chatcmpl-1", "choices": []interface{}{
+		map[string]interface{}{"index": 0, "message": map[string]interface{}{"role": "assistant", "content": "4"}},
+	}}
+	mockMux.On("ChatCompletion", mock.Anything, "gpt-4", mock.Anything).Run(func(args mock.Arguments) {
+		providers.ReportServing(args.Get(0).(context.Context), providers.NewProvider(&config.Provider{
+			Name: "openai", Type: "openai",
+		}))
+	}).Return(response, nil)
+
+	body := `{"model": "gpt-4", "messages": [{"role": "user", "content": "What is 2 + 2?"}]}`
+	w := httptest.NewRecorder()
+	proxy.HandleChatCompletions(w, httptest.NewRequest("POST", "/v1/chat/completions", strings.NewReader(body)))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "openai", w.Header().Get("X-Modelplex-Provenance-Provider"))
+	assert.Equal(t, "chatcmpl-1", w.Header().Get("X-Modelplex-Provenance-Request-Id"))
+	var decoded map[string]interface{}
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &decoded))
+	stamp := decoded["provenance"].(map[string]interface{})
+	assert.Equal(t, "gpt-4", stamp["model"])
+	assert.Equal(t, w.Header().Get("X-Modelplex-Provenance-Hash"), stamp["hash"])
+	assert.NotContains(t, response, "provenance", "shared responses are copied before stamping")
+}
+
 func TestOpenAIProxy_HandleCompletions(t *testing.T) {
 	mockMux := &MockMultiplexer{}
 	proxy := New(mockMux)
@@ -212,7 +470,7 @@ func TestOpenAIProxy_HandleModels(t *testing.T) {
 	proxy := New(mockMux)
 
 	mockModels := []string{"gpt-4", "gpt-3.5-turbo", "claude-3-sonnet"}
-	mockMux.On("ListModels").Return(mockModels)
+	mockMux.On("ListModelsContext", mock.Anything).Return(mockModels, nil)
 
 	req := httptest.NewRequest("GET", "/v1/models", http.NoBody)
 	w := httptest.NewRecorder()
@@ -234,10 +492,53 @@ func TestOpenAIProxy_HandleModels(t *testing.T) {
 		assert.Equal(t, "modelplex", response.Data[i].OwnedBy)
 		assert.Equal(t, int64(1677610602), response.Data[i].Created)
 	}
+	assert.Empty(t, response.Warnings)
+
+	mockMux.AssertExpectations(t)
+}
+
+func TestOpenAIProxy_HandleModels_PartialResults(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	proxy := New(mockMux)
+
+	mockMux.On("ListModelsContext", mock.Anything).
+		Return([]string{"gpt-4"}, []string{"provider slow: listing models: context deadline exceeded"})
+
+	req := httptest.NewRequest("GET", "/v1/models", http.NoBody)
+	w := httptest.NewRecorder()
+
+	proxy.HandleModels(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response ModelsResponse
+	err := json.NewDecoder(w.Body).Decode(&response)
+	require.NoError(t, err)
+
+	require.Len(t, response.Data, 1)
+	assert.Equal(t, "gpt-4", response.Data[0].ID)
+	assert.Equal(t, []string{"provider slow: listing models: context deadline exceeded"}, response.Warnings)
 
 	mockMux.AssertExpectations(t)
 }
 
+func TestOpenAIProxy_HandleModels_Catalog(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	cfg := &config.Config{Usage: config.UsageConfig{Prices: map[string]config.ModelPrice{"gpt-4o": {Input: 2, Output: 8}}}}
+	c := catalog.Catalog{"gpt-4o": {ContextWindow: 128000, Modalities: []string{"text", "image"},
+		Pricing: &catalog.Pricing{Input: 2.5, Output: 10}}}
+	proxy := New(mockMux, WithCatalog(c, cfg))
+	mockMux.On("ListModelsContext", mock.Anything).Return([]string{"gpt-4o", "llama2"}, nil)
+
+	w := httptest.NewRecorder()
+	proxy.HandleModels(w, httptest.NewRequest("GET", "/v1/models", http.NoBody))
+
+	assert.JSONEq(t, `{"object":"list","data":[`+
+		`{"id":"gpt-4o","object":"model","created":1677610602,"owned_by":"modelplex","context_window":128000,`+
+		`"modalities":["text","image"],"pricing":{"input":2,"output":8}},`+
+		`{"id":"llama2","object":"model","created":1677610602,"owned_by":"modelplex"}]}`, w.Body.String())
+}
+
 func TestNormalizeModel(t *testing.T) {
 	proxy := &OpenAIProxy{}
 
@@ -368,6 +669,45 @@ func TestOpenAIProxy_HandleChatCompletions_Streaming(t *testing.T) {
 	mockMux.AssertExpectations(t)
 }
 
+func TestOpenAIProxy_ResumeStream(t *testing.T) {
+	mockMux := &MockMultiplexer{}
+	streams := resume.NewRegistry(time.Minute, func(*http.Request) string { return "" })
+	proxy := New(mockMux, WithResumableStreams(streams))
+
+	streamChan := make(chan interface{}, 3)
+	streamChan <- map[string]interface{}{"n": 1}
+	streamChan <- map[string]interface{}{"n": 2}
+	streamChan <- map[string]interface{}{"n": 3}
+	close(streamChan)
+	var readOnlyChan <-chan interface{} = streamChan
+	mockMux.On("CompletionStream", mock.Anything, "gpt-4", "Hi").Return(readOnlyChan, nil)
+
+	req := httptest.NewRequest("POST", "/v1/completions", strings.NewReader(`{"model":"gpt-4","prompt":"Hi","stream":true}`))
+	w := httptest.NewRecorder()
+	proxy.HandleCompletions(w, req)
+
+	require.Equal(t, http.StatusOK, w.Code)
+	token := w.Header().Get(resume.TokenHeader)
+	require.NotEmpty(t, token)
+	assert.Contains(t, w.Body.String(), "id: 3\ndata: {\"n\":3}\n\n")
+
+	// A client that saw the first event continues with the second
+	req = httptest.NewRequest("GET", "/v1/streams/"+token, nil)
+	req = mux.SetURLVars(req, map[string]string{"token": token})
+	req.Header.Set(resume.LastEventIDHeader, "1")
+	w = httptest.NewRecorder()
+	proxy.HandleStreamResume(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "id: 2\ndata: {\"n\":2}\n\nid: 3\ndata: {\"n\":3}\n\ndata: [DONE]\n\n", w.Body.String())
+
+	req = httptest.NewRequest("GET", "/v1/streams/unknown", nil)
+	req = mux.SetURLVars(req, map[string]string{"token": "unknown"})
+	w = httptest.NewRecorder()
+	proxy.HandleStreamResume(w, req)
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
 func TestOpenAIProxy_HandleCompletions_Streaming(t *testing.T) {
 	mockMux := &MockMultiplexer{}
 	proxy := New(mockMux)
@@ -0,0 +1,105 @@
+package proxy
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+// RerankRequest represents a rerank request, in the shape Cohere's and Voyage's APIs share:
+// documents are strings or, as in Cohere's v1 API, objects with a text field, and Voyage's top_k
+// stands for top_n.
+type RerankRequest struct {
+	Model           string            `json:"model"`
+	Query           string            `json:"query"`
+	Documents       []json.RawMessage `json:"documents"`
+	TopN            int               `json:"top_n,omitempty"`
+	TopK            int               `json:"top_k,omitempty"`
+	ReturnDocuments bool              `json:"return_documents,omitempty"`
+}
+
+// HandleRerank handles rerank requests, answering in Cohere's shape.
+func (p *OpenAIProxy) HandleRerank(w http.ResponseWriter, r *http.Request) {
+	var req RerankRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+	switch {
+	case req.Model == "":
+		writeError(w, http.StatusBadRequest, "model is required")
+		return
+	case req.Query == "":
+		writeError(w, http.StatusBadRequest, "query is required")
+		return
+	case len(req.Documents) == 0:
+		writeError(w, http.StatusBadRequest, "documents must be a non-empty array")
+		return
+	case req.TopN < 0 || req.TopK < 0:
+		writeError(w, http.StatusBadRequest, "top_n must not be negative")
+		return
+	}
+
+	documents := make([]string, len(req.Documents))
+	for i, raw := range req.Documents {
+		text, ok := documentText(raw)
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("documents[%d] must be a string or an object with text", i))
+			return
+		}
+		documents[i] = text
+	}
+	if p.reranker == nil {
+		writeError(w, http.StatusNotFound, "Reranking is not available")
+		return
+	}
+
+	model := p.normalizeModel(req.Model)
+	response, err := p.reranker.Rerank(r.Context(), model, &providers.RerankRequest{
+		Query: req.Query, Documents: documents, TopN: max(req.TopN, req.TopK),
+	})
+	if errors.Is(err, providers.ErrNoReranker) {
+		writeError(w, http.StatusNotFound, err.Error())
+		return
+	}
+	var status *providers.StatusError
+	if errors.As(err, &status) && status.StatusCode < http.StatusInternalServerError {
+		// The request itself is invalid, e.g. too many documents; the reranker explains why
+		slog.Debug("Reranker rejected request", "model", model, "status", status.StatusCode)
+		writeError(w, status.StatusCode, status.Body)
+		return
+	}
+	if err != nil {
+		p.writeRequestError(w, err, "rerank")
+		return
+	}
+
+	response.Model = req.Model
+	if req.ReturnDocuments {
+		for i := range response.Results {
+			if index := response.Results[i].Index; index >= 0 && index < len(documents) {
+				response.Results[i].Document = &providers.RerankDocument{Text: documents[index]}
+			}
+		}
+	}
+	p.writeJSONResponse(w, response, "rerank")
+}
+
+// documentText returns the text of a rerank document, given as a string or an object with text.
+func documentText(raw json.RawMessage) (string, bool) {
+	var text string
+	if err := json.Unmarshal(raw, &text); err == nil {
+		return text, true
+	}
+	var document struct {
+		Text *string `json:"text"`
+	}
+	if err := json.Unmarshal(raw, &document); err != nil || document.Text == nil {
+		return "", false
+	}
+	return *document.Text, true
+}
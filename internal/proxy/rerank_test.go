@@ -0,0 +1,77 @@
+package proxy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/providers"
+)
+
+type stubReranker struct {
+	request *providers.RerankRequest
+}
+
+func (s *stubReranker) Rerank(
+	_ context.Context, model string, req *providers.RerankRequest,
+) (*providers.RerankResponse, error) {
+	if model != "rerank-v3.5" {
+		return nil, fmt.Errorf("%w for model %s", providers.ErrNoReranker, model)
+	}
+	s.request = req
+	return &providers.RerankResponse{Model: model, Results: []providers.RerankResult{
+		{Index: 1, RelevanceScore: 0.9}, {Index: 0, RelevanceScore: 0.2},
+	}}, nil
+}
+
+func TestOpenAIProxy_HandleRerank(t *testing.T) {
+	reranker := &stubReranker{}
+	proxy := New(&MockMultiplexer{}, WithReranker(reranker))
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+	}{
+		{
+			name:   "voyage shape",
+			body:   `{"model":"rerank-v3.5","query":"q","documents":["a","b"],"top_k":2,"return_documents":true}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "cohere v1 documents",
+			body:   `{"model":"modelplex-rerank-v3.5","query":"q","documents":[{"text":"a"},{"text":"b"}],"top_n":2}`,
+			status: http.StatusOK,
+		},
+		{name: "no documents", body: `{"model":"rerank-v3.5","query":"q","documents":[]}`, status: http.StatusBadRequest},
+		{name: "bad document", body: `{"model":"rerank-v3.5","query":"q","documents":[1]}`, status: http.StatusBadRequest},
+		{name: "unknown model", body: `{"model":"gpt-4","query":"q","documents":["a"]}`, status: http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			proxy.HandleRerank(w, httptest.NewRequest("POST", "/v1/rerank", strings.NewReader(tt.body)))
+			require.Equal(t, tt.status, w.Code, w.Body.String())
+			if tt.status != http.StatusOK {
+				return
+			}
+
+			assert.Equal(t, &providers.RerankRequest{Query: "q", Documents: []string{"a", "b"}, TopN: 2}, reranker.request)
+			var response providers.RerankResponse
+			require.NoError(t, json.NewDecoder(w.Body).Decode(&response))
+			assert.Len(t, response.Results, 2)
+			if strings.Contains(tt.body, "return_documents") {
+				assert.Equal(t, &providers.RerankDocument{Text: "b"}, response.Results[0].Document)
+			} else {
+				assert.Nil(t, response.Results[0].Document)
+			}
+		})
+	}
+}
@@ -0,0 +1,244 @@
+// Package reasoning normalizes the intermediate output of reasoning models across the response
+// shapes providers return. OpenAI-compatible backends send reasoning_content or reasoning, Anthropic
+// sends thinking content blocks and Ollama a thinking field; in expose mode each of them also gets
+// a reasoning_content field next to it, and in strip mode all of them are removed.
+package reasoning
+
+import (
+	"encoding/json"
+	"log/slog"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// Field carries reasoning text in every response shape in expose mode
+const Field = "reasoning_content"
+
+// Normalizer rewrites reasoning in responses as configured. A nil Normalizer leaves them as they are.
+type Normalizer struct {
+	strip bool
+}
+
+// New creates a normalizer for cfg, or nil in passthrough mode.
+func New(cfg *config.ReasoningConfig) *Normalizer {
+	switch cfg.Mode {
+	case config.ReasoningExpose:
+		return &Normalizer{}
+	case config.ReasoningStrip:
+		return &Normalizer{strip: true}
+	default:
+		return nil
+	}
+}
+
+// Response returns result with its reasoning normalized. result may be shared, e.g. by coalesced
+// requests, so it is copied before anything changes.
+func (n *Normalizer) Response(result interface{}) interface{} {
+	if n == nil {
+		return result
+	}
+	response, ok := result.(map[string]interface{})
+	if !ok || !hasReasoning(response) {
+		return result
+	}
+
+	response, err := clone(response)
+	if err != nil {
+		slog.Warn("Failed to copy response for reasoning normalization", "error", err)
+		return result
+	}
+
+	// Anthropic content blocks
+	if blocks, ok := response["content"].([]interface{}); ok {
+		var reasoning []string
+		kept := make([]interface{}, 0, len(blocks))
+		for _, block := range blocks {
+			b, _ := block.(map[string]interface{})
+			switch b["type"] {
+			case "thinking":
+				text, _ := b["thinking"].(string)
+				reasoning = append(reasoning, text)
+			case "redacted_thinking":
+			default:
+				kept = append(kept, block)
+				continue
+			}
+			if !n.strip {
+				kept = append(kept, block)
+			}
+		}
+		response["content"] = kept
+		if !n.strip && len(reasoning) > 0 {
+			response[Field] = strings.Join(reasoning, "\n\n")
+		}
+	}
+
+	// OpenAI choices
+	if choices, ok := response["choices"].([]interface{}); ok {
+		for _, choice := range choices {
+			c, _ := choice.(map[string]interface{})
+			message, _ := c["message"].(map[string]interface{})
+			n.fields(message, "reasoning")
+		}
+	}
+
+	// Ollama chat and generate responses
+	message, _ := response["message"].(map[string]interface{})
+	n.fields(message, "thinking")
+	n.fields(response, "thinking")
+	return response
+}
+
+// Stream returns upstream with reasoning normalized in every chunk; in strip mode chunks that
+// only carry reasoning are dropped. The returned channel must be read until it closes.
+func (n *Normalizer) Stream(upstream <-chan interface{}) <-chan interface{} {
+	if n == nil {
+		return upstream
+	}
+
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		blocks := &blockIndex{}
+		for chunk := range upstream {
+			if chunk, keep := n.chunk(chunk, blocks); keep {
+				out <- chunk
+			}
+		}
+	}()
+	return out
+}
+
+// chunk normalizes a streaming chunk in place; chunks come from a single upstream and aren't shared yet.
+func (n *Normalizer) chunk(chunk interface{}, blocks *blockIndex) (interface{}, bool) {
+	event, ok := chunk.(map[string]interface{})
+	if !ok {
+		return chunk, true
+	}
+
+	// Anthropic events address content blocks by index
+	switch event["type"] {
+	case "content_block_start":
+		block, _ := event["content_block"].(map[string]interface{})
+		thinking := block["type"] == "thinking" || block["type"] == "redacted_thinking"
+		return event, blocks.start(event, n.strip && thinking)
+	case "content_block_delta":
+		if delta, ok := event["delta"].(map[string]interface{}); ok && delta["type"] == "thinking_delta" {
+			n.fields(delta, "thinking")
+		}
+		return event, blocks.keep(event)
+	case "content_block_stop":
+		return event, blocks.keep(event)
+	}
+
+	if choices, ok := event["choices"].([]interface{}); ok {
+		for _, choice := range choices {
+			c, _ := choice.(map[string]interface{})
+			delta, _ := c["delta"].(map[string]interface{})
+			n.fields(delta, "reasoning")
+		}
+	}
+	message, _ := event["message"].(map[string]interface{})
+	n.fields(message, "thinking")
+	n.fields(event, "thinking")
+	return event, true
+}
+
+// fields exposes the reasoning of obj, found in Field or in native, as Field, or strips both.
+// The native field is left in place when exposing so clients relying on it keep working.
+func (n *Normalizer) fields(obj map[string]interface{}, native string) {
+	if obj == nil {
+		return
+	}
+	if n.strip {
+		delete(obj, Field)
+		delete(obj, native)
+		return
+	}
+	if _, ok := obj[Field]; ok {
+		return
+	}
+	if text, ok := obj[native].(string); ok {
+		obj[Field] = text
+	}
+}
+
+// blockIndex renumbers Anthropic content blocks when thinking blocks are dropped, so clients
+// assembling blocks by index see a contiguous sequence.
+type blockIndex struct {
+	next    int
+	dropped map[int]bool
+	mapped  map[int]int
+}
+
+func (b *blockIndex) start(event map[string]interface{}, drop bool) bool {
+	index, ok := event["index"].(float64)
+	if !ok {
+		return true
+	}
+	if b.dropped == nil {
+		b.dropped, b.mapped = make(map[int]bool), make(map[int]int)
+	}
+	if drop {
+		b.dropped[int(index)] = true
+		return false
+	}
+	b.mapped[int(index)] = b.next
+	event["index"] = float64(b.next)
+	b.next++
+	return true
+}
+
+func (b *blockIndex) keep(event map[string]interface{}) bool {
+	index, ok := event["index"].(float64)
+	if !ok || b.mapped == nil {
+		return true
+	}
+	if b.dropped[int(index)] {
+		return false
+	}
+	if mapped, ok := b.mapped[int(index)]; ok {
+		event["index"] = float64(mapped)
+	}
+	return true
+}
+
+// hasReasoning reports whether a non-streaming response carries reasoning in any known shape.
+func hasReasoning(response map[string]interface{}) bool {
+	if _, ok := response["thinking"]; ok {
+		return true
+	}
+	if message, ok := response["message"].(map[string]interface{}); ok && message["thinking"] != nil {
+		return true
+	}
+	if blocks, ok := response["content"].([]interface{}); ok {
+		for _, block := range blocks {
+			if b, ok := block.(map[string]interface{}); ok && (b["type"] == "thinking" || b["type"] == "redacted_thinking") {
+				return true
+			}
+		}
+	}
+	if choices, ok := response["choices"].([]interface{}); ok {
+		for _, choice := range choices {
+			c, _ := choice.(map[string]interface{})
+			if message, ok := c["message"].(map[string]interface{}); ok &&
+				(message[Field] != nil || message["reasoning"] != nil) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func clone(response map[string]interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	var copied map[string]interface{}
+	if err := json.Unmarshal(data, &copied); err != nil {
+		return nil, err
+	}
+	return copied, nil
+}
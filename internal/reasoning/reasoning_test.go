@@ -0,0 +1,157 @@
+package reasoning
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func decode(t *testing.T, data string) map[string]interface{} {
+	t.Helper()
+	var value map[string]interface{}
+	require.NoError(t, json.Unmarshal([]byte(data), &value))
+	return value
+}
+
+func TestNormalizer_Response(t *testing.T) {
+	tests := []struct {
+		name     string
+		mode     string
+		response string
+		expected string
+	}{
+		{
+			name:     "anthropic thinking exposed",
+			mode:     config.ReasoningExpose,
+			response: `{"content":[{"type":"thinking","thinking":"a"},{"type":"thinking","thinking":"b"},{"type":"text","text":"4"}]}`,
+			expected: `{"content":[{"type":"thinking","thinking":"a"},{"type":"thinking","thinking":"b"},{"type":"text","text":"4"}],` +
+				`"reasoning_content":"a\n\nb"}`,
+		},
+		{
+			name:     "anthropic thinking stripped",
+			mode:     config.ReasoningStrip,
+			response: `{"content":[{"type":"thinking","thinking":"a"},{"type":"redacted_thinking","data":"x"},{"type":"text","text":"4"}]}`,
+			expected: `{"content":[{"type":"text","text":"4"}]}`,
+		},
+		{
+			name:     "openai reasoning exposed",
+			mode:     config.ReasoningExpose,
+			response: `{"choices":[{"message":{"content":"4","reasoning":"a"}}]}`,
+			expected: `{"choices":[{"message":{"content":"4","reasoning":"a","reasoning_content":"a"}}]}`,
+		},
+		{
+			name:     "openai reasoning stripped",
+			mode:     config.ReasoningStrip,
+			response: `{"choices":[{"message":{"content":"4","reasoning_content":"a"}}]}`,
+			expected: `{"choices":[{"message":{"content":"4"}}]}`,
+		},
+		{
+			name:     "ollama chat exposed",
+			mode:     config.ReasoningExpose,
+			response: `{"message":{"content":"4","thinking":"a"}}`,
+			expected: `{"message":{"content":"4","thinking":"a","reasoning_content":"a"}}`,
+		},
+		{
+			name:     "ollama generate stripped",
+			mode:     config.ReasoningStrip,
+			response: `{"response":"4","thinking":"a"}`,
+			expected: `{"response":"4"}`,
+		},
+		{
+			name:     "passthrough",
+			mode:     config.ReasoningPassthrough,
+			response: `{"message":{"content":"4","thinking":"a"}}`,
+			expected: `{"message":{"content":"4","thinking":"a"}}`,
+		},
+		{
+			name:     "no reasoning",
+			mode:     config.ReasoningStrip,
+			response: `{"choices":[{"message":{"content":"4"}}]}`,
+			expected: `{"choices":[{"message":{"content":"4"}}]}`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			n := New(&config.ReasoningConfig{Mode: tt.mode})
+			response := decode(t, tt.response)
+
+			result := n.Response(response)
+
+			assert.Equal(t, decode(t, tt.expected), result)
+			// Responses may be shared between coalesced requests, so the original stays as it was
+			assert.Equal(t, decode(t, tt.response), response)
+		})
+	}
+}
+
+func stream(t *testing.T, n *Normalizer, chunks ...string) []interface{} {
+	t.Helper()
+	upstream := make(chan interface{}, len(chunks))
+	for _, chunk := range chunks {
+		upstream <- decode(t, chunk)
+	}
+	close(upstream)
+
+	var out []interface{}
+	for chunk := range n.Stream(upstream) {
+		out = append(out, chunk)
+	}
+	return out
+}
+
+func TestNormalizer_StreamAnthropic(t *testing.T) {
+	events := []string{
+		`{"type":"message_start"}`,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"thinking"}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"a"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"text"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"4"}}`,
+		`{"type":"content_block_stop","index":1}`,
+		`{"type":"message_stop"}`,
+	}
+
+	exposed := stream(t, New(&config.ReasoningConfig{Mode: config.ReasoningExpose}), events...)
+	require.Len(t, exposed, len(events))
+	assert.Equal(t, decode(t, `{"type":"content_block_delta","index":0,`+
+		`"delta":{"type":"thinking_delta","thinking":"a","reasoning_content":"a"}}`), exposed[2])
+
+	// The thinking block is dropped and the text block takes its index
+	stripped := stream(t, New(&config.ReasoningConfig{Mode: config.ReasoningStrip}), events...)
+	assert.Equal(t, []interface{}{
+		decode(t, `{"type":"message_start"}`),
+		decode(t, `{"type":"content_block_start","index":0,"content_block":{"type":"text"}}`),
+		decode(t, `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"4"}}`),
+		decode(t, `{"type":"content_block_stop","index":0}`),
+		decode(t, `{"type":"message_stop"}`),
+	}, stripped)
+}
+
+func TestNormalizer_StreamOpenAI(t *testing.T) {
+	chunks := []string{
+		`{"choices":[{"delta":{"reasoning":"a"}}]}`,
+		`{"choices":[{"delta":{"content":"4"}}]}`,
+	}
+
+	exposed := stream(t, New(&config.ReasoningConfig{Mode: config.ReasoningExpose}), chunks...)
+	assert.Equal(t, decode(t, `{"choices":[{"delta":{"reasoning":"a","reasoning_content":"a"}}]}`), exposed[0])
+
+	stripped := stream(t, New(&config.ReasoningConfig{Mode: config.ReasoningStrip}), chunks...)
+	assert.Equal(t, []interface{}{
+		decode(t, `{"choices":[{"delta":{}}]}`),
+		decode(t, `{"choices":[{"delta":{"content":"4"}}]}`),
+	}, stripped)
+}
+
+func TestNormalizer_Passthrough(t *testing.T) {
+	n := New(&config.ReasoningConfig{Mode: config.ReasoningPassthrough})
+	assert.Nil(t, n)
+
+	upstream := make(chan interface{})
+	assert.Equal(t, (<-chan interface{})(upstream), n.Stream(upstream))
+}
@@ -0,0 +1,195 @@
+// Package resume keeps the deltas of streaming responses so a client that loses its connection
+// during a long generation can reconnect with a resume token and continue from where it left off.
+// Streams are kept in process memory, so a client must reconnect to the instance that served it.
+// Each remembers the tenant and metadata of its request, so Forget can drop those of a data subject.
+package resume
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/broadcast"
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/metadata"
+)
+
+const (
+	// TokenHeader carries the resume token on resumable streaming responses
+	TokenHeader = "X-Modelplex-Resume-Token"
+	// LastEventIDHeader is sent by SSE clients on reconnect with the id of the last event they received
+	LastEventIDHeader = "Last-Event-ID"
+
+	// tokenBytes is the entropy of a resume token; the token alone grants access to the stream
+	tokenBytes = 16
+)
+
+// Registry keeps resumable streams until their retention window has passed.
+type Registry struct {
+	retention time.Duration
+	scope     func(*http.Request) string
+
+	mtx     sync.Mutex
+	streams map[string]*Stream
+}
+
+// NewRegistry creates a registry that keeps streams for retention after their last client
+// disconnects or the generation finishes, whichever is later.
+// scope partitions streams, e.g. by tenant, so clients can't resume each other's streams.
+func NewRegistry(retention time.Duration, scope func(*http.Request) string) *Registry {
+	return &Registry{retention: retention, scope: scope, streams: make(map[string]*Stream)}
+}
+
+// Start keeps upstream under a new resume token.
+// cancel stops the upstream generation; it is called when no client has been attached for the retention window.
+func (reg *Registry) Start(r *http.Request, upstream *broadcast.Stream, cancel context.CancelFunc) (*Stream, error) {
+	raw := make([]byte, tokenBytes)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, err
+	}
+
+	s := &Stream{
+		Stream:   upstream,
+		token:    hex.EncodeToString(raw),
+		owner:    reg.scope(r),
+		metadata: metadata.From(r.Context()),
+		registry: reg,
+		cancel:   cancel,
+	}
+	reg.mtx.Lock()
+	reg.streams[s.token] = s
+	reg.mtx.Unlock()
+
+	go s.awaitFinish()
+	return s, nil
+}
+
+// Lookup returns the stream for token if it is still retained and belongs to the caller of r.
+func (reg *Registry) Lookup(r *http.Request, token string) (*Stream, bool) {
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+
+	s, ok := reg.streams[token]
+	if !ok || s.owner != reg.scope(r) {
+		return nil, false
+	}
+	return s, true
+}
+
+// Forget drops the streams of the requests subject matches, cancelling the generations still
+// running, and returns how many it dropped. A stream's owner is taken for its tenant, as the scope
+// partitions streams by tenant.
+func (reg *Registry) Forget(subject *erasure.Subject) int {
+	reg.mtx.Lock()
+	var forgotten []*Stream
+	for token, s := range reg.streams {
+		if subject.Matches(s.owner, s.metadata) {
+			delete(reg.streams, token)
+			forgotten = append(forgotten, s)
+		}
+	}
+	reg.mtx.Unlock()
+
+	for _, s := range forgotten {
+		s.mtx.Lock()
+		if s.expiry != nil {
+			s.expiry.Stop()
+			s.expiry = nil
+		}
+		s.mtx.Unlock()
+		s.cancel()
+	}
+	return len(forgotten)
+}
+
+func (reg *Registry) remove(token string) {
+	reg.mtx.Lock()
+	defer reg.mtx.Unlock()
+	delete(reg.streams, token)
+}
+
+// Stream is a resumable generation.
+type Stream struct {
+	*broadcast.Stream
+	token    string
+	owner    string
+	metadata map[string]string
+	registry *Registry
+	cancel   context.CancelFunc
+
+	mtx      sync.Mutex
+	finished bool
+	clients  int
+	expiry   *time.Timer
+}
+
+// Token returns the resume token of the stream.
+func (s *Stream) Token() string {
+	return s.token
+}
+
+// Attach marks a client as reading the stream, which keeps it from expiring.
+func (s *Stream) Attach() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.clients++
+	if s.expiry != nil {
+		s.expiry.Stop()
+		s.expiry = nil
+	}
+}
+
+// Detach marks a client as gone. The last client leaving starts the retention window.
+func (s *Stream) Detach() {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.clients--
+	if s.clients == 0 {
+		s.expireLocked()
+	}
+}
+
+// awaitFinish starts the retention window of a generation that finishes with no client attached.
+func (s *Stream) awaitFinish() {
+	<-s.Finished()
+
+	// Releases the detached upstream context
+	s.cancel()
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	s.finished = true
+	if s.clients == 0 {
+		s.expireLocked()
+	}
+}
+
+// expireLocked drops the stream after the retention window unless a client attaches first.
+// A generation still running by then has been abandoned, so it is cancelled to stop spending tokens.
+func (s *Stream) expireLocked() {
+	if s.expiry != nil {
+		s.expiry.Stop()
+	}
+	s.expiry = time.AfterFunc(s.registry.retention, func() {
+		s.mtx.Lock()
+		// A client may have attached while the timer was firing
+		if s.clients > 0 {
+			s.mtx.Unlock()
+			return
+		}
+		abandoned := !s.finished
+		s.mtx.Unlock()
+
+		s.registry.remove(s.token)
+		if abandoned {
+			slog.Info("Cancelling abandoned resumable stream")
+			s.cancel()
+		}
+	})
+}
@@ -0,0 +1,97 @@
+package resume
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/broadcast"
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/metadata"
+)
+
+func tenantScope(r *http.Request) string {
+	return r.Header.Get("X-Tenant")
+}
+
+func requestFor(tenant string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/v1/streams/x", nil)
+	r.Header.Set("X-Tenant", tenant)
+	return r
+}
+
+func TestStream_ReplaysFromOffset(t *testing.T) {
+	reg := NewRegistry(time.Minute, tenantScope)
+	upstream := make(chan interface{})
+	stream, err := reg.Start(requestFor("a"), broadcast.New(upstream), func() {})
+	require.NoError(t, err)
+
+	_, done, changed := stream.Next(0)
+	assert.False(t, done)
+	upstream <- "one"
+	<-changed
+	upstream <- "two"
+	close(upstream)
+
+	for {
+		chunks, done, changed := stream.Next(1)
+		if done {
+			assert.Equal(t, []interface{}{"two"}, chunks)
+			break
+		}
+		<-changed
+	}
+
+	found, ok := reg.Lookup(requestFor("a"), stream.Token())
+	require.True(t, ok)
+	assert.Same(t, stream, found)
+
+	_, ok = reg.Lookup(requestFor("b"), stream.Token())
+	assert.False(t, ok, "other tenants must not resume the stream")
+}
+
+func TestStream_CancelsAbandonedGeneration(t *testing.T) {
+	reg := NewRegistry(time.Millisecond, tenantScope)
+	upstream := make(chan interface{})
+	ctx, cancel := context.WithCancel(t.Context())
+	go func() {
+		<-ctx.Done()
+		close(upstream)
+	}()
+
+	stream, err := reg.Start(requestFor("a"), broadcast.New(upstream), cancel)
+	require.NoError(t, err)
+	stream.Attach()
+	stream.Detach()
+
+	<-ctx.Done()
+	_, ok := reg.Lookup(requestFor("a"), stream.Token())
+	assert.False(t, ok)
+}
+
+func TestRegistry_Forget(t *testing.T) {
+	reg := NewRegistry(time.Minute, tenantScope)
+	start := func(tenant, conversation string) (*Stream, context.Context) {
+		r := requestFor(tenant)
+		r = r.WithContext(metadata.With(r.Context(), map[string]string{"conversation_id": conversation}))
+		ctx, cancel := context.WithCancel(t.Context())
+		stream, err := reg.Start(r, broadcast.New(make(chan interface{})), cancel)
+		require.NoError(t, err)
+		return stream, ctx
+	}
+	forgotten, generation := start("a", "c-1")
+	kept, _ := start("a", "c-2")
+
+	assert.Equal(t, 1, reg.Forget(&erasure.Subject{Tenant: "a", ConversationID: "c-1"}))
+	<-generation.Done()
+	_, ok := reg.Lookup(requestFor("a"), forgotten.Token())
+	assert.False(t, ok)
+	_, ok = reg.Lookup(requestFor("a"), kept.Token())
+	assert.True(t, ok)
+	assert.Zero(t, reg.Forget(&erasure.Subject{Tenant: "b"}))
+}
@@ -0,0 +1,239 @@
+// Package routing applies the declarative routing rules from config. The first rule whose
+// conditions match a request may replace its model, pin it to a provider and override its
+// parameters, so routing policy changes with the config instead of the code. Conditions can
+// depend on the request, the time of day and the current load.
+package routing
+
+import (
+	"context"
+	"log/slog"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/multiplexer"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// Load counts the requests in flight per model, streams until their last chunk. It outlives a
+// Multiplexer so counts survive config reloads.
+type Load struct {
+	mtx      sync.Mutex
+	inFlight map[string]int64
+}
+
+// NewLoad creates a load with nothing in flight.
+func NewLoad() *Load {
+	return &Load{inFlight: make(map[string]int64)}
+}
+
+// InFlight returns the number of requests in flight for model.
+func (l *Load) InFlight(model string) int64 {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return l.inFlight[model]
+}
+
+// Snapshot returns the models with requests in flight and their counts.
+func (l *Load) Snapshot() map[string]int64 {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	return maps.Clone(l.inFlight)
+}
+
+// begin counts a request for model until the returned function is called.
+func (l *Load) begin(model string) func() {
+	l.mtx.Lock()
+	l.inFlight[model]++
+	l.mtx.Unlock()
+
+	return func() {
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+		l.inFlight[model]--
+		if l.inFlight[model] == 0 {
+			delete(l.inFlight, model)
+		}
+	}
+}
+
+// rule is a configured rule with its time window and days parsed.
+type rule struct {
+	config.RoutingRule
+	windowed   bool
+	start, end int
+	days       []time.Weekday
+}
+
+// Multiplexer wraps a multiplexer, routing each request by the first rule that matches it and
+// counting it on the load of the model it is routed to.
+// Requests no rule matches and listing pass straight through to the embedded multiplexer.
+type Multiplexer struct {
+	proxy.Multiplexer
+	rules    []rule
+	load     *Load
+	location *time.Location
+	now      func() time.Time
+}
+
+// NewMultiplexer wraps mux with the rules of cfg, which must have been validated,
+// counting requests on load.
+func NewMultiplexer(mux proxy.Multiplexer, cfg *config.RoutingConfig, load *Load) *Multiplexer {
+	rules := make([]rule, 0, len(cfg.Rules))
+	for _, r := range cfg.Rules {
+		start, end, windowed, _ := r.Match.HoursWindow()
+		days := make([]time.Weekday, 0, len(r.Match.Days))
+		for _, day := range r.Match.Days {
+			days = append(days, time.Weekday(slices.Index(config.Weekdays, day)))
+		}
+		rules = append(rules, rule{RoutingRule: r, windowed: windowed, start: start, end: end, days: days})
+	}
+	location, err := time.LoadLocation(cfg.Timezone)
+	if err != nil {
+		location = time.Local
+	}
+	return &Multiplexer{Multiplexer: mux, rules: rules, load: load, location: location, now: time.Now}
+}
+
+// ChatCompletion routes a chat completion by the first matching rule.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	ctx, model = m.route(ctx, model, providers.EstimateMessagesTokens(messages))
+	defer m.load.begin(model)()
+	return m.Multiplexer.ChatCompletion(ctx, model, messages)
+}
+
+// Completion routes a completion by the first matching rule.
+func (m *Multiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	ctx, model = m.route(ctx, model, providers.EstimateTextTokens(prompt))
+	defer m.load.begin(model)()
+	return m.Multiplexer.Completion(ctx, model, prompt)
+}
+
+// ChatCompletionStream routes a streaming chat completion by the first matching rule.
+func (m *Multiplexer) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	ctx, model = m.route(ctx, model, providers.EstimateMessagesTokens(messages))
+	done := m.load.begin(model)
+	stream, err := m.Multiplexer.ChatCompletionStream(ctx, model, messages)
+	return m.track(ctx, stream, err, done)
+}
+
+// CompletionStream routes a streaming completion by the first matching rule.
+func (m *Multiplexer) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	ctx, model = m.route(ctx, model, providers.EstimateTextTokens(prompt))
+	done := m.load.begin(model)
+	stream, err := m.Multiplexer.CompletionStream(ctx, model, prompt)
+	return m.track(ctx, stream, err, done)
+}
+
+// track keeps a stream counted until its last chunk has been passed on or ctx is done.
+func (m *Multiplexer) track(
+	ctx context.Context, stream <-chan interface{}, err error, done func(),
+) (<-chan interface{}, error) {
+	if err != nil {
+		done()
+		return nil, err
+	}
+	out := make(chan interface{})
+	go func() {
+		// The count drops before out closes, so a reader seeing the end sees it already released
+		defer close(out)
+		defer done()
+		for chunk := range stream {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out, nil
+}
+
+// route applies the first rule matching the request to its context and model.
+func (m *Multiplexer) route(ctx context.Context, model string, promptTokens int64) (context.Context, string) {
+	ctx, model, _ = m.Route(ctx, model, promptTokens)
+	return ctx, model
+}
+
+// Route returns the context and model a request for model with a prompt of promptTokens is
+// routed with, and the name of the rule that matched it, or "" when none did. Nothing is counted
+// on the load, so it can tell where a request would go without making it.
+func (m *Multiplexer) Route(ctx context.Context, model string, promptTokens int64) (context.Context, string, string) {
+	for i := range m.rules {
+		r := &m.rules[i]
+		if !m.matches(ctx, r, model, promptTokens) {
+			continue
+		}
+
+		slog.Debug("Routing rule matched", "rule", r.Name, "model", model, "prompt_tokens", promptTokens)
+		if r.Model != "" {
+			model = r.Model
+		}
+		ctx = multiplexer.WithProvider(ctx, r.Provider)
+		if len(r.Params) > 0 {
+			ctx = providers.WithParams(ctx, providers.ParamsFrom(ctx).Override(r.Params))
+		}
+		return ctx, model, r.Name
+	}
+	return ctx, model, ""
+}
+
+// matches reports whether every condition of r holds for the request.
+func (m *Multiplexer) matches(ctx context.Context, r *rule, model string, promptTokens int64) bool {
+	match := &r.Match
+	if len(match.Models) > 0 && !slices.Contains(match.Models, model) {
+		return false
+	}
+	if len(match.Tenants) > 0 && !slices.Contains(match.Tenants, usage.TenantFrom(ctx)) {
+		return false
+	}
+	if len(match.Metadata) > 0 {
+		values := metadata.From(ctx)
+		for _, key := range slices.Sorted(maps.Keys(match.Metadata)) {
+			if value, ok := values[key]; !ok || value != match.Metadata[key] {
+				return false
+			}
+		}
+	}
+	if promptTokens < match.MinPromptTokens {
+		return false
+	}
+	if match.MaxPromptTokens > 0 && promptTokens > match.MaxPromptTokens {
+		return false
+	}
+	if r.windowed || len(r.days) > 0 {
+		now := m.now().In(m.location)
+		if r.windowed && !inWindow(now, r.start, r.end) {
+			return false
+		}
+		if len(r.days) > 0 && !slices.Contains(r.days, now.Weekday()) {
+			return false
+		}
+	}
+	if match.MinInFlight > 0 || match.MaxInFlight > 0 {
+		inFlight := m.load.InFlight(model)
+		if inFlight < match.MinInFlight || (match.MaxInFlight > 0 && inFlight > match.MaxInFlight) {
+			return false
+		}
+	}
+	return true
+}
+
+// inWindow reports whether the time of day of t falls in [start, end), in minutes after midnight.
+// A window ending before it starts wraps past midnight.
+func inWindow(t time.Time, start, end int) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
@@ -0,0 +1,235 @@
+package routing
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/multiplexer"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// providerServer answers chat completions with the provider's name, the model and the temperature it received.
+func providerServer(t *testing.T, name string) string {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.Header().Set("Content-Type", "application/json")
+		assert.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{
+			"provider": name, "model": body["model"], "temperature": body["temperature"],
+		}))
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func newMultiplexer(t *testing.T, rules ...config.RoutingRule) *Multiplexer {
+	t.Helper()
+	return newMultiplexerWith(t, &config.RoutingConfig{Rules: rules}, NewLoad())
+}
+
+func newMultiplexerWith(t *testing.T, cfg *config.RoutingConfig, load *Load) *Multiplexer {
+	t.Helper()
+	mux := multiplexer.New([]config.Provider{
+		{Name: "primary", Type: "openai", BaseURL: providerServer(t, "primary"), Models: []string{"gpt-4"}, Priority: 1},
+		{Name: "cheap", Type: "openai", BaseURL: providerServer(t, "cheap"), Models: []string{"gpt-4o-mini"}, Priority: 2},
+	})
+	return NewMultiplexer(mux, cfg, load)
+}
+
+var userMessage = []map[string]interface{}{{"role": "user", "content": "Hello"}}
+
+func chat(t *testing.T, ctx context.Context, mux *Multiplexer, model, content string) map[string]interface{} {
+	t.Helper()
+	result, err := mux.ChatCompletion(ctx, model, []map[string]interface{}{{"role": "user", "content": content}})
+	require.NoError(t, err)
+	return result.(map[string]interface{})
+}
+
+func TestMultiplexer_FirstMatchingRuleWins(t *testing.T) {
+	mux := newMultiplexer(t,
+		config.RoutingRule{
+			Name:     "batch jobs",
+			Match:    config.RoutingMatch{Metadata: map[string]string{"job": "batch"}},
+			Model:    "gpt-4o-mini",
+			Provider: "cheap",
+		},
+		config.RoutingRule{
+			Name:   "acme",
+			Match:  config.RoutingMatch{Tenants: []string{"acme"}},
+			Params: map[string]interface{}{"temperature": 0.0},
+		},
+		config.RoutingRule{Name: "everyone", Model: "gpt-4o-mini"},
+	)
+
+	acme := usage.WithTenant(t.Context(), "acme")
+	result := chat(t, metadata.With(acme, map[string]string{"job": "batch"}), mux, "gpt-4", "Hello")
+	assert.Equal(t, "cheap", result["provider"])
+	assert.Equal(t, "gpt-4o-mini", result["model"])
+	assert.Nil(t, result["temperature"], "later rules never apply once one matched")
+
+	result = chat(t, acme, mux, "gpt-4", "Hello")
+	assert.Equal(t, "primary", result["provider"])
+	assert.Equal(t, "gpt-4", result["model"])
+	assert.Equal(t, 0.0, result["temperature"])
+
+	result = chat(t, t.Context(), mux, "gpt-4", "Hello")
+	assert.Equal(t, "cheap", result["provider"], "the rewritten model is routed to its provider")
+	assert.Equal(t, "gpt-4o-mini", result["model"])
+}
+
+func TestMultiplexer_ParamOverridesKeepRequestParams(t *testing.T) {
+	mux := newMultiplexer(t, config.RoutingRule{Params: map[string]interface{}{"temperature": 0.0}})
+
+	params := providers.NewParams(map[string]interface{}{"temperature": 1.0, "logit_bias": map[string]interface{}{}}, nil)
+	result := chat(t, providers.WithParams(t.Context(), params), mux, "gpt-4", "Hello")
+	assert.Equal(t, 0.0, result["temperature"])
+	assert.Equal(t, 1.0, params.Values()["temperature"], "the request's own params are left as they were")
+}
+
+func TestMultiplexer_PromptTokens(t *testing.T) {
+	mux := newMultiplexer(t,
+		config.RoutingRule{Name: "long", Match: config.RoutingMatch{MinPromptTokens: 100}, Provider: "cheap"},
+		config.RoutingRule{Name: "short", Match: config.RoutingMatch{MaxPromptTokens: 10}, Model: "gpt-4o-mini"},
+	)
+
+	result := chat(t, t.Context(), mux, "gpt-4", strings.Repeat("word ", 100))
+	assert.Equal(t, "cheap", result["provider"])
+	assert.Equal(t, "gpt-4", result["model"])
+
+	result = chat(t, t.Context(), mux, "gpt-4", "Hello")
+	assert.Equal(t, "gpt-4o-mini", result["model"])
+
+	result = chat(t, t.Context(), mux, "gpt-4", strings.Repeat("word ", 20))
+	assert.Equal(t, "primary", result["provider"], "requests no rule matches are routed as usual")
+	assert.Equal(t, "gpt-4", result["model"])
+}
+
+func TestMultiplexer_Hours(t *testing.T) {
+	mux := newMultiplexer(t, config.RoutingRule{
+		Name:     "overnight",
+		Match:    config.RoutingMatch{Models: []string{"gpt-4"}, Hours: "22:00-06:00"},
+		Provider: "cheap",
+	})
+
+	tests := []struct {
+		clock    string
+		provider string
+	}{
+		{"23:30", "cheap"},
+		{"05:59", "cheap"},
+		{"06:00", "primary"},
+		{"12:00", "primary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.clock, func(t *testing.T) {
+			now, err := time.ParseInLocation("15:04", tt.clock, time.Local)
+			require.NoError(t, err)
+			mux.now = func() time.Time { return now }
+			assert.Equal(t, tt.provider, chat(t, t.Context(), mux, "gpt-4", "Hello")["provider"])
+		})
+	}
+}
+
+func TestMultiplexer_DaysInTimezone(t *testing.T) {
+	mux := newMultiplexerWith(t, &config.RoutingConfig{
+		Timezone: "UTC",
+		Rules: []config.RoutingRule{{
+			Name:     "business hours",
+			Match:    config.RoutingMatch{Hours: "09:00-17:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}},
+			Provider: "cheap",
+		}},
+	}, NewLoad())
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		provider string
+	}{
+		{"monday morning", time.Date(2026, time.October, 19, 10, 0, 0, 0, time.UTC), "cheap"},
+		{"monday evening", time.Date(2026, time.October, 19, 18, 0, 0, 0, time.UTC), "primary"},
+		{"saturday morning", time.Date(2026, time.October, 17, 10, 0, 0, 0, time.UTC), "primary"},
+		{"read in the configured zone", time.Date(2026, time.October, 19, 10, 0, 0, 0, time.FixedZone("X", 12*3600)), "primary"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux.now = func() time.Time { return tt.now }
+			assert.Equal(t, tt.provider, chat(t, t.Context(), mux, "gpt-4", "Hello")["provider"])
+		})
+	}
+}
+
+// streamingMultiplexer answers streams with channels the test closes and echoes the model otherwise.
+type streamingMultiplexer struct {
+	proxy.Multiplexer
+	streams chan chan interface{}
+}
+
+func (m *streamingMultiplexer) ChatCompletion(
+	_ context.Context, model string, _ []map[string]interface{},
+) (interface{}, error) {
+	return map[string]interface{}{"model": model}, nil
+}
+
+func (m *streamingMultiplexer) ChatCompletionStream(
+	_ context.Context, _ string, _ []map[string]interface{},
+) (<-chan interface{}, error) {
+	stream := make(chan interface{})
+	m.streams <- stream
+	return stream, nil
+}
+
+func TestMultiplexer_InFlight(t *testing.T) {
+	upstream := &streamingMultiplexer{streams: make(chan chan interface{}, 2)}
+	load := NewLoad()
+	mux := NewMultiplexer(upstream, &config.RoutingConfig{Rules: []config.RoutingRule{{
+		Name:  "overflow",
+		Match: config.RoutingMatch{Models: []string{"gpt-4"}, MinInFlight: 2},
+		Model: "gpt-4o-mini",
+	}}}, load)
+
+	first, err := mux.ChatCompletionStream(t.Context(), "gpt-4", userMessage)
+	require.NoError(t, err)
+	second, err := mux.ChatCompletionStream(t.Context(), "gpt-4", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"gpt-4": 2}, load.Snapshot())
+
+	result, err := mux.ChatCompletion(t.Context(), "gpt-4", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4o-mini", result.(map[string]interface{})["model"])
+
+	// Streams stay counted until their last chunk has been passed on
+	for range 2 {
+		stream := <-upstream.streams
+		stream <- "chunk"
+		close(stream)
+	}
+	assert.Equal(t, []interface{}{"chunk"}, collect(first))
+	assert.Equal(t, []interface{}{"chunk"}, collect(second))
+	assert.Zero(t, load.InFlight("gpt-4"))
+
+	result, err = mux.ChatCompletion(t.Context(), "gpt-4", userMessage)
+	require.NoError(t, err)
+	assert.Equal(t, "gpt-4", result.(map[string]interface{})["model"])
+	assert.Empty(t, load.Snapshot())
+}
+
+func collect(stream <-chan interface{}) []interface{} {
+	var chunks []interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
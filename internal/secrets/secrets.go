@@ -0,0 +1,206 @@
+// Package secrets provides at-rest encryption and OS keychain lookup for provider credentials.
+// Encrypted values are stored in config as "enc:v1:<base64>" so config files can be committed
+// without plaintext secrets; "keychain:<service>/<account>" values are read from the OS keychain.
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+const (
+	// EncryptedPrefix marks a config value encrypted with a passphrase
+	EncryptedPrefix = "enc:v1:"
+	// KeychainPrefix marks a config value stored in the OS keychain
+	KeychainPrefix = "keychain:"
+	// PassphraseEnv is the environment variable consulted for the unlock passphrase
+	PassphraseEnv = "MODELPLEX_SECRETS_PASSPHRASE"
+
+	saltSize        = 16
+	keySize         = 32
+	kdfIterations   = 600000 // OWASP recommendation for PBKDF2-HMAC-SHA256
+	keychainTimeout = 10 * time.Second
+)
+
+// ErrPassphraseRequired is returned when encrypted values are present but no passphrase was given.
+var ErrPassphraseRequired = errors.New("encrypted secrets present but no passphrase provided")
+
+// Encrypt seals plaintext with a key derived from passphrase and returns a config-ready value.
+func Encrypt(plaintext, passphrase string) (string, error) {
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	gcm, err := newGCM(passphrase, salt)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	sealed := gcm.Seal(nil, nonce, []byte(plaintext), nil)
+	blob := append(append(salt, nonce...), sealed...)
+	return EncryptedPrefix + base64.StdEncoding.EncodeToString(blob), nil
+}
+
+// Decrypt opens a value produced by Encrypt.
+func Decrypt(value, passphrase string) (string, error) {
+	blob, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, EncryptedPrefix))
+	if err != nil {
+		return "", fmt.Errorf("invalid encrypted value: %w", err)
+	}
+	if len(blob) < saltSize {
+		return "", errors.New("invalid encrypted value: too short")
+	}
+
+	gcm, err := newGCM(passphrase, blob[:saltSize])
+	if err != nil {
+		return "", err
+	}
+
+	rest := blob[saltSize:]
+	if len(rest) < gcm.NonceSize() {
+		return "", errors.New("invalid encrypted value: too short")
+	}
+
+	plaintext, err := gcm.Open(nil, rest[:gcm.NonceSize()], rest[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("failed to decrypt value: wrong passphrase or corrupted data")
+	}
+
+	return string(plaintext), nil
+}
+
+func newGCM(passphrase string, salt []byte) (cipher.AEAD, error) {
+	key, err := pbkdf2.Key(sha256.New, passphrase, salt, kdfIterations, keySize)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// IsSecretRef reports whether value needs resolving before use.
+func IsSecretRef(value string) bool {
+	return strings.HasPrefix(value, EncryptedPrefix) || strings.HasPrefix(value, KeychainPrefix)
+}
+
+// Resolver turns encrypted and keychain references into plaintext values.
+type Resolver struct {
+	Passphrase string
+	// lookupKeychain is swappable so tests don't touch the real keychain
+	lookupKeychain func(ctx context.Context, service, account string) (string, error)
+}
+
+// NewResolver creates a resolver that unlocks encrypted values with passphrase.
+func NewResolver(passphrase string) *Resolver {
+	return &Resolver{
+		Passphrase:     passphrase,
+		lookupKeychain: lookupKeychain,
+	}
+}
+
+// Resolve returns the plaintext for value, expanding "${ENV_VAR}" references as well.
+// Values without a secret prefix are returned unchanged.
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	switch {
+	case strings.HasPrefix(value, "${") && strings.HasSuffix(value, "}"):
+		return os.Getenv(strings.TrimSuffix(strings.TrimPrefix(value, "${"), "}")), nil
+	case strings.HasPrefix(value, EncryptedPrefix):
+		if r.Passphrase == "" {
+			return "", ErrPassphraseRequired
+		}
+		return Decrypt(value, r.Passphrase)
+	case strings.HasPrefix(value, KeychainPrefix):
+		service, account, ok := strings.Cut(strings.TrimPrefix(value, KeychainPrefix), "/")
+		if !ok || service == "" || account == "" {
+			return "", fmt.Errorf("invalid keychain reference %q, expected keychain:<service>/<account>", value)
+		}
+		return r.lookupKeychain(ctx, service, account)
+	default:
+		return value, nil
+	}
+}
+
+// ResolveConfig replaces every secret reference in the credentials of cfg with its plaintext.
+func (r *Resolver) ResolveConfig(ctx context.Context, cfg *config.Config) error {
+	var errs []error
+	resolve := func(owner string, field *string) {
+		resolved, err := r.Resolve(ctx, *field)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", owner, err))
+			return
+		}
+		*field = resolved
+	}
+
+	for i := range cfg.Providers {
+		p := &cfg.Providers[i]
+		resolve("provider "+p.Name, &p.APIKey)
+		resolve("provider "+p.Name, &p.Auth.ClientSecret)
+		resolve("provider "+p.Name, &p.Bedrock.AccessKeyID)
+		resolve("provider "+p.Name, &p.Bedrock.SecretAccessKey)
+		resolve("provider "+p.Name, &p.Bedrock.SessionToken)
+		for _, extras := range []map[string]string{p.ExtraHeaders, p.ExtraQuery} {
+			for key, value := range extras {
+				resolve("provider "+p.Name+" "+key, &value)
+				extras[key] = value
+			}
+		}
+	}
+	resolve("usage", &cfg.Usage.APIKey)
+	resolve("mcp capabilities", &cfg.MCP.Capabilities.Secret)
+	resolve("provenance", &cfg.Provenance.Secret)
+	resolve("conversations", &cfg.Conversations.Secret)
+	for i := range cfg.Admin.Tokens {
+		resolve(fmt.Sprintf("admin token %d", i), &cfg.Admin.Tokens[i].Token)
+	}
+
+	return errors.Join(errs...)
+}
+
+// lookupKeychain reads a generic password from the platform keychain via its CLI.
+func lookupKeychain(ctx context.Context, service, account string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, keychainTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.CommandContext(ctx, "security", "find-generic-password", "-s", service, "-a", account, "-w")
+	case "linux":
+		cmd = exec.CommandContext(ctx, "secret-tool", "lookup", "service", service, "account", account)
+	default:
+		return "", fmt.Errorf("keychain lookup is not supported on %s", runtime.GOOS)
+	}
+
+	out, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("keychain lookup for %s/%s failed: %w", service, account, err)
+	}
+
+	return strings.TrimRight(string(out), "\r\n"), nil
+}
@@ -0,0 +1,113 @@
+package secrets
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/conversation"
+	"github.com/modelplex/modelplex/internal/state"
+)
+
+func TestEncryptDecrypt(t *testing.T) {
+	encrypted, err := Encrypt("sk-test123", "correct horse")
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(encrypted, EncryptedPrefix))
+	assert.NotContains(t, encrypted, "sk-test123")
+
+	plaintext, err := Decrypt(encrypted, "correct horse")
+	require.NoError(t, err)
+	assert.Equal(t, "sk-test123", plaintext)
+
+	_, err = Decrypt(encrypted, "wrong passphrase")
+	assert.Error(t, err)
+
+	_, err = Decrypt(EncryptedPrefix+"not-base64!", "correct horse")
+	assert.Error(t, err)
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	encrypted, err := Encrypt("sk-encrypted", "pass")
+	require.NoError(t, err)
+
+	t.Setenv("TEST_RESOLVER_KEY", "sk-env")
+
+	r := NewResolver("pass")
+	r.lookupKeychain = func(_ context.Context, service, account string) (string, error) {
+		if service == "modelplex" && account == "openai" {
+			return "sk-keychain", nil
+		}
+		return "", errors.New("not found")
+	}
+
+	tests := []struct {
+		name     string
+		value    string
+		expected string
+		wantErr  bool
+	}{
+		{name: "plain value", value: "sk-plain", expected: "sk-plain"},
+		{name: "env reference", value: "${TEST_RESOLVER_KEY}", expected: "sk-env"},
+		{name: "encrypted", value: encrypted, expected: "sk-encrypted"},
+		{name: "keychain", value: "keychain:modelplex/openai", expected: "sk-keychain"},
+		{name: "keychain missing", value: "keychain:modelplex/other", wantErr: true},
+		{name: "keychain malformed", value: "keychain:modelplex", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := r.Resolve(t.Context(), tt.value)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.expected, result)
+		})
+	}
+}
+
+func TestResolver_ResolveConfig(t *testing.T) {
+	encrypted, err := Encrypt("sk-encrypted", "pass")
+	require.NoError(t, err)
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "openai", APIKey: encrypted},
+			{Name: "azure", Auth: config.ProviderAuth{ClientSecret: encrypted}},
+			{Name: "gateway", ExtraHeaders: map[string]string{"X-Gateway-Key": encrypted, "X-Tenant": "t1"}},
+		},
+		MCP: config.MCPConfig{Capabilities: config.MCPCapabilities{Secret: encrypted}},
+	}
+
+	require.NoError(t, NewResolver("pass").ResolveConfig(t.Context(), cfg))
+	assert.Equal(t, "sk-encrypted", cfg.Providers[0].APIKey)
+	assert.Equal(t, "sk-encrypted", cfg.Providers[1].Auth.ClientSecret)
+	assert.Equal(t, map[string]string{"X-Gateway-Key": "sk-encrypted", "X-Tenant": "t1"}, cfg.Providers[2].ExtraHeaders)
+	assert.Equal(t, "sk-encrypted", cfg.MCP.Capabilities.Secret)
+
+	locked := &config.Config{Providers: []config.Provider{{Name: "openai", APIKey: encrypted}}}
+	err = NewResolver("").ResolveConfig(t.Context(), locked)
+	assert.ErrorIs(t, err, ErrPassphraseRequired)
+}
+
+func TestResolver_ResolveConfig_ConversationSecret(t *testing.T) {
+	t.Setenv("MODELPLEX_TEST_CONVERSATION_SECRET", "s3cret")
+	cfg := &config.Config{Conversations: config.ConversationsConfig{Secret: "${MODELPLEX_TEST_CONVERSATION_SECRET}"}}
+	require.NoError(t, NewResolver("").ResolveConfig(t.Context(), cfg))
+	assert.Equal(t, "s3cret", cfg.Conversations.Secret)
+
+	// Exports are signed with the resolved secret, never the placeholder published in sample configs
+	migrator := conversation.NewMigrator(cfg.Conversations.Secret, state.NewMemoryStore(), nil, nil, time.Hour)
+	export, _, err := migrator.Export(t.Context(), "c-1")
+	require.NoError(t, err)
+	forger := conversation.NewMigrator("${MODELPLEX_TEST_CONVERSATION_SECRET}", state.NewMemoryStore(), nil, nil, time.Hour)
+	_, err = forger.Import(t.Context(), export)
+	assert.ErrorIs(t, err, conversation.ErrInvalid)
+}
@@ -0,0 +1,49 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+)
+
+// ListenerFDEnv names the environment variable telling an upgraded process the file descriptor of the
+// listener it inherits from the process it replaces.
+const ListenerFDEnv = "MODELPLEX_LISTENER_FD"
+
+// Inherit makes Start serve l, a listener handed over by the process being upgraded, instead of
+// listening itself. It must be called before Start.
+func (s *Server) Inherit(l net.Listener) {
+	s.startMtx.Lock()
+	defer s.startMtx.Unlock()
+	s.inherited = l
+}
+
+// ListenerFile returns a duplicate of the listener's file descriptor to hand to another process.
+// The server keeps serving until Drain.
+func (s *Server) ListenerFile() (*os.File, error) {
+	s.startMtx.RLock()
+	defer s.startMtx.RUnlock()
+
+	if s.listener == nil {
+		return nil, errors.New("server is not running")
+	}
+	filer, ok := s.listener.(interface{ File() (*os.File, error) })
+	if !ok {
+		return nil, errors.New("listener can't be handed over")
+	}
+	return filer.File()
+}
+
+// Drain stops the server like Stop, waiting for requests in flight until ctx is done, but leaves the
+// socket file to the process the listener was handed to.
+func (s *Server) Drain(ctx context.Context) {
+	s.startMtx.Lock()
+	s.handedOff = true
+	if unix, ok := s.listener.(*net.UnixListener); ok {
+		unix.SetUnlinkOnClose(false)
+	}
+	s.startMtx.Unlock()
+
+	s.Stop(ctx)
+}
@@ -0,0 +1,128 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/events"
+)
+
+// maintenanceMode closes the model routes during planned maintenance, such as a provider migration:
+// they answer 503 with a message for clients, so agents see a planned pause rather than an outage,
+// while health and admin endpoints stay up. It starts as maintenance.enabled says, is toggled by
+// admins at runtime and outlives reloads; the configured message and Retry-After follow reloads.
+type maintenanceMode struct {
+	mtx     sync.Mutex
+	enabled bool
+	since   time.Time
+	// message replaces maintenance.message until maintenance mode ends, when an admin gave one
+	message string
+}
+
+// maintenanceState is the state of maintenance mode.
+type maintenanceState struct {
+	Enabled bool `json:"enabled"`
+	// Since is when maintenance mode began; nil while it is off
+	Since *time.Time `json:"since,omitempty"`
+	// Message is what clients are told while it is on
+	Message           string `json:"message"`
+	RetryAfterSeconds int64  `json:"retry_after_seconds,omitempty"`
+}
+
+// set turns maintenance mode on or off, telling clients message instead of the configured one while
+// on when message is set. It returns whether maintenance mode was turned on or off.
+func (m *maintenanceMode) set(enabled bool, message string) bool {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	changed := m.enabled != enabled
+	if changed && enabled {
+		m.since = time.Now()
+	}
+	m.enabled = enabled
+	m.message = ""
+	if enabled {
+		m.message = message
+	}
+	return changed
+}
+
+// state reports the state of maintenance mode under cfg.
+func (m *maintenanceMode) state(cfg *config.MaintenanceConfig) maintenanceState {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	state := maintenanceState{Enabled: m.enabled, Message: cfg.Message, RetryAfterSeconds: cfg.RetryAfterSeconds}
+	if m.message != "" {
+		state.Message = m.message
+	}
+	if m.enabled {
+		since := m.since
+		state.Since = &since
+	}
+	return state
+}
+
+// closedForMaintenance refuses every request to next with a 503 carrying the maintenance message
+// while maintenance mode is on.
+func (s *Server) closedForMaintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		state := s.maintenanceMode.state(&s.currentConfig().Maintenance)
+		if !state.Enabled {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if state.RetryAfterSeconds > 0 {
+			w.Header().Set("Retry-After", strconv.FormatInt(state.RetryAfterSeconds, 10))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusServiceUnavailable)
+		errorResp := map[string]interface{}{
+			"error": map[string]interface{}{
+				"message": state.Message,
+				"type":    "maintenance",
+				"code":    "maintenance",
+			},
+		}
+		if err := json.NewEncoder(w).Encode(errorResp); err != nil {
+			slog.Error("Error writing maintenance response", "error", err)
+		}
+	})
+}
+
+// handleInternalMaintenance reports whether maintenance mode is on, and turns it on or off on POST
+// with {"enabled": bool}, optionally with a "message" for clients in place of maintenance.message.
+func (s *Server) handleInternalMaintenance(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		var req struct {
+			Enabled *bool  `json:"enabled"`
+			Message string `json:"message"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			writeJSONError(w, http.StatusBadRequest, `expected a JSON body with "enabled"`)
+			return
+		}
+		if s.maintenanceMode.set(*req.Enabled, req.Message) {
+			if *req.Enabled {
+				message := s.maintenanceMode.state(&s.currentConfig().Maintenance).Message
+				slog.Warn("Maintenance mode on, model routes are closed", "message", message)
+				s.events.Emit(events.TypeMaintenanceStarted, "maintenance", map[string]string{"message": message})
+			} else {
+				slog.Info("Maintenance mode off, model routes are open")
+				s.events.Emit(events.TypeMaintenanceEnded, "maintenance", nil)
+			}
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	state := s.maintenanceMode.state(&s.currentConfig().Maintenance)
+	if err := json.NewEncoder(w).Encode(state); err != nil {
+		slog.Error("Error writing maintenance response", "error", err)
+	}
+}
@@ -2,22 +2,59 @@
 package server
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"log/slog"
+	"math"
 	"net"
 	"net/http"
+	"net/http/httputil"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/gorilla/mux"
 
+	"github.com/modelplex/modelplex/internal/approval"
+	"github.com/modelplex/modelplex/internal/audit"
+	"github.com/modelplex/modelplex/internal/auth"
+	"github.com/modelplex/modelplex/internal/broadcast"
+	"github.com/modelplex/modelplex/internal/budget"
+	"github.com/modelplex/modelplex/internal/cache"
+	"github.com/modelplex/modelplex/internal/capability"
+	"github.com/modelplex/modelplex/internal/catalog"
+	"github.com/modelplex/modelplex/internal/coalesce"
 	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/conversation"
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/estimate"
+	"github.com/modelplex/modelplex/internal/events"
+	"github.com/modelplex/modelplex/internal/gemini"
+	"github.com/modelplex/modelplex/internal/idempotency"
+	"github.com/modelplex/modelplex/internal/injection"
+	"github.com/modelplex/modelplex/internal/journal"
+	"github.com/modelplex/modelplex/internal/judge"
+	"github.com/modelplex/modelplex/internal/loops"
+	"github.com/modelplex/modelplex/internal/mcp"
 	"github.com/modelplex/modelplex/internal/multiplexer"
+	"github.com/modelplex/modelplex/internal/openapi"
+	"github.com/modelplex/modelplex/internal/providers"
 	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/resume"
+	"github.com/modelplex/modelplex/internal/routing"
+	"github.com/modelplex/modelplex/internal/sessions"
+	"github.com/modelplex/modelplex/internal/startup"
+	"github.com/modelplex/modelplex/internal/state"
+	"github.com/modelplex/modelplex/internal/tags"
+	"github.com/modelplex/modelplex/internal/usage"
+	"github.com/modelplex/modelplex/internal/version"
 )
 
 const (
@@ -25,6 +62,19 @@ const (
 	shutdownTimeout = 5 * time.Second
 	readTimeout     = 30 * time.Second
 	writeTimeout    = 30 * time.Second
+	// rateLimitWindow is the fixed window for limits.requests_per_minute
+	rateLimitWindow = time.Minute
+	// globalRateLimitKey is the counter shared by all clients
+	globalRateLimitKey = "global"
+)
+
+// TokenCountHeader tells whether a count_tokens answer was counted by the provider or estimated.
+const TokenCountHeader = "X-Modelplex-Token-Count"
+
+// Values of TokenCountHeader.
+const (
+	tokenCountProvider  = "provider"
+	tokenCountEstimated = "estimated"
 )
 
 // Server provides HTTP server functionality over Unix domain sockets or HTTP.
@@ -36,13 +86,84 @@ type Server struct {
 	server     *http.Server
 	mux        *multiplexer.ModelMultiplexer
 	proxy      *proxy.OpenAIProxy
+	store      state.Store
+	limiter    *state.RateLimiter
+	budget     *budget.Budget
+	cache      *cache.Cache
+	idempotent *idempotency.Guard
+	admin      *auth.Authenticator
+	readOnly   bool
+	usage      *usage.Exporter
+	usageStop  context.CancelFunc
+	usageDone  chan struct{}
 	startMtx   sync.RWMutex
 	started    chan struct{}
+	// reloadMtx guards config, mux and proxy, which Reload swaps while serving
+	reloadMtx sync.RWMutex
+
+	// coalesceStats is nil unless coalescing is enabled; coalesceRoutes keeps the startup routes
+	coalesceStats  *coalesce.Stats
+	coalesceRoutes []string
+	// streams is nil unless streams are resumable; it outlives reloads so tokens stay valid
+	streams *resume.Registry
+	// judgeStats is nil unless judge scoring is enabled; judgeConfig keeps the startup judge
+	judgeStats  *judge.Stats
+	judgeConfig config.JudgeConfig
+	// injection is nil unless prompt injection detection is enabled; it keeps the startup rules
+	injection      *injection.Detector
+	injectionStats *injection.Stats
+	loops          *loops.Detector
+	// tagStats is nil unless request tags are allowed; tagsConfig keeps the startup tags
+	tagStats   *tags.Stats
+	tagsConfig config.TagsConfig
+	// live tracks generations in progress for tailing; nil in strict privacy mode
+	live *broadcast.Registry
+	// load counts the requests in flight per model for routing rules; it outlives reloads
+	load *routing.Load
+	// restartStats counts streams restarted before their first token or salvaged; it outlives reloads
+	restartStats *multiplexer.RestartStats
+	// events delivers operational events to the startup webhook
+	events *events.Notifier
+	// journal keeps recent provider failures; it outlives reloads
+	journal *journal.Journal
+	// audit is nil unless requests are journaled for compliance; it outlives reloads
+	audit *audit.Log
+	// conversations is nil unless conversations.secret is set; it outlives reloads
+	conversations *conversation.Migrator
+	// capabilities is nil unless MCP tool calls must present a capability token; it outlives reloads
+	capabilities *capability.Minter
+	// mcp runs the configured MCP servers and calls their tools; nil without any, it keeps the startup servers
+	mcp *mcp.Client
+	// approvals is nil unless tool calls can need an operator's approval; it outlives reloads
+	approvals *approval.Queue
+	// sessions tracks the conversations and tenants operators terminated; it outlives reloads
+	sessions *sessions.Registry
+	// maintenanceMode closes the model routes for planned maintenance; it outlives reloads
+	maintenanceMode maintenanceMode
+	// maintenanceStop ends the maintenance of the current multiplexer's local backends, which a
+	// reload restarts
+	maintenanceStop context.CancelFunc
+	maintenanceDone chan struct{}
+	// updates is nil unless checking for newer releases is enabled
+	updates     *version.Checker
+	updatesStop context.CancelFunc
+	updatesDone chan struct{}
+	// startup waits for the startup dependencies and reports readiness; it keeps the startup dependencies
+	startup     *startup.Gate
+	startupStop context.CancelFunc
+	startupDone chan struct{}
+	// inherited is the listener handed over by an upgraded process, served instead of listening
+	inherited net.Listener
+	// handedOff leaves the socket file to the process the listener was handed to
+	handedOff bool
 }
 
 // NewWithSocket creates a new server instance with Unix socket.
+// Unset fields of cfg are filled with their defaults and from the model catalog.
 func NewWithSocket(cfg *config.Config, socketPath string) *Server {
-	muxer := multiplexer.New(cfg.Providers)
+	config.ApplyDefaults(cfg)
+	catalog.Apply(cfg)
+	muxer := multiplexer.New(cfg.Providers, multiplexer.WithRoutes(cfg.Routing.Models))
 	pr := proxy.New(muxer)
 
 	return &Server{
@@ -55,8 +176,11 @@ func NewWithSocket(cfg *config.Config, socketPath string) *Server {
 }
 
 // NewWithHTTPAddress creates a new server instance with HTTP using address string.
+// Unset fields of cfg are filled with their defaults and from the model catalog.
 func NewWithHTTPAddress(cfg *config.Config, addr string) *Server {
-	muxer := multiplexer.New(cfg.Providers)
+	config.ApplyDefaults(cfg)
+	catalog.Apply(cfg)
+	muxer := multiplexer.New(cfg.Providers, multiplexer.WithRoutes(cfg.Routing.Models))
 	pr := proxy.New(muxer)
 
 	return &Server{
@@ -79,17 +203,131 @@ func (s *Server) Start() <-chan error {
 			return errors.New("server is already running")
 		}
 
-		if s.socketPath != "" {
-			// Check if socket already exists and error if it does
-			if _, statErr := os.Stat(s.socketPath); statErr == nil {
-				return fmt.Errorf("socket file already exists: %s", s.socketPath)
+		s.admin, err = auth.New(&s.config.Admin)
+		if err != nil {
+			return fmt.Errorf("invalid admin auth config: %w", err)
+		}
+		s.readOnly = s.config.Server.ReadOnly
+		providers.SetFaultInjection(s.config.Chaos.Enabled)
+		providers.SetPausePolicy(s.config.Pause)
+		s.maintenanceMode.set(s.config.Maintenance.Enabled, "")
+		if s.config.Chaos.Enabled {
+			slog.Warn("Chaos mode enabled, configured provider faults will be injected")
+		}
+		if s.readOnly {
+			slog.Info("Read-only mode enabled, admin mutations and MCP tool calls are disabled")
+		}
+		if s.config.Maintenance.Enabled {
+			slog.Warn("Maintenance mode enabled, model routes are closed")
+		}
+
+		s.store, err = state.New(&s.config.State)
+		if err != nil {
+			return fmt.Errorf("failed to create state store: %w", err)
+		}
+		if s.config.Privacy.Strict {
+			slog.Info("Strict privacy mode enabled, request and response content is never retained")
+			s.store = state.NoRetention(s.store)
+		}
+		if s.config.Limits.RequestsPerMinute > 0 {
+			s.limiter = state.NewRateLimiter(s.store, s.config.Limits.RequestsPerMinute, rateLimitWindow)
+		}
+		s.budget = budget.New(s.store, &s.config.Limits.Conversation)
+		if s.config.Cache.Enabled {
+			s.cache = cache.New(s.store, time.Duration(s.config.Cache.TTLSeconds)*time.Second)
+		}
+		// Replays need the recorded response, so strict privacy mode goes without them
+		if !s.config.Privacy.Strict {
+			s.idempotent = idempotency.New(s.store, time.Duration(s.config.Idempotency.WindowSeconds)*time.Second,
+				func(r *http.Request) string { return usage.TenantFrom(r.Context()) })
+		}
+		if s.config.Coalesce.Enabled {
+			s.coalesceStats = coalesce.NewStats()
+			s.coalesceRoutes = s.config.Coalesce.Routes
+		}
+		if s.config.Judge.Enabled {
+			s.judgeStats = judge.NewStats()
+			s.judgeConfig = s.config.Judge
+		}
+		if s.config.Injection.Enabled {
+			if s.injection, err = injection.NewDetector(&s.config.Injection); err != nil {
+				return fmt.Errorf("invalid injection rules: %w", err)
+			}
+			s.injectionStats = injection.NewStats()
+		}
+		if s.config.Loops.Enabled {
+			s.loops = loops.NewDetector(&s.config.Loops)
+		}
+		if len(s.config.Tags.Allowed) > 0 {
+			s.tagStats = tags.NewStats(s.config.Tags.MaxValues, s.config.Privacy.Strict)
+			s.tagsConfig = s.config.Tags
+		}
+		// Tailing shows response content to admins, which strict privacy mode rules out
+		if !s.config.Privacy.Strict {
+			s.live = broadcast.NewRegistry()
+		}
+		if s.config.Streams.Resumable {
+			s.streams = resume.NewRegistry(time.Duration(s.config.Streams.RetentionSeconds)*time.Second,
+				func(r *http.Request) string { return usage.TenantFrom(r.Context()) })
+		}
+		if s.config.Audit.Path != "" {
+			if s.audit, err = audit.Open(s.config.Audit.Path, s.config.Audit.Transcripts); err != nil {
+				return fmt.Errorf("failed to open audit journal: %w", err)
+			}
+		}
+		if secret := s.config.Conversations.Secret; secret != "" {
+			s.conversations = conversation.NewMigrator(secret, s.store, s.budget, s.audit,
+				time.Duration(s.config.Limits.Conversation.TTLSeconds)*time.Second)
+		}
+		if caps := s.config.MCP.Capabilities; caps.Required {
+			if s.capabilities, err = capability.NewMinter(caps.Secret, time.Duration(caps.TTLSeconds)*time.Second); err != nil {
+				return fmt.Errorf("failed to create capability minter: %w", err)
+			}
+		}
+		if s.capabilities != nil && s.socketPath != "" && s.admin == nil {
+			slog.Warn("Capability tokens can't be minted over the socket without admin auth, tool calls will be refused")
+		}
+		if len(s.config.MCP.Servers) > 0 {
+			s.mcp = mcp.NewMCPClient(s.config.MCP.Servers)
+		}
+		if len(s.config.MCP.Approvals.Rules) > 0 {
+			if s.approvals, err = approval.NewQueue(&s.config.MCP.Approvals); err != nil {
+				return fmt.Errorf("invalid approval rules: %w", err)
+			}
+			if s.socketPath != "" && s.admin == nil {
+				slog.Warn("Tool calls can't be approved over the socket without admin auth, they will be denied")
 			}
-			s.listener, err = net.Listen("unix", s.socketPath)
+		}
+		if s.config.Usage.Endpoint != "" {
+			s.startUsageExport()
+		}
+		if s.config.Updates.Check {
+			s.startUpdateCheck()
+		}
+		s.sessions = sessions.NewRegistry()
+		s.load = routing.NewLoad()
+		s.restartStats = multiplexer.NewRestartStats()
+		s.events = events.NewNotifier(&s.config.Events)
+		s.journal = journal.New(journal.DefaultSize)
+		// Rebuilt so standby promotions reach the webhook and failures the journal
+		s.mux = multiplexer.New(s.config.Providers, multiplexer.WithRoutes(s.config.Routing.Models),
+			multiplexer.WithStreamRestarts(s.config.Streams.RestartAttempts, s.restartStats),
+			multiplexer.WithStreamSalvage(s.config.Streams.SalvageAttempts),
+			multiplexer.WithNotifier(s.events), multiplexer.WithJournal(s.journal))
+		s.proxy = s.newProxy(s.config, s.mux)
+		s.startMaintenance(s.mux)
+
+		switch {
+		case s.inherited != nil:
+			s.listener = s.inherited
+			slog.Info("Modelplex server listening on inherited listener", "address", s.listener.Addr())
+		case s.socketPath != "":
+			s.listener, err = listenUnix(s.socketPath, &s.config.Server)
 			if err != nil {
-				return fmt.Errorf("failed to listen on socket: %w", err)
+				return err
 			}
 			slog.Info("Modelplex server listening", "socket", s.socketPath)
-		} else {
+		default:
 			s.listener, err = net.Listen("tcp", s.httpAddr)
 			if err != nil {
 				return fmt.Errorf("failed to listen on address: %w", err)
@@ -97,6 +335,7 @@ func (s *Server) Start() <-chan error {
 			slog.Info("Modelplex server listening", "address", s.httpAddr)
 		}
 
+		s.startDependencyWait()
 		close(s.started)
 		return nil
 	}()
@@ -147,14 +386,232 @@ func (s *Server) Stop(ctx context.Context) {
 		slog.Error("Error closing listener", "error", err)
 	}
 
-	// Clean up socket file if using socket
-	if s.socketPath != "" {
+	if s.usageStop != nil {
+		s.usageStop()
+		<-s.usageDone
+	}
+	if s.updatesStop != nil {
+		s.updatesStop()
+		<-s.updatesDone
+	}
+	if s.startupStop != nil {
+		s.startupStop()
+		<-s.startupDone
+	}
+	s.reloadMtx.Lock()
+	s.stopMaintenance()
+	s.reloadMtx.Unlock()
+
+	if s.mcp != nil {
+		s.mcp.Stop()
+	}
+
+	providers.SetFaultInjection(false)
+
+	if s.audit != nil {
+		if err := s.audit.Close(); err != nil {
+			slog.Error("Error closing audit journal", "error", err)
+		}
+	}
+	if s.store != nil {
+		if err := s.store.Close(); err != nil {
+			slog.Error("Error closing state store", "error", err)
+		}
+	}
+
+	// Clean up socket file if using socket; abstract sockets vanish with their listener, and a handed
+	// off socket is still served by the upgraded process
+	if s.socketPath != "" && !isAbstractSocket(s.socketPath) && !s.handedOff {
 		if err := os.Remove(s.socketPath); err != nil && !os.IsNotExist(err) {
 			slog.Error("Error removing socket file", "path", s.socketPath, "error", err)
 		}
 	}
 }
 
+// Reload swaps in a new configuration without dropping the listener or in-flight requests.
+// Providers and routing are rebuilt from it. The rest keeps its startup settings: the listener, state
+// backend, limits, cache, coalescing, admin auth and read-only mode; resumable streams and live stream
+// tailing; judge scoring, injection and loop detection, request tags and the session kill switch; the
+// event webhook and the failure and audit journals; MCP servers, capability tokens and tool call
+// approvals; the update check, the chaos switch, the pause of outbound traffic and startup dependencies.
+// Maintenance mode stays on or off, but takes the new message and Retry-After.
+// Provider health, standby promotions, backend telemetry and the idle times of local models start over.
+func (s *Server) Reload(cfg *config.Config) {
+	config.ApplyDefaults(cfg)
+	catalog.Apply(cfg)
+	muxer := multiplexer.New(cfg.Providers, multiplexer.WithRoutes(cfg.Routing.Models),
+		multiplexer.WithStreamRestarts(cfg.Streams.RestartAttempts, s.restartStats),
+		multiplexer.WithStreamSalvage(cfg.Streams.SalvageAttempts),
+		multiplexer.WithNotifier(s.events), multiplexer.WithJournal(s.journal))
+	pr := s.newProxy(cfg, muxer)
+
+	s.reloadMtx.Lock()
+	defer s.reloadMtx.Unlock()
+
+	s.config = cfg
+	s.mux = muxer
+	s.proxy = pr
+	// Reloading a server that never started leaves maintenance to Start
+	if s.maintenanceStop != nil {
+		s.stopMaintenance()
+		s.startMaintenance(muxer)
+	}
+	slog.Info("Configuration reloaded", "providers", len(cfg.Providers))
+}
+
+// newProxy builds the API proxy for muxer under cfg, wrapping it, innermost first, in usage recording, judge
+// scoring, request coalescing, the response cache, tag counts, conversation budgets, loop detection, routing
+// rules, injection screening, the session kill switch and the audit journal, each when enabled. Usage sits
+// below the others so cache hits and coalesced requests are not billed, while judge requests are; likewise
+// only responses that reached a provider are judged.
+func (s *Server) newProxy(cfg *config.Config, muxer *multiplexer.ModelMultiplexer) *proxy.OpenAIProxy {
+	var m proxy.Multiplexer = muxer
+	if s.usage != nil {
+		m = usage.NewMultiplexer(m, s.usage)
+	}
+	if s.judgeStats != nil {
+		m = judge.NewMultiplexer(m, &s.judgeConfig, s.judgeStats)
+	}
+	if s.coalesceStats != nil {
+		m = coalesce.NewMultiplexer(m, s.coalesceRoutes, s.coalesceStats)
+	}
+	if s.cache != nil {
+		m = cache.NewMultiplexer(m, s.cache)
+	}
+	// Above the cache, so tagged requests count whether or not a provider answered them
+	if s.tagStats != nil {
+		m = tags.NewMultiplexer(m, s.tagStats)
+	}
+	// Above the cache, so every turn of a conversation counts whoever answered it
+	if s.budget != nil {
+		m = budget.NewMultiplexer(m, s.budget)
+	}
+	// Above the budget, so refused repeats don't use up a conversation's turns
+	if s.loops != nil {
+		m = loops.NewMultiplexer(m, s.loops)
+	}
+	// Above caching and coalescing, so they see the model and parameters a rule chose
+	if s.load != nil {
+		m = routing.NewMultiplexer(m, &cfg.Routing, s.load)
+	}
+	// Outside routing, so requests are assessed as the client made them and blocked ones reach no provider
+	if s.injection != nil {
+		m = injection.NewMultiplexer(m, s.injection, s.injectionStats)
+	}
+	// Outside everything but the journal, so the requests of terminated sessions reach nothing; embeddings and
+	// reranking don't go through the multiplexer chain, so they are refused on their own
+	var embedder proxy.Embedder = muxer
+	var reranker proxy.Reranker = muxer
+	if s.sessions != nil {
+		m = sessions.NewMultiplexer(m, s.sessions)
+		embedder = sessions.NewEmbedder(muxer, s.sessions)
+		reranker = sessions.NewReranker(muxer, s.sessions)
+	}
+	// Outermost, so every request is journaled as the client made it, whoever answered it
+	if s.audit != nil {
+		m = audit.NewMultiplexer(m, s.audit)
+	}
+	opts := []proxy.Option{
+		proxy.WithParameterPolicies(&cfg.Parameters), proxy.WithReasoning(&cfg.Reasoning), proxy.WithTags(&s.tagsConfig),
+		proxy.WithStrictOpenAI(cfg.Server.StrictOpenAI), proxy.WithReranker(reranker), proxy.WithEmbedder(embedder),
+		proxy.WithProvenance(&cfg.Provenance),
+	}
+	if !cfg.Catalog.Disabled {
+		opts = append(opts, proxy.WithCatalog(catalog.Builtin(), cfg))
+	}
+	if s.live != nil {
+		opts = append(opts, proxy.WithStreamObserver(func(r *http.Request, model string, stream *broadcast.Stream) {
+			s.live.Add(model, usage.TenantFrom(r.Context()), stream)
+		}))
+	}
+	if s.streams != nil {
+		opts = append(opts, proxy.WithResumableStreams(s.streams))
+	}
+	if cfg.ToolCalls.Assemble || len(cfg.ToolCalls.Tenants) > 0 {
+		opts = append(opts, proxy.WithToolCallAssembly(func(r *http.Request) bool {
+			return cfg.ToolCalls.AssembleFor(usage.TenantFrom(r.Context()))
+		}))
+	}
+	return proxy.New(m, opts...)
+}
+
+// startMaintenance looks after the local backends of muxer until stopMaintenance.
+func (s *Server) startMaintenance(muxer *multiplexer.ModelMultiplexer) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.maintenanceStop = cancel
+	s.maintenanceDone = make(chan struct{})
+	go func() {
+		defer close(s.maintenanceDone)
+		muxer.Maintain(ctx)
+	}()
+}
+
+// stopMaintenance ends the maintenance of local backends, if any, and waits for it.
+func (s *Server) stopMaintenance() {
+	if s.maintenanceStop != nil {
+		s.maintenanceStop()
+		<-s.maintenanceDone
+		s.maintenanceStop = nil
+	}
+}
+
+// startUpdateCheck checks for newer releases until Stop.
+func (s *Server) startUpdateCheck() {
+	s.updates = version.NewChecker(&s.config.Updates, version.Version)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.updatesStop = cancel
+	s.updatesDone = make(chan struct{})
+	go func() {
+		defer close(s.updatesDone)
+		s.updates.Run(ctx)
+	}()
+}
+
+// startDependencyWait waits for the startup dependencies, once listening, until they are up or Stop.
+func (s *Server) startDependencyWait() {
+	s.startup = startup.New(startup.Dependencies(s.config, s.mcp))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.startupStop = cancel
+	s.startupDone = make(chan struct{})
+	go func() {
+		defer close(s.startupDone)
+		s.startup.Run(ctx)
+	}()
+}
+
+// startUsageExport runs the usage exporter until Stop.
+func (s *Server) startUsageExport() {
+	s.usage = usage.NewExporter(&s.config.Usage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.usageStop = cancel
+	s.usageDone = make(chan struct{})
+	go func() {
+		defer close(s.usageDone)
+		s.usage.Run(ctx)
+	}()
+}
+
+func (s *Server) currentConfig() *config.Config {
+	s.reloadMtx.RLock()
+	defer s.reloadMtx.RUnlock()
+	return s.config
+}
+
+func (s *Server) currentMultiplexer() *multiplexer.ModelMultiplexer {
+	s.reloadMtx.RLock()
+	defer s.reloadMtx.RUnlock()
+	return s.mux
+}
+
+func (s *Server) currentProxy() *proxy.OpenAIProxy {
+	s.reloadMtx.RLock()
+	defer s.reloadMtx.RUnlock()
+	return s.proxy
+}
+
 // Addr returns the actual network address the server is listening on.
 // Returns nil if the server is not started or is using a Unix socket.
 func (s *Server) Addr() net.Addr {
@@ -185,33 +642,430 @@ func (s *Server) SocketPath() string {
 }
 
 func (s *Server) setupRoutes(router *mux.Router) {
+	// Registered ahead of the API subrouters so checking the limits doesn't count against them
+	for _, prefix := range []string{"/models/v1", "/v1"} {
+		router.Handle(prefix+"/limits", s.tagTenant(http.HandlerFunc(s.handleLimits))).Methods("GET")
+	}
+
 	// OpenAI-compatible endpoints under /models/v1
 	modelsV1 := router.PathPrefix("/models/v1").Subrouter()
-	modelsV1.HandleFunc("/chat/completions", s.proxy.HandleChatCompletions).Methods("POST")
-	modelsV1.HandleFunc("/completions", s.proxy.HandleCompletions).Methods("POST")
-	modelsV1.HandleFunc("/models", s.proxy.HandleModels).Methods("GET")
+	modelsV1.Use(s.closedForMaintenance, s.limitRequestSize, s.rateLimit, s.tagTenant, s.tagResidency, s.idempotent.Wrap)
+	modelsV1.HandleFunc("/chat/completions", s.handleChatCompletions).Methods("POST")
+	modelsV1.HandleFunc("/completions", s.handleCompletions).Methods("POST")
+	modelsV1.HandleFunc("/models", s.handleModels).Methods("GET")
+	modelsV1.HandleFunc("/rerank", s.handleRerank).Methods("POST")
+	modelsV1.HandleFunc("/embeddings", s.handleEmbeddings).Methods("POST")
+	modelsV1.HandleFunc("/engines", s.handleEngines).Methods("GET")
+	modelsV1.HandleFunc("/engines/{engine}", s.handleEngine).Methods("GET")
+	modelsV1.HandleFunc("/engines/{engine}/completions", s.handleEngineCompletions).Methods("POST")
+	modelsV1.HandleFunc("/estimate", s.handleEstimate).Methods("POST")
+	modelsV1.HandleFunc("/messages/count_tokens", s.handleCountTokens).Methods("POST")
+	modelsV1.HandleFunc("/streams/{token}", s.handleStreamResume).Methods("GET")
 
 	// MCP-style RPC under /mcp/v1
 	mcpV1 := router.PathPrefix("/mcp/v1").Subrouter()
 	mcpV1.HandleFunc("/tools", s.handleMCPTools).Methods("GET")
-	mcpV1.HandleFunc("/tools/{tool}/call", s.handleMCPToolCall).Methods("POST")
+	toolCall := s.requireCapability(s.requireApproval(http.HandlerFunc(s.handleMCPToolCall)))
+	mcpV1.Handle("/tools/{tool}/call", s.denyInReadOnly(toolCall)).Methods("POST")
 
 	// Internal host-only RPC under /_internal (only available on HTTP, not socket)
 	if s.socketPath == "" {
 		internal := router.PathPrefix("/_internal").Subrouter()
+		if s.admin != nil {
+			internal.Use(s.admin.Require(auth.MethodRole))
+		}
+		internal.Use(s.readOnlyAdmin)
 		internal.HandleFunc("/status", s.handleInternalStatus).Methods("GET")
 		internal.HandleFunc("/config", s.handleInternalConfig).Methods("GET")
+		internal.HandleFunc("/version", s.handleInternalVersion).Methods("GET")
 		internal.HandleFunc("/metrics", s.handleInternalMetrics).Methods("GET")
+		internal.HandleFunc("/cache/invalidate", s.handleInternalCacheInvalidate).Methods("POST")
+		internal.HandleFunc("/chaos", s.handleInternalChaos).Methods("GET", "POST")
+		internal.HandleFunc("/pause", s.handleInternalPause).Methods("GET", "POST")
+		internal.HandleFunc("/maintenance", s.handleInternalMaintenance).Methods("GET", "POST")
+		internal.HandleFunc("/streams", s.handleInternalStreams).Methods("GET")
+		internal.HandleFunc("/errors", s.handleInternalErrors).Methods("GET")
+		internal.HandleFunc("/providers/{name}", s.handleInternalProvider).Methods("GET")
+		internal.HandleFunc("/forget", s.handleInternalForget).Methods("POST")
+		internal.HandleFunc("/capabilities", s.handleInternalCapabilities).Methods("POST")
+		internal.HandleFunc("/approvals", s.handleInternalApprovals).Methods("GET")
+		internal.HandleFunc("/approvals/{id}", s.handleInternalApprovalDecision).Methods("POST")
+		internal.HandleFunc("/sessions", s.handleInternalSessions).Methods("GET")
+		internal.HandleFunc("/sessions/{id}/terminate", s.handleInternalSessionTerminate).Methods("POST")
+		internal.HandleFunc("/sessions/{id}/resume", s.handleInternalSessionResume).Methods("POST")
+		// Tails show response content, so viewers only get to list streams
+		tail := http.Handler(http.HandlerFunc(s.handleInternalStreamTail))
+		if s.admin != nil {
+			tail = s.admin.Require(operatorRole)(tail)
+		}
+		internal.Handle("/streams/{id}", tail).Methods("GET")
+		// Exports carry transcripts, so viewers don't get them either
+		export := http.Handler(http.HandlerFunc(s.handleInternalConversationExport))
+		if s.admin != nil {
+			export = s.admin.Require(operatorRole)(export)
+		}
+		internal.Handle("/conversations/{id}/export", export).Methods("GET")
+		internal.HandleFunc("/conversations/import", s.handleInternalConversationImport).Methods("POST")
+
+		// Raw passthrough injects provider credentials, so it is never served without admin auth
+		if s.admin != nil {
+			raw := router.PathPrefix("/providers/{name}/raw/").Subrouter()
+			raw.Use(s.admin.Require(operatorRole), s.readOnlyAdmin)
+			raw.PathPrefix("/").HandlerFunc(s.handleProviderRaw)
+		}
+	}
+
+	// Agents share the socket, so it only mints capability tokens and decides on tool calls for
+	// operators, with admin auth
+	if s.socketPath != "" && s.admin != nil {
+		operator := func(handler http.HandlerFunc) http.Handler {
+			return s.admin.Require(operatorRole)(s.readOnlyAdmin(handler))
+		}
+		router.Handle("/_internal/capabilities", operator(s.handleInternalCapabilities)).Methods("POST")
+		router.Handle("/_internal/approvals", operator(s.handleInternalApprovals)).Methods("GET")
+		router.Handle("/_internal/approvals/{id}", operator(s.handleInternalApprovalDecision)).Methods("POST")
 	}
 
 	// Health check at root level
 	router.HandleFunc("/health", s.handleHealth).Methods("GET")
+	router.HandleFunc("/ready", s.handleReady).Methods("GET")
 
 	// Backward compatibility: Keep old /v1 endpoints for now
 	v1 := router.PathPrefix("/v1").Subrouter()
-	v1.HandleFunc("/chat/completions", s.proxy.HandleChatCompletions).Methods("POST")
-	v1.HandleFunc("/completions", s.proxy.HandleCompletions).Methods("POST")
-	v1.HandleFunc("/models", s.proxy.HandleModels).Methods("GET")
+	v1.Use(s.closedForMaintenance, s.limitRequestSize, s.rateLimit, s.tagTenant, s.tagResidency, s.idempotent.Wrap)
+	v1.HandleFunc("/chat/completions", s.handleChatCompletions).Methods("POST")
+	v1.HandleFunc("/completions", s.handleCompletions).Methods("POST")
+	v1.HandleFunc("/models", s.handleModels).Methods("GET")
+	v1.HandleFunc("/rerank", s.handleRerank).Methods("POST")
+	v1.HandleFunc("/embeddings", s.handleEmbeddings).Methods("POST")
+	v1.HandleFunc("/engines", s.handleEngines).Methods("GET")
+	v1.HandleFunc("/engines/{engine}", s.handleEngine).Methods("GET")
+	v1.HandleFunc("/engines/{engine}/completions", s.handleEngineCompletions).Methods("POST")
+	v1.HandleFunc("/estimate", s.handleEstimate).Methods("POST")
+	v1.HandleFunc("/messages/count_tokens", s.handleCountTokens).Methods("POST")
+	v1.HandleFunc("/streams/{token}", s.handleStreamResume).Methods("GET")
+
+	// Gemini-compatible endpoints under /gemini/v1beta, for tooling written for Google's SDKs
+	geminiV1beta := router.PathPrefix("/gemini/v1beta").Subrouter()
+	geminiV1beta.Use(s.closedForMaintenance, s.limitRequestSize, s.rateLimit, s.tagTenant, s.tagResidency,
+		s.idempotent.Wrap)
+	geminiV1beta.HandleFunc("/models/{model:[^/:]+}:generateContent", s.handleGeminiGenerateContent).Methods("POST")
+	geminiV1beta.HandleFunc("/models/{model:[^/:]+}:streamGenerateContent",
+		s.handleGeminiStreamGenerateContent).Methods("POST")
+
+	s.setupOpenAPI(router)
+}
+
+// setupOpenAPI serves the OpenAPI document of router, and Swagger UI when configured. It runs
+// after every other route is registered, since the document is generated from them.
+func (s *Server) setupOpenAPI(router *mux.Router) {
+	var spec []byte
+	router.HandleFunc("/openapi.json", func(w http.ResponseWriter, _ *http.Request) {
+		if spec == nil {
+			writeJSONError(w, http.StatusInternalServerError, "OpenAPI document unavailable")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if _, err := w.Write(spec); err != nil {
+			slog.Error("Error writing OpenAPI document", "error", err)
+		}
+	}).Methods("GET")
+	if s.config.OpenAPI.SwaggerUI {
+		router.Handle("/docs", openapi.SwaggerUI("/openapi.json")).Methods("GET")
+	}
+
+	doc, err := openapi.Generate(router, version.Version, s.admin != nil)
+	if err == nil {
+		spec, err = json.Marshal(doc)
+	}
+	if err != nil {
+		slog.Error("Failed to generate OpenAPI document", "error", err)
+	}
+}
+
+// rateLimit rejects API requests over the configured limit.
+// Store failures fail open so an unreachable Redis doesn't take down completions.
+func (s *Server) rateLimit(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.limiter == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		allowed, remaining, err := s.limiter.Allow(r.Context(), globalRateLimitKey)
+		if err != nil {
+			slog.Error("Rate limit check failed, allowing request", "error", err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// OpenAI's header names, so SDK backoff logic works unchanged
+		reset := s.limiter.Reset()
+		w.Header().Set("x-ratelimit-limit-requests", strconv.FormatInt(s.limiter.Limit(), 10))
+		w.Header().Set("x-ratelimit-remaining-requests", strconv.FormatInt(remaining, 10))
+		w.Header().Set("x-ratelimit-reset-requests", reset.Round(time.Millisecond).String())
+		if !allowed {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Retry-After", fmt.Sprint(int(math.Ceil(reset.Seconds()))))
+			w.WriteHeader(http.StatusTooManyRequests)
+			message := `{"error":{"message":"Rate limit exceeded","type":"rate_limit_error"}}`
+			if _, err := w.Write([]byte(message)); err != nil {
+				slog.Error("Error writing rate limit response", "error", err)
+			}
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// readOnlyAdmin rejects admin requests that could change state while read-only mode is on.
+func (s *Server) readOnlyAdmin(next http.Handler) http.Handler {
+	guarded := s.denyInReadOnly(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+		default:
+			guarded.ServeHTTP(w, r)
+		}
+	})
+}
+
+// denyInReadOnly rejects every request to next while read-only mode is on.
+func (s *Server) denyInReadOnly(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.readOnly {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusForbidden)
+		message := `{"error":{"message":"modelplex is running in read-only mode","type":"read_only_error"}}`
+		if _, err := w.Write([]byte(message)); err != nil {
+			slog.Error("Error writing read-only response", "error", err)
+		}
+	})
+}
+
+// limitRequestSize rejects API request bodies larger than server.max_request_size.
+func (s *Server) limitRequestSize(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		limit := s.currentConfig().Server.MaxRequestSize
+		if r.ContentLength > limit {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusRequestEntityTooLarge)
+			message := `{"error":{"message":"Request body too large","type":"invalid_request_error"}}`
+			if _, err := w.Write([]byte(message)); err != nil {
+				slog.Error("Error writing request size response", "error", err)
+			}
+			return
+		}
+
+		// Chunked bodies have no length up front, so cap reads as well
+		r.Body = http.MaxBytesReader(w, r.Body, limit)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// API handlers resolve the proxy per request so Reload takes effect immediately
+func (s *Server) handleChatCompletions(w http.ResponseWriter, r *http.Request) {
+	s.currentProxy().HandleChatCompletions(w, r)
+}
+
+func (s *Server) handleCompletions(w http.ResponseWriter, r *http.Request) {
+	s.currentProxy().HandleCompletions(w, r)
+}
+
+func (s *Server) handleModels(w http.ResponseWriter, r *http.Request) {
+	s.currentProxy().HandleModels(w, r)
+}
+
+func (s *Server) handleRerank(w http.ResponseWriter, r *http.Request) {
+	s.currentProxy().HandleRerank(w, r)
+}
+
+func (s *Server) handleEmbeddings(w http.ResponseWriter, r *http.Request) {
+	s.currentProxy().HandleEmbeddings(w, r)
+}
+
+// handleEngines, handleEngine and handleEngineCompletions serve the engines API that models
+// replaced, so legacy tools can be pointed at modelplex unchanged.
+func (s *Server) handleEngines(w http.ResponseWriter, r *http.Request) {
+	s.currentProxy().HandleEngines(w, r)
+}
+
+func (s *Server) handleEngine(w http.ResponseWriter, r *http.Request) {
+	s.currentProxy().HandleEngine(w, r)
+}
+
+func (s *Server) handleEngineCompletions(w http.ResponseWriter, r *http.Request) {
+	s.currentProxy().HandleEngineCompletions(w, r)
+}
+
+func (s *Server) handleGeminiGenerateContent(w http.ResponseWriter, r *http.Request) {
+	gemini.NewHandler(s.currentProxy().Multiplexer(), &s.currentConfig().Parameters).GenerateContent(w, r)
+}
+
+func (s *Server) handleGeminiStreamGenerateContent(w http.ResponseWriter, r *http.Request) {
+	gemini.NewHandler(s.currentProxy().Multiplexer(), &s.currentConfig().Parameters).StreamGenerateContent(w, r)
+}
+
+// handleEstimate answers with the route and maximum cost of a chat completion or completion
+// request without making it.
+func (s *Server) handleEstimate(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	decoder := json.NewDecoder(r.Body)
+	decoder.UseNumber()
+	if err := decoder.Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+
+	estimator := estimate.New(s.currentConfig(), s.currentMultiplexer(), s.load)
+	result, err := estimator.Estimate(r.Context(), body)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(result); err != nil {
+		slog.Error("Error encoding estimate", "error", err)
+	}
+}
+
+// handleCountTokens answers Anthropic's count_tokens request with the input tokens of a Messages
+// request. Anthropic providers count them with their API; for the others they are estimated from
+// the text, and TokenCountHeader tells the two apart.
+func (s *Server) handleCountTokens(w http.ResponseWriter, r *http.Request) {
+	var body map[string]interface{}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeJSONError(w, http.StatusBadRequest, fmt.Sprintf("Invalid JSON: %v", err))
+		return
+	}
+	model, _ := body["model"].(string)
+	if model == "" {
+		writeJSONError(w, http.StatusBadRequest, "model is required")
+		return
+	}
+	if _, ok := body["messages"].([]interface{}); !ok {
+		writeJSONError(w, http.StatusBadRequest, "messages must be an array")
+		return
+	}
+
+	provider, err := s.currentMultiplexer().Resolve(r.Context(), model)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	source := tokenCountEstimated
+	tokens := providers.EstimateRequestTokens(body)
+	if s.providerType(provider.Name()) == "anthropic" {
+		counted, err := providers.CountTokens(r.Context(), provider, model, body)
+		var status *providers.StatusError
+		switch {
+		case errors.As(err, &status) && status.StatusCode < http.StatusInternalServerError:
+			// The request itself is invalid; the client gets Anthropic's explanation
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(status.StatusCode)
+			if _, err := w.Write([]byte(status.Body)); err != nil {
+				slog.Error("Error writing count_tokens response", "error", err)
+			}
+			return
+		case err != nil:
+			slog.Warn("Token count failed, estimating", "provider", provider.Name(), "model", model, "error", err)
+		default:
+			source, tokens = tokenCountProvider, counted
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set(TokenCountHeader, source)
+	if err := json.NewEncoder(w).Encode(map[string]int64{"input_tokens": tokens}); err != nil {
+		slog.Error("Error encoding token count", "error", err)
+	}
+}
+
+// providerType returns the configured type of the named provider.
+func (s *Server) providerType(name string) string {
+	for _, p := range s.currentConfig().Providers {
+		if p.Name == name {
+			return p.Type
+		}
+	}
+	return ""
+}
+
+// handleLimits answers with the headroom left to the caller, so agents can slow down before
+// hitting 429s: the remaining requests of the rate limit window, which every client shares,
+// the free slots of each provider with fair scheduling and, given a conversation_id query
+// parameter, what that conversation has used of its budget.
+func (s *Server) handleLimits(w http.ResponseWriter, r *http.Request) {
+	tenant := usage.TenantFrom(r.Context())
+	limits := map[string]interface{}{"object": "limits", "tenant": tenant}
+	if s.limiter != nil {
+		remaining, reset, err := s.limiter.Remaining(r.Context(), globalRateLimitKey)
+		if err != nil {
+			slog.Error("Rate limit lookup failed", "error", err)
+			writeJSONError(w, http.StatusServiceUnavailable, "Rate limit state unavailable")
+			return
+		}
+		limits["rate_limit"] = map[string]interface{}{
+			"limit":         s.limiter.Limit(),
+			"remaining":     remaining,
+			"reset_seconds": int(math.Ceil(reset.Seconds())),
+		}
+	}
+	if conversation := r.URL.Query().Get("conversation_id"); conversation != "" && s.budget != nil {
+		turns, tokens, err := s.budget.Used(r.Context(), conversation)
+		if err != nil {
+			slog.Error("Conversation budget lookup failed", "error", err)
+			writeJSONError(w, http.StatusServiceUnavailable, "Conversation budget state unavailable")
+			return
+		}
+		maxTurns, maxTokens := s.budget.Limits()
+		limits["conversation"] = map[string]interface{}{
+			"conversation_id": conversation,
+			"max_turns":       maxTurns,
+			"turns":           turns,
+			"max_tokens":      maxTokens,
+			"tokens":          tokens,
+		}
+	}
+	if headroom := s.currentMultiplexer().Headroom(tenant); len(headroom) > 0 {
+		limits["concurrency"] = headroom
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(limits); err != nil {
+		slog.Error("Error encoding limits", "error", err)
+	}
+}
+
+func (s *Server) handleStreamResume(w http.ResponseWriter, r *http.Request) {
+	s.currentProxy().HandleStreamResume(w, r)
+}
+
+// tagTenant attaches the tenant named by the configured header to the request context for usage billing.
+func (s *Server) tagTenant(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if tenant := r.Header.Get(s.currentConfig().Usage.TenantHeader); tenant != "" {
+			r = r.WithContext(usage.WithTenant(r.Context(), tenant))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// tagResidency restricts routing to the jurisdictions allowed for the request's tenant.
+func (s *Server) tagResidency(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		jurisdictions := s.currentConfig().Residency.Jurisdictions(usage.TenantFrom(r.Context()))
+		if len(jurisdictions) > 0 {
+			r = r.WithContext(multiplexer.WithResidency(r.Context(), jurisdictions))
+		}
+		next.ServeHTTP(w, r)
+	})
 }
 
 func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
@@ -222,35 +1076,200 @@ func (s *Server) handleHealth(w http.ResponseWriter, _ *http.Request) {
 	}
 }
 
+// handleReady reports whether the startup dependencies are up, answering 503 until they are, with the
+// state of each and why one failed.
+func (s *Server) handleReady(w http.ResponseWriter, _ *http.Request) {
+	readiness := s.startup.Readiness()
+	w.Header().Set("Content-Type", "application/json")
+	if readiness.Status != startup.StatusReady {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(readiness); err != nil {
+		slog.Error("Error writing readiness response", "error", err)
+	}
+}
+
 // MCP endpoint handlers
 func (s *Server) handleMCPTools(w http.ResponseWriter, _ *http.Request) {
+	tools := []mcp.Tool{}
+	if s.mcp != nil {
+		tools = append(tools, s.mcp.ListTools()...)
+	}
 	w.Header().Set("Content-Type", "application/json")
-	// TODO: Implement MCP tools listing
-	w.WriteHeader(http.StatusOK)
-	if _, err := w.Write([]byte(`{"tools":[],"message":"MCP tools endpoint - implementation pending"}`)); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"tools": tools}); err != nil {
 		slog.Error("Error writing MCP tools response", "error", err)
 	}
 }
 
-func (s *Server) handleMCPToolCall(w http.ResponseWriter, _ *http.Request) {
+// handleMCPToolCall calls a tool of the MCP servers with the call's arguments and answers with its result.
+func (s *Server) handleMCPToolCall(w http.ResponseWriter, r *http.Request) {
+	call, ok := readToolCall(w, r)
+	if !ok {
+		return
+	}
+	tool := mux.Vars(r)["tool"]
+	if s.mcp == nil {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("%s: %s", mcp.ErrToolNotFound, tool))
+		return
+	}
+
+	result, err := s.mcp.CallTool(r.Context(), tool, call.Arguments)
+	var toolErr *mcp.ToolError
+	switch {
+	case errors.Is(err, mcp.ErrToolNotFound):
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	case errors.As(err, &toolErr):
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	case r.Context().Err() != nil:
+		return
+	case err != nil:
+		slog.Error("MCP tool call failed", "tool", tool, "error", err)
+		writeJSONError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	// TODO: Implement MCP tool calling
-	w.WriteHeader(http.StatusOK)
-	message := `{"result":null,"message":"MCP tool call endpoint - implementation pending"}`
-	if _, err := w.Write([]byte(message)); err != nil {
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"result": result}); err != nil {
 		slog.Error("Error writing MCP tool call response", "error", err)
 	}
 }
 
+// maxToolCallSize bounds the tool call bodies read to check their arguments against a capability token
+// or the approval rules
+const maxToolCallSize = 1 << 20
+
+// requireCapability refuses tool calls unless they present a capability token permitting the tool
+// and its arguments in the conversation the call names in its metadata, when tokens are required.
+// The body is restored for the handler.
+func (s *Server) requireCapability(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.capabilities == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token := r.Header.Get(capability.Header)
+		if token == "" {
+			writeJSONError(w, http.StatusUnauthorized, "a capability token is required in "+capability.Header)
+			return
+		}
+		call, ok := readToolCall(w, r)
+		if !ok {
+			return
+		}
+		tool := mux.Vars(r)["tool"]
+		conversation := call.Metadata[erasure.ConversationKey]
+		grant, err := s.capabilities.Authorize(token, conversation, tool, call.Arguments)
+		var denied *capability.DeniedError
+		if errors.As(err, &denied) {
+			slog.Warn("Denied tool call outside its capability token", "tool", tool,
+				"conversation", conversation, "granted_conversation", grant.Conversation, "error", err)
+			writeJSONError(w, http.StatusForbidden, err.Error())
+			return
+		}
+		if err != nil {
+			writeJSONError(w, http.StatusUnauthorized, err.Error())
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// requireApproval holds the tool calls the approval rules select until an operator approves or
+// denies them, or the approval timeout passes; approved calls go on to next. The body is restored
+// for the handler, and the write deadline is extended by the approval timeout.
+func (s *Server) requireApproval(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.approvals == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, ok := readToolCall(w, r)
+		if !ok {
+			return
+		}
+		args := body.Arguments
+		tool := mux.Vars(r)["tool"]
+		if !s.approvals.Requires(tool, args) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		// The wait can outlast the write timeout, so the response gets it on top
+		deadline := time.Now().Add(s.approvals.Timeout() + writeTimeout)
+		if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil {
+			slog.Warn("Failed to extend the write deadline for a tool call approval", "tool", tool, "error", err)
+		}
+		call, wait := s.approvals.Park(tool, args)
+		s.events.Emit(events.TypeToolCallPending, tool, call)
+		decision, err := wait(r.Context())
+		if err != nil {
+			slog.Info("Tool call abandoned while waiting for approval", "id", call.ID, "tool", tool)
+			return
+		}
+		if !decision.Approved {
+			slog.Warn("Denied tool call", "id", call.ID, "tool", tool, "reason", decision.Reason)
+			message := "tool call denied"
+			if decision.Reason != "" {
+				message += ": " + decision.Reason
+			}
+			writeJSONError(w, http.StatusForbidden, message)
+			return
+		}
+		slog.Info("Approved tool call", "id", call.ID, "tool", tool)
+		next.ServeHTTP(w, r)
+	})
+}
+
+// toolCall is the body of a tool call: the tool's arguments, and metadata naming the conversation
+// making the call as chat requests do.
+type toolCall struct {
+	Arguments map[string]interface{} `json:"arguments"`
+	Metadata  map[string]string      `json:"metadata"`
+}
+
+// readToolCall reads a tool call and restores its body. Unreadable calls are answered with an
+// error and reported as not ok.
+func readToolCall(w http.ResponseWriter, r *http.Request) (*toolCall, bool) {
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxToolCallSize))
+	if err != nil {
+		writeJSONError(w, http.StatusRequestEntityTooLarge, "tool call body too large")
+		return nil, false
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	var call toolCall
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &call); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return nil, false
+		}
+	}
+	return &call, true
+}
+
 // Internal endpoint handlers (only available on HTTP, not socket)
 func (s *Server) handleInternalStatus(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	cfg := s.currentConfig()
 	status := map[string]interface{}{
 		"service":     "modelplex",
 		"status":      "running",
 		"mode":        "http",
-		"providers":   len(s.config.Providers),
-		"mcp_servers": len(s.config.MCP.Servers),
+		"read_only":   s.readOnly,
+		"privacy":     privacyMode(cfg),
+		"providers":   len(cfg.Providers),
+		"mcp_servers": len(cfg.MCP.Servers),
+		"paused":      providers.TrafficState().Paused,
+		"maintenance": s.maintenanceMode.state(&cfg.Maintenance).Enabled,
+		"ready":       s.startup.Readiness().Status,
+	}
+	if resources := s.currentMultiplexer().Resources(); len(resources) > 0 {
+		status["backends"] = resources
+	}
+	if deprecations := providers.Deprecations(); len(deprecations) > 0 {
+		status["deprecations"] = deprecations
 	}
 
 	// Add address information
@@ -264,24 +1283,30 @@ func (s *Server) handleInternalStatus(w http.ResponseWriter, _ *http.Request) {
 
 func (s *Server) handleInternalConfig(w http.ResponseWriter, _ *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
+	cfg := s.currentConfig()
 	// Return sanitized config (without API keys)
 	sanitizedConfig := map[string]interface{}{
-		"server": s.config.Server,
+		"server": cfg.Server,
 		"providers": func() []map[string]interface{} {
 			var providers []map[string]interface{}
-			for _, p := range s.config.Providers {
+			for _, p := range cfg.Providers {
 				providers = append(providers, map[string]interface{}{
-					"name":     p.Name,
-					"type":     p.Type,
-					"base_url": p.BaseURL,
-					"models":   p.Models,
-					"priority": p.Priority,
-					// Exclude API key for security
+					"name":         p.Name,
+					"type":         p.Type,
+					"base_url":     p.BaseURL,
+					"regions":      p.Regions,
+					"jurisdiction": p.Jurisdiction,
+					"models":       p.Models,
+					"priority":     p.Priority,
+					"auth":         p.Auth.Type,
+					// Exclude API key and client secret for security
 				})
 			}
 			return providers
 		}(),
-		"mcp": s.config.MCP,
+		"routes": cfg.Routing.Models,
+		// Redacted, as the capability secret mints tokens for any tool
+		"mcp": config.Redact(cfg).MCP,
 	}
 	if err := json.NewEncoder(w).Encode(sanitizedConfig); err != nil {
 		slog.Error("Error writing internal config response", "error", err)
@@ -299,8 +1324,599 @@ func (s *Server) handleInternalMetrics(w http.ResponseWriter, _ *http.Request) {
 		"uptime_seconds":   0,
 		"message":          "Metrics collection - implementation pending",
 	}
+	if s.coalesceStats != nil {
+		metrics["coalescing"] = s.coalesceStats.Snapshot()
+	}
+	if s.judgeStats != nil {
+		metrics["judge_scores"] = s.judgeStats.Snapshot()
+	}
+	if s.injectionStats != nil {
+		metrics["injection"] = s.injectionStats.Snapshot()
+	}
+	if s.tagStats != nil {
+		metrics["tags"] = s.tagStats.Snapshot()
+	}
+	if s.load != nil {
+		metrics["in_flight"] = s.load.Snapshot()
+	}
+	if s.restartStats != nil {
+		metrics["stream_restarts"] = s.restartStats.Snapshot()
+	}
+	if s.audit != nil {
+		metrics["audit"] = s.audit.Status()
+	}
+	if resources := s.currentMultiplexer().Resources(); len(resources) > 0 {
+		backends := make(map[string]interface{}, len(resources))
+		for _, r := range resources {
+			backends[r.Provider] = map[string]interface{}{
+				"loaded_models":    len(r.Models),
+				"vram_used_bytes":  r.VRAMUsed,
+				"vram_total_bytes": r.VRAMTotal,
+				"offloaded":        r.Offloaded,
+				"spilling":         r.Spilling,
+			}
+		}
+		metrics["backends"] = backends
+	}
+	upstreamHeaders := make(map[string]interface{})
+	queueTimes := make(map[string]interface{})
+	for _, p := range s.currentConfig().Providers {
+		if captured := providers.CapturedHeaders(p.Name); len(captured) > 0 {
+			values := make(map[string]string, len(captured))
+			for name, header := range captured {
+				values[name] = header.Value
+			}
+			upstreamHeaders[p.Name] = values
+		}
+		if queued := providers.QueueTimes(p.Name); queued != nil {
+			queueTimes[p.Name] = queued
+		}
+	}
+	if len(upstreamHeaders) > 0 {
+		metrics["upstream_headers"] = upstreamHeaders
+	}
+	if len(queueTimes) > 0 {
+		metrics["queue_times"] = queueTimes
+	}
 	if err := json.NewEncoder(w).Encode(metrics); err != nil {
 		slog.Error("Error writing internal metrics response", "error", err)
 		http.Error(w, "Internal server error", http.StatusInternalServerError)
 	}
 }
+
+// handleInternalStreams lists the streaming generations in progress.
+func (s *Server) handleInternalStreams(w http.ResponseWriter, _ *http.Request) {
+	if s.live == nil {
+		writeJSONError(w, http.StatusNotFound, "live streams are not available in strict privacy mode")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"streams": s.live.List()}); err != nil {
+		slog.Error("Error writing internal streams response", "error", err)
+	}
+}
+
+// handleInternalVersion describes the running build, and the latest release once the update check found it.
+func (s *Server) handleInternalVersion(w http.ResponseWriter, _ *http.Request) {
+	response := struct {
+		version.Info
+		LatestRelease string `json:"latest_release,omitempty"`
+	}{Info: version.Current(), LatestRelease: s.updates.Latest()}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Error writing internal version response", "error", err)
+	}
+}
+
+// handleInternalErrors lists recent provider failures, oldest first. The provider parameter
+// narrows them to one provider, and since to those recorded after an RFC 3339 time, or within
+// a duration such as 15m.
+func (s *Server) handleInternalErrors(w http.ResponseWriter, r *http.Request) {
+	var since time.Time
+	if value := r.URL.Query().Get("since"); value != "" {
+		if ago, err := time.ParseDuration(value); err == nil {
+			since = time.Now().Add(-ago)
+		} else if since, err = time.Parse(time.RFC3339, value); err != nil {
+			writeJSONError(w, http.StatusBadRequest,
+				fmt.Sprintf("invalid since %q: expected an RFC 3339 time or a duration", value))
+			return
+		}
+	}
+
+	entries := s.journal.Query(r.URL.Query().Get("provider"), since)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"errors": entries}); err != nil {
+		slog.Error("Error writing internal errors response", "error", err)
+	}
+}
+
+// handleInternalForget purges the stored data of a data subject, selected by conversation id,
+// tenant or metadata pairs, from the audit journal's transcripts, the response cache, the pending
+// usage events, imported conversation history, recorded idempotent responses and resumable streams,
+// and reports what was deleted from each.
+// Subsystems that aren't configured are reported as not enabled; one that fails doesn't stop the
+// others.
+func (s *Server) handleInternalForget(w http.ResponseWriter, r *http.Request) {
+	var subject erasure.Subject
+	if err := json.NewDecoder(r.Body).Decode(&subject); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	if subject.Empty() {
+		writeJSONError(w, http.StatusBadRequest, "conversation_id, tenant or metadata is required")
+		return
+	}
+
+	report := map[string]erasure.Result{
+		"audit": {Enabled: s.audit != nil},
+		"cache": {Enabled: s.cache != nil},
+		"usage": {Enabled: s.usage != nil},
+		// Imported history is looked up by conversation id only
+		"conversations": {Enabled: s.conversations != nil},
+		"idempotency":   {Enabled: s.idempotent != nil},
+		"streams":       {Enabled: s.streams != nil},
+	}
+	if s.audit != nil {
+		deleted, err := s.audit.Erase(&subject)
+		report["audit"] = forgotten(deleted, err)
+	}
+	if s.cache != nil {
+		deleted, err := s.cache.Forget(r.Context(), &subject)
+		report["cache"] = forgotten(deleted, err)
+	}
+	if s.usage != nil {
+		report["usage"] = forgotten(s.usage.Forget(&subject), nil)
+	}
+	if s.conversations != nil {
+		deleted, err := s.conversations.Forget(r.Context(), &subject)
+		report["conversations"] = forgotten(deleted, err)
+	}
+	if s.idempotent != nil {
+		deleted, err := s.idempotent.Forget(r.Context(), &subject)
+		report["idempotency"] = forgotten(deleted, err)
+	}
+	if s.streams != nil {
+		report["streams"] = forgotten(s.streams.Forget(&subject), nil)
+	}
+
+	slog.Info("Forgot data subject", "audit", report["audit"].Deleted, "cache", report["cache"].Deleted,
+		"usage", report["usage"].Deleted, "conversations", report["conversations"].Deleted,
+		"idempotency", report["idempotency"].Deleted, "streams", report["streams"].Deleted)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"deleted": report}); err != nil {
+		slog.Error("Error writing forget response", "error", err)
+	}
+}
+
+// handleInternalConversationExport exports the stored state of a conversation as a signed blob
+// another instance imports.
+func (s *Server) handleInternalConversationExport(w http.ResponseWriter, r *http.Request) {
+	if s.conversations == nil {
+		writeJSONError(w, http.StatusNotFound, "conversation export is not enabled, set conversations.secret")
+		return
+	}
+	id := mux.Vars(r)["id"]
+	export, exported, err := s.conversations.Export(r.Context(), id)
+	if err != nil {
+		slog.Error("Conversation export failed", "conversation_id", id, "error", err)
+		writeJSONError(w, http.StatusServiceUnavailable, "Conversation state unavailable")
+		return
+	}
+
+	slog.Info("Exported conversation", "conversation_id", id, "records", len(exported.History))
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"conversation_id": id,
+		"exported_at":     exported.ExportedAt,
+		"budget":          exported.Budget,
+		"records":         len(exported.History),
+		"export":          export,
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Error writing conversation export response", "error", err)
+	}
+}
+
+// handleInternalConversationImport restores the state of a conversation exported by another
+// instance: its budget counts and history.
+func (s *Server) handleInternalConversationImport(w http.ResponseWriter, r *http.Request) {
+	if s.conversations == nil {
+		writeJSONError(w, http.StatusNotFound, "conversation import is not enabled, set conversations.secret")
+		return
+	}
+	var req struct {
+		Export string `json:"export"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Export == "" {
+		writeJSONError(w, http.StatusBadRequest, "export is required")
+		return
+	}
+	imported, err := s.conversations.Import(r.Context(), req.Export)
+	if errors.Is(err, conversation.ErrInvalid) {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		slog.Error("Conversation import failed", "error", err)
+		writeJSONError(w, http.StatusServiceUnavailable, "Conversation state unavailable")
+		return
+	}
+
+	slog.Info("Imported conversation", "conversation_id", imported.ConversationID,
+		"exported_at", imported.ExportedAt, "records", len(imported.History))
+	w.Header().Set("Content-Type", "application/json")
+	response := map[string]interface{}{
+		"conversation_id": imported.ConversationID,
+		"exported_at":     imported.ExportedAt,
+		"budget":          imported.Budget,
+		"records":         len(imported.History),
+	}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Error writing conversation import response", "error", err)
+	}
+}
+
+// handleInternalCapabilities mints a capability token for a conversation's tool calls.
+func (s *Server) handleInternalCapabilities(w http.ResponseWriter, r *http.Request) {
+	if s.capabilities == nil {
+		writeJSONError(w, http.StatusNotFound, "capability tokens are not enabled, set mcp.capabilities.required")
+		return
+	}
+	var req struct {
+		capability.Grant
+		TTLSeconds int64 `json:"ttl_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	token, grant, err := s.capabilities.Mint(req.Grant, time.Duration(req.TTLSeconds)*time.Second)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slog.Info("Minted capability token", "conversation", grant.Conversation, "tools", len(grant.Tools),
+		"expires_at", grant.Expires)
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		Token string `json:"token"`
+		capability.Grant
+	}{token, grant}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Error writing capability response", "error", err)
+	}
+}
+
+// handleInternalApprovals lists the tool calls waiting for approval, oldest first.
+func (s *Server) handleInternalApprovals(w http.ResponseWriter, _ *http.Request) {
+	if s.approvals == nil {
+		writeJSONError(w, http.StatusNotFound, "tool call approvals are not enabled, set mcp.approvals.rules")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"approvals": s.approvals.Pending()}); err != nil {
+		slog.Error("Error writing approvals response", "error", err)
+	}
+}
+
+// handleInternalApprovalDecision approves or denies a waiting tool call, which then goes on to the
+// tool or is refused with the reason given.
+func (s *Server) handleInternalApprovalDecision(w http.ResponseWriter, r *http.Request) {
+	if s.approvals == nil {
+		writeJSONError(w, http.StatusNotFound, "tool call approvals are not enabled, set mcp.approvals.rules")
+		return
+	}
+	var decision approval.Decision
+	if err := json.NewDecoder(r.Body).Decode(&decision); err != nil {
+		writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+		return
+	}
+	call, err := s.approvals.Decide(mux.Vars(r)["id"], decision)
+	if err != nil {
+		writeJSONError(w, http.StatusNotFound, err.Error())
+		return
+	}
+
+	slog.Info("Decided on tool call", "id", call.ID, "tool", call.Tool, "approved", decision.Approved)
+	w.Header().Set("Content-Type", "application/json")
+	response := struct {
+		approval.Call
+		approval.Decision
+	}{call, decision}
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Error writing approval decision response", "error", err)
+	}
+}
+
+// sessionRequest is the optional body of the session termination and resume endpoints.
+type sessionRequest struct {
+	// Scope is what the id names, "conversation", the default, or "tenant"
+	Scope string `json:"scope"`
+	// Reason explains a termination to the session's clients
+	Reason string `json:"reason"`
+}
+
+// decodeSessionRequest decodes the optional body of r, answering it when the body is invalid.
+func decodeSessionRequest(w http.ResponseWriter, r *http.Request) (sessionRequest, bool) {
+	req := sessionRequest{Scope: sessions.ScopeConversation}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeJSONError(w, http.StatusBadRequest, "invalid JSON body")
+			return req, false
+		}
+	}
+	if req.Scope == "" {
+		req.Scope = sessions.ScopeConversation
+	}
+	return req, true
+}
+
+// handleInternalSessions lists the terminated sessions.
+func (s *Server) handleInternalSessions(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"terminated": s.sessions.Terminated()}); err != nil {
+		slog.Error("Error writing sessions response", "error", err)
+	}
+}
+
+// handleInternalSessionTerminate is the emergency stop for a misbehaving agent: it cancels the
+// requests in flight of a conversation, or of a tenant, and refuses its later ones until resumed.
+func (s *Server) handleInternalSessionTerminate(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeSessionRequest(w, r)
+	if !ok {
+		return
+	}
+	termination, err := s.sessions.Terminate(req.Scope, mux.Vars(r)["id"], req.Reason)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	slog.Warn("Session terminated", "scope", termination.Scope, "id", termination.ID,
+		"cancelled", termination.Cancelled)
+	s.events.Emit(events.TypeSessionTerminated, termination.ID, termination)
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(termination); err != nil {
+		slog.Error("Error writing session termination response", "error", err)
+	}
+}
+
+// handleInternalSessionResume lets a terminated session make requests again.
+func (s *Server) handleInternalSessionResume(w http.ResponseWriter, r *http.Request) {
+	req, ok := decodeSessionRequest(w, r)
+	if !ok {
+		return
+	}
+	id := mux.Vars(r)["id"]
+	resumed, err := s.sessions.Resume(req.Scope, id)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !resumed {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("%s %q is not terminated", req.Scope, id))
+		return
+	}
+
+	slog.Info("Session resumed", "scope", req.Scope, "id", id)
+	s.events.Emit(events.TypeSessionResumed, id, map[string]string{"scope": req.Scope, "id": id})
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]string{"scope": req.Scope, "id": id}); err != nil {
+		slog.Error("Error writing session resume response", "error", err)
+	}
+}
+
+// forgotten reports the result of purging one subsystem.
+func forgotten(deleted int, err error) erasure.Result {
+	result := erasure.Result{Enabled: true, Deleted: deleted}
+	if err != nil {
+		slog.Error("Failed to forget data subject", "error", err)
+		result.Error = err.Error()
+	}
+	return result
+}
+
+// handleInternalProvider describes a configured provider with the response headers it captured
+// last, such as its rate limit state, and the deprecations it announced for its models.
+func (s *Server) handleInternalProvider(w http.ResponseWriter, r *http.Request) {
+	name, cfg := mux.Vars(r)["name"], s.currentConfig()
+	index := slices.IndexFunc(cfg.Providers, func(p config.Provider) bool { return p.Name == name })
+	if index < 0 {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("unknown provider %q", name))
+		return
+	}
+	p := cfg.Providers[index]
+	headers := providers.CapturedHeaders(name)
+	if headers == nil {
+		headers = map[string]providers.CapturedHeader{}
+	}
+	deprecations := slices.DeleteFunc(providers.Deprecations(), func(d providers.Deprecation) bool {
+		return d.Provider != name
+	})
+	response := map[string]interface{}{
+		"name":            p.Name,
+		"type":            p.Type,
+		"models":          p.Models,
+		"capture_headers": p.CaptureHeaders,
+		"headers":         headers,
+		"deprecations":    deprecations,
+	}
+	if queued := providers.QueueTimes(name); queued != nil {
+		response["queue_time"] = queued
+	}
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(response); err != nil {
+		slog.Error("Error writing internal provider response", "error", err)
+	}
+}
+
+// handleInternalStreamTail follows a generation in progress from its first delta, alongside its client.
+func (s *Server) handleInternalStreamTail(w http.ResponseWriter, r *http.Request) {
+	if s.live == nil {
+		writeJSONError(w, http.StatusNotFound, "live streams are not available in strict privacy mode")
+		return
+	}
+	stream, ok := s.live.Get(mux.Vars(r)["id"])
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, "unknown or finished stream")
+		return
+	}
+	s.currentProxy().WriteEventStream(w, r, stream, 0, "stream tail")
+}
+
+// handleInternalCacheInvalidate drops cached responses for one model, or all models without a body.
+func (s *Server) handleInternalCacheInvalidate(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if s.cache == nil {
+		w.WriteHeader(http.StatusNotFound)
+		if _, err := w.Write([]byte(`{"error":"response cache is not enabled"}`)); err != nil {
+			slog.Error("Error writing cache invalidate response", "error", err)
+		}
+		return
+	}
+
+	var req struct {
+		Model string `json:"model"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			if _, err := w.Write([]byte(`{"error":"invalid JSON body"}`)); err != nil {
+				slog.Error("Error writing cache invalidate response", "error", err)
+			}
+			return
+		}
+	}
+
+	if err := s.cache.Invalidate(r.Context(), req.Model); err != nil {
+		slog.Error("Cache invalidation failed", "model", req.Model, "error", err)
+		http.Error(w, "Internal server error", http.StatusInternalServerError)
+		return
+	}
+
+	scope := req.Model
+	if scope == "" {
+		scope = "all"
+	}
+	slog.Info("Response cache invalidated", "scope", scope)
+	if err := json.NewEncoder(w).Encode(map[string]interface{}{"invalidated": scope}); err != nil {
+		slog.Error("Error writing cache invalidate response", "error", err)
+	}
+}
+
+// handleInternalChaos reports fault injection state, and toggles it on POST with {"enabled": bool}.
+func (s *Server) handleInternalChaos(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		var req struct {
+			Enabled *bool `json:"enabled"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Enabled == nil {
+			writeJSONError(w, http.StatusBadRequest, `expected a JSON body with "enabled"`)
+			return
+		}
+		providers.SetFaultInjection(*req.Enabled)
+		slog.Warn("Chaos mode toggled", "enabled", *req.Enabled)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(map[string]bool{"enabled": providers.FaultInjectionEnabled()}); err != nil {
+		slog.Error("Error writing chaos response", "error", err)
+	}
+}
+
+// handleInternalPause reports whether outbound traffic is paused, and pauses or resumes it on POST
+// with {"paused": bool}. Inbound requests are still accepted while paused, their provider calls
+// queued or refused as pause.inbound says.
+func (s *Server) handleInternalPause(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		var req struct {
+			Paused *bool `json:"paused"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.Paused == nil {
+			writeJSONError(w, http.StatusBadRequest, `expected a JSON body with "paused"`)
+			return
+		}
+		if *req.Paused {
+			if cancelled, paused := providers.PauseTraffic(); paused {
+				slog.Warn("Outbound traffic paused", "cancelled", cancelled)
+				s.events.Emit(events.TypeTrafficPaused, "outbound", map[string]int{"cancelled": cancelled})
+			}
+		} else if providers.ResumeTraffic() {
+			slog.Info("Outbound traffic resumed")
+			s.events.Emit(events.TypeTrafficResumed, "outbound", nil)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(providers.TrafficState()); err != nil {
+		slog.Error("Error writing pause response", "error", err)
+	}
+}
+
+func privacyMode(cfg *config.Config) string {
+	if cfg.Privacy.Strict {
+		return "strict"
+	}
+	return "standard"
+}
+
+// operatorRole requires the operator role for every method; raw requests can spend money
+// and stream tails show response content even when they are GETs.
+func operatorRole(*http.Request) auth.Role {
+	return auth.RoleOperator
+}
+
+// handleProviderRaw forwards /providers/{name}/raw/<path> to <path> below the provider's base URL.
+func (s *Server) handleProviderRaw(w http.ResponseWriter, r *http.Request) {
+	name := mux.Vars(r)["name"]
+	provider, ok := s.currentMultiplexer().Provider(name)
+	if !ok {
+		writeJSONError(w, http.StatusNotFound, fmt.Sprintf("unknown provider %q", name))
+		return
+	}
+	forwarder, ok := provider.(providers.RawForwarder)
+	if !ok {
+		writeJSONError(w, http.StatusNotImplemented, fmt.Sprintf("provider %q does not support raw requests", name))
+		return
+	}
+
+	path := strings.TrimPrefix(r.URL.Path, "/providers/"+name+"/raw")
+	slog.Info("Forwarding raw provider request", "provider", name, "method", r.Method, "path", path)
+
+	rp := &httputil.ReverseProxy{
+		Rewrite: func(pr *httputil.ProxyRequest) {
+			// The caller's admin credentials must never reach the provider
+			pr.Out.Header.Del("Authorization")
+			pr.Out.Header.Del("Cookie")
+		},
+		Transport: roundTripperFunc(func(req *http.Request) (*http.Response, error) {
+			return forwarder.Forward(req, path)
+		}),
+		// Flush immediately so streamed upstream responses stay streamed
+		FlushInterval: -1,
+		ErrorHandler: func(w http.ResponseWriter, _ *http.Request, err error) {
+			slog.Error("Raw provider request failed", "provider", name, "error", err)
+			writeJSONError(w, http.StatusBadGateway, "upstream request failed")
+		},
+	}
+	rp.ServeHTTP(w, r)
+}
+
+// roundTripperFunc adapts a function to http.RoundTripper.
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip implements http.RoundTripper.
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// writeJSONError writes an error response in the shape used by the admin endpoints.
+func writeJSONError(w http.ResponseWriter, status int, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(map[string]string{"error": message}); err != nil {
+		slog.Error("Error writing error response", "error", err)
+	}
+}
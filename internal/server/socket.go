@@ -0,0 +1,114 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+// listenUnix listens on the Unix socket at path with the mode and ownership of cfg. The socket is
+// removed again when they can't be applied, so it is never left more exposed than configured.
+// A path starting with @ names a socket in the Linux abstract namespace, which has no file.
+func listenUnix(path string, cfg *config.Server) (net.Listener, error) {
+	if isAbstractSocket(path) {
+		return listenAbstract(path, cfg)
+	}
+	// Check if socket already exists and error if it does
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("socket file already exists: %s", path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on socket: %w", err)
+	}
+	if err := applySocketPermissions(path, cfg); err != nil {
+		// Closing a Unix listener removes its socket file
+		return nil, errors.Join(err, listener.Close())
+	}
+	return listener, nil
+}
+
+// listenAbstract listens on the abstract socket named by path. Abstract sockets have no mode or
+// owner: any process in the same network namespace can connect, which containers scope.
+func listenAbstract(path string, cfg *config.Server) (net.Listener, error) {
+	if runtime.GOOS != "linux" {
+		return nil, fmt.Errorf("abstract socket %s is not supported on %s", path, runtime.GOOS)
+	}
+	if cfg.SocketMode != "" || cfg.SocketOwner != "" || cfg.SocketGroup != "" {
+		return nil, fmt.Errorf("abstract socket %s has no permissions, unset socket_mode, socket_owner and socket_group",
+			path)
+	}
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on socket: %w", err)
+	}
+	return listener, nil
+}
+
+// isAbstractSocket reports whether path names a socket in the abstract namespace.
+func isAbstractSocket(path string) bool {
+	return strings.HasPrefix(path, "@")
+}
+
+// applySocketPermissions sets the configured mode, owner and group of the socket at path.
+func applySocketPermissions(path string, cfg *config.Server) error {
+	if cfg.SocketMode != "" {
+		// Validation guarantees the mode parses
+		mode, _ := config.ParseFileMode(cfg.SocketMode)
+		if err := os.Chmod(path, mode); err != nil {
+			return fmt.Errorf("failed to set socket mode: %w", err)
+		}
+	}
+	if cfg.SocketOwner == "" && cfg.SocketGroup == "" {
+		return nil
+	}
+
+	// -1 leaves the owner or group unchanged
+	uid, gid := -1, -1
+	var err error
+	if cfg.SocketOwner != "" {
+		if uid, err = lookupID(cfg.SocketOwner, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		}); err != nil {
+			return fmt.Errorf("unknown socket owner %q: %w", cfg.SocketOwner, err)
+		}
+	}
+	if cfg.SocketGroup != "" {
+		if gid, err = lookupID(cfg.SocketGroup, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		}); err != nil {
+			return fmt.Errorf("unknown socket group %q: %w", cfg.SocketGroup, err)
+		}
+	}
+	if err := os.Chown(path, uid, gid); err != nil {
+		return fmt.Errorf("failed to set socket ownership: %w", err)
+	}
+	return nil
+}
+
+// lookupID returns the numeric id named by value, which is either the id itself or a name lookup resolves.
+func lookupID(value string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(value); err == nil {
+		return id, nil
+	}
+	id, err := lookup(value)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(id)
+}
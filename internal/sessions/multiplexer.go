@@ -0,0 +1,156 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+)
+
+// Multiplexer wraps a multiplexer and refuses, or cancels, the requests of terminated sessions.
+type Multiplexer struct {
+	proxy.Multiplexer
+	registry *Registry
+}
+
+// NewMultiplexer wraps mux so requests are tracked by registry while they are served.
+func NewMultiplexer(mux proxy.Multiplexer, registry *Registry) *Multiplexer {
+	return &Multiplexer{Multiplexer: mux, registry: registry}
+}
+
+// ChatCompletion forwards the request unless its session is terminated.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	ctx, done, err := m.registry.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	result, err := m.Multiplexer.ChatCompletion(ctx, model, messages)
+	return result, terminated(ctx, err)
+}
+
+// Completion forwards the request unless its session is terminated.
+func (m *Multiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	ctx, done, err := m.registry.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	result, err := m.Multiplexer.Completion(ctx, model, prompt)
+	return result, terminated(ctx, err)
+}
+
+// ChatCompletionStream forwards the request unless its session is terminated.
+func (m *Multiplexer) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	return m.stream(ctx, func(ctx context.Context) (<-chan interface{}, error) {
+		return m.Multiplexer.ChatCompletionStream(ctx, model, messages)
+	})
+}
+
+// CompletionStream forwards the request unless its session is terminated.
+func (m *Multiplexer) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	return m.stream(ctx, func(ctx context.Context) (<-chan interface{}, error) {
+		return m.Multiplexer.CompletionStream(ctx, model, prompt)
+	})
+}
+
+// Embedder wraps an embedder and refuses, or cancels, the requests of terminated sessions.
+type Embedder struct {
+	proxy.Embedder
+	registry *Registry
+}
+
+// NewEmbedder wraps e so requests are tracked by registry while they are served.
+func NewEmbedder(e proxy.Embedder, registry *Registry) *Embedder {
+	return &Embedder{Embedder: e, registry: registry}
+}
+
+// Embed forwards the request unless its session is terminated.
+func (e *Embedder) Embed(
+	ctx context.Context, model string, req *providers.EmbeddingRequest,
+) (*providers.EmbeddingResponse, error) {
+	ctx, done, err := e.registry.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	response, err := e.Embedder.Embed(ctx, model, req)
+	return response, terminated(ctx, err)
+}
+
+// Reranker wraps a reranker and refuses, or cancels, the requests of terminated sessions.
+type Reranker struct {
+	proxy.Reranker
+	registry *Registry
+}
+
+// NewReranker wraps r so requests are tracked by registry while they are served.
+func NewReranker(r proxy.Reranker, registry *Registry) *Reranker {
+	return &Reranker{Reranker: r, registry: registry}
+}
+
+// Rerank forwards the request unless its session is terminated.
+func (r *Reranker) Rerank(
+	ctx context.Context, model string, req *providers.RerankRequest,
+) (*providers.RerankResponse, error) {
+	ctx, done, err := r.registry.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer done()
+	response, err := r.Reranker.Rerank(ctx, model, req)
+	return response, terminated(ctx, err)
+}
+
+// stream starts a stream with start and tracks it until it ends. A stream cut short by its
+// session's termination ends with an error chunk saying so.
+func (m *Multiplexer) stream(
+	parent context.Context, start func(context.Context) (<-chan interface{}, error),
+) (<-chan interface{}, error) {
+	ctx, done, err := m.registry.Begin(parent)
+	if err != nil {
+		return nil, err
+	}
+	chunks, err := start(ctx)
+	if err != nil {
+		done()
+		return nil, terminated(ctx, err)
+	}
+
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		defer done()
+		for chunk := range chunks {
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+			}
+		}
+		var termination *providers.SessionTerminatedError
+		if errors.As(context.Cause(ctx), &termination) {
+			select {
+			case out <- map[string]interface{}{"error": map[string]interface{}{
+				"type": "session_terminated", "message": termination.Error(),
+			}}:
+			case <-parent.Done():
+			}
+		}
+	}()
+	return out, nil
+}
+
+// terminated returns the termination that cancelled ctx in place of err, which is then only its
+// consequence, or err as it is.
+func terminated(ctx context.Context, err error) error {
+	var termination *providers.SessionTerminatedError
+	if err != nil && errors.As(context.Cause(ctx), &termination) {
+		return termination
+	}
+	return err
+}
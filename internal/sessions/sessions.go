@@ -0,0 +1,176 @@
+// Package sessions is the emergency stop for misbehaving autonomous agents: an operator terminates
+// a session, a conversation or every request of a tenant, which cancels its requests in flight and
+// refuses its later ones until the session is resumed.
+//
+// A conversation is the requests carrying the same conversation_id metadata value, a tenant those
+// tagged with the same usage.tenant_header value. Terminations are held by the instance they were
+// made on and don't survive a restart.
+package sessions
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// The scopes of sessions
+const (
+	ScopeConversation = "conversation"
+	ScopeTenant       = "tenant"
+)
+
+// ErrUnknownScope is returned for a scope other than ScopeConversation and ScopeTenant.
+var ErrUnknownScope = errors.New(`unknown scope, expected "conversation" or "tenant"`)
+
+// Termination is a terminated session.
+type Termination struct {
+	Scope        string    `json:"scope"`
+	ID           string    `json:"id"`
+	Reason       string    `json:"reason,omitempty"`
+	TerminatedAt time.Time `json:"terminated_at"`
+	// Cancelled counts the requests in flight the termination cancelled
+	Cancelled int `json:"cancelled"`
+}
+
+// session identifies a session by scope and id.
+type session struct {
+	scope string
+	id    string
+}
+
+// request is a request in flight.
+type request struct {
+	cancel context.CancelCauseFunc
+}
+
+// Registry tracks the terminated sessions and the requests in flight of every session.
+type Registry struct {
+	now func() time.Time
+
+	mtx        sync.Mutex
+	terminated map[session]Termination
+	inFlight   map[session]map[*request]struct{}
+}
+
+// NewRegistry creates a registry without terminated sessions.
+func NewRegistry() *Registry {
+	return &Registry{
+		now:        time.Now,
+		terminated: make(map[session]Termination),
+		inFlight:   make(map[session]map[*request]struct{}),
+	}
+}
+
+// Begin starts tracking the request of ctx, returning the context to serve it with, cancelled
+// when its session is terminated, and the function to call once it is served. It returns a
+// *providers.SessionTerminatedError instead when a session of the request is terminated.
+func (r *Registry) Begin(ctx context.Context) (context.Context, func(), error) {
+	sessions := sessionsOf(ctx)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	for _, s := range sessions {
+		if termination, ok := r.terminated[s]; ok {
+			return nil, nil, termination.err()
+		}
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	req := &request{cancel: cancel}
+	for _, s := range sessions {
+		if r.inFlight[s] == nil {
+			r.inFlight[s] = make(map[*request]struct{})
+		}
+		r.inFlight[s][req] = struct{}{}
+	}
+	done := func() {
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+		for _, s := range sessions {
+			delete(r.inFlight[s], req)
+			if len(r.inFlight[s]) == 0 {
+				delete(r.inFlight, s)
+			}
+		}
+		cancel(nil)
+	}
+	return ctx, done, nil
+}
+
+// Terminate terminates the session of scope and id, cancelling its requests in flight and
+// refusing its later ones until it is resumed. Terminating a terminated session again only
+// replaces its reason, when a new one is given.
+func (r *Registry) Terminate(scope, id, reason string) (Termination, error) {
+	if scope != ScopeConversation && scope != ScopeTenant {
+		return Termination{}, ErrUnknownScope
+	}
+	s := session{scope: scope, id: id}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	termination, ok := r.terminated[s]
+	if !ok {
+		termination = Termination{Scope: scope, ID: id, TerminatedAt: r.now().UTC()}
+	}
+	if reason != "" {
+		termination.Reason = reason
+	}
+	cause := termination.err()
+	for req := range r.inFlight[s] {
+		req.cancel(cause)
+		termination.Cancelled++
+	}
+	delete(r.inFlight, s)
+	r.terminated[s] = termination
+	return termination, nil
+}
+
+// Resume lets the session of scope and id make requests again, returning whether it was
+// terminated.
+func (r *Registry) Resume(scope, id string) (bool, error) {
+	if scope != ScopeConversation && scope != ScopeTenant {
+		return false, ErrUnknownScope
+	}
+	s := session{scope: scope, id: id}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	_, ok := r.terminated[s]
+	delete(r.terminated, s)
+	return ok, nil
+}
+
+// Terminated returns the terminated sessions, the earliest terminated first.
+func (r *Registry) Terminated() []Termination {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	terminations := make([]Termination, 0, len(r.terminated))
+	for _, termination := range r.terminated {
+		terminations = append(terminations, termination)
+	}
+	slices.SortFunc(terminations, func(a, b Termination) int {
+		return a.TerminatedAt.Compare(b.TerminatedAt)
+	})
+	return terminations
+}
+
+func (t Termination) err() *providers.SessionTerminatedError {
+	return &providers.SessionTerminatedError{Scope: t.Scope, ID: t.ID, Reason: t.Reason}
+}
+
+// sessionsOf returns the sessions the request of ctx belongs to: its tenant and, when it has one,
+// its conversation.
+func sessionsOf(ctx context.Context) []session {
+	sessions := []session{{scope: ScopeTenant, id: usage.TenantFrom(ctx)}}
+	if conversation := metadata.From(ctx)[erasure.ConversationKey]; conversation != "" {
+		sessions = append(sessions, session{scope: ScopeConversation, id: conversation})
+	}
+	return sessions
+}
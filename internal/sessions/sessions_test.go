@@ -0,0 +1,144 @@
+package sessions
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/providers"
+	"github.com/modelplex/modelplex/internal/proxy"
+	"github.com/modelplex/modelplex/internal/usage"
+)
+
+// stubMultiplexer answers chat requests once their context is done, and streams until then.
+type stubMultiplexer struct {
+	proxy.Multiplexer
+	started chan struct{}
+}
+
+func (m *stubMultiplexer) ChatCompletion(
+	ctx context.Context, _ string, _ []map[string]interface{},
+) (interface{}, error) {
+	m.started <- struct{}{}
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func (m *stubMultiplexer) ChatCompletionStream(
+	ctx context.Context, _ string, _ []map[string]interface{},
+) (<-chan interface{}, error) {
+	chunks := make(chan interface{})
+	go func() {
+		defer close(chunks)
+		for {
+			select {
+			case chunks <- map[string]interface{}{"choices": []interface{}{}}:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return chunks, nil
+}
+
+var messages = []map[string]interface{}{{"role": "user", "content": "Hello"}}
+
+func conversationContext(ctx context.Context, tenant, conversation string) context.Context {
+	return metadata.With(usage.WithTenant(ctx, tenant), map[string]string{"conversation_id": conversation})
+}
+
+func TestMultiplexer_TerminateCancelsInFlight(t *testing.T) {
+	registry := NewRegistry()
+	stub := &stubMultiplexer{started: make(chan struct{})}
+	m := NewMultiplexer(stub, registry)
+	ctx := conversationContext(t.Context(), "acme", "c-1")
+
+	errs := make(chan error)
+	go func() {
+		_, err := m.ChatCompletion(ctx, "gpt-4", messages)
+		errs <- err
+	}()
+	<-stub.started
+	termination, err := registry.Terminate(ScopeConversation, "c-1", "runaway agent")
+	require.NoError(t, err)
+	assert.Equal(t, 1, termination.Cancelled)
+
+	var terminated *providers.SessionTerminatedError
+	require.ErrorAs(t, <-errs, &terminated)
+	assert.Equal(t, providers.SessionTerminatedError{Scope: "conversation", ID: "c-1", Reason: "runaway agent"},
+		*terminated)
+
+	_, err = m.ChatCompletion(ctx, "gpt-4", messages)
+	require.ErrorAs(t, err, &terminated, "later requests are refused")
+	_, err = m.ChatCompletionStream(ctx, "gpt-4", messages)
+	require.ErrorAs(t, err, &terminated)
+
+	resumed, err := registry.Resume(ScopeConversation, "c-1")
+	require.NoError(t, err)
+	assert.True(t, resumed)
+	assert.Empty(t, registry.Terminated())
+	_, done, err := registry.Begin(ctx)
+	require.NoError(t, err, "resumed sessions make requests again")
+	done()
+}
+
+func TestMultiplexer_TerminateEndsStreams(t *testing.T) {
+	registry := NewRegistry()
+	m := NewMultiplexer(&stubMultiplexer{}, registry)
+
+	stream, err := m.ChatCompletionStream(conversationContext(t.Context(), "acme", "c-1"), "gpt-4", messages)
+	require.NoError(t, err)
+	other, err := m.ChatCompletionStream(conversationContext(t.Context(), "globex", "c-2"), "gpt-4", messages)
+	require.NoError(t, err)
+	<-stream
+
+	termination, err := registry.Terminate(ScopeTenant, "acme", "")
+	require.NoError(t, err)
+	assert.Equal(t, 1, termination.Cancelled)
+	var last interface{}
+	for chunk := range stream {
+		last = chunk
+	}
+	assert.Equal(t, map[string]interface{}{"error": map[string]interface{}{
+		"type": "session_terminated", "message": `tenant "acme" was terminated by an operator`,
+	}}, last)
+	assert.NotNil(t, <-other, "other tenants stream on")
+
+	_, err = registry.Terminate("key", "acme", "")
+	assert.ErrorIs(t, err, ErrUnknownScope)
+	assert.Equal(t, []Termination{termination}, registry.Terminated())
+}
+
+type stubEmbedder struct{}
+
+func (stubEmbedder) Embed(
+	_ context.Context, model string, _ *providers.EmbeddingRequest,
+) (*providers.EmbeddingResponse, error) {
+	return &providers.EmbeddingResponse{Object: "list", Model: model, Data: []providers.Embedding{
+		{Object: "embedding", Embedding: []float64{0.5}},
+	}}, nil
+}
+
+func TestEmbedder_RefusesTerminatedSessions(t *testing.T) {
+	registry := NewRegistry()
+	handler := proxy.New(&stubMultiplexer{}, proxy.WithEmbedder(NewEmbedder(stubEmbedder{}, registry)))
+	embed := func(conversation string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest("POST", "/v1/embeddings", strings.NewReader(`{"model":"voyage-3.5","input":"a"}`))
+		w := httptest.NewRecorder()
+		handler.HandleEmbeddings(w, r.WithContext(conversationContext(r.Context(), "acme", conversation)))
+		return w
+	}
+
+	_, err := registry.Terminate(ScopeConversation, "c-1", "runaway agent")
+	require.NoError(t, err)
+	w := embed("c-1")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Body.String(), `"type":"session_terminated"`)
+	assert.Equal(t, http.StatusOK, embed("c-2").Code, "other conversations embed on")
+}
@@ -0,0 +1,262 @@
+// Package startup waits for what the server depends on to come up after it starts listening, such
+// as a local Ollama or MCP servers, and reports whether the server is ready to take traffic.
+//
+// Dependencies are waited for one after another, in their configured order, each checked every
+// second until it is up or its timeout passes. Once one fails, those after it aren't waited for, so
+// the readiness report names the first dependency that didn't come up and why.
+package startup
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/mcp"
+)
+
+// The states of a dependency
+const (
+	// StatePending is a dependency not waited for yet
+	StatePending = "pending"
+	// StateWaiting is the dependency being waited for
+	StateWaiting = "waiting"
+	// StateUp is a dependency that came up
+	StateUp = "up"
+	// StateFailed is a dependency that didn't come up before its timeout
+	StateFailed = "failed"
+	// StateSkipped is a dependency not waited for, since one before it failed
+	StateSkipped = "skipped"
+)
+
+// The readiness statuses
+const (
+	StatusStarting = "starting"
+	StatusReady    = "ready"
+	StatusFailed   = "failed"
+)
+
+const (
+	// checkInterval is the pause between checks of a dependency that isn't up yet
+	checkInterval = time.Second
+	// requestTimeout bounds the HTTP request of a single check
+	requestTimeout = 5 * time.Second
+)
+
+// Check returns nil when a dependency is up, or why it isn't.
+type Check func(ctx context.Context) error
+
+// Dependency is something waited for at startup.
+type Dependency struct {
+	Name    string
+	Timeout time.Duration
+	Check   Check
+}
+
+// DependencyState is how far waiting for a dependency got.
+type DependencyState struct {
+	Name  string `json:"name"`
+	State string `json:"state"`
+	// Reason tells why the dependency failed or was skipped, or why it isn't up yet while waiting
+	Reason string `json:"reason,omitempty"`
+	// Took is how long the dependency took to come up or fail, in milliseconds
+	Took int64 `json:"took_ms,omitempty"`
+}
+
+// Readiness is whether the server is ready, with the state of every dependency.
+type Readiness struct {
+	Status       string            `json:"status"`
+	Dependencies []DependencyState `json:"dependencies"`
+}
+
+// Gate waits for the dependencies and tracks how far it got.
+type Gate struct {
+	dependencies []Dependency
+	interval     time.Duration
+
+	mtx    sync.Mutex
+	status string
+	states []DependencyState
+}
+
+// New creates a gate for dependencies, which is ready at once without any.
+func New(dependencies []Dependency) *Gate {
+	g := &Gate{
+		dependencies: dependencies,
+		interval:     checkInterval,
+		status:       StatusReady,
+		states:       make([]DependencyState, len(dependencies)),
+	}
+	if len(dependencies) > 0 {
+		g.status = StatusStarting
+	}
+	for i, d := range dependencies {
+		g.states[i] = DependencyState{Name: d.Name, State: StatePending}
+	}
+	return g
+}
+
+// Run waits for the dependencies in order until all are up, one fails or ctx is done.
+func (g *Gate) Run(ctx context.Context) {
+	for i, d := range g.dependencies {
+		g.update(i, StateWaiting, "", 0)
+		start := time.Now()
+		err := g.wait(ctx, i, d)
+		took := time.Since(start)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			reason := fmt.Sprintf("not up after %s: %v", d.Timeout, err)
+			slog.Error("Startup dependency failed, the server isn't ready", "dependency", d.Name, "reason", reason)
+			g.update(i, StateFailed, reason, took)
+			g.fail(i)
+			return
+		}
+		slog.Info("Startup dependency is up", "dependency", d.Name, "took", took.Round(time.Millisecond))
+		g.update(i, StateUp, "", took)
+	}
+
+	g.mtx.Lock()
+	g.status = StatusReady
+	g.mtx.Unlock()
+	if len(g.dependencies) > 0 {
+		slog.Info("Startup dependencies are up, the server is ready")
+	}
+}
+
+// wait checks d until it is up or its timeout passes, returning why the last check found it down
+// then. A check cut short by the timeout only says so, so an earlier check's reason is kept over it.
+func (g *Gate) wait(ctx context.Context, i int, d Dependency) error {
+	ctx, cancel := context.WithTimeout(ctx, d.Timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	var last error
+	for {
+		err := d.Check(ctx)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() == nil || last == nil {
+			last = err
+			g.update(i, StateWaiting, err.Error(), 0)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return last
+		}
+	}
+}
+
+func (g *Gate) update(i int, state, reason string, took time.Duration) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.states[i] = DependencyState{Name: g.states[i].Name, State: state, Reason: reason, Took: took.Milliseconds()}
+}
+
+// fail marks the gate failed on the dependency at i, skipping those after it.
+func (g *Gate) fail(i int) {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	g.status = StatusFailed
+	for j := i + 1; j < len(g.states); j++ {
+		g.states[j].State = StateSkipped
+		g.states[j].Reason = fmt.Sprintf("%s failed before it", g.states[i].Name)
+	}
+}
+
+// Readiness reports whether the server is ready.
+func (g *Gate) Readiness() Readiness {
+	g.mtx.Lock()
+	defer g.mtx.Unlock()
+	return Readiness{Status: g.status, Dependencies: slices.Clone(g.states)}
+}
+
+// Dependencies returns the startup dependencies of cfg with their checks. MCP servers are checked
+// on servers, the client running them, which is nil without any.
+func Dependencies(cfg *config.Config, servers *mcp.Client) []Dependency {
+	client := &http.Client{Timeout: requestTimeout}
+	dependencies := make([]Dependency, 0, len(cfg.Startup.Dependencies))
+	for _, d := range cfg.Startup.Dependencies {
+		dependency := Dependency{Name: d.Name, Timeout: time.Duration(d.TimeoutSeconds) * time.Second}
+		provider := slices.IndexFunc(cfg.Providers, func(p config.Provider) bool { return p.Name == d.Provider })
+		switch {
+		case d.Provider != "" && provider >= 0:
+			dependency.Check = answers(client, providerEndpoints(&cfg.Providers[provider]))
+		case d.MCPServer != "" && servers != nil:
+			name := d.MCPServer
+			dependency.Check = func(context.Context) error { return servers.Ready(name) }
+		case d.URL != "":
+			dependency.Check = healthy(client, d.URL)
+		default:
+			// Validation rules this out; the dependency can't come up
+			dependency.Check = func(context.Context) error { return fmt.Errorf("%s isn't configured", d.Describe()) }
+		}
+		dependencies = append(dependencies, dependency)
+	}
+	return dependencies
+}
+
+// providerEndpoints returns the base URLs of p, one per region with regions.
+func providerEndpoints(p *config.Provider) []string {
+	if len(p.Regions) == 0 {
+		return []string{p.BaseURL}
+	}
+	endpoints := make([]string, len(p.Regions))
+	for i, r := range p.Regions {
+		endpoints[i] = r.BaseURL
+	}
+	return endpoints
+}
+
+// answers checks that one of endpoints answers a GET without a server error. Other error statuses,
+// e.g. a 404 for a bare base URL or a 401 without credentials, still show the server is running.
+func answers(client *http.Client, endpoints []string) Check {
+	return func(ctx context.Context) error {
+		var err error
+		for _, endpoint := range endpoints {
+			var status int
+			if status, err = get(ctx, client, endpoint); err == nil && status >= http.StatusInternalServerError {
+				err = fmt.Errorf("%s answered with status %d", endpoint, status)
+			}
+			if err == nil {
+				return nil
+			}
+		}
+		return err
+	}
+}
+
+// healthy checks that url answers a GET with a 2xx status.
+func healthy(client *http.Client, url string) Check {
+	return func(ctx context.Context) error {
+		status, err := get(ctx, client, url)
+		if err != nil {
+			return err
+		}
+		if status < http.StatusOK || status >= http.StatusMultipleChoices {
+			return fmt.Errorf("%s answered with status %d", url, status)
+		}
+		return nil
+	}
+}
+
+func get(ctx context.Context, client *http.Client, url string) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, http.NoBody)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	_ = resp.Body.Close()
+	return resp.StatusCode, nil
+}
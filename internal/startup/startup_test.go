@@ -0,0 +1,118 @@
+package startup
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/mcp"
+)
+
+func TestGate(t *testing.T) {
+	assert.Equal(t, StatusReady, New(nil).Readiness().Status, "nothing to wait for")
+
+	var order []string
+	attempts := 0
+	gate := New([]Dependency{
+		{Name: "ollama", Timeout: time.Second, Check: func(context.Context) error {
+			order = append(order, "ollama")
+			if attempts++; attempts < 3 {
+				return errors.New("connection refused")
+			}
+			return nil
+		}},
+		{Name: "filesystem", Timeout: time.Second, Check: func(context.Context) error {
+			order = append(order, "filesystem")
+			return nil
+		}},
+	})
+	gate.interval = time.Millisecond
+	assert.Equal(t, StatusStarting, gate.Readiness().Status)
+	assert.Equal(t, StatePending, gate.Readiness().Dependencies[0].State)
+
+	gate.Run(t.Context())
+	readiness := gate.Readiness()
+	assert.Equal(t, StatusReady, readiness.Status)
+	assert.Equal(t, []string{"ollama", "ollama", "ollama", "filesystem"}, order, "one after another")
+	for _, d := range readiness.Dependencies {
+		assert.Equal(t, StateUp, d.State, d.Name)
+		assert.Empty(t, d.Reason, d.Name)
+	}
+}
+
+func TestGate_Failure(t *testing.T) {
+	waited := false
+	gate := New([]Dependency{
+		{Name: "ollama", Timeout: 20 * time.Millisecond, Check: func(ctx context.Context) error {
+			if _, ok := ctx.Deadline(); !ok {
+				return nil
+			}
+			return errors.New("connection refused")
+		}},
+		{Name: "filesystem", Timeout: time.Second, Check: func(context.Context) error {
+			waited = true
+			return nil
+		}},
+	})
+	gate.interval = time.Millisecond
+
+	gate.Run(t.Context())
+	readiness := gate.Readiness()
+	assert.Equal(t, StatusFailed, readiness.Status)
+	assert.Equal(t, DependencyState{
+		Name: "ollama", State: StateFailed, Reason: "not up after 20ms: connection refused",
+		Took: readiness.Dependencies[0].Took,
+	}, readiness.Dependencies[0])
+	assert.Equal(t, DependencyState{Name: "filesystem", State: StateSkipped, Reason: "ollama failed before it"},
+		readiness.Dependencies[1])
+	assert.False(t, waited)
+}
+
+func TestDependencies(t *testing.T) {
+	status := http.StatusNotFound
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(provider.Close)
+	health := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(health.Close)
+
+	cfg := &config.Config{
+		Providers: []config.Provider{{Name: "ollama", Type: "ollama", BaseURL: provider.URL}},
+		MCP:       config.MCPConfig{Servers: []config.MCPServer{{Name: "filesystem", Command: "modelplex-missing-mcp"}}},
+		Startup: config.StartupConfig{Dependencies: []config.StartupDependency{
+			{Name: "provider ollama", Provider: "ollama", TimeoutSeconds: 120},
+			{Name: "embedder", URL: health.URL + "/healthz", TimeoutSeconds: 30},
+			{Name: "search", URL: health.URL + "/search", TimeoutSeconds: 30},
+			{Name: "provider missing", Provider: "missing", TimeoutSeconds: 30},
+			{Name: "mcp filesystem", MCPServer: "filesystem", TimeoutSeconds: 30},
+		}},
+	}
+	servers := mcp.NewMCPClient(cfg.MCP.Servers)
+	t.Cleanup(servers.Stop)
+	dependencies := Dependencies(cfg, servers)
+	require.Len(t, dependencies, 5)
+	assert.Equal(t, "provider ollama", dependencies[0].Name)
+	assert.Equal(t, 2*time.Minute, dependencies[0].Timeout)
+
+	assert.NoError(t, dependencies[0].Check(t.Context()), "any answer shows the provider is running")
+	status = http.StatusBadGateway
+	assert.ErrorContains(t, dependencies[0].Check(t.Context()), "answered with status 502")
+
+	assert.NoError(t, dependencies[1].Check(t.Context()))
+	assert.ErrorContains(t, dependencies[2].Check(t.Context()), "answered with status 503")
+	assert.EqualError(t, dependencies[3].Check(t.Context()), "provider missing isn't configured")
+	assert.ErrorContains(t, dependencies[4].Check(t.Context()), "failed to start",
+		"the running instance is checked, not a new one")
+}
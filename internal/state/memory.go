@@ -0,0 +1,124 @@
+package state
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	// sweepInterval bounds how often expired entries that are never read again get evicted
+	sweepInterval = time.Minute
+)
+
+// memoryEntry is a single value with an optional expiry.
+type memoryEntry struct {
+	value     []byte
+	expiresAt time.Time
+}
+
+// MemoryStore implements Store in the local process.
+type MemoryStore struct {
+	entries   map[string]memoryEntry
+	mtx       sync.Mutex
+	now       func() time.Time
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store.
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		entries: make(map[string]memoryEntry),
+		now:     time.Now,
+	}
+}
+
+// sweep evicts expired entries at most once per sweepInterval. Callers must hold mtx.
+func (s *MemoryStore) sweep() {
+	now := s.now()
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, entry := range s.entries {
+		if !entry.expiresAt.IsZero() && !now.Before(entry.expiresAt) {
+			delete(s.entries, key)
+		}
+	}
+}
+
+// IncrBy atomically adds delta to the counter at key.
+func (s *MemoryStore) IncrBy(_ context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.sweep()
+	entry, ok := s.lookup(key)
+	var current int64
+	if ok {
+		var err error
+		current, err = strconv.ParseInt(string(entry.value), 10, 64)
+		if err != nil {
+			return 0, err
+		}
+	} else if ttl > 0 {
+		entry.expiresAt = s.now().Add(ttl)
+	}
+
+	current += delta
+	entry.value = []byte(strconv.FormatInt(current, 10))
+	s.entries[key] = entry
+	return current, nil
+}
+
+// Get returns the value at key.
+func (s *MemoryStore) Get(_ context.Context, key string) ([]byte, bool, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	entry, ok := s.lookup(key)
+	return entry.value, ok, nil
+}
+
+// Set stores value at key.
+func (s *MemoryStore) Set(_ context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.sweep()
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = s.now().Add(ttl)
+	}
+	s.entries[key] = entry
+	return nil
+}
+
+// Delete removes key.
+func (s *MemoryStore) Delete(_ context.Context, key string) error {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	delete(s.entries, key)
+	return nil
+}
+
+// Close is a no-op for the in-memory store.
+func (s *MemoryStore) Close() error {
+	return nil
+}
+
+// lookup returns a live entry, evicting it if it has expired. Callers must hold mtx.
+func (s *MemoryStore) lookup(key string) (memoryEntry, bool) {
+	entry, ok := s.entries[key]
+	if !ok {
+		return memoryEntry{}, false
+	}
+	if !entry.expiresAt.IsZero() && !s.now().Before(entry.expiresAt) {
+		delete(s.entries, key)
+		return memoryEntry{}, false
+	}
+	return entry, true
+}
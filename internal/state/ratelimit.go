@@ -0,0 +1,74 @@
+package state
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// RateLimiter enforces a fixed-window request limit on top of a Store.
+// With a shared store every instance counts against the same window.
+type RateLimiter struct {
+	store  Store
+	limit  int64
+	window time.Duration
+	now    func() time.Time
+}
+
+// NewRateLimiter creates a limiter allowing limit requests per window.
+func NewRateLimiter(store Store, limit int64, window time.Duration) *RateLimiter {
+	return &RateLimiter{
+		store:  store,
+		limit:  limit,
+		window: window,
+		now:    time.Now,
+	}
+}
+
+// Allow records a request for key and reports whether it fits in the current window,
+// along with how many requests remain in it.
+func (l *RateLimiter) Allow(ctx context.Context, key string) (allowed bool, remaining int64, err error) {
+	count, err := l.store.IncrBy(ctx, l.windowKey(key), 1, l.window)
+	if err != nil {
+		return false, 0, err
+	}
+
+	remaining = l.limit - count
+	if remaining < 0 {
+		remaining = 0
+	}
+	return count <= l.limit, remaining, nil
+}
+
+// Remaining reports how many requests remain for key in the current window and how long until
+// it resets, without recording a request.
+func (l *RateLimiter) Remaining(ctx context.Context, key string) (remaining int64, reset time.Duration, err error) {
+	value, ok, err := l.store.Get(ctx, l.windowKey(key))
+	if err != nil {
+		return 0, 0, err
+	}
+	var count int64
+	if ok {
+		if count, err = strconv.ParseInt(string(value), 10, 64); err != nil {
+			return 0, 0, err
+		}
+	}
+	return max(l.limit-count, 0), l.Reset(), nil
+}
+
+// Limit returns the number of requests allowed per window.
+func (l *RateLimiter) Limit() int64 {
+	return l.limit
+}
+
+// Reset returns how long until the current window ends and its counts start over.
+func (l *RateLimiter) Reset() time.Duration {
+	now := l.now()
+	return now.Truncate(l.window).Add(l.window).Sub(now)
+}
+
+// windowKey returns the counter of key for the current window.
+func (l *RateLimiter) windowKey(key string) string {
+	return fmt.Sprintf("ratelimit:%s:%d", key, l.now().Truncate(l.window).Unix())
+}
@@ -0,0 +1,303 @@
+package state
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// redisDefaultAddr is used when the URL omits a host
+	redisDefaultAddr = "localhost:6379"
+	// redisPoolSize is the number of idle connections kept for reuse
+	redisPoolSize = 8
+	// redisDialTimeout bounds connection establishment when ctx has no deadline
+	redisDialTimeout = 5 * time.Second
+	// redisCommandTimeout bounds a command, connecting included, when ctx has no deadline, so a
+	// stalled server fails requests that don't set one rather than holding them
+	redisCommandTimeout = 500 * time.Millisecond
+)
+
+// errRedisNil represents a nil bulk reply (missing key).
+var errRedisNil = errors.New("redis: nil")
+
+// incrByScript increments KEYS[1] by ARGV[1] and, given a TTL in ARGV[2], sets it on a counter that
+// has no expiry yet. Running both in one script keeps a counter from being left without expiry, and
+// its client limited for good, when the expiry can't be set after the increment.
+const incrByScript = `local value = redis.call('INCRBY', KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call('PTTL', KEYS[1]) == -1 then
+  redis.call('PEXPIRE', KEYS[1], ARGV[2])
+end
+return value`
+
+// RedisStore implements Store on a Redis server using the RESP protocol directly,
+// which keeps modelplex free of a client library dependency for a handful of commands.
+type RedisStore struct {
+	addr     string
+	password string
+	db       int
+	prefix   string
+	idle     chan *redisConn
+	// timeout is redisCommandTimeout; tests shorten it
+	timeout time.Duration
+}
+
+// redisConn is a single buffered connection to Redis.
+type redisConn struct {
+	conn net.Conn
+	rd   *bufio.Reader
+}
+
+// NewRedisStore creates a store for a redis://[:password@]host:port/db URL.
+// Connections are established lazily on first use.
+func NewRedisStore(rawURL, prefix string) (*RedisStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid redis URL: %w", err)
+	}
+	if u.Scheme != "redis" {
+		return nil, fmt.Errorf("invalid redis URL scheme: %q", u.Scheme)
+	}
+
+	s := &RedisStore{
+		addr:    u.Host,
+		prefix:  prefix,
+		idle:    make(chan *redisConn, redisPoolSize),
+		timeout: redisCommandTimeout,
+	}
+	if s.addr == "" {
+		s.addr = redisDefaultAddr
+	}
+	if u.User != nil {
+		s.password, _ = u.User.Password()
+	}
+	if db := strings.TrimPrefix(u.Path, "/"); db != "" {
+		if s.db, err = strconv.Atoi(db); err != nil {
+			return nil, fmt.Errorf("invalid redis database %q: %w", db, err)
+		}
+	}
+
+	return s, nil
+}
+
+// IncrBy atomically adds delta to the counter at key. Only the increment that finds the key without
+// expiry sets it, mirroring a fixed window; the increment and expiry run as one script.
+func (s *RedisStore) IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error) {
+	reply, err := s.do(ctx, "EVAL", incrByScript, "1", s.prefix+key,
+		strconv.FormatInt(delta, 10), strconv.FormatInt(ttl.Milliseconds(), 10))
+	if err != nil {
+		return 0, err
+	}
+	value, ok := reply.(int64)
+	if !ok {
+		return 0, fmt.Errorf("redis: unexpected EVAL reply %T", reply)
+	}
+	return value, nil
+}
+
+// Get returns the value at key.
+func (s *RedisStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	reply, err := s.do(ctx, "GET", s.prefix+key)
+	if errors.Is(err, errRedisNil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	value, ok := reply.([]byte)
+	if !ok {
+		return nil, false, fmt.Errorf("redis: unexpected GET reply %T", reply)
+	}
+	return value, true, nil
+}
+
+// Set stores value at key.
+func (s *RedisStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	args := []string{"SET", s.prefix + key, string(value)}
+	if ttl > 0 {
+		args = append(args, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	}
+	_, err := s.do(ctx, args...)
+	return err
+}
+
+// Delete removes key.
+func (s *RedisStore) Delete(ctx context.Context, key string) error {
+	_, err := s.do(ctx, "DEL", s.prefix+key)
+	return err
+}
+
+// Close closes all idle connections.
+func (s *RedisStore) Close() error {
+	for {
+		select {
+		case c := <-s.idle:
+			_ = c.conn.Close()
+		default:
+			return nil
+		}
+	}
+}
+
+// do runs a single command on a pooled connection, within the store's timeout when ctx has no deadline.
+func (s *RedisStore) do(ctx context.Context, args ...string) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, s.timeout)
+		defer cancel()
+	}
+	c, err := s.get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := c.roundTrip(ctx, args...)
+	if err != nil && !errors.Is(err, errRedisNil) && !isRedisError(err) {
+		// Connection state is unknown after an I/O error
+		_ = c.conn.Close()
+		return nil, err
+	}
+
+	s.put(c)
+	return reply, err
+}
+
+func (s *RedisStore) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case c := <-s.idle:
+		return c, nil
+	default:
+	}
+
+	dialer := net.Dialer{Timeout: redisDialTimeout}
+	conn, err := dialer.DialContext(ctx, "tcp", s.addr)
+	if err != nil {
+		return nil, fmt.Errorf("redis: %w", err)
+	}
+	c := &redisConn{conn: conn, rd: bufio.NewReader(conn)}
+
+	if s.password != "" {
+		if _, err := c.roundTrip(ctx, "AUTH", s.password); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+	if s.db != 0 {
+		if _, err := c.roundTrip(ctx, "SELECT", strconv.Itoa(s.db)); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+	}
+
+	return c, nil
+}
+
+func (s *RedisStore) put(c *redisConn) {
+	select {
+	case s.idle <- c:
+	default:
+		_ = c.conn.Close()
+	}
+}
+
+// redisError is an error reply from the server; the connection remains usable.
+type redisError string
+
+func (e redisError) Error() string {
+	return "redis: " + string(e)
+}
+
+func isRedisError(err error) bool {
+	var re redisError
+	return errors.As(err, &re)
+}
+
+func (c *redisConn) roundTrip(ctx context.Context, args ...string) (interface{}, error) {
+	deadline, ok := ctx.Deadline()
+	if !ok {
+		deadline = time.Time{}
+	}
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c.conn, b.String()); err != nil {
+		return nil, err
+	}
+
+	return c.readReply()
+}
+
+func (c *redisConn) readReply() (interface{}, error) {
+	line, err := c.rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, redisError(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		return c.readBulk(line[1:])
+	case '*':
+		return c.readArray(line[1:])
+	default:
+		return nil, fmt.Errorf("redis: unknown reply type %q", line[0])
+	}
+}
+
+func (c *redisConn) readBulk(header string) (interface{}, error) {
+	n, err := strconv.Atoi(header)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, errRedisNil
+	}
+
+	buf := make([]byte, n+len("\r\n"))
+	if _, err := io.ReadFull(c.rd, buf); err != nil {
+		return nil, err
+	}
+	return buf[:n], nil
+}
+
+func (c *redisConn) readArray(header string) (interface{}, error) {
+	n, err := strconv.Atoi(header)
+	if err != nil {
+		return nil, err
+	}
+	if n < 0 {
+		return nil, errRedisNil
+	}
+
+	items := make([]interface{}, n)
+	for i := range items {
+		item, err := c.readReply()
+		if err != nil && !errors.Is(err, errRedisNil) {
+			return nil, err
+		}
+		items[i] = item
+	}
+	return items, nil
+}
@@ -0,0 +1,248 @@
+package state
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRedis is a tiny RESP server supporting the commands RedisStore uses.
+type fakeRedis struct {
+	listener net.Listener
+	mtx      sync.Mutex
+	data     map[string]string
+	expiry   map[string]string
+	commands []string
+	// failEval answers EVAL with an error, as Redis does for a script that fails
+	failEval bool
+	// stall leaves commands unanswered, as a server that has hung does
+	stall bool
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	f := &fakeRedis{listener: listener, data: make(map[string]string), expiry: make(map[string]string)}
+	go f.serve()
+	t.Cleanup(func() { _ = listener.Close() })
+	return f
+}
+
+func (f *fakeRedis) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeRedis) handle(conn net.Conn) {
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	for {
+		args, err := readCommand(rd)
+		if err != nil {
+			return
+		}
+		f.mtx.Lock()
+		stall := f.stall
+		f.mtx.Unlock()
+		if stall {
+			continue
+		}
+		if _, err := io.WriteString(conn, f.exec(args)); err != nil {
+			return
+		}
+	}
+}
+
+func readCommand(rd *bufio.Reader) ([]string, error) {
+	line, err := rd.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+
+	args := make([]string, n)
+	for i := range args {
+		header, err := rd.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		size, err := strconv.Atoi(strings.TrimSpace(header[1:]))
+		if err != nil {
+			return nil, err
+		}
+		// Bulk strings are read by length, since scripts span lines
+		arg := make([]byte, size+2)
+		if _, err := io.ReadFull(rd, arg); err != nil {
+			return nil, err
+		}
+		args[i] = string(arg[:size])
+	}
+	return args, nil
+}
+
+func (f *fakeRedis) exec(args []string) string {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	if args[0] == "EVAL" && args[1] == incrByScript {
+		f.commands = append(f.commands, strings.Join(append([]string{"EVAL incrBy"}, args[2:]...), " "))
+	} else {
+		f.commands = append(f.commands, strings.Join(args, " "))
+	}
+	switch args[0] {
+	case "AUTH", "SELECT":
+		return "+OK\r\n"
+	case "EVAL":
+		if f.failEval || args[1] != incrByScript {
+			return "-ERR Error running script\r\n"
+		}
+		key := args[3]
+		current, _ := strconv.ParseInt(f.data[key], 10, 64)
+		delta, _ := strconv.ParseInt(args[4], 10, 64)
+		f.data[key] = strconv.FormatInt(current+delta, 10)
+		if _, ok := f.expiry[key]; !ok && args[5] != "0" {
+			f.expiry[key] = args[5]
+		}
+		return fmt.Sprintf(":%d\r\n", current+delta)
+	case "GET":
+		value, ok := f.data[args[1]]
+		if !ok {
+			return "$-1\r\n"
+		}
+		return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+	case "SET":
+		f.data[args[1]] = args[2]
+		return "+OK\r\n"
+	case "DEL":
+		delete(f.data, args[1])
+		delete(f.expiry, args[1])
+		return ":1\r\n"
+	default:
+		return "-ERR unknown command\r\n"
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+	fake := newFakeRedis(t)
+	store, err := NewRedisStore("redis://:secret@"+fake.listener.Addr().String()+"/3", "test:")
+	require.NoError(t, err)
+	defer store.Close()
+	ctx := t.Context()
+
+	count, err := store.IncrBy(ctx, "counter", 1, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), count)
+
+	count, err = store.IncrBy(ctx, "counter", 1, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	require.NoError(t, store.Set(ctx, "key", []byte("value"), time.Second))
+	value, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+
+	require.NoError(t, store.Delete(ctx, "key"))
+	_, ok, err = store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	fake.mtx.Lock()
+	defer fake.mtx.Unlock()
+	assert.Equal(t, map[string]string{"test:counter": "60000"}, fake.expiry)
+	assert.Equal(t, []string{
+		"AUTH secret",
+		"SELECT 3",
+		"EVAL incrBy 1 test:counter 1 60000",
+		"EVAL incrBy 1 test:counter 1 60000",
+		"SET test:key value PX 1000",
+		"GET test:key",
+		"DEL test:key",
+		"GET test:key",
+	}, fake.commands)
+}
+
+func TestRedisStore_ErrorReply(t *testing.T) {
+	fake := newFakeRedis(t)
+	store, err := NewRedisStore("redis://"+fake.listener.Addr().String(), "test:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	_, err = store.do(t.Context(), "FLUSHALL")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "unknown command")
+
+	// The connection stays usable after an error reply
+	require.NoError(t, store.Set(t.Context(), "key", []byte("value"), 0))
+}
+
+func TestRedisStore_IncrByError(t *testing.T) {
+	fake := newFakeRedis(t)
+	store, err := NewRedisStore("redis://"+fake.listener.Addr().String(), "test:")
+	require.NoError(t, err)
+	defer store.Close()
+
+	// A counter left without expiry, e.g. by an increment whose expiry failed, gets one on the next
+	fake.mtx.Lock()
+	fake.data["test:counter"] = "5"
+	fake.mtx.Unlock()
+	count, err := store.IncrBy(t.Context(), "counter", 1, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), count)
+
+	fake.mtx.Lock()
+	assert.Equal(t, "60000", fake.expiry["test:counter"])
+	fake.failEval = true
+	fake.mtx.Unlock()
+	_, err = store.IncrBy(t.Context(), "other", 1, time.Minute)
+	require.ErrorContains(t, err, "Error running script")
+	fake.mtx.Lock()
+	assert.NotContains(t, fake.data, "test:other", "a failed script leaves no counter behind")
+	fake.failEval = false
+	fake.mtx.Unlock()
+
+	count, err = store.IncrBy(t.Context(), "other", 1, time.Minute)
+	require.NoError(t, err, "the connection stays usable")
+	assert.Equal(t, int64(1), count)
+}
+
+func TestRedisStore_StalledServer(t *testing.T) {
+	fake := newFakeRedis(t)
+	store, err := NewRedisStore("redis://"+fake.listener.Addr().String(), "test:")
+	require.NoError(t, err)
+	defer store.Close()
+	assert.Equal(t, redisCommandTimeout, store.timeout)
+	store.timeout = 50 * time.Millisecond
+
+	fake.mtx.Lock()
+	fake.stall = true
+	fake.mtx.Unlock()
+	start := time.Now()
+	_, _, err = store.Get(t.Context(), "key")
+	require.Error(t, err, "a command without a deadline still times out")
+	assert.Less(t, time.Since(start), time.Second)
+
+	fake.mtx.Lock()
+	fake.stall = false
+	fake.mtx.Unlock()
+	require.NoError(t, store.Set(t.Context(), "key", []byte("value"), 0), "the stalled connection isn't reused")
+}
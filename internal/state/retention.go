@@ -0,0 +1,27 @@
+package state
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ErrRetentionDisabled is returned when a value is stored while strict privacy mode is on.
+var ErrRetentionDisabled = errors.New("storing values is disabled in strict privacy mode")
+
+// noRetentionStore keeps counters working but refuses to store values.
+type noRetentionStore struct {
+	Store
+}
+
+// NoRetention wraps store so that Set always fails with ErrRetentionDisabled.
+// Values are only ever request or response content, while counters such as rate limits are not,
+// so strict privacy mode puts this in front of the backend and no subsystem can persist content.
+func NoRetention(store Store) Store {
+	return noRetentionStore{Store: store}
+}
+
+// Set refuses to store value.
+func (noRetentionStore) Set(context.Context, string, []byte, time.Duration) error {
+	return ErrRetentionDisabled
+}
@@ -0,0 +1,54 @@
+// Package state provides the shared state backends used for counters such as rate limits.
+// The in-memory backend keeps limits per instance; the Redis backend lets several modelplex
+// instances behind a load balancer enforce the same limits cluster-wide.
+package state
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+const (
+	// BackendMemory keeps state in the local process
+	BackendMemory = "memory"
+	// BackendRedis keeps state in a shared Redis server
+	BackendRedis = "redis"
+
+	// defaultKeyPrefix namespaces keys so a Redis instance can be shared with other applications
+	defaultKeyPrefix = config.DefaultStateKeyPrefix
+)
+
+// Store is a minimal key-value store with expiring keys and atomic counters.
+type Store interface {
+	// IncrBy atomically adds delta to the counter at key and returns the new value.
+	// The ttl is applied when the counter is created.
+	IncrBy(ctx context.Context, key string, delta int64, ttl time.Duration) (int64, error)
+	// Get returns the value at key and whether it exists.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value at key; a zero ttl means the key never expires.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes key.
+	Delete(ctx context.Context, key string) error
+	// Close releases any resources held by the store.
+	Close() error
+}
+
+// New creates the store selected by cfg, defaulting to the in-memory backend.
+func New(cfg *config.StateConfig) (Store, error) {
+	prefix := cfg.KeyPrefix
+	if prefix == "" {
+		prefix = defaultKeyPrefix
+	}
+
+	switch cfg.Backend {
+	case "", BackendMemory:
+		return NewMemoryStore(), nil
+	case BackendRedis:
+		return NewRedisStore(cfg.RedisURL, prefix)
+	default:
+		return nil, fmt.Errorf("unknown state backend: %s", cfg.Backend)
+	}
+}
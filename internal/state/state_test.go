@@ -0,0 +1,126 @@
+package state
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestNew(t *testing.T) {
+	store, err := New(&config.StateConfig{})
+	require.NoError(t, err)
+	assert.IsType(t, &MemoryStore{}, store)
+
+	store, err = New(&config.StateConfig{Backend: BackendRedis, RedisURL: "redis://:secret@cache:6380/2"})
+	require.NoError(t, err)
+	redis := store.(*RedisStore)
+	assert.Equal(t, "cache:6380", redis.addr)
+	assert.Equal(t, "secret", redis.password)
+	assert.Equal(t, 2, redis.db)
+	assert.Equal(t, defaultKeyPrefix, redis.prefix)
+
+	_, err = New(&config.StateConfig{Backend: BackendRedis, RedisURL: "http://cache"})
+	assert.Error(t, err)
+
+	_, err = New(&config.StateConfig{Backend: "etcd"})
+	assert.Error(t, err)
+}
+
+func TestMemoryStore(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	ctx := t.Context()
+
+	count, err := store.IncrBy(ctx, "counter", 2, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), count)
+
+	count, err = store.IncrBy(ctx, "counter", 3, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5), count)
+
+	require.NoError(t, store.Set(ctx, "key", []byte("value"), 0))
+	value, ok, err := store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value"), value)
+
+	// Counters expire with their ttl, plain keys without one persist
+	now = now.Add(time.Minute)
+	_, ok, err = store.Get(ctx, "counter")
+	require.NoError(t, err)
+	assert.False(t, ok)
+	_, ok, err = store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, store.Delete(ctx, "key"))
+	_, ok, err = store.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRateLimiter(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limiter := NewRateLimiter(store, 2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	for _, expected := range []struct {
+		allowed   bool
+		remaining int64
+	}{{true, 1}, {true, 0}, {false, 0}} {
+		allowed, remaining, err := limiter.Allow(t.Context(), "global")
+		require.NoError(t, err)
+		assert.Equal(t, expected.allowed, allowed)
+		assert.Equal(t, expected.remaining, remaining)
+	}
+
+	// A new window resets the count
+	now = now.Add(time.Minute)
+	allowed, _, err := limiter.Allow(t.Context(), "global")
+	require.NoError(t, err)
+	assert.True(t, allowed)
+}
+
+func TestRateLimiter_Remaining(t *testing.T) {
+	now := time.Unix(1700000000, 0).Truncate(time.Minute).Add(15 * time.Second)
+	store := NewMemoryStore()
+	store.now = func() time.Time { return now }
+	limiter := NewRateLimiter(store, 2, time.Minute)
+	limiter.now = func() time.Time { return now }
+
+	remaining, reset, err := limiter.Remaining(t.Context(), "global")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), remaining)
+	assert.Equal(t, 45*time.Second, reset)
+
+	_, _, err = limiter.Allow(t.Context(), "global")
+	require.NoError(t, err)
+	// Looking doesn't count as a request
+	for range 2 {
+		remaining, _, err = limiter.Remaining(t.Context(), "global")
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), remaining)
+	}
+}
+
+func TestNoRetention(t *testing.T) {
+	store := NoRetention(NewMemoryStore())
+	defer store.Close()
+
+	assert.ErrorIs(t, store.Set(t.Context(), "k", []byte("prompt text"), 0), ErrRetentionDisabled)
+	_, ok, err := store.Get(t.Context(), "k")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	n, err := store.IncrBy(t.Context(), "counter", 2, time.Minute)
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), n)
+}
@@ -0,0 +1,466 @@
+// Package strict rewrites responses into exactly the OpenAI schema for SDKs that reject anything
+// else. Fields the schema requires are filled in when missing, fields it doesn't define are dropped,
+// finish reasons are mapped onto OpenAI's, and the native shapes of Anthropic and Ollama are
+// converted, so every response reads as a chat.completion or text_completion, or their chunks.
+package strict
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/modelplex/modelplex/pkg/convert"
+)
+
+// FinishReasons are the finish reasons of the OpenAI schema.
+var FinishReasons = []string{"stop", "length", "tool_calls", "content_filter", "function_call"}
+
+// ErrNotOpenAI is returned for a response that can't be read as any known shape.
+var ErrNotOpenAI = errors.New("response does not match the OpenAI schema")
+
+const (
+	objectChat       = "chat.completion"
+	objectChatChunk  = "chat.completion.chunk"
+	objectCompletion = "text_completion"
+	// idBytes is the length of generated response ids, before hex encoding
+	idBytes = 12
+)
+
+// Normalizer rewrites responses into the OpenAI schema. A nil Normalizer leaves them as they are.
+type Normalizer struct {
+	now func() time.Time
+}
+
+// New creates a normalizer, or nil when strict mode is off.
+func New(enabled bool) *Normalizer {
+	if !enabled {
+		return nil
+	}
+	return &Normalizer{now: time.Now}
+}
+
+// Response returns the response to a chat completion, or completion unless chat, for model in
+// the OpenAI schema. result is never modified, since it may be shared.
+func (n *Normalizer) Response(result interface{}, model string, chat bool) (interface{}, error) {
+	if n == nil {
+		return result, nil
+	}
+	response, err := decode(result)
+	if err != nil {
+		return nil, err
+	}
+
+	var choices []interface{}
+	var usage map[string]interface{}
+	switch {
+	case response["type"] == "message":
+		choices, usage = native(convert.AnthropicToOpenAIResponse(response), chat)
+	case response["done"] != nil:
+		choices, usage = native(convert.OllamaToOpenAIResponse(response), chat)
+	default:
+		var ok bool
+		if choices, ok = response["choices"].([]interface{}); !ok {
+			return nil, fmt.Errorf("%w: no choices", ErrNotOpenAI)
+		}
+		usage, _ = response["usage"].(map[string]interface{})
+	}
+
+	out := n.envelope(response, model, chat, false)
+	normalized := make([]interface{}, 0, len(choices))
+	for i, choice := range choices {
+		c, ok := choice.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: choices[%d] is not an object", ErrNotOpenAI, i)
+		}
+		normalized = append(normalized, normalizeChoice(c, i, chat, false))
+	}
+	out["choices"] = normalized
+	out["usage"] = normalizeUsage(usage)
+	return out, nil
+}
+
+// Stream returns upstream with every chunk in the OpenAI schema for model; chunks that carry
+// nothing the schema can hold are dropped. The returned channel must be read until it closes.
+func (n *Normalizer) Stream(upstream <-chan interface{}, model string, chat bool) <-chan interface{} {
+	if n == nil {
+		return upstream
+	}
+
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		s := &stream{Normalizer: n, model: model, chat: chat, tools: make(map[interface{}]float64)}
+		for chunk := range upstream {
+			normalized, err := s.chunk(chunk)
+			if err != nil {
+				slog.Warn("Dropping stream chunk in strict mode", "model", model, "error", err)
+				continue
+			}
+			if normalized != nil {
+				out <- normalized
+			}
+		}
+	}()
+	return out
+}
+
+// envelope returns the top-level fields of a response or chunk, taken from response where it has them.
+func (n *Normalizer) envelope(response map[string]interface{}, model string, chat, chunk bool) map[string]interface{} {
+	object := objectCompletion
+	switch {
+	case chat && chunk:
+		object = objectChatChunk
+	case chat:
+		object = objectChat
+	}
+	out := map[string]interface{}{
+		"id":      response["id"],
+		"object":  object,
+		"created": response["created"],
+		"model":   response["model"],
+	}
+	if id, ok := out["id"].(string); !ok || id == "" {
+		out["id"] = newID(chat)
+	}
+	if _, ok := out["created"].(float64); !ok {
+		out["created"] = n.now().Unix()
+	}
+	if name, ok := out["model"].(string); !ok || name == "" {
+		out["model"] = model
+	}
+	if fingerprint, ok := response["system_fingerprint"].(string); ok {
+		out["system_fingerprint"] = fingerprint
+	}
+	return out
+}
+
+// stream holds what a stream's chunks share: OpenAI streams keep one id, created time and model,
+// and native streams number their tool calls.
+type stream struct {
+	*Normalizer
+	model   string
+	chat    bool
+	id      interface{}
+	created interface{}
+	// tools numbers Anthropic tool_use blocks, by content block index, as tool calls
+	tools map[interface{}]float64
+}
+
+func (s *stream) chunk(chunk interface{}) (interface{}, error) {
+	event, err := decode(chunk)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := event["error"]; ok || event["type"] == "error" {
+		return streamError(event), nil
+	}
+
+	var choice map[string]interface{}
+	switch {
+	case event["type"] != nil && event["choices"] == nil:
+		if choice = s.anthropicEvent(event); choice == nil {
+			return nil, nil
+		}
+	case event["done"] != nil:
+		choice = ollamaChunk(event, s.chat)
+	default:
+		return s.openAIChunk(event)
+	}
+	return s.wrap(event, []interface{}{choice}, nil), nil
+}
+
+func (s *stream) openAIChunk(event map[string]interface{}) (interface{}, error) {
+	choices, ok := event["choices"].([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: chunk has no choices", ErrNotOpenAI)
+	}
+	normalized := make([]interface{}, 0, len(choices))
+	for i, choice := range choices {
+		c, ok := choice.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%w: choices[%d] is not an object", ErrNotOpenAI, i)
+		}
+		normalized = append(normalized, normalizeChoice(c, i, s.chat, true))
+	}
+	usage, _ := event["usage"].(map[string]interface{})
+	return s.wrap(event, normalized, usage), nil
+}
+
+// wrap returns choices as a chunk with the stream's envelope.
+func (s *stream) wrap(event map[string]interface{}, choices []interface{}, usage map[string]interface{}) interface{} {
+	out := s.envelope(event, s.model, s.chat, true)
+	// The first chunk's id and time hold for the whole stream
+	if s.id == nil {
+		s.id = out["id"]
+	}
+	if s.created == nil {
+		s.created = out["created"]
+	}
+	out["id"], out["created"] = s.id, s.created
+	out["choices"] = choices
+	if usage != nil {
+		out["usage"] = normalizeUsage(usage)
+	}
+	return out
+}
+
+// anthropicEvent returns the choice an Anthropic stream event adds, or nil for events that add
+// nothing a chunk can hold, such as pings and thinking.
+func (s *stream) anthropicEvent(event map[string]interface{}) map[string]interface{} {
+	delta := map[string]interface{}{}
+	var finishReason interface{}
+	switch event["type"] {
+	case "message_start":
+		message, _ := event["message"].(map[string]interface{})
+		if id, ok := message["id"].(string); ok {
+			s.id = id
+		}
+		delta["role"] = "assistant"
+		if s.chat {
+			delta["content"] = ""
+		}
+	case "content_block_start":
+		block, _ := event["content_block"].(map[string]interface{})
+		if block["type"] != "tool_use" || !s.chat {
+			return nil
+		}
+		index := float64(len(s.tools))
+		s.tools[event["index"]] = index
+		delta["tool_calls"] = []interface{}{map[string]interface{}{
+			"index": index, "id": block["id"], "type": "function",
+			"function": map[string]interface{}{"name": block["name"], "arguments": ""},
+		}}
+	case "content_block_delta":
+		d, _ := event["delta"].(map[string]interface{})
+		switch d["type"] {
+		case "text_delta":
+			delta["content"] = d["text"]
+		case "input_json_delta":
+			index, ok := s.tools[event["index"]]
+			if !ok || !s.chat {
+				return nil
+			}
+			delta["tool_calls"] = []interface{}{map[string]interface{}{
+				"index": index, "function": map[string]interface{}{"arguments": d["partial_json"]},
+			}}
+		default:
+			return nil
+		}
+	case "message_delta":
+		d, _ := event["delta"].(map[string]interface{})
+		reason, _ := d["stop_reason"].(string)
+		if reason == "" {
+			return nil
+		}
+		finishReason = convert.AnthropicFinishReason(reason)
+	default:
+		return nil
+	}
+	return chunkChoice(delta, finishReason, s.chat)
+}
+
+// chunkChoice returns the first choice of a chunk adding delta, or text unless chat.
+func chunkChoice(delta map[string]interface{}, finishReason interface{}, chat bool) map[string]interface{} {
+	choice := map[string]interface{}{"index": float64(0), "logprobs": nil, "finish_reason": finishReason}
+	if chat {
+		choice["delta"] = delta
+	} else {
+		text, _ := delta["content"].(string)
+		choice["text"] = text
+	}
+	return choice
+}
+
+// native returns the choices and usage of a native response converted to a chat completion, with
+// the text of each choice in place of its message unless chat.
+func native(completion map[string]interface{}, chat bool) ([]interface{}, map[string]interface{}) {
+	choices, _ := completion["choices"].([]interface{})
+	if !chat {
+		for _, choice := range choices {
+			c, _ := choice.(map[string]interface{})
+			message, _ := c["message"].(map[string]interface{})
+			text, _ := message["content"].(string)
+			c["text"] = text
+			delete(c, "message")
+		}
+	}
+	usage, _ := completion["usage"].(map[string]interface{})
+	return choices, usage
+}
+
+// ollamaChunk returns the choice of an Ollama stream line, which has the shape of a whole response.
+func ollamaChunk(event map[string]interface{}, chat bool) map[string]interface{} {
+	choices, _ := native(convert.OllamaToOpenAIResponse(event), true)
+	choice, _ := choices[0].(map[string]interface{})
+	delta, _ := choice["message"].(map[string]interface{})
+	if content, _ := delta["content"].(string); content == "" && event["done"] == true {
+		delete(delta, "content")
+	}
+	return chunkChoice(delta, choice["finish_reason"], chat)
+}
+
+// normalizeChoice keeps the schema's fields of choice i. Complete responses get a finish reason
+// and message even when the provider left them out. Indexes stay float64, as decoded JSON has
+// them, so later stages of a stream read them alike.
+func normalizeChoice(choice map[string]interface{}, i int, chat, chunk bool) map[string]interface{} {
+	index, ok := choice["index"].(float64)
+	if !ok {
+		index = float64(i)
+	}
+	out := map[string]interface{}{
+		"index":         index,
+		"logprobs":      choice["logprobs"],
+		"finish_reason": finishReason(choice["finish_reason"], chunk),
+	}
+	switch {
+	case chat && chunk:
+		delta, _ := choice["delta"].(map[string]interface{})
+		out["delta"] = normalizeMessage(delta, true)
+	case chat:
+		message, _ := choice["message"].(map[string]interface{})
+		out["message"] = normalizeMessage(message, false)
+	default:
+		text, _ := choice["text"].(string)
+		out["text"] = text
+	}
+	return out
+}
+
+// finishReason maps reason onto the schema's; only chunks may have none.
+func finishReason(reason interface{}, chunk bool) interface{} {
+	switch r := reason.(type) {
+	case string:
+		if slices.Contains(FinishReasons, r) {
+			return r
+		}
+		return convert.AnthropicFinishReason(r)
+	case nil:
+		if chunk {
+			return nil
+		}
+	}
+	return "stop"
+}
+
+// normalizeMessage keeps the schema's fields of a message, or of a delta, which only carries
+// the fields that changed.
+func normalizeMessage(message map[string]interface{}, delta bool) map[string]interface{} {
+	out := map[string]interface{}{}
+	if role, ok := message["role"].(string); ok || !delta {
+		if !ok {
+			role = "assistant"
+		}
+		out["role"] = role
+	}
+	content, isString := message["content"].(string)
+	switch {
+	case isString:
+		out["content"] = content
+	case !delta && message["tool_calls"] != nil:
+		out["content"] = nil
+	case !delta:
+		out["content"] = ""
+	}
+	if refusal, ok := message["refusal"].(string); ok {
+		out["refusal"] = refusal
+	}
+	if calls, ok := message["tool_calls"].([]interface{}); ok {
+		normalized := make([]interface{}, 0, len(calls))
+		for i, call := range calls {
+			if c, ok := call.(map[string]interface{}); ok {
+				normalized = append(normalized, normalizeToolCall(c, i, delta))
+			}
+		}
+		out["tool_calls"] = normalized
+	}
+	return out
+}
+
+// normalizeToolCall keeps the schema's fields of a tool call; streamed ones are numbered so their
+// fragments can be joined.
+func normalizeToolCall(call map[string]interface{}, i int, delta bool) map[string]interface{} {
+	out := map[string]interface{}{}
+	if delta {
+		index, ok := call["index"].(float64)
+		if !ok {
+			index = float64(i)
+		}
+		out["index"] = index
+	}
+	for _, field := range []string{"id", "type"} {
+		if value, ok := call[field].(string); ok {
+			out[field] = value
+		}
+	}
+	if _, ok := out["type"]; !ok && !delta {
+		out["type"] = "function"
+	}
+	if function, ok := call["function"].(map[string]interface{}); ok {
+		f := map[string]interface{}{}
+		for _, field := range []string{"name", "arguments"} {
+			if value, ok := function[field].(string); ok {
+				f[field] = value
+			}
+		}
+		out["function"] = f
+	}
+	return out
+}
+
+// normalizeUsage returns the token counts of usage, with the total computed when left out.
+func normalizeUsage(usage map[string]interface{}) map[string]interface{} {
+	prompt, _ := usage["prompt_tokens"].(float64)
+	completion, _ := usage["completion_tokens"].(float64)
+	total, ok := usage["total_tokens"].(float64)
+	if !ok {
+		total = prompt + completion
+	}
+	return map[string]interface{}{
+		"prompt_tokens": int64(prompt), "completion_tokens": int64(completion), "total_tokens": int64(total),
+	}
+}
+
+// streamError keeps the schema's fields of an error sent as a stream event.
+func streamError(event map[string]interface{}) interface{} {
+	e, _ := event["error"].(map[string]interface{})
+	message, _ := e["message"].(string)
+	if message == "" {
+		message = "Upstream stream error"
+	}
+	kind, _ := e["type"].(string)
+	if kind == "" {
+		kind = "provider_error"
+	}
+	return map[string]interface{}{"error": map[string]interface{}{
+		"message": message, "type": kind, "param": e["param"], "code": e["code"],
+	}}
+}
+
+// decode returns value as the JSON object a client would read, which also copies it.
+func decode(value interface{}) (map[string]interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(data, &response); err != nil || response == nil {
+		return nil, fmt.Errorf("%w: not a JSON object", ErrNotOpenAI)
+	}
+	return response, nil
+}
+
+// newID returns an id in the style of OpenAI's for responses that have none.
+func newID(chat bool) string {
+	prefix := "cmpl-"
+	if chat {
+		prefix = "chatcmpl-"
+	}
+	b := make([]byte, idBytes)
+	_, _ = rand.Read(b)
+	return prefix + hex.EncodeToString(b)
+}
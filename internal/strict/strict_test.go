@@ -0,0 +1,198 @@
+package strict
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/providers/conformance"
+	"github.com/modelplex/modelplex/internal/toolcalls"
+)
+
+func newNormalizer() *Normalizer {
+	n := New(true)
+	n.now = func() time.Time { return time.Unix(1700000000, 0) }
+	return n
+}
+
+func collect(stream <-chan interface{}) []interface{} {
+	var chunks []interface{}
+	for chunk := range stream {
+		chunks = append(chunks, chunk)
+	}
+	return chunks
+}
+
+func TestNormalizer_DisabledPassesThrough(t *testing.T) {
+	n := New(false)
+	result := map[string]interface{}{"content": "native"}
+	out, err := n.Response(result, "gpt-4", true)
+	require.NoError(t, err)
+	assert.Equal(t, result, out)
+}
+
+func TestNormalizer_Response(t *testing.T) {
+	result := map[string]interface{}{
+		"id": "chatcmpl-1", "object": "chat.completion", "model": "gpt-4", "provider": "openai",
+		"choices": []interface{}{map[string]interface{}{
+			"message":       map[string]interface{}{"content": "Hi", "reasoning_content": "greet"},
+			"finish_reason": "end_turn",
+			"extra":         true,
+		}},
+		"usage": map[string]interface{}{"prompt_tokens": float64(3), "completion_tokens": float64(1)},
+	}
+
+	out, err := newNormalizer().Response(result, "gpt-4", true)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{
+		"id": "chatcmpl-1", "object": "chat.completion", "created": int64(1700000000), "model": "gpt-4",
+		"choices": []interface{}{map[string]interface{}{
+			"index": float64(0), "logprobs": nil, "finish_reason": "stop",
+			"message": map[string]interface{}{"role": "assistant", "content": "Hi"},
+		}},
+		"usage": map[string]interface{}{"prompt_tokens": int64(3), "completion_tokens": int64(1), "total_tokens": int64(4)},
+	}, out)
+	assert.NoError(t, conformance.Check(conformance.CallChat, out))
+	// The original is left alone, since it may be shared
+	assert.Contains(t, result, "provider")
+}
+
+func TestNormalizer_ConvertsNativeResponses(t *testing.T) {
+	anthropic := map[string]interface{}{
+		"id": "msg_1", "type": "message", "role": "assistant", "model": "claude-3-sonnet",
+		"content": []interface{}{
+			map[string]interface{}{"type": "thinking", "thinking": "hmm"},
+			map[string]interface{}{"type": "text", "text": "Checking."},
+			map[string]interface{}{"type": "tool_use", "id": "toolu_1", "name": "weather",
+				"input": map[string]interface{}{"city": "Paris"}},
+		},
+		"stop_reason": "tool_use",
+		"usage":       map[string]interface{}{"input_tokens": float64(10), "output_tokens": float64(5)},
+	}
+	out, err := newNormalizer().Response(anthropic, "claude-3-sonnet", true)
+	require.NoError(t, err)
+	assert.NoError(t, conformance.Check(conformance.CallChat, out))
+	choice := out.(map[string]interface{})["choices"].([]interface{})[0].(map[string]interface{})
+	assert.Equal(t, "tool_calls", choice["finish_reason"])
+	assert.Equal(t, map[string]interface{}{
+		"role": "assistant", "content": "Checking.",
+		"tool_calls": []interface{}{map[string]interface{}{
+			"id": "toolu_1", "type": "function",
+			"function": map[string]interface{}{"name": "weather", "arguments": `{"city":"Paris"}`},
+		}},
+	}, choice["message"])
+
+	ollama := map[string]interface{}{
+		"model": "llama3", "response": "Once upon a time", "done": true, "done_reason": "length",
+		"prompt_eval_count": float64(4), "eval_count": float64(4),
+	}
+	out, err = newNormalizer().Response(ollama, "llama3", false)
+	require.NoError(t, err)
+	assert.NoError(t, conformance.Check(conformance.CallCompletion, out))
+	response := out.(map[string]interface{})
+	assert.Regexp(t, "^cmpl-", response["id"])
+	assert.Equal(t, "length", response["choices"].([]interface{})[0].(map[string]interface{})["finish_reason"])
+}
+
+func TestNormalizer_RejectsUnknownShapes(t *testing.T) {
+	_, err := newNormalizer().Response(map[string]interface{}{"output": "Hi"}, "gpt-4", true)
+	assert.ErrorIs(t, err, ErrNotOpenAI)
+	_, err = newNormalizer().Response("Hi", "gpt-4", true)
+	assert.ErrorIs(t, err, ErrNotOpenAI)
+}
+
+func TestNormalizer_Stream(t *testing.T) {
+	upstream := make(chan interface{}, 3)
+	upstream <- map[string]interface{}{
+		"id": "chatcmpl-1", "created": float64(1), "model": "gpt-4", "x_trace": "abc",
+		"choices": []interface{}{map[string]interface{}{"delta": map[string]interface{}{"content": "Hi"}}},
+	}
+	upstream <- map[string]interface{}{
+		"choices": []interface{}{map[string]interface{}{"delta": map[string]interface{}{}, "finish_reason": "stop"}},
+	}
+	upstream <- map[string]interface{}{"unexpected": true}
+	close(upstream)
+
+	chunks := collect(newNormalizer().Stream(upstream, "gpt-4", true))
+	require.Len(t, chunks, 2)
+	assert.Equal(t, map[string]interface{}{
+		"id": "chatcmpl-1", "object": "chat.completion.chunk", "created": float64(1), "model": "gpt-4",
+		"choices": []interface{}{map[string]interface{}{
+			"index": float64(0), "logprobs": nil, "finish_reason": nil, "delta": map[string]interface{}{"content": "Hi"},
+		}},
+	}, chunks[0])
+	// Later chunks share the first one's id and time
+	assert.Equal(t, "chatcmpl-1", chunks[1].(map[string]interface{})["id"])
+	assert.Equal(t, float64(1), chunks[1].(map[string]interface{})["created"])
+	assert.NoError(t, conformance.CheckStream(conformance.CallChatStream, chunks))
+}
+
+func TestNormalizer_ConvertsAnthropicStream(t *testing.T) {
+	events := []interface{}{
+		map[string]interface{}{"type": "message_start", "message": map[string]interface{}{"id": "msg_1"}},
+		map[string]interface{}{"type": "ping"},
+		map[string]interface{}{"type": "content_block_start", "index": float64(0),
+			"content_block": map[string]interface{}{"type": "text", "text": ""}},
+		map[string]interface{}{"type": "content_block_delta", "index": float64(0),
+			"delta": map[string]interface{}{"type": "text_delta", "text": "Hi"}},
+		map[string]interface{}{"type": "content_block_start", "index": float64(1),
+			"content_block": map[string]interface{}{"type": "tool_use", "id": "toolu_1", "name": "weather"}},
+		map[string]interface{}{"type": "content_block_delta", "index": float64(1),
+			"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": `{"city":`}},
+		map[string]interface{}{"type": "message_delta", "delta": map[string]interface{}{"stop_reason": "tool_use"}},
+		map[string]interface{}{"type": "message_stop"},
+	}
+	upstream := make(chan interface{}, len(events))
+	for _, event := range events {
+		upstream <- event
+	}
+	close(upstream)
+
+	chunks := collect(newNormalizer().Stream(upstream, "claude-3-sonnet", true))
+	require.Len(t, chunks, 5)
+	assert.NoError(t, conformance.CheckStream(conformance.CallChatStream, chunks))
+	delta := func(i int) map[string]interface{} {
+		choice := chunks[i].(map[string]interface{})["choices"].([]interface{})[0].(map[string]interface{})
+		return choice["delta"].(map[string]interface{})
+	}
+	assert.Equal(t, map[string]interface{}{"role": "assistant", "content": ""}, delta(0))
+	assert.Equal(t, map[string]interface{}{"content": "Hi"}, delta(1))
+	assert.Equal(t, "toolu_1", delta(2)["tool_calls"].([]interface{})[0].(map[string]interface{})["id"])
+	assert.Equal(t, map[string]interface{}{"tool_calls": []interface{}{map[string]interface{}{
+		"index": float64(0), "function": map[string]interface{}{"arguments": `{"city":`},
+	}}}, delta(3))
+	for _, chunk := range chunks {
+		assert.Equal(t, "msg_1", chunk.(map[string]interface{})["id"])
+	}
+}
+
+func TestNormalizer_StreamFeedsToolCallAssembly(t *testing.T) {
+	events := []interface{}{
+		map[string]interface{}{"type": "message_start", "message": map[string]interface{}{"id": "msg_1"}},
+		map[string]interface{}{"type": "content_block_start", "index": float64(0),
+			"content_block": map[string]interface{}{"type": "tool_use", "id": "toolu_1", "name": "weather"}},
+		map[string]interface{}{"type": "content_block_delta", "index": float64(0),
+			"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": `{"city":`}},
+		map[string]interface{}{"type": "content_block_delta", "index": float64(0),
+			"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": `"Paris"}`}},
+		map[string]interface{}{"type": "message_delta", "delta": map[string]interface{}{"stop_reason": "tool_use"}},
+	}
+	upstream := make(chan interface{}, len(events))
+	for _, event := range events {
+		upstream <- event
+	}
+	close(upstream)
+
+	var calls []interface{}
+	for _, chunk := range collect(toolcalls.Assemble(newNormalizer().Stream(upstream, "claude-3-sonnet", true))) {
+		choice := chunk.(map[string]interface{})["choices"].([]interface{})[0].(map[string]interface{})
+		if delta, ok := choice["delta"].(map[string]interface{}); ok && delta["tool_calls"] != nil {
+			calls = append(calls, delta["tool_calls"].([]interface{})...)
+		}
+	}
+	require.Len(t, calls, 1)
+	function := calls[0].(map[string]interface{})["function"].(map[string]interface{})
+	assert.Equal(t, `{"city":"Paris"}`, function["arguments"])
+}
@@ -0,0 +1,161 @@
+// Package tags counts requests by the tags clients attach to them, such as the team or experiment
+// a request belongs to. Tags become metric labels, so each tag keeps at most a configured number of
+// distinct values; requests with further values are counted under config.TagOverflow. In strict
+// privacy mode values are counted by a hash, so no label carries what a client sent.
+package tags
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/proxy"
+)
+
+// Counts are the totals of the requests carrying one tag value.
+type Counts struct {
+	Requests int64 `json:"requests"`
+	// Errors is the number of requests that failed before a response was returned
+	Errors int64 `json:"errors"`
+	// InputTokens and OutputTokens are summed from non-streaming responses' usage
+	InputTokens  int64 `json:"input_tokens"`
+	OutputTokens int64 `json:"output_tokens"`
+}
+
+// Stats collects counts per tag and value. It outlives a Multiplexer so counts survive config reloads.
+type Stats struct {
+	maxValues int64
+	hashed    bool
+
+	mtx  sync.Mutex
+	tags map[string]map[string]*Counts
+}
+
+// NewStats creates empty stats keeping at most maxValues distinct values per tag, counted by their
+// hashes when hashed.
+func NewStats(maxValues int64, hashed bool) *Stats {
+	return &Stats{maxValues: maxValues, hashed: hashed, tags: make(map[string]map[string]*Counts)}
+}
+
+// Snapshot returns a copy of the current counts keyed by tag, then value.
+func (s *Stats) Snapshot() map[string]map[string]Counts {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	out := make(map[string]map[string]Counts, len(s.tags))
+	for tag, values := range s.tags {
+		out[tag] = make(map[string]Counts, len(values))
+		for value, counts := range values {
+			out[tag][value] = *counts
+		}
+	}
+	return out
+}
+
+func (s *Stats) record(tags map[string]string, usage map[string]interface{}, err error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	for tag, value := range tags {
+		if s.hashed {
+			value = hashValue(value)
+		}
+		counts := s.counts(tag, value)
+		counts.Requests++
+		if err != nil {
+			counts.Errors++
+		}
+		counts.InputTokens += intField(usage, "prompt_tokens") + intField(usage, "input_tokens")
+		counts.OutputTokens += intField(usage, "completion_tokens") + intField(usage, "output_tokens")
+	}
+}
+
+// hashValue returns the label a tag value is counted under in strict privacy mode: the start of its
+// SHA-256, enough to tell values apart without revealing them.
+func hashValue(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:8])
+}
+
+// counts returns the counts of value, or of config.TagOverflow once tag has maxValues other values.
+func (s *Stats) counts(tag, value string) *Counts {
+	values, ok := s.tags[tag]
+	if !ok {
+		values = make(map[string]*Counts)
+		s.tags[tag] = values
+	}
+	if _, ok := values[value]; !ok && int64(len(values)) >= s.maxValues {
+		value = config.TagOverflow
+	}
+	counts, ok := values[value]
+	if !ok {
+		counts = &Counts{}
+		values[value] = counts
+	}
+	return counts
+}
+
+// Multiplexer wraps a multiplexer and counts the requests carrying tags in stats.
+type Multiplexer struct {
+	proxy.Multiplexer
+	stats *Stats
+}
+
+// NewMultiplexer wraps mux so tagged requests are counted in stats.
+func NewMultiplexer(mux proxy.Multiplexer, stats *Stats) *Multiplexer {
+	return &Multiplexer{Multiplexer: mux, stats: stats}
+}
+
+// ChatCompletion forwards the request and counts it with its usage.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	result, err := m.Multiplexer.ChatCompletion(ctx, model, messages)
+	m.record(ctx, result, err)
+	return result, err
+}
+
+// Completion forwards the request and counts it with its usage.
+func (m *Multiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	result, err := m.Multiplexer.Completion(ctx, model, prompt)
+	m.record(ctx, result, err)
+	return result, err
+}
+
+// ChatCompletionStream forwards the request and counts it. Streams report no usage here.
+func (m *Multiplexer) ChatCompletionStream(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (<-chan interface{}, error) {
+	stream, err := m.Multiplexer.ChatCompletionStream(ctx, model, messages)
+	m.record(ctx, nil, err)
+	return stream, err
+}
+
+// CompletionStream forwards the request and counts it. Streams report no usage here.
+func (m *Multiplexer) CompletionStream(ctx context.Context, model, prompt string) (<-chan interface{}, error) {
+	stream, err := m.Multiplexer.CompletionStream(ctx, model, prompt)
+	m.record(ctx, nil, err)
+	return stream, err
+}
+
+func (m *Multiplexer) record(ctx context.Context, result interface{}, err error) {
+	tags := metadata.Tags(ctx)
+	if len(tags) == 0 {
+		return
+	}
+	var usage map[string]interface{}
+	if response, ok := result.(map[string]interface{}); ok && err == nil {
+		usage, _ = response["usage"].(map[string]interface{})
+	}
+	m.stats.record(tags, usage, err)
+}
+
+func intField(m map[string]interface{}, key string) int64 {
+	if val, ok := m[key].(float64); ok {
+		return int64(val)
+	}
+	return 0
+}
@@ -0,0 +1,92 @@
+package tags
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/proxy"
+)
+
+// stubMultiplexer answers with a fixed usage, or fails with err.
+type stubMultiplexer struct {
+	proxy.Multiplexer
+	err error
+}
+
+func (m *stubMultiplexer) ChatCompletion(
+	_ context.Context, _ string, _ []map[string]interface{},
+) (interface{}, error) {
+	if m.err != nil {
+		return nil, m.err
+	}
+	return map[string]interface{}{
+		"usage": map[string]interface{}{"prompt_tokens": float64(10), "completion_tokens": float64(5)},
+	}, nil
+}
+
+func (m *stubMultiplexer) CompletionStream(_ context.Context, _, _ string) (<-chan interface{}, error) {
+	stream := make(chan interface{})
+	close(stream)
+	return stream, m.err
+}
+
+func TestMultiplexer_CountsTaggedRequests(t *testing.T) {
+	upstream := &stubMultiplexer{}
+	stats := NewStats(10, false)
+	mux := NewMultiplexer(upstream, stats)
+	ctx := metadata.WithTags(t.Context(), map[string]string{"team": "search", "experiment": "b"})
+
+	_, err := mux.ChatCompletion(ctx, "gpt-4", nil)
+	require.NoError(t, err)
+	_, err = mux.CompletionStream(ctx, "gpt-4", "Hi")
+	require.NoError(t, err)
+	upstream.err = errors.New("upstream down")
+	_, err = mux.ChatCompletion(ctx, "gpt-4", nil)
+	require.Error(t, err)
+
+	// Untagged requests aren't counted
+	upstream.err = nil
+	_, err = mux.ChatCompletion(t.Context(), "gpt-4", nil)
+	require.NoError(t, err)
+
+	want := Counts{Requests: 3, Errors: 1, InputTokens: 10, OutputTokens: 5}
+	assert.Equal(t, map[string]map[string]Counts{
+		"team":       {"search": want},
+		"experiment": {"b": want},
+	}, stats.Snapshot())
+}
+
+func TestStats_LimitsValuesPerTag(t *testing.T) {
+	stats := NewStats(2, false)
+	for _, team := range []string{"search", "ads", "search", "infra", "billing"} {
+		stats.record(map[string]string{"team": team}, nil, nil)
+	}
+
+	assert.Equal(t, map[string]Counts{
+		"search":           {Requests: 2},
+		"ads":              {Requests: 1},
+		config.TagOverflow: {Requests: 2},
+	}, stats.Snapshot()["team"])
+}
+
+func TestStats_HashesValuesInStrictPrivacyMode(t *testing.T) {
+	stats := NewStats(2, true)
+	for _, team := range []string{"search", "search", "ads", "infra"} {
+		stats.record(map[string]string{"team": team}, nil, nil)
+	}
+
+	hashed := hashValue("search")
+	assert.Len(t, hashed, 16)
+	assert.NotEqual(t, hashValue("ads"), hashed)
+	assert.Equal(t, map[string]Counts{
+		hashed:             {Requests: 2},
+		hashValue("ads"):   {Requests: 1},
+		config.TagOverflow: {Requests: 1},
+	}, stats.Snapshot()["team"], "no label carries a value as sent")
+}
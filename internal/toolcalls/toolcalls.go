@@ -0,0 +1,196 @@
+// Package toolcalls assembles tool calls streamed in fragments into whole ones, for clients that
+// can't parse partial argument JSON. OpenAI-style chunks carry each tool call's arguments across
+// many deltas, and Anthropic streams a tool_use block's input as partial JSON; both are buffered
+// and sent complete once the tool call has ended.
+package toolcalls
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Assemble returns upstream with every tool call sent whole. Chunks left with nothing to say once
+// their fragments are buffered are dropped. The returned channel must be read until it closes.
+func Assemble(upstream <-chan interface{}) <-chan interface{} {
+	out := make(chan interface{})
+	go func() {
+		defer close(out)
+		a := &assembler{calls: make(map[float64]map[float64]*call), inputs: make(map[float64]*strings.Builder)}
+		for chunk := range upstream {
+			for _, chunk := range a.chunk(chunk) {
+				out <- chunk
+			}
+		}
+		if rest := a.flush(); rest != nil {
+			out <- rest
+		}
+	}()
+	return out
+}
+
+// call is an OpenAI tool call being assembled.
+type call struct {
+	index     float64
+	id        string
+	kind      string
+	name      string
+	arguments strings.Builder
+}
+
+func (c *call) message() map[string]interface{} {
+	return map[string]interface{}{
+		"index": c.index,
+		"id":    c.id,
+		"type":  c.kind,
+		"function": map[string]interface{}{
+			"name":      c.name,
+			"arguments": c.arguments.String(),
+		},
+	}
+}
+
+type assembler struct {
+	// calls holds the OpenAI tool calls being assembled by choice and tool call index
+	calls map[float64]map[float64]*call
+	// inputs holds the input JSON of the Anthropic tool_use blocks being assembled by block index
+	inputs map[float64]*strings.Builder
+	// last is the most recent OpenAI chunk, whose id and model a flushed chunk reuses
+	last map[string]interface{}
+}
+
+// chunk returns what to send in place of chunk.
+func (a *assembler) chunk(chunk interface{}) []interface{} {
+	event, ok := chunk.(map[string]interface{})
+	if !ok {
+		return []interface{}{chunk}
+	}
+
+	switch event["type"] {
+	case "content_block_start":
+		block, _ := event["content_block"].(map[string]interface{})
+		if index, ok := event["index"].(float64); ok && block["type"] == "tool_use" {
+			a.inputs[index] = &strings.Builder{}
+		}
+		return []interface{}{event}
+	case "content_block_delta":
+		index, _ := event["index"].(float64)
+		delta, _ := event["delta"].(map[string]interface{})
+		if input, ok := a.inputs[index]; ok && delta["type"] == "input_json_delta" {
+			partial, _ := delta["partial_json"].(string)
+			input.WriteString(partial)
+			return nil
+		}
+		return []interface{}{event}
+	case "content_block_stop":
+		index, _ := event["index"].(float64)
+		input, ok := a.inputs[index]
+		if !ok {
+			return []interface{}{event}
+		}
+		delete(a.inputs, index)
+		whole := map[string]interface{}{
+			"type":  "content_block_delta",
+			"index": index,
+			"delta": map[string]interface{}{"type": "input_json_delta", "partial_json": input.String()},
+		}
+		return []interface{}{whole, event}
+	}
+
+	choices, ok := event["choices"].([]interface{})
+	if !ok {
+		return []interface{}{event}
+	}
+	a.last = event
+	keep := event["usage"] != nil
+	for _, choice := range choices {
+		c, _ := choice.(map[string]interface{})
+		delta, _ := c["delta"].(map[string]interface{})
+		if fragments, ok := delta["tool_calls"].([]interface{}); ok {
+			index, _ := c["index"].(float64)
+			a.buffer(index, fragments)
+			delete(delta, "tool_calls")
+		}
+		if c["finish_reason"] != nil {
+			index, _ := c["index"].(float64)
+			if calls := a.take(index); calls != nil {
+				if delta == nil {
+					delta = make(map[string]interface{})
+					c["delta"] = delta
+				}
+				delta["tool_calls"] = calls
+			}
+		}
+		keep = keep || len(delta) > 0 || c["finish_reason"] != nil
+	}
+	if !keep {
+		return nil
+	}
+	return []interface{}{event}
+}
+
+// buffer adds the tool call fragments of a choice's delta to the calls being assembled.
+func (a *assembler) buffer(choice float64, fragments []interface{}) {
+	calls, ok := a.calls[choice]
+	if !ok {
+		calls = make(map[float64]*call)
+		a.calls[choice] = calls
+	}
+	for _, fragment := range fragments {
+		f, _ := fragment.(map[string]interface{})
+		index, _ := f["index"].(float64)
+		c, ok := calls[index]
+		if !ok {
+			c = &call{index: index}
+			calls[index] = c
+		}
+		if id, ok := f["id"].(string); ok && id != "" {
+			c.id = id
+		}
+		if kind, ok := f["type"].(string); ok && kind != "" {
+			c.kind = kind
+		}
+		function, _ := f["function"].(map[string]interface{})
+		if name, ok := function["name"].(string); ok && name != "" {
+			c.name = name
+		}
+		arguments, _ := function["arguments"].(string)
+		c.arguments.WriteString(arguments)
+	}
+}
+
+// take returns the assembled tool calls of a choice in index order, or nil when it has none.
+func (a *assembler) take(choice float64) []interface{} {
+	calls, ok := a.calls[choice]
+	if !ok {
+		return nil
+	}
+	delete(a.calls, choice)
+	var assembled []interface{}
+	for _, index := range slices.Sorted(maps.Keys(calls)) {
+		assembled = append(assembled, calls[index].message())
+	}
+	return assembled
+}
+
+// flush returns a chunk with the tool calls of a stream that ended without a finish reason, or nil.
+func (a *assembler) flush() interface{} {
+	var choices []interface{}
+	for _, index := range slices.Sorted(maps.Keys(a.calls)) {
+		choices = append(choices, map[string]interface{}{
+			"index":         index,
+			"delta":         map[string]interface{}{"tool_calls": a.take(index)},
+			"finish_reason": nil,
+		})
+	}
+	if choices == nil {
+		return nil
+	}
+	chunk := map[string]interface{}{"object": "chat.completion.chunk", "choices": choices}
+	for _, field := range []string{"id", "created", "model"} {
+		if value, ok := a.last[field]; ok {
+			chunk[field] = value
+		}
+	}
+	return chunk
+}
@@ -0,0 +1,94 @@
+package toolcalls
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func assemble(t *testing.T, chunks ...string) []string {
+	t.Helper()
+	upstream := make(chan interface{}, len(chunks))
+	for _, chunk := range chunks {
+		var value interface{}
+		require.NoError(t, json.Unmarshal([]byte(chunk), &value))
+		upstream <- value
+	}
+	close(upstream)
+
+	var out []string
+	for chunk := range Assemble(upstream) {
+		data, err := json.Marshal(chunk)
+		require.NoError(t, err)
+		out = append(out, string(data))
+	}
+	return out
+}
+
+func TestAssemble_OpenAI(t *testing.T) {
+	out := assemble(t,
+		`{"id":"c1","choices":[{"index":0,"delta":{"role":"assistant","content":null,"tool_calls":[`+
+			`{"index":0,"id":"call_1","type":"function","function":{"name":"get_weather","arguments":""}}]}}]}`,
+		`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"{\"city\":"}}]}}]}`,
+		`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[{"index":0,"function":{"arguments":"\"Paris\"}"}}]}}]}`,
+		`{"id":"c1","choices":[{"index":0,"delta":{"tool_calls":[`+
+			`{"index":1,"id":"call_2","type":"function","function":{"name":"get_time","arguments":"{}"}}]}}]}`,
+		`{"id":"c1","choices":[{"index":0,"delta":{},"finish_reason":"tool_calls"}]}`,
+	)
+
+	assert.Equal(t, []string{
+		`{"choices":[{"delta":{"content":null,"role":"assistant"},"index":0}],"id":"c1"}`,
+		`{"choices":[{"delta":{"tool_calls":[` +
+			`{"function":{"arguments":"{\"city\":\"Paris\"}","name":"get_weather"},"id":"call_1","index":0,"type":"function"},` +
+			`{"function":{"arguments":"{}","name":"get_time"},"id":"call_2","index":1,"type":"function"}` +
+			`]},"finish_reason":"tool_calls","index":0}],"id":"c1"}`,
+	}, out)
+}
+
+func TestAssemble_OpenAIWithoutFinishReason(t *testing.T) {
+	out := assemble(t,
+		`{"id":"c1","model":"gpt-4","choices":[{"index":0,"delta":{"tool_calls":[`+
+			`{"index":0,"id":"call_1","type":"function","function":{"name":"ping","arguments":"{}"}}]}}]}`,
+	)
+
+	assert.Equal(t, []string{
+		`{"choices":[{"delta":{"tool_calls":[{"function":{"arguments":"{}","name":"ping"},"id":"call_1","index":0,` +
+			`"type":"function"}]},"finish_reason":null,"index":0}],"id":"c1","model":"gpt-4","object":"chat.completion.chunk"}`,
+	}, out)
+}
+
+func TestAssemble_Anthropic(t *testing.T) {
+	out := assemble(t,
+		`{"type":"content_block_start","index":0,"content_block":{"type":"text","text":""}}`,
+		`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"Checking"}}`,
+		`{"type":"content_block_stop","index":0}`,
+		`{"type":"content_block_start","index":1,"content_block":{"type":"tool_use","id":"tu_1","name":"get_weather","input":{}}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"{\"city\":"}}`,
+		`{"type":"content_block_delta","index":1,"delta":{"type":"input_json_delta","partial_json":"\"Paris\"}"}}`,
+		`{"type":"content_block_stop","index":1}`,
+	)
+
+	assert.Equal(t, []string{
+		`{"content_block":{"text":"","type":"text"},"index":0,"type":"content_block_start"}`,
+		`{"delta":{"text":"Checking","type":"text_delta"},"index":0,"type":"content_block_delta"}`,
+		`{"index":0,"type":"content_block_stop"}`,
+		`{"content_block":{"id":"tu_1","input":{},"name":"get_weather","type":"tool_use"},"index":1,"type":"content_block_start"}`,
+		`{"delta":{"partial_json":"{\"city\":\"Paris\"}","type":"input_json_delta"},"index":1,"type":"content_block_delta"}`,
+		`{"index":1,"type":"content_block_stop"}`,
+	}, out)
+}
+
+func TestAssemble_PassesOtherChunks(t *testing.T) {
+	chunks := []string{
+		`{"choices":[{"index":0,"delta":{"content":"Hi"}}]}`,
+		`{"choices":[],"usage":{"completion_tokens":1}}`,
+		`{"message":{"content":"Hi"},"done":true}`,
+	}
+	assert.Equal(t, []string{
+		`{"choices":[{"delta":{"content":"Hi"},"index":0}]}`,
+		`{"choices":[],"usage":{"completion_tokens":1}}`,
+		`{"done":true,"message":{"content":"Hi"}}`,
+	}, assemble(t, chunks...))
+}
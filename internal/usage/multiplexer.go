@@ -0,0 +1,47 @@
+package usage
+
+import (
+	"context"
+
+	"github.com/modelplex/modelplex/internal/proxy"
+)
+
+// Multiplexer wraps a multiplexer and records the usage reported by non-streaming responses.
+type Multiplexer struct {
+	proxy.Multiplexer
+	exporter *Exporter
+}
+
+// NewMultiplexer wraps mux so completions are recorded on exporter.
+func NewMultiplexer(mux proxy.Multiplexer, exporter *Exporter) *Multiplexer {
+	return &Multiplexer{Multiplexer: mux, exporter: exporter}
+}
+
+// ChatCompletion forwards the request and records its usage.
+func (m *Multiplexer) ChatCompletion(
+	ctx context.Context, model string, messages []map[string]interface{},
+) (interface{}, error) {
+	result, err := m.Multiplexer.ChatCompletion(ctx, model, messages)
+	m.record(ctx, model, result, err)
+	return result, err
+}
+
+// Completion forwards the request and records its usage.
+func (m *Multiplexer) Completion(ctx context.Context, model, prompt string) (interface{}, error) {
+	result, err := m.Multiplexer.Completion(ctx, model, prompt)
+	m.record(ctx, model, result, err)
+	return result, err
+}
+
+func (m *Multiplexer) record(ctx context.Context, model string, result interface{}, err error) {
+	if err != nil {
+		return
+	}
+	response, ok := result.(map[string]interface{})
+	if !ok {
+		return
+	}
+	if usage, ok := response["usage"].(map[string]interface{}); ok {
+		m.exporter.Record(ctx, model, usage)
+	}
+}
@@ -0,0 +1,272 @@
+// Package usage records per-request token usage and exports it to a metering endpoint.
+// Events are CloudEvents batches, the format accepted by OpenMeter and compatible
+// billing pipelines, so internal teams can be charged back for shared proxy usage.
+package usage
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/metadata"
+)
+
+const (
+	// DefaultTenant is billed when a request carries no tenant
+	DefaultTenant = "default"
+
+	// defaultInterval is how often pending events are exported
+	defaultInterval = config.DefaultUsageIntervalSeconds * time.Second
+	// maxPending bounds memory while the endpoint is unreachable; the oldest events are dropped
+	maxPending = 10000
+	// finalFlushTimeout bounds the flush performed on shutdown
+	finalFlushTimeout = 5 * time.Second
+	// tokensPerPriceUnit converts per-million-token prices
+	tokensPerPriceUnit = 1_000_000
+	eventIDBytes       = 16
+)
+
+type tenantKey struct{}
+
+// WithTenant returns a context carrying the tenant billed for requests made with it.
+func WithTenant(ctx context.Context, tenant string) context.Context {
+	return context.WithValue(ctx, tenantKey{}, tenant)
+}
+
+// TenantFrom returns the tenant carried by ctx, or DefaultTenant.
+func TenantFrom(ctx context.Context) string {
+	if tenant, ok := ctx.Value(tenantKey{}).(string); ok && tenant != "" {
+		return tenant
+	}
+	return DefaultTenant
+}
+
+// Event is a CloudEvents envelope for a single request's usage.
+type Event struct {
+	SpecVersion string    `json:"specversion"`
+	ID          string    `json:"id"`
+	Source      string    `json:"source"`
+	Type        string    `json:"type"`
+	Subject     string    `json:"subject"`
+	Time        time.Time `json:"time"`
+	Data        EventData `json:"data"`
+}
+
+// EventData is the usage payload of an Event.
+type EventData struct {
+	Model        string `json:"model"`
+	InputTokens  int64  `json:"input_tokens"`
+	OutputTokens int64  `json:"output_tokens"`
+	TotalTokens  int64  `json:"total_tokens"`
+	// CachedTokens and ReasoningTokens are the parts of the input and output tokens reported as
+	// read from the prompt cache and spent reasoning
+	CachedTokens    int64 `json:"cached_tokens,omitempty"`
+	ReasoningTokens int64 `json:"reasoning_tokens,omitempty"`
+	// Cost is what the provider reported the request cost, as OpenRouter does in credits, or else
+	// what the configured prices make it
+	Cost float64 `json:"cost,omitempty"`
+	// Metadata is the metadata object the client attached to the request
+	Metadata map[string]string `json:"metadata,omitempty"`
+	// Tags are the metadata pairs the config allows as tags, also used as metric labels
+	Tags map[string]string `json:"tags,omitempty"`
+}
+
+// Exporter buffers usage events and periodically posts them to the metering endpoint.
+type Exporter struct {
+	endpoint string
+	apiKey   string
+	interval time.Duration
+	prices   map[string]config.ModelPrice
+	client   *http.Client
+	now      func() time.Time
+
+	mtx     sync.Mutex
+	pending []Event
+}
+
+// NewExporter creates an exporter from cfg. The api key must already be resolved.
+func NewExporter(cfg *config.UsageConfig) *Exporter {
+	interval := time.Duration(cfg.IntervalSeconds) * time.Second
+	if interval <= 0 {
+		interval = defaultInterval
+	}
+
+	return &Exporter{
+		endpoint: cfg.Endpoint,
+		apiKey:   cfg.APIKey,
+		interval: interval,
+		prices:   cfg.Prices,
+		client:   &http.Client{},
+		now:      time.Now,
+	}
+}
+
+// Record queues a usage event for the tenant in ctx, based on an OpenAI-style usage object.
+// Anthropic-style input/output token names are accepted too, as are the cost and token details
+// OpenRouter adds.
+func (e *Exporter) Record(ctx context.Context, model string, usage map[string]interface{}) {
+	input := intField(usage, "prompt_tokens") + intField(usage, "input_tokens")
+	output := intField(usage, "completion_tokens") + intField(usage, "output_tokens")
+	total := intField(usage, "total_tokens")
+	if total == 0 {
+		total = input + output
+	}
+
+	data := EventData{
+		Model:        model,
+		InputTokens:  input,
+		OutputTokens: output,
+		TotalTokens:  total,
+		Metadata:     metadata.From(ctx),
+		Tags:         metadata.Tags(ctx),
+	}
+	if details, ok := usage["prompt_tokens_details"].(map[string]interface{}); ok {
+		data.CachedTokens = intField(details, "cached_tokens")
+	}
+	if details, ok := usage["completion_tokens_details"].(map[string]interface{}); ok {
+		data.ReasoningTokens = intField(details, "reasoning_tokens")
+	}
+	if cost, ok := usage["cost"].(float64); ok {
+		data.Cost = cost
+	} else if price, ok := e.prices[model]; ok {
+		data.Cost = Cost(price, input, output)
+	}
+
+	event := Event{
+		SpecVersion: "1.0",
+		ID:          newEventID(),
+		Source:      "modelplex",
+		Type:        "llm.usage",
+		Subject:     TenantFrom(ctx),
+		Time:        e.now().UTC(),
+		Data:        data,
+	}
+
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+	e.pending = append(e.pending, event)
+	if len(e.pending) > maxPending {
+		e.pending = e.pending[len(e.pending)-maxPending:]
+	}
+}
+
+// Forget strips the metadata and tags of the pending events of the requests subject matches,
+// returning how many. Their token counts stay, to be billed; events already exported are the
+// metering endpoint's to purge.
+func (e *Exporter) Forget(subject *erasure.Subject) int {
+	e.mtx.Lock()
+	defer e.mtx.Unlock()
+
+	forgotten := 0
+	for i := range e.pending {
+		data := &e.pending[i].Data
+		if (data.Metadata != nil || data.Tags != nil) && subject.Matches(e.pending[i].Subject, data.Metadata) {
+			data.Metadata, data.Tags = nil, nil
+			forgotten++
+		}
+	}
+	return forgotten
+}
+
+// Run exports pending events every interval until ctx is done, then flushes one last time.
+func (e *Exporter) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			if err := e.Flush(ctx); err != nil {
+				slog.Warn("Usage export failed, will retry", "error", err)
+			}
+		case <-ctx.Done():
+			flushCtx, cancel := context.WithTimeout(context.Background(), finalFlushTimeout)
+			if err := e.Flush(flushCtx); err != nil {
+				slog.Error("Final usage export failed", "error", err)
+			}
+			cancel()
+			return
+		}
+	}
+}
+
+// Flush posts all pending events as one batch. Events are kept for the next attempt on failure.
+func (e *Exporter) Flush(ctx context.Context) error {
+	e.mtx.Lock()
+	batch := e.pending
+	e.pending = nil
+	e.mtx.Unlock()
+
+	if len(batch) == 0 {
+		return nil
+	}
+
+	if err := e.post(ctx, batch); err != nil {
+		e.mtx.Lock()
+		e.pending = append(batch, e.pending...)
+		if len(e.pending) > maxPending {
+			e.pending = e.pending[len(e.pending)-maxPending:]
+		}
+		e.mtx.Unlock()
+		return err
+	}
+
+	slog.Debug("Exported usage events", "count", len(batch))
+	return nil
+}
+
+func (e *Exporter) post(ctx context.Context, batch []Event) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/cloudevents-batch+json")
+	if e.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+e.apiKey)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		respBody, _ := io.ReadAll(resp.Body)
+		return fmt.Errorf("usage export failed with status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+// Cost returns the cost of input and output tokens at price.
+func Cost(price config.ModelPrice, input, output int64) float64 {
+	return (float64(input)*price.Input + float64(output)*price.Output) / tokensPerPriceUnit
+}
+
+func intField(m map[string]interface{}, key string) int64 {
+	if val, ok := m[key].(float64); ok {
+		return int64(val)
+	}
+	return 0
+}
+
+func newEventID() string {
+	b := make([]byte, eventIDBytes)
+	_, _ = rand.Read(b) // crypto/rand.Read never returns an error
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,183 @@
+package usage
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+	"github.com/modelplex/modelplex/internal/erasure"
+	"github.com/modelplex/modelplex/internal/metadata"
+	"github.com/modelplex/modelplex/internal/proxy"
+)
+
+func TestTenantContext(t *testing.T) {
+	assert.Equal(t, DefaultTenant, TenantFrom(t.Context()))
+	assert.Equal(t, "team-a", TenantFrom(WithTenant(t.Context(), "team-a")))
+}
+
+func TestExporter_RecordAndFlush(t *testing.T) {
+	var received []Event
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/cloudevents-batch+json", r.Header.Get("Content-Type"))
+		assert.Equal(t, "Bearer meter-key", r.Header.Get("Authorization"))
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&received))
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	exporter := NewExporter(&config.UsageConfig{
+		Endpoint: server.URL,
+		APIKey:   "meter-key",
+		Prices:   map[string]config.ModelPrice{"gpt-4": {Input: 30, Output: 60}},
+	})
+
+	ctx := metadata.With(WithTenant(t.Context(), "team-a"), map[string]string{"task": "summarize"})
+	ctx = metadata.WithTags(ctx, map[string]string{"task": "summarize"})
+	exporter.Record(ctx, "gpt-4", map[string]interface{}{
+		"prompt_tokens": float64(1000), "completion_tokens": float64(500), "total_tokens": float64(1500),
+	})
+	exporter.Record(t.Context(), "claude-3-sonnet", map[string]interface{}{
+		"input_tokens": float64(10), "output_tokens": float64(20),
+	})
+
+	require.NoError(t, exporter.Flush(t.Context()))
+	require.Len(t, received, 2)
+
+	assert.Equal(t, "1.0", received[0].SpecVersion)
+	assert.Equal(t, "llm.usage", received[0].Type)
+	assert.Equal(t, "team-a", received[0].Subject)
+	assert.NotEmpty(t, received[0].ID)
+	assert.Equal(t, EventData{
+		Model: "gpt-4", InputTokens: 1000, OutputTokens: 500, TotalTokens: 1500, Cost: 0.06,
+		Metadata: map[string]string{"task": "summarize"}, Tags: map[string]string{"task": "summarize"},
+	}, received[0].Data)
+
+	assert.Equal(t, DefaultTenant, received[1].Subject)
+	assert.Equal(t, EventData{Model: "claude-3-sonnet", InputTokens: 10, OutputTokens: 20, TotalTokens: 30},
+		received[1].Data)
+
+	// Nothing left to send
+	received = nil
+	require.NoError(t, exporter.Flush(t.Context()))
+	assert.Nil(t, received)
+}
+
+func TestExporter_RecordReportedCost(t *testing.T) {
+	exporter := NewExporter(&config.UsageConfig{
+		Endpoint: "http://meter.invalid",
+		Prices:   map[string]config.ModelPrice{"anthropic/claude-sonnet-4": {Input: 3, Output: 15}},
+	})
+	exporter.Record(t.Context(), "anthropic/claude-sonnet-4", map[string]interface{}{
+		"prompt_tokens": float64(1000), "completion_tokens": float64(200), "total_tokens": float64(1200),
+		"cost": 0.0042, "prompt_tokens_details": map[string]interface{}{"cached_tokens": float64(800)},
+		"completion_tokens_details": map[string]interface{}{"reasoning_tokens": float64(150)},
+	})
+
+	require.Len(t, exporter.pending, 1)
+	assert.Equal(t, EventData{
+		Model: "anthropic/claude-sonnet-4", InputTokens: 1000, OutputTokens: 200, TotalTokens: 1200,
+		CachedTokens: 800, ReasoningTokens: 150, Cost: 0.0042,
+	}, exporter.pending[0].Data, "the reported cost wins over the configured prices")
+}
+
+func TestExporter_Forget(t *testing.T) {
+	exporter := NewExporter(&config.UsageConfig{Endpoint: "http://meter.invalid"})
+	usage := map[string]interface{}{"prompt_tokens": float64(10)}
+	for _, user := range []string{"u-1", "u-2"} {
+		ctx := metadata.With(WithTenant(t.Context(), "team-a"), map[string]string{"user": user})
+		exporter.Record(metadata.WithTags(ctx, map[string]string{"user": user}), "gpt-4", usage)
+	}
+	exporter.Record(t.Context(), "gpt-4", usage)
+
+	assert.Equal(t, 1, exporter.Forget(&erasure.Subject{Metadata: map[string]string{"user": "u-1"}}))
+	assert.Zero(t, exporter.Forget(&erasure.Subject{Metadata: map[string]string{"user": "u-1"}}))
+	require.Len(t, exporter.pending, 3, "token counts stay to be billed")
+	assert.Nil(t, exporter.pending[0].Data.Metadata)
+	assert.Nil(t, exporter.pending[0].Data.Tags)
+	assert.Equal(t, int64(10), exporter.pending[0].Data.InputTokens)
+	assert.Equal(t, map[string]string{"user": "u-2"}, exporter.pending[1].Data.Metadata)
+}
+
+func TestExporter_RetriesAfterFailure(t *testing.T) {
+	fail := true
+	batches := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if fail {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		batches++
+	}))
+	defer server.Close()
+
+	exporter := NewExporter(&config.UsageConfig{Endpoint: server.URL})
+	exporter.Record(t.Context(), "gpt-4", map[string]interface{}{"total_tokens": float64(5)})
+
+	err := exporter.Flush(t.Context())
+	assert.ErrorContains(t, err, "status 503")
+	assert.Len(t, exporter.pending, 1)
+
+	fail = false
+	require.NoError(t, exporter.Flush(t.Context()))
+	assert.Equal(t, 1, batches)
+	assert.Empty(t, exporter.pending)
+}
+
+func TestExporter_RunFlushesOnShutdown(t *testing.T) {
+	flushed := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, _ *http.Request) {
+		flushed <- struct{}{}
+	}))
+	defer server.Close()
+
+	exporter := NewExporter(&config.UsageConfig{Endpoint: server.URL, IntervalSeconds: 3600})
+	exporter.Record(t.Context(), "gpt-4", map[string]interface{}{"total_tokens": float64(5)})
+
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan struct{})
+	go func() {
+		exporter.Run(ctx)
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("exporter did not stop")
+	}
+	assert.Len(t, flushed, 1)
+}
+
+// fixedMultiplexer answers every completion with the same response.
+type fixedMultiplexer struct {
+	proxy.Multiplexer
+	response interface{}
+}
+
+func (m *fixedMultiplexer) ChatCompletion(
+	_ context.Context, _ string, _ []map[string]interface{},
+) (interface{}, error) {
+	return m.response, nil
+}
+
+func TestMultiplexer_RecordsUsage(t *testing.T) {
+	exporter := NewExporter(&config.UsageConfig{Endpoint: "http://unused"})
+	mux := NewMultiplexer(&fixedMultiplexer{response: map[string]interface{}{
+		"usage": map[string]interface{}{"prompt_tokens": float64(3), "completion_tokens": float64(4)},
+	}}, exporter)
+
+	_, err := mux.ChatCompletion(WithTenant(t.Context(), "team-b"), "gpt-4", nil)
+	require.NoError(t, err)
+
+	require.Len(t, exporter.pending, 1)
+	assert.Equal(t, "team-b", exporter.pending[0].Subject)
+	assert.Equal(t, int64(7), exporter.pending[0].Data.TotalTokens)
+}
@@ -0,0 +1,193 @@
+// Package version describes the running build and looks for newer releases of it.
+// Version, Commit and Date are set at link time by the release build.
+package version
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+var (
+	// Version is the release, or "dev" for builds that aren't one
+	Version = "dev"
+	Commit  = "unknown"
+	Date    = "unknown"
+)
+
+const (
+	// devVersion marks builds that aren't a release and so can't be compared with one
+	devVersion = "dev"
+	// checkTimeout bounds a single lookup of the latest release
+	checkTimeout = 10 * time.Second
+)
+
+// Info describes a build.
+type Info struct {
+	Version   string `json:"version"`
+	Commit    string `json:"commit"`
+	Date      string `json:"date"`
+	GoVersion string `json:"go_version"`
+}
+
+// Current returns the running build.
+func Current() Info {
+	return Info{Version: Version, Commit: Commit, Date: Date, GoVersion: runtime.Version()}
+}
+
+// Release is the part of a GitHub release object the checker reads.
+type Release struct {
+	TagName string `json:"tag_name"`
+	HTMLURL string `json:"html_url"`
+}
+
+// Checker looks up the latest release and logs when it is newer than the running one.
+type Checker struct {
+	url      string
+	interval time.Duration
+	current  string
+	client   *http.Client
+
+	mtx    sync.RWMutex
+	latest string
+}
+
+// NewChecker creates a checker from cfg comparing releases with current.
+func NewChecker(cfg *config.UpdatesConfig, current string) *Checker {
+	return &Checker{
+		url:      cfg.URL,
+		interval: time.Duration(cfg.IntervalHours) * time.Hour,
+		current:  current,
+		client:   &http.Client{Timeout: checkTimeout},
+	}
+}
+
+// Run checks at once and then every interval until ctx is cancelled. Development builds are never
+// checked since they can't be compared with a release.
+func (c *Checker) Run(ctx context.Context) {
+	if c.current == devVersion {
+		slog.Debug("Not checking for updates of a development build")
+		return
+	}
+
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+	for {
+		if err := c.Check(ctx); err != nil && ctx.Err() == nil {
+			slog.Warn("Update check failed, will retry", "error", err)
+		}
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// Check looks up the latest release once, logging it when it is newer than the running one.
+func (c *Checker) Check(ctx context.Context) error {
+	release, err := c.fetch(ctx)
+	if err != nil {
+		return err
+	}
+	c.mtx.Lock()
+	c.latest = release.TagName
+	c.mtx.Unlock()
+
+	if Newer(release.TagName, c.current) {
+		slog.Info("A newer modelplex release is available",
+			"current", c.current, "latest", release.TagName, "url", release.HTMLURL)
+	}
+	return nil
+}
+
+// Latest returns the latest release found so far, or "" before the first successful check.
+// A nil Checker has found none.
+func (c *Checker) Latest() string {
+	if c == nil {
+		return ""
+	}
+	c.mtx.RLock()
+	defer c.mtx.RUnlock()
+	return c.latest
+}
+
+func (c *Checker) fetch(ctx context.Context) (*Release, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, http.NoBody)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("User-Agent", "modelplex/"+c.current)
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("latest release lookup returned status %d", resp.StatusCode)
+	}
+
+	var release Release
+	if err := json.NewDecoder(resp.Body).Decode(&release); err != nil {
+		return nil, fmt.Errorf("invalid release: %w", err)
+	}
+	if release.TagName == "" {
+		return nil, errors.New("invalid release: tag_name is missing")
+	}
+	return &release, nil
+}
+
+// Newer reports whether release is a later version than current. Both are semantic versions with an
+// optional "v" prefix; a pre-release precedes its release. Versions that don't parse are never newer.
+func Newer(release, current string) bool {
+	r, rPre, ok := parse(release)
+	if !ok {
+		return false
+	}
+	c, cPre, ok := parse(current)
+	if !ok {
+		return false
+	}
+	for i := range r {
+		if r[i] != c[i] {
+			return r[i] > c[i]
+		}
+	}
+	return cPre && !rPre
+}
+
+// parse returns the major, minor and patch numbers of version and whether it is a pre-release.
+func parse(version string) (numbers [3]int, pre, ok bool) {
+	version = strings.TrimPrefix(version, "v")
+	// Build metadata doesn't affect precedence
+	version, _, _ = strings.Cut(version, "+")
+	version, suffix, pre := strings.Cut(version, "-")
+	if pre && suffix == "" {
+		return numbers, false, false
+	}
+
+	parts := strings.Split(version, ".")
+	if len(parts) != len(numbers) {
+		return numbers, false, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return numbers, false, false
+		}
+		numbers[i] = n
+	}
+	return numbers, pre, true
+}
@@ -0,0 +1,65 @@
+package version
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/modelplex/modelplex/internal/config"
+)
+
+func TestNewer(t *testing.T) {
+	tests := []struct {
+		release, current string
+		expected         bool
+	}{
+		{"v1.3.0", "v1.2.9", true},
+		{"1.2.10", "v1.2.9", true},
+		{"v2.0.0", "v1.9.9", true},
+		{"v1.2.3", "v1.2.3", false},
+		{"v1.2.2", "v1.2.3", false},
+		{"v1.2.3", "v1.2.3-rc.1", true},
+		{"v1.2.3-rc.2", "v1.2.3", false},
+		{"v1.2.3+build.5", "v1.2.3", false},
+		{"latest", "v1.2.3", false},
+		{"v1.3.0", "dev", false},
+		{"v1.3", "v1.2.0", false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.expected, Newer(tt.release, tt.current), "%s over %s", tt.release, tt.current)
+	}
+}
+
+func TestChecker_Check(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "modelplex/v1.0.0", r.Header.Get("User-Agent"))
+		_, _ = w.Write([]byte(`{"tag_name":"v1.1.0","html_url":"https://example.com/releases/v1.1.0"}`))
+	}))
+	defer server.Close()
+
+	checker := NewChecker(&config.UpdatesConfig{Check: true, IntervalHours: 24, URL: server.URL}, "v1.0.0")
+	assert.Empty(t, checker.Latest())
+	require.NoError(t, checker.Check(t.Context()))
+	assert.Equal(t, "v1.1.0", checker.Latest())
+}
+
+func TestChecker_CheckFails(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/limited" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_, _ = w.Write([]byte(`{"message":"Not Found"}`))
+	}))
+	defer server.Close()
+
+	checker := NewChecker(&config.UpdatesConfig{URL: server.URL + "/limited"}, "v1.0.0")
+	assert.EqualError(t, checker.Check(t.Context()), "latest release lookup returned status 403")
+
+	checker = NewChecker(&config.UpdatesConfig{URL: server.URL}, "v1.0.0")
+	assert.EqualError(t, checker.Check(t.Context()), "invalid release: tag_name is missing")
+	assert.Empty(t, checker.Latest())
+}
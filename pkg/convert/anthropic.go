@@ -0,0 +1,164 @@
+package convert
+
+import "strings"
+
+// anthropicStopReasons maps Anthropic stop reasons onto OpenAI finish reasons.
+var anthropicStopReasons = map[string]string{
+	"end_turn":      "stop",
+	"stop_sequence": "stop",
+	"pause_turn":    "stop",
+	"max_tokens":    "length",
+	"tool_use":      "tool_calls",
+	"refusal":       "content_filter",
+}
+
+// AnthropicFinishReason maps an Anthropic stop reason onto an OpenAI finish reason; unknown ones are stop.
+func AnthropicFinishReason(stopReason string) string {
+	if reason, ok := anthropicStopReasons[stopReason]; ok {
+		return reason
+	}
+	return "stop"
+}
+
+// OpenAIToAnthropicMessages converts OpenAI chat messages into the system prompt and messages of
+// an Anthropic Messages request. System messages are joined into the system prompt. Assistant tool
+// calls become tool_use blocks and tool messages tool_result blocks, with consecutive results in one
+// user message as Anthropic requires. Image URLs become image blocks. Other content, such as the
+// thinking blocks of earlier assistant turns, is passed on as is.
+func OpenAIToAnthropicMessages(messages []map[string]interface{}) (string, []map[string]interface{}) {
+	var system []string
+	out := make([]map[string]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		role, _ := msg["role"].(string)
+		switch role {
+		case "system":
+			if text := Text(msg["content"]); text != "" {
+				system = append(system, text)
+			}
+		case "tool":
+			result := map[string]interface{}{
+				"type":        "tool_result",
+				"tool_use_id": msg["tool_call_id"],
+				"content":     anthropicContent(msg["content"]),
+			}
+			if last := len(out) - 1; last >= 0 && isToolResults(out[last]) {
+				out[last]["content"] = append(out[last]["content"].([]interface{}), result)
+				continue
+			}
+			out = append(out, map[string]interface{}{"role": "user", "content": []interface{}{result}})
+		case "assistant":
+			out = append(out, map[string]interface{}{"role": role, "content": anthropicAssistantContent(msg)})
+		default:
+			out = append(out, map[string]interface{}{"role": role, "content": anthropicContent(msg["content"])})
+		}
+	}
+	return strings.Join(system, "\n\n"), out
+}
+
+// isToolResults reports whether message is a user message of tool results.
+func isToolResults(message map[string]interface{}) bool {
+	blocks, ok := message["content"].([]interface{})
+	if !ok || message["role"] != "user" || len(blocks) == 0 {
+		return false
+	}
+	first, _ := blocks[0].(map[string]interface{})
+	return first["type"] == "tool_result"
+}
+
+// anthropicAssistantContent returns the content of an assistant message, with its tool calls.
+func anthropicAssistantContent(msg map[string]interface{}) interface{} {
+	calls := toolCalls(msg)
+	if len(calls) == 0 {
+		return anthropicContent(msg["content"])
+	}
+
+	var blocks []interface{}
+	switch content := anthropicContent(msg["content"]).(type) {
+	case string:
+		if content != "" {
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": content})
+		}
+	case []interface{}:
+		blocks = append(blocks, content...)
+	}
+	for _, call := range calls {
+		function, _ := call["function"].(map[string]interface{})
+		blocks = append(blocks, map[string]interface{}{
+			"type":  "tool_use",
+			"id":    call["id"],
+			"name":  function["name"],
+			"input": decodeArguments(function["arguments"]),
+		})
+	}
+	return blocks
+}
+
+// anthropicContent converts the image parts of content; text parts already have Anthropic's shape.
+func anthropicContent(content interface{}) interface{} {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return content
+	}
+	out := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		p, ok := part.(map[string]interface{})
+		if !ok || p["type"] != "image_url" {
+			out = append(out, part)
+			continue
+		}
+		url := imageURL(p)
+		source := map[string]interface{}{"type": "url", "url": url}
+		if mediaType, data, ok := dataURL(url); ok {
+			source = map[string]interface{}{"type": "base64", "media_type": mediaType, "data": data}
+		}
+		out = append(out, map[string]interface{}{"type": "image", "source": source})
+	}
+	return out
+}
+
+// AnthropicToOpenAIResponse converts an Anthropic Messages response into an OpenAI chat completion.
+// Text blocks are joined into the message content and tool_use blocks become tool calls; thinking
+// blocks are left out. The created time is left to the caller, as Anthropic doesn't send one.
+func AnthropicToOpenAIResponse(response map[string]interface{}) map[string]interface{} {
+	var text strings.Builder
+	var calls []interface{}
+	blocks, _ := response["content"].([]interface{})
+	for _, block := range blocks {
+		b, _ := block.(map[string]interface{})
+		switch b["type"] {
+		case "text":
+			s, _ := b["text"].(string)
+			text.WriteString(s)
+		case "tool_use":
+			calls = append(calls, map[string]interface{}{
+				"id":   b["id"],
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      b["name"],
+					"arguments": encodeArguments(b["input"]),
+				},
+			})
+		}
+	}
+
+	message := map[string]interface{}{"role": "assistant", "content": text.String()}
+	if len(calls) > 0 {
+		message["tool_calls"] = calls
+		if text.Len() == 0 {
+			message["content"] = nil
+		}
+	}
+	stopReason, _ := response["stop_reason"].(string)
+	tokens, _ := response["usage"].(map[string]interface{})
+	return map[string]interface{}{
+		"id":     response["id"],
+		"object": "chat.completion",
+		"model":  response["model"],
+		"choices": []interface{}{map[string]interface{}{
+			"index":         float64(0),
+			"message":       message,
+			"finish_reason": AnthropicFinishReason(stopReason),
+		}},
+		"usage": usage(number(tokens["input_tokens"]), number(tokens["output_tokens"])),
+	}
+}
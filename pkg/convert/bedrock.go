@@ -0,0 +1,274 @@
+package convert
+
+import (
+	"errors"
+	"strings"
+)
+
+// converseStopReasons maps Bedrock Converse stop reasons onto OpenAI finish reasons.
+var converseStopReasons = map[string]string{
+	"end_turn":             "stop",
+	"stop_sequence":        "stop",
+	"max_tokens":           "length",
+	"tool_use":             "tool_calls",
+	"guardrail_intervened": "content_filter",
+	"content_filtered":     "content_filter",
+}
+
+// converseImageFormats are the image formats Converse takes, by media type.
+var converseImageFormats = map[string]string{
+	"image/png":  "png",
+	"image/jpeg": "jpeg",
+	"image/gif":  "gif",
+	"image/webp": "webp",
+}
+
+// ConverseFinishReason maps a Converse stop reason onto an OpenAI finish reason; unknown ones are stop.
+func ConverseFinishReason(stopReason string) string {
+	if reason, ok := converseStopReasons[stopReason]; ok {
+		return reason
+	}
+	return "stop"
+}
+
+// OpenAIToConverseMessages converts OpenAI chat messages into the system prompt and messages of a
+// Bedrock Converse request. System messages become system text blocks. Assistant tool calls become
+// toolUse blocks and tool messages toolResult blocks of a user message. Consecutive messages of one
+// role are merged, as Converse requires the roles to alternate. Base64 images become image blocks;
+// images given by URL are left out, as Converse only takes inline ones.
+func OpenAIToConverseMessages(messages []map[string]interface{}) ([]interface{}, []map[string]interface{}) {
+	var system []interface{}
+	out := make([]map[string]interface{}, 0, len(messages))
+	add := func(role string, blocks []interface{}) {
+		if len(blocks) == 0 {
+			return
+		}
+		if last := len(out) - 1; last >= 0 && out[last]["role"] == role {
+			out[last]["content"] = append(out[last]["content"].([]interface{}), blocks...)
+			return
+		}
+		out = append(out, map[string]interface{}{"role": role, "content": blocks})
+	}
+
+	for _, msg := range messages {
+		role, _ := msg["role"].(string)
+		switch role {
+		case "system", "developer":
+			if text := Text(msg["content"]); text != "" {
+				system = append(system, map[string]interface{}{"text": text})
+			}
+		case "tool":
+			add("user", []interface{}{map[string]interface{}{"toolResult": map[string]interface{}{
+				"toolUseId": msg["tool_call_id"],
+				"content":   []interface{}{map[string]interface{}{"text": Text(msg["content"])}},
+			}}})
+		case "assistant":
+			blocks := converseContent(msg["content"])
+			for _, call := range toolCalls(msg) {
+				function, _ := call["function"].(map[string]interface{})
+				blocks = append(blocks, map[string]interface{}{"toolUse": map[string]interface{}{
+					"toolUseId": call["id"],
+					"name":      function["name"],
+					"input":     decodeArguments(function["arguments"]),
+				}})
+			}
+			add(role, blocks)
+		default:
+			add("user", converseContent(msg["content"]))
+		}
+	}
+	return system, out
+}
+
+// converseContent converts message content, a string or an array of parts, into content blocks.
+func converseContent(content interface{}) []interface{} {
+	switch c := content.(type) {
+	case string:
+		if c == "" {
+			return nil
+		}
+		return []interface{}{map[string]interface{}{"text": c}}
+	case []interface{}:
+		blocks := make([]interface{}, 0, len(c))
+		for _, part := range c {
+			p, _ := part.(map[string]interface{})
+			switch p["type"] {
+			case "text":
+				if text, _ := p["text"].(string); text != "" {
+					blocks = append(blocks, map[string]interface{}{"text": text})
+				}
+			case "image_url":
+				mediaType, data, ok := dataURL(imageURL(p))
+				format, known := converseImageFormats[mediaType]
+				if ok && known {
+					blocks = append(blocks, map[string]interface{}{"image": map[string]interface{}{
+						"format": format,
+						"source": map[string]interface{}{"bytes": data},
+					}})
+				}
+			}
+		}
+		return blocks
+	}
+	return nil
+}
+
+// OpenAIToConverseTools converts OpenAI function tools into Converse tool specs.
+func OpenAIToConverseTools(tools []interface{}) []interface{} {
+	out := make([]interface{}, 0, len(tools))
+	for _, tool := range tools {
+		t, _ := tool.(map[string]interface{})
+		function, ok := t["function"].(map[string]interface{})
+		if !ok {
+			continue
+		}
+		schema := function["parameters"]
+		if schema == nil {
+			schema = map[string]interface{}{"type": "object", "properties": map[string]interface{}{}}
+		}
+		spec := map[string]interface{}{"name": function["name"], "inputSchema": map[string]interface{}{"json": schema}}
+		if description, ok := function["description"]; ok {
+			spec["description"] = description
+		}
+		out = append(out, map[string]interface{}{"toolSpec": spec})
+	}
+	return out
+}
+
+// OpenAIToConverseToolChoice converts an OpenAI tool_choice into a Converse one. Converse can't be
+// told not to use the tools it is given, so "none" is an error.
+func OpenAIToConverseToolChoice(choice interface{}) (map[string]interface{}, error) {
+	switch c := choice.(type) {
+	case string:
+		switch c {
+		case "auto":
+			return map[string]interface{}{"auto": map[string]interface{}{}}, nil
+		case "required":
+			return map[string]interface{}{"any": map[string]interface{}{}}, nil
+		case "none":
+			return nil, errors.New(`"none" is not supported, leave the tools out instead`)
+		}
+	case map[string]interface{}:
+		if function, ok := c["function"].(map[string]interface{}); ok && function["name"] != nil {
+			return map[string]interface{}{"tool": map[string]interface{}{"name": function["name"]}}, nil
+		}
+	}
+	return nil, errors.New(`must be "auto", "required", "none" or a function`)
+}
+
+// ConverseToOpenAIResponse converts a Converse response into an OpenAI chat completion. Text blocks
+// are joined into the message content and toolUse blocks become tool calls; reasoning blocks are
+// left out. The id, model and created time are left to the caller, as Converse sends none of them.
+func ConverseToOpenAIResponse(response map[string]interface{}) map[string]interface{} {
+	output, _ := response["output"].(map[string]interface{})
+	m, _ := output["message"].(map[string]interface{})
+	blocks, _ := m["content"].([]interface{})
+
+	var text strings.Builder
+	var calls []interface{}
+	for _, block := range blocks {
+		b, _ := block.(map[string]interface{})
+		if s, ok := b["text"].(string); ok {
+			text.WriteString(s)
+		}
+		if use, ok := b["toolUse"].(map[string]interface{}); ok {
+			calls = append(calls, map[string]interface{}{
+				"id":   use["toolUseId"],
+				"type": "function",
+				"function": map[string]interface{}{
+					"name":      use["name"],
+					"arguments": encodeArguments(use["input"]),
+				},
+			})
+		}
+	}
+
+	message := map[string]interface{}{"role": "assistant", "content": text.String()}
+	if len(calls) > 0 {
+		message["tool_calls"] = calls
+		if text.Len() == 0 {
+			message["content"] = nil
+		}
+	}
+	stopReason, _ := response["stopReason"].(string)
+	tokens, _ := response["usage"].(map[string]interface{})
+	return map[string]interface{}{
+		"object": "chat.completion",
+		"choices": []interface{}{map[string]interface{}{
+			"index":         float64(0),
+			"message":       message,
+			"finish_reason": ConverseFinishReason(stopReason),
+		}},
+		"usage": usage(number(tokens["inputTokens"]), number(tokens["outputTokens"])),
+	}
+}
+
+// ConverseStream converts the events of a Converse stream into OpenAI chat completion chunks. It
+// numbers the tool calls of the stream, so one ConverseStream serves one stream.
+type ConverseStream struct {
+	// calls maps the content block indexes of tool uses to their tool call indexes
+	calls map[float64]float64
+}
+
+// Chunk converts a stream event, given its type, into an OpenAI chunk, or nil for events without
+// one, such as a content block's end. The metadata event, which closes the stream, becomes a
+// chunk without choices carrying the usage. The id, model and created time are left to the caller.
+func (s *ConverseStream) Chunk(eventType string, event map[string]interface{}) map[string]interface{} {
+	switch eventType {
+	case "messageStart":
+		return converseChunk(map[string]interface{}{"role": "assistant", "content": ""}, nil)
+	case "contentBlockStart":
+		start, _ := event["start"].(map[string]interface{})
+		use, ok := start["toolUse"].(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		if s.calls == nil {
+			s.calls = make(map[float64]float64)
+		}
+		index := float64(len(s.calls))
+		s.calls[number(event["contentBlockIndex"])] = index
+		return converseChunk(map[string]interface{}{"tool_calls": []interface{}{map[string]interface{}{
+			"index":    index,
+			"id":       use["toolUseId"],
+			"type":     "function",
+			"function": map[string]interface{}{"name": use["name"], "arguments": ""},
+		}}}, nil)
+	case "contentBlockDelta":
+		delta, _ := event["delta"].(map[string]interface{})
+		if text, ok := delta["text"].(string); ok {
+			return converseChunk(map[string]interface{}{"content": text}, nil)
+		}
+		use, ok := delta["toolUse"].(map[string]interface{})
+		index, started := s.calls[number(event["contentBlockIndex"])]
+		if !ok || !started {
+			return nil
+		}
+		return converseChunk(map[string]interface{}{"tool_calls": []interface{}{map[string]interface{}{
+			"index":    index,
+			"function": map[string]interface{}{"arguments": use["input"]},
+		}}}, nil)
+	case "messageStop":
+		stopReason, _ := event["stopReason"].(string)
+		return converseChunk(map[string]interface{}{}, ConverseFinishReason(stopReason))
+	case "metadata":
+		tokens, _ := event["usage"].(map[string]interface{})
+		return map[string]interface{}{
+			"object":  "chat.completion.chunk",
+			"choices": []interface{}{},
+			"usage":   usage(number(tokens["inputTokens"]), number(tokens["outputTokens"])),
+		}
+	}
+	return nil
+}
+
+func converseChunk(delta map[string]interface{}, finishReason interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"object": "chat.completion.chunk",
+		"choices": []interface{}{map[string]interface{}{
+			"index":         float64(0),
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	}
+}
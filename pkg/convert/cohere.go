@@ -0,0 +1,230 @@
+package convert
+
+import "strings"
+
+// cohereFinishReasons maps Cohere v2 chat finish reasons onto OpenAI finish reasons.
+var cohereFinishReasons = map[string]string{
+	"COMPLETE":      "stop",
+	"STOP_SEQUENCE": "stop",
+	"MAX_TOKENS":    "length",
+	"TOOL_CALL":     "tool_calls",
+	"ERROR_TOXIC":   "content_filter",
+}
+
+// CohereFinishReason maps a Cohere finish reason onto an OpenAI finish reason; unknown ones are stop.
+func CohereFinishReason(finishReason string) string {
+	if reason, ok := cohereFinishReasons[finishReason]; ok {
+		return reason
+	}
+	return "stop"
+}
+
+// OpenAIToCohereMessages converts OpenAI chat messages into the messages of a Cohere v2 chat
+// request. System and developer messages are joined into one leading system message, v2's
+// counterpart of v1's preamble. Assistant tool calls and tool messages keep OpenAI's shape, which
+// v2 shares; content parts other than text and images are left out.
+func OpenAIToCohereMessages(messages []map[string]interface{}) []map[string]interface{} {
+	var preamble []string
+	out := make([]map[string]interface{}, 0, len(messages)+1)
+	for _, msg := range messages {
+		role, _ := msg["role"].(string)
+		switch role {
+		case "system", "developer":
+			if text := Text(msg["content"]); text != "" {
+				preamble = append(preamble, text)
+			}
+		case "tool":
+			out = append(out, map[string]interface{}{
+				"role":         "tool",
+				"tool_call_id": msg["tool_call_id"],
+				"content":      Text(msg["content"]),
+			})
+		case "assistant":
+			message := map[string]interface{}{"role": role}
+			if text := Text(msg["content"]); text != "" {
+				message["content"] = text
+			}
+			calls := toolCalls(msg)
+			if len(calls) > 0 {
+				converted := make([]interface{}, 0, len(calls))
+				for _, call := range calls {
+					function, _ := call["function"].(map[string]interface{})
+					converted = append(converted, map[string]interface{}{
+						"id":   call["id"],
+						"type": "function",
+						"function": map[string]interface{}{
+							"name":      function["name"],
+							"arguments": encodeArguments(decodeArguments(function["arguments"])),
+						},
+					})
+				}
+				message["tool_calls"] = converted
+			}
+			out = append(out, message)
+		default:
+			out = append(out, map[string]interface{}{"role": "user", "content": cohereContent(msg["content"])})
+		}
+	}
+	if len(preamble) > 0 {
+		system := map[string]interface{}{"role": "system", "content": strings.Join(preamble, "\n\n")}
+		out = append([]map[string]interface{}{system}, out...)
+	}
+	return out
+}
+
+// cohereContent converts user message content, a string or an array of parts, into v2 content.
+func cohereContent(content interface{}) interface{} {
+	parts, ok := content.([]interface{})
+	if !ok {
+		return Text(content)
+	}
+	out := make([]interface{}, 0, len(parts))
+	for _, part := range parts {
+		p, _ := part.(map[string]interface{})
+		switch p["type"] {
+		case "text":
+			out = append(out, map[string]interface{}{"type": "text", "text": p["text"]})
+		case "image_url":
+			if url := imageURL(p); url != "" {
+				out = append(out, map[string]interface{}{
+					"type": "image_url", "image_url": map[string]interface{}{"url": url},
+				})
+			}
+		}
+	}
+	return out
+}
+
+// CohereToOpenAIResponse converts a Cohere v2 chat response into an OpenAI chat completion. Text
+// content is joined into the message content and tool calls are kept; the tool plan, Cohere's
+// reasoning before calling tools, is left out. The model and created time are left to the caller,
+// as Cohere sends neither.
+func CohereToOpenAIResponse(response map[string]interface{}) map[string]interface{} {
+	m, _ := response["message"].(map[string]interface{})
+	var text strings.Builder
+	blocks, _ := m["content"].([]interface{})
+	for _, block := range blocks {
+		if b, ok := block.(map[string]interface{}); ok && b["type"] == "text" {
+			s, _ := b["text"].(string)
+			text.WriteString(s)
+		}
+	}
+
+	message := map[string]interface{}{"role": "assistant", "content": text.String()}
+	if calls := cohereToolCalls(m["tool_calls"]); len(calls) > 0 {
+		message["tool_calls"] = calls
+		if text.Len() == 0 {
+			message["content"] = nil
+		}
+	}
+	finishReason, _ := response["finish_reason"].(string)
+	return map[string]interface{}{
+		"id":     response["id"],
+		"object": "chat.completion",
+		"choices": []interface{}{map[string]interface{}{
+			"index":         float64(0),
+			"message":       message,
+			"finish_reason": CohereFinishReason(finishReason),
+		}},
+		"usage": cohereUsage(response["usage"]),
+	}
+}
+
+// cohereToolCalls converts the tool calls of a Cohere message into OpenAI ones.
+func cohereToolCalls(raw interface{}) []interface{} {
+	calls, _ := raw.([]interface{})
+	out := make([]interface{}, 0, len(calls))
+	for _, call := range calls {
+		c, _ := call.(map[string]interface{})
+		function, _ := c["function"].(map[string]interface{})
+		arguments, ok := function["arguments"].(string)
+		if !ok {
+			arguments = encodeArguments(function["arguments"])
+		}
+		out = append(out, map[string]interface{}{
+			"id":       c["id"],
+			"type":     "function",
+			"function": map[string]interface{}{"name": function["name"], "arguments": arguments},
+		})
+	}
+	return out
+}
+
+// cohereUsage returns the OpenAI token counts of Cohere usage: the tokens the model processed,
+// or the billed ones when those are missing.
+func cohereUsage(raw interface{}) map[string]interface{} {
+	u, _ := raw.(map[string]interface{})
+	tokens, ok := u["tokens"].(map[string]interface{})
+	if !ok {
+		tokens, _ = u["billed_units"].(map[string]interface{})
+	}
+	return usage(number(tokens["input_tokens"]), number(tokens["output_tokens"]))
+}
+
+// CohereStream converts the events of a Cohere v2 chat stream into OpenAI chat completion chunks.
+// It numbers the tool calls of the stream, so one CohereStream serves one stream.
+type CohereStream struct {
+	// calls maps the event indexes of tool calls to their tool call indexes
+	calls map[float64]float64
+}
+
+// Chunk converts a stream event into an OpenAI chunk, or nil for events without one, such as a
+// content block's start or the tool plan. The message-end event, which closes the stream, becomes
+// a chunk carrying the finish reason and the usage. The id, model and created time are left to
+// the caller.
+func (s *CohereStream) Chunk(event map[string]interface{}) map[string]interface{} {
+	delta, _ := event["delta"].(map[string]interface{})
+	message, _ := delta["message"].(map[string]interface{})
+	switch event["type"] {
+	case "message-start":
+		return cohereChunk(map[string]interface{}{"role": "assistant", "content": ""}, nil)
+	case "content-delta":
+		content, _ := message["content"].(map[string]interface{})
+		if text, ok := content["text"].(string); ok {
+			return cohereChunk(map[string]interface{}{"content": text}, nil)
+		}
+	case "tool-call-start":
+		call, _ := message["tool_calls"].(map[string]interface{})
+		function, _ := call["function"].(map[string]interface{})
+		if s.calls == nil {
+			s.calls = make(map[float64]float64)
+		}
+		index := float64(len(s.calls))
+		s.calls[number(event["index"])] = index
+		arguments, _ := function["arguments"].(string)
+		return cohereChunk(map[string]interface{}{"tool_calls": []interface{}{map[string]interface{}{
+			"index":    index,
+			"id":       call["id"],
+			"type":     "function",
+			"function": map[string]interface{}{"name": function["name"], "arguments": arguments},
+		}}}, nil)
+	case "tool-call-delta":
+		call, _ := message["tool_calls"].(map[string]interface{})
+		function, _ := call["function"].(map[string]interface{})
+		index, started := s.calls[number(event["index"])]
+		if !started {
+			return nil
+		}
+		return cohereChunk(map[string]interface{}{"tool_calls": []interface{}{map[string]interface{}{
+			"index":    index,
+			"function": map[string]interface{}{"arguments": function["arguments"]},
+		}}}, nil)
+	case "message-end":
+		finishReason, _ := delta["finish_reason"].(string)
+		chunk := cohereChunk(map[string]interface{}{}, CohereFinishReason(finishReason))
+		chunk["usage"] = cohereUsage(delta["usage"])
+		return chunk
+	}
+	return nil
+}
+
+func cohereChunk(delta map[string]interface{}, finishReason interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"object": "chat.completion.chunk",
+		"choices": []interface{}{map[string]interface{}{
+			"index":         float64(0),
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	}
+}
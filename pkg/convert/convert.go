@@ -0,0 +1,111 @@
+// Package convert translates chat requests and responses between the wire formats of OpenAI,
+// Anthropic, Gemini, Ollama, Bedrock's Converse API, Cohere's v2 chat API and Text Generation
+// Inference's generate API, which takes a prompt rendered from the messages. OpenAI's chat
+// completion format is the hub: every other format is converted to or from it, so any two can be
+// bridged through it.
+//
+// Messages, responses and parameters are the values encoding/json decodes into, maps, slices,
+// strings and float64 numbers, so request and response bodies can be converted without a typed
+// client of each API. Inputs are never modified.
+//
+// Every conversion is covered by golden test vectors in testdata/<conversion>/, each a JSON file
+// holding an input and the output expected of it. A fix comes with a vector for the case it fixes;
+// go test -update rewrites the expected outputs from the current code, to be reviewed in the diff.
+package convert
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Text joins the text of message content, which is a string or an array of content parts.
+func Text(content interface{}) string {
+	switch c := content.(type) {
+	case string:
+		return c
+	case []interface{}:
+		var b strings.Builder
+		for _, part := range c {
+			if p, ok := part.(map[string]interface{}); ok && p["type"] == "text" {
+				s, _ := p["text"].(string)
+				b.WriteString(s)
+			}
+		}
+		return b.String()
+	}
+	return ""
+}
+
+// encodeArguments returns the arguments object of a tool call as the JSON string OpenAI sends.
+func encodeArguments(args interface{}) string {
+	if args == nil {
+		return "{}"
+	}
+	data, err := json.Marshal(args)
+	if err != nil {
+		return "{}"
+	}
+	return string(data)
+}
+
+// decodeArguments returns the JSON string arguments of an OpenAI tool call as an object; arguments
+// that aren't a JSON object, such as the truncated ones of a cut-off reply, are an empty one.
+func decodeArguments(arguments interface{}) map[string]interface{} {
+	args := map[string]interface{}{}
+	if s, ok := arguments.(string); ok && s != "" {
+		if err := json.Unmarshal([]byte(s), &args); err != nil || args == nil {
+			return map[string]interface{}{}
+		}
+	}
+	return args
+}
+
+// number returns a decoded JSON number, or 0 when value isn't one.
+func number(value interface{}) float64 {
+	switch v := value.(type) {
+	case float64:
+		return v
+	case json.Number:
+		f, _ := v.Float64()
+		return f
+	}
+	return 0
+}
+
+// usage returns OpenAI token counts.
+func usage(prompt, completion float64) map[string]interface{} {
+	return map[string]interface{}{
+		"prompt_tokens":     prompt,
+		"completion_tokens": completion,
+		"total_tokens":      prompt + completion,
+	}
+}
+
+// toolCalls returns the tool calls of an OpenAI message.
+func toolCalls(message map[string]interface{}) []map[string]interface{} {
+	raw, _ := message["tool_calls"].([]interface{})
+	calls := make([]map[string]interface{}, 0, len(raw))
+	for _, call := range raw {
+		if c, ok := call.(map[string]interface{}); ok {
+			calls = append(calls, c)
+		}
+	}
+	return calls
+}
+
+// dataURL splits a base64 data URL into its media type and data.
+func dataURL(url string) (mediaType, data string, ok bool) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return "", "", false
+	}
+	mediaType, data, ok = strings.Cut(rest, ";base64,")
+	return mediaType, data, ok
+}
+
+// imageURL returns the URL of an OpenAI image_url content part.
+func imageURL(part map[string]interface{}) string {
+	image, _ := part["image_url"].(map[string]interface{})
+	url, _ := image["url"].(string)
+	return url
+}
@@ -0,0 +1,265 @@
+package convert
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected outputs of the test vectors")
+
+// vector is a test vector: an input and the output expected of converting it.
+type vector struct {
+	Input    json.RawMessage `json:"input"`
+	Expected json.RawMessage `json:"expected"`
+}
+
+// conversions run a conversion on the input of a vector, by the name of its testdata directory.
+var conversions = map[string]func(input []byte) (interface{}, error){
+	"openai_to_anthropic_messages": func(input []byte) (interface{}, error) {
+		var messages []map[string]interface{}
+		if err := json.Unmarshal(input, &messages); err != nil {
+			return nil, err
+		}
+		system, converted := OpenAIToAnthropicMessages(messages)
+		return map[string]interface{}{"system": system, "messages": converted}, nil
+	},
+	"anthropic_to_openai_response": func(input []byte) (interface{}, error) {
+		var response map[string]interface{}
+		if err := json.Unmarshal(input, &response); err != nil {
+			return nil, err
+		}
+		return AnthropicToOpenAIResponse(response), nil
+	},
+	"openai_to_ollama_messages": func(input []byte) (interface{}, error) {
+		var messages []map[string]interface{}
+		if err := json.Unmarshal(input, &messages); err != nil {
+			return nil, err
+		}
+		return OpenAIToOllamaMessages(messages), nil
+	},
+	"ollama_to_openai_response": func(input []byte) (interface{}, error) {
+		var response map[string]interface{}
+		if err := json.Unmarshal(input, &response); err != nil {
+			return nil, err
+		}
+		return OllamaToOpenAIResponse(response), nil
+	},
+	"gemini_to_openai_request": func(input []byte) (interface{}, error) {
+		var req GeminiRequest
+		if err := json.Unmarshal(input, &req); err != nil {
+			return nil, err
+		}
+		messages, err := GeminiToOpenAIMessages(&req)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}, nil
+		}
+		params, ignored := GeminiToOpenAIParams(&req)
+		return map[string]interface{}{"messages": messages, "params": params, "ignored": ignored}, nil
+	},
+	"openai_to_converse_messages": func(input []byte) (interface{}, error) {
+		var messages []map[string]interface{}
+		if err := json.Unmarshal(input, &messages); err != nil {
+			return nil, err
+		}
+		system, converted := OpenAIToConverseMessages(messages)
+		return map[string]interface{}{"system": system, "messages": converted}, nil
+	},
+	"openai_to_converse_tools": func(input []byte) (interface{}, error) {
+		var req struct {
+			Tools      []interface{} `json:"tools"`
+			ToolChoice interface{}   `json:"tool_choice"`
+		}
+		if err := json.Unmarshal(input, &req); err != nil {
+			return nil, err
+		}
+		out := map[string]interface{}{"tools": OpenAIToConverseTools(req.Tools)}
+		if choice, err := OpenAIToConverseToolChoice(req.ToolChoice); err != nil {
+			out["error"] = err.Error()
+		} else {
+			out["tool_choice"] = choice
+		}
+		return out, nil
+	},
+	"converse_to_openai_response": func(input []byte) (interface{}, error) {
+		var response map[string]interface{}
+		if err := json.Unmarshal(input, &response); err != nil {
+			return nil, err
+		}
+		return ConverseToOpenAIResponse(response), nil
+	},
+	"converse_stream_to_openai_chunks": func(input []byte) (interface{}, error) {
+		var events []struct {
+			Type  string                 `json:"type"`
+			Event map[string]interface{} `json:"event"`
+		}
+		if err := json.Unmarshal(input, &events); err != nil {
+			return nil, err
+		}
+		stream := &ConverseStream{}
+		chunks := make([]interface{}, 0, len(events))
+		for _, e := range events {
+			if chunk := stream.Chunk(e.Type, e.Event); chunk != nil {
+				chunks = append(chunks, chunk)
+			}
+		}
+		return chunks, nil
+	},
+	"openai_to_cohere_messages": func(input []byte) (interface{}, error) {
+		var messages []map[string]interface{}
+		if err := json.Unmarshal(input, &messages); err != nil {
+			return nil, err
+		}
+		return OpenAIToCohereMessages(messages), nil
+	},
+	"cohere_to_openai_response": func(input []byte) (interface{}, error) {
+		var response map[string]interface{}
+		if err := json.Unmarshal(input, &response); err != nil {
+			return nil, err
+		}
+		return CohereToOpenAIResponse(response), nil
+	},
+	"cohere_stream_to_openai_chunks": func(input []byte) (interface{}, error) {
+		var events []map[string]interface{}
+		if err := json.Unmarshal(input, &events); err != nil {
+			return nil, err
+		}
+		stream := &CohereStream{}
+		chunks := make([]interface{}, 0, len(events))
+		for _, event := range events {
+			if chunk := stream.Chunk(event); chunk != nil {
+				chunks = append(chunks, chunk)
+			}
+		}
+		return chunks, nil
+	},
+	"openai_to_tgi_prompt": func(input []byte) (interface{}, error) {
+		var req struct {
+			Template string                   `json:"template"`
+			Messages []map[string]interface{} `json:"messages"`
+		}
+		if err := json.Unmarshal(input, &req); err != nil {
+			return nil, err
+		}
+		prompt, stop, err := OpenAIToTGIPrompt(req.Messages, req.Template)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}, nil
+		}
+		return map[string]interface{}{"prompt": prompt, "stop": stop}, nil
+	},
+	"tgi_to_openai_response": func(input []byte) (interface{}, error) {
+		var req struct {
+			Response     map[string]interface{} `json:"response"`
+			Stops        []string               `json:"stops"`
+			PromptTokens float64                `json:"prompt_tokens"`
+		}
+		if err := json.Unmarshal(input, &req); err != nil {
+			return nil, err
+		}
+		return TGIToOpenAIResponse(req.Response, req.Stops, req.PromptTokens), nil
+	},
+	"tgi_stream_to_openai_chunks": func(input []byte) (interface{}, error) {
+		var req struct {
+			Events       []map[string]interface{} `json:"events"`
+			Stops        []string                 `json:"stops"`
+			PromptTokens float64                  `json:"prompt_tokens"`
+		}
+		if err := json.Unmarshal(input, &req); err != nil {
+			return nil, err
+		}
+		stream := &TGIStream{Stops: req.Stops, PromptTokens: req.PromptTokens}
+		chunks := make([]interface{}, 0, len(req.Events))
+		for _, event := range req.Events {
+			if chunk := stream.Chunk(event); chunk != nil {
+				chunks = append(chunks, chunk)
+			}
+		}
+		return chunks, nil
+	},
+	"openai_to_gemini_response": func(input []byte) (interface{}, error) {
+		var completion map[string]interface{}
+		if err := json.Unmarshal(input, &completion); err != nil {
+			return nil, err
+		}
+		return OpenAIToGeminiResponse(completion), nil
+	},
+}
+
+// TestVectors converts the input of every vector in testdata/<conversion>/ and compares the
+// output with the expected one; with -update, the expected outputs are rewritten instead.
+func TestVectors(t *testing.T) {
+	entries, err := os.ReadDir("testdata")
+	require.NoError(t, err)
+	tested := make(map[string]bool)
+	for _, entry := range entries {
+		conversion, ok := conversions[entry.Name()]
+		require.True(t, ok, "testdata/%s names no conversion", entry.Name())
+		tested[entry.Name()] = true
+
+		files, err := filepath.Glob(filepath.Join("testdata", entry.Name(), "*.json"))
+		require.NoError(t, err)
+		require.NotEmpty(t, files, "testdata/%s holds no vectors", entry.Name())
+		for _, file := range files {
+			name := entry.Name() + "/" + strings.TrimSuffix(filepath.Base(file), ".json")
+			t.Run(name, func(t *testing.T) {
+				runVector(t, file, conversion)
+			})
+		}
+	}
+	for name := range conversions {
+		assert.True(t, tested[name], "conversion %s has no vectors in testdata/%s", name, name)
+	}
+}
+
+func runVector(t *testing.T, file string, conversion func([]byte) (interface{}, error)) {
+	data, err := os.ReadFile(file)
+	require.NoError(t, err)
+	var v vector
+	require.NoError(t, json.Unmarshal(data, &v))
+
+	output, err := conversion(v.Input)
+	require.NoError(t, err)
+	got, err := json.Marshal(output)
+	require.NoError(t, err)
+
+	if *update {
+		v.Expected = got
+		data, err := json.MarshalIndent(v, "", "  ")
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(file, append(data, '\n'), 0o600))
+		return
+	}
+	require.NotEmpty(t, v.Expected, "vector has no expected output; run go test -update to record it")
+	assert.JSONEq(t, string(v.Expected), string(got))
+}
+
+func TestConversions_DoNotModifyInputs(t *testing.T) {
+	messages := []map[string]interface{}{
+		{"role": "user", "content": []interface{}{
+			map[string]interface{}{"type": "image_url",
+				"image_url": map[string]interface{}{"url": "data:image/png;base64,aGk="}},
+		}},
+		{"role": "assistant", "tool_calls": []interface{}{map[string]interface{}{
+			"id": "call_1", "type": "function",
+			"function": map[string]interface{}{"name": "weather", "arguments": `{"city":"Paris"}`},
+		}}},
+	}
+	before, err := json.Marshal(messages)
+	require.NoError(t, err)
+
+	OpenAIToAnthropicMessages(messages)
+	OpenAIToOllamaMessages(messages)
+	OpenAIToConverseMessages(messages)
+	OpenAIToCohereMessages(messages)
+	_, _, _ = OpenAIToTGIPrompt(messages, "mistral")
+
+	after, err := json.Marshal(messages)
+	require.NoError(t, err)
+	assert.JSONEq(t, string(before), string(after))
+}
@@ -0,0 +1,297 @@
+package convert
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// geminiGenerationParams maps generationConfig fields onto the OpenAI parameters they become.
+var geminiGenerationParams = map[string]string{
+	"temperature":      "temperature",
+	"topP":             "top_p",
+	"topK":             "top_k",
+	"maxOutputTokens":  "max_tokens",
+	"stopSequences":    "stop",
+	"candidateCount":   "n",
+	"presencePenalty":  "presence_penalty",
+	"frequencyPenalty": "frequency_penalty",
+	"seed":             "seed",
+}
+
+// geminiFinishReasons maps OpenAI finish reasons onto Gemini's; any other is OTHER.
+var geminiFinishReasons = map[string]string{
+	"stop":           "STOP",
+	"tool_calls":     "STOP",
+	"length":         "MAX_TOKENS",
+	"content_filter": "SAFETY",
+}
+
+// GeminiRequest is a Gemini generateContent request.
+type GeminiRequest struct {
+	Contents          []GeminiContent        `json:"contents"`
+	SystemInstruction *GeminiContent         `json:"systemInstruction,omitempty"`
+	Tools             []GeminiTool           `json:"tools,omitempty"`
+	GenerationConfig  map[string]interface{} `json:"generationConfig,omitempty"`
+}
+
+// GeminiContent is one turn of a Gemini conversation.
+type GeminiContent struct {
+	Role  string       `json:"role,omitempty"`
+	Parts []GeminiPart `json:"parts"`
+}
+
+// GeminiPart is a piece of a turn's content; exactly one of its fields is set.
+type GeminiPart struct {
+	Text             string                  `json:"text,omitempty"`
+	InlineData       *GeminiBlob             `json:"inlineData,omitempty"`
+	FunctionCall     *GeminiFunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *GeminiFunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// GeminiBlob is inline media, base64 encoded.
+type GeminiBlob struct {
+	MimeType string `json:"mimeType"`
+	Data     string `json:"data"`
+}
+
+// GeminiFunctionCall is a call of a declared function by the model.
+type GeminiFunctionCall struct {
+	ID   string                 `json:"id,omitempty"`
+	Name string                 `json:"name"`
+	Args map[string]interface{} `json:"args"`
+}
+
+// GeminiFunctionResponse is the result of a function call, sent back to the model.
+type GeminiFunctionResponse struct {
+	ID       string                 `json:"id,omitempty"`
+	Name     string                 `json:"name"`
+	Response map[string]interface{} `json:"response"`
+}
+
+// GeminiTool declares the functions the model may call.
+type GeminiTool struct {
+	FunctionDeclarations []GeminiFunctionDeclaration `json:"functionDeclarations"`
+}
+
+// GeminiFunctionDeclaration describes a function and its parameters as a JSON schema.
+type GeminiFunctionDeclaration struct {
+	Name        string                 `json:"name"`
+	Description string                 `json:"description,omitempty"`
+	Parameters  map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// GeminiToOpenAIMessages converts the system instruction and contents of a Gemini request into
+// OpenAI chat messages. Function calls become tool calls and function responses tool messages,
+// paired by id, or by function name for clients that send no ids. Inline media become image URLs.
+func GeminiToOpenAIMessages(req *GeminiRequest) ([]map[string]interface{}, error) {
+	if len(req.Contents) == 0 {
+		return nil, errors.New("contents must not be empty")
+	}
+	var messages []map[string]interface{}
+	if req.SystemInstruction != nil {
+		if system := geminiText(req.SystemInstruction.Parts); system != "" {
+			messages = append(messages, map[string]interface{}{"role": "system", "content": system})
+		}
+	}
+	for i, content := range req.Contents {
+		switch content.Role {
+		case "", "user":
+			messages = append(messages, geminiUserMessages(content.Parts)...)
+		case "model":
+			messages = append(messages, geminiModelMessage(content.Parts))
+		default:
+			return nil, fmt.Errorf("contents[%d] has unknown role %q", i, content.Role)
+		}
+	}
+	return messages, nil
+}
+
+// geminiUserMessages converts a user turn: function responses each become a tool message, and the
+// rest a user message, with content parts when the turn holds media.
+func geminiUserMessages(parts []GeminiPart) []map[string]interface{} {
+	var messages []map[string]interface{}
+	var content []interface{}
+	media := false
+	for _, part := range parts {
+		switch {
+		case part.FunctionResponse != nil:
+			messages = append(messages, map[string]interface{}{
+				"role":         "tool",
+				"tool_call_id": geminiCallID(part.FunctionResponse.ID, part.FunctionResponse.Name),
+				"content":      encodeArguments(part.FunctionResponse.Response),
+			})
+		case part.InlineData != nil:
+			media = true
+			content = append(content, map[string]interface{}{
+				"type": "image_url",
+				"image_url": map[string]interface{}{
+					"url": "data:" + part.InlineData.MimeType + ";base64," + part.InlineData.Data,
+				},
+			})
+		case part.Text != "":
+			content = append(content, map[string]interface{}{"type": "text", "text": part.Text})
+		}
+	}
+	if len(content) == 0 {
+		return messages
+	}
+	user := map[string]interface{}{"role": "user", "content": content}
+	if !media {
+		user["content"] = geminiText(parts)
+	}
+	return append(messages, user)
+}
+
+// geminiModelMessage converts a model turn, whose function calls become tool calls.
+func geminiModelMessage(parts []GeminiPart) map[string]interface{} {
+	message := map[string]interface{}{"role": "assistant", "content": geminiText(parts)}
+	var calls []interface{}
+	for _, part := range parts {
+		if part.FunctionCall == nil {
+			continue
+		}
+		calls = append(calls, map[string]interface{}{
+			"id":   geminiCallID(part.FunctionCall.ID, part.FunctionCall.Name),
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":      part.FunctionCall.Name,
+				"arguments": encodeArguments(part.FunctionCall.Args),
+			},
+		})
+	}
+	if calls != nil {
+		message["tool_calls"] = calls
+	}
+	return message
+}
+
+// geminiCallID identifies a function call by its id, or by its function's name.
+func geminiCallID(id, name string) string {
+	if id != "" {
+		return id
+	}
+	return name
+}
+
+// geminiText joins the text of parts.
+func geminiText(parts []GeminiPart) string {
+	var b strings.Builder
+	for _, part := range parts {
+		b.WriteString(part.Text)
+	}
+	return b.String()
+}
+
+// GeminiToOpenAIParams converts the generation config and tools of a Gemini request into OpenAI
+// chat completion parameters, and returns the generation config fields that have no equivalent.
+func GeminiToOpenAIParams(req *GeminiRequest) (params map[string]interface{}, ignored []string) {
+	params = make(map[string]interface{})
+	for field, value := range req.GenerationConfig {
+		if name, ok := geminiGenerationParams[field]; ok {
+			params[name] = value
+			continue
+		}
+		switch field {
+		case "responseMimeType":
+			if value == "application/json" {
+				params["response_format"] = map[string]interface{}{"type": "json_object"}
+			}
+		default:
+			ignored = append(ignored, field)
+		}
+	}
+	sort.Strings(ignored)
+
+	var tools []interface{}
+	for _, tool := range req.Tools {
+		for _, declaration := range tool.FunctionDeclarations {
+			function := map[string]interface{}{"name": declaration.Name}
+			if declaration.Description != "" {
+				function["description"] = declaration.Description
+			}
+			if declaration.Parameters != nil {
+				function["parameters"] = declaration.Parameters
+			}
+			tools = append(tools, map[string]interface{}{"type": "function", "function": function})
+		}
+	}
+	if tools != nil {
+		params["tools"] = tools
+	}
+	return params, ignored
+}
+
+// OpenAIToGeminiResponse converts an OpenAI chat completion, or a chunk of one, into a Gemini
+// generateContent response. Chunk candidates with nothing to say are left out.
+func OpenAIToGeminiResponse(completion map[string]interface{}) map[string]interface{} {
+	candidates := []interface{}{}
+	choices, _ := completion["choices"].([]interface{})
+	for i, choice := range choices {
+		c, _ := choice.(map[string]interface{})
+		message, ok := c["message"].(map[string]interface{})
+		if !ok {
+			message, _ = c["delta"].(map[string]interface{})
+		}
+		index, ok := c["index"].(float64)
+		if !ok {
+			index = float64(i)
+		}
+		parts := geminiParts(message)
+		candidate := map[string]interface{}{
+			"content": map[string]interface{}{"role": "model", "parts": parts},
+			"index":   index,
+		}
+		if reason, ok := c["finish_reason"].(string); ok {
+			candidate["finishReason"] = GeminiFinishReason(reason)
+		} else if len(parts) == 0 {
+			continue
+		}
+		candidates = append(candidates, candidate)
+	}
+
+	response := map[string]interface{}{"candidates": candidates}
+	if model, ok := completion["model"].(string); ok {
+		response["modelVersion"] = model
+	}
+	if id, ok := completion["id"].(string); ok {
+		response["responseId"] = id
+	}
+	if usage, ok := completion["usage"].(map[string]interface{}); ok {
+		response["usageMetadata"] = map[string]interface{}{
+			"promptTokenCount":     usage["prompt_tokens"],
+			"candidatesTokenCount": usage["completion_tokens"],
+			"totalTokenCount":      usage["total_tokens"],
+		}
+	}
+	return response
+}
+
+// GeminiFinishReason maps an OpenAI finish reason onto a Gemini one; unknown ones are OTHER.
+func GeminiFinishReason(reason string) string {
+	if mapped, ok := geminiFinishReasons[reason]; ok {
+		return mapped
+	}
+	return "OTHER"
+}
+
+// geminiParts converts the content and tool calls of an OpenAI message into parts.
+func geminiParts(message map[string]interface{}) []interface{} {
+	parts := []interface{}{}
+	if content := Text(message["content"]); content != "" {
+		parts = append(parts, map[string]interface{}{"text": content})
+	}
+	for _, call := range toolCalls(message) {
+		function, _ := call["function"].(map[string]interface{})
+		functionCall := map[string]interface{}{
+			"name": function["name"],
+			"args": decodeArguments(function["arguments"]),
+		}
+		if id, ok := call["id"].(string); ok && id != "" {
+			functionCall["id"] = id
+		}
+		parts = append(parts, map[string]interface{}{"functionCall": functionCall})
+	}
+	return parts
+}
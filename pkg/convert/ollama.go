@@ -0,0 +1,113 @@
+package convert
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+)
+
+// OpenAIToOllamaMessages converts OpenAI chat messages into those of an Ollama chat request. Content
+// parts are split into the text and the base64 images Ollama takes; images given by URL are left
+// out, as Ollama only takes inline ones. Tool call arguments are decoded into the objects Ollama
+// sends and expects back. Other fields are kept.
+func OpenAIToOllamaMessages(messages []map[string]interface{}) []map[string]interface{} {
+	out := make([]map[string]interface{}, 0, len(messages))
+	for _, msg := range messages {
+		converted := maps.Clone(msg)
+		if parts, ok := msg["content"].([]interface{}); ok {
+			var text strings.Builder
+			var images []interface{}
+			for _, part := range parts {
+				p, _ := part.(map[string]interface{})
+				switch p["type"] {
+				case "text":
+					s, _ := p["text"].(string)
+					text.WriteString(s)
+				case "image_url":
+					if _, data, ok := dataURL(imageURL(p)); ok {
+						images = append(images, data)
+					}
+				}
+			}
+			converted["content"] = text.String()
+			if images != nil {
+				converted["images"] = images
+			}
+		}
+		if calls := toolCalls(msg); len(calls) > 0 {
+			ollamaCalls := make([]interface{}, 0, len(calls))
+			for _, call := range calls {
+				function, _ := call["function"].(map[string]interface{})
+				ollamaCalls = append(ollamaCalls, map[string]interface{}{
+					"function": map[string]interface{}{
+						"name":      function["name"],
+						"arguments": decodeArguments(function["arguments"]),
+					},
+				})
+			}
+			converted["tool_calls"] = ollamaCalls
+		}
+		out = append(out, converted)
+	}
+	return out
+}
+
+// OllamaToOpenAIResponse converts an Ollama chat or generate response into an OpenAI chat completion.
+// Ollama streams lines of the same shape, which convert alike; only the last, done one has a finish
+// reason and usage. Ollama sends no id and no tool call ids, so tool calls are numbered call_0, call_1, and so on.
+func OllamaToOpenAIResponse(response map[string]interface{}) map[string]interface{} {
+	message := map[string]interface{}{"role": "assistant", "content": ""}
+	if m, ok := response["message"].(map[string]interface{}); ok {
+		message = ollamaMessage(m)
+	} else if text, ok := response["response"].(string); ok {
+		message["content"] = text
+	}
+
+	var finishReason interface{}
+	if response["done"] == true {
+		finishReason = "stop"
+		switch {
+		case response["done_reason"] == "length":
+			finishReason = "length"
+		case message["tool_calls"] != nil:
+			finishReason = "tool_calls"
+		}
+	}
+	completion := map[string]interface{}{
+		"object": "chat.completion",
+		"model":  response["model"],
+		"choices": []interface{}{map[string]interface{}{
+			"index":         float64(0),
+			"message":       message,
+			"finish_reason": finishReason,
+		}},
+	}
+	if finishReason != nil {
+		completion["usage"] = usage(number(response["prompt_eval_count"]), number(response["eval_count"]))
+	}
+	return completion
+}
+
+// ollamaMessage converts an Ollama message, whose tool calls carry arguments as objects.
+func ollamaMessage(message map[string]interface{}) map[string]interface{} {
+	content, _ := message["content"].(string)
+	out := map[string]interface{}{"role": "assistant", "content": content}
+	calls, _ := message["tool_calls"].([]interface{})
+	converted := make([]interface{}, 0, len(calls))
+	for i, call := range calls {
+		c, _ := call.(map[string]interface{})
+		function, _ := c["function"].(map[string]interface{})
+		converted = append(converted, map[string]interface{}{
+			"id":   fmt.Sprintf("call_%d", i),
+			"type": "function",
+			"function": map[string]interface{}{
+				"name":      function["name"],
+				"arguments": encodeArguments(function["arguments"]),
+			},
+		})
+	}
+	if len(converted) > 0 {
+		out["tool_calls"] = converted
+	}
+	return out
+}
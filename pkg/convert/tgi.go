@@ -0,0 +1,214 @@
+package convert
+
+import (
+	"fmt"
+	"strings"
+)
+
+// tgiTemplate is the prompt format of a model family, which Text Generation Inference's /generate
+// route expects instead of messages.
+type tgiTemplate struct {
+	// turn renders one message of role, which is system, user, assistant or tool
+	turn func(role, content string) string
+	// reply starts the assistant's reply, ending the prompt
+	reply string
+	// stop ends an assistant turn
+	stop string
+	// systemInUser prepends system messages to the next user message, for formats without a system role
+	systemInUser bool
+}
+
+// tgiTemplates are the supported prompt formats by name. The beginning of sequence token is left to
+// TGI, which adds it while tokenizing.
+var tgiTemplates = map[string]tgiTemplate{
+	"chatml": {
+		turn: func(role, content string) string {
+			return "<|im_start|>" + role + "\n" + content + "<|im_end|>\n"
+		},
+		reply: "<|im_start|>assistant\n",
+		stop:  "<|im_end|>",
+	},
+	"llama3": {
+		turn: func(role, content string) string {
+			return "<|start_header_id|>" + role + "<|end_header_id|>\n\n" + content + "<|eot_id|>"
+		},
+		reply: "<|start_header_id|>assistant<|end_header_id|>\n\n",
+		stop:  "<|eot_id|>",
+	},
+	"mistral": {
+		turn: func(role, content string) string {
+			if role == "assistant" {
+				return " " + content + "</s>"
+			}
+			return "[INST] " + content + " [/INST]"
+		},
+		stop:         "</s>",
+		systemInUser: true,
+	},
+}
+
+// OpenAIToTGIPrompt renders OpenAI chat messages as a prompt in the format of template, chatml,
+// llama3 or mistral, ending where the assistant's reply begins. It also returns the sequence that
+// ends the assistant's turn, for generation to stop on. Developer messages are system messages;
+// of assistant messages only the text is kept, as /generate knows no tools.
+func OpenAIToTGIPrompt(messages []map[string]interface{}, template string) (prompt, stop string, err error) {
+	t, ok := tgiTemplates[template]
+	if !ok {
+		return "", "", fmt.Errorf("unknown chat template %q", template)
+	}
+
+	var b strings.Builder
+	var system []string
+	for _, msg := range messages {
+		role, _ := msg["role"].(string)
+		content := Text(msg["content"])
+		switch role {
+		case "system", "developer":
+			if t.systemInUser {
+				if content != "" {
+					system = append(system, content)
+				}
+				continue
+			}
+			role = "system"
+		case "tool":
+			if t.systemInUser {
+				role = "user"
+			}
+		case "assistant":
+		default:
+			role = "user"
+		}
+		if role == "user" && len(system) > 0 {
+			content = strings.Join(append(system, content), "\n\n")
+			system = nil
+		}
+		b.WriteString(t.turn(role, content))
+	}
+	if len(system) > 0 {
+		b.WriteString(t.turn("user", strings.Join(system, "\n\n")))
+	}
+	b.WriteString(t.reply)
+	return b.String(), t.stop, nil
+}
+
+// TGIFinishReason maps a TGI finish reason onto an OpenAI finish reason: length is length, the end
+// of sequence token and stop sequences are stop.
+func TGIFinishReason(finishReason string) string {
+	if finishReason == "length" {
+		return "length"
+	}
+	return "stop"
+}
+
+// tgiText returns the text TGI generated without the stop sequence it ended on, which TGI keeps.
+func tgiText(text string, details map[string]interface{}, stops []string) string {
+	if details["finish_reason"] != "stop_sequence" {
+		return text
+	}
+	for _, stop := range stops {
+		if trimmed, ok := strings.CutSuffix(text, stop); ok {
+			return trimmed
+		}
+	}
+	return text
+}
+
+// tgiUsage returns the OpenAI token counts of a TGI generation. TGI counts the prompt's tokens only
+// in the details of newer servers' streams, so promptTokens stands in for them otherwise.
+func tgiUsage(details map[string]interface{}, promptTokens float64) map[string]interface{} {
+	if inputLength := number(details["input_length"]); inputLength > 0 {
+		promptTokens = inputLength
+	}
+	return usage(promptTokens, number(details["generated_tokens"]))
+}
+
+// TGIToOpenAIResponse converts the response of TGI's /generate, requested with details, into an
+// OpenAI chat completion. stops are the request's stop sequences, cut from the end of the text;
+// promptTokens is the prompt's size as the caller estimated it, since TGI doesn't report it. The
+// id, model and created time are left to the caller, as TGI sends none.
+func TGIToOpenAIResponse(response map[string]interface{}, stops []string, promptTokens float64) map[string]interface{} {
+	details, _ := response["details"].(map[string]interface{})
+	text, _ := response["generated_text"].(string)
+	finishReason, _ := details["finish_reason"].(string)
+	return map[string]interface{}{
+		"object": "chat.completion",
+		"choices": []interface{}{map[string]interface{}{
+			"index":         float64(0),
+			"message":       map[string]interface{}{"role": "assistant", "content": tgiText(text, details, stops)},
+			"finish_reason": TGIFinishReason(finishReason),
+		}},
+		"usage": tgiUsage(details, promptTokens),
+	}
+}
+
+// TGIStream converts the events of a TGI /generate_stream stream into OpenAI chat completion chunks.
+// It tracks the text streamed so far, so one TGIStream serves one stream.
+type TGIStream struct {
+	// Stops are the request's stop sequences, cut from the end of the text
+	Stops []string
+	// PromptTokens is the prompt's size as the caller estimated it, for servers that don't report it
+	PromptTokens float64
+
+	started bool
+	sent    strings.Builder
+}
+
+// Chunk converts a stream event, which carries a token, into an OpenAI chunk, or nil for a special
+// token such as the end of sequence. The last event, which carries the details, becomes a chunk with
+// the finish reason and the usage; its token is replaced by the rest of the generated text with the
+// stop sequence cut, so only a stop sequence spanning tokens may be partly streamed. An error event
+// becomes an error chunk. The id, model and created time are left to the caller.
+func (s *TGIStream) Chunk(event map[string]interface{}) map[string]interface{} {
+	if message, ok := event["error"].(string); ok {
+		return map[string]interface{}{"error": map[string]interface{}{"message": message, "type": event["error_type"]}}
+	}
+
+	token, _ := event["token"].(map[string]interface{})
+	text, _ := token["text"].(string)
+	if token["special"] == true {
+		text = ""
+	}
+	details, last := event["details"].(map[string]interface{})
+	if generated, ok := event["generated_text"].(string); ok && last {
+		rest, extends := strings.CutPrefix(tgiText(generated, details, s.Stops), s.sent.String())
+		switch {
+		case extends:
+			text = rest
+		case details["finish_reason"] == "stop_sequence":
+			// The stop sequence began in a token already streamed, whose text can't be taken back
+			text = ""
+		}
+	}
+	if text == "" && !last {
+		return nil
+	}
+	s.sent.WriteString(text)
+
+	delta := map[string]interface{}{}
+	if text != "" {
+		delta["content"] = text
+	}
+	if !s.started {
+		s.started = true
+		delta["role"] = "assistant"
+	}
+	if !last {
+		return tgiChunk(delta, nil)
+	}
+	finishReason, _ := details["finish_reason"].(string)
+	chunk := tgiChunk(delta, TGIFinishReason(finishReason))
+	chunk["usage"] = tgiUsage(details, s.PromptTokens)
+	return chunk
+}
+
+func tgiChunk(delta map[string]interface{}, finishReason interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"object": "chat.completion.chunk",
+		"choices": []interface{}{map[string]interface{}{
+			"index":         float64(0),
+			"delta":         delta,
+			"finish_reason": finishReason,
+		}},
+	}
+}
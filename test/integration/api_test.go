@@ -8,6 +8,8 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"testing"
 	"time"
 
@@ -197,6 +199,94 @@ func TestIntegration_ConfigValidation(t *testing.T) {
 	}
 }
 
+func TestIntegration_SocketPermissions(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+	tmpDir := t.TempDir()
+
+	t.Run("mode and group applied", func(t *testing.T) {
+		socketPath := filepath.Join(tmpDir, "restricted.socket")
+		srv := server.NewWithSocket(&config.Config{Server: config.Server{
+			SocketMode:  "0600",
+			SocketGroup: strconv.Itoa(os.Getgid()),
+		}}, socketPath)
+		cleanup := startServer(t, srv)
+		defer cleanup()
+
+		info, err := os.Stat(socketPath)
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0o600), info.Mode().Perm())
+	})
+
+	t.Run("unknown group removes the socket", func(t *testing.T) {
+		socketPath := filepath.Join(tmpDir, "unknown-group.socket")
+		srv := server.NewWithSocket(&config.Config{Server: config.Server{
+			SocketGroup: "modelplex-no-such-group",
+		}}, socketPath)
+
+		err := <-srv.Start()
+		require.ErrorContains(t, err, `unknown socket group "modelplex-no-such-group"`)
+		_, err = os.Stat(socketPath)
+		assert.True(t, os.IsNotExist(err), "socket should be removed, got %v", err)
+	})
+}
+
+func TestIntegration_AbstractSocket(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+	if runtime.GOOS != "linux" {
+		t.Skip("Abstract sockets are Linux only")
+	}
+
+	socketPath := "@modelplex-test-" + strconv.Itoa(os.Getpid())
+	srv := server.NewWithSocket(&config.Config{}, socketPath)
+	cleanup := startServer(t, srv)
+
+	response := makeUnixRequest(t, socketPath, "GET", "/health", nil)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	_, err := os.Stat(socketPath)
+	assert.True(t, os.IsNotExist(err), "abstract sockets have no file, got %v", err)
+
+	cleanup()
+	_, err = net.Dial("unix", socketPath)
+	assert.Error(t, err, "socket should be gone after stop")
+
+	srv = server.NewWithSocket(&config.Config{Server: config.Server{SocketMode: "0600"}}, socketPath)
+	assert.ErrorContains(t, <-srv.Start(), "has no permissions")
+}
+
+func TestIntegration_ListenerHandoff(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	socketPath := filepath.Join(t.TempDir(), "handoff.socket")
+	old := server.NewWithSocket(&config.Config{}, socketPath)
+	oldDone := old.Start()
+
+	file, err := old.ListenerFile()
+	require.NoError(t, err)
+	listener, err := net.FileListener(file)
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	upgraded := server.NewWithSocket(&config.Config{}, socketPath)
+	upgraded.Inherit(listener)
+	cleanup := startServer(t, upgraded)
+	defer cleanup()
+
+	old.Drain(t.Context())
+	<-oldDone
+
+	// The socket file stays for the upgraded server, which now serves every connection
+	response := makeUnixRequest(t, socketPath, "GET", "/health", nil)
+	defer response.Body.Close()
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+}
+
 // makeUnixRequest makes an HTTP request over a Unix socket
 func makeUnixRequest(t *testing.T, socketPath, method, path string, body *bytes.Reader) *http.Response {
 	client := &http.Client{
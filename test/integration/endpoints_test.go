@@ -7,6 +7,10 @@ import (
 	"io"
 	"net"
 	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -140,8 +144,7 @@ func TestIntegration_HTTPEndpoints(t *testing.T) {
 
 	t.Run("MCP Tools Endpoint", func(t *testing.T) {
 		testJSONEndpoint(t, client, baseURL+"/mcp/v1/tools", map[string]interface{}{
-			"tools":   nil,
-			"message": nil,
+			"tools": nil,
 		})
 	})
 
@@ -165,6 +168,25 @@ func TestIntegration_HTTPEndpoints(t *testing.T) {
 		assert.Contains(t, status, "address")
 	})
 
+	t.Run("Internal Version Endpoint", func(t *testing.T) {
+		req, _ := http.NewRequestWithContext(t.Context(), "GET", baseURL+"/_internal/version", http.NoBody)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var info map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&info)
+		require.NoError(t, err)
+
+		assert.Equal(t, "dev", info["version"])
+		assert.Equal(t, "unknown", info["commit"])
+		assert.Equal(t, "unknown", info["date"])
+		assert.Contains(t, info, "go_version")
+		assert.NotContains(t, info, "latest_release", "update checks are off by default")
+	})
+
 	t.Run("Internal Config Endpoint", func(t *testing.T) {
 		req, _ := http.NewRequestWithContext(t.Context(), "GET", baseURL+"/_internal/config", http.NoBody)
 		resp, err := client.Do(req)
@@ -292,6 +314,7 @@ func TestIntegration_SocketSecurity(t *testing.T) {
 			"/_internal/status",
 			"/_internal/config",
 			"/_internal/metrics",
+			"/_internal/version",
 		}
 
 		for _, endpoint := range internalEndpoints {
@@ -454,3 +477,811 @@ func testJSONEndpoint(t *testing.T, client *http.Client, url string, expectedFie
 		assert.Contains(t, responseData, field)
 	}
 }
+
+// TestIntegration_RateLimit tests that the global request limit is enforced on API routes
+func TestIntegration_RateLimit(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "test-openai", Type: "openai", BaseURL: "http://localhost:8080", Models: []string{"gpt-4"}},
+		},
+		Limits: config.Limits{RequestsPerMinute: 2},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	get := func(path string) int {
+		req, _ := http.NewRequestWithContext(t.Context(), "GET", baseURL+path, http.NoBody)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		return resp.StatusCode
+	}
+
+	assert.Equal(t, http.StatusOK, get("/v1/models"))
+	assert.Equal(t, http.StatusOK, get("/models/v1/models"))
+	assert.Equal(t, http.StatusTooManyRequests, get("/v1/models"))
+
+	// Health checks are never rate limited
+	assert.Equal(t, http.StatusOK, get("/health"))
+}
+
+// TestIntegration_CacheInvalidate tests the admin cache invalidation endpoint
+func TestIntegration_CacheInvalidate(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "test-openai", Type: "openai", BaseURL: "http://localhost:8080", Models: []string{"gpt-4"}},
+		},
+		Cache: config.CacheConfig{Enabled: true},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	for body, expected := range map[string]string{"": "all", `{"model":"gpt-4"}`: "gpt-4"} {
+		req, _ := http.NewRequestWithContext(t.Context(), "POST", baseURL+"/_internal/cache/invalidate",
+			strings.NewReader(body))
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+
+		var result map[string]interface{}
+		err = json.NewDecoder(resp.Body).Decode(&result)
+		_ = resp.Body.Close()
+		require.NoError(t, err)
+
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		assert.Equal(t, expected, result["invalidated"])
+	}
+}
+
+// TestIntegration_AdminAuth tests that admin endpoints require a token with a sufficient role
+func TestIntegration_AdminAuth(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "test-openai", Type: "openai", BaseURL: "http://localhost:8080", Models: []string{"gpt-4"}},
+		},
+		Cache: config.CacheConfig{Enabled: true},
+		Admin: config.AdminConfig{Tokens: []config.AdminToken{
+			{Token: "viewer-secret", Role: "viewer"},
+			{Token: "operator-secret", Role: "operator"},
+		}},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	tests := []struct {
+		method   string
+		path     string
+		token    string
+		expected int
+	}{
+		{"GET", "/_internal/status", "", http.StatusUnauthorized},
+		{"GET", "/_internal/status", "viewer-secret", http.StatusOK},
+		{"POST", "/_internal/cache/invalidate", "viewer-secret", http.StatusForbidden},
+		{"POST", "/_internal/cache/invalidate", "operator-secret", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequestWithContext(t.Context(), tt.method, baseURL+tt.path, http.NoBody)
+		if tt.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.token)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, tt.expected, resp.StatusCode, "%s %s", tt.method, tt.path)
+	}
+}
+
+// TestIntegration_ReadOnly tests that read-only mode blocks side effects but keeps serving reads
+func TestIntegration_ReadOnly(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "test-openai", Type: "openai", BaseURL: "http://localhost:8080", Models: []string{"gpt-4"}},
+		},
+		Server: config.Server{ReadOnly: true},
+		Cache:  config.CacheConfig{Enabled: true},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	tests := []struct {
+		method   string
+		path     string
+		expected int
+	}{
+		{"GET", "/v1/models", http.StatusOK},
+		{"GET", "/_internal/status", http.StatusOK},
+		{"GET", "/mcp/v1/tools", http.StatusOK},
+		{"POST", "/_internal/cache/invalidate", http.StatusForbidden},
+		{"POST", "/mcp/v1/tools/search/call", http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequestWithContext(t.Context(), tt.method, baseURL+tt.path, http.NoBody)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		assert.Equal(t, tt.expected, resp.StatusCode, "%s %s", tt.method, tt.path)
+	}
+}
+
+// TestIntegration_CapabilityTokens tests that tool calls need a token permitting the tool in their conversation
+func TestIntegration_CapabilityTokens(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	cfg := &config.Config{
+		MCP: config.MCPConfig{Capabilities: config.MCPCapabilities{
+			Required: true, Secret: "capability-signing-secret", TTLSeconds: 900,
+		}},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	req, _ := http.NewRequestWithContext(t.Context(), "POST", baseURL+"/_internal/capabilities",
+		strings.NewReader(`{"conversation_id":"c-1","tools":{"search":{"query":"go .*"}}}`))
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	var minted struct {
+		Token string `json:"token"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&minted))
+	_ = resp.Body.Close()
+	require.NotEmpty(t, minted.Token)
+
+	tests := []struct {
+		name     string
+		token    string
+		tool     string
+		body     string
+		expected int
+	}{
+		{"no token", "", "search", `{"metadata":{"conversation_id":"c-1"}}`, http.StatusUnauthorized},
+		{"another conversation", minted.Token, "search",
+			`{"arguments":{"query":"go modules"},"metadata":{"conversation_id":"c-2"}}`, http.StatusForbidden},
+		{"no conversation", minted.Token, "search", `{"arguments":{"query":"go modules"}}`, http.StatusForbidden},
+		{"tool not granted", minted.Token, "delete_file",
+			`{"arguments":{"path":"/"},"metadata":{"conversation_id":"c-1"}}`, http.StatusForbidden},
+		{"arguments outside the grant", minted.Token, "search",
+			`{"arguments":{"query":"rust"},"metadata":{"conversation_id":"c-1"}}`, http.StatusForbidden},
+		// Permitted calls reach the MCP servers, none of which offers the tool here
+		{"permitted", minted.Token, "search",
+			`{"arguments":{"query":"go modules"},"metadata":{"conversation_id":"c-1"}}`, http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req, _ := http.NewRequestWithContext(t.Context(), "POST", baseURL+"/mcp/v1/tools/"+tt.tool+"/call",
+				strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("X-Modelplex-Capability", tt.token)
+			}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			body, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			assert.Equal(t, tt.expected, resp.StatusCode, string(body))
+		})
+	}
+
+	req, _ = http.NewRequestWithContext(t.Context(), "GET", baseURL+"/_internal/config", http.NoBody)
+	resp, err = client.Do(req)
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.NotContains(t, string(body), "capability-signing-secret", "the secret mints tokens")
+	assert.Contains(t, string(body), config.Redacted)
+}
+
+// TestIntegration_SocketApprovals tests that operators decide on held tool calls over the socket
+func TestIntegration_SocketApprovals(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	cfg := &config.Config{
+		Admin: config.AdminConfig{Tokens: []config.AdminToken{
+			{Token: "viewer-secret", Role: "viewer"},
+			{Token: "operator-secret", Role: "operator"},
+		}},
+		MCP: config.MCPConfig{Approvals: config.MCPApprovals{
+			Rules: []config.ApprovalRule{{Tool: "delete_.*"}}, TimeoutSeconds: 30,
+		}},
+	}
+	socketPath := t.TempDir() + "/approvals.socket"
+	srv := server.NewWithSocket(cfg, socketPath)
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(_ context.Context, _, _ string) (net.Conn, error) {
+				return net.Dial("unix", socketPath)
+			},
+		},
+		Timeout: 5 * time.Second,
+	}
+	send := func(method, path, token, body string) *http.Response {
+		req, _ := http.NewRequestWithContext(t.Context(), method, "http://unix"+path, strings.NewReader(body))
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	called := make(chan int, 1)
+	go func() {
+		req, _ := http.NewRequestWithContext(t.Context(), "POST", "http://unix/mcp/v1/tools/delete_file/call",
+			strings.NewReader(`{"arguments":{"path":"/tmp/x"}}`))
+		resp, err := client.Do(req)
+		if err != nil {
+			called <- 0
+			return
+		}
+		_ = resp.Body.Close()
+		called <- resp.StatusCode
+	}()
+
+	var pending struct {
+		Approvals []struct {
+			ID string `json:"id"`
+		} `json:"approvals"`
+	}
+	require.Eventually(t, func() bool {
+		resp := send("GET", "/_internal/approvals", "operator-secret", "")
+		defer resp.Body.Close()
+		return resp.StatusCode == http.StatusOK && json.NewDecoder(resp.Body).Decode(&pending) == nil &&
+			len(pending.Approvals) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	path := "/_internal/approvals/" + pending.Approvals[0].ID
+	for token, expected := range map[string]int{"": http.StatusUnauthorized, "viewer-secret": http.StatusForbidden} {
+		resp := send("POST", path, token, `{"approved":true}`)
+		_ = resp.Body.Close()
+		assert.Equal(t, expected, resp.StatusCode, "token %q", token)
+	}
+	resp := send("POST", path, "operator-secret", `{"approved":true}`)
+	_ = resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// Approved, the call reaches the MCP servers, none of which offers the tool here
+	assert.Equal(t, http.StatusNotFound, <-called)
+}
+
+// TestIntegration_MaxRequestSize tests that oversized API request bodies are rejected
+func TestIntegration_MaxRequestSize(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "test-openai", Type: "openai", BaseURL: "http://localhost:8080", Models: []string{"gpt-4"}},
+		},
+		Server: config.Server{MaxRequestSize: 64},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	body := `{"model":"gpt-4","messages":[{"role":"user","content":"` + strings.Repeat("x", 100) + `"}]}`
+	req, _ := http.NewRequestWithContext(t.Context(), "POST",
+		fmt.Sprintf("http://127.0.0.1:%d/v1/chat/completions", port), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+
+	assert.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+}
+
+// TestIntegration_ProviderRaw tests forwarding raw requests to a provider with its credentials
+func TestIntegration_ProviderRaw(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	var upstreamAuth, upstreamPath string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upstreamAuth = r.Header.Get("Authorization")
+		upstreamPath = r.URL.RequestURI()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"object":"list","data":[]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "upstream", Type: "openai", BaseURL: upstream.URL + "/v1", APIKey: "sk-upstream"},
+		},
+		Admin: config.AdminConfig{Tokens: []config.AdminToken{
+			{Token: "viewer-secret", Role: "viewer"},
+			{Token: "operator-secret", Role: "operator"},
+		}},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	get := func(path, token string) *http.Response {
+		req, _ := http.NewRequestWithContext(t.Context(), "GET", baseURL+path, http.NoBody)
+		req.Header.Set("Authorization", "Bearer "+token)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp
+	}
+
+	resp := get("/providers/upstream/raw/files?purpose=batch", "operator-secret")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "/v1/files?purpose=batch", upstreamPath)
+	assert.Equal(t, "Bearer sk-upstream", upstreamAuth, "the admin token must be replaced by the provider key")
+
+	assert.Equal(t, http.StatusForbidden, get("/providers/upstream/raw/files", "viewer-secret").StatusCode)
+	assert.Equal(t, http.StatusNotFound, get("/providers/missing/raw/files", "operator-secret").StatusCode)
+}
+
+// TestIntegration_Chaos tests toggling fault injection through the admin endpoint
+func TestIntegration_Chaos(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"hi"}}]}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Providers: []config.Provider{{
+			Name: "flaky", Type: "openai", BaseURL: upstream.URL, Models: []string{"gpt-4"},
+			Faults: config.ProviderFaults{ErrorRate: 1},
+		}},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	post := func(path, body string) int {
+		req, _ := http.NewRequestWithContext(t.Context(), "POST", baseURL+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		_ = resp.Body.Close()
+		return resp.StatusCode
+	}
+	chat := `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`
+
+	assert.Equal(t, http.StatusOK, post("/v1/chat/completions", chat), "faults stay off until chaos is enabled")
+
+	assert.Equal(t, http.StatusOK, post("/_internal/chaos", `{"enabled":true}`))
+	assert.Equal(t, http.StatusInternalServerError, post("/v1/chat/completions", chat))
+
+	assert.Equal(t, http.StatusOK, post("/_internal/chaos", `{"enabled":false}`))
+	assert.Equal(t, http.StatusOK, post("/v1/chat/completions", chat))
+
+	assert.Equal(t, http.StatusBadRequest, post("/_internal/chaos", `{}`))
+}
+
+func TestIntegration_Coalesce(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		calls.Add(1)
+		<-release
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"hi"}}]}`))
+	}))
+	defer upstream.Close()
+	// Runs before upstream.Close so a failed test doesn't leave handlers blocked
+	var releaseOnce sync.Once
+	unblock := func() { releaseOnce.Do(func() { close(release) }) }
+	defer unblock()
+
+	cfg := &config.Config{
+		Providers: []config.Provider{{Name: "slow", Type: "openai", BaseURL: upstream.URL, Models: []string{"gpt-4"}}},
+		Coalesce:  config.CoalesceConfig{Enabled: true},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+	chat := `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}]}`
+
+	const callers = 3
+	var wg sync.WaitGroup
+	for range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			req, _ := http.NewRequestWithContext(t.Context(), "POST", baseURL+"/v1/chat/completions",
+				strings.NewReader(chat))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := client.Do(req)
+			if assert.NoError(t, err) {
+				assert.Equal(t, http.StatusOK, resp.StatusCode)
+				_ = resp.Body.Close()
+			}
+		}()
+	}
+
+	var metrics struct {
+		Coalescing map[string]struct {
+			UpstreamCalls int64 `json:"upstream_calls"`
+			Coalesced     int64 `json:"coalesced"`
+		} `json:"coalescing"`
+	}
+	require.Eventually(t, func() bool {
+		req, _ := http.NewRequestWithContext(t.Context(), "GET", baseURL+"/_internal/metrics", http.NoBody)
+		resp, err := client.Do(req)
+		if err != nil {
+			return false
+		}
+		defer resp.Body.Close()
+		return json.NewDecoder(resp.Body).Decode(&metrics) == nil &&
+			metrics.Coalescing["chat/completions"].Coalesced == callers-1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	unblock()
+	wg.Wait()
+	assert.Equal(t, int32(1), calls.Load())
+	assert.Equal(t, int64(1), metrics.Coalescing["chat/completions"].UpstreamCalls)
+}
+
+func TestIntegration_Tags(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","choices":[{"message":{"role":"assistant","content":"hi"}}],` +
+			`"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`))
+	}))
+	defer upstream.Close()
+
+	cfg := &config.Config{
+		Providers: []config.Provider{{Name: "openai", Type: "openai", BaseURL: upstream.URL, Models: []string{"gpt-4"}}},
+		Tags:      config.TagsConfig{Allowed: []string{"team"}, MaxValues: 1},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+	for _, team := range []string{"search", "ads", "search"} {
+		chat := `{"model":"gpt-4","messages":[{"role":"user","content":"hi"}],"metadata":{"team":"` + team + `"}}`
+		req, _ := http.NewRequestWithContext(t.Context(), "POST", baseURL+"/v1/chat/completions",
+			strings.NewReader(chat))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		assert.Equal(t, http.StatusOK, resp.StatusCode)
+		_ = resp.Body.Close()
+	}
+
+	req, _ := http.NewRequestWithContext(t.Context(), "GET", baseURL+"/_internal/metrics", http.NoBody)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	var metrics struct {
+		Tags map[string]map[string]struct {
+			Requests    int64 `json:"requests"`
+			InputTokens int64 `json:"input_tokens"`
+		} `json:"tags"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&metrics))
+	assert.Equal(t, int64(2), metrics.Tags["team"]["search"].Requests)
+	assert.Equal(t, int64(6), metrics.Tags["team"]["search"].InputTokens)
+	// Values beyond max_values share one label
+	assert.Equal(t, int64(1), metrics.Tags["team"][config.TagOverflow].Requests)
+}
+
+func TestIntegration_OpenAPI(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "test-openai", Type: "openai", BaseURL: "http://localhost:8080", Models: []string{"gpt-4"}},
+		},
+		OpenAPI: config.OpenAPIConfig{SwaggerUI: true},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	req, _ := http.NewRequestWithContext(t.Context(), "GET", baseURL+"/openapi.json", http.NoBody)
+	resp, err := client.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var doc struct {
+		OpenAPI string                                `json:"openapi"`
+		Paths   map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&doc))
+	assert.Equal(t, "3.1.0", doc.OpenAPI)
+	for path, method := range map[string]string{
+		"/v1/chat/completions":        "post",
+		"/models/v1/models":           "get",
+		"/mcp/v1/tools/{tool}/call":   "post",
+		"/_internal/metrics":          "get",
+		"/health":                     "get",
+		"/openapi.json":               "get",
+		"/docs":                       "get",
+		"/models/v1/streams/{token}":  "get",
+		"/_internal/cache/invalidate": "post",
+		"/_internal/streams/{id}":     "get",
+	} {
+		assert.Contains(t, doc.Paths[path], method, path)
+	}
+
+	req, _ = http.NewRequestWithContext(t.Context(), "GET", baseURL+"/docs", http.NoBody)
+	docs, err := client.Do(req)
+	require.NoError(t, err)
+	defer docs.Body.Close()
+	assert.Equal(t, http.StatusOK, docs.StatusCode)
+	assert.Contains(t, docs.Header.Get("Content-Type"), "text/html")
+}
+
+func TestIntegration_CountTokens(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	anthropic := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/messages/count_tokens", r.URL.Path)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"input_tokens":42}`))
+	}))
+	defer anthropic.Close()
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "anthropic", Type: "anthropic", BaseURL: anthropic.URL, Models: []string{"claude-3-sonnet"}},
+			{Name: "openai", Type: "openai", BaseURL: "http://localhost:8080", Models: []string{"gpt-4"}},
+		},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+	count := func(model string) (int64, string) {
+		body := `{"model":"` + model + `","messages":[{"role":"user","content":"Hello there, how are you?"}]}`
+		req, _ := http.NewRequestWithContext(t.Context(), "POST", baseURL+"/v1/messages/count_tokens",
+			strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var result struct {
+			InputTokens int64 `json:"input_tokens"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+		return result.InputTokens, resp.Header.Get(server.TokenCountHeader)
+	}
+
+	tokens, source := count("claude-3-sonnet")
+	assert.Equal(t, int64(42), tokens)
+	assert.Equal(t, "provider", source)
+
+	// Other providers can't count, so the prompt is estimated
+	tokens, source = count("gpt-4")
+	assert.Equal(t, int64(7), tokens)
+	assert.Equal(t, "estimated", source)
+}
+
+// TestIntegration_Gemini tests that Gemini's generateContent API is served by OpenAI-compatible providers
+func TestIntegration_Gemini(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	openai := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		assert.Equal(t, "/chat/completions", r.URL.Path)
+		assert.InDelta(t, 0.5, body["temperature"], 0)
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id":"chatcmpl-1","object":"chat.completion","model":"gpt-4",` +
+			`"choices":[{"index":0,"message":{"role":"assistant","content":"Bonjour"},"finish_reason":"stop"}],` +
+			`"usage":{"prompt_tokens":3,"completion_tokens":1,"total_tokens":4}}`))
+	}))
+	defer openai.Close()
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "openai", Type: "openai", BaseURL: openai.URL, Models: []string{"gpt-4"}},
+		},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	body := `{"contents":[{"role":"user","parts":[{"text":"Hello"}]}],"generationConfig":{"temperature":0.5}}`
+	req, _ := http.NewRequestWithContext(t.Context(), "POST",
+		fmt.Sprintf("http://127.0.0.1:%d/gemini/v1beta/models/gpt-4:generateContent", port), strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := (&http.Client{Timeout: 5 * time.Second}).Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	var result struct {
+		Candidates []struct {
+			Content struct {
+				Role  string `json:"role"`
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+			FinishReason string `json:"finishReason"`
+		} `json:"candidates"`
+		UsageMetadata struct {
+			TotalTokenCount int `json:"totalTokenCount"`
+		} `json:"usageMetadata"`
+	}
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&result))
+	require.Len(t, result.Candidates, 1)
+	assert.Equal(t, "model", result.Candidates[0].Content.Role)
+	assert.Equal(t, "Bonjour", result.Candidates[0].Content.Parts[0].Text)
+	assert.Equal(t, "STOP", result.Candidates[0].FinishReason)
+	assert.Equal(t, 4, result.UsageMetadata.TotalTokenCount)
+}
+
+// TestIntegration_Reload tests that a reloaded configuration is served without restarting
+func TestIntegration_Reload(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Skipping integration test in short mode")
+	}
+
+	cfg := &config.Config{
+		Providers: []config.Provider{
+			{Name: "test-openai", Type: "openai", BaseURL: "http://localhost:8080", Models: []string{"gpt-4"}},
+		},
+	}
+
+	port := getAvailablePort(t)
+	srv := server.NewWithHTTPAddress(cfg, fmt.Sprintf("127.0.0.1:%d", port))
+
+	cleanup := startServer(t, srv)
+	defer cleanup()
+
+	baseURL := fmt.Sprintf("http://127.0.0.1:%d", port)
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	listModels := func() []string {
+		req, _ := http.NewRequestWithContext(t.Context(), "GET", baseURL+"/v1/models", http.NoBody)
+		resp, err := client.Do(req)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+
+		var models struct {
+			Data []struct {
+				ID string `json:"id"`
+			} `json:"data"`
+		}
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&models))
+
+		ids := make([]string, len(models.Data))
+		for i, model := range models.Data {
+			ids[i] = model.ID
+		}
+		return ids
+	}
+
+	assert.Equal(t, []string{"gpt-4"}, listModels())
+
+	srv.Reload(&config.Config{
+		Providers: []config.Provider{
+			{Name: "test-ollama", Type: "ollama", BaseURL: "http://localhost:11434", Models: []string{"llama3"}},
+		},
+	})
+
+	assert.Equal(t, []string{"llama3"}, listModels())
+}